@@ -60,6 +60,13 @@ func main() {
 						Usage:    "eg. ./btc.csv",
 						Required: true,
 					},
+					&cli.BoolFlag{
+						Name:     "resume",
+						Aliases:  []string{"r"},
+						Usage:    "continue an interrupted download from the last saved candle",
+						Value:    false,
+						Required: false,
+					},
 					&cli.BoolFlag{
 						Name:     "futures",
 						Aliases:  []string{"f"},
@@ -93,6 +100,10 @@ func main() {
 						options = append(options, download.WithDays(days))
 					}
 
+					if c.Bool("resume") {
+						options = append(options, download.WithResume())
+					}
+
 					start := c.Timestamp("start")
 					end := c.Timestamp("end")
 					if start != nil && end != nil && !start.IsZero() && !end.IsZero() {
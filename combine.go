@@ -0,0 +1,120 @@
+package ninjabot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+)
+
+// CombineResults merges the results of separate runs, e.g. one backtest per pair, into the result of a
+// portfolio with all of them. The weight of each run scales its equity, trades, positions and profits, e.g.
+// 0.5 for half of the capital; nil weights are 1 for all runs. The equity curves are aligned on the union of
+// their times: a curve keeps its last value until the next point, and its first value before it starts.
+// The max drawdown and the Sharpe ratio are the ones of the combined curve. The metrics of each run are kept,
+// a pair in several runs has one entry per run.
+func CombineResults(results []Result, weights []float64) (Result, error) {
+	if weights == nil {
+		weights = make([]float64, len(results))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+
+	if len(weights) != len(results) {
+		return Result{}, fmt.Errorf("combine: %d weights for %d results", len(weights), len(results))
+	}
+
+	var combined Result
+	for i, result := range results {
+		weight := weights[i]
+		for _, metrics := range result.Metrics {
+			metrics.Profit *= weight
+			metrics.Volume *= weight
+			combined.Metrics = append(combined.Metrics, metrics)
+		}
+
+		for _, position := range result.Positions {
+			position.Quantity *= weight
+			combined.Positions = append(combined.Positions, position)
+		}
+
+		for _, trade := range result.Closed {
+			trade.ProfitValue *= weight
+			combined.Closed = append(combined.Closed, trade)
+		}
+
+		for _, trade := range result.Trades {
+			trade.Quantity *= weight
+			trade.Fee *= weight
+			trade.ProfitValue *= weight
+			combined.Trades = append(combined.Trades, trade)
+		}
+	}
+
+	sort.SliceStable(combined.Closed, func(i, j int) bool {
+		return combined.Closed[i].ExitTime.Before(combined.Closed[j].ExitTime)
+	})
+	sort.SliceStable(combined.Trades, func(i, j int) bool {
+		return combined.Trades[i].UpdatedAt.Before(combined.Trades[j].UpdatedAt)
+	})
+
+	combined.Equity = combineEquity(results, weights)
+	if len(combined.Equity) > 1 {
+		maxDrawdown, _, _ := exchange.MaxDrawdown(combined.Equity)
+		if !math.IsNaN(maxDrawdown) && !math.IsInf(maxDrawdown, 0) {
+			combined.MaxDrawdown = maxDrawdown
+		}
+	}
+	return combined, nil
+}
+
+// Fees returns the sum of the fees of the trades
+func (r Result) Fees() float64 {
+	var fees float64
+	for _, trade := range r.Trades {
+		fees += trade.Fee
+	}
+	return fees
+}
+
+// combineEquity returns the weighted sum of the equity curves on the union of their times, runs without
+// equity curve are not included
+func combineEquity(results []Result, weights []float64) []EquityPoint {
+	seen := make(map[time.Time]bool)
+	times := make([]time.Time, 0)
+	for _, result := range results {
+		for _, point := range result.Equity {
+			if !seen[point.Time] {
+				seen[point.Time] = true
+				times = append(times, point.Time)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+
+	equity := make([]EquityPoint, len(times))
+	for i, t := range times {
+		equity[i].Time = t
+	}
+
+	for i, result := range results {
+		if len(result.Equity) == 0 {
+			continue
+		}
+
+		// position of the last point of the curve at or before the time
+		position := 0
+		for j, t := range times {
+			for position+1 < len(result.Equity) && !result.Equity[position+1].Time.After(t) {
+				position++
+			}
+			equity[j].Value += weights[i] * result.Equity[position].Value
+		}
+	}
+	return equity
+}
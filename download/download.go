@@ -1,14 +1,19 @@
 package download
 
 import (
+	"bufio"
 	"context"
 	"encoding/csv"
+	"errors"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/schollz/progressbar/v3"
 	"github.com/xhit/go-str2duration/v2"
 
+	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/tools/log"
 )
@@ -26,8 +31,11 @@ func NewDownloader(exchange service.Feeder) Downloader {
 }
 
 type Parameters struct {
-	Start time.Time
-	End   time.Time
+	Start     time.Time
+	End       time.Time
+	BatchSize int
+	Resume    bool
+	Timestamp model.TimestampConvention
 }
 
 type Option func(*Parameters)
@@ -46,6 +54,59 @@ func WithDays(days int) Option {
 	}
 }
 
+// WithBatchSize sets the maximum number of candles requested to the exchange in a single call (default: 500)
+func WithBatchSize(size int) Option {
+	return func(parameters *Parameters) {
+		parameters.BatchSize = size
+	}
+}
+
+// WithResume continues an interrupted download, appending to the output file from its last saved candle
+func WithResume() Option {
+	return func(parameters *Parameters) {
+		parameters.Resume = true
+	}
+}
+
+// WithTimestampConvention declares the timestamp convention of the candles of the exchange, the candles
+// timestamped by close time are saved with the open time, the convention of the framework
+func WithTimestampConvention(convention model.TimestampConvention) Option {
+	return func(parameters *Parameters) {
+		parameters.Timestamp = convention
+	}
+}
+
+// lastSavedTime returns the time of the last candle written in a CSV file, or zero time if the file is empty
+func lastSavedTime(output string) (time.Time, error) {
+	file, err := os.Open(output)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	var lastLine string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lastLine = line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	timestamp, err := strconv.ParseInt(strings.Split(lastLine, ",")[0], 10, 64)
+	if err != nil {
+		// empty file or header only
+		return time.Time{}, nil
+	}
+
+	return time.Unix(timestamp, 0).UTC(), nil
+}
+
 func candlesCount(start, end time.Time, timeframe string) (int, time.Duration, error) {
 	totalDuration := end.Sub(start)
 	interval, err := str2duration.ParseDuration(timeframe)
@@ -55,17 +116,15 @@ func candlesCount(start, end time.Time, timeframe string) (int, time.Duration, e
 	return int(totalDuration / interval), interval, nil
 }
 
+// Download fetches candles of a given pair in chunks of `batchSize` candles, paginating by time, and writes
+// them incrementally to a CSV file. With WithResume, an interrupted download continues from the last saved
+// candle. The boundary candle between chunks is written only once.
 func (d Downloader) Download(ctx context.Context, pair, timeframe string, output string, options ...Option) error {
-	recordFile, err := os.Create(output)
-	if err != nil {
-		return err
-	}
-	defer recordFile.Close()
-
 	now := time.Now()
 	parameters := &Parameters{
-		Start: now.AddDate(0, -1, 0),
-		End:   now,
+		Start:     now.AddDate(0, -1, 0),
+		End:       now,
+		BatchSize: batchSize,
 	}
 
 	for _, option := range options {
@@ -82,7 +141,33 @@ func (d Downloader) Download(ctx context.Context, pair, timeframe string, output
 		parameters.End = now
 	}
 
-	candlesCount, interval, err := candlesCount(parameters.Start, parameters.End, timeframe)
+	var lastTime time.Time
+	if parameters.Resume {
+		var err error
+		lastTime, err = lastSavedTime(output)
+		if err != nil {
+			return err
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if !lastTime.IsZero() {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+
+	recordFile, err := os.OpenFile(output, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer recordFile.Close()
+
+	begin := parameters.Start
+	if lastTime.After(begin) {
+		begin = lastTime
+		log.Infof("Resuming download from %s", lastTime)
+	}
+
+	candlesCount, interval, err := candlesCount(begin, parameters.End, timeframe)
 	if err != nil {
 		return err
 	}
@@ -94,23 +179,24 @@ func (d Downloader) Download(ctx context.Context, pair, timeframe string, output
 
 	progressBar := progressbar.Default(int64(candlesCount))
 	lostData := 0
-	isLastLoop := false
 
 	// write headers
-	err = writer.Write([]string{
-		"time", "open", "close", "low", "high", "volume",
-	})
-	if err != nil {
-		return err
+	if lastTime.IsZero() {
+		err = writer.Write([]string{
+			"time", "open", "close", "low", "high", "volume",
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	for begin := parameters.Start; begin.Before(parameters.End); begin = begin.Add(interval * batchSize) {
-		end := begin.Add(interval * batchSize)
-		if end.Before(parameters.End) {
-			end = end.Add(-1 * time.Second)
-		} else {
+	for begin.Before(parameters.End) {
+		end := begin.Add(interval * time.Duration(parameters.BatchSize))
+		isLastLoop := !end.Before(parameters.End)
+		if isLastLoop {
 			end = parameters.End
-			isLastLoop = true
+		} else {
+			end = end.Add(-1 * time.Second)
 		}
 
 		candles, err := d.exchange.CandlesByPeriod(ctx, pair, timeframe, begin, end)
@@ -118,21 +204,43 @@ func (d Downloader) Download(ctx context.Context, pair, timeframe string, output
 			return err
 		}
 
+		countCandles := 0
 		for _, candle := range candles {
+			candle.Time = parameters.Timestamp.OpenTime(candle.Time, interval)
+
+			// skip the boundary candle, already saved in the previous chunk
+			if !lastTime.IsZero() && !candle.Time.After(lastTime) {
+				continue
+			}
+
 			err := writer.Write(candle.ToSlice(info.QuotePrecision))
 			if err != nil {
 				return err
 			}
+			lastTime = candle.Time
+			countCandles++
+		}
+
+		// persist each chunk, so an interrupted download can be resumed
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
 		}
 
-		countCandles := len(candles)
-		if !isLastLoop {
-			lostData += batchSize - countCandles
+		if !isLastLoop && len(candles) < parameters.BatchSize {
+			lostData += parameters.BatchSize - len(candles)
 		}
 
 		if err = progressBar.Add(countCandles); err != nil {
 			log.Warnf("update progresbar fail: %s", err.Error())
 		}
+
+		// paginate from the last received candle, or skip the window when it has no new data
+		if lastTime.After(begin) {
+			begin = lastTime
+		} else {
+			begin = end.Add(time.Second)
+		}
 	}
 
 	if err = progressBar.Close(); err != nil {
@@ -143,7 +251,6 @@ func (d Downloader) Download(ctx context.Context, pair, timeframe string, output
 		log.Warnf("%d missing candles", lostData)
 	}
 
-	writer.Flush()
 	log.Info("Done!")
-	return writer.Error()
+	return nil
 }
@@ -2,11 +2,16 @@ package download
 
 import (
 	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/service"
 
 	"github.com/stretchr/testify/assert"
@@ -98,3 +103,118 @@ func TestDownloader_download(t *testing.T) {
 		require.Len(t, csvFeed.CandlePairTimeFrame["BTCUSDT--1d"], 14)
 	})
 }
+
+type paginatedFeeder struct {
+	service.Feeder
+	candles []model.Candle
+	limit   int
+	calls   int
+	failAt  int
+}
+
+func (f *paginatedFeeder) AssetsInfo(_ string) model.AssetInfo {
+	return model.AssetInfo{QuotePrecision: 2}
+}
+
+func (f *paginatedFeeder) CandlesByPeriod(_ context.Context, _, _ string,
+	start, end time.Time) ([]model.Candle, error) {
+
+	f.calls++
+	if f.calls == f.failAt {
+		return nil, errors.New("connection lost")
+	}
+
+	result := make([]model.Candle, 0)
+	for _, candle := range f.candles {
+		if candle.Time.Before(start) || candle.Time.After(end) {
+			continue
+		}
+		result = append(result, candle)
+		if len(result) == f.limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func TestDownloader_resume(t *testing.T) {
+	ctx := context.Background()
+	tmpFile, err := os.CreateTemp(os.TempDir(), "*.csv")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candles := make([]model.Candle, 0)
+	for i := 0; i < 40; i++ {
+		candles = append(candles, model.Candle{
+			Time:  start.AddDate(0, 0, i),
+			Close: float64(i),
+		})
+	}
+
+	options := []Option{
+		WithInterval(start, start.AddDate(0, 0, 29)),
+		WithBatchSize(7),
+		WithResume(),
+	}
+
+	// connection lost in the third request, after two chunks have been saved
+	feeder := &paginatedFeeder{candles: candles, limit: 7, failAt: 3}
+	err = NewDownloader(feeder).Download(ctx, "BTCUSDT", "1d", tmpFile.Name(), options...)
+	require.Error(t, err)
+
+	lastTime, err := lastSavedTime(tmpFile.Name())
+	require.NoError(t, err)
+	require.Equal(t, start.AddDate(0, 0, 12), lastTime)
+
+	feeder = &paginatedFeeder{candles: candles, limit: 7}
+	err = NewDownloader(feeder).Download(ctx, "BTCUSDT", "1d", tmpFile.Name(), options...)
+	require.NoError(t, err)
+
+	file, err := os.Open(tmpFile.Name())
+	require.NoError(t, err)
+	defer file.Close()
+
+	lines, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []string{"time", "open", "close", "low", "high", "volume"}, lines[0])
+	require.Len(t, lines[1:], 30)
+
+	for i, line := range lines[1:] {
+		timestamp, err := strconv.ParseInt(line[0], 10, 64)
+		require.NoError(t, err)
+		require.Equal(t, start.AddDate(0, 0, i).Unix(), timestamp)
+	}
+}
+
+func TestDownloader_WithTimestampConvention(t *testing.T) {
+	ctx := context.Background()
+	tmpFile, err := os.CreateTemp(t.TempDir(), "*.csv")
+	require.NoError(t, err)
+
+	// exchange with the candles timestamped by close time
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candles := make([]model.Candle, 0)
+	for i := 1; i <= 5; i++ {
+		candles = append(candles, model.Candle{Time: start.AddDate(0, 0, i), Close: float64(i)})
+	}
+
+	feeder := &paginatedFeeder{candles: candles, limit: 10}
+	err = NewDownloader(feeder).Download(ctx, "BTCUSDT", "1d", tmpFile.Name(),
+		WithInterval(start, start.AddDate(0, 0, 5)), WithTimestampConvention(model.TimestampCloseTime))
+	require.NoError(t, err)
+
+	file, err := os.Open(tmpFile.Name())
+	require.NoError(t, err)
+	defer file.Close()
+
+	lines, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, lines[1:], 5)
+	for i, line := range lines[1:] {
+		timestamp, err := strconv.ParseInt(line[0], 10, 64)
+		require.NoError(t, err)
+		require.Equal(t, start.AddDate(0, 0, i).Unix(), timestamp)
+		require.Equal(t, fmt.Sprintf("%d.00", i+1), line[2])
+	}
+}
@@ -0,0 +1,216 @@
+package exchange
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// AggregatedFeed is an exchange that subscribes to the candles of a base timeframe, e.g. 1m, and aggregates
+// them locally into the subscribed higher timeframes, e.g. 4h, with the same rules of the CSV resample. A
+// missed event of the exchange affects only a base candle, instead of a whole candle of the higher timeframe.
+// Subscriptions of the base timeframe, or lower ones, and the historical candles are read from the exchange.
+type AggregatedFeed struct {
+	service.Exchange
+	baseTimeframe string
+}
+
+func NewAggregatedFeed(exchange service.Exchange, baseTimeframe string) *AggregatedFeed {
+	return &AggregatedFeed{Exchange: exchange, baseTimeframe: baseTimeframe}
+}
+
+// CandlesSubscription emits the candles of the timeframe aggregated from the base timeframe, including the
+// partial candles. The in-progress candle is rebuilt from the recent base candles of the exchange, so a
+// restart in the middle of a candle does not lose the open, high, low and volume already traded.
+func (a *AggregatedFeed) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle,
+	chan error) {
+	aggregate, err := a.aggregates(timeframe)
+	if err != nil {
+		return failedSubscription(err)
+	}
+	if !aggregate {
+		return a.Exchange.CandlesSubscription(ctx, pair, timeframe)
+	}
+
+	aggregator := newCandleAggregator(a.baseTimeframe, timeframe)
+	source, sourceErr := a.Exchange.CandlesSubscription(ctx, pair, a.baseTimeframe)
+	seedErr := a.seed(ctx, aggregator, pair, timeframe)
+
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+
+	go func() {
+		defer close(ccandle)
+		for candle := range source {
+			for _, aggregated := range aggregator.add(candle) {
+				select {
+				case ccandle <- aggregated:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		defer close(cerr)
+		if seedErr != nil {
+			select {
+			case cerr <- seedErr:
+			case <-ctx.Done():
+				return
+			}
+		}
+		for err := range sourceErr {
+			select {
+			case cerr <- err:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ccandle, cerr
+}
+
+// aggregates returns true when the timeframe is higher than the base timeframe
+func (a *AggregatedFeed) aggregates(timeframe string) (bool, error) {
+	if timeframe == a.baseTimeframe {
+		return false, nil
+	}
+
+	baseDuration, err := str2duration.ParseDuration(a.baseTimeframe)
+	if err != nil {
+		return false, err
+	}
+
+	duration, err := str2duration.ParseDuration(timeframe)
+	if err != nil {
+		return false, err
+	}
+
+	if duration <= baseDuration {
+		return false, nil
+	}
+
+	// validates the timeframe supported by the resample
+	_, err = isLastCandlePeriod(time.Time{}, a.baseTimeframe, timeframe)
+	return err == nil, err
+}
+
+// seed adds the complete base candles of the in-progress candle of the timeframe to the aggregator
+func (a *AggregatedFeed) seed(ctx context.Context, aggregator *candleAggregator, pair, timeframe string) error {
+	baseDuration, _ := str2duration.ParseDuration(a.baseTimeframe)
+	duration, _ := str2duration.ParseDuration(timeframe)
+
+	candles, err := a.Exchange.CandlesByLimit(ctx, pair, a.baseTimeframe, int(duration/baseDuration))
+	if err != nil {
+		return err
+	}
+
+	for _, candle := range candles {
+		aggregator.add(candle)
+	}
+	return nil
+}
+
+// failedSubscription returns closed channels with the given error
+func failedSubscription(err error) (chan model.Candle, chan error) {
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error, 1)
+	cerr <- err
+	close(cerr)
+	close(ccandle)
+	return ccandle, cerr
+}
+
+// candleAggregator merges the candles of a base timeframe into the candle of a higher timeframe
+type candleAggregator struct {
+	baseTimeframe string
+	timeframe     string
+	duration      time.Duration
+
+	// current is the merge of the complete base candles of the in-progress candle, and last is the
+	// in-progress candle with the latest base candle, partial or complete
+	current      model.Candle
+	last         model.Candle
+	started      bool
+	period       time.Time
+	lastComplete time.Time
+}
+
+func newCandleAggregator(baseTimeframe, timeframe string) *candleAggregator {
+	// the timeframe is validated by AggregatedFeed, the errors are not possible here
+	duration, _ := str2duration.ParseDuration(timeframe)
+	return &candleAggregator{baseTimeframe: baseTimeframe, timeframe: timeframe, duration: duration}
+}
+
+// periodStart returns the start of the candle of the timeframe that includes the given time, the weekly
+// candles start on Sunday, like the CSV resample
+func (c *candleAggregator) periodStart(t time.Time) time.Time {
+	if c.timeframe == "1w" {
+		day := t.Truncate(24 * time.Hour)
+		return day.AddDate(0, 0, -int(day.Weekday()))
+	}
+	return t.Truncate(c.duration)
+}
+
+// add merges a base candle, partial or complete, and returns the candles of the timeframe updated with it.
+// Base candles before the first boundary of the timeframe and repeated complete candles are ignored. After
+// the first boundary, a candle starts whenever a base candle of a new period is received, even if the first
+// base candles of the period are missed. When the last base candles of a candle are missed, the candle is
+// completed with the base candles received and returned before the next one.
+func (c *candleAggregator) add(candle model.Candle) []model.Candle {
+	if !candle.Time.After(c.lastComplete) {
+		return nil
+	}
+
+	var candles []model.Candle
+	period := c.periodStart(candle.Time)
+	advanced := !c.period.IsZero() && period.After(c.period)
+	if c.started && advanced && !c.last.Time.IsZero() {
+		missed := c.last
+		missed.Complete = true
+		candles = append(candles, missed)
+	}
+
+	first, _ := isFistCandlePeriod(candle.Time, c.baseTimeframe, c.timeframe)
+	if first || advanced {
+		c.started = true
+		c.period = period
+		c.current, c.last = model.Candle{}, model.Candle{}
+	}
+
+	if !c.started {
+		return candles
+	}
+
+	aggregated := candle
+	aggregated.Time = c.period
+	if !c.current.Time.IsZero() {
+		aggregated.Open = c.current.Open
+		aggregated.High = math.Max(c.current.High, candle.High)
+		aggregated.Low = math.Min(c.current.Low, candle.Low)
+		aggregated.Volume += c.current.Volume
+	}
+
+	last, _ := isLastCandlePeriod(candle.Time, c.baseTimeframe, c.timeframe)
+	aggregated.Complete = candle.Complete && last
+
+	c.last = aggregated
+	if candle.Complete {
+		c.lastComplete = candle.Time
+		c.current = aggregated
+		if aggregated.Complete {
+			c.started = false
+			c.current, c.last = model.Candle{}, model.Candle{}
+		}
+	}
+
+	return append(candles, aggregated)
+}
@@ -0,0 +1,188 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+type baseExchange struct {
+	service.Exchange
+	history    []model.Candle
+	stream     []model.Candle
+	timeframes []string
+}
+
+func (b *baseExchange) CandlesByLimit(_ context.Context, _, _ string, limit int) ([]model.Candle, error) {
+	return b.history[len(b.history)-limit:], nil
+}
+
+func (b *baseExchange) CandlesSubscription(_ context.Context, _, timeframe string) (chan model.Candle,
+	chan error) {
+	b.timeframes = append(b.timeframes, timeframe)
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+	go func() {
+		for _, candle := range b.stream {
+			ccandle <- candle
+		}
+		close(ccandle)
+		close(cerr)
+	}()
+	return ccandle, cerr
+}
+
+func TestAggregatedFeed_CandlesSubscription(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	minute := func(i int, complete bool) model.Candle {
+		price := float64(100 + i)
+		return model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.Add(time.Duration(i) * time.Minute),
+			Open:     price,
+			Close:    price + 0.5,
+			High:     price + 1,
+			Low:      price - 1,
+			Volume:   1,
+			Complete: complete,
+		}
+	}
+
+	// restart at 00:07, the history has the complete 1m candles of the previous bar and of 00:00 to 00:06
+	base := &baseExchange{}
+	for i := -8; i < 7; i++ {
+		base.history = append(base.history, minute(i, true))
+	}
+
+	// the stream repeats the last complete candle and sends a partial update of each candle
+	base.stream = append(base.stream, minute(6, true))
+	for i := 7; i < 31; i++ {
+		base.stream = append(base.stream, minute(i, false), minute(i, true))
+	}
+
+	feed := NewAggregatedFeed(base, "1m")
+	ccandle, cerr := feed.CandlesSubscription(context.Background(), "BTCUSDT", "15m")
+
+	var candles, complete []model.Candle
+	for candle := range ccandle {
+		candles = append(candles, candle)
+		if candle.Complete {
+			complete = append(complete, candle)
+		}
+	}
+	for err := range cerr {
+		require.NoError(t, err)
+	}
+	require.Equal(t, []string{"1m"}, base.timeframes)
+
+	// the in-progress candle includes the base candles before the restart
+	require.Equal(t, start, candles[0].Time)
+	require.Equal(t, 100.0, candles[0].Open)
+	require.Equal(t, 107.5, candles[0].Close)
+	require.Equal(t, 99.0, candles[0].Low)
+	require.Equal(t, 8.0, candles[0].Volume)
+	require.False(t, candles[0].Complete)
+
+	require.Len(t, complete, 2)
+	require.Equal(t, model.Candle{Pair: "BTCUSDT", Time: start, Open: 100, Close: 114.5, High: 115, Low: 99,
+		Volume: 15, Complete: true}, complete[0])
+	require.Equal(t, model.Candle{Pair: "BTCUSDT", Time: start.Add(15 * time.Minute), Open: 115, Close: 129.5,
+		High: 130, Low: 114, Volume: 15, Complete: true}, complete[1])
+
+	// the next candle starts at the boundary
+	last := candles[len(candles)-1]
+	require.Equal(t, start.Add(30*time.Minute), last.Time)
+	require.Equal(t, 130.0, last.Open)
+	require.Equal(t, 1.0, last.Volume)
+	require.False(t, last.Complete)
+
+	t.Run("missed last base candle", func(t *testing.T) {
+		// the candle of 00:14 is missed, the candle of 00:00 is completed when the next one starts
+		missed := &baseExchange{}
+		for i := -15; i < 0; i++ {
+			missed.history = append(missed.history, minute(i, true))
+		}
+		for i := 0; i < 17; i++ {
+			if i != 14 {
+				missed.stream = append(missed.stream, minute(i, true))
+			}
+		}
+
+		ccandle, _ := NewAggregatedFeed(missed, "1m").CandlesSubscription(context.Background(), "BTCUSDT", "15m")
+		var candles []model.Candle
+		for candle := range ccandle {
+			candles = append(candles, candle)
+		}
+
+		require.Len(t, candles, 17)
+		require.Equal(t, model.Candle{Pair: "BTCUSDT", Time: start, Open: 100, Close: 113.5, High: 114, Low: 99,
+			Volume: 14, Complete: true}, candles[14])
+		require.Equal(t, start.Add(15*time.Minute), candles[15].Time)
+		require.False(t, candles[15].Complete)
+	})
+
+	t.Run("missed first base candle", func(t *testing.T) {
+		// the candle of 00:15 is missed, the candle of 00:15 starts with the candle of 00:16
+		missed := &baseExchange{}
+		for i := -15; i < 0; i++ {
+			missed.history = append(missed.history, minute(i, true))
+		}
+		for i := 0; i < 30; i++ {
+			if i != 15 {
+				missed.stream = append(missed.stream, minute(i, true))
+			}
+		}
+
+		ccandle, _ := NewAggregatedFeed(missed, "1m").CandlesSubscription(context.Background(), "BTCUSDT", "15m")
+		var complete []model.Candle
+		for candle := range ccandle {
+			if candle.Complete {
+				complete = append(complete, candle)
+			}
+		}
+
+		require.Len(t, complete, 2)
+		require.Equal(t, model.Candle{Pair: "BTCUSDT", Time: start.Add(15 * time.Minute), Open: 116, Close: 129.5,
+			High: 130, Low: 115, Volume: 14, Complete: true}, complete[1])
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		ccandle, _ := NewAggregatedFeed(base, "1m").CandlesSubscription(ctx, "BTCUSDT", "15m")
+		<-ccandle
+		cancel()
+
+		// the candles are not sent after the cancellation, the channel is closed
+		require.Eventually(t, func() bool {
+			select {
+			case _, ok := <-ccandle:
+				return !ok
+			default:
+				return false
+			}
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("base timeframe", func(t *testing.T) {
+		base.timeframes = nil
+		ccandle, _ := NewAggregatedFeed(base, "1m").CandlesSubscription(context.Background(), "BTCUSDT", "1m")
+		var count int
+		for range ccandle {
+			count++
+		}
+		require.Equal(t, len(base.stream), count)
+		require.Equal(t, []string{"1m"}, base.timeframes)
+	})
+
+	t.Run("invalid timeframe", func(t *testing.T) {
+		ccandle, cerr := NewAggregatedFeed(base, "1m").CandlesSubscription(context.Background(), "BTCUSDT", "7m")
+		_, ok := <-ccandle
+		require.False(t, ok)
+		require.Error(t, <-cerr)
+	})
+}
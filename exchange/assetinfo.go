@@ -0,0 +1,98 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2/common"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// filterErrorCodes are the API error codes of orders rejected by the symbol filters, e.g. tick size,
+// step size or minimum notional, which may be caused by a change in the symbol rules
+var filterErrorCodes = map[int64]bool{
+	-1013: true, // filter failure
+	-1111: true, // precision is over the maximum defined for this asset
+	-4014: true, // price not increased by tick size (futures)
+	-4023: true, // quantity not increased by step size (futures)
+	-4164: true, // notional below the minimum (futures)
+}
+
+func isFilterError(err error) bool {
+	var apiError *common.APIError
+	return errors.As(err, &apiError) && filterErrorCodes[apiError.Code]
+}
+
+type assetsInfoLoader func(ctx context.Context) (map[string]model.AssetInfo, error)
+
+// assetsInfoCache keeps the asset info (precision and filters) of the exchange pairs. The map is replaced
+// as a whole on refresh and never modified, so an order is validated and formatted with a consistent snapshot.
+type assetsInfoCache struct {
+	mtx    sync.RWMutex
+	ctx    context.Context
+	load   assetsInfoLoader
+	assets map[string]model.AssetInfo
+}
+
+func newAssetsInfoCache(ctx context.Context, load assetsInfoLoader) (*assetsInfoCache, error) {
+	cache := &assetsInfoCache{ctx: ctx, load: load}
+	if err := cache.refresh(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func (c *assetsInfoCache) get(pair string) (model.AssetInfo, bool) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	info, ok := c.assets[pair]
+	return info, ok
+}
+
+// refresh loads the asset info from the exchange, the current values are kept on failure
+func (c *assetsInfoCache) refresh() error {
+	assets, err := c.load(c.ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.assets = assets
+	return nil
+}
+
+// refreshEvery refreshes the asset info in background with the given interval, until the context is done
+func (c *assetsInfoCache) refreshEvery(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.refresh(); err != nil {
+					log.Errorf("assetsInfo/refresh: %v", err)
+				}
+			case <-c.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// refreshOnFilterError refreshes the asset info when the order was rejected by the symbol filters,
+// so the next orders use the current rules
+func (c *assetsInfoCache) refreshOnFilterError(err error) {
+	if !isFilterError(err) {
+		return
+	}
+
+	log.Warnf("assetsInfo: order rejected by filters, refreshing assets info: %v", err)
+	if err := c.refresh(); err != nil {
+		log.Errorf("assetsInfo/refresh: %v", err)
+	}
+}
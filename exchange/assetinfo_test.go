@@ -0,0 +1,82 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestAssetsInfoCache(t *testing.T) {
+	var (
+		stepSize atomic.Value
+		fail     atomic.Bool
+		loads    atomic.Int32
+	)
+	stepSize.Store(0.01)
+
+	load := func(context.Context) (map[string]model.AssetInfo, error) {
+		loads.Add(1)
+		if fail.Load() {
+			return nil, errors.New("exchange unavailable")
+		}
+		return map[string]model.AssetInfo{"BTCUSDT": {StepSize: stepSize.Load().(float64)}}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cache, err := newAssetsInfoCache(ctx, load)
+	require.NoError(t, err)
+
+	info, ok := cache.get("BTCUSDT")
+	require.True(t, ok)
+	require.Equal(t, 0.01, info.StepSize)
+
+	_, ok = cache.get("ETHUSDT")
+	require.False(t, ok)
+
+	t.Run("filter errors", func(t *testing.T) {
+		stepSize.Store(0.1)
+
+		cache.refreshOnFilterError(&common.APIError{Code: -2010, Message: "insufficient balance"})
+		info, _ := cache.get("BTCUSDT")
+		require.Equal(t, 0.01, info.StepSize)
+
+		cache.refreshOnFilterError(&common.APIError{Code: -1013, Message: "Filter failure: PRICE_FILTER"})
+		info, _ = cache.get("BTCUSDT")
+		require.Equal(t, 0.1, info.StepSize)
+	})
+
+	t.Run("failed refresh keeps values", func(t *testing.T) {
+		fail.Store(true)
+		defer fail.Store(false)
+
+		require.Error(t, cache.refresh())
+		info, _ := cache.get("BTCUSDT")
+		require.Equal(t, 0.1, info.StepSize)
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		stepSize.Store(0.5)
+		cache.refreshEvery(10 * time.Millisecond)
+
+		require.Eventually(t, func() bool {
+			info, _ := cache.get("BTCUSDT")
+			return info.StepSize == 0.5
+		}, time.Second, 10*time.Millisecond)
+
+		// stops with the context
+		cancel()
+		time.Sleep(30 * time.Millisecond)
+		count := loads.Load()
+		time.Sleep(30 * time.Millisecond)
+		require.Equal(t, count, loads.Load())
+	})
+}
@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/adshao/go-binance/v2"
-	"github.com/adshao/go-binance/v2/common"
 	"github.com/jpillora/backoff"
 
 	"github.com/rodrigo-brito/ninjabot/model"
@@ -19,7 +18,7 @@ type MetadataFetchers func(pair string, t time.Time) (string, float64)
 type Binance struct {
 	ctx        context.Context
 	client     *binance.Client
-	assetsInfo map[string]model.AssetInfo
+	assetsInfo *assetsInfoCache
 	HeikinAshi bool
 	Testnet    bool
 
@@ -27,6 +26,8 @@ type Binance struct {
 	APISecret string
 
 	MetadataFetchers []MetadataFetchers
+
+	assetsInfoRefresh time.Duration
 }
 
 type BinanceOption func(*Binance)
@@ -87,6 +88,15 @@ func WithCustomTestnetAPIEndpoint(apiURL, wsURL, combinedURL string) BinanceOpti
 	}
 }
 
+// WithBinanceAssetsInfoRefresh refreshes the assets info (precision, tick size, step size and quantity limits)
+// in the given interval, so changes in the symbol rules are applied without restarting the bot.
+// The assets info is also refreshed after an order rejected by the symbol filters.
+func WithBinanceAssetsInfoRefresh(interval time.Duration) BinanceOption {
+	return func(b *Binance) {
+		b.assetsInfoRefresh = interval
+	}
+}
+
 // NewBinance create a new Binance exchange instance
 func NewBinance(ctx context.Context, options ...BinanceOption) (*Binance, error) {
 	binance.WebsocketKeepalive = true
@@ -101,13 +111,29 @@ func NewBinance(ctx context.Context, options ...BinanceOption) (*Binance, error)
 		return nil, fmt.Errorf("binance ping fail: %w", err)
 	}
 
-	results, err := exchange.client.NewExchangeInfoService().Do(ctx)
+	// Initialize with orders precision and assets limits
+	exchange.assetsInfo, err = newAssetsInfoCache(ctx, exchange.loadAssetsInfo)
 	if err != nil {
 		return nil, err
 	}
 
-	// Initialize with orders precision and assets limits
-	exchange.assetsInfo = make(map[string]model.AssetInfo)
+	if exchange.assetsInfoRefresh > 0 {
+		exchange.assetsInfo.refreshEvery(exchange.assetsInfoRefresh)
+	}
+
+	log.Info("[SETUP] Using Binance exchange")
+
+	return exchange, nil
+}
+
+// loadAssetsInfo fetches the orders precision and assets limits of all pairs
+func (b *Binance) loadAssetsInfo(ctx context.Context) (map[string]model.AssetInfo, error) {
+	results, err := b.client.NewExchangeInfoService().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assetsInfo := make(map[string]model.AssetInfo)
 	for _, info := range results.Symbols {
 		tradeLimits := model.AssetInfo{
 			BaseAsset:          info.BaseAsset,
@@ -128,14 +154,18 @@ func NewBinance(ctx context.Context, options ...BinanceOption) (*Binance, error)
 					tradeLimits.MaxPrice, _ = strconv.ParseFloat(filter["maxPrice"].(string), 64)
 					tradeLimits.TickSize, _ = strconv.ParseFloat(filter["tickSize"].(string), 64)
 				}
+
+				// NOTIONAL replaces MIN_NOTIONAL in the spot market
+				if typ == string(binance.SymbolFilterTypeMinNotional) || typ == "NOTIONAL" {
+					if value, ok := filter["minNotional"].(string); ok {
+						tradeLimits.MinNotional, _ = strconv.ParseFloat(value, 64)
+					}
+				}
 			}
 		}
-		exchange.assetsInfo[info.Symbol] = tradeLimits
+		assetsInfo[info.Symbol] = tradeLimits
 	}
-
-	log.Info("[SETUP] Using Binance exchange")
-
-	return exchange, nil
+	return assetsInfo, nil
 }
 
 func (b *Binance) LastQuote(ctx context.Context, pair string) (float64, error) {
@@ -146,46 +176,78 @@ func (b *Binance) LastQuote(ctx context.Context, pair string) (float64, error) {
 	return candles[0].Close, nil
 }
 
+// OrderBook returns the best bids and asks of the pair, up to limit levels in each side
+func (b *Binance) OrderBook(ctx context.Context, pair string, limit int) (model.OrderBook, error) {
+	depth, err := b.client.NewDepthService().Symbol(pair).Limit(limit).Do(ctx)
+	if err != nil {
+		return model.OrderBook{}, err
+	}
+
+	book := model.OrderBook{
+		Pair: pair,
+		Bids: make([]model.BookLevel, 0, len(depth.Bids)),
+		Asks: make([]model.BookLevel, 0, len(depth.Asks)),
+	}
+	for _, bid := range depth.Bids {
+		book.Bids = append(book.Bids, bookLevel(bid.Price, bid.Quantity))
+	}
+	for _, ask := range depth.Asks {
+		book.Asks = append(book.Asks, bookLevel(ask.Price, ask.Quantity))
+	}
+	return book, nil
+}
+
+// bookLevel parses a price level of the order book, returned by the API as strings
+func bookLevel(price, quantity string) model.BookLevel {
+	level := model.BookLevel{}
+	level.Price, _ = strconv.ParseFloat(price, 64)
+	level.Quantity, _ = strconv.ParseFloat(quantity, 64)
+	return level
+}
+
 func (b *Binance) AssetsInfo(pair string) model.AssetInfo {
-	return b.assetsInfo[pair]
+	info, _ := b.assetsInfo.get(pair)
+	return info
 }
 
-func (b *Binance) validate(pair string, quantity float64) error {
-	info, ok := b.assetsInfo[pair]
+// validate checks the order quantity and returns the asset info snapshot used to format the order
+func (b *Binance) validate(pair string, quantity float64) (model.AssetInfo, error) {
+	info, ok := b.assetsInfo.get(pair)
 	if !ok {
-		return ErrInvalidAsset
+		return model.AssetInfo{}, ErrInvalidAsset
 	}
 
 	if quantity > info.MaxQuantity || quantity < info.MinQuantity {
-		return &OrderError{
+		return model.AssetInfo{}, &OrderError{
 			Err:      fmt.Errorf("%w: min: %f max: %f", ErrInvalidQuantity, info.MinQuantity, info.MaxQuantity),
 			Pair:     pair,
 			Quantity: quantity,
 		}
 	}
 
-	return nil
+	return info, nil
 }
 
 func (b *Binance) CreateOrderOCO(side model.SideType, pair string,
 	quantity, price, stop, stopLimit float64) ([]model.Order, error) {
 
 	// validate stop
-	err := b.validate(pair, quantity)
+	info, err := b.validate(pair, quantity)
 	if err != nil {
 		return nil, err
 	}
 
 	ocoOrder, err := b.client.NewCreateOCOService().
 		Side(binance.SideType(side)).
-		Quantity(b.formatQuantity(pair, quantity)).
-		Price(b.formatPrice(pair, price)).
-		StopPrice(b.formatPrice(pair, stop)).
-		StopLimitPrice(b.formatPrice(pair, stopLimit)).
+		Quantity(b.formatQuantity(info, quantity)).
+		Price(b.formatPrice(info, price)).
+		StopPrice(b.formatPrice(info, stop)).
+		StopLimitPrice(b.formatPrice(info, stopLimit)).
 		StopLimitTimeInForce(binance.TimeInForceTypeGTC).
 		Symbol(pair).
 		Do(b.ctx)
 	if err != nil {
+		b.assetsInfo.refreshOnFilterError(err)
 		return nil, err
 	}
 
@@ -217,7 +279,7 @@ func (b *Binance) CreateOrderOCO(side model.SideType, pair string,
 }
 
 func (b *Binance) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
-	err := b.validate(pair, quantity)
+	info, err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -226,16 +288,22 @@ func (b *Binance) CreateOrderStop(pair string, quantity float64, limit float64)
 		Type(binance.OrderTypeStopLoss).
 		TimeInForce(binance.TimeInForceTypeGTC).
 		Side(binance.SideTypeSell).
-		Quantity(b.formatQuantity(pair, quantity)).
-		Price(b.formatPrice(pair, limit)).
+		Quantity(b.formatQuantity(info, quantity)).
+		Price(b.formatPrice(info, limit)).
 		Do(b.ctx)
 	if err != nil {
+		b.assetsInfo.refreshOnFilterError(err)
 		return model.Order{}, err
 	}
 
 	price, _ := strconv.ParseFloat(order.Price, 64)
 	quantity, _ = strconv.ParseFloat(order.OrigQuantity, 64)
 
+	fee, assetFee, feeAsset, err := orderFee(order.Fills, info)
+	if err != nil {
+		return model.Order{}, err
+	}
+
 	return model.Order{
 		ExchangeID: order.OrderID,
 		CreatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
@@ -246,27 +314,26 @@ func (b *Binance) CreateOrderStop(pair string, quantity float64, limit float64)
 		Status:     model.OrderStatusType(order.Status),
 		Price:      price,
 		Quantity:   quantity,
+		Fee:        fee,
+		AssetFee:   assetFee,
+		FeeAsset:   feeAsset,
 	}, nil
 }
 
-func (b *Binance) formatPrice(pair string, value float64) string {
-	if info, ok := b.assetsInfo[pair]; ok {
-		value = common.AmountToLotSize(info.TickSize, info.QuotePrecision, value)
-	}
+func (b *Binance) formatPrice(info model.AssetInfo, value float64) string {
+	value = info.RoundPrice(value)
 	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
-func (b *Binance) formatQuantity(pair string, value float64) string {
-	if info, ok := b.assetsInfo[pair]; ok {
-		value = common.AmountToLotSize(info.StepSize, info.BaseAssetPrecision, value)
-	}
+func (b *Binance) formatQuantity(info model.AssetInfo, value float64) string {
+	value = info.RoundQuantity(value)
 	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
 func (b *Binance) CreateOrderLimit(side model.SideType, pair string,
 	quantity float64, limit float64) (model.Order, error) {
 
-	err := b.validate(pair, quantity)
+	info, err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -276,10 +343,11 @@ func (b *Binance) CreateOrderLimit(side model.SideType, pair string,
 		Type(binance.OrderTypeLimit).
 		TimeInForce(binance.TimeInForceTypeGTC).
 		Side(binance.SideType(side)).
-		Quantity(b.formatQuantity(pair, quantity)).
-		Price(b.formatPrice(pair, limit)).
+		Quantity(b.formatQuantity(info, quantity)).
+		Price(b.formatPrice(info, limit)).
 		Do(b.ctx)
 	if err != nil {
+		b.assetsInfo.refreshOnFilterError(err)
 		return model.Order{}, err
 	}
 
@@ -293,6 +361,11 @@ func (b *Binance) CreateOrderLimit(side model.SideType, pair string,
 		return model.Order{}, err
 	}
 
+	fee, assetFee, feeAsset, err := orderFee(order.Fills, info)
+	if err != nil {
+		return model.Order{}, err
+	}
+
 	return model.Order{
 		ExchangeID: order.OrderID,
 		CreatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
@@ -303,11 +376,20 @@ func (b *Binance) CreateOrderLimit(side model.SideType, pair string,
 		Status:     model.OrderStatusType(order.Status),
 		Price:      price,
 		Quantity:   quantity,
+		Fee:        fee,
+		AssetFee:   assetFee,
+		FeeAsset:   feeAsset,
 	}, nil
 }
 
+// SimulateOrder is not supported by Binance, which has no endpoint to estimate the fees and balances of an
+// order. The orders are estimated by the paper wallet, in backtests and paper trading.
+func (b *Binance) SimulateOrder(_ string, _ model.SideType, _, _ float64) (model.OrderPreview, error) {
+	return model.OrderPreview{}, fmt.Errorf("%w: binance, use the paper wallet", ErrSimulationNotSupported)
+}
+
 func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity float64) (model.Order, error) {
-	err := b.validate(pair, quantity)
+	info, err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -316,10 +398,11 @@ func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity f
 		Symbol(pair).
 		Type(binance.OrderTypeMarket).
 		Side(binance.SideType(side)).
-		Quantity(b.formatQuantity(pair, quantity)).
+		Quantity(b.formatQuantity(info, quantity)).
 		NewOrderRespType(binance.NewOrderRespTypeFULL).
 		Do(b.ctx)
 	if err != nil {
+		b.assetsInfo.refreshOnFilterError(err)
 		return model.Order{}, err
 	}
 
@@ -333,6 +416,11 @@ func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity f
 		return model.Order{}, err
 	}
 
+	fee, assetFee, feeAsset, err := orderFee(order.Fills, info)
+	if err != nil {
+		return model.Order{}, err
+	}
+
 	return model.Order{
 		ExchangeID: order.OrderID,
 		CreatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
@@ -343,11 +431,14 @@ func (b *Binance) CreateOrderMarket(side model.SideType, pair string, quantity f
 		Status:     model.OrderStatusType(order.Status),
 		Price:      cost / quantity,
 		Quantity:   quantity,
+		Fee:        fee,
+		AssetFee:   assetFee,
+		FeeAsset:   feeAsset,
 	}, nil
 }
 
 func (b *Binance) CreateOrderMarketQuote(side model.SideType, pair string, quantity float64) (model.Order, error) {
-	err := b.validate(pair, quantity)
+	info, err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -356,10 +447,11 @@ func (b *Binance) CreateOrderMarketQuote(side model.SideType, pair string, quant
 		Symbol(pair).
 		Type(binance.OrderTypeMarket).
 		Side(binance.SideType(side)).
-		QuoteOrderQty(b.formatQuantity(pair, quantity)).
+		QuoteOrderQty(b.formatQuantity(info, quantity)).
 		NewOrderRespType(binance.NewOrderRespTypeFULL).
 		Do(b.ctx)
 	if err != nil {
+		b.assetsInfo.refreshOnFilterError(err)
 		return model.Order{}, err
 	}
 
@@ -373,6 +465,11 @@ func (b *Binance) CreateOrderMarketQuote(side model.SideType, pair string, quant
 		return model.Order{}, err
 	}
 
+	fee, assetFee, feeAsset, err := orderFee(order.Fills, info)
+	if err != nil {
+		return model.Order{}, err
+	}
+
 	return model.Order{
 		ExchangeID: order.OrderID,
 		CreatedAt:  time.Unix(0, order.TransactTime*int64(time.Millisecond)),
@@ -383,9 +480,44 @@ func (b *Binance) CreateOrderMarketQuote(side model.SideType, pair string, quant
 		Status:     model.OrderStatusType(order.Status),
 		Price:      cost / quantity,
 		Quantity:   quantity,
+		Fee:        fee,
+		AssetFee:   assetFee,
+		FeeAsset:   feeAsset,
 	}, nil
 }
 
+// orderFee returns the commission of the fills of an order in the quote asset, commissions in the base asset
+// are converted with the price of the fill. Commissions in other assets, e.g. BNB, are returned as the asset
+// fee, see model.Order.AssetFee. Binance charges all fills of an order in the same asset, the asset of the
+// first fill is used.
+func orderFee(fills []*binance.Fill, info model.AssetInfo) (fee, assetFee float64, asset string, err error) {
+	if len(fills) == 0 {
+		return 0, 0, "", nil
+	}
+
+	asset = fills[0].CommissionAsset
+	for _, fill := range fills {
+		commission, err := strconv.ParseFloat(fill.Commission, 64)
+		if err != nil {
+			return 0, 0, "", err
+		}
+
+		if asset == info.BaseAsset {
+			price, err := strconv.ParseFloat(fill.Price, 64)
+			if err != nil {
+				return 0, 0, "", err
+			}
+			commission *= price
+		}
+		fee += commission
+	}
+
+	if asset == info.BaseAsset || asset == info.QuoteAsset {
+		return fee, 0, "", nil
+	}
+	return 0, fee, asset, nil
+}
+
 func (b *Binance) Cancel(order model.Order) error {
 	_, err := b.client.NewCancelOrderService().
 		Symbol(order.Pair).
@@ -404,7 +536,43 @@ func (b *Binance) Orders(pair string, limit int) ([]model.Order, error) {
 		return nil, err
 	}
 
+	if len(result) == 0 {
+		return []model.Order{}, nil
+	}
+
+	// the fees are not returned with the orders, they are loaded from the trades since the oldest order
+	start := result[0].Time
+	for _, order := range result {
+		if order.Time < start {
+			start = order.Time
+		}
+	}
+	fills, err := b.orderFills(b.client.NewListTradesService().Symbol(pair).StartTime(start).Limit(1000))
+	if err != nil {
+		return nil, err
+	}
+
 	orders := make([]model.Order, 0)
+	for _, order := range result {
+		item := newOrder(order)
+		if err := b.setFee(&item, fills[item.ExchangeID]); err != nil {
+			return nil, err
+		}
+		orders = append(orders, item)
+	}
+	return orders, nil
+}
+
+// OpenOrders returns the orders of the pair waiting for execution, see service.OpenOrdersBroker
+func (b *Binance) OpenOrders(pair string) ([]model.Order, error) {
+	result, err := b.client.NewListOpenOrdersService().
+		Symbol(pair).
+		Do(b.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]model.Order, 0, len(result))
 	for _, order := range result {
 		orders = append(orders, newOrder(order))
 	}
@@ -421,7 +589,54 @@ func (b *Binance) Order(pair string, id int64) (model.Order, error) {
 		return model.Order{}, err
 	}
 
-	return newOrder(order), nil
+	result := newOrder(order)
+	if result.Status != model.OrderStatusTypeFilled && result.Status != model.OrderStatusTypePartiallyFilled {
+		return result, nil
+	}
+
+	// the fees are not returned with the order, they are loaded from its trades
+	fills, err := b.orderFills(b.client.NewListTradesService().Symbol(pair).OrderId(id))
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if err := b.setFee(&result, fills[id]); err != nil {
+		return model.Order{}, err
+	}
+	return result, nil
+}
+
+// orderFills returns the trades of the account by order id, as fills of the orders
+func (b *Binance) orderFills(service *binance.ListTradesService) (map[int64][]*binance.Fill, error) {
+	trades, err := service.Do(b.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tradeFills(trades), nil
+}
+
+func tradeFills(trades []*binance.TradeV3) map[int64][]*binance.Fill {
+	fills := make(map[int64][]*binance.Fill)
+	for _, trade := range trades {
+		fills[trade.OrderID] = append(fills[trade.OrderID], &binance.Fill{
+			TradeID:         trade.ID,
+			Price:           trade.Price,
+			Quantity:        trade.Quantity,
+			Commission:      trade.Commission,
+			CommissionAsset: trade.CommissionAsset,
+		})
+	}
+	return fills
+}
+
+// setFee sets the fee of the order from its fills, see orderFee
+func (b *Binance) setFee(order *model.Order, fills []*binance.Fill) error {
+	fee, assetFee, feeAsset, err := orderFee(fills, b.AssetsInfo(order.Pair))
+	if err != nil {
+		return err
+	}
+	order.Fee, order.AssetFee, order.FeeAsset = fee, assetFee, feeAsset
+	return nil
 }
 
 func newOrder(order *binance.Order) model.Order {
@@ -34,7 +34,7 @@ type PairOption struct {
 type BinanceFuture struct {
 	ctx        context.Context
 	client     *futures.Client
-	assetsInfo map[string]model.AssetInfo
+	assetsInfo *assetsInfoCache
 	HeikinAshi bool
 	Testnet    bool
 
@@ -43,6 +43,8 @@ type BinanceFuture struct {
 
 	MetadataFetchers []MetadataFetchers
 	PairOptions      []PairOption
+
+	assetsInfoRefresh time.Duration
 }
 
 type BinanceFutureOption func(*BinanceFuture)
@@ -73,6 +75,15 @@ func WithBinanceFutureLeverage(pair string, leverage int, marginType MarginType)
 	}
 }
 
+// WithBinanceFutureAssetsInfoRefresh refreshes the assets info (precision, tick size, step size and quantity limits)
+// in the given interval, so changes in the symbol rules are applied without restarting the bot.
+// The assets info is also refreshed after an order rejected by the symbol filters.
+func WithBinanceFutureAssetsInfoRefresh(interval time.Duration) BinanceFutureOption {
+	return func(b *BinanceFuture) {
+		b.assetsInfoRefresh = interval
+	}
+}
+
 // NewBinanceFuture will create a new BinanceFuture instance
 func NewBinanceFuture(ctx context.Context, options ...BinanceFutureOption) (*BinanceFuture, error) {
 	binance.WebsocketKeepalive = true
@@ -87,11 +98,6 @@ func NewBinanceFuture(ctx context.Context, options ...BinanceFutureOption) (*Bin
 		return nil, fmt.Errorf("binance ping fail: %w", err)
 	}
 
-	results, err := exchange.client.NewExchangeInfoService().Do(ctx)
-	if err != nil {
-		return nil, err
-	}
-
 	// Set leverage and margin type
 	for _, option := range exchange.PairOptions {
 		_, err = exchange.client.NewChangeLeverageService().Symbol(option.Pair).Leverage(option.Leverage).Do(ctx)
@@ -108,7 +114,28 @@ func NewBinanceFuture(ctx context.Context, options ...BinanceFutureOption) (*Bin
 	}
 
 	// Initialize with orders precision and assets limits
-	exchange.assetsInfo = make(map[string]model.AssetInfo)
+	exchange.assetsInfo, err = newAssetsInfoCache(ctx, exchange.loadAssetsInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if exchange.assetsInfoRefresh > 0 {
+		exchange.assetsInfo.refreshEvery(exchange.assetsInfoRefresh)
+	}
+
+	log.Info("[SETUP] Using Binance Futures exchange")
+
+	return exchange, nil
+}
+
+// loadAssetsInfo fetches the orders precision and assets limits of all pairs
+func (b *BinanceFuture) loadAssetsInfo(ctx context.Context) (map[string]model.AssetInfo, error) {
+	results, err := b.client.NewExchangeInfoService().Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assetsInfo := make(map[string]model.AssetInfo)
 	for _, info := range results.Symbols {
 		tradeLimits := model.AssetInfo{
 			BaseAsset:          info.BaseAsset,
@@ -129,14 +156,17 @@ func NewBinanceFuture(ctx context.Context, options ...BinanceFutureOption) (*Bin
 					tradeLimits.MaxPrice, _ = strconv.ParseFloat(filter["maxPrice"].(string), 64)
 					tradeLimits.TickSize, _ = strconv.ParseFloat(filter["tickSize"].(string), 64)
 				}
+
+				if typ == string(binance.SymbolFilterTypeMinNotional) {
+					if value, ok := filter["notional"].(string); ok {
+						tradeLimits.MinNotional, _ = strconv.ParseFloat(value, 64)
+					}
+				}
 			}
 		}
-		exchange.assetsInfo[info.Symbol] = tradeLimits
+		assetsInfo[info.Symbol] = tradeLimits
 	}
-
-	log.Info("[SETUP] Using Binance Futures exchange")
-
-	return exchange, nil
+	return assetsInfo, nil
 }
 
 func (b *BinanceFuture) LastQuote(ctx context.Context, pair string) (float64, error) {
@@ -147,25 +177,48 @@ func (b *BinanceFuture) LastQuote(ctx context.Context, pair string) (float64, er
 	return candles[0].Close, nil
 }
 
+// OrderBook returns the best bids and asks of the pair, up to limit levels in each side
+func (b *BinanceFuture) OrderBook(ctx context.Context, pair string, limit int) (model.OrderBook, error) {
+	depth, err := b.client.NewDepthService().Symbol(pair).Limit(limit).Do(ctx)
+	if err != nil {
+		return model.OrderBook{}, err
+	}
+
+	book := model.OrderBook{
+		Pair: pair,
+		Bids: make([]model.BookLevel, 0, len(depth.Bids)),
+		Asks: make([]model.BookLevel, 0, len(depth.Asks)),
+	}
+	for _, bid := range depth.Bids {
+		book.Bids = append(book.Bids, bookLevel(bid.Price, bid.Quantity))
+	}
+	for _, ask := range depth.Asks {
+		book.Asks = append(book.Asks, bookLevel(ask.Price, ask.Quantity))
+	}
+	return book, nil
+}
+
 func (b *BinanceFuture) AssetsInfo(pair string) model.AssetInfo {
-	return b.assetsInfo[pair]
+	info, _ := b.assetsInfo.get(pair)
+	return info
 }
 
-func (b *BinanceFuture) validate(pair string, quantity float64) error {
-	info, ok := b.assetsInfo[pair]
+// validate checks the order quantity and returns the asset info snapshot used to format the order
+func (b *BinanceFuture) validate(pair string, quantity float64) (model.AssetInfo, error) {
+	info, ok := b.assetsInfo.get(pair)
 	if !ok {
-		return ErrInvalidAsset
+		return model.AssetInfo{}, ErrInvalidAsset
 	}
 
 	if quantity > info.MaxQuantity || quantity < info.MinQuantity {
-		return &OrderError{
+		return model.AssetInfo{}, &OrderError{
 			Err:      fmt.Errorf("%w: min: %f max: %f", ErrInvalidQuantity, info.MinQuantity, info.MaxQuantity),
 			Pair:     pair,
 			Quantity: quantity,
 		}
 	}
 
-	return nil
+	return info, nil
 }
 
 func (b *BinanceFuture) CreateOrderOCO(_ model.SideType, _ string,
@@ -174,7 +227,7 @@ func (b *BinanceFuture) CreateOrderOCO(_ model.SideType, _ string,
 }
 
 func (b *BinanceFuture) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
-	err := b.validate(pair, quantity)
+	info, err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -183,10 +236,11 @@ func (b *BinanceFuture) CreateOrderStop(pair string, quantity float64, limit flo
 		Type(futures.OrderTypeStopMarket).
 		TimeInForce(futures.TimeInForceTypeGTC).
 		Side(futures.SideTypeSell).
-		Quantity(b.formatQuantity(pair, quantity)).
-		Price(b.formatPrice(pair, limit)).
+		Quantity(b.formatQuantity(info, quantity)).
+		Price(b.formatPrice(info, limit)).
 		Do(b.ctx)
 	if err != nil {
+		b.assetsInfo.refreshOnFilterError(err)
 		return model.Order{}, err
 	}
 
@@ -206,24 +260,32 @@ func (b *BinanceFuture) CreateOrderStop(pair string, quantity float64, limit flo
 	}, nil
 }
 
-func (b *BinanceFuture) formatPrice(pair string, value float64) string {
-	if info, ok := b.assetsInfo[pair]; ok {
-		value = common.AmountToLotSize(info.TickSize, info.QuotePrecision, value)
-	}
+func (b *BinanceFuture) formatPrice(info model.AssetInfo, value float64) string {
+	value = info.RoundPrice(value)
 	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
-func (b *BinanceFuture) formatQuantity(pair string, value float64) string {
-	if info, ok := b.assetsInfo[pair]; ok {
-		value = common.AmountToLotSize(info.StepSize, info.BaseAssetPrecision, value)
-	}
+func (b *BinanceFuture) formatQuantity(info model.AssetInfo, value float64) string {
+	value = info.RoundQuantity(value)
 	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
 func (b *BinanceFuture) CreateOrderLimit(side model.SideType, pair string,
 	quantity float64, limit float64) (model.Order, error) {
+	return b.createOrderLimit(side, pair, quantity, limit, false)
+}
 
-	err := b.validate(pair, quantity)
+// CreateOrderLimitReduceOnly creates a limit order that only reduces the current position,
+// the position check is done by Binance
+func (b *BinanceFuture) CreateOrderLimitReduceOnly(side model.SideType, pair string,
+	quantity float64, limit float64) (model.Order, error) {
+	return b.createOrderLimit(side, pair, quantity, limit, true)
+}
+
+func (b *BinanceFuture) createOrderLimit(side model.SideType, pair string,
+	quantity float64, limit float64, reduceOnly bool) (model.Order, error) {
+
+	info, err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -233,10 +295,12 @@ func (b *BinanceFuture) CreateOrderLimit(side model.SideType, pair string,
 		Type(futures.OrderTypeLimit).
 		TimeInForce(futures.TimeInForceTypeGTC).
 		Side(futures.SideType(side)).
-		Quantity(b.formatQuantity(pair, quantity)).
-		Price(b.formatPrice(pair, limit)).
+		Quantity(b.formatQuantity(info, quantity)).
+		Price(b.formatPrice(info, limit)).
+		ReduceOnly(reduceOnly).
 		Do(b.ctx)
 	if err != nil {
+		b.assetsInfo.refreshOnFilterError(err)
 		return model.Order{}, err
 	}
 
@@ -260,11 +324,30 @@ func (b *BinanceFuture) CreateOrderLimit(side model.SideType, pair string,
 		Status:     model.OrderStatusType(order.Status),
 		Price:      price,
 		Quantity:   quantity,
+		ReduceOnly: order.ReduceOnly,
 	}, nil
 }
 
+// SimulateOrder is not supported by Binance Futures, see Binance.SimulateOrder
+func (b *BinanceFuture) SimulateOrder(_ string, _ model.SideType, _, _ float64) (model.OrderPreview, error) {
+	return model.OrderPreview{}, fmt.Errorf("%w: binance futures, use the paper wallet", ErrSimulationNotSupported)
+}
+
 func (b *BinanceFuture) CreateOrderMarket(side model.SideType, pair string, quantity float64) (model.Order, error) {
-	err := b.validate(pair, quantity)
+	return b.createOrderMarket(side, pair, quantity, false)
+}
+
+// CreateOrderMarketReduceOnly creates a market order that only reduces the current position,
+// the position check is done by Binance
+func (b *BinanceFuture) CreateOrderMarketReduceOnly(side model.SideType, pair string,
+	quantity float64) (model.Order, error) {
+	return b.createOrderMarket(side, pair, quantity, true)
+}
+
+func (b *BinanceFuture) createOrderMarket(side model.SideType, pair string, quantity float64,
+	reduceOnly bool) (model.Order, error) {
+
+	info, err := b.validate(pair, quantity)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -273,10 +356,12 @@ func (b *BinanceFuture) CreateOrderMarket(side model.SideType, pair string, quan
 		Symbol(pair).
 		Type(futures.OrderTypeMarket).
 		Side(futures.SideType(side)).
-		Quantity(b.formatQuantity(pair, quantity)).
+		Quantity(b.formatQuantity(info, quantity)).
+		ReduceOnly(reduceOnly).
 		NewOrderResponseType(futures.NewOrderRespTypeRESULT).
 		Do(b.ctx)
 	if err != nil {
+		b.assetsInfo.refreshOnFilterError(err)
 		return model.Order{}, err
 	}
 
@@ -300,6 +385,7 @@ func (b *BinanceFuture) CreateOrderMarket(side model.SideType, pair string, quan
 		Status:     model.OrderStatusType(order.Status),
 		Price:      cost / quantity,
 		Quantity:   quantity,
+		ReduceOnly: order.ReduceOnly,
 	}, nil
 }
 
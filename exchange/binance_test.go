@@ -1,16 +1,19 @@
 package exchange
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
+	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/common"
 	"github.com/stretchr/testify/require"
 
 	"github.com/rodrigo-brito/ninjabot/model"
 )
 
 func TestFormatQuantity(t *testing.T) {
-	binance := Binance{assetsInfo: map[string]model.AssetInfo{
+	binance := Binance{assetsInfo: &assetsInfoCache{assets: map[string]model.AssetInfo{
 		"BTCUSDT": {
 			StepSize:           0.00001000,
 			TickSize:           0.00001000,
@@ -23,7 +26,7 @@ func TestFormatQuantity(t *testing.T) {
 			BaseAssetPrecision: 2,
 			QuotePrecision:     2,
 		},
-	}}
+	}}}
 
 	tt := []struct {
 		pair     string
@@ -46,8 +49,69 @@ func TestFormatQuantity(t *testing.T) {
 
 	for _, tc := range tt {
 		t.Run(fmt.Sprintf("given %f %s", tc.quantity, tc.pair), func(t *testing.T) {
-			require.Equal(t, tc.expected, binance.formatQuantity(tc.pair, tc.quantity))
-			require.Equal(t, tc.expected, binance.formatPrice(tc.pair, tc.quantity))
+			info := binance.AssetsInfo(tc.pair)
+			require.Equal(t, tc.expected, binance.formatQuantity(info, tc.quantity))
+			require.Equal(t, tc.expected, binance.formatPrice(info, tc.quantity))
 		})
 	}
 }
+
+func TestBinance_AssetsInfoRefresh(t *testing.T) {
+	info := model.AssetInfo{
+		MinQuantity:        0.1,
+		MaxQuantity:        100,
+		StepSize:           0.01,
+		TickSize:           0.01,
+		BaseAssetPrecision: 2,
+		QuotePrecision:     2,
+	}
+
+	cache, err := newAssetsInfoCache(context.Background(), func(context.Context) (map[string]model.AssetInfo, error) {
+		return map[string]model.AssetInfo{"BTCUSDT": info}, nil
+	})
+	require.NoError(t, err)
+	binance := Binance{assetsInfo: cache}
+
+	snapshot, err := binance.validate("BTCUSDT", 0.123456)
+	require.NoError(t, err)
+	require.Equal(t, "0.12", binance.formatQuantity(snapshot, 0.123456))
+
+	// symbol rules changed
+	info.MinQuantity = 0.2
+	info.StepSize = 0.001
+	info.BaseAssetPrecision = 3
+	binance.assetsInfo.refreshOnFilterError(&common.APIError{Code: -1013, Message: "Filter failure: LOT_SIZE"})
+
+	// snapshot of an in-flight order is not affected
+	require.Equal(t, "0.12", binance.formatQuantity(snapshot, 0.123456))
+
+	_, err = binance.validate("BTCUSDT", 0.123456)
+	require.ErrorIs(t, err.(*OrderError).Err, ErrInvalidQuantity)
+
+	snapshot, err = binance.validate("BTCUSDT", 0.234567)
+	require.NoError(t, err)
+	require.Equal(t, "0.234", binance.formatQuantity(snapshot, 0.234567))
+}
+
+func TestTradeFills(t *testing.T) {
+	info := model.AssetInfo{BaseAsset: "BTC", QuoteAsset: "USDT"}
+	fills := tradeFills([]*binance.TradeV3{
+		{ID: 1, OrderID: 10, Price: "100", Quantity: "1", Commission: "0.001", CommissionAsset: "BTC"},
+		{ID: 2, OrderID: 10, Price: "110", Quantity: "1", Commission: "0.002", CommissionAsset: "BTC"},
+		{ID: 3, OrderID: 20, Price: "100", Quantity: "1", Commission: "0.01", CommissionAsset: "BNB"},
+	})
+	require.Len(t, fills, 2)
+
+	// fees in the base asset are converted with the price of the fills
+	fee, assetFee, asset, err := orderFee(fills[10], info)
+	require.NoError(t, err)
+	require.InDelta(t, 0.1+0.22, fee, 1e-9)
+	require.Equal(t, 0.0, assetFee)
+	require.Empty(t, asset)
+
+	fee, assetFee, asset, err = orderFee(fills[20], info)
+	require.NoError(t, err)
+	require.Equal(t, 0.0, fee)
+	require.Equal(t, 0.01, assetFee)
+	require.Equal(t, "BNB", asset)
+}
@@ -0,0 +1,185 @@
+package exchange
+
+import (
+	"sync"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// OverflowPolicy defines what a CandleBuffer does when a new candle arrives and the buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until the consumer frees a slot in the buffer
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered candle to make room for the new one
+	OverflowDropOldest
+	// OverflowDropNewest discards the incoming candle
+	OverflowDropNewest
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowDropNewest:
+		return "drop-newest"
+	default:
+		return "block"
+	}
+}
+
+// CandleBuffer is a bounded queue between a candle feed and a slow consumer.
+// Complete and partial candles have independent overflow policies. By default, complete candles
+// block the producer, since dropping them corrupts indicators, and partial candles drop the oldest
+// partial candle in the buffer. A complete candle is never evicted while its policy is OverflowBlock.
+type CandleBuffer struct {
+	mtx            sync.Mutex
+	cond           *sync.Cond
+	capacity       int
+	candles        []model.Candle
+	closed         bool
+	dropped        int
+	completePolicy OverflowPolicy
+	partialPolicy  OverflowPolicy
+}
+
+type CandleBufferOption func(*CandleBuffer)
+
+// WithCompleteCandlePolicy sets the overflow policy for complete candles (default: OverflowBlock)
+func WithCompleteCandlePolicy(policy OverflowPolicy) CandleBufferOption {
+	return func(b *CandleBuffer) {
+		b.completePolicy = policy
+	}
+}
+
+// WithPartialCandlePolicy sets the overflow policy for partial candles (default: OverflowDropOldest)
+func WithPartialCandlePolicy(policy OverflowPolicy) CandleBufferOption {
+	return func(b *CandleBuffer) {
+		b.partialPolicy = policy
+	}
+}
+
+// NewCandleBuffer creates a buffer with room for `capacity` candles
+func NewCandleBuffer(capacity int, options ...CandleBufferOption) *CandleBuffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	buffer := &CandleBuffer{
+		capacity:       capacity,
+		candles:        make([]model.Candle, 0, capacity),
+		completePolicy: OverflowBlock,
+		partialPolicy:  OverflowDropOldest,
+	}
+	buffer.cond = sync.NewCond(&buffer.mtx)
+
+	for _, option := range options {
+		option(buffer)
+	}
+
+	return buffer
+}
+
+func (b *CandleBuffer) policy(candle model.Candle) OverflowPolicy {
+	if candle.Complete {
+		return b.completePolicy
+	}
+	return b.partialPolicy
+}
+
+// evictOldest removes the oldest candle that can be discarded and reports if any was found
+func (b *CandleBuffer) evictOldest() (model.Candle, bool) {
+	for i, candle := range b.candles {
+		if candle.Complete && b.completePolicy == OverflowBlock {
+			continue
+		}
+		b.candles = append(b.candles[:i], b.candles[i+1:]...)
+		return candle, true
+	}
+	return model.Candle{}, false
+}
+
+func (b *CandleBuffer) drop(candle model.Candle, policy OverflowPolicy) {
+	b.dropped++
+	log.Warnf("[FEED] candle buffer full (%d), %s: dropping candle %s %s",
+		b.capacity, policy, candle.Pair, candle.Time)
+}
+
+// Push adds a candle to the buffer, applying the overflow policy when the buffer is full.
+// Candles pushed after Close are ignored.
+func (b *CandleBuffer) Push(candle model.Candle) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	policy := b.policy(candle)
+	for !b.closed && len(b.candles) >= b.capacity {
+		switch policy {
+		case OverflowDropNewest:
+			b.drop(candle, policy)
+			return
+		case OverflowDropOldest:
+			oldest, ok := b.evictOldest()
+			if !ok {
+				// only protected candles in the buffer, the new one is discarded instead
+				b.drop(candle, policy)
+				return
+			}
+			b.drop(oldest, policy)
+		default:
+			b.cond.Wait()
+		}
+	}
+
+	if b.closed {
+		return
+	}
+
+	b.candles = append(b.candles, candle)
+	b.cond.Broadcast()
+}
+
+// Pop returns the oldest candle in the buffer, waiting until one is available.
+// It returns false when the buffer is closed and there are no pending candles.
+func (b *CandleBuffer) Pop() (model.Candle, bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	for len(b.candles) == 0 {
+		if b.closed {
+			return model.Candle{}, false
+		}
+		b.cond.Wait()
+	}
+
+	candle := b.candles[0]
+	b.candles = b.candles[1:]
+	b.cond.Broadcast()
+	return candle, true
+}
+
+// Close stops accepting new candles and releases blocked producers; pending candles can still be consumed
+func (b *CandleBuffer) Close() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.closed = true
+	b.cond.Broadcast()
+}
+
+// Depth returns the number of candles waiting to be consumed
+func (b *CandleBuffer) Depth() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return len(b.candles)
+}
+
+// Dropped returns the number of candles discarded by the overflow policy
+func (b *CandleBuffer) Dropped() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	return b.dropped
+}
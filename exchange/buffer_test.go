@@ -0,0 +1,100 @@
+package exchange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestCandleBuffer_Push(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candleAt := func(i int, complete bool) model.Candle {
+		return model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Minute), Complete: complete}
+	}
+
+	t.Run("partial candles drop oldest", func(t *testing.T) {
+		buffer := NewCandleBuffer(3)
+		for i := 0; i < 10; i++ {
+			buffer.Push(candleAt(i, false))
+		}
+
+		require.Equal(t, 3, buffer.Depth())
+		require.Equal(t, 7, buffer.Dropped())
+		for i := 7; i < 10; i++ {
+			candle, ok := buffer.Pop()
+			require.True(t, ok)
+			require.Equal(t, candleAt(i, false).Time, candle.Time)
+		}
+	})
+
+	t.Run("drop newest", func(t *testing.T) {
+		buffer := NewCandleBuffer(3, WithPartialCandlePolicy(OverflowDropNewest))
+		for i := 0; i < 10; i++ {
+			buffer.Push(candleAt(i, false))
+		}
+
+		require.Equal(t, 3, buffer.Depth())
+		require.Equal(t, 7, buffer.Dropped())
+		candle, ok := buffer.Pop()
+		require.True(t, ok)
+		require.Equal(t, start, candle.Time)
+	})
+
+	t.Run("complete candles are never evicted by partial ones", func(t *testing.T) {
+		buffer := NewCandleBuffer(2)
+		buffer.Push(candleAt(0, true))
+		buffer.Push(candleAt(1, true))
+		buffer.Push(candleAt(2, false))
+
+		require.Equal(t, 2, buffer.Depth())
+		require.Equal(t, 1, buffer.Dropped())
+		candle, _ := buffer.Pop()
+		require.True(t, candle.Complete)
+		candle, _ = buffer.Pop()
+		require.True(t, candle.Complete)
+	})
+
+	t.Run("complete candles block", func(t *testing.T) {
+		buffer := NewCandleBuffer(5)
+		done := make(chan bool)
+		go func() {
+			for i := 0; i < 10; i++ {
+				buffer.Push(candleAt(i, true))
+			}
+			done <- true
+		}()
+
+		require.Eventually(t, func() bool {
+			return buffer.Depth() == 5
+		}, time.Second, time.Millisecond)
+
+		select {
+		case <-done:
+			t.Fatal("producer should be blocked by a full buffer")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		for i := 0; i < 10; i++ {
+			candle, ok := buffer.Pop()
+			require.True(t, ok)
+			require.Equal(t, candleAt(i, true).Time, candle.Time)
+		}
+		<-done
+		require.Zero(t, buffer.Dropped())
+	})
+
+	t.Run("close", func(t *testing.T) {
+		buffer := NewCandleBuffer(2)
+		buffer.Push(candleAt(0, true))
+		buffer.Close()
+		buffer.Push(candleAt(1, true))
+
+		_, ok := buffer.Pop()
+		require.True(t, ok)
+		_, ok = buffer.Pop()
+		require.False(t, ok)
+	})
+}
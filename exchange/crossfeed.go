@@ -0,0 +1,113 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// CrossSource is an exchange of a CrossExchangeFeed
+type CrossSource struct {
+	// Name identifies the exchange in the prices of model.CrossPrice
+	Name   string
+	Feeder service.Feeder
+	// Symbols maps the pairs to the symbols of the exchange, when the naming is different,
+	// e.g. BTCUSDT to BTC-USDT. Pairs without mapping are used as is.
+	Symbols map[string]string
+}
+
+func (s CrossSource) symbol(pair string) string {
+	if symbol, ok := s.Symbols[pair]; ok {
+		return symbol
+	}
+	return pair
+}
+
+// CrossExchangeFeed subscribes to the same pair in several exchanges and combines their last prices, e.g. to
+// monitor arbitrage opportunities with model.CrossPrice.Spread. It is only a feed, the orders are executed
+// in a single exchange.
+type CrossExchangeFeed struct {
+	sources []CrossSource
+}
+
+func NewCrossExchangeFeed(sources ...CrossSource) *CrossExchangeFeed {
+	return &CrossExchangeFeed{sources: sources}
+}
+
+// Subscribe emits the last prices of the pair in all exchanges each time a candle is received from any of
+// them, with the close of the candle as the price of the exchange. Exchanges without candles yet are not in
+// the prices. The channels are closed when all exchanges are closed.
+func (f *CrossExchangeFeed) Subscribe(ctx context.Context, pair, timeframe string) (chan model.CrossPrice,
+	chan error) {
+	cprice := make(chan model.CrossPrice)
+	if len(f.sources) == 0 {
+		cerr := make(chan error, 1)
+		cerr <- fmt.Errorf("cross exchange feed without exchanges")
+		close(cerr)
+		close(cprice)
+		return cprice, cerr
+	}
+
+	cerr := make(chan error)
+
+	candles := make(chan sourceCandle)
+	wg := new(sync.WaitGroup)
+	errWg := new(sync.WaitGroup)
+	for i, source := range f.sources {
+		sourceCandles, sourceErr := source.Feeder.CandlesSubscription(ctx, source.symbol(pair), timeframe)
+
+		errWg.Add(1)
+		go func(name string) {
+			defer errWg.Done()
+			for err := range sourceErr {
+				cerr <- fmt.Errorf("exchange %s: %w", name, err)
+			}
+		}(source.Name)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for candle := range sourceCandles {
+				candles <- sourceCandle{source: i, candle: candle}
+			}
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(candles)
+	}()
+
+	go func() {
+		errWg.Wait()
+		close(cerr)
+	}()
+
+	go func() {
+		defer close(cprice)
+		prices := make(map[string]float64, len(f.sources))
+		updatedAt := make(map[string]time.Time, len(f.sources))
+		for received := range candles {
+			name := f.sources[received.source].Name
+			prices[name] = received.candle.Close
+			updatedAt[name] = received.candle.UpdatedAt
+
+			price := model.CrossPrice{
+				Pair:      pair,
+				Prices:    make(map[string]float64, len(prices)),
+				UpdatedAt: make(map[string]time.Time, len(updatedAt)),
+			}
+			for name, value := range prices {
+				price.Prices[name] = value
+				price.UpdatedAt[name] = updatedAt[name]
+			}
+			cprice <- price
+		}
+	}()
+
+	return cprice, cerr
+}
@@ -0,0 +1,73 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// channelFeeder streams the candles sent to its channel and records the subscribed symbols
+type channelFeeder struct {
+	service.Feeder
+	candles chan model.Candle
+	symbols []string
+}
+
+func (c *channelFeeder) CandlesSubscription(_ context.Context, symbol, _ string) (chan model.Candle, chan error) {
+	c.symbols = append(c.symbols, symbol)
+	return c.candles, make(chan error)
+}
+
+func TestCrossExchangeFeed_Subscribe(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	binance := &channelFeeder{candles: make(chan model.Candle)}
+	other := &channelFeeder{candles: make(chan model.Candle)}
+	feed := NewCrossExchangeFeed(
+		CrossSource{Name: "binance", Feeder: binance},
+		CrossSource{Name: "other", Feeder: other, Symbols: map[string]string{"BTCUSDT": "BTC-USDT"}},
+	)
+
+	prices, _ := feed.Subscribe(context.Background(), "BTCUSDT", "1m")
+	require.Equal(t, []string{"BTCUSDT"}, binance.symbols)
+	require.Equal(t, []string{"BTC-USDT"}, other.symbols)
+
+	binance.candles <- model.Candle{Pair: "BTCUSDT", Time: start, UpdatedAt: start, Close: 100}
+	price := <-prices
+	require.Equal(t, "BTCUSDT", price.Pair)
+	require.Equal(t, map[string]float64{"binance": 100}, price.Prices)
+	_, _, ok := price.Spread("binance", "other")
+	require.False(t, ok)
+
+	other.candles <- model.Candle{Pair: "BTC-USDT", Time: start, UpdatedAt: start.Add(time.Second), Close: 102}
+	price = <-prices
+	require.Equal(t, map[string]float64{"binance": 100, "other": 102}, price.Prices)
+	require.Equal(t, start.Add(time.Second), price.UpdatedAt["other"])
+	spread, percent, ok := price.Spread("binance", "other")
+	require.True(t, ok)
+	require.Equal(t, 2.0, spread)
+	require.InDelta(t, 0.02, percent, 1e-9)
+
+	binance.candles <- model.Candle{Pair: "BTCUSDT", Time: start, UpdatedAt: start.Add(2 * time.Second), Close: 103}
+	price = <-prices
+	spread, percent, ok = price.Spread("binance", "other")
+	require.True(t, ok)
+	require.Equal(t, -1.0, spread)
+	require.InDelta(t, -1.0/103, percent, 1e-9)
+
+	close(binance.candles)
+	close(other.candles)
+	_, open := <-prices
+	require.False(t, open)
+
+	t.Run("without exchanges", func(t *testing.T) {
+		prices, errs := NewCrossExchangeFeed().Subscribe(context.Background(), "BTCUSDT", "1m")
+		require.Error(t, <-errs)
+		_, open := <-prices
+		require.False(t, open)
+	})
+}
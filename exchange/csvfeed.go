@@ -23,6 +23,12 @@ type PairFeed struct {
 	File       string
 	Timeframe  string
 	HeikinAshi bool
+	// TimeTolerance snaps the candle times to the timeframe grid when they are within the given fraction of
+	// the timeframe from a boundary, e.g. 0.01 snaps 59.999s to 1m in 1m candles. Zero keeps the times.
+	TimeTolerance float64
+	// Timestamp is the timestamp convention of the file, the candles timestamped by close time are shifted to
+	// the open time by the timeframe, after the TimeTolerance. Open time by default.
+	Timestamp model.TimestampConvention
 }
 
 type CSVFeed struct {
@@ -31,6 +37,11 @@ type CSVFeed struct {
 }
 
 func (c CSVFeed) AssetsInfo(pair string) model.AssetInfo {
+	return defaultAssetInfo(pair)
+}
+
+// defaultAssetInfo returns the asset information of feeds without exchange limits
+func defaultAssetInfo(pair string) model.AssetInfo {
 	asset, quote := SplitAssetQuote(pair)
 	return model.AssetInfo{
 		BaseAsset:          asset,
@@ -49,7 +60,7 @@ func parseHeaders(headers []string) (index map[string]int, additional []string,
 		"time": 0, "open": 1, "close": 2, "low": 3, "high": 4, "volume": 5,
 	}
 
-	_, err := strconv.Atoi(headers[0])
+	_, err := parseTimestamp(headers[0])
 	if err == nil {
 		return headerMap, additional, false
 	}
@@ -64,6 +75,35 @@ func parseHeaders(headers []string) (index map[string]int, additional []string,
 	return headerMap, additional, true
 }
 
+// parseTimestamp parses a unix timestamp in seconds, with an optional fraction
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(math.Round(fraction*float64(time.Second)))), nil
+}
+
+// snapTime rounds the time to the closest boundary of the interval when the distance is within the tolerance,
+// given as a fraction of the interval
+func snapTime(t time.Time, interval time.Duration, tolerance float64) time.Time {
+	if tolerance <= 0 || interval <= 0 {
+		return t
+	}
+
+	rounded := t.Round(interval)
+	if math.Abs(float64(t.Sub(rounded))) <= tolerance*float64(interval) {
+		return rounded
+	}
+	return t
+}
+
 // NewCSVFeed creates a new data feed from CSV files and resample
 func NewCSVFeed(targetTimeframe string, feeds ...PairFeed) (*CSVFeed, error) {
 	csvFeed := &CSVFeed{
@@ -84,6 +124,14 @@ func NewCSVFeed(targetTimeframe string, feeds ...PairFeed) (*CSVFeed, error) {
 			return nil, err
 		}
 
+		var interval time.Duration
+		if feed.TimeTolerance > 0 || feed.Timestamp == model.TimestampCloseTime {
+			interval, err = str2duration.ParseDuration(feed.Timeframe)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		var candles []model.Candle
 		ha := model.NewHeikinAshi()
 
@@ -94,14 +142,16 @@ func NewCSVFeed(targetTimeframe string, feeds ...PairFeed) (*CSVFeed, error) {
 		}
 
 		for _, line := range csvLines {
-			timestamp, err := strconv.Atoi(line[headerMap["time"]])
+			timestamp, err := parseTimestamp(line[headerMap["time"]])
 			if err != nil {
 				return nil, err
 			}
+			timestamp = snapTime(timestamp, interval, feed.TimeTolerance)
+			timestamp = feed.Timestamp.OpenTime(timestamp, interval)
 
 			candle := model.Candle{
-				Time:      time.Unix(int64(timestamp), 0),
-				UpdatedAt: time.Unix(int64(timestamp), 0),
+				Time:      timestamp,
+				UpdatedAt: timestamp,
 				Pair:      feed.Pair,
 				Complete:  true,
 			}
@@ -265,7 +315,7 @@ func (c *CSVFeed) resample(pair, sourceTimeframe, targetTimeframe string) error
 	}
 
 	// remove last candle if not complete
-	if !candles[len(candles)-1].Complete {
+	if len(candles) > 0 && !candles[len(candles)-1].Complete {
 		candles = candles[:len(candles)-1]
 	}
 
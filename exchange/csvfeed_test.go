@@ -3,11 +3,14 @@ package exchange
 import (
 	"context"
 	"fmt"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
 )
 
 func TestNewCSVFeed(t *testing.T) {
@@ -215,3 +218,91 @@ func TestIsFistCandlePeriod(t *testing.T) {
 		require.False(t, last)
 	})
 }
+
+func TestCSVFeed_TimeTolerance(t *testing.T) {
+	// 1m candles with jittery timestamps, e.g. 00:14:59.999 instead of 00:15:00
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	file, err := os.CreateTemp(t.TempDir(), "*.csv")
+	require.NoError(t, err)
+	for i := 0; i < 30; i++ {
+		timestamp := fmt.Sprintf("%d", start.Add(time.Duration(i)*time.Minute).Unix())
+		switch i % 3 {
+		case 0:
+			timestamp = fmt.Sprintf("%d.999", start.Add(time.Duration(i)*time.Minute).Unix()-1)
+		case 1:
+			timestamp = fmt.Sprintf("%d", start.Add(time.Duration(i)*time.Minute).Unix()+1)
+		}
+		_, err := fmt.Fprintf(file, "%s,%d,%d,%d,%d,1\n", timestamp, 100+i, 100+i, 100+i, 100+i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, file.Close())
+
+	completeTimes := func(feed *CSVFeed) []time.Time {
+		var times []time.Time
+		for _, candle := range feed.CandlePairTimeFrame["BTCUSDT--15m"] {
+			if candle.Complete {
+				times = append(times, candle.Time.UTC())
+			}
+		}
+		return times
+	}
+
+	t.Run("without tolerance", func(t *testing.T) {
+		feed, err := NewCSVFeed("15m", PairFeed{Pair: "BTCUSDT", File: file.Name(), Timeframe: "1m"})
+		require.NoError(t, err)
+		require.NotEqual(t, []time.Time{start, start.Add(15 * time.Minute)}, completeTimes(feed))
+	})
+
+	t.Run("with tolerance", func(t *testing.T) {
+		feed, err := NewCSVFeed("15m", PairFeed{Pair: "BTCUSDT", File: file.Name(), Timeframe: "1m",
+			TimeTolerance: 0.05})
+		require.NoError(t, err)
+		require.Equal(t, []time.Time{start, start.Add(15 * time.Minute)}, completeTimes(feed))
+
+		for i, candle := range feed.CandlePairTimeFrame["BTCUSDT--1m"] {
+			require.Equal(t, start.Add(time.Duration(i)*time.Minute), candle.Time.UTC())
+		}
+
+		candles := feed.CandlePairTimeFrame["BTCUSDT--15m"]
+		last := candles[len(candles)-1]
+		require.Equal(t, 115.0, last.Open)
+		require.Equal(t, 129.0, last.Close)
+		require.Equal(t, 15.0, last.Volume)
+	})
+}
+
+func TestCSVFeed_Timestamp(t *testing.T) {
+	// 1h candles timestamped by close time, 01:00 for the candle from 00:00 to 01:00
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	file, err := os.CreateTemp(t.TempDir(), "*.csv")
+	require.NoError(t, err)
+	for i := 0; i < 8; i++ {
+		_, err := fmt.Fprintf(file, "%d,%d,%d,%d,%d,1\n", start.Add(time.Duration(i+1)*time.Hour).Unix(),
+			100+i, 100+i, 100+i, 100+i)
+		require.NoError(t, err)
+	}
+	require.NoError(t, file.Close())
+
+	feed, err := NewCSVFeed("2h", PairFeed{Pair: "BTCUSDT", File: file.Name(), Timeframe: "1h",
+		Timestamp: model.TimestampCloseTime})
+	require.NoError(t, err)
+
+	for i, candle := range feed.CandlePairTimeFrame["BTCUSDT--1h"] {
+		require.Equal(t, start.Add(time.Duration(i)*time.Hour), candle.Time.UTC())
+		require.Equal(t, float64(100+i), candle.Close)
+	}
+
+	// the candles of the open time are aggregated in the same period
+	var complete []model.Candle
+	for _, candle := range feed.CandlePairTimeFrame["BTCUSDT--2h"] {
+		if candle.Complete {
+			complete = append(complete, candle)
+		}
+	}
+	require.Len(t, complete, 4)
+	for i, candle := range complete {
+		require.Equal(t, start.Add(time.Duration(2*i)*time.Hour), candle.Time.UTC())
+		require.Equal(t, float64(100+2*i), candle.Open)
+		require.Equal(t, float64(101+2*i), candle.Close)
+	}
+}
@@ -0,0 +1,72 @@
+package exchange
+
+import (
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// DefaultDedupWindow is the default period in which the finalized candles of a feed are remembered
+const DefaultDedupWindow = 24 * time.Hour
+
+// candleDedup filters the candles of a feed resent by the exchange, keyed by pair and time of the candle.
+// Some feeds resend a candle with a different UpdatedAt, so the priority queue treats them as distinct.
+// The first complete candle finalizes the bar, later deliveries of the bar are suppressed, and partial
+// candles older than the last update of the bar are discarded. Finalized bars are remembered for the window,
+// counted from the last candle received, and candles older than the window are also suppressed.
+type candleDedup struct {
+	window  time.Duration
+	last    time.Time
+	updates map[candleKey]candleUpdate
+}
+
+type candleKey struct {
+	pair string
+	time int64
+}
+
+type candleUpdate struct {
+	updatedAt time.Time
+	finalized bool
+}
+
+func newCandleDedup(window time.Duration) *candleDedup {
+	return &candleDedup{
+		window:  window,
+		updates: make(map[candleKey]candleUpdate),
+	}
+}
+
+// accept returns true when the candle must be delivered to the subscribers
+func (d *candleDedup) accept(candle model.Candle) bool {
+	if candle.Time.Before(d.last.Add(-d.window)) {
+		return false
+	}
+
+	key := candleKey{pair: candle.Pair, time: candle.Time.UnixNano()}
+	update, ok := d.updates[key]
+	if ok && (update.finalized || candle.UpdatedAt.Before(update.updatedAt)) {
+		if candle.UpdatedAt.After(update.updatedAt) {
+			update.updatedAt = candle.UpdatedAt
+			d.updates[key] = update
+		}
+		return false
+	}
+
+	d.updates[key] = candleUpdate{updatedAt: candle.UpdatedAt, finalized: candle.Complete}
+	if candle.Time.After(d.last) {
+		d.last = candle.Time
+		d.prune()
+	}
+	return true
+}
+
+// prune removes the bars older than the window
+func (d *candleDedup) prune() {
+	limit := d.last.Add(-d.window)
+	for key := range d.updates {
+		if time.Unix(0, key.time).Before(limit) {
+			delete(d.updates, key)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package exchange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestCandleDedup_Accept(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candleAt := func(i int, update time.Duration, complete bool) model.Candle {
+		candleTime := start.Add(time.Duration(i) * time.Hour)
+		return model.Candle{Pair: "BTCUSDT", Time: candleTime, UpdatedAt: candleTime.Add(update),
+			Complete: complete}
+	}
+
+	t.Run("revised partial candles", func(t *testing.T) {
+		dedup := newCandleDedup(DefaultDedupWindow)
+		require.True(t, dedup.accept(candleAt(0, time.Minute, false)))
+		require.True(t, dedup.accept(candleAt(0, 2*time.Minute, false)))
+		// outdated revision
+		require.False(t, dedup.accept(candleAt(0, time.Minute, false)))
+		require.True(t, dedup.accept(candleAt(0, time.Hour, true)))
+	})
+
+	t.Run("finalized candles", func(t *testing.T) {
+		dedup := newCandleDedup(DefaultDedupWindow)
+		require.True(t, dedup.accept(candleAt(0, time.Hour, true)))
+		require.False(t, dedup.accept(candleAt(0, time.Hour+time.Second, true)))
+		require.False(t, dedup.accept(candleAt(0, time.Hour-time.Second, true)))
+		require.False(t, dedup.accept(candleAt(0, time.Minute, false)))
+
+		// same time in other pair
+		other := candleAt(0, time.Hour, true)
+		other.Pair = "ETHUSDT"
+		require.True(t, dedup.accept(other))
+	})
+
+	t.Run("window", func(t *testing.T) {
+		dedup := newCandleDedup(2 * time.Hour)
+		for i := 0; i < 5; i++ {
+			require.True(t, dedup.accept(candleAt(i, time.Hour, true)))
+		}
+		require.Len(t, dedup.updates, 3)
+		require.False(t, dedup.accept(candleAt(2, time.Hour, true)))
+		require.False(t, dedup.accept(candleAt(1, time.Hour, true)))
+	})
+}
+
+func TestDataFeedSubscription_Dedup(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candleAt := func(i int, update time.Duration, complete bool) model.Candle {
+		candleTime := start.Add(time.Duration(i) * time.Hour)
+		return model.Candle{Pair: "BTCUSDT", Time: candleTime, UpdatedAt: candleTime.Add(update),
+			Complete: complete}
+	}
+	stream := []model.Candle{
+		candleAt(0, time.Minute, false),
+		candleAt(0, time.Hour, true),
+		candleAt(0, time.Hour+time.Second, true), // resent with other UpdatedAt
+		candleAt(1, time.Hour, true),
+		candleAt(0, time.Hour+2*time.Second, true),
+		candleAt(1, time.Hour, true),
+	}
+
+	run := func(window time.Duration) []model.Candle {
+		feed := NewDataFeed(&baseExchange{stream: stream})
+		feed.SetDedupWindow(window)
+
+		var candles []model.Candle
+		feed.Subscribe("BTCUSDT", "1h", func(candle model.Candle) {
+			candles = append(candles, candle)
+		}, false)
+		feed.Start(true)
+		return candles
+	}
+
+	t.Run("default", func(t *testing.T) {
+		candles := run(DefaultDedupWindow)
+		require.Len(t, candles, 3)
+		require.False(t, candles[0].Complete)
+
+		finalized := make(map[time.Time]int)
+		for _, candle := range candles[1:] {
+			require.True(t, candle.Complete)
+			finalized[candle.Time]++
+		}
+		require.Equal(t, map[time.Time]int{candleAt(0, 0, true).Time: 1, candleAt(1, 0, true).Time: 1}, finalized)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		require.Len(t, run(0), len(stream))
+	})
+}
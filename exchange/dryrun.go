@@ -0,0 +1,78 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+var ErrDryRun = errors.New("order not executed in dry-run mode")
+
+// DryRun is an exchange that logs and rejects all orders with ErrDryRun, market data and account
+// information are read from the given exchange
+type DryRun struct {
+	service.Exchange
+	logger log.Logger
+}
+
+func NewDryRun(exchange service.Exchange) *DryRun {
+	return &DryRun{Exchange: exchange, logger: log.Default()}
+}
+
+// SetLogger replaces the default logger, see log.Logger
+func (d *DryRun) SetLogger(logger log.Logger) {
+	d.logger = logger
+}
+
+// OrderBook returns the order book of the wrapped exchange, see service.DepthFeeder
+func (d *DryRun) OrderBook(ctx context.Context, pair string, limit int) (model.OrderBook, error) {
+	return OrderBook(ctx, d.Exchange, pair, limit)
+}
+
+// SimulateOrder estimates the order with the wrapped exchange, see service.OrderSimulator
+func (d *DryRun) SimulateOrder(pair string, side model.SideType, quantity,
+	price float64) (model.OrderPreview, error) {
+	simulator, ok := d.Exchange.(service.OrderSimulator)
+	if !ok {
+		return model.OrderPreview{}, ErrSimulationNotSupported
+	}
+	return simulator.SimulateOrder(pair, side, quantity, price)
+}
+
+func (d *DryRun) reject(kind string, side model.SideType, pair string, fields ...interface{}) error {
+	d.logger.Info("[DRY-RUN] Order not executed", append([]interface{}{"pair", pair, "side", side,
+		"type", kind}, fields...)...)
+	return fmt.Errorf("%w: %s %s %s", ErrDryRun, kind, side, pair)
+}
+
+func (d *DryRun) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	return nil, d.reject("OCO", side, pair, "quantity", size, "price", price, "stop", stop,
+		"stopLimit", stopLimit)
+}
+
+func (d *DryRun) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	return model.Order{}, d.reject(string(model.OrderTypeLimit), side, pair, "quantity", size, "price", limit)
+}
+
+func (d *DryRun) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return model.Order{}, d.reject(string(model.OrderTypeMarket), side, pair, "quantity", size)
+}
+
+func (d *DryRun) CreateOrderMarketQuote(side model.SideType, pair string, quote float64) (model.Order, error) {
+	return model.Order{}, d.reject(string(model.OrderTypeMarket), side, pair, "amount", quote)
+}
+
+func (d *DryRun) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	return model.Order{}, d.reject(string(model.OrderTypeStopLoss), model.SideTypeSell, pair,
+		"quantity", quantity, "stop", limit)
+}
+
+func (d *DryRun) Cancel(order model.Order) error {
+	return d.reject("CANCEL", order.Side, order.Pair, "id", order.ExchangeID)
+}
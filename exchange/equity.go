@@ -0,0 +1,49 @@
+package exchange
+
+import (
+	"strings"
+	"time"
+)
+
+// WithPaperEquityAsset also reports the equity curve and the returns denominated in the given asset,
+// e.g. BTC for a wallet in USDT or ETH for ETHBTC pairs. The equity is converted with the last close
+// price of the asset against the base coin of the wallet, so strategies that make profit in the quote
+// but lose units of the asset are visible. See EquityAssetValues.
+func WithPaperEquityAsset(asset string) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.equityAsset = strings.ToUpper(asset)
+	}
+}
+
+// EquityAssetValues returns the equity curve denominated in the asset defined with WithPaperEquityAsset
+func (p *PaperWallet) EquityAssetValues() []AssetValue {
+	return p.assetEquity
+}
+
+// EquityAssetMaxDrawdown returns the max drawdown of the equity denominated in the asset
+// defined with WithPaperEquityAsset, see MaxDrawdown
+func (p *PaperWallet) EquityAssetMaxDrawdown() (float64, time.Time, time.Time) {
+	return MaxDrawdown(p.assetEquity)
+}
+
+// updateAssetEquity converts the equity in base coin to the equity asset,
+// values are skipped until the price of the asset is known
+func (p *PaperWallet) updateAssetEquity(at time.Time, equity float64) {
+	if p.equityAsset == "" {
+		return
+	}
+
+	price := 1.0
+	if p.equityAsset != p.baseCoin {
+		price = p.lastCandle[p.equityAsset+p.baseCoin].Close
+	}
+
+	if price <= 0 {
+		return
+	}
+
+	p.assetEquity = appendValue(p.assetEquity, AssetValue{
+		Time:  at,
+		Value: equity / price,
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/StudioSol/set"
 
@@ -15,9 +16,14 @@ import (
 )
 
 var (
-	ErrInvalidQuantity   = errors.New("invalid quantity")
-	ErrInsufficientFunds = errors.New("insufficient funds or locked")
-	ErrInvalidAsset      = errors.New("invalid asset")
+	ErrInvalidQuantity        = errors.New("invalid quantity")
+	ErrInsufficientFunds      = errors.New("insufficient funds or locked")
+	ErrInvalidAsset           = errors.New("invalid asset")
+	ErrReduceOnly             = errors.New("reduce-only order would increase the position")
+	ErrOrderBookNotSupported  = errors.New("order book not supported by the exchange")
+	ErrSimulationNotSupported = errors.New("order simulation not supported by the exchange")
+	ErrPriceNotAvailable      = errors.New("price not available")
+	ErrOrderNotAmendable      = errors.New("only open limit orders can be amended")
 )
 
 type DataFeed struct {
@@ -27,14 +33,18 @@ type DataFeed struct {
 
 type DataFeedSubscription struct {
 	exchange                service.Exchange
+	logger                  log.Logger
 	Feeds                   *set.LinkedHashSetString
 	DataFeeds               map[string]*DataFeed
 	SubscriptionsByDataFeed map[string][]Subscription
+	dedupWindow             time.Duration
+	candleGrace             time.Duration
 }
 
 type Subscription struct {
 	onCandleClose bool
 	consumer      DataFeedConsumer
+	buffer        *CandleBuffer
 }
 
 type OrderError struct {
@@ -47,17 +57,58 @@ func (o *OrderError) Error() string {
 	return fmt.Sprintf("order error: %v", o.Err)
 }
 
+func (o *OrderError) Unwrap() error {
+	return o.Err
+}
+
 type DataFeedConsumer func(model.Candle)
 
 func NewDataFeed(exchange service.Exchange) *DataFeedSubscription {
 	return &DataFeedSubscription{
 		exchange:                exchange,
+		logger:                  log.Default(),
 		Feeds:                   set.NewLinkedHashSetString(),
 		DataFeeds:               make(map[string]*DataFeed),
 		SubscriptionsByDataFeed: make(map[string][]Subscription),
+		dedupWindow:             DefaultDedupWindow,
+		candleGrace:             DefaultCandleGrace,
 	}
 }
 
+// SetLogger replaces the default logger, see log.Logger
+func (d *DataFeedSubscription) SetLogger(logger log.Logger) {
+	d.logger = logger
+}
+
+// SetDedupWindow sets the period in which the finalized candles of each feed are remembered to suppress the
+// candles resent by the exchange, DefaultDedupWindow by default. Zero disables the deduplication.
+func (d *DataFeedSubscription) SetDedupWindow(window time.Duration) {
+	d.dedupWindow = window
+}
+
+// SetCandleGrace sets the period a complete candle of the live feeds is held before it is delivered, to absorb
+// the revisions of the candle sent by the exchange after it was marked complete, DefaultCandleGrace by
+// default. Zero disables the grace period. Backtests (loadSync) are not delayed.
+func (d *DataFeedSubscription) SetCandleGrace(grace time.Duration) {
+	d.candleGrace = grace
+}
+
+// SetExchange replaces the source of the candles, e.g. by a feed that wraps the exchange, keeping the
+// subscriptions already registered. It must be called before Start.
+func (d *DataFeedSubscription) SetExchange(exchange service.Exchange) {
+	d.exchange = exchange
+}
+
+// OrderBook returns the order book of the pair when the source implements service.DepthFeeder, otherwise it
+// returns ErrOrderBookNotSupported
+func OrderBook(ctx context.Context, source interface{}, pair string, limit int) (model.OrderBook, error) {
+	feeder, ok := source.(service.DepthFeeder)
+	if !ok {
+		return model.OrderBook{}, ErrOrderBookNotSupported
+	}
+	return feeder.OrderBook(ctx, pair, limit)
+}
+
 func (d *DataFeedSubscription) feedKey(pair, timeframe string) string {
 	return fmt.Sprintf("%s--%s", pair, timeframe)
 }
@@ -76,8 +127,24 @@ func (d *DataFeedSubscription) Subscribe(pair, timeframe string, consumer DataFe
 	})
 }
 
+// SubscribeBuffered subscribes a consumer through a bounded CandleBuffer, so a slow consumer does not
+// hold the feed. The returned buffer exposes the queue depth and the number of dropped candles.
+func (d *DataFeedSubscription) SubscribeBuffered(pair, timeframe string, consumer DataFeedConsumer,
+	onCandleClose bool, capacity int, options ...CandleBufferOption) *CandleBuffer {
+
+	key := d.feedKey(pair, timeframe)
+	buffer := NewCandleBuffer(capacity, options...)
+	d.Feeds.Add(key)
+	d.SubscriptionsByDataFeed[key] = append(d.SubscriptionsByDataFeed[key], Subscription{
+		onCandleClose: onCandleClose,
+		consumer:      consumer,
+		buffer:        buffer,
+	})
+	return buffer
+}
+
 func (d *DataFeedSubscription) Preload(pair, timeframe string, candles []model.Candle) {
-	log.Infof("[SETUP] preloading %d candles for %s-%s", len(candles), pair, timeframe)
+	d.logger.Info("[SETUP] preloading candles", "pair", pair, "timeframe", timeframe, "candles", len(candles))
 	key := d.feedKey(pair, timeframe)
 	for _, candle := range candles {
 		if !candle.Complete {
@@ -91,7 +158,7 @@ func (d *DataFeedSubscription) Preload(pair, timeframe string, candles []model.C
 }
 
 func (d *DataFeedSubscription) Connect() {
-	log.Infof("Connecting to the exchange.")
+	d.logger.Info("Connecting to the exchange.")
 	for feed := range d.Feeds.Iter() {
 		pair, timeframe := d.pairTimeframeFromKey(feed)
 		ccandle, cerr := d.exchange.CandlesSubscription(context.Background(), pair, timeframe)
@@ -106,31 +173,72 @@ func (d *DataFeedSubscription) Start(loadSync bool) {
 	d.Connect()
 	wg := new(sync.WaitGroup)
 	for key, feed := range d.DataFeeds {
+		for _, subscription := range d.SubscriptionsByDataFeed[key] {
+			if subscription.buffer == nil {
+				continue
+			}
+
+			wg.Add(1)
+			go func(subscription Subscription) {
+				defer wg.Done()
+				for {
+					candle, ok := subscription.buffer.Pop()
+					if !ok {
+						return
+					}
+					subscription.consumer(candle)
+				}
+			}(subscription)
+		}
+
 		wg.Add(1)
 		go func(key string, feed *DataFeed) {
+			var dedup *candleDedup
+			if d.dedupWindow > 0 {
+				dedup = newCandleDedup(d.dedupWindow)
+			}
+
+			var data <-chan model.Candle = feed.Data
+			if d.candleGrace > 0 && !loadSync {
+				data = withCandleGrace(feed.Data, d.candleGrace)
+			}
 			for {
 				select {
-				case candle, ok := <-feed.Data:
+				case candle, ok := <-data:
 					if !ok {
+						for _, subscription := range d.SubscriptionsByDataFeed[key] {
+							if subscription.buffer != nil {
+								subscription.buffer.Close()
+							}
+						}
 						wg.Done()
 						return
 					}
+					if dedup != nil && !dedup.accept(candle) {
+						d.logger.Debug("dataFeedSubscription/start: duplicated candle suppressed", "feed", key,
+							"time", candle.Time, "updated_at", candle.UpdatedAt)
+						continue
+					}
 					for _, subscription := range d.SubscriptionsByDataFeed[key] {
 						if subscription.onCandleClose && !candle.Complete {
 							continue
 						}
+						if subscription.buffer != nil {
+							subscription.buffer.Push(candle)
+							continue
+						}
 						subscription.consumer(candle)
 					}
 				case err := <-feed.Err:
 					if err != nil {
-						log.Error("dataFeedSubscription/start: ", err)
+						d.logger.Error("dataFeedSubscription/start: "+err.Error(), "feed", key)
 					}
 				}
 			}
 		}(key, feed)
 	}
 
-	log.Infof("Data feed connected.")
+	d.logger.Info("Data feed connected.")
 	if loadSync {
 		wg.Wait()
 	}
@@ -0,0 +1,204 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// FailoverFeed is an exchange that reads the candles from a prioritized list of sources: the wrapped exchange,
+// the primary source, and the fallback sources, e.g. another exchange with the same pairs. All sources are
+// subscribed, and the candles are emitted from the source with the highest priority that delivered a candle
+// within the timeout. When the active source stops delivering candles, the feed fails over to the next one,
+// and fails back when a source with higher priority recovers. Orders are executed in the wrapped exchange.
+type FailoverFeed struct {
+	service.Exchange
+	sources  []service.Feeder
+	timeout  time.Duration
+	mtx      sync.Mutex
+	notifier service.Notifier
+	logger   log.Logger
+}
+
+// NewFailoverFeed creates a feed with the exchange as the primary source and the fallback sources in the order
+// of priority. A source is considered failed when it does not deliver a candle within the timeout.
+func NewFailoverFeed(exchange service.Exchange, timeout time.Duration, sources ...service.Feeder) *FailoverFeed {
+	return &FailoverFeed{
+		Exchange: exchange,
+		sources:  append([]service.Feeder{exchange}, sources...),
+		timeout:  timeout,
+		logger:   log.Default(),
+	}
+}
+
+// SetNotifier sends the failover and failback events to the notifier
+func (f *FailoverFeed) SetNotifier(notifier service.Notifier) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.notifier = notifier
+}
+
+func (f *FailoverFeed) SetLogger(logger log.Logger) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.logger = logger
+}
+
+// CandlesByPeriod returns the candles of the first source without errors
+func (f *FailoverFeed) CandlesByPeriod(ctx context.Context, pair, period string, start,
+	end time.Time) ([]model.Candle, error) {
+	var err error
+	for _, source := range f.sources {
+		var candles []model.Candle
+		if candles, err = source.CandlesByPeriod(ctx, pair, period, start, end); err == nil {
+			return candles, nil
+		}
+	}
+	return nil, err
+}
+
+// CandlesByLimit returns the candles of the first source without errors
+func (f *FailoverFeed) CandlesByLimit(ctx context.Context, pair, period string, limit int) ([]model.Candle, error) {
+	var err error
+	for _, source := range f.sources {
+		var candles []model.Candle
+		if candles, err = source.CandlesByLimit(ctx, pair, period, limit); err == nil {
+			return candles, nil
+		}
+	}
+	return nil, err
+}
+
+type sourceCandle struct {
+	source int
+	candle model.Candle
+}
+
+// CandlesSubscription emits the candles of the active source, see FailoverFeed. Candles older than the last
+// candle emitted, or of a complete candle already emitted, are discarded after a switch of source. The channels
+// are closed when all sources are closed.
+func (f *FailoverFeed) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle,
+	chan error) {
+	if f.timeout <= 0 {
+		return failedSubscription(fmt.Errorf("invalid failover timeout: %s", f.timeout))
+	}
+
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+
+	candles := make(chan sourceCandle)
+	closed := make(chan int)
+	wg := new(sync.WaitGroup)
+	for i, source := range f.sources {
+		sourceCandles, sourceErr := source.CandlesSubscription(ctx, pair, timeframe)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for err := range sourceErr {
+				cerr <- fmt.Errorf("candle source %s: %w", sourceName(i), err)
+			}
+		}(i)
+
+		go func(i int) {
+			for candle := range sourceCandles {
+				candles <- sourceCandle{source: i, candle: candle}
+			}
+			closed <- i
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(cerr)
+	}()
+
+	go func() {
+		defer close(ccandle)
+		f.failover(pair, timeframe, candles, closed, ccandle)
+	}()
+
+	return ccandle, cerr
+}
+
+// failover forwards the candles of the active source until all sources are closed
+func (f *FailoverFeed) failover(pair, timeframe string, candles chan sourceCandle, closed chan int,
+	ccandle chan model.Candle) {
+
+	now := time.Now()
+	lastReceived := make([]time.Time, len(f.sources))
+	open := make([]bool, len(f.sources))
+	for i := range f.sources {
+		lastReceived[i] = now
+		open[i] = true
+	}
+
+	active := 0
+	var last model.Candle
+	ticker := time.NewTicker(f.timeout / 2)
+	defer ticker.Stop()
+
+	// activate selects the source with the highest priority that is alive, the active source is kept when
+	// all sources are late
+	activate := func(now time.Time) {
+		for i := range f.sources {
+			if open[i] && now.Sub(lastReceived[i]) <= f.timeout {
+				if i != active {
+					f.notifySwitch(pair, timeframe, active, i)
+					active = i
+				}
+				return
+			}
+		}
+	}
+
+	for remaining := len(f.sources); remaining > 0; {
+		select {
+		case received := <-candles:
+			lastReceived[received.source] = time.Now()
+			activate(lastReceived[received.source])
+			candle := received.candle
+			if received.source != active || candle.Time.Before(last.Time) ||
+				candle.Time.Equal(last.Time) && last.Complete {
+				continue
+			}
+			last = candle
+			ccandle <- candle
+		case i := <-closed:
+			open[i] = false
+			remaining--
+			activate(time.Now())
+		case now := <-ticker.C:
+			activate(now)
+		}
+	}
+}
+
+func (f *FailoverFeed) notifySwitch(pair, timeframe string, from, to int) {
+	event := "FAILOVER"
+	if to < from {
+		event = "FAILBACK"
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.logger.Warn("candle feed "+event, "pair", pair, "timeframe", timeframe, "from", sourceName(from),
+		"to", sourceName(to))
+	if f.notifier != nil {
+		f.notifier.Notify(fmt.Sprintf("⚠️ CANDLE FEED %s - %s %s\n-----\nFrom: %s\nTo: %s", event, pair,
+			timeframe, sourceName(from), sourceName(to)))
+	}
+}
+
+func sourceName(i int) string {
+	if i == 0 {
+		return "primary"
+	}
+	return fmt.Sprintf("fallback %d", i)
+}
@@ -0,0 +1,108 @@
+package exchange
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// channelExchange emits the candles sent to its channel by the test
+type channelExchange struct {
+	service.Exchange
+	candles chan model.Candle
+}
+
+func (c *channelExchange) CandlesSubscription(_ context.Context, _, _ string) (chan model.Candle, chan error) {
+	cerr := make(chan error)
+	close(cerr)
+	return c.candles, cerr
+}
+
+type messageNotifier struct {
+	service.Notifier
+	mtx      sync.Mutex
+	messages []string
+}
+
+func (n *messageNotifier) Notify(message string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.messages = append(n.messages, message)
+}
+
+func (n *messageNotifier) Messages() []string {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return append([]string(nil), n.messages...)
+}
+
+func TestFailoverFeed_CandlesSubscription(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candleAt := func(i int) model.Candle {
+		return model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Minute), Close: float64(i),
+			Complete: true}
+	}
+
+	primary := &channelExchange{candles: make(chan model.Candle)}
+	secondary := &channelExchange{candles: make(chan model.Candle)}
+	notifier := &messageNotifier{}
+
+	feed := NewFailoverFeed(primary, 50*time.Millisecond, secondary)
+	feed.SetNotifier(notifier)
+	ccandle, _ := feed.CandlesSubscription(context.Background(), "BTCUSDT", "1m")
+
+	received := make(chan model.Candle, 10)
+	go func() {
+		for candle := range ccandle {
+			received <- candle
+		}
+		close(received)
+	}()
+	next := func() model.Candle {
+		select {
+		case candle := <-received:
+			return candle
+		case <-time.After(time.Second):
+			t.Fatal("candle not received")
+			return model.Candle{}
+		}
+	}
+
+	// both sources alive, only the primary is emitted
+	primary.candles <- candleAt(0)
+	secondary.candles <- candleAt(0)
+	require.Equal(t, candleAt(0), next())
+
+	// primary stops, the candles continue from the secondary
+	time.Sleep(100 * time.Millisecond)
+	secondary.candles <- candleAt(1)
+	require.Equal(t, candleAt(1), next())
+	secondary.candles <- candleAt(2)
+	require.Equal(t, candleAt(2), next())
+
+	// primary recovers, the older candle is discarded
+	primary.candles <- candleAt(1)
+	primary.candles <- candleAt(3)
+	secondary.candles <- candleAt(3)
+	require.Equal(t, candleAt(3), next())
+
+	messages := notifier.Messages()
+	require.Len(t, messages, 2)
+	require.True(t, strings.HasPrefix(messages[0], "⚠️ CANDLE FEED FAILOVER - BTCUSDT 1m"))
+	require.Contains(t, messages[0], "To: fallback 1")
+	require.True(t, strings.HasPrefix(messages[1], "⚠️ CANDLE FEED FAILBACK - BTCUSDT 1m"))
+	require.Contains(t, messages[1], "To: primary")
+
+	// closed when all sources are closed
+	close(primary.candles)
+	close(secondary.candles)
+	_, ok := <-received
+	require.False(t, ok)
+}
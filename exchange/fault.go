@@ -0,0 +1,165 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+var ErrInjectedFault = errors.New("injected exchange fault")
+
+// FaultExchange is an exchange that injects failures in the orders of the wrapped exchange, e.g. a paper
+// wallet, to test the error handling of strategies. Rejections are drawn from a random generator with the
+// given seed, so the same sequence of orders has the same failures.
+type FaultExchange struct {
+	service.Exchange
+
+	mtx        sync.Mutex
+	random     *rand.Rand
+	rejectRate float64
+	errs       []error
+	fillDelay  time.Duration
+	pending    map[int64]time.Time
+	now        func() time.Time
+}
+
+type FaultOption func(*FaultExchange)
+
+// WithFaultRejectRate rejects the given fraction of the new orders, e.g. 0.1 for 10%
+func WithFaultRejectRate(rate float64) FaultOption {
+	return func(exchange *FaultExchange) {
+		exchange.rejectRate = rate
+	}
+}
+
+// WithFaultErrors sets the errors returned by the rejected orders, one of them is drawn for each rejection,
+// e.g. an OrderError with ErrInsufficientFunds. By default, rejections return ErrInjectedFault.
+func WithFaultErrors(errs ...error) FaultOption {
+	return func(exchange *FaultExchange) {
+		exchange.errs = errs
+	}
+}
+
+// WithFaultFillDelay reports the market orders as new until the delay is elapsed, so the fill is only
+// known by querying the order
+func WithFaultFillDelay(delay time.Duration) FaultOption {
+	return func(exchange *FaultExchange) {
+		exchange.fillDelay = delay
+	}
+}
+
+func NewFaultExchange(exchange service.Exchange, seed int64, options ...FaultOption) *FaultExchange {
+	fault := &FaultExchange{
+		Exchange: exchange,
+		random:   rand.New(rand.NewSource(seed)),
+		pending:  make(map[int64]time.Time),
+		now:      time.Now,
+	}
+
+	for _, option := range options {
+		option(fault)
+	}
+
+	return fault
+}
+
+// reject returns the injected error when the order is drawn to be rejected
+func (f *FaultExchange) reject(kind string, side model.SideType, pair string) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.rejectRate <= 0 || f.random.Float64() >= f.rejectRate {
+		return nil
+	}
+
+	if len(f.errs) > 0 {
+		return f.errs[f.random.Intn(len(f.errs))]
+	}
+	return fmt.Errorf("%w: %s %s %s", ErrInjectedFault, kind, side, pair)
+}
+
+// delay reports a filled order as new until the fill delay is elapsed
+func (f *FaultExchange) delay(order model.Order) model.Order {
+	if f.fillDelay <= 0 || order.Status != model.OrderStatusTypeFilled {
+		return order
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.pending[order.ExchangeID] = f.now().Add(f.fillDelay)
+	order.Status = model.OrderStatusTypeNew
+	return order
+}
+
+func (f *FaultExchange) Order(pair string, id int64) (model.Order, error) {
+	order, err := f.Exchange.Order(pair, id)
+	if err != nil {
+		return order, err
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if filledAt, ok := f.pending[id]; ok {
+		if f.now().Before(filledAt) {
+			order.Status = model.OrderStatusTypeNew
+		} else {
+			delete(f.pending, id)
+		}
+	}
+	return order, nil
+}
+
+func (f *FaultExchange) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	if err := f.reject("OCO", side, pair); err != nil {
+		return nil, err
+	}
+	return f.Exchange.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
+}
+
+func (f *FaultExchange) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	if err := f.reject(string(model.OrderTypeLimit), side, pair); err != nil {
+		return model.Order{}, err
+	}
+	return f.Exchange.CreateOrderLimit(side, pair, size, limit)
+}
+
+func (f *FaultExchange) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	if err := f.reject(string(model.OrderTypeMarket), side, pair); err != nil {
+		return model.Order{}, err
+	}
+
+	order, err := f.Exchange.CreateOrderMarket(side, pair, size)
+	if err != nil {
+		return order, err
+	}
+	return f.delay(order), nil
+}
+
+func (f *FaultExchange) CreateOrderMarketQuote(side model.SideType, pair string,
+	quote float64) (model.Order, error) {
+	if err := f.reject(string(model.OrderTypeMarket), side, pair); err != nil {
+		return model.Order{}, err
+	}
+
+	order, err := f.Exchange.CreateOrderMarketQuote(side, pair, quote)
+	if err != nil {
+		return order, err
+	}
+	return f.delay(order), nil
+}
+
+func (f *FaultExchange) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	if err := f.reject(string(model.OrderTypeStopLoss), model.SideTypeSell, pair); err != nil {
+		return model.Order{}, err
+	}
+	return f.Exchange.CreateOrderStop(pair, quantity, limit)
+}
@@ -0,0 +1,88 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestFaultExchange(t *testing.T) {
+	newWallet := func() *PaperWallet {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100000))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		return wallet
+	}
+
+	rejections := func(fault *FaultExchange) []bool {
+		result := make([]bool, 0, 100)
+		for i := 0; i < 100; i++ {
+			_, err := fault.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+			result = append(result, err != nil)
+			if err != nil {
+				require.ErrorIs(t, err, ErrInjectedFault)
+			}
+		}
+		return result
+	}
+
+	t.Run("deterministic rejections", func(t *testing.T) {
+		first := rejections(NewFaultExchange(newWallet(), 42, WithFaultRejectRate(0.3)))
+		second := rejections(NewFaultExchange(newWallet(), 42, WithFaultRejectRate(0.3)))
+		require.Equal(t, first, second)
+
+		var count int
+		for _, rejected := range first {
+			if rejected {
+				count++
+			}
+		}
+		require.InDelta(t, 30, count, 15)
+	})
+
+	t.Run("without faults", func(t *testing.T) {
+		for _, rejected := range rejections(NewFaultExchange(newWallet(), 42)) {
+			require.False(t, rejected)
+		}
+	})
+
+	t.Run("custom errors", func(t *testing.T) {
+		fault := NewFaultExchange(newWallet(), 1, WithFaultRejectRate(1),
+			WithFaultErrors(&OrderError{Err: ErrInsufficientFunds, Pair: "BTCUSDT", Quantity: 1}))
+
+		_, err := fault.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.True(t, IsInsufficientFunds(err))
+		_, err = fault.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 1, 110, 95, 94)
+		require.True(t, IsInsufficientFunds(err))
+		_, err = fault.CreateOrderStop("BTCUSDT", 1, 95)
+		require.True(t, IsInsufficientFunds(err))
+
+		// rejected orders are not sent to the exchange
+		account, err := fault.Account()
+		require.NoError(t, err)
+		_, quote := account.Balance("BTC", "USDT")
+		require.Equal(t, 100000.0, quote.Free)
+	})
+
+	t.Run("fill delay", func(t *testing.T) {
+		now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+		fault := NewFaultExchange(newWallet(), 1, WithFaultFillDelay(time.Minute))
+		fault.now = func() time.Time { return now }
+
+		order, err := fault.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+
+		order, err = fault.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+
+		now = now.Add(time.Minute)
+		order, err = fault.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+	})
+}
@@ -0,0 +1,136 @@
+package exchange
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// FeeVolumeWindow is the rolling period of traded volume used to select a FeeTier
+const FeeVolumeWindow = 30 * 24 * time.Hour
+
+// FeeTier is a maker/taker fee level (e.g. 0.001 for 0.1%), applied when the volume
+// traded in the last FeeVolumeWindow is at least MinVolume
+type FeeTier struct {
+	MinVolume float64
+	Maker     float64
+	Taker     float64
+}
+
+type tradeVolume struct {
+	time   time.Time
+	volume float64
+}
+
+// WithPaperFeeTiers simulates volume-tiered fees, e.g. Binance VIP levels. For each execution, the tier
+// is selected by the volume traded in the previous 30 days, measured in the quote of the pairs.
+func WithPaperFeeTiers(tiers ...FeeTier) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.feeTiers = append([]FeeTier(nil), tiers...)
+		sort.Slice(wallet.feeTiers, func(i, j int) bool {
+			return wallet.feeTiers[i].MinVolume < wallet.feeTiers[j].MinVolume
+		})
+	}
+}
+
+func (p *PaperWallet) feeEnabled() bool {
+	return p.makerFee > 0 || p.takerFee > 0 || len(p.feeTiers) > 0
+}
+
+// rollingVolume returns the volume traded in the window before the given time
+func (p *PaperWallet) rollingVolume(at time.Time) float64 {
+	start := at.Add(-FeeVolumeWindow)
+
+	// discard trades outside the window
+	first := 0
+	for first < len(p.tradeVolumes) && !p.tradeVolumes[first].time.After(start) {
+		first++
+	}
+	p.tradeVolumes = p.tradeVolumes[first:]
+
+	var volume float64
+	for _, trade := range p.tradeVolumes {
+		volume += trade.volume
+	}
+	return volume
+}
+
+// FeeRate returns the maker and taker fees applied to an execution at the given time
+func (p *PaperWallet) FeeRate(at time.Time) (maker, taker float64) {
+	if len(p.feeTiers) == 0 {
+		return p.makerFee, p.takerFee
+	}
+
+	volume := p.rollingVolume(at)
+	tier := p.feeTiers[0]
+	for _, candidate := range p.feeTiers {
+		if volume < candidate.MinVolume {
+			break
+		}
+		tier = candidate
+	}
+	return tier.Maker, tier.Taker
+}
+
+// AverageFeeRate returns the total fees paid divided by the total volume traded
+func (p *PaperWallet) AverageFeeRate() float64 {
+	if p.feeVolume == 0 {
+		return 0
+	}
+	return p.fees / p.feeVolume
+}
+
+// estimateFee returns the fee of an execution of the volume at the given time
+func (p *PaperWallet) estimateFee(volume float64, maker bool, at time.Time) float64 {
+	if !p.feeEnabled() {
+		return 0
+	}
+
+	makerFee, takerFee := p.FeeRate(at)
+	if maker {
+		return volume * makerFee
+	}
+	return volume * takerFee
+}
+
+// chargeFee deducts the fee of an execution from the quote balance and returns the fee charged. The fee of an
+// open order filled without the quote to pay it, e.g. spent by other orders after the creation, is limited to
+// the free quote balance, so the balance never goes negative.
+func (p *PaperWallet) chargeFee(pair string, volume float64, maker bool, at time.Time) float64 {
+	if !p.feeEnabled() {
+		return 0
+	}
+
+	fee := p.estimateFee(volume, maker, at)
+	_, quote := SplitAssetQuote(pair)
+	if free := math.Max(p.asset(quote).Free, 0); fee > free {
+		log.Warnf("paper wallet: fee of %s limited to the free balance of %s, %f of %f", pair, quote, free, fee)
+		fee = free
+	}
+	p.asset(quote).addFree(-fee)
+
+	p.fees += fee
+	p.feeVolume += volume
+	p.tradeVolumes = append(p.tradeVolumes, tradeVolume{time: at, volume: volume})
+	return fee
+}
+
+// WithPaperSlippage simulates the slippage of market orders, filled at the last close price worse by
+// the given rate (e.g. 0.0005 for 0.05%). The cost is registered in the Slippage field of the order.
+func WithPaperSlippage(rate float64) PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.slippage = rate
+	}
+}
+
+// marketPrice returns the fill price of a market order with the simulated slippage
+func (p *PaperWallet) marketPrice(side model.SideType, pair string) float64 {
+	price := p.lastCandle[pair].Close
+	if side == model.SideTypeBuy {
+		return price * (1 + p.slippage)
+	}
+	return price * (1 - p.slippage)
+}
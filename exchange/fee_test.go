@@ -0,0 +1,101 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestPaperWallet_FeeTiers(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 10000),
+		WithPaperFeeTiers(
+			FeeTier{MinVolume: 10000, Maker: 0.0005, Taker: 0.0008},
+			FeeTier{MinVolume: 0, Maker: 0.001, Taker: 0.001},
+		))
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	trade := func(day int, side model.SideType) {
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.AddDate(0, 0, day), Close: 5000})
+		_, err := wallet.CreateOrderMarket(side, "BTCUSDT", 1)
+		require.NoError(t, err)
+	}
+
+	// first tier, 0.1% of 5000 USDT for each trade
+	trade(0, model.SideTypeBuy)
+	trade(1, model.SideTypeSell)
+	require.InDelta(t, 9990.0, wallet.assets["USDT"].Free, 1e-9)
+
+	// 10000 USDT traded in the last 30 days, next tier
+	_, taker := wallet.FeeRate(start.AddDate(0, 0, 2))
+	require.Equal(t, 0.0008, taker)
+	trade(2, model.SideTypeBuy)
+	trade(3, model.SideTypeSell)
+	require.InDelta(t, 9982.0, wallet.assets["USDT"].Free, 1e-9)
+	require.InDelta(t, 18/20000.0, wallet.AverageFeeRate(), 1e-12)
+
+	// previous trades out of the window, back to the first tier
+	trade(40, model.SideTypeBuy)
+	require.InDelta(t, 4977.0, wallet.assets["USDT"].Free, 1e-9)
+
+	maker, _ := wallet.FeeRate(start.AddDate(0, 0, 41))
+	require.Equal(t, 0.001, maker)
+}
+
+func TestPaperWallet_FeeLimitOrder(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+		WithPaperFee(0.001, 0.002))
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	_, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+	require.NoError(t, err)
+
+	// maker fee charged on fill
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 90, High: 100, Low: 85})
+	require.InDelta(t, 909.91, wallet.assets["USDT"].Free, 1e-9)
+	require.InDelta(t, 0.001, wallet.AverageFeeRate(), 1e-12)
+
+	// taker fee on market order
+	_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.InDelta(t, 909.91+90-0.18, wallet.assets["USDT"].Free, 1e-9)
+}
+
+func TestPaperWallet_FeeFunds(t *testing.T) {
+	t.Run("market order without the fee", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+			WithPaperFee(0.001, 0.001))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, ErrInsufficientFunds)
+		require.Equal(t, 100.0, wallet.assets["USDT"].Free)
+		require.Zero(t, wallet.assets["BTC"].Free)
+		require.Zero(t, wallet.avgLongPrice["BTCUSDT"])
+
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.99)
+		require.NoError(t, err)
+		require.InDelta(t, 0.099, order.Fee, 1e-9)
+		require.InDelta(t, 0.901, wallet.assets["USDT"].Free, 1e-9)
+	})
+
+	t.Run("limit order without the fee", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100),
+			WithPaperFee(0.001, 0.001))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 110})
+
+		// all the quote is locked by the order, the fee is limited to the free balance
+		_, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 100)
+		require.NoError(t, err)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 110, Low: 95})
+
+		order := wallet.orders[0]
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Zero(t, order.Fee)
+		require.Zero(t, wallet.assets["USDT"].Free)
+		require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+	})
+}
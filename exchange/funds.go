@@ -0,0 +1,29 @@
+package exchange
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/adshao/go-binance/v2/common"
+)
+
+// IsInsufficientFunds returns true when the order was rejected by the lack of balance, in the paper wallet
+// (ErrInsufficientFunds) or in the exchange API, e.g. a concurrent fill or fees consuming part of the balance
+func IsInsufficientFunds(err error) bool {
+	if errors.Is(err, ErrInsufficientFunds) {
+		return true
+	}
+
+	var apiError *common.APIError
+	if !errors.As(err, &apiError) {
+		return false
+	}
+
+	switch apiError.Code {
+	case -2019: // margin is insufficient (futures)
+		return true
+	case -2010: // new order rejected, also returned by other reasons
+		return strings.Contains(strings.ToLower(apiError.Message), "insufficient balance")
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsInsufficientFunds(t *testing.T) {
+	require.True(t, IsInsufficientFunds(&OrderError{Err: ErrInsufficientFunds, Pair: "BTCUSDT"}))
+	require.True(t, IsInsufficientFunds(&common.APIError{Code: -2010,
+		Message: "Account has insufficient balance for requested action."}))
+	require.True(t, IsInsufficientFunds(&common.APIError{Code: -2019, Message: "Margin is insufficient."}))
+	require.True(t, IsInsufficientFunds(fmt.Errorf("order: %w", ErrInsufficientFunds)))
+
+	require.False(t, IsInsufficientFunds(nil))
+	require.False(t, IsInsufficientFunds(errors.New("timeout")))
+	require.False(t, IsInsufficientFunds(&common.APIError{Code: -2010, Message: "Market is closed."}))
+	require.False(t, IsInsufficientFunds(&OrderError{Err: ErrInvalidQuantity, Pair: "BTCUSDT"}))
+}
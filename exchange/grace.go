@@ -0,0 +1,64 @@
+package exchange
+
+import (
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// DefaultCandleGrace is the default period a complete candle of a live feed is held before it is delivered
+const DefaultCandleGrace = 2 * time.Second
+
+// withCandleGrace delays the complete candles of the feed by the grace period, so the last-tick revisions sent
+// by the exchange after the candle was marked complete are absorbed. Updates of the held candle within the
+// grace period replace it, and it is still delivered once as complete. The held candle is delivered earlier
+// when a candle of a later period arrives or the feed is closed. Partial and older candles are not delayed.
+func withCandleGrace(candles <-chan model.Candle, grace time.Duration) <-chan model.Candle {
+	output := make(chan model.Candle)
+	go func() {
+		defer close(output)
+
+		var pending *model.Candle
+		var expired <-chan time.Time
+		flush := func() {
+			if pending != nil {
+				output <- *pending
+			}
+			pending, expired = nil, nil
+		}
+
+		for {
+			select {
+			case candle, ok := <-candles:
+				if !ok {
+					flush()
+					return
+				}
+
+				if pending != nil && candle.Time.Equal(pending.Time) {
+					// outdated revision
+					if candle.UpdatedAt.Before(pending.UpdatedAt) {
+						continue
+					}
+					candle.Complete = true
+					pending = &candle
+					continue
+				}
+
+				if pending != nil && candle.Time.After(pending.Time) {
+					flush()
+				}
+
+				if candle.Complete && pending == nil {
+					pending = &candle
+					expired = time.After(grace)
+					continue
+				}
+				output <- candle
+			case <-expired:
+				flush()
+			}
+		}
+	}()
+	return output
+}
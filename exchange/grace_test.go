@@ -0,0 +1,68 @@
+package exchange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestWithCandleGrace(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candleAt := func(i int, update time.Duration, close float64, complete bool) model.Candle {
+		candleTime := start.Add(time.Duration(i) * time.Hour)
+		return model.Candle{Pair: "BTCUSDT", Time: candleTime, UpdatedAt: candleTime.Add(update), Close: close,
+			Complete: complete}
+	}
+
+	t.Run("late update within the grace period", func(t *testing.T) {
+		input := make(chan model.Candle)
+		output := withCandleGrace(input, 100*time.Millisecond)
+
+		input <- candleAt(0, time.Minute, 9, false)
+		require.Equal(t, candleAt(0, time.Minute, 9, false), <-output)
+
+		sent := time.Now()
+		input <- candleAt(0, time.Hour, 10, true)
+		input <- candleAt(0, time.Hour+time.Second, 11, false)
+		// outdated revision
+		input <- candleAt(0, time.Hour-time.Second, 12, false)
+
+		candle := <-output
+		require.GreaterOrEqual(t, time.Since(sent), 100*time.Millisecond)
+		require.Equal(t, candleAt(0, time.Hour+time.Second, 11, true), candle)
+
+		close(input)
+		_, ok := <-output
+		require.False(t, ok)
+	})
+
+	t.Run("next candle", func(t *testing.T) {
+		input := make(chan model.Candle)
+		output := withCandleGrace(input, time.Hour)
+
+		input <- candleAt(0, time.Hour, 10, true)
+		go func() {
+			input <- candleAt(1, time.Minute, 20, false)
+			close(input)
+		}()
+
+		require.Equal(t, candleAt(0, time.Hour, 10, true), <-output)
+		require.Equal(t, candleAt(1, time.Minute, 20, false), <-output)
+		_, ok := <-output
+		require.False(t, ok)
+	})
+
+	t.Run("closed feed", func(t *testing.T) {
+		input := make(chan model.Candle, 1)
+		output := withCandleGrace(input, time.Hour)
+		input <- candleAt(0, time.Hour, 10, true)
+		close(input)
+
+		require.Equal(t, candleAt(0, time.Hour, 10, true), <-output)
+		_, ok := <-output
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,110 @@
+package exchange
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// icebergDust is the fraction of the order size below which the order is considered filled, for float rounding
+const icebergDust = 1e-9
+
+// Fill is a partial execution of an order, e.g. a slice of an iceberg order
+type Fill struct {
+	OrderID  int64
+	Time     time.Time
+	Price    float64
+	Quantity float64
+	Fee      float64
+}
+
+// CreateOrderIceberg creates a limit order that shows only the visible quantity in the order book. The funds of
+// the whole size are locked in the creation. In the simulation, each candle that reaches the limit price fills
+// one slice of the visible quantity, since the refilled slice loses the priority in the queue. The order is
+// partially filled until the last slice, the slices are returned by Fills.
+func (p *PaperWallet) CreateOrderIceberg(side model.SideType, pair string, size, limit,
+	visibleQuantity float64) (model.Order, error) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	if size <= 0 {
+		return model.Order{}, ErrInvalidQuantity
+	}
+	if visibleQuantity <= 0 || visibleQuantity > size {
+		return model.Order{}, fmt.Errorf("%w: visible quantity %f of size %f", ErrInvalidQuantity,
+			visibleQuantity, size)
+	}
+
+	lock, err := p.lockFunds(side, pair, size, limit)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order := model.Order{
+		ExchangeID:      p.ID(),
+		CreatedAt:       p.lastCandle[pair].Time,
+		UpdatedAt:       p.lastCandle[pair].Time,
+		Pair:            pair,
+		Side:            side,
+		Type:            model.OrderTypeIceberg,
+		Status:          model.OrderStatusTypeNew,
+		Price:           limit,
+		Quantity:        size,
+		VisibleQuantity: visibleQuantity,
+	}
+	p.orders = append(p.orders, order)
+	p.locks[order.ExchangeID] = lock
+	return order, nil
+}
+
+// Fills returns the slices filled of an order, in chronological order
+func (p *PaperWallet) Fills(id int64) []Fill {
+	p.Lock()
+	defer p.Unlock()
+
+	return append([]Fill(nil), p.fills[id]...)
+}
+
+func (p *PaperWallet) filledQuantity(id int64) float64 {
+	var quantity float64
+	for _, fill := range p.fills[id] {
+		quantity += fill.Quantity
+	}
+	return quantity
+}
+
+// fillIceberg fills the next slice of the iceberg order when the candle reaches the limit price, with the
+// accounting of the limit orders, see fillOrder
+func (p *PaperWallet) fillIceberg(i int, candle model.Candle) {
+	order := p.orders[i]
+	if order.Status != model.OrderStatusTypeNew && order.Status != model.OrderStatusTypePartiallyFilled {
+		return
+	}
+	if order.Side == model.SideTypeBuy && candle.Low > order.Price ||
+		order.Side == model.SideTypeSell && candle.High < order.Price {
+		return
+	}
+
+	remaining := order.Quantity - p.filledQuantity(order.ExchangeID)
+	quantity := math.Min(order.VisibleQuantity, remaining)
+
+	fill := Fill{
+		OrderID:  order.ExchangeID,
+		Time:     candle.Time,
+		Price:    order.Price,
+		Quantity: quantity,
+		Fee:      p.fillOrder(order, quantity, order.Price, true, candle.Time),
+	}
+	p.fills[order.ExchangeID] = append(p.fills[order.ExchangeID], fill)
+
+	p.orders[i].Fee += fill.Fee
+	p.orders[i].UpdatedAt = candle.Time
+	p.orders[i].Status = model.OrderStatusTypePartiallyFilled
+	if remaining-quantity <= icebergDust*order.Quantity {
+		p.orders[i].Status = model.OrderStatusTypeFilled
+		delete(p.locks, lockKey(order))
+	}
+}
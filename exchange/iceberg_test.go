@@ -0,0 +1,143 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestPaperWallet_CreateOrderIceberg(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candleAt := func(i int, low, high float64) model.Candle {
+		return model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour), Low: low,
+			High: high, Close: (low + high) / 2, Complete: true}
+	}
+
+	t.Run("buy", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+		wallet.OnCandle(candleAt(0, 110, 120))
+
+		order, err := wallet.CreateOrderIceberg(model.SideTypeBuy, "BTCUSDT", 2.5, 100, 1)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderTypeIceberg, order.Type)
+		require.Equal(t, 750.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 250.0, wallet.assets["USDT"].Lock)
+
+		// above the limit price
+		wallet.OnCandle(candleAt(1, 105, 115))
+		require.Empty(t, wallet.Fills(order.ExchangeID))
+
+		wallet.OnCandle(candleAt(2, 95, 105))
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypePartiallyFilled, order.Status)
+		require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 150.0, wallet.assets["USDT"].Lock)
+
+		wallet.OnCandle(candleAt(3, 90, 100))
+		wallet.OnCandle(candleAt(4, 90, 100))
+		wallet.OnCandle(candleAt(5, 90, 100))
+
+		fills := wallet.Fills(order.ExchangeID)
+		require.Len(t, fills, 3)
+		var total float64
+		for i, fill := range fills {
+			require.Equal(t, 100.0, fill.Price)
+			require.Equal(t, candleAt(i+2, 0, 0).Time, fill.Time)
+			total += fill.Quantity
+		}
+		require.Equal(t, 0.5, fills[2].Quantity)
+		require.Equal(t, 2.5, total)
+
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, candleAt(4, 0, 0).Time, order.UpdatedAt)
+		require.Equal(t, 2.5, wallet.assets["BTC"].Free)
+		require.Equal(t, 750.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	})
+
+	t.Run("sell and cancel", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", 3),
+			WithPaperAsset("USDT", 0))
+		wallet.OnCandle(candleAt(0, 90, 100))
+
+		order, err := wallet.CreateOrderIceberg(model.SideTypeSell, "BTCUSDT", 3, 110, 1)
+		require.NoError(t, err)
+		require.Equal(t, 3.0, wallet.assets["BTC"].Lock)
+
+		wallet.OnCandle(candleAt(1, 100, 115))
+		require.Len(t, wallet.Fills(order.ExchangeID), 1)
+		require.Equal(t, 110.0, wallet.assets["USDT"].Free)
+
+		// the slices not filled are unlocked
+		require.NoError(t, wallet.Cancel(order))
+		require.Equal(t, 2.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+
+		wallet.OnCandle(candleAt(2, 100, 115))
+		require.Len(t, wallet.Fills(order.ExchangeID), 1)
+	})
+
+	t.Run("short and cover", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+		wallet.OnCandle(candleAt(0, 90, 100))
+
+		// opens a short position with the quote locked
+		order, err := wallet.CreateOrderIceberg(model.SideTypeSell, "BTCUSDT", 2, 110, 1)
+		require.NoError(t, err)
+		require.Equal(t, 220.0, wallet.assets["USDT"].Lock)
+
+		wallet.OnCandle(candleAt(1, 100, 115))
+		wallet.OnCandle(candleAt(2, 100, 115))
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, -2.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+		require.Equal(t, 780.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+
+		// buys to cover the short position, with profit of 20 USDT
+		order, err = wallet.CreateOrderIceberg(model.SideTypeBuy, "BTCUSDT", 2, 100, 1)
+		require.NoError(t, err)
+		wallet.OnCandle(candleAt(3, 95, 105))
+		wallet.OnCandle(candleAt(4, 95, 105))
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.InDelta(t, 0.0, wallet.assets["BTC"].Free, 1e-9)
+		require.InDelta(t, 0.0, wallet.assets["BTC"].Lock, 1e-9)
+		require.InDelta(t, 1020.0, wallet.assets["USDT"].Free, 1e-9)
+		require.InDelta(t, 0.0, wallet.assets["USDT"].Lock, 1e-9)
+	})
+
+	t.Run("flip long to short", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", 1),
+			WithPaperAsset("USDT", 200))
+		wallet.OnCandle(candleAt(0, 90, 100))
+
+		// sells the long of 1 BTC and opens a short of 1 BTC, the same of a market order at the price
+		_, err := wallet.CreateOrderIceberg(model.SideTypeSell, "BTCUSDT", 2, 110, 1)
+		require.NoError(t, err)
+		wallet.OnCandle(candleAt(1, 100, 115))
+		wallet.OnCandle(candleAt(2, 100, 115))
+		require.Equal(t, -1.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+		require.Equal(t, 200.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	})
+
+	t.Run("invalid visible quantity", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+		_, err := wallet.CreateOrderIceberg(model.SideTypeBuy, "BTCUSDT", 1, 100, 2)
+		require.ErrorIs(t, err, ErrInvalidQuantity)
+		_, err = wallet.CreateOrderIceberg(model.SideTypeBuy, "BTCUSDT", 1, 100, 0)
+		require.ErrorIs(t, err, ErrInvalidQuantity)
+	})
+}
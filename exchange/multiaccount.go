@@ -0,0 +1,591 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+var ErrNoAccountEquity = errors.New("accounts without equity in the pair")
+
+// AccountExchange is an exchange account of a MultiAccount, e.g. a Binance client with the keys of a sub-account
+type AccountExchange struct {
+	Name     string
+	Exchange service.Exchange
+}
+
+// AccountError is the error of an order in one of the accounts of a MultiAccount
+type AccountError struct {
+	Account string
+	Err     error
+}
+
+func (a *AccountError) Error() string {
+	return fmt.Sprintf("account %s: %v", a.Account, a.Err)
+}
+
+func (a *AccountError) Unwrap() error {
+	return a.Err
+}
+
+// AccountReport is the state of an account of a MultiAccount in a pair
+type AccountReport struct {
+	Name  string
+	Asset float64
+	Quote float64
+	// Equity is the value of the asset and quote balances in the quote, and Share is its fraction of the
+	// equity of all accounts, used to size the orders that open or increase a position in the account
+	Equity float64
+	Share  float64
+	// Orders and Failures count the orders created and rejected in the account, with the last rejection
+	Orders    int
+	Failures  int
+	LastError error
+}
+
+type accountOrder struct {
+	account int
+	order   model.Order
+}
+
+type accountStatus struct {
+	orders    int
+	failures  int
+	lastError error
+}
+
+// MultiAccount is an exchange that executes each order in all the accounts, with the quantity split by the
+// equity of each account in the pair, so the same strategy runs proportionally in several accounts.
+// Orders that reduce or close the position are split by the position of each account instead, so an account
+// never sells more than it holds when the positions drifted apart, e.g. after a failure or a manual trade.
+// The strategy sees a single account with the sum of the balances. Market data is read from the first account.
+// An order that fails in some accounts is kept in the others, the failures are logged and reported by Report.
+// Orders are tracked in memory, so open orders are not recovered after a restart.
+type MultiAccount struct {
+	mtx      sync.Mutex
+	ctx      context.Context
+	accounts []AccountExchange
+	status   []accountStatus
+	counter  int64
+	orders   map[int64][]accountOrder
+	logger   log.Logger
+}
+
+func NewMultiAccount(ctx context.Context, accounts ...AccountExchange) (*MultiAccount, error) {
+	if len(accounts) == 0 {
+		return nil, errors.New("multi-account: no accounts")
+	}
+
+	names := make(map[string]bool)
+	for _, account := range accounts {
+		if names[account.Name] {
+			return nil, fmt.Errorf("multi-account: duplicated account %s", account.Name)
+		}
+		names[account.Name] = true
+	}
+
+	return &MultiAccount{
+		ctx:      ctx,
+		accounts: accounts,
+		status:   make([]accountStatus, len(accounts)),
+		orders:   make(map[int64][]accountOrder),
+		logger:   log.Default(),
+	}, nil
+}
+
+// SetLogger replaces the default logger, see log.Logger
+func (m *MultiAccount) SetLogger(logger log.Logger) {
+	m.logger = logger
+}
+
+func (m *MultiAccount) primary() service.Exchange {
+	return m.accounts[0].Exchange
+}
+
+func (m *MultiAccount) AssetsInfo(pair string) model.AssetInfo {
+	return m.primary().AssetsInfo(pair)
+}
+
+func (m *MultiAccount) LastQuote(ctx context.Context, pair string) (float64, error) {
+	return m.primary().LastQuote(ctx, pair)
+}
+
+func (m *MultiAccount) CandlesByPeriod(ctx context.Context, pair, period string,
+	start, end time.Time) ([]model.Candle, error) {
+	return m.primary().CandlesByPeriod(ctx, pair, period, start, end)
+}
+
+func (m *MultiAccount) CandlesByLimit(ctx context.Context, pair, period string, limit int) ([]model.Candle, error) {
+	return m.primary().CandlesByLimit(ctx, pair, period, limit)
+}
+
+func (m *MultiAccount) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle,
+	chan error) {
+	return m.primary().CandlesSubscription(ctx, pair, timeframe)
+}
+
+// Account returns the sum of the balances of all accounts
+func (m *MultiAccount) Account() (model.Account, error) {
+	balances := make(map[string]*model.Balance)
+	assets := make([]string, 0)
+	for _, account := range m.accounts {
+		result, err := account.Exchange.Account()
+		if err != nil {
+			return model.Account{}, &AccountError{Account: account.Name, Err: err}
+		}
+
+		for _, balance := range result.Balances {
+			total, ok := balances[balance.Asset]
+			if !ok {
+				total = &model.Balance{Asset: balance.Asset}
+				balances[balance.Asset] = total
+				assets = append(assets, balance.Asset)
+			}
+			total.Free += balance.Free
+			total.Lock += balance.Lock
+		}
+	}
+
+	account := model.Account{Balances: make([]model.Balance, 0, len(assets))}
+	for _, asset := range assets {
+		account.Balances = append(account.Balances, *balances[asset])
+	}
+	return account, nil
+}
+
+// Position returns the sum of the positions of all accounts
+func (m *MultiAccount) Position(pair string) (asset, quote float64, err error) {
+	for _, account := range m.accounts {
+		accountAsset, accountQuote, err := account.Exchange.Position(pair)
+		if err != nil {
+			return 0, 0, &AccountError{Account: account.Name, Err: err}
+		}
+		asset += accountAsset
+		quote += accountQuote
+	}
+	return asset, quote, nil
+}
+
+// Report returns the balances, equity share and order status of each account in the pair
+func (m *MultiAccount) Report(pair string) ([]AccountReport, error) {
+	reports, _, err := m.report(pair)
+	return reports, err
+}
+
+func (m *MultiAccount) report(pair string) ([]AccountReport, float64, error) {
+	price, err := m.primary().LastQuote(m.ctx, pair)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	reports := make([]AccountReport, 0, len(m.accounts))
+	var total float64
+	for _, account := range m.accounts {
+		asset, quote, err := account.Exchange.Position(pair)
+		if err != nil {
+			return nil, 0, &AccountError{Account: account.Name, Err: err}
+		}
+
+		report := AccountReport{Name: account.Name, Asset: asset, Quote: quote, Equity: quote + asset*price}
+		total += report.Equity
+		reports = append(reports, report)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for i := range reports {
+		if total > 0 {
+			reports[i].Share = reports[i].Equity / total
+		}
+		reports[i].Orders = m.status[i].orders
+		reports[i].Failures = m.status[i].failures
+		reports[i].LastError = m.status[i].lastError
+	}
+	return reports, price, nil
+}
+
+// equityShares returns the fraction of the equity of each account in the pair
+func equityShares(pair string, reports []AccountReport) ([]float64, error) {
+	// accounts without equity, e.g. with a short position larger than the balance, are skipped
+	shares := make([]float64, len(reports))
+	var valid bool
+	for i, report := range reports {
+		if report.Share > 0 {
+			shares[i], valid = report.Share, true
+		}
+	}
+
+	if !valid {
+		return nil, fmt.Errorf("%w: %s", ErrNoAccountEquity, pair)
+	}
+	return shares, nil
+}
+
+// sizes splits the quantity of the order between the accounts. Orders that reduce the position are split by
+// the position of each account in the same direction, capped at that position, and only the quantity beyond
+// the total position, e.g. of an order that flips it, is split by the equity. Quote amounts are converted to
+// the asset with the last price.
+func (m *MultiAccount) sizes(pair string, side model.SideType, quantity float64, quote bool) ([]float64, error) {
+	reports, price, err := m.report(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	var position float64
+	for _, report := range reports {
+		position += report.Asset
+	}
+
+	direction := 1.0
+	if position < 0 {
+		direction = -1
+	}
+
+	reducing := (side == model.SideTypeSell && position > 0) || (side == model.SideTypeBuy && position < 0)
+	if !reducing {
+		shares, err := equityShares(pair, reports)
+		if err != nil {
+			return nil, err
+		}
+
+		sizes := make([]float64, len(reports))
+		for i := range shares {
+			sizes[i] = quantity * shares[i]
+		}
+		return sizes, nil
+	}
+
+	if quote {
+		if price <= 0 {
+			return nil, fmt.Errorf("multi-account: invalid price %f for %s", price, pair)
+		}
+		quantity /= price
+	}
+
+	// positions of the accounts in the direction of the total position, accounts with an opposite
+	// position are not reduced
+	positions := make([]float64, len(reports))
+	var total float64
+	for i, report := range reports {
+		if held := report.Asset * direction; held > 0 {
+			positions[i] = held
+			total += held
+		}
+	}
+
+	closing := math.Min(quantity, total)
+	sizes := make([]float64, len(reports))
+	for i := range positions {
+		sizes[i] = closing * positions[i] / total
+	}
+
+	if excess := quantity - closing; excess > 0 {
+		shares, err := equityShares(pair, reports)
+		if err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			sizes[i] += excess * shares[i]
+		}
+	}
+
+	if quote {
+		for i := range sizes {
+			sizes[i] *= price
+		}
+	}
+	return sizes, nil
+}
+
+// execute runs the order in each account with its share of the quantity, concurrently. The order is kept in
+// the accounts where it succeeded, and the error is returned only when it failed in all of them.
+func (m *MultiAccount) execute(pair string, side model.SideType, quantity float64, lotSize bool,
+	create func(exchange service.Exchange, quantity float64) ([]model.Order, error)) ([][]model.Order, error) {
+
+	sizes, err := m.sizes(pair, side, quantity, !lotSize)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]model.Order, len(m.accounts))
+	errs := make([]error, len(m.accounts))
+	wg := new(sync.WaitGroup)
+	for i, account := range m.accounts {
+		size := sizes[i]
+		if lotSize {
+			size = account.Exchange.AssetsInfo(pair).RoundQuantity(size)
+		}
+
+		if size <= 0 {
+			m.logger.Debug("[MULTI-ACCOUNT] Order skipped, quantity too small", "account", account.Name,
+				"pair", pair, "size", sizes[i])
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, account AccountExchange, size float64) {
+			defer wg.Done()
+			results[i], errs[i] = create(account.Exchange, size)
+		}(i, account, size)
+	}
+	wg.Wait()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	var firstErr error
+	var created int
+	for i, account := range m.accounts {
+		if errs[i] != nil {
+			m.status[i].failures++
+			m.status[i].lastError = errs[i]
+			m.logger.Warn("[MULTI-ACCOUNT] Order failed", "account", account.Name, "pair", pair,
+				"error", errs[i].Error())
+			if firstErr == nil {
+				firstErr = &AccountError{Account: account.Name, Err: errs[i]}
+			}
+			continue
+		}
+
+		if len(results[i]) > 0 {
+			m.status[i].orders++
+			created++
+		}
+	}
+
+	if created == 0 {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%w: %s", ErrInvalidQuantity, pair)
+		}
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+// register tracks the orders of each account as a single order per leg, e.g. two legs for OCO orders
+func (m *MultiAccount) register(results [][]model.Order) []model.Order {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	var legs int
+	for _, orders := range results {
+		if len(orders) > legs {
+			legs = len(orders)
+		}
+	}
+
+	orders := make([]model.Order, 0, legs)
+	var groupID *int64
+	for leg := 0; leg < legs; leg++ {
+		m.counter++
+		id := m.counter
+		for account, accountOrders := range results {
+			if leg < len(accountOrders) {
+				m.orders[id] = append(m.orders[id], accountOrder{account: account, order: accountOrders[leg]})
+			}
+		}
+
+		order := aggregateOrders(id, m.orders[id])
+		if legs > 1 {
+			if groupID == nil {
+				groupID = &id
+			}
+			order.GroupID = groupID
+		}
+		orders = append(orders, order)
+	}
+	return orders
+}
+
+func isFinalStatus(status model.OrderStatusType) bool {
+	switch status {
+	case model.OrderStatusTypeFilled, model.OrderStatusTypeCanceled, model.OrderStatusTypeRejected,
+		model.OrderStatusTypeExpired:
+		return true
+	}
+	return false
+}
+
+// aggregateOrders merges the orders of the accounts. The order is filled when all of them are finished and
+// at least one is filled, with the filled quantity. Otherwise, it is partially filled while some of them are.
+func aggregateOrders(id int64, orders []accountOrder) model.Order {
+	order := orders[0].order
+	order.ExchangeID = id
+	order.GroupID = nil
+
+	var quantity, filledQuantity, value, filledValue, fee, assetFee, slippage float64
+	finished, filled := true, 0
+	for _, accountOrder := range orders {
+		current := accountOrder.order
+		quantity += current.Quantity
+		value += current.Quantity * current.Price
+		fee += current.Fee
+		assetFee += current.AssetFee
+		slippage += current.Slippage
+
+		switch {
+		case current.Status == model.OrderStatusTypeFilled:
+			filled++
+			filledQuantity += current.Quantity
+			filledValue += current.Quantity * current.Price
+		case current.Status == model.OrderStatusTypePartiallyFilled:
+			filled++
+			finished = false
+		case !isFinalStatus(current.Status):
+			finished = false
+		}
+	}
+
+	order.Quantity, order.Fee, order.AssetFee, order.Slippage = quantity, fee, assetFee, slippage
+	switch {
+	case finished && filled > 0:
+		order.Status = model.OrderStatusTypeFilled
+		order.Quantity = filledQuantity
+		value = filledValue
+	case finished:
+		order.Status = orders[0].order.Status
+	case filled > 0:
+		order.Status = model.OrderStatusTypePartiallyFilled
+	default:
+		order.Status = model.OrderStatusTypeNew
+	}
+
+	if order.Quantity > 0 {
+		order.Price = value / order.Quantity
+	}
+	return order
+}
+
+// Order refreshes the orders of the accounts and returns the merged order
+func (m *MultiAccount) Order(pair string, id int64) (model.Order, error) {
+	m.mtx.Lock()
+	orders := append([]accountOrder(nil), m.orders[id]...)
+	m.mtx.Unlock()
+
+	if len(orders) == 0 {
+		return model.Order{}, fmt.Errorf("multi-account: order %d not found", id)
+	}
+
+	for i, order := range orders {
+		if isFinalStatus(order.order.Status) {
+			continue
+		}
+
+		account := m.accounts[order.account]
+		current, err := account.Exchange.Order(pair, order.order.ExchangeID)
+		if err != nil {
+			return model.Order{}, &AccountError{Account: account.Name, Err: err}
+		}
+		orders[i].order = current
+	}
+
+	m.mtx.Lock()
+	m.orders[id] = orders
+	m.mtx.Unlock()
+
+	return aggregateOrders(id, orders), nil
+}
+
+func (m *MultiAccount) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	results, err := m.execute(pair, side, size, true, func(exchange service.Exchange,
+		size float64) ([]model.Order, error) {
+		return exchange.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.register(results), nil
+}
+
+func (m *MultiAccount) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	return m.createOrder(pair, side, size, true, func(exchange service.Exchange,
+		size float64) (model.Order, error) {
+		return exchange.CreateOrderLimit(side, pair, size, limit)
+	})
+}
+
+func (m *MultiAccount) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return m.createOrder(pair, side, size, true, func(exchange service.Exchange,
+		size float64) (model.Order, error) {
+		return exchange.CreateOrderMarket(side, pair, size)
+	})
+}
+
+// CreateOrderMarketQuote splits the quote amount by the equity of each account, or by the value of its
+// position when the order reduces it
+func (m *MultiAccount) CreateOrderMarketQuote(side model.SideType, pair string,
+	quote float64) (model.Order, error) {
+	return m.createOrder(pair, side, quote, false, func(exchange service.Exchange,
+		quote float64) (model.Order, error) {
+		return exchange.CreateOrderMarketQuote(side, pair, quote)
+	})
+}
+
+func (m *MultiAccount) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	return m.createOrder(pair, model.SideTypeSell, quantity, true, func(exchange service.Exchange,
+		size float64) (model.Order, error) {
+		return exchange.CreateOrderStop(pair, size, limit)
+	})
+}
+
+func (m *MultiAccount) createOrder(pair string, side model.SideType, quantity float64, lotSize bool,
+	create func(exchange service.Exchange, quantity float64) (model.Order, error)) (model.Order, error) {
+
+	results, err := m.execute(pair, side, quantity, lotSize, func(exchange service.Exchange,
+		quantity float64) ([]model.Order, error) {
+		order, err := create(exchange, quantity)
+		if err != nil {
+			return nil, err
+		}
+		return []model.Order{order}, nil
+	})
+	if err != nil {
+		return model.Order{}, err
+	}
+	return m.register(results)[0], nil
+}
+
+// Cancel cancels the open orders of all accounts, the error is returned only when all of them failed
+func (m *MultiAccount) Cancel(order model.Order) error {
+	m.mtx.Lock()
+	orders := append([]accountOrder(nil), m.orders[order.ExchangeID]...)
+	m.mtx.Unlock()
+
+	if len(orders) == 0 {
+		return fmt.Errorf("multi-account: order %d not found", order.ExchangeID)
+	}
+
+	var firstErr error
+	var canceled int
+	for _, accountOrder := range orders {
+		if isFinalStatus(accountOrder.order.Status) {
+			continue
+		}
+
+		account := m.accounts[accountOrder.account]
+		if err := account.Exchange.Cancel(accountOrder.order); err != nil {
+			m.logger.Warn("[MULTI-ACCOUNT] Cancel failed", "account", account.Name, "pair", order.Pair,
+				"error", err.Error())
+			if firstErr == nil {
+				firstErr = &AccountError{Account: account.Name, Err: err}
+			}
+			continue
+		}
+		canceled++
+	}
+
+	if canceled == 0 {
+		return firstErr
+	}
+	return nil
+}
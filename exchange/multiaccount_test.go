@@ -0,0 +1,186 @@
+package exchange
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// quoteWallet is a paper wallet that quotes the close of the last candle, without data feed
+type quoteWallet struct {
+	*PaperWallet
+}
+
+func (q quoteWallet) LastQuote(_ context.Context, pair string) (float64, error) {
+	return q.lastCandle[pair].Close, nil
+}
+
+func TestMultiAccount(t *testing.T) {
+	ctx := context.Background()
+	newAccount := func(balance float64) quoteWallet {
+		wallet := NewPaperWallet(ctx, "USDT", WithPaperAsset("USDT", balance))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100, Low: 100})
+		return quoteWallet{wallet}
+	}
+	newMultiAccount := func(first, second service.Exchange) *MultiAccount {
+		multiAccount, err := NewMultiAccount(ctx,
+			AccountExchange{Name: "first", Exchange: first},
+			AccountExchange{Name: "second", Exchange: second})
+		require.NoError(t, err)
+		return multiAccount
+	}
+
+	t.Run("proportional sizing", func(t *testing.T) {
+		first, second := newAccount(3000), newAccount(1000)
+		multiAccount := newMultiAccount(first, second)
+
+		order, err := multiAccount.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 4)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 4.0, order.Quantity)
+		require.Equal(t, 100.0, order.Price)
+
+		asset, _, err := first.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 3.0, asset)
+		asset, _, err = second.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 1.0, asset)
+
+		// aggregated balances
+		account, err := multiAccount.Account()
+		require.NoError(t, err)
+		btc, usdt := account.Balance("BTC", "USDT")
+		require.Equal(t, 4.0, btc.Free)
+		require.Equal(t, 3600.0, usdt.Free)
+
+		asset, quote, err := multiAccount.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 4.0, asset)
+		require.Equal(t, 3600.0, quote)
+
+		// limit orders are merged until all accounts are filled
+		order, err = multiAccount.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 2, 110)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+		require.Equal(t, 2.0, order.Quantity)
+
+		first.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 105, High: 112, Low: 100})
+		order, err = multiAccount.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypePartiallyFilled, order.Status)
+
+		second.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 105, High: 112, Low: 100})
+		order, err = multiAccount.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 2.0, order.Quantity)
+		require.Equal(t, 110.0, order.Price)
+
+		reports, err := multiAccount.Report("BTCUSDT")
+		require.NoError(t, err)
+		require.Len(t, reports, 2)
+		require.Equal(t, "first", reports[0].Name)
+		require.Equal(t, 1.5, reports[0].Asset)
+		require.InDelta(t, 0.75, reports[0].Share, 1e-9)
+		require.Equal(t, 2, reports[0].Orders)
+		require.Equal(t, 0.5, reports[1].Asset)
+		require.InDelta(t, 0.25, reports[1].Share, 1e-9)
+		require.Equal(t, 2, reports[1].Orders)
+	})
+
+	t.Run("position sizing of reducing orders", func(t *testing.T) {
+		// the first account has most of the equity, but the second one holds most of the position
+		newHolder := func(balance, asset float64) quoteWallet {
+			wallet := NewPaperWallet(ctx, "USDT", WithPaperAsset("USDT", balance), WithPaperAsset("BTC", asset))
+			wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100, Low: 100})
+			return quoteWallet{wallet}
+		}
+		first, second := newHolder(2900, 1), newHolder(700, 3)
+		multiAccount := newMultiAccount(first, second)
+
+		reports, err := multiAccount.Report("BTCUSDT")
+		require.NoError(t, err)
+		require.InDelta(t, 0.75, reports[0].Share, 1e-9)
+
+		order, err := multiAccount.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 2)
+		require.NoError(t, err)
+		require.Equal(t, 2.0, order.Quantity)
+
+		asset, _, err := first.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.5, asset)
+		asset, _, err = second.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 1.5, asset)
+
+		// closing the position does not over-sell the account with the smaller position
+		order, err = multiAccount.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 2)
+		require.NoError(t, err)
+		require.Equal(t, 2.0, order.Quantity)
+
+		asset, _, err = first.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, asset)
+		asset, _, err = second.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, asset)
+	})
+
+	t.Run("independent failures", func(t *testing.T) {
+		first := newAccount(3000)
+		failing := NewFaultExchange(newAccount(1000), 1, WithFaultRejectRate(1))
+		multiAccount := newMultiAccount(first, failing)
+
+		order, err := multiAccount.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 4)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 3.0, order.Quantity)
+
+		reports, err := multiAccount.Report("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 1, reports[0].Orders)
+		require.Equal(t, 0, reports[0].Failures)
+		require.NoError(t, reports[0].LastError)
+		require.Equal(t, 0, reports[1].Orders)
+		require.Equal(t, 1, reports[1].Failures)
+		require.ErrorIs(t, reports[1].LastError, ErrInjectedFault)
+
+		// all accounts failed
+		multiAccount = newMultiAccount(NewFaultExchange(newAccount(3000), 1, WithFaultRejectRate(1)), failing)
+		_, err = multiAccount.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 4)
+		require.ErrorIs(t, err, ErrInjectedFault)
+		var accountErr *AccountError
+		require.ErrorAs(t, err, &accountErr)
+		require.Equal(t, "first", accountErr.Account)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		multiAccount := newMultiAccount(newAccount(1000), newAccount(1000))
+		order, err := multiAccount.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 2, 90)
+		require.NoError(t, err)
+
+		require.NoError(t, multiAccount.Cancel(order))
+		order, err = multiAccount.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeCanceled, order.Status)
+
+		asset, quote, err := multiAccount.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, asset)
+		require.Equal(t, 2000.0, quote)
+	})
+
+	t.Run("invalid accounts", func(t *testing.T) {
+		_, err := NewMultiAccount(ctx)
+		require.Error(t, err)
+
+		_, err = NewMultiAccount(ctx, AccountExchange{Name: "a", Exchange: newAccount(1)},
+			AccountExchange{Name: "a", Exchange: newAccount(1)})
+		require.EqualError(t, err, "multi-account: duplicated account a")
+	})
+}
@@ -9,8 +9,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/adshao/go-binance/v2/common"
-
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/tools/log"
@@ -19,6 +17,29 @@ import (
 type assetInfo struct {
 	Free float64
 	Lock float64
+
+	// exact balances, used with decimal accounting
+	decimal   bool
+	freeExact model.Decimal
+	lockExact model.Decimal
+}
+
+func (a *assetInfo) addFree(value float64) {
+	if !a.decimal {
+		a.Free += value
+		return
+	}
+	a.freeExact = a.freeExact.Add(model.NewDecimal(value))
+	a.Free = a.freeExact.Float64()
+}
+
+func (a *assetInfo) addLock(value float64) {
+	if !a.decimal {
+		a.Lock += value
+		return
+	}
+	a.lockExact = a.lockExact.Add(model.NewDecimal(value))
+	a.Lock = a.lockExact.Float64()
 }
 
 type AssetValue struct {
@@ -33,6 +54,11 @@ type PaperWallet struct {
 	counter       int64
 	takerFee      float64
 	makerFee      float64
+	feeTiers      []FeeTier
+	slippage      float64
+	tradeVolumes  []tradeVolume
+	fees          float64
+	feeVolume     float64
 	initialValue  float64
 	feeder        service.Feeder
 	orders        []model.Order
@@ -44,6 +70,11 @@ type PaperWallet struct {
 	fistCandle    map[string]model.Candle
 	assetValues   map[string][]AssetValue
 	equityValues  []AssetValue
+	equityAsset   string
+	assetEquity   []AssetValue
+	decimal       bool
+	fills         map[int64][]Fill
+	locks         map[int64]fundsLock
 }
 
 func (p *PaperWallet) AssetsInfo(pair string) model.AssetInfo {
@@ -71,6 +102,9 @@ func WithPaperAsset(pair string, amount float64) PaperWalletOption {
 	}
 }
 
+// WithPaperFee sets static maker and taker fees (e.g. 0.001 for 0.1%), charged in the quote asset. The fees
+// are deducted from the quote balance on every execution and reported in the Fee of the orders. In previous
+// versions this option was ignored, so backtests with it report lower profits than before.
 func WithPaperFee(maker, taker float64) PaperWalletOption {
 	return func(wallet *PaperWallet) {
 		wallet.makerFee = maker
@@ -78,6 +112,15 @@ func WithPaperFee(maker, taker float64) PaperWalletOption {
 	}
 }
 
+// WithPaperDecimalAccounting tracks balances with fixed-point decimals (see model.Decimal) instead of float64,
+// avoiding rounding drift after thousands of trades. The float64 API is kept, values are converted at the
+// boundary. It makes order execution noticeably slower, so it is disabled by default.
+func WithPaperDecimalAccounting() PaperWalletOption {
+	return func(wallet *PaperWallet) {
+		wallet.decimal = true
+	}
+}
+
 func WithDataFeed(feeder service.Feeder) PaperWalletOption {
 	return func(wallet *PaperWallet) {
 		wallet.feeder = feeder
@@ -97,12 +140,22 @@ func NewPaperWallet(ctx context.Context, baseCoin string, options ...PaperWallet
 		volume:        make(map[string]float64),
 		assetValues:   make(map[string][]AssetValue),
 		equityValues:  make([]AssetValue, 0),
+		fills:         make(map[int64][]Fill),
+		locks:         make(map[int64]fundsLock),
 	}
 
 	for _, option := range options {
 		option(&wallet)
 	}
 
+	if wallet.decimal {
+		for _, info := range wallet.assets {
+			info.decimal = true
+			info.freeExact = model.NewDecimal(info.Free)
+			info.lockExact = model.NewDecimal(info.Lock)
+		}
+	}
+
 	wallet.initialValue = wallet.assets[wallet.baseCoin].Free
 	log.Info("[SETUP] Using paper wallet")
 	log.Infof("[SETUP] Initial Portfolio = %f %s", wallet.initialValue, wallet.baseCoin)
@@ -110,6 +163,15 @@ func NewPaperWallet(ctx context.Context, baseCoin string, options ...PaperWallet
 	return &wallet
 }
 
+func (p *PaperWallet) asset(name string) *assetInfo {
+	if _, ok := p.assets[name]; !ok {
+		p.assets[name] = &assetInfo{
+			decimal: p.decimal,
+		}
+	}
+	return p.assets[name]
+}
+
 func (p *PaperWallet) ID() int64 {
 	p.counter++
 	return p.counter
@@ -127,6 +189,11 @@ func (p *PaperWallet) LastQuote(ctx context.Context, pair string) (float64, erro
 	return p.feeder.LastQuote(ctx, pair)
 }
 
+// OrderBook returns the order book of the data feed, it requires a feeder that implements service.DepthFeeder
+func (p *PaperWallet) OrderBook(ctx context.Context, pair string, limit int) (model.OrderBook, error) {
+	return OrderBook(ctx, p.feeder, pair, limit)
+}
+
 func (p *PaperWallet) AssetValues(pair string) []AssetValue {
 	return p.assetValues[pair]
 }
@@ -136,31 +203,37 @@ func (p *PaperWallet) EquityValues() []AssetValue {
 }
 
 func (p *PaperWallet) MaxDrawdown() (float64, time.Time, time.Time) {
-	if len(p.equityValues) < 1 {
+	return MaxDrawdown(p.equityValues)
+}
+
+// MaxDrawdown returns the largest decline of the values, as a fraction of the value before the decline, with
+// the start and end time of the decline
+func MaxDrawdown(values []AssetValue) (float64, time.Time, time.Time) {
+	if len(values) < 1 {
 		return 0, time.Time{}, time.Time{}
 	}
 
 	localMin := math.MaxFloat64
-	localMinBase := p.equityValues[0].Value
-	localMinStart := p.equityValues[0].Time
-	localMinEnd := p.equityValues[0].Time
+	localMinBase := values[0].Value
+	localMinStart := values[0].Time
+	localMinEnd := values[0].Time
 
 	globalMin := localMin
 	globalMinBase := localMinBase
 	globalMinStart := localMinStart
 	globalMinEnd := localMinEnd
 
-	for i := 1; i < len(p.equityValues); i++ {
-		diff := p.equityValues[i].Value - p.equityValues[i-1].Value
+	for i := 1; i < len(values); i++ {
+		diff := values[i].Value - values[i-1].Value
 
 		if localMin > 0 {
 			localMin = diff
-			localMinBase = p.equityValues[i-1].Value
-			localMinStart = p.equityValues[i-1].Time
-			localMinEnd = p.equityValues[i].Time
+			localMinBase = values[i-1].Value
+			localMinStart = values[i-1].Time
+			localMinEnd = values[i].Time
 		} else {
 			localMin += diff
-			localMinEnd = p.equityValues[i].Time
+			localMinEnd = values[i].Time
 		}
 
 		if localMin < globalMin {
@@ -215,24 +288,35 @@ func (p *PaperWallet) Summary() {
 	fmt.Println("------ RISK -------")
 	fmt.Printf("最大亏损 = %.2f %%\n", maxDrawDown*100)
 	fmt.Println()
+	if len(p.assetEquity) > 0 {
+		initial, final := p.assetEquity[0].Value, p.assetEquity[len(p.assetEquity)-1].Value
+		assetDrawDown, _, _ := MaxDrawdown(p.assetEquity)
+		fmt.Printf("----- RETURNS (%s) -----\n", p.equityAsset)
+		fmt.Printf("初始资金     = %.8f %s\n", initial, p.equityAsset)
+		fmt.Printf("最终资金     = %.8f %s\n", final, p.equityAsset)
+		fmt.Printf("毛利润        =  %f %s (%.2f%%)\n", final-initial, p.equityAsset, (final-initial)/initial*100)
+		fmt.Printf("最大亏损 = %.2f %%\n", assetDrawDown*100)
+		fmt.Println()
+	}
 	fmt.Println("------ VOLUME -----")
 	for pair, vol := range p.volume {
 		volume += vol
 		fmt.Printf("%s         = %.2f %s\n", pair, vol, p.baseCoin)
 	}
 	fmt.Printf("TOTAL           = %.2f %s\n", volume, p.baseCoin)
+	if p.feeEnabled() {
+		fmt.Println()
+		fmt.Println("------ FEES -------")
+		fmt.Printf("TOTAL           = %.2f %s\n", p.fees, p.baseCoin)
+		fmt.Printf("AVERAGE RATE    = %.4f %%\n", p.AverageFeeRate()*100)
+	}
 	fmt.Println("-------------------")
 }
 
 func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, value float64, fill bool) error {
 	asset, quote := SplitAssetQuote(pair)
-	if _, ok := p.assets[asset]; !ok {
-		p.assets[asset] = &assetInfo{}
-	}
-
-	if _, ok := p.assets[quote]; !ok {
-		p.assets[quote] = &assetInfo{}
-	}
+	p.asset(asset)
+	p.asset(quote)
 
 	funds := p.assets[quote].Free
 	if side == model.SideTypeSell {
@@ -251,19 +335,20 @@ func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, va
 		lockedAsset := math.Min(math.Max(p.assets[asset].Free, 0), amount) // ignore negative asset amount to lock
 		lockedQuote := (amount - lockedAsset) * value
 
-		p.assets[asset].Free -= lockedAsset
-		p.assets[quote].Free -= lockedQuote
+		p.assets[asset].addFree(-lockedAsset)
+		p.assets[quote].addFree(-lockedQuote)
 		if fill {
 			p.updateAveragePrice(side, pair, amount, value)
-			if lockedQuote > 0 { // entering in short position
-				p.assets[asset].Free -= amount
+			if lockedQuote > 0 { // entering in short position, after liquidating the long position (flip)
+				p.assets[asset].addFree(-(amount - lockedAsset))
+				p.assets[quote].addFree(lockedAsset * value)
 			} else { // liquidating long position
-				p.assets[quote].Free += amount * value
+				p.assets[quote].addFree(amount * value)
 
 			}
 		} else {
-			p.assets[asset].Lock += lockedAsset
-			p.assets[quote].Lock += lockedQuote
+			p.assets[asset].addLock(lockedAsset)
+			p.assets[quote].addLock(lockedQuote)
 		}
 
 		log.Debugf("%s -> LOCK = %f / FREE %f", asset, p.assets[asset].Lock, p.assets[asset].Free)
@@ -291,15 +376,15 @@ func (p *PaperWallet) validateFunds(side model.SideType, pair string, amount, va
 		lockedAsset := math.Min(-math.Min(p.assets[asset].Free, 0), amount) // ignore positive amount to lock
 		lockedQuote := (amount-lockedAsset)*value - liquidShortValue
 
-		p.assets[asset].Free += lockedAsset
-		p.assets[quote].Free -= lockedQuote
+		p.assets[asset].addFree(lockedAsset)
+		p.assets[quote].addFree(-lockedQuote)
 
 		if fill {
 			p.updateAveragePrice(side, pair, amount, value)
-			p.assets[asset].Free += amount - lockedAsset
+			p.assets[asset].addFree(amount - lockedAsset)
 		} else {
-			p.assets[asset].Lock += lockedAsset
-			p.assets[quote].Lock += lockedQuote
+			p.assets[asset].addLock(lockedAsset)
+			p.assets[quote].addLock(lockedQuote)
 		}
 		log.Debugf("%s -> LOCK = %f / FREE %f", asset, p.assets[asset].Lock, p.assets[asset].Free)
 	}
@@ -379,6 +464,11 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 	}
 
 	for i, order := range p.orders {
+		if order.Pair == candle.Pair && order.Type == model.OrderTypeIceberg {
+			p.fillIceberg(i, candle)
+			continue
+		}
+
 		if order.Pair != candle.Pair || order.Status != model.OrderStatusTypeNew {
 			continue
 		}
@@ -387,30 +477,22 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 			p.volume[candle.Pair] = 0
 		}
 
-		asset, quote := SplitAssetQuote(order.Pair)
 		if order.Side == model.SideTypeBuy && order.Price >= candle.Close {
-			if _, ok := p.assets[asset]; !ok {
-				p.assets[asset] = &assetInfo{}
-			}
-
-			p.volume[candle.Pair] += order.Price * order.Quantity
-			p.orders[i].UpdatedAt = candle.Time
-			p.orders[i].Status = model.OrderStatusTypeFilled
-
-			// update assets size
-			p.updateAveragePrice(order.Side, order.Pair, order.Quantity, order.Price)
-			p.assets[asset].Free = p.assets[asset].Free + order.Quantity
-			p.assets[quote].Lock = p.assets[quote].Lock - order.Price*order.Quantity
+			p.fillOpenOrder(i, order.Price, true, candle.Time)
 		}
 
 		if order.Side == model.SideTypeSell {
-			var orderPrice float64
+			var (
+				orderPrice float64
+				maker      bool
+			)
 			if (order.Type == model.OrderTypeLimit ||
 				order.Type == model.OrderTypeLimitMaker ||
 				order.Type == model.OrderTypeTakeProfit ||
 				order.Type == model.OrderTypeTakeProfitLimit) &&
 				candle.High >= order.Price {
 				orderPrice = order.Price
+				maker = true
 			} else if (order.Type == model.OrderTypeStopLossLimit ||
 				order.Type == model.OrderTypeStopLoss) &&
 				candle.Low <= *order.Stop {
@@ -431,20 +513,7 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 				}
 			}
 
-			if _, ok := p.assets[quote]; !ok {
-				p.assets[quote] = &assetInfo{}
-			}
-
-			orderVolume := order.Quantity * orderPrice
-
-			p.volume[candle.Pair] += orderVolume
-			p.orders[i].UpdatedAt = candle.Time
-			p.orders[i].Status = model.OrderStatusTypeFilled
-
-			// update assets size
-			p.updateAveragePrice(order.Side, order.Pair, order.Quantity, orderPrice)
-			p.assets[asset].Lock = p.assets[asset].Lock - order.Quantity
-			p.assets[quote].Free = p.assets[quote].Free + order.Quantity*orderPrice
+			p.fillOpenOrder(i, orderPrice, maker, candle.Time)
 		}
 	}
 
@@ -461,20 +530,86 @@ func (p *PaperWallet) OnCandle(candle model.Candle) {
 				total += amount * p.lastCandle[pair].Close
 			}
 
-			p.assetValues[asset] = append(p.assetValues[asset], AssetValue{
+			p.assetValues[asset] = appendValue(p.assetValues[asset], AssetValue{
 				Time:  candle.Time,
 				Value: amount * p.lastCandle[pair].Close,
 			})
 		}
 
 		baseCoinInfo := p.assets[p.baseCoin]
-		p.equityValues = append(p.equityValues, AssetValue{
+		equity := total + baseCoinInfo.Lock + baseCoinInfo.Free
+		p.equityValues = appendValue(p.equityValues, AssetValue{
 			Time:  candle.Time,
-			Value: total + baseCoinInfo.Lock + baseCoinInfo.Free,
+			Value: equity,
 		})
+		p.updateAssetEquity(candle.Time, equity)
 	}
 }
 
+// fillOpenOrder fills the open order at the index of the orders at the price. Reduce-only orders are capped to
+// the position at the execution, it may have been reduced by other orders after the creation, and they expire
+// without position. The funds locked by the quantity not filled are released.
+func (p *PaperWallet) fillOpenOrder(i int, price float64, maker bool, t time.Time) {
+	order := p.orders[i]
+	quantity := order.Quantity
+	if order.ReduceOnly {
+		asset, _ := SplitAssetQuote(order.Pair)
+		position := p.asset(asset).Free + p.asset(asset).Lock
+		if order.Side == model.SideTypeBuy {
+			position = -position
+		}
+		quantity = math.Max(0, math.Min(quantity, position))
+		if quantity < order.Quantity {
+			p.unlockOrder(order, order.Quantity-quantity)
+		}
+	}
+
+	p.orders[i].UpdatedAt = t
+	if quantity == 0 {
+		p.orders[i].Status = model.OrderStatusTypeExpired
+	} else {
+		p.orders[i].Status = model.OrderStatusTypeFilled
+		p.orders[i].Quantity = quantity
+		p.orders[i].Fee = p.fillOrder(order, quantity, price, maker, t)
+	}
+	delete(p.locks, lockKey(order))
+}
+
+// fillOrder executes the quantity of an open order at the price, consuming its share of the funds locked in the
+// creation, and returns the fee. The locked asset of a sell is sold, and the remaining quantity opens a short
+// position backed by the locked quote. The locked asset of a buy covers the short position, and the remaining
+// quantity is bought with the locked quote. The result is the same of a market order at the price.
+func (p *PaperWallet) fillOrder(order model.Order, quantity, price float64, maker bool, t time.Time) float64 {
+	asset, quote := SplitAssetQuote(order.Pair)
+	p.asset(asset)
+	p.asset(quote)
+
+	lock := p.orderLock(order, quantity)
+	p.updateAveragePrice(order.Side, order.Pair, quantity, price)
+	p.assets[asset].addLock(-lock.asset)
+	p.assets[quote].addLock(-lock.quote)
+	if order.Side == model.SideTypeBuy {
+		p.assets[asset].addFree(quantity - lock.asset)
+	} else {
+		p.assets[asset].addFree(-(quantity - lock.asset))
+		p.assets[quote].addFree(lock.asset * price)
+	}
+
+	p.volume[order.Pair] += quantity * price
+	return p.chargeFee(order.Pair, quantity*price, maker, t)
+}
+
+// appendValue appends the value to the series, or replaces the last value of the same time. The candles of
+// the pairs of a portfolio at the same time are processed in sequence, the last one values all the assets with
+// the prices of that time, so the series has a single point per time.
+func appendValue(values []AssetValue, value AssetValue) []AssetValue {
+	if last := len(values) - 1; last >= 0 && values[last].Time.Equal(value.Time) {
+		values[last] = value
+		return values
+	}
+	return append(values, value)
+}
+
 func (p *PaperWallet) Account() (model.Account, error) {
 	balances := make([]model.Balance, 0)
 	for pair, info := range p.assets {
@@ -514,12 +649,13 @@ func (p *PaperWallet) CreateOrderOCO(side model.SideType, pair string,
 		return nil, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, price, false)
+	lock, err := p.lockFunds(side, pair, size, price)
 	if err != nil {
 		return nil, err
 	}
 
 	groupID := p.ID()
+	p.locks[groupID] = lock
 	limitMaker := model.Order{
 		ExchangeID: p.ID(),
 		CreatedAt:  p.lastCandle[pair].Time,
@@ -559,11 +695,17 @@ func (p *PaperWallet) CreateOrderLimit(side model.SideType, pair string,
 	p.Lock()
 	defer p.Unlock()
 
+	return p.createOrderLimit(side, pair, size, limit, false)
+}
+
+func (p *PaperWallet) createOrderLimit(side model.SideType, pair string, size float64, limit float64,
+	reduceOnly bool) (model.Order, error) {
+
 	if size == 0 {
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, limit, false)
+	lock, err := p.lockFunds(side, pair, size, limit)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -577,8 +719,10 @@ func (p *PaperWallet) CreateOrderLimit(side model.SideType, pair string,
 		Status:     model.OrderStatusTypeNew,
 		Price:      limit,
 		Quantity:   size,
+		ReduceOnly: reduceOnly,
 	}
 	p.orders = append(p.orders, order)
+	p.locks[order.ExchangeID] = lock
 	return order, nil
 }
 
@@ -586,7 +730,52 @@ func (p *PaperWallet) CreateOrderMarket(side model.SideType, pair string, size f
 	p.Lock()
 	defer p.Unlock()
 
-	return p.createOrderMarket(side, pair, size)
+	return p.createOrderMarket(side, pair, size, false)
+}
+
+// CreateOrderMarketReduceOnly creates a market order capped to the current position size
+func (p *PaperWallet) CreateOrderMarketReduceOnly(side model.SideType, pair string,
+	size float64) (model.Order, error) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	size, err := p.reduceOnlySize(side, pair, size)
+	if err != nil {
+		return model.Order{}, err
+	}
+	return p.createOrderMarket(side, pair, size, true)
+}
+
+// CreateOrderLimitReduceOnly creates a limit order capped to the current position size
+func (p *PaperWallet) CreateOrderLimitReduceOnly(side model.SideType, pair string,
+	size float64, limit float64) (model.Order, error) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	size, err := p.reduceOnlySize(side, pair, size)
+	if err != nil {
+		return model.Order{}, err
+	}
+	return p.createOrderLimit(side, pair, size, limit, true)
+}
+
+// reduceOnlySize caps the order size to the free position, assets locked by other orders are not considered.
+// Orders in the same direction of the position, or without position, are rejected.
+func (p *PaperWallet) reduceOnlySize(side model.SideType, pair string, size float64) (float64, error) {
+	asset, _ := SplitAssetQuote(pair)
+	position := p.asset(asset).Free
+
+	if (side == model.SideTypeSell && position <= 0) || (side == model.SideTypeBuy && position >= 0) {
+		return 0, &OrderError{
+			Err:      ErrReduceOnly,
+			Pair:     pair,
+			Quantity: size,
+		}
+	}
+
+	return math.Min(size, math.Abs(position)), nil
 }
 
 func (p *PaperWallet) CreateOrderStop(pair string, size float64, limit float64) (model.Order, error) {
@@ -597,7 +786,7 @@ func (p *PaperWallet) CreateOrderStop(pair string, size float64, limit float64)
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(model.SideTypeSell, pair, size, limit, false)
+	lock, err := p.lockFunds(model.SideTypeSell, pair, size, limit)
 	if err != nil {
 		return model.Order{}, err
 	}
@@ -615,24 +804,40 @@ func (p *PaperWallet) CreateOrderStop(pair string, size float64, limit float64)
 		Quantity:   size,
 	}
 	p.orders = append(p.orders, order)
+	p.locks[order.ExchangeID] = lock
 	return order, nil
 }
 
-func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size float64,
+	reduceOnly bool) (model.Order, error) {
 	if size == 0 {
 		return model.Order{}, ErrInvalidQuantity
 	}
 
-	err := p.validateFunds(side, pair, size, p.lastCandle[pair].Close, true)
+	price := p.marketPrice(side, pair)
+	restore := p.checkpoint(pair)
+	err := p.validateFunds(side, pair, size, price, true)
 	if err != nil {
 		return model.Order{}, err
 	}
 
+	// the fee is charged in the quote after the execution, orders that can't pay it are rejected
+	_, quote := SplitAssetQuote(pair)
+	if fee := p.estimateFee(price*size, false, p.lastCandle[pair].Time); fee > p.assets[quote].Free {
+		restore()
+		return model.Order{}, &OrderError{
+			Err:      ErrInsufficientFunds,
+			Pair:     pair,
+			Quantity: size,
+		}
+	}
+
 	if _, ok := p.volume[pair]; !ok {
 		p.volume[pair] = 0
 	}
 
-	p.volume[pair] += p.lastCandle[pair].Close * size
+	p.volume[pair] += price * size
+	fee := p.chargeFee(pair, price*size, false, p.lastCandle[pair].Time)
 
 	order := model.Order{
 		ExchangeID: p.ID(),
@@ -642,8 +847,11 @@ func (p *PaperWallet) createOrderMarket(side model.SideType, pair string, size f
 		Side:       side,
 		Type:       model.OrderTypeMarket,
 		Status:     model.OrderStatusTypeFilled,
-		Price:      p.lastCandle[pair].Close,
+		Price:      price,
 		Quantity:   size,
+		ReduceOnly: reduceOnly,
+		Fee:        fee,
+		Slippage:   math.Abs(price-p.lastCandle[pair].Close) * size,
 	}
 
 	p.orders = append(p.orders, order)
@@ -656,9 +864,73 @@ func (p *PaperWallet) CreateOrderMarketQuote(side model.SideType, pair string,
 	p.Lock()
 	defer p.Unlock()
 
-	info := p.AssetsInfo(pair)
-	quantity := common.AmountToLotSize(info.StepSize, info.BaseAssetPrecision, quoteQuantity/p.lastCandle[pair].Close)
-	return p.createOrderMarket(side, pair, quantity)
+	quantity := p.AssetsInfo(pair).RoundQuantity(quoteQuantity / p.lastCandle[pair].Close)
+	return p.createOrderMarket(side, pair, quantity, false)
+}
+
+// SimulateOrder estimates the execution of an order with the fees, slippage and balances of the wallet,
+// without submitting it, see service.OrderSimulator. The resulting balances consider a spot execution, where
+// a buy pays the order value and the fee with the quote asset. Orders without funds return ErrInsufficientFunds,
+// and market orders of a pair without candles return ErrPriceNotAvailable.
+func (p *PaperWallet) SimulateOrder(pair string, side model.SideType, quantity,
+	price float64) (model.OrderPreview, error) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	if quantity <= 0 {
+		return model.OrderPreview{}, ErrInvalidQuantity
+	}
+
+	preview := model.OrderPreview{
+		Pair:     pair,
+		Side:     side,
+		Type:     model.OrderTypeLimit,
+		Quantity: quantity,
+		Price:    price,
+	}
+
+	if price == 0 {
+		if p.lastCandle[pair].Close == 0 {
+			return model.OrderPreview{}, fmt.Errorf("%w: %s", ErrPriceNotAvailable, pair)
+		}
+		preview.Type = model.OrderTypeMarket
+		preview.Price = p.marketPrice(side, pair)
+		preview.Slippage = math.Abs(preview.Price-p.lastCandle[pair].Close) * quantity
+	}
+	preview.Value = preview.Price * quantity
+
+	if p.feeEnabled() {
+		makerFee, takerFee := p.FeeRate(p.lastCandle[pair].Time)
+		if preview.Type == model.OrderTypeMarket {
+			preview.Fee = preview.Value * takerFee
+		} else {
+			preview.Fee = preview.Value * makerFee
+		}
+	}
+
+	// check the funds with a copy of the balances, so the wallet is not changed
+	asset, quote := SplitAssetQuote(pair)
+	assetBalance, quoteBalance := p.asset(asset), p.asset(quote)
+	assetCopy, quoteCopy := *assetBalance, *quoteBalance
+	p.assets[asset], p.assets[quote] = &assetCopy, &quoteCopy
+	err := p.validateFunds(side, pair, quantity, preview.Price, false)
+	p.assets[asset], p.assets[quote] = assetBalance, quoteBalance
+	if err != nil {
+		return model.OrderPreview{}, err
+	}
+
+	preview.AssetBalance = assetBalance.Free + assetBalance.Lock
+	preview.QuoteBalance = quoteBalance.Free + quoteBalance.Lock - preview.Fee
+	if side == model.SideTypeBuy {
+		preview.AssetBalance += quantity
+		preview.QuoteBalance -= preview.Value
+	} else {
+		preview.AssetBalance -= quantity
+		preview.QuoteBalance += preview.Value
+	}
+
+	return preview, nil
 }
 
 func (p *PaperWallet) Cancel(order model.Order) error {
@@ -669,25 +941,161 @@ func (p *PaperWallet) Cancel(order model.Order) error {
 		if o.ExchangeID == order.ExchangeID {
 			p.orders[i].Status = model.OrderStatusTypeCanceled
 
-			// unlock funds
-			assset, quote := SplitAssetQuote(o.Pair)
-			// we have open long position
-			if p.assets[assset].Lock > 0 && o.Side == model.SideTypeSell {
-				p.assets[assset].Free += o.Quantity
-				p.assets[assset].Lock -= o.Quantity
-			} else {
-				// we don't have open long position
-				if p.assets[assset].Lock == 0 {
-					amount := order.Price * order.Quantity
-					p.assets[quote].Free += amount
-					p.assets[quote].Lock -= amount
-				}
-			}
+			// unlock funds, except the slices filled of iceberg orders
+			p.unlockOrder(o, o.Quantity-p.filledQuantity(o.ExchangeID))
+			delete(p.locks, lockKey(o))
 		}
 	}
 	return nil
 }
 
+// AmendOrder changes the price and quantity of an open limit order, see service.OrderAmender. The funds locked
+// by the order are replaced by the funds of the new price and quantity, and the order is kept when the
+// funds are insufficient.
+func (p *PaperWallet) AmendOrder(order model.Order, price, quantity float64) (model.Order, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	for i, o := range p.orders {
+		if o.ExchangeID != order.ExchangeID {
+			continue
+		}
+
+		if o.Type != model.OrderTypeLimit || o.Status != model.OrderStatusTypeNew {
+			return model.Order{}, ErrOrderNotAmendable
+		}
+
+		if quantity <= 0 {
+			return model.Order{}, ErrInvalidQuantity
+		}
+
+		p.unlockOrder(o, o.Quantity)
+		lock, err := p.lockFunds(o.Side, o.Pair, quantity, price)
+		if err != nil {
+			// lock the funds of the original order again
+			lock, _ = p.lockFunds(o.Side, o.Pair, o.Quantity, o.Price)
+			p.locks[lockKey(o)] = lock
+			return model.Order{}, err
+		}
+		p.locks[lockKey(o)] = lock
+
+		p.orders[i].Price = price
+		p.orders[i].Quantity = quantity
+		p.orders[i].UpdatedAt = p.lastCandle[o.Pair].Time
+		return p.orders[i], nil
+	}
+	return model.Order{}, errors.New("order not found")
+}
+
+// fundsLock is the amount of the asset and the quote locked by an open order
+type fundsLock struct {
+	asset float64
+	quote float64
+}
+
+// lockFunds validates and locks the funds of an order, returning the amounts locked
+func (p *PaperWallet) lockFunds(side model.SideType, pair string, amount, value float64) (fundsLock, error) {
+	asset, quote := SplitAssetQuote(pair)
+	assetLock, quoteLock := p.asset(asset).Lock, p.asset(quote).Lock
+	if err := p.validateFunds(side, pair, amount, value, false); err != nil {
+		return fundsLock{}, err
+	}
+
+	return fundsLock{
+		asset: p.assets[asset].Lock - assetLock,
+		quote: p.assets[quote].Lock - quoteLock,
+	}, nil
+}
+
+// checkpoint returns a function that restores the balances and the average prices of the pair, to undo an
+// execution
+func (p *PaperWallet) checkpoint(pair string) func() {
+	asset, quote := SplitAssetQuote(pair)
+	assetBalance, quoteBalance := *p.asset(asset), *p.asset(quote)
+	avgLong, longOk := p.avgLongPrice[pair]
+	avgShort, shortOk := p.avgShortPrice[pair]
+
+	return func() {
+		*p.assets[asset], *p.assets[quote] = assetBalance, quoteBalance
+		delete(p.avgLongPrice, pair)
+		delete(p.avgShortPrice, pair)
+		if longOk {
+			p.avgLongPrice[pair] = avgLong
+		}
+		if shortOk {
+			p.avgShortPrice[pair] = avgShort
+		}
+	}
+}
+
+// lockKey returns the key of the funds locked by the order, the orders of an OCO group share the same funds
+func lockKey(order model.Order) int64 {
+	if order.GroupID != nil {
+		return *order.GroupID
+	}
+	return order.ExchangeID
+}
+
+// orderLock returns the share of the funds locked by the order that corresponds to the quantity
+func (p *PaperWallet) orderLock(order model.Order, quantity float64) fundsLock {
+	lock, ok := p.locks[lockKey(order)]
+	if !ok || order.Quantity == 0 {
+		return fundsLock{}
+	}
+
+	share := quantity / order.Quantity
+	return fundsLock{asset: lock.asset * share, quote: lock.quote * share}
+}
+
+// unlockOrder releases the funds locked by the quantity of an open order
+func (p *PaperWallet) unlockOrder(order model.Order, quantity float64) {
+	asset, quote := SplitAssetQuote(order.Pair)
+	lock := p.orderLock(order, quantity)
+	p.asset(asset).addLock(-lock.asset)
+	p.asset(quote).addLock(-lock.quote)
+	p.assets[quote].addFree(lock.quote)
+
+	// the asset of a buy is locked from the short position
+	if order.Side == model.SideTypeBuy {
+		p.assets[asset].addFree(-lock.asset)
+	} else {
+		p.assets[asset].addFree(lock.asset)
+	}
+}
+
+// OpenOrders returns the orders of the pair waiting for execution, see service.OpenOrdersBroker
+func (p *PaperWallet) OpenOrders(pair string) ([]model.Order, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	orders := make([]model.Order, 0)
+	for _, order := range p.orders {
+		if order.Pair == pair && (order.Status == model.OrderStatusTypeNew ||
+			order.Status == model.OrderStatusTypePartiallyFilled) {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
+}
+
+// Orders returns the last orders of the pair, limited to the given number of orders, see
+// service.OrderHistoryBroker
+func (p *PaperWallet) Orders(pair string, limit int) ([]model.Order, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	orders := make([]model.Order, 0)
+	for _, order := range p.orders {
+		if order.Pair == pair {
+			orders = append(orders, order)
+		}
+	}
+	if len(orders) > limit {
+		orders = orders[len(orders)-limit:]
+	}
+	return orders, nil
+}
+
 func (p *PaperWallet) Order(_ string, id int64) (model.Order, error) {
 	for _, order := range p.orders {
 		if order.ExchangeID == id {
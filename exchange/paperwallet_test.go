@@ -2,6 +2,7 @@ package exchange
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -57,11 +58,12 @@ func TestPaperWallet_ValidateFunds(t *testing.T) {
 		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", 1), WithPaperAsset("USDT", 100))
 		wallet.avgLongPrice["BTCUSDT"] = 100
 
+		// sells the long of 1 BTC and opens a short of 1 BTC, locking 100 USDT
 		err := wallet.validateFunds(model.SideTypeSell, "BTCUSDT", 2, 100, true)
 		require.NoError(t, err)
-		require.Equal(t, 0.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 100.0, wallet.assets["USDT"].Free)
 		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
-		require.Equal(t, -2.0, wallet.assets["BTC"].Free)
+		require.Equal(t, -1.0, wallet.assets["BTC"].Free)
 		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
 	})
 
@@ -237,6 +239,61 @@ func TestPaperWallet_OrderLimit(t *testing.T) {
 	})
 }
 
+func TestPaperWallet_AmendOrder(t *testing.T) {
+	t.Run("buy", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 200))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		order, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+		require.NoError(t, err)
+
+		order, err = wallet.AmendOrder(order, 95, 2)
+		require.NoError(t, err)
+		require.Len(t, wallet.orders, 1)
+		require.Equal(t, 95.0, order.Price)
+		require.Equal(t, 2.0, order.Quantity)
+		require.Equal(t, 10.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 190.0, wallet.assets["USDT"].Lock)
+
+		// insufficient funds, the order is kept
+		_, err = wallet.AmendOrder(order, 95, 3)
+		require.ErrorIs(t, err, ErrInsufficientFunds)
+		require.Equal(t, 2.0, wallet.orders[0].Quantity)
+		require.Equal(t, 10.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 190.0, wallet.assets["USDT"].Lock)
+
+		// filled at the new price
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 97})
+		require.Equal(t, model.OrderStatusTypeNew, wallet.orders[0].Status)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 95})
+		require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[0].Status)
+		require.Equal(t, 2.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 10.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+
+		_, err = wallet.AmendOrder(order, 90, 2)
+		require.ErrorIs(t, err, ErrOrderNotAmendable)
+	})
+
+	t.Run("sell", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", 1),
+			WithPaperAsset("USDT", 0))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100})
+		order, err := wallet.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 110)
+		require.NoError(t, err)
+
+		_, err = wallet.AmendOrder(order, 105, 0.5)
+		require.NoError(t, err)
+		require.Equal(t, 0.5, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.5, wallet.assets["BTC"].Lock)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 104, High: 106})
+		require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[0].Status)
+		require.Equal(t, 52.5, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.5, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+	})
+}
+
 func TestPaperWallet_OrderMarket(t *testing.T) {
 	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 100))
 	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 50})
@@ -404,6 +461,50 @@ func TestPaperWallet_MaxDrawndown(t *testing.T) {
 	}
 }
 
+func TestPaperWallet_EquityAsset(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := NewPaperWallet(context.Background(), "BTC", WithPaperAsset("BTC", 1), WithPaperEquityAsset("eth"))
+
+	// buy 16 ETH with 1 BTC
+	wallet.OnCandle(model.Candle{Pair: "ETHBTC", Time: start, Close: 0.0625, Complete: true})
+	_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "ETHBTC", 16)
+	require.NoError(t, err)
+
+	// sell 16 ETH for 2 BTC
+	wallet.OnCandle(model.Candle{Pair: "ETHBTC", Time: start.Add(time.Hour), Close: 0.125, Complete: true})
+	_, err = wallet.CreateOrderMarket(model.SideTypeSell, "ETHBTC", 16)
+	require.NoError(t, err)
+
+	// the asset keeps going up after the exit
+	wallet.OnCandle(model.Candle{Pair: "ETHBTC", Time: start.Add(2 * time.Hour), Close: 0.25, Complete: true})
+
+	// profit of 100% in BTC
+	require.Equal(t, []AssetValue{
+		{Time: start, Value: 1},
+		{Time: start.Add(time.Hour), Value: 2},
+		{Time: start.Add(2 * time.Hour), Value: 2},
+	}, wallet.EquityValues())
+
+	// loss of 50% in ETH
+	require.Equal(t, []AssetValue{
+		{Time: start, Value: 16},
+		{Time: start.Add(time.Hour), Value: 16},
+		{Time: start.Add(2 * time.Hour), Value: 8},
+	}, wallet.EquityAssetValues())
+
+	drawdown, _, _ := wallet.MaxDrawdown()
+	require.Equal(t, 0.0, drawdown)
+	drawdown, _, _ = wallet.EquityAssetMaxDrawdown()
+	require.Equal(t, -0.5, drawdown)
+
+	t.Run("disabled by default", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "BTC", WithPaperAsset("BTC", 1))
+		wallet.OnCandle(model.Candle{Pair: "ETHBTC", Time: start, Close: 0.0625, Complete: true})
+		require.Len(t, wallet.EquityValues(), 1)
+		require.Empty(t, wallet.EquityAssetValues())
+	})
+}
+
 func TestPaperWallet_AssetsInfo(t *testing.T) {
 	wallet := PaperWallet{}
 	info := wallet.AssetsInfo("BTCUSDT")
@@ -441,6 +542,66 @@ func TestPaperWallet_CreateOrderStop(t *testing.T) {
 	})
 }
 
+func TestPaperWallet_FlipPosition(t *testing.T) {
+	t.Run("limit order", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", 1),
+			WithPaperAsset("USDT", 200))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100, Low: 100})
+
+		// sell the long position of 1 BTC and open a short of 1 BTC
+		_, err := wallet.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 2, 110)
+		require.NoError(t, err)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 1.0, wallet.assets["BTC"].Lock)
+		require.Equal(t, 90.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 110.0, wallet.assets["USDT"].Lock)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 105, High: 115, Low: 100})
+		require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[0].Status)
+		require.Equal(t, -1.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+		require.Equal(t, 200.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+		require.Equal(t, 110.0, wallet.avgShortPrice["BTCUSDT"])
+	})
+
+	t.Run("stop order", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", 1),
+			WithPaperAsset("USDT", 200))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100, Low: 100})
+
+		// stop the long position of 1 BTC and open a short of 1 BTC
+		_, err := wallet.CreateOrderStop("BTCUSDT", 2, 90)
+		require.NoError(t, err)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 1.0, wallet.assets["BTC"].Lock)
+		require.Equal(t, 110.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 90.0, wallet.assets["USDT"].Lock)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 85, High: 95, Low: 80})
+		require.Equal(t, model.OrderStatusTypeFilled, wallet.orders[0].Status)
+		require.Equal(t, -1.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+		require.Equal(t, 200.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+		require.Equal(t, 90.0, wallet.avgShortPrice["BTCUSDT"])
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("BTC", 1),
+			WithPaperAsset("USDT", 200))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100, Low: 100})
+
+		order, err := wallet.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 2, 110)
+		require.NoError(t, err)
+		require.NoError(t, wallet.Cancel(order))
+		require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+		require.Equal(t, 200.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	})
+}
+
 func TestUpdateAveragePrice(t *testing.T) {
 	t.Run("long", func(t *testing.T) {
 		wallet := NewPaperWallet(
@@ -598,3 +759,234 @@ func TestUpdateAveragePrice(t *testing.T) {
 	})
 
 }
+
+func TestPaperWallet_DecimalAccounting(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+		WithPaperDecimalAccounting())
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 0.1})
+
+	// float64 accumulation ends with 999.6999999999616 USDT and 3.0000000000000027 BTC
+	for i := 0; i < 1000; i++ {
+		_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.003)
+		require.NoError(t, err)
+	}
+
+	expectedQuote := model.NewDecimal(1000)
+	expectedAsset := model.NewDecimal(0)
+	for i := 0; i < 1000; i++ {
+		expectedQuote = expectedQuote.Sub(model.NewDecimal(0.0003))
+		expectedAsset = expectedAsset.Add(model.NewDecimal(0.003))
+	}
+
+	require.Equal(t, "999.70000000", expectedQuote.String())
+	require.Equal(t, expectedQuote.Float64(), wallet.assets["USDT"].Free)
+	require.Equal(t, expectedAsset.Float64(), wallet.assets["BTC"].Free)
+
+	// sell everything back in small chunks
+	for i := 0; i < 1000; i++ {
+		_, err := wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.003)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, 1000.0, wallet.assets["USDT"].Free)
+	require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+}
+
+func TestPaperWallet_ReduceOnly(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+	_, err := wallet.CreateOrderMarketReduceOnly(model.SideTypeSell, "BTCUSDT", 1)
+	require.Equal(t, &OrderError{
+		Err:      ErrReduceOnly,
+		Pair:     "BTCUSDT",
+		Quantity: 1,
+	}, err)
+
+	_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+
+	// buy increases the long position
+	_, err = wallet.CreateOrderMarketReduceOnly(model.SideTypeBuy, "BTCUSDT", 1)
+	require.Equal(t, &OrderError{
+		Err:      ErrReduceOnly,
+		Pair:     "BTCUSDT",
+		Quantity: 1,
+	}, err)
+
+	// sell larger than the position is capped, without opening a short position
+	order, err := wallet.CreateOrderMarketReduceOnly(model.SideTypeSell, "BTCUSDT", 5)
+	require.NoError(t, err)
+	require.True(t, order.ReduceOnly)
+	require.Equal(t, 2.0, order.Quantity)
+	require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+	require.Equal(t, 1000.0, wallet.assets["USDT"].Free)
+
+	t.Run("limit", func(t *testing.T) {
+		_, err := wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		order, err := wallet.CreateOrderLimitReduceOnly(model.SideTypeBuy, "BTCUSDT", 3, 90)
+		require.NoError(t, err)
+		require.True(t, order.ReduceOnly)
+		require.Equal(t, 1.0, order.Quantity)
+
+		// the short position is closed before the execution, the order expires without opening a long position
+		_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 80})
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeExpired, order.Status)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	})
+
+	t.Run("limit reduced before the execution", func(t *testing.T) {
+		wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+			WithPaperAsset("BTC", 2))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100})
+
+		order, err := wallet.CreateOrderLimitReduceOnly(model.SideTypeSell, "BTCUSDT", 2, 110)
+		require.NoError(t, err)
+
+		// the position is reduced to 1 BTC by a short sale of the free quote
+		_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+
+		// the order is capped to the position, without opening a short position
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 110, High: 120})
+		order, err = wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, order.Status)
+		require.Equal(t, 1.0, order.Quantity)
+		asset, _, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, asset)
+	})
+}
+
+func TestPaperWallet_SimulateOrder(t *testing.T) {
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000),
+		WithPaperFee(0.0005, 0.001), WithPaperSlippage(0.01))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+	t.Run("buy", func(t *testing.T) {
+		preview, err := wallet.SimulateOrder("BTCUSDT", model.SideTypeBuy, 1, 0)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderTypeMarket, preview.Type)
+		require.InDelta(t, 101.0, preview.Price, 1e-9)
+		require.InDelta(t, 101.0, preview.Value, 1e-9)
+		require.InDelta(t, 0.101, preview.Fee, 1e-9)
+		require.InDelta(t, 1.0, preview.Slippage, 1e-9)
+		require.Equal(t, 1.0, preview.AssetBalance)
+		require.InDelta(t, 898.899, preview.QuoteBalance, 1e-9)
+
+		// wallet not changed
+		require.Empty(t, wallet.orders)
+		require.Equal(t, 1000.0, wallet.assets["USDT"].Free)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+
+		// same result of the execution
+		order, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, preview.Price, order.Price)
+		require.Equal(t, preview.Fee, order.Fee)
+		require.Equal(t, preview.AssetBalance, wallet.assets["BTC"].Free)
+		require.InDelta(t, preview.QuoteBalance, wallet.assets["USDT"].Free, 1e-9)
+	})
+
+	t.Run("limit sell", func(t *testing.T) {
+		preview, err := wallet.SimulateOrder("BTCUSDT", model.SideTypeSell, 1, 120)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderTypeLimit, preview.Type)
+		require.Equal(t, 120.0, preview.Price)
+		require.InDelta(t, 0.06, preview.Fee, 1e-9)
+		require.Equal(t, 0.0, preview.Slippage)
+		require.Equal(t, 0.0, preview.AssetBalance)
+		require.InDelta(t, 898.899+120-0.06, preview.QuoteBalance, 1e-9)
+
+		require.Equal(t, 1.0, wallet.assets["BTC"].Free)
+		require.Equal(t, 0.0, wallet.assets["BTC"].Lock)
+	})
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		preview, err := wallet.SimulateOrder("BTCUSDT", model.SideTypeBuy, 20, 0)
+		require.ErrorIs(t, err, ErrInsufficientFunds)
+		require.Empty(t, preview)
+		require.InDelta(t, 898.899, wallet.assets["USDT"].Free, 1e-9)
+		require.Equal(t, 0.0, wallet.assets["USDT"].Lock)
+	})
+
+	t.Run("invalid quantity", func(t *testing.T) {
+		_, err := wallet.SimulateOrder("BTCUSDT", model.SideTypeBuy, 0, 0)
+		require.ErrorIs(t, err, ErrInvalidQuantity)
+	})
+
+	t.Run("price not available", func(t *testing.T) {
+		_, err := wallet.SimulateOrder("ETHUSDT", model.SideTypeBuy, 1, 0)
+		require.ErrorIs(t, err, ErrPriceNotAvailable)
+	})
+}
+
+func TestPaperWallet_Portfolio(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := NewPaperWallet(context.Background(), "USDT", WithPaperAsset("USDT", 1000))
+
+	// candle streams merged in time order, like the backtest
+	queue := model.NewPriorityQueue(nil)
+	for i, price := range []float64{100, 110, 120} {
+		queue.Push(model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour), Close: price,
+			Complete: true})
+	}
+	for i, price := range []float64{10, 5, 10} {
+		queue.Push(model.Candle{Pair: "ETHUSDT", Time: start.Add(time.Duration(i) * time.Hour), Close: price,
+			Complete: true})
+	}
+
+	actions := map[string]func(){
+		"BTCUSDT@0": func() {
+			_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 5)
+			require.NoError(t, err)
+		},
+		"ETHUSDT@0": func() {
+			_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 50)
+			require.NoError(t, err)
+
+			// the balance is shared by the pairs
+			_, err = wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+			require.ErrorIs(t, err, ErrInsufficientFunds)
+		},
+		"ETHUSDT@1": func() {
+			_, err := wallet.CreateOrderMarket(model.SideTypeSell, "ETHUSDT", 50)
+			require.NoError(t, err)
+		},
+		"BTCUSDT@2": func() {
+			// with the quote of the ETH exit
+			_, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+			require.NoError(t, err)
+		},
+	}
+
+	for queue.Len() > 0 {
+		candle := queue.Pop().(model.Candle)
+		wallet.OnCandle(candle)
+		if action, ok := actions[fmt.Sprintf("%s@%d", candle.Pair, candle.Time.Sub(start)/time.Hour)]; ok {
+			action()
+		}
+	}
+
+	// a single point per time, with the prices of all pairs
+	require.Equal(t, []AssetValue{
+		{Time: start, Value: 1000},
+		{Time: start.Add(time.Hour), Value: 800},
+		{Time: start.Add(2 * time.Hour), Value: 850},
+	}, wallet.EquityValues())
+	require.Len(t, wallet.AssetValues("BTC"), 3)
+
+	asset, quote, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 7.0, asset)
+	require.Equal(t, 10.0, quote)
+}
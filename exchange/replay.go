@@ -0,0 +1,293 @@
+package exchange
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// CandleEvent is a candle received by a data feed, with the time it arrived. Preload events are the candles
+// returned by CandlesByLimit, used to fill the strategy warmup.
+type CandleEvent struct {
+	ReceivedAt time.Time    `json:"received_at"`
+	Timeframe  string       `json:"timeframe"`
+	Preload    bool         `json:"preload,omitempty"`
+	Candle     model.Candle `json:"candle"`
+}
+
+// FeedRecorder wraps a data feed and writes each candle received, including the partial candles, to the
+// writer as JSON lines. The recording can be replayed with ReplayFeed, e.g. to reproduce an issue of a
+// live session with the paper wallet.
+type FeedRecorder struct {
+	service.Feeder
+
+	mtx     sync.Mutex
+	encoder *json.Encoder
+	err     error
+	now     func() time.Time
+}
+
+func NewFeedRecorder(feeder service.Feeder, w io.Writer) *FeedRecorder {
+	return &FeedRecorder{
+		Feeder:  feeder,
+		encoder: json.NewEncoder(w),
+		now:     time.Now,
+	}
+}
+
+// Err returns the first error writing the recording, events are not recorded after an error
+func (r *FeedRecorder) Err() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.err
+}
+
+func (r *FeedRecorder) record(timeframe string, preload bool, candles ...model.Candle) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	receivedAt := r.now()
+	for _, candle := range candles {
+		r.err = r.encoder.Encode(CandleEvent{
+			ReceivedAt: receivedAt,
+			Timeframe:  timeframe,
+			Preload:    preload,
+			Candle:     candle,
+		})
+		if r.err != nil {
+			return
+		}
+	}
+}
+
+func (r *FeedRecorder) CandlesByLimit(ctx context.Context, pair, period string, limit int) ([]model.Candle, error) {
+	candles, err := r.Feeder.CandlesByLimit(ctx, pair, period, limit)
+	if err != nil {
+		return nil, err
+	}
+	r.record(period, true, candles...)
+	return candles, nil
+}
+
+func (r *FeedRecorder) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle,
+	chan error) {
+	source, sourceErr := r.Feeder.CandlesSubscription(ctx, pair, timeframe)
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+
+	go func() {
+		for candle := range source {
+			r.record(timeframe, false, candle)
+			ccandle <- candle
+		}
+		close(ccandle)
+	}()
+
+	go func() {
+		for err := range sourceErr {
+			cerr <- err
+		}
+		close(cerr)
+	}()
+
+	return ccandle, cerr
+}
+
+// ReplayFeed is a data feed that emits the candles of a FeedRecorder recording, with the same order and
+// the same interval between events. The speed changes the interval, and a speed of zero emits the events
+// without waiting. The order between different pairs is preserved only when the intervals are replayed.
+type ReplayFeed struct {
+	events    []CandleEvent
+	speed     float64
+	timestamp model.TimestampConvention
+
+	mtx        sync.Mutex
+	start      time.Time
+	lastQuotes map[string]float64
+}
+
+// NewReplayFeed reads the recording written by FeedRecorder, with the events replayed at the original speed
+func NewReplayFeed(r io.Reader) (*ReplayFeed, error) {
+	feed := &ReplayFeed{
+		speed:      1,
+		lastQuotes: make(map[string]float64),
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event CandleEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("invalid event in line %d: %w", line, err)
+		}
+		feed.events = append(feed.events, event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// SetSpeed sets the replay speed, e.g. 2 emits the events in half of the recorded interval and 0 emits
+// them without waiting
+func (r *ReplayFeed) SetSpeed(speed float64) {
+	r.speed = speed
+}
+
+// SetTimestampConvention declares the timestamp convention of the recorded candles, e.g. a recording of an
+// external source timestamped by close time. The candles are shifted to the open time by the timeframe of each
+// event. The recordings of FeedRecorder are timestamped by open time, the default.
+func (r *ReplayFeed) SetTimestampConvention(convention model.TimestampConvention) error {
+	if (convention == model.TimestampCloseTime) == (r.timestamp == model.TimestampCloseTime) {
+		r.timestamp = convention
+		return nil
+	}
+
+	events := make([]CandleEvent, len(r.events))
+	for i, event := range r.events {
+		interval, err := str2duration.ParseDuration(event.Timeframe)
+		if err != nil {
+			return fmt.Errorf("invalid timeframe of event %d: %w", i, err)
+		}
+
+		if convention == model.TimestampCloseTime {
+			event.Candle.Time = event.Candle.Time.Add(-interval)
+		} else {
+			// back to the recorded times
+			event.Candle.Time = event.Candle.Time.Add(interval)
+		}
+		events[i] = event
+	}
+
+	r.events = events
+	r.timestamp = convention
+	return nil
+}
+
+// Events returns the recorded events
+func (r *ReplayFeed) Events() []CandleEvent {
+	return r.events
+}
+
+func (r *ReplayFeed) AssetsInfo(pair string) model.AssetInfo {
+	return defaultAssetInfo(pair)
+}
+
+// LastQuote returns the close of the last candle emitted for the pair
+func (r *ReplayFeed) LastQuote(_ context.Context, pair string) (float64, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	quote, ok := r.lastQuotes[pair]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrInsufficientData, pair)
+	}
+	return quote, nil
+}
+
+// CandlesByPeriod returns the recorded complete candles in the period
+func (r *ReplayFeed) CandlesByPeriod(_ context.Context, pair, timeframe string,
+	start, end time.Time) ([]model.Candle, error) {
+
+	candles := make([]model.Candle, 0)
+	for _, event := range r.events {
+		candle := event.Candle
+		if candle.Pair != pair || event.Timeframe != timeframe || !candle.Complete ||
+			candle.Time.Before(start) || candle.Time.After(end) {
+			continue
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+// CandlesByLimit returns the last preload candles recorded for the pair, as returned to the live session
+func (r *ReplayFeed) CandlesByLimit(_ context.Context, pair, timeframe string, limit int) ([]model.Candle, error) {
+	candles := make([]model.Candle, 0)
+	for _, event := range r.events {
+		if event.Preload && event.Candle.Pair == pair && event.Timeframe == timeframe {
+			candles = append(candles, event.Candle)
+		}
+	}
+
+	if len(candles) < limit {
+		return nil, fmt.Errorf("%w: %s", ErrInsufficientData, pair)
+	}
+	return candles[len(candles)-limit:], nil
+}
+
+// CandlesSubscription emits the recorded candles of the pair, the interval between events is relative to the
+// first subscription and the first recorded event, so subscriptions of different pairs share the same clock
+func (r *ReplayFeed) CandlesSubscription(ctx context.Context, pair, timeframe string) (chan model.Candle,
+	chan error) {
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+
+	r.mtx.Lock()
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+	start := r.start
+	r.mtx.Unlock()
+
+	var origin time.Time
+	for _, event := range r.events {
+		if !event.Preload {
+			origin = event.ReceivedAt
+			break
+		}
+	}
+
+	go func() {
+		defer close(cerr)
+		defer close(ccandle)
+
+		for _, event := range r.events {
+			if event.Preload || event.Candle.Pair != pair || event.Timeframe != timeframe {
+				continue
+			}
+
+			if r.speed > 0 {
+				delay := time.Duration(float64(event.ReceivedAt.Sub(origin)) / r.speed)
+				timer := time.NewTimer(time.Until(start.Add(delay)))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+
+			r.mtx.Lock()
+			r.lastQuotes[pair] = event.Candle.Close
+			r.mtx.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return
+			case ccandle <- event.Candle:
+			}
+		}
+	}()
+
+	return ccandle, cerr
+}
@@ -0,0 +1,201 @@
+package exchange
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+type streamFeeder struct {
+	service.Feeder
+	preload []model.Candle
+	stream  []model.Candle
+}
+
+func (s streamFeeder) CandlesByLimit(_ context.Context, _, _ string, limit int) ([]model.Candle, error) {
+	return s.preload[len(s.preload)-limit:], nil
+}
+
+func (s streamFeeder) CandlesSubscription(_ context.Context, _, _ string) (chan model.Candle, chan error) {
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+	go func() {
+		for _, candle := range s.stream {
+			ccandle <- candle
+		}
+		close(ccandle)
+		close(cerr)
+	}()
+	return ccandle, cerr
+}
+
+func TestReplayFeed(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	feeder := streamFeeder{}
+	for i := 0; i < 3; i++ {
+		feeder.preload = append(feeder.preload, model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) *
+			time.Minute), Close: float64(100 + i), Complete: true})
+	}
+
+	// partial updates followed by the complete candle
+	for i := 0; i < 6; i++ {
+		candle := model.Candle{
+			Pair:      "BTCUSDT",
+			Time:      start.Add(time.Duration(3+i/3) * time.Minute),
+			UpdatedAt: start.Add(time.Duration(3+i/3)*time.Minute + time.Duration(i%3)*20*time.Second),
+			Open:      100,
+			Close:     float64(100 + i),
+			Low:       90,
+			High:      110,
+			Volume:    float64(i),
+			Complete:  i%3 == 2,
+		}
+		if candle.Complete {
+			candle.Metadata = map[string]float64{"lsr": 1.1}
+		}
+		feeder.stream = append(feeder.stream, candle)
+	}
+
+	buffer := new(bytes.Buffer)
+	recorder := NewFeedRecorder(feeder, buffer)
+	clock := start
+	recorder.now = func() time.Time {
+		clock = clock.Add(10 * time.Millisecond)
+		return clock
+	}
+
+	preload, err := recorder.CandlesByLimit(context.Background(), "BTCUSDT", "1m", 2)
+	require.NoError(t, err)
+	require.Equal(t, feeder.preload[1:], preload)
+
+	ccandle, cerr := recorder.CandlesSubscription(context.Background(), "BTCUSDT", "1m")
+	var recorded []model.Candle
+	for candle := range ccandle {
+		recorded = append(recorded, candle)
+	}
+	require.Empty(t, <-cerr)
+	require.Equal(t, feeder.stream, recorded)
+	require.NoError(t, recorder.Err())
+
+	t.Run("events", func(t *testing.T) {
+		replay, err := NewReplayFeed(bytes.NewReader(buffer.Bytes()))
+		require.NoError(t, err)
+
+		events := replay.Events()
+		require.Len(t, events, 8)
+		require.True(t, events[0].Preload)
+		require.True(t, events[1].Preload)
+		for i, event := range events[2:] {
+			require.False(t, event.Preload)
+			require.Equal(t, "1m", event.Timeframe)
+			require.Equal(t, start.Add(time.Duration(i+2)*10*time.Millisecond), event.ReceivedAt.UTC())
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		replay, err := NewReplayFeed(bytes.NewReader(buffer.Bytes()))
+		require.NoError(t, err)
+		replay.SetSpeed(0)
+
+		_, err = replay.LastQuote(context.Background(), "BTCUSDT")
+		require.ErrorIs(t, err, ErrInsufficientData)
+
+		preload, err := replay.CandlesByLimit(context.Background(), "BTCUSDT", "1m", 2)
+		require.NoError(t, err)
+		require.Equal(t, feeder.preload[1:], preload)
+
+		_, err = replay.CandlesByLimit(context.Background(), "BTCUSDT", "1m", 3)
+		require.ErrorIs(t, err, ErrInsufficientData)
+
+		ccandle, cerr := replay.CandlesSubscription(context.Background(), "BTCUSDT", "1m")
+		var replayed []model.Candle
+		for candle := range ccandle {
+			replayed = append(replayed, candle)
+		}
+		require.Empty(t, <-cerr)
+		require.Equal(t, feeder.stream, replayed)
+
+		quote, err := replay.LastQuote(context.Background(), "BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 105.0, quote)
+
+		candles, err := replay.CandlesByPeriod(context.Background(), "BTCUSDT", "1m", start, start.Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, candles, 4)
+
+		ccandle, _ = replay.CandlesSubscription(context.Background(), "ETHUSDT", "1m")
+		_, ok := <-ccandle
+		require.False(t, ok)
+	})
+
+	t.Run("timing", func(t *testing.T) {
+		replay, err := NewReplayFeed(bytes.NewReader(buffer.Bytes()))
+		require.NoError(t, err)
+
+		begin := time.Now()
+		ccandle, _ := replay.CandlesSubscription(context.Background(), "BTCUSDT", "1m")
+		var elapsed []time.Duration
+		for range ccandle {
+			elapsed = append(elapsed, time.Since(begin))
+		}
+
+		// events recorded with 10ms of interval
+		require.Len(t, elapsed, 6)
+		for i, value := range elapsed {
+			require.GreaterOrEqual(t, value, time.Duration(i)*10*time.Millisecond)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		replay, err := NewReplayFeed(bytes.NewReader(buffer.Bytes()))
+		require.NoError(t, err)
+		replay.SetSpeed(0.001)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ccandle, _ := replay.CandlesSubscription(ctx, "BTCUSDT", "1m")
+		_, ok := <-ccandle
+		require.True(t, ok)
+
+		cancel()
+		_, ok = <-ccandle
+		require.False(t, ok)
+	})
+
+	t.Run("invalid recording", func(t *testing.T) {
+		_, err := NewReplayFeed(bytes.NewBufferString("{}\ninvalid\n"))
+		require.EqualError(t, err, "invalid event in line 2: invalid character 'i' looking for beginning of value")
+	})
+}
+
+func TestReplayFeed_SetTimestampConvention(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	buffer := new(bytes.Buffer)
+	encoder := json.NewEncoder(buffer)
+	for i := 1; i <= 3; i++ {
+		closeTime := start.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, encoder.Encode(CandleEvent{ReceivedAt: closeTime, Timeframe: "1m", Preload: true,
+			Candle: model.Candle{Pair: "BTCUSDT", Time: closeTime, Close: float64(i), Complete: true}}))
+	}
+
+	feed, err := NewReplayFeed(buffer)
+	require.NoError(t, err)
+	require.NoError(t, feed.SetTimestampConvention(model.TimestampCloseTime))
+	require.NoError(t, feed.SetTimestampConvention(model.TimestampCloseTime))
+
+	candles, err := feed.CandlesByLimit(context.Background(), "BTCUSDT", "1m", 3)
+	require.NoError(t, err)
+	require.Len(t, candles, 3)
+	for i, candle := range candles {
+		require.Equal(t, start.Add(time.Duration(i)*time.Minute), candle.Time)
+	}
+
+	require.NoError(t, feed.SetTimestampConvention(model.TimestampOpenTime))
+	require.Equal(t, start.Add(time.Minute), feed.Events()[0].Candle.Time)
+}
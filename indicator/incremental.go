@@ -0,0 +1,105 @@
+package indicator
+
+import (
+	"math"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// Stateful is an indicator updated one value at a time in O(1), instead of recomputing the whole series
+type Stateful interface {
+	// Update adds a value to the state and returns the indicator value, NaN during the warmup
+	Update(value float64) float64
+	// Peek returns the indicator value with the given value, without changing the state
+	Peek(value float64) float64
+}
+
+// EMAState is the incremental EMA, seeded with the SMA of the first period values. It is the same EMA of the
+// indicators of the model package, e.g. model.OHLC.TRIX.
+type EMAState = model.EMAState
+
+func NewEMAState(period int) *EMAState {
+	return model.NewEMAState(period)
+}
+
+// RSIState is the incremental RSI, with the Wilder smoothing of RSI. It is the same RSI of the indicators of
+// the model package, e.g. model.OHLC.ConnorsRSI. Unlike RSI, a window without changes is 50 instead of 0.
+type RSIState = model.RSIState
+
+func NewRSIState(period int) *RSIState {
+	return model.NewRSIState(period)
+}
+
+// Incremental fills a metadata series of the dataframe with a stateful indicator, computing only the candles
+// received since the last call instead of the whole series, e.g. in the Indicators function of a live strategy.
+// The last candle of the dataframe is computed with Peek, so updates of a partial candle do not change the
+// state, and it is added to the state when a newer candle is received.
+type Incremental struct {
+	key    string
+	state  Stateful
+	input  func(df *model.Dataframe) []float64
+	times  []time.Time
+	values []float64
+}
+
+// NewIncremental creates an incremental indicator stored in the metadata key, the input returns the source
+// values of the indicator, e.g. the close price
+func NewIncremental(key string, state Stateful, input func(df *model.Dataframe) []float64) *Incremental {
+	return &Incremental{key: key, state: state, input: input}
+}
+
+// PriceInput returns the input of an incremental indicator with the price source of the dataframe, e.g.
+// NewIncremental("rsi", NewRSIState(14), PriceInput(model.PriceHLC3))
+func PriceInput(source model.PriceSource) func(df *model.Dataframe) []float64 {
+	return func(df *model.Dataframe) []float64 {
+		return df.Price(source)
+	}
+}
+
+// Update computes the new candles of the dataframe and stores the series in the metadata, with the same
+// length of the dataframe. It must be called with a single dataframe, e.g. one Incremental per pair.
+func (i *Incremental) Update(df *model.Dataframe) model.Series[float64] {
+	input := i.input(df)
+	if df.Metadata == nil {
+		df.Metadata = make(map[string]model.Series[float64])
+	}
+	if len(df.Time) == 0 {
+		df.Metadata[i.key] = model.Series[float64]{}
+		return df.Metadata[i.key]
+	}
+
+	// candles already added to the state are skipped
+	start := 0
+	if len(i.times) > 0 {
+		last := i.times[len(i.times)-1]
+		for start < len(df.Time) && !df.Time[start].After(last) {
+			start++
+		}
+	}
+
+	for j := start; j < len(df.Time)-1; j++ {
+		i.times = append(i.times, df.Time[j])
+		i.values = append(i.values, i.state.Update(input[j]))
+	}
+
+	// keep only the values that can be part of the dataframe
+	if excess := len(i.values) - len(df.Time); excess > 0 {
+		i.times = i.times[excess:]
+		i.values = i.values[excess:]
+	}
+
+	series := make(model.Series[float64], len(df.Time))
+	offset := len(df.Time) - 1 - len(i.values)
+	for j := range series[:len(series)-1] {
+		if j < offset {
+			series[j] = math.NaN()
+			continue
+		}
+		series[j] = i.values[j-offset]
+	}
+	series[len(series)-1] = i.state.Peek(input[len(input)-1])
+
+	df.Metadata[i.key] = series
+	return series
+}
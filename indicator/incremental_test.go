@@ -0,0 +1,111 @@
+package indicator
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func incrementalFixture() []float64 {
+	values := make([]float64, 0, 200)
+	for i := 0; i < 200; i++ {
+		values = append(values, 100+10*math.Sin(float64(i)/7)+float64(i%5))
+	}
+	return values
+}
+
+func TestEMAState(t *testing.T) {
+	values := incrementalFixture()
+	batch := EMA(values, 9)
+
+	state := NewEMAState(9)
+	for i, value := range values {
+		peek := state.Peek(value)
+		result := state.Update(value)
+		if i < 8 {
+			require.True(t, math.IsNaN(result))
+			continue
+		}
+		require.InDelta(t, batch[i], result, 1e-9)
+		require.Equal(t, peek, result)
+	}
+}
+
+func TestRSIState(t *testing.T) {
+	values := incrementalFixture()
+	batch := RSI(values, 14)
+
+	state := NewRSIState(14)
+	for i, value := range values {
+		result := state.Update(value)
+		if i < 14 {
+			require.True(t, math.IsNaN(result))
+			continue
+		}
+		require.InDelta(t, batch[i], result, 1e-9)
+	}
+
+	// flat series and series without losses, as in the Connors RSI
+	state = NewRSIState(2)
+	for _, value := range []float64{10, 10, 10, 10} {
+		state.Update(value)
+	}
+	require.Equal(t, 50.0, state.Peek(10))
+	require.Equal(t, 100.0, state.Peek(11))
+}
+
+func TestIncremental_Update(t *testing.T) {
+	values := incrementalFixture()
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := make([]time.Time, len(values))
+	for i := range values {
+		times[i] = start.Add(time.Duration(i) * time.Hour)
+	}
+
+	ema := NewIncremental("ema", NewEMAState(9), func(df *model.Dataframe) []float64 {
+		return df.Close
+	})
+
+	// sliding window of candles, as received by the strategy
+	window := 30
+	for i := window; i <= len(values); i++ {
+		closes := model.Series[float64](values[i-window : i])
+
+		// partial candle with a different close does not change the state
+		partial := &model.Dataframe{OHLC: model.OHLC{
+			Close: append(model.Series[float64]{}, closes...),
+			Time:  times[i-window : i],
+		}}
+		partial.Close[window-1] += 50
+		ema.Update(partial)
+
+		df := &model.Dataframe{OHLC: model.OHLC{Close: closes, Time: times[i-window : i]}}
+		series := ema.Update(df)
+		require.Len(t, series, window)
+		require.Equal(t, series, df.Metadata["ema"])
+
+		batch := EMA(values[:i], 9)
+		for j := range series {
+			if i-window+j < 8 {
+				require.True(t, math.IsNaN(series[j]))
+				continue
+			}
+			require.InDelta(t, batch[i-window+j], series[j], 1e-9)
+		}
+	}
+}
+
+func TestPriceInput(t *testing.T) {
+	df := &model.Dataframe{OHLC: model.OHLC{
+		Open:  []float64{10, 20},
+		High:  []float64{16, 26},
+		Low:   []float64{8, 17},
+		Close: []float64{12, 21},
+	}}
+	require.Equal(t, []float64{12, 21.5}, PriceInput(model.PriceHL2)(df))
+	require.Equal(t, []float64{12, 21}, PriceInput(model.PriceClose)(df))
+}
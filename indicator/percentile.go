@@ -0,0 +1,14 @@
+package indicator
+
+import (
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// RollingPercentile - rolling q-quantile (0 <= q <= 1) over a window of `period` values.
+// The quantile is linearly interpolated between the two closest ranks of the sorted window, the same
+// method used by default in numpy and Excel's PERCENTILE.INC, see model.RollingQuantile.
+// The output has the same length of the input, with NaN in the first period-1 positions (warmup).
+// Invalid parameters return model.ErrInvalidQuantile.
+func RollingPercentile(values []float64, period int, q float64) ([]float64, error) {
+	return model.RollingQuantile(values, period, q)
+}
@@ -0,0 +1,54 @@
+package indicator
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestRollingPercentile(t *testing.T) {
+	values := []float64{3, 1, 4, 1, 5, 9, 2, 6}
+
+	t.Run("median", func(t *testing.T) {
+		result, err := RollingPercentile(values, 4, 0.5)
+		require.NoError(t, err)
+		require.Len(t, result, len(values))
+		for i := 0; i < 3; i++ {
+			require.True(t, math.IsNaN(result[i]))
+		}
+
+		// sorted windows: [1 1 3 4] [1 1 4 5] [1 4 5 9] [1 2 5 9] [2 5 6 9]
+		expected := []float64{2, 2.5, 4.5, 3.5, 5.5}
+		require.InDeltaSlice(t, expected, result[3:], 1e-9)
+	})
+
+	t.Run("90th percentile", func(t *testing.T) {
+		result, err := RollingPercentile(values, 4, 0.9)
+		require.NoError(t, err)
+
+		// h = 3 * 0.9 = 2.7, window [1 1 3 4] -> 3 + 0.7 * (4 - 3)
+		expected := []float64{3.7, 4.7, 7.8, 7.8, 8.1}
+		require.InDeltaSlice(t, expected, result[3:], 1e-9)
+	})
+
+	t.Run("bounds", func(t *testing.T) {
+		lowest, err := RollingPercentile(values, 4, 0)
+		require.NoError(t, err)
+		require.Equal(t, 1.0, lowest[3])
+
+		highest, err := RollingPercentile(values, 4, 1)
+		require.NoError(t, err)
+		require.Equal(t, 4.0, highest[3])
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		_, err := RollingPercentile(values, 0, 0.5)
+		require.ErrorIs(t, err, model.ErrInvalidQuantile)
+
+		_, err = RollingPercentile(values, 4, 1.5)
+		require.ErrorIs(t, err, model.ErrInvalidQuantile)
+	})
+}
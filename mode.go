@@ -0,0 +1,60 @@
+package ninjabot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// modeExchange returns the exchange that executes the orders in the given mode, the data feed is not affected.
+// In paper mode, the given wallet is used when defined, otherwise a paper wallet is created with the balances
+// of the exchange account.
+func modeExchange(ctx context.Context, settings model.Settings, exch service.Exchange,
+	wallet *exchange.PaperWallet) (service.Exchange, *exchange.PaperWallet, error) {
+
+	switch settings.Mode {
+	case "", model.ModeLive:
+		return exch, wallet, nil
+	case model.ModeDryRun, model.ModeSignal:
+		return exchange.NewDryRun(exch), wallet, nil
+	case model.ModePaper:
+		if wallet != nil {
+			return wallet, wallet, nil
+		}
+
+		account, err := exch.Account()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// the quote of the first pair is the base coin of the wallet
+		var baseCoin string
+		if len(settings.Pairs) > 0 {
+			_, baseCoin = exchange.SplitAssetQuote(settings.Pairs[0])
+		}
+
+		options := []exchange.PaperWalletOption{exchange.WithPaperAsset(baseCoin, 0), exchange.WithDataFeed(exch)}
+		for _, balance := range account.Balances {
+			options = append(options, exchange.WithPaperAsset(balance.Asset, balance.Free+balance.Lock))
+		}
+		wallet = exchange.NewPaperWallet(ctx, baseCoin, options...)
+		return wallet, wallet, nil
+	}
+	return nil, nil, fmt.Errorf("invalid mode: %s", settings.Mode)
+}
+
+// modeFile namespaces the file by the mode, e.g. ninjabot-paper.db, so paper and dry-run executions
+// do not mix with the live history
+func modeFile(name string, mode model.Mode) string {
+	if mode == "" || mode == model.ModeLive {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(name, ext), mode, ext)
+}
@@ -0,0 +1,155 @@
+package ninjabot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// liveExchange fails the test when an order reaches the exchange, other methods are not implemented
+type liveExchange struct {
+	service.Exchange
+	t *testing.T
+}
+
+func (e liveExchange) Account() (model.Account, error) {
+	return model.Account{Balances: []model.Balance{{Asset: "USDT", Free: 1000}, {Asset: "BTC", Free: 1}}}, nil
+}
+
+func (e liveExchange) CreateOrderMarket(_ model.SideType, _ string, _ float64) (model.Order, error) {
+	e.t.Fatal("order sent to the live exchange")
+	return model.Order{}, nil
+}
+
+func (e liveExchange) CreateOrderLimit(_ model.SideType, _ string, _, _ float64) (model.Order, error) {
+	e.t.Fatal("order sent to the live exchange")
+	return model.Order{}, nil
+}
+
+func TestModeExchange(t *testing.T) {
+	ctx := context.Background()
+	live := liveExchange{t: t}
+	settings := model.Settings{Pairs: []string{"BTCUSDT"}}
+
+	t.Run("live", func(t *testing.T) {
+		exch, wallet, err := modeExchange(ctx, settings, live, nil)
+		require.NoError(t, err)
+		require.Equal(t, live, exch)
+		require.Nil(t, wallet)
+	})
+
+	t.Run("paper", func(t *testing.T) {
+		settings := settings
+		settings.Mode = model.ModePaper
+		exch, wallet, err := modeExchange(ctx, settings, live, nil)
+		require.NoError(t, err)
+		require.Equal(t, wallet, exch)
+
+		// initialized with the account balances
+		asset, quote, err := exch.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 1.0, asset)
+		require.Equal(t, 1000.0, quote)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, Complete: true})
+		_, err = exch.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		_, err = exch.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 200)
+		require.NoError(t, err)
+
+		asset, quote, err = exch.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 2.0, asset)
+		require.Equal(t, 900.0, quote)
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		settings := settings
+		settings.Mode = model.ModeDryRun
+		exch, _, err := modeExchange(ctx, settings, live, nil)
+		require.NoError(t, err)
+
+		_, err = exch.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, exchange.ErrDryRun)
+
+		account, err := exch.Account()
+		require.NoError(t, err)
+		require.Len(t, account.Balances, 2)
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		settings := settings
+		settings.Mode = model.ModeSignal
+		exch, _, err := modeExchange(ctx, settings, live, nil)
+		require.NoError(t, err)
+
+		_, err = exch.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, exchange.ErrDryRun)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		settings := settings
+		settings.Mode = "unknown"
+		_, _, err := modeExchange(ctx, settings, live, nil)
+		require.Error(t, err)
+	})
+}
+
+func TestModeFile(t *testing.T) {
+	require.Equal(t, "ninjabot.db", modeFile("ninjabot.db", ""))
+	require.Equal(t, "ninjabot.db", modeFile("ninjabot.db", model.ModeLive))
+	require.Equal(t, "ninjabot-paper.db", modeFile("ninjabot.db", model.ModePaper))
+	require.Equal(t, "ninjabot-pairs-dry-run.json", modeFile("ninjabot-pairs.json", model.ModeDryRun))
+}
+
+type signalRecorder struct {
+	signals []model.Signal
+}
+
+func (s *signalRecorder) OnSignal(signal model.Signal) {
+	s.signals = append(s.signals, signal)
+}
+
+func TestNinjaBot_EmitSignal(t *testing.T) {
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	repository, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	notifier := &notifierRecorder{}
+	subscriber := &signalRecorder{}
+	bot := &NinjaBot{
+		logger:            log.Default(),
+		signals:           make(chan model.Signal, 1),
+		notifier:          notifier,
+		orderController:   order.NewController(ctx, wallet, repository, order.NewOrderFeed()),
+		signalSubscribers: []SignalSubscriber{subscriber},
+	}
+
+	signal := model.Signal{Pair: "BTCUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeMarket, Size: 1,
+		Price: 100, Reason: "oversold", Indicators: map[string]float64{"rsi": 25}}
+	bot.emitSignal(signal)
+
+	require.Equal(t, signal, <-bot.Signals())
+	require.Equal(t, []model.Signal{signal}, subscriber.signals)
+
+	messages := notifier.Messages()
+	require.Len(t, messages, 1)
+	require.True(t, strings.HasPrefix(messages[0], "🔔 SIGNAL - BUY BTCUSDT"))
+	require.Contains(t, messages[0], "Reason: oversold")
+	require.Contains(t, messages[0], "rsi: 25.0000")
+
+	// no orders created
+	orders, err := repository.Orders()
+	require.NoError(t, err)
+	require.Empty(t, orders)
+}
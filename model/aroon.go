@@ -0,0 +1,34 @@
+package model
+
+import "math"
+
+// Aroon returns the Aroon up and down lines: 100 * (period - candles since the highest high) / period and the
+// analogous line of the lowest low, in the window of the current candle and the previous period candles.
+// Values are in [0, 100], 100 is a new high (or low) in the current candle. Ties use the most recent extreme.
+// Warmup positions (period) are NaN.
+func (df *OHLC) Aroon(period int) (up, down []float64) {
+	up, down = make([]float64, len(df.Close)), make([]float64, len(df.Close))
+	for i := range up {
+		up[i], down[i] = math.NaN(), math.NaN()
+	}
+
+	if period <= 0 {
+		return up, down
+	}
+
+	for i := period; i < len(df.Close); i++ {
+		highest, lowest := i-period, i-period
+		for j := i - period + 1; j <= i; j++ {
+			if df.High[j] >= df.High[highest] {
+				highest = j
+			}
+			if df.Low[j] <= df.Low[lowest] {
+				lowest = j
+			}
+		}
+
+		up[i] = 100 * float64(period-(i-highest)) / float64(period)
+		down[i] = 100 * float64(period-(i-lowest)) / float64(period)
+	}
+	return up, down
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOHLC_Aroon(t *testing.T) {
+	df := &OHLC{
+		High:  []float64{10, 11, 12, 11, 10, 9, 10, 13},
+		Low:   []float64{9, 10, 11, 10, 9, 8, 9, 12},
+		Close: []float64{9.5, 10.5, 11.5, 10.5, 9.5, 8.5, 9.5, 12.5},
+	}
+	up, down := df.Aroon(4)
+	require.Len(t, up, len(df.Close))
+	require.Len(t, down, len(df.Close))
+
+	for i := 0; i < 4; i++ {
+		require.True(t, math.IsNaN(up[i]))
+		require.True(t, math.IsNaN(down[i]))
+	}
+
+	// the high of index 2 ages one candle at a time, the lows are recent
+	require.Equal(t, []float64{50, 25, 0}, up[4:7])
+	require.Equal(t, []float64{100, 100, 75}, down[4:7])
+
+	// a fresh high
+	require.Equal(t, 100.0, up[7])
+	require.Equal(t, 50.0, down[7])
+
+	t.Run("ties use the most recent extreme", func(t *testing.T) {
+		df := &OHLC{High: []float64{10, 10, 10}, Low: []float64{10, 10, 10}, Close: []float64{10, 10, 10}}
+		up, down := df.Aroon(2)
+		require.Equal(t, 100.0, up[2])
+		require.Equal(t, 100.0, down[2])
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		up, down := df.Aroon(0)
+		for i := range up {
+			require.True(t, math.IsNaN(up[i]))
+			require.True(t, math.IsNaN(down[i]))
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package model
+
+import "math"
+
+// BollingerBands returns the bands of the close price: SMA(period) ± mult * standard deviation(period).
+// Warmup positions (period - 1) are zero.
+func (df *OHLC) BollingerBands(period int, mult float64) (upper, middle, lower []float64) {
+	length := len(df.Close)
+	upper, middle, lower = make([]float64, length), make([]float64, length), make([]float64, length)
+	if period <= 0 {
+		return upper, middle, lower
+	}
+
+	for i := period - 1; i < length; i++ {
+		var sum float64
+		for _, value := range df.Close[i-period+1 : i+1] {
+			sum += value
+		}
+		mean := sum / float64(period)
+
+		var variance float64
+		for _, value := range df.Close[i-period+1 : i+1] {
+			variance += (value - mean) * (value - mean)
+		}
+		deviation := math.Sqrt(variance / float64(period))
+
+		middle[i] = mean
+		upper[i] = mean + mult*deviation
+		lower[i] = mean - mult*deviation
+	}
+	return upper, middle, lower
+}
+
+// KeltnerChannels returns the channels of the close price: EMA(period) ± mult * ATR(period).
+// Warmup positions (period) are zero.
+func (df *OHLC) KeltnerChannels(period int, mult float64) (upper, middle, lower []float64) {
+	length := len(df.Close)
+	upper, middle, lower = make([]float64, length), make([]float64, length), make([]float64, length)
+	if period <= 0 || length <= period {
+		return upper, middle, lower
+	}
+
+	ema := exponentialAverage(df.Close, period)
+
+	// ATR with Wilder's smoothing, the first true range depends on the previous close
+	var atr float64
+	for i := 1; i < length; i++ {
+		trueRange := math.Max(df.High[i]-df.Low[i],
+			math.Max(math.Abs(df.High[i]-df.Close[i-1]), math.Abs(df.Low[i]-df.Close[i-1])))
+		switch {
+		case i < period:
+			atr += trueRange
+			continue
+		case i == period:
+			atr = (atr + trueRange) / float64(period)
+		default:
+			atr = (atr*float64(period-1) + trueRange) / float64(period)
+		}
+
+		middle[i] = ema[i]
+		upper[i] = ema[i] + mult*atr
+		lower[i] = ema[i] - mult*atr
+	}
+	return upper, middle, lower
+}
+
+// Squeeze marks the candles where the Bollinger Bands are inside the Keltner Channels,
+// a low volatility period that usually precedes a breakout. Warmup positions are false.
+func (df *OHLC) Squeeze(bbPeriod, kcPeriod int, bbMult, kcMult float64) []bool {
+	result := make([]bool, len(df.Close))
+	if bbPeriod <= 0 || kcPeriod <= 0 {
+		return result
+	}
+
+	bbUpper, _, bbLower := df.BollingerBands(bbPeriod, bbMult)
+	kcUpper, _, kcLower := df.KeltnerChannels(kcPeriod, kcMult)
+
+	warmup := kcPeriod
+	if bbPeriod-1 > warmup {
+		warmup = bbPeriod - 1
+	}
+
+	for i := warmup; i < len(df.Close); i++ {
+		result[i] = bbUpper[i] < kcUpper[i] && bbLower[i] > kcLower[i]
+	}
+	return result
+}
+
+// exponentialAverage returns the EMA of values seeded with the SMA of the first period.
+// Leading NaN values are skipped, e.g. to smooth another average, and warmup positions (period - 1
+// after the first value) are NaN.
+func exponentialAverage(values []float64, period int) []float64 {
+	result := make([]float64, len(values))
+	for i := range result {
+		result[i] = math.NaN()
+	}
+
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+
+	state := NewEMAState(period)
+	for i := start; i < len(values); i++ {
+		result[i] = state.Update(values[i])
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func squeezeFixture() *OHLC {
+	df := &OHLC{}
+	for i := 0; i < 80; i++ {
+		// strong trend, followed by a low volatility stretch with wide candles
+		price, spread := 100+2*float64(i), 0.5
+		if i >= 40 {
+			price, spread = 178+float64(i%2)*0.2, 1
+		}
+		df.Open = append(df.Open, price)
+		df.Close = append(df.Close, price)
+		df.High = append(df.High, price+spread)
+		df.Low = append(df.Low, price-spread)
+	}
+	return df
+}
+
+func TestOHLC_BollingerBands(t *testing.T) {
+	df := &OHLC{Close: []float64{1, 2, 3, 4, 5}}
+	upper, middle, lower := df.BollingerBands(3, 2)
+
+	require.Equal(t, []float64{0, 0, 2, 3, 4}, middle)
+	require.InDeltaSlice(t, []float64{0, 0, 3.633, 4.633, 5.633}, upper, 0.001)
+	require.InDeltaSlice(t, []float64{0, 0, 0.367, 1.367, 2.367}, lower, 0.001)
+}
+
+func TestOHLC_KeltnerChannels(t *testing.T) {
+	df := &OHLC{
+		Close: []float64{10, 11, 12, 13},
+		High:  []float64{11, 12, 13, 14},
+		Low:   []float64{9, 10, 11, 12},
+	}
+	upper, middle, lower := df.KeltnerChannels(2, 1)
+
+	// EMA: 10.5 (seed), 11.5, 12.5 | ATR: 2 (seed), 2
+	require.Equal(t, []float64{0, 0, 11.5, 12.5}, middle)
+	require.Equal(t, []float64{0, 0, 13.5, 14.5}, upper)
+	require.Equal(t, []float64{0, 0, 9.5, 10.5}, lower)
+}
+
+func TestOHLC_Squeeze(t *testing.T) {
+	df := squeezeFixture()
+	squeeze := df.Squeeze(20, 20, 2, 1.5)
+	require.Len(t, squeeze, 80)
+
+	for i := 0; i < 40; i++ {
+		require.False(t, squeeze[i], i)
+	}
+
+	for i := 60; i < 80; i++ {
+		require.True(t, squeeze[i], i)
+	}
+
+	require.Equal(t, make([]bool, 10), (&OHLC{Close: make([]float64, 10)}).Squeeze(20, 20, 2, 1.5))
+}
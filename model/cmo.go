@@ -0,0 +1,35 @@
+package model
+
+import "math"
+
+// CMO returns the Chande Momentum Oscillator: 100 * (sumUp - sumDown) / (sumUp + sumDown), with the sums of
+// the close gains and losses of the last period changes. Values are bounded to [-100, 100] and a period
+// without changes is 0. Warmup positions (period) are NaN.
+func (df *OHLC) CMO(period int) []float64 {
+	result := make([]float64, len(df.Close))
+	for i := range result {
+		result[i] = math.NaN()
+	}
+
+	if period <= 0 {
+		return result
+	}
+
+	for i := period; i < len(df.Close); i++ {
+		var up, down float64
+		for j := i - period + 1; j <= i; j++ {
+			if change := df.Close[j] - df.Close[j-1]; change > 0 {
+				up += change
+			} else {
+				down -= change
+			}
+		}
+
+		if up+down == 0 {
+			result[i] = 0
+			continue
+		}
+		result[i] = 100 * (up - down) / (up + down)
+	}
+	return result
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOHLC_CMO(t *testing.T) {
+	cmo := stcFixture().CMO(4)
+
+	// warmup: 4 changes
+	for _, value := range cmo[:4] {
+		require.True(t, math.IsNaN(value))
+	}
+
+	expected := []float64{81.8181818182, 83.3333333333, 42.8571428571, 25, -36.3636363636, -100, -54.5454545455,
+		4, 57.1428571429, 100, 78.5714285714, 17.2413793103, -40.7407407407, -65.2173913043, -10.3448275862, 50,
+		100, 69.2307692308, 8.3333333333, -54.5454545455}
+	require.InDeltaSlice(t, expected, cmo[4:], 1e-6)
+
+	t.Run("flat", func(t *testing.T) {
+		df := &OHLC{Close: []float64{10, 10, 10, 10}}
+		require.Equal(t, []float64{0, 0}, df.CMO(2)[2:])
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		for _, value := range stcFixture().CMO(0) {
+			require.True(t, math.IsNaN(value))
+		}
+	})
+}
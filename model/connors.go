@@ -0,0 +1,71 @@
+package model
+
+import "math"
+
+// ConnorsRSI returns the Connors RSI of the close price, the average of three components:
+// the RSI of the close over rsiPeriod, the RSI of the up/down streak over streakPeriod and the percent rank
+// of the current return among the previous rankPeriod returns. The streak counts the consecutive closes
+// above (positive) or below (negative) the previous close, a flat close resets it to zero.
+// Values are bounded to [0, 100]. Warmup positions of the longest component
+// (max(rsiPeriod, streakPeriod, rankPeriod + 1)) are NaN.
+func (df *OHLC) ConnorsRSI(rsiPeriod, streakPeriod, rankPeriod int) []float64 {
+	result := make([]float64, len(df.Close))
+	for i := range result {
+		result[i] = math.NaN()
+	}
+
+	if rsiPeriod <= 0 || streakPeriod <= 0 || rankPeriod <= 0 {
+		return result
+	}
+
+	returns := make([]float64, len(df.Close))
+	for i := 1; i < len(df.Close); i++ {
+		if df.Close[i-1] != 0 {
+			returns[i] = (df.Close[i] - df.Close[i-1]) / df.Close[i-1]
+		}
+	}
+
+	closeRSI := wilderRSI(df.Close, rsiPeriod)
+	streakRSI := wilderRSI(streaks(df.Close), streakPeriod)
+	for i := rankPeriod + 1; i < len(result); i++ {
+		if math.IsNaN(closeRSI[i]) || math.IsNaN(streakRSI[i]) {
+			continue
+		}
+
+		var lower int
+		for _, value := range returns[i-rankPeriod : i] {
+			if value < returns[i] {
+				lower++
+			}
+		}
+		rank := float64(lower) / float64(rankPeriod) * 100
+
+		result[i] = math.Max(0, math.Min(100, (closeRSI[i]+streakRSI[i]+rank)/3))
+	}
+	return result
+}
+
+// streaks returns the number of consecutive values above (positive) or below (negative) the previous one,
+// an unchanged value resets the streak to zero
+func streaks(values []float64) []float64 {
+	result := make([]float64, len(values))
+	for i := 1; i < len(values); i++ {
+		switch {
+		case values[i] > values[i-1]:
+			result[i] = math.Max(result[i-1], 0) + 1
+		case values[i] < values[i-1]:
+			result[i] = math.Min(result[i-1], 0) - 1
+		}
+	}
+	return result
+}
+
+// wilderRSI returns the RSI of values with the Wilder smoothing, see RSIState. Warmup positions (period) are NaN.
+func wilderRSI(values []float64, period int) []float64 {
+	result := make([]float64, len(values))
+	state := NewRSIState(period)
+	for i, value := range values {
+		result[i] = state.Update(value)
+	}
+	return result
+}
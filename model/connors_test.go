@@ -0,0 +1,52 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func connorsFixture() *OHLC {
+	return &OHLC{
+		Close: []float64{44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08, 45.89, 46.03, 45.61,
+			46.28, 46.28, 46.00, 46.03, 46.41, 46.22, 45.64, 46.21, 46.25, 45.71, 46.45, 45.78, 45.35, 44.03, 44.18,
+			44.22, 44.57},
+	}
+}
+
+func TestOHLC_ConnorsRSI(t *testing.T) {
+	crsi := connorsFixture().ConnorsRSI(3, 2, 10)
+
+	// warmup of the percent rank, the longest component: 10 + 1
+	for _, value := range crsi[:11] {
+		require.True(t, math.IsNaN(value))
+	}
+
+	// reference values from the average of the Wilder RSI(3) of the close, RSI(2) of the streak
+	// and the percent rank of the return over 10 candles
+	expected := []float64{53.2110206821, 25.4959117251, 73.5702980605, 44.2595186973, 27.7903419395,
+		53.7540390897, 78.8287478932, 36.6161047336, 13.8937550474, 73.2628451927, 66.7870276097, 23.2924205345,
+		75.6456170866, 23.6885501335, 26.5928308782, 8.4749707408, 56.3539149815, 59.1762256819, 72.2238068909}
+	require.InDeltaSlice(t, expected, crsi[11:], 1e-9)
+
+	t.Run("bounded", func(t *testing.T) {
+		df := &OHLC{Close: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 15, 20, 30}}
+		for _, value := range df.ConnorsRSI(3, 2, 5)[6:] {
+			require.GreaterOrEqual(t, value, 0.0)
+			require.LessOrEqual(t, value, 100.0)
+		}
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		for _, value := range connorsFixture().ConnorsRSI(3, 0, 10) {
+			require.True(t, math.IsNaN(value))
+		}
+	})
+}
+
+func TestStreaks(t *testing.T) {
+	// flat closes reset the streak, the next move starts a new streak from one
+	values := []float64{10, 11, 12, 12, 13, 12, 11, 11, 10, 10, 10, 11}
+	require.Equal(t, []float64{0, 1, 2, 0, 1, -1, -2, 0, -1, 0, 0, 1}, streaks(values))
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrMissingConversion = errors.New("missing conversion rate")
+
+// ConversionRate returns the value of one unit of the asset in the base currency, from the prices by pair,
+// e.g. BTCUSDT. It uses the pair of the asset and the base currency, its inverse (e.g. USDTBRL for BRL to
+// USDT) or an intermediate currency, e.g. ETHBTC and BTCUSDT for ETH to USDT.
+func ConversionRate(asset, base string, prices map[string]float64) (float64, error) {
+	if asset == base {
+		return 1, nil
+	}
+
+	if rate, ok := directRate(asset, base, prices); ok {
+		return rate, nil
+	}
+
+	// sorted to choose the same intermediate currency in each call
+	pairs := make([]string, 0, len(prices))
+	for pair := range prices {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	for _, pair := range pairs {
+		if !strings.HasPrefix(pair, asset) || prices[pair] <= 0 {
+			continue
+		}
+
+		intermediate := strings.TrimPrefix(pair, asset)
+		if rate, ok := directRate(intermediate, base, prices); ok {
+			return prices[pair] * rate, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s to %s", ErrMissingConversion, asset, base)
+}
+
+// directRate returns the rate of the pair of the asset and the base currency, or of its inverse
+func directRate(asset, base string, prices map[string]float64) (float64, bool) {
+	if price, ok := prices[asset+base]; ok && price > 0 {
+		return price, true
+	}
+	if price, ok := prices[base+asset]; ok && price > 0 {
+		return 1 / price, true
+	}
+	return 0, false
+}
+
+// EquityIn returns the value of all balances in the base currency, e.g. USDT for an account with USDT and BTC
+// quoted pairs, converted with the prices by pair, see ConversionRate. Balances without a conversion rate
+// return ErrMissingConversion, instead of a partial value.
+func (a Account) EquityIn(base string, prices map[string]float64) (float64, error) {
+	var total float64
+	for _, balance := range a.Balances {
+		amount := balance.Free + balance.Lock
+		if amount == 0 {
+			continue
+		}
+
+		rate, err := ConversionRate(balance.Asset, base, prices)
+		if err != nil {
+			return 0, err
+		}
+		total += amount * rate
+	}
+	return total, nil
+}
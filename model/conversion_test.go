@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConversionRate(t *testing.T) {
+	prices := map[string]float64{
+		"BTCUSDT": 20000,
+		"ETHBTC":  0.05,
+		"USDTBRL": 5,
+	}
+
+	for asset, expected := range map[string]float64{
+		"USDT": 1,
+		"BTC":  20000,
+		"ETH":  1000,
+		"BRL":  0.2,
+	} {
+		rate, err := ConversionRate(asset, "USDT", prices)
+		require.NoError(t, err, asset)
+		require.InDelta(t, expected, rate, 1e-9, asset)
+	}
+
+	rate, err := ConversionRate("USDT", "BTC", prices)
+	require.NoError(t, err)
+	require.InDelta(t, 0.00005, rate, 1e-12)
+
+	_, err = ConversionRate("SOL", "USDT", prices)
+	require.ErrorIs(t, err, ErrMissingConversion)
+}
+
+func TestAccount_EquityIn(t *testing.T) {
+	// USDT and BTC quoted pairs: BTCUSDT and ETHBTC
+	account := Account{Balances: []Balance{
+		{Asset: "USDT", Free: 500, Lock: 100},
+		{Asset: "BTC", Free: 0.1},
+		{Asset: "ETH", Free: 2, Lock: 1},
+		{Asset: "SOL"},
+	}}
+	prices := map[string]float64{"BTCUSDT": 20000, "ETHBTC": 0.05}
+
+	equity, err := account.EquityIn("USDT", prices)
+	require.NoError(t, err)
+	require.InDelta(t, 600+0.1*20000+3*1000, equity, 1e-9)
+
+	equity, err = account.EquityIn("BTC", prices)
+	require.NoError(t, err)
+	require.InDelta(t, 600.0/20000+0.1+3*0.05, equity, 1e-9)
+
+	// the quote balances are summed without conversion by Equity
+	require.Equal(t, 603.1, account.Equity())
+
+	account.Balances[3].Free = 10
+	_, err = account.EquityIn("USDT", prices)
+	require.ErrorIs(t, err, ErrMissingConversion)
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// CrossPrice is the last price of a pair in several exchanges, by the name of the exchange, e.g. to monitor
+// arbitrage opportunities
+type CrossPrice struct {
+	Pair   string
+	Prices map[string]float64
+	// UpdatedAt is the time of the last candle received from each exchange
+	UpdatedAt map[string]time.Time
+}
+
+// Spread returns the difference between the price of the pair in the sell exchange and in the buy exchange,
+// and the difference relative to the buy price, e.g. 0.01 for 1%. ok is false when any price is missing.
+func (c CrossPrice) Spread(buy, sell string) (spread, percent float64, ok bool) {
+	buyPrice, ok := c.Prices[buy]
+	if !ok || buyPrice <= 0 {
+		return 0, 0, false
+	}
+
+	sellPrice, ok := c.Prices[sell]
+	if !ok {
+		return 0, 0, false
+	}
+
+	spread = sellPrice - buyPrice
+	return spread, spread / buyPrice, true
+}
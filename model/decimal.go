@@ -0,0 +1,74 @@
+package model
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DecimalPlaces is the number of fractional digits kept by Decimal, same precision used by exchanges for balances
+const DecimalPlaces = 8
+
+// Decimal is a fixed-point number with DecimalPlaces fractional digits and no integer size limit.
+// Values are rounded to DecimalPlaces when converted from float64, after that, additions and
+// subtractions are exact, so accumulating thousands of small amounts does not drift as float64 does.
+// The trade-off is performance: each operation allocates and is much slower than float64 arithmetic.
+type Decimal struct {
+	units *big.Int // value * 10^DecimalPlaces
+}
+
+// NewDecimal converts a float64 to Decimal, rounding to the nearest DecimalPlaces digit.
+// NaN and infinite values are converted to zero.
+func NewDecimal(value float64) Decimal {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Decimal{}
+	}
+
+	text := strings.Replace(strconv.FormatFloat(value, 'f', DecimalPlaces, 64), ".", "", 1)
+	units, ok := new(big.Int).SetString(text, 10)
+	if !ok {
+		return Decimal{}
+	}
+	return Decimal{units: units}
+}
+
+func (d Decimal) int() *big.Int {
+	if d.units == nil {
+		return new(big.Int)
+	}
+	return d.units
+}
+
+// Add returns d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{units: new(big.Int).Add(d.int(), other.int())}
+}
+
+// Sub returns d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{units: new(big.Int).Sub(d.int(), other.int())}
+}
+
+// Float64 returns the nearest float64 value of d
+func (d Decimal) Float64() float64 {
+	value, _ := strconv.ParseFloat(d.String(), 64)
+	return value
+}
+
+// String returns d formatted with DecimalPlaces fractional digits
+func (d Decimal) String() string {
+	units := d.int()
+	digits := new(big.Int).Abs(units).String()
+	if len(digits) <= DecimalPlaces {
+		digits = strings.Repeat("0", DecimalPlaces-len(digits)+1) + digits
+	}
+
+	sign := ""
+	if units.Sign() < 0 {
+		sign = "-"
+	}
+
+	point := len(digits) - DecimalPlaces
+	return sign + digits[:point] + "." + digits[point:]
+}
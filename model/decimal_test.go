@@ -0,0 +1,28 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDecimal(t *testing.T) {
+	require.Equal(t, "0.10000000", NewDecimal(0.1).String())
+	require.Equal(t, "-1.50000000", NewDecimal(-1.5).String())
+	require.Equal(t, "0.00000001", NewDecimal(0.000000014).String())
+	require.Equal(t, "12345678901234.00000000", NewDecimal(12345678901234).String())
+	require.Equal(t, "0.00000000", Decimal{}.String())
+}
+
+func TestDecimal_Add(t *testing.T) {
+	total := NewDecimal(0)
+	sum := 0.0
+	for i := 0; i < 10000; i++ {
+		total = total.Add(NewDecimal(0.1))
+		sum += 0.1
+	}
+
+	require.NotEqual(t, 1000.0, sum)
+	require.Equal(t, 1000.0, total.Float64())
+	require.Equal(t, -0.2, NewDecimal(0.1).Sub(NewDecimal(0.3)).Float64())
+}
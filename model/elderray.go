@@ -0,0 +1,35 @@
+package model
+
+import "math"
+
+// ElderRay returns the Elder Ray Index, bullPower = High - EMA(close) and bearPower = Low - EMA(close).
+// Warmup positions (emaPeriod - 1) are NaN.
+func (df *OHLC) ElderRay(emaPeriod int) (bullPower, bearPower []float64) {
+	ema := exponentialAverage(df.Close, emaPeriod)
+	bullPower, bearPower = make([]float64, len(df.Close)), make([]float64, len(df.Close))
+	for i := range df.Close {
+		bullPower[i] = df.High[i] - ema[i]
+		bearPower[i] = df.Low[i] - ema[i]
+	}
+	return bullPower, bearPower
+}
+
+// ElderRaySignals flags the classic Elder Ray entries:
+// buy when the EMA is rising and bear power is negative but rising,
+// sell when the EMA is falling and bull power is positive but falling.
+// Warmup positions (emaPeriod) are false.
+func (df *OHLC) ElderRaySignals(emaPeriod int) (buy, sell []bool) {
+	ema := exponentialAverage(df.Close, emaPeriod)
+	bullPower, bearPower := df.ElderRay(emaPeriod)
+
+	buy, sell = make([]bool, len(df.Close)), make([]bool, len(df.Close))
+	for i := 1; i < len(df.Close); i++ {
+		if math.IsNaN(ema[i-1]) {
+			continue
+		}
+
+		buy[i] = ema[i] > ema[i-1] && bearPower[i] < 0 && bearPower[i] > bearPower[i-1]
+		sell[i] = ema[i] < ema[i-1] && bullPower[i] > 0 && bullPower[i] < bullPower[i-1]
+	}
+	return buy, sell
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func elderRayFixture() *OHLC {
+	return &OHLC{
+		Close: []float64{10, 11, 12, 13, 14, 11, 10},
+		High:  []float64{10.5, 11.5, 12.5, 13.5, 15, 13, 11.5},
+		Low:   []float64{9.5, 10.5, 9.5, 9, 12.5, 10.5, 9.5},
+	}
+}
+
+func TestOHLC_ElderRay(t *testing.T) {
+	// EMA(3): NaN, NaN, 11, 12, 13, 12, 11
+	bullPower, bearPower := elderRayFixture().ElderRay(3)
+
+	require.True(t, math.IsNaN(bullPower[0]) && math.IsNaN(bullPower[1]))
+	require.True(t, math.IsNaN(bearPower[0]) && math.IsNaN(bearPower[1]))
+	require.Equal(t, []float64{1.5, 1.5, 2, 1, 0.5}, bullPower[2:])
+	require.Equal(t, []float64{-1.5, -3, -0.5, -1.5, -1.5}, bearPower[2:])
+}
+
+func TestOHLC_ElderRaySignals(t *testing.T) {
+	buy, sell := elderRayFixture().ElderRaySignals(3)
+
+	// rising EMA and bear power recovering from -3 to -0.5
+	require.Equal(t, []bool{false, false, false, false, true, false, false}, buy)
+	// falling EMA and bull power fading from 2 to 0.5
+	require.Equal(t, []bool{false, false, false, false, false, true, true}, sell)
+}
@@ -0,0 +1,41 @@
+package model
+
+import "math"
+
+// FisherTransform returns the Ehlers Fisher Transform of the median price (HL2) and its trigger, the fisher
+// line lagged by one candle. The price is normalized to [-1, 1] with the highest and lowest values of the
+// period, smoothed and clamped to ±0.999 to avoid the log blowup at the extremes.
+// Warmup positions (period - 1 for fisher, period for trigger) are NaN.
+func (df *OHLC) FisherTransform(period int) (fisher, trigger []float64) {
+	hl2 := df.HL2()
+	fisher, trigger = make([]float64, len(hl2)), make([]float64, len(hl2))
+	for i := range hl2 {
+		fisher[i], trigger[i] = math.NaN(), math.NaN()
+	}
+
+	if period <= 0 {
+		return fisher, trigger
+	}
+
+	var value, previous float64
+	for i := period - 1; i < len(hl2); i++ {
+		low, high := hl2[i], hl2[i]
+		for _, price := range hl2[i-period+1 : i+1] {
+			low, high = math.Min(low, price), math.Max(high, price)
+		}
+
+		// flat window, the price is in the middle of the range
+		var normalized float64
+		if high > low {
+			normalized = (hl2[i]-low)/(high-low) - 0.5
+		}
+
+		value = math.Max(-0.999, math.Min(0.999, 0.66*normalized+0.67*value))
+		fisher[i] = 0.5*math.Log((1+value)/(1-value)) + 0.5*previous
+		if i > period-1 {
+			trigger[i] = fisher[i-1]
+		}
+		previous = fisher[i]
+	}
+	return fisher, trigger
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fisherFixture builds candles with HL2 equal to the close price
+func fisherFixture(prices ...float64) *OHLC {
+	df := &OHLC{}
+	for _, price := range prices {
+		df.Close = append(df.Close, price)
+		df.High = append(df.High, price+0.5)
+		df.Low = append(df.Low, price-0.5)
+	}
+	return df
+}
+
+func TestOHLC_FisherTransform(t *testing.T) {
+	df := fisherFixture(10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 18, 17, 16, 15, 14, 13, 12)
+	fisher, trigger := df.FisherTransform(5)
+
+	for i := 0; i < 4; i++ {
+		require.True(t, math.IsNaN(fisher[i]))
+	}
+	for i := 0; i < 5; i++ {
+		require.True(t, math.IsNaN(trigger[i]))
+	}
+	require.Equal(t, fisher[4:len(fisher)-1], trigger[5:])
+
+	require.InDelta(t, 0.3428282544, fisher[4], 1e-9)
+	require.InDelta(t, 2.6125686014, fisher[9], 1e-9)
+	require.InDelta(t, -1.9804406528, fisher[16], 1e-9)
+
+	// rising in the uptrend, the top of the trend is the top of the fisher line
+	for i := 5; i <= 9; i++ {
+		require.Greater(t, fisher[i], trigger[i])
+	}
+
+	// sharp reversal: fisher crosses below the trigger on the first candle of the downtrend
+	// and changes the sign three candles later
+	for i := 10; i < len(fisher); i++ {
+		require.Less(t, fisher[i], trigger[i])
+	}
+	require.Greater(t, fisher[12], 0.0)
+	require.Less(t, fisher[13], 0.0)
+
+	t.Run("extremes", func(t *testing.T) {
+		prices := make([]float64, 0)
+		for i := 0; i < 100; i++ {
+			prices = append(prices, float64(10+i))
+		}
+		fisher, _ := fisherFixture(prices...).FisherTransform(5)
+		for _, value := range fisher[4:] {
+			require.False(t, math.IsInf(value, 0) || math.IsNaN(value))
+		}
+	})
+
+	t.Run("flat prices", func(t *testing.T) {
+		fisher, _ := fisherFixture(10, 10, 10, 10, 10, 10).FisherTransform(3)
+		require.Equal(t, []float64{0, 0, 0, 0}, fisher[2:])
+	})
+}
@@ -0,0 +1,20 @@
+package model
+
+// Gaps flags the candles whose open is more than thresholdPct (e.g. 0.02 for 2%) above (gapUp) or
+// below (gapDown) the previous close. Since crypto markets trade continuously, gaps are mainly
+// meaningful after interruptions in the data feed or on resampled higher timeframes.
+// The first candle is always false.
+func (df *OHLC) Gaps(thresholdPct float64) (gapUp, gapDown []bool) {
+	gapUp, gapDown = make([]bool, len(df.Close)), make([]bool, len(df.Close))
+	for i := 1; i < len(df.Close); i++ {
+		previous := df.Close[i-1]
+		if previous == 0 {
+			continue
+		}
+
+		change := (df.Open[i] - previous) / previous
+		gapUp[i] = change > thresholdPct
+		gapDown[i] = change < -thresholdPct
+	}
+	return gapUp, gapDown
+}
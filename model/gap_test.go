@@ -0,0 +1,22 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOHLC_Gaps(t *testing.T) {
+	df := &OHLC{
+		Open:  []float64{100, 100.5, 106, 106.2, 99, 99.1},
+		Close: []float64{100, 101, 106, 104, 99.5, 100},
+	}
+
+	gapUp, gapDown := df.Gaps(0.02)
+	require.Equal(t, []bool{false, false, true, false, false, false}, gapUp)
+	require.Equal(t, []bool{false, false, false, false, true, false}, gapDown)
+
+	gapUp, gapDown = df.Gaps(0.1)
+	require.Equal(t, make([]bool, 6), gapUp)
+	require.Equal(t, make([]bool, 6), gapDown)
+}
@@ -0,0 +1,97 @@
+package model
+
+import "math"
+
+// RollingHedgeRatio returns the rolling OLS slope (beta) of y on x over the window, i.e. the quantity of x that
+// hedges one unit of y, to build the spread y - beta * x of a pairs trade. The series are aligned by index and
+// the result has the length of the shortest one. Windows where x is constant have no slope and are NaN.
+// Warmup positions (window - 1) are NaN.
+func RollingHedgeRatio(y, x []float64, window int) []float64 {
+	result := make([]float64, int(math.Min(float64(len(y)), float64(len(x)))))
+	for i := range result {
+		result[i] = math.NaN()
+	}
+
+	if window <= 1 {
+		return result
+	}
+
+	for i := window - 1; i < len(result); i++ {
+		result[i], _ = regression(y[i-window+1:i+1], x[i-window+1:i+1])
+	}
+	return result
+}
+
+// SpreadZScore returns the z-score of the spread y - beta * x, with the hedge ratio of the rolling window, see
+// RollingHedgeRatio. It is the residual of the regression of the window divided by the standard deviation of
+// the residuals, a perfect fit is 0. Windows where x is constant and warmup positions (window - 1) are NaN.
+func SpreadZScore(y, x []float64, window int) []float64 {
+	result := make([]float64, int(math.Min(float64(len(y)), float64(len(x)))))
+	for i := range result {
+		result[i] = math.NaN()
+	}
+
+	if window <= 1 {
+		return result
+	}
+
+	for i := window - 1; i < len(result); i++ {
+		ys, xs := y[i-window+1:i+1], x[i-window+1:i+1]
+		beta, alpha := regression(ys, xs)
+		if math.IsNaN(beta) {
+			continue
+		}
+
+		var variance float64
+		for j := range ys {
+			residual := ys[j] - alpha - beta*xs[j]
+			variance += residual * residual
+		}
+		variance /= float64(window)
+
+		if variance == 0 {
+			result[i] = 0
+			continue
+		}
+		result[i] = (y[i] - alpha - beta*x[i]) / math.Sqrt(variance)
+	}
+	return result
+}
+
+// SpreadSignals flags the entries and exits of a pairs trade from the spread z-score, see SpreadZScore. Long
+// the spread (buy y and sell beta * x) when the z-score is below -entry, short the spread when it is above
+// entry, and exit when its absolute value is below exit, e.g. entry 2 and exit 0.5. Warmup positions are false.
+func SpreadSignals(zscore []float64, entry, exit float64) (long, short, flat []bool) {
+	long, short, flat = make([]bool, len(zscore)), make([]bool, len(zscore)), make([]bool, len(zscore))
+	for i, value := range zscore {
+		// comparisons with NaN are false
+		long[i] = value < -entry
+		short[i] = value > entry
+		flat[i] = math.Abs(value) < exit
+	}
+	return long, short, flat
+}
+
+// regression returns the OLS slope and intercept of y on x, the slope is NaN when x is constant
+func regression(y, x []float64) (slope, intercept float64) {
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(len(x))
+	meanY /= float64(len(y))
+
+	var covariance, variance float64
+	for i := range x {
+		covariance += (x[i] - meanX) * (y[i] - meanY)
+		variance += (x[i] - meanX) * (x[i] - meanX)
+	}
+
+	if variance == 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	slope = covariance / variance
+	return slope, meanY - slope*meanX
+}
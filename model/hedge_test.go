@@ -0,0 +1,66 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollingHedgeRatio(t *testing.T) {
+	y := []float64{2, 4, 5, 4, 6}
+	x := []float64{1, 2, 3, 4, 5}
+
+	// hand-computed slopes: cov(x, y) / var(x) of each window of 3
+	beta := RollingHedgeRatio(y, x, 3)
+	require.True(t, math.IsNaN(beta[0]))
+	require.True(t, math.IsNaN(beta[1]))
+	require.InDeltaSlice(t, []float64{1.5, 0, 0.5}, beta[2:], 1e-9)
+
+	t.Run("hedged spread", func(t *testing.T) {
+		// y = 2 * x + 10, the spread is constant
+		y, x := make([]float64, 10), make([]float64, 10)
+		for i := range x {
+			x[i] = float64(i*i) + 1
+			y[i] = 2*x[i] + 10
+		}
+		for _, value := range RollingHedgeRatio(y, x, 4)[3:] {
+			require.InDelta(t, 2, value, 1e-9)
+		}
+	})
+
+	t.Run("zero variance", func(t *testing.T) {
+		beta := RollingHedgeRatio([]float64{1, 2, 3, 4}, []float64{5, 5, 5, 6}, 3)
+		require.True(t, math.IsNaN(beta[2]))
+		require.InDelta(t, 1.5, beta[3], 1e-9)
+	})
+
+	t.Run("invalid window", func(t *testing.T) {
+		for _, value := range RollingHedgeRatio(y, x, 1) {
+			require.True(t, math.IsNaN(value))
+		}
+	})
+}
+
+func TestSpreadZScore(t *testing.T) {
+	y := []float64{2, 4, 5, 4, 6}
+	x := []float64{1, 2, 3, 4, 5}
+
+	// residuals of the last point over the standard deviation of the residuals of the window, e.g. in the
+	// first window: alpha = 2/3, residuals (-1/6, 1/3, -1/6) and deviation sqrt(1/18)
+	zscore := SpreadZScore(y, x, 3)
+	require.True(t, math.IsNaN(zscore[0]))
+	require.True(t, math.IsNaN(zscore[1]))
+	require.InDeltaSlice(t, []float64{-math.Sqrt(0.5), -math.Sqrt(0.5), math.Sqrt(0.5)}, zscore[2:], 1e-9)
+
+	t.Run("perfect fit", func(t *testing.T) {
+		require.Equal(t, 0.0, SpreadZScore([]float64{3, 5, 7}, []float64{1, 2, 3}, 3)[2])
+	})
+
+	t.Run("signals", func(t *testing.T) {
+		long, short, flat := SpreadSignals([]float64{math.NaN(), -2.5, -1, 0.2, 2.1}, 2, 0.5)
+		require.Equal(t, []bool{false, true, false, false, false}, long)
+		require.Equal(t, []bool{false, false, false, false, true}, short)
+		require.Equal(t, []bool{false, false, false, true, false}, flat)
+	})
+}
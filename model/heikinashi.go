@@ -0,0 +1,35 @@
+package model
+
+import "math"
+
+// HeikinAshiTrend classifies the trend of each candle with Heikin Ashi candles:
+// +1 for a bullish HA candle closing above the EMA of the HA close,
+// -1 for a bearish HA candle closing below it and 0 otherwise.
+// The dataframe is converted to Heikin Ashi unless it is already. Warmup positions (emaPeriod - 1) are 0.
+func (df *OHLC) HeikinAshiTrend(emaPeriod int) []int {
+	open, close := df.Open, df.Close
+	if !df.IsHeikinAshi {
+		ha := NewHeikinAshi()
+		open, close = make([]float64, len(df.Close)), make([]float64, len(df.Close))
+		for i := range df.Close {
+			candle := ha.CalculateHeikinAshi(Candle{Open: df.Open[i], High: df.High[i], Low: df.Low[i],
+				Close: df.Close[i]})
+			open[i], close[i] = candle.Open, candle.Close
+		}
+	}
+
+	ema := exponentialAverage(close, emaPeriod)
+	trend := make([]int, len(close))
+	for i := range close {
+		if math.IsNaN(ema[i]) {
+			continue
+		}
+
+		if close[i] > open[i] && close[i] > ema[i] {
+			trend[i] = 1
+		} else if close[i] < open[i] && close[i] < ema[i] {
+			trend[i] = -1
+		}
+	}
+	return trend
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOHLC_HeikinAshiTrend(t *testing.T) {
+	df := &OHLC{
+		Open:  []float64{10, 10, 11, 12, 13, 14, 15, 13, 11, 10, 10.5, 11.5, 13},
+		Close: []float64{10, 11, 12, 13, 14, 15, 13, 11, 10, 10.5, 11.5, 13, 15},
+		High:  []float64{10, 11, 12, 13, 14, 15, 15, 13, 11, 10.5, 11.5, 13, 15},
+		Low:   []float64{10, 10, 11, 12, 13, 14, 13, 11, 10, 10, 10.5, 11.5, 13},
+	}
+
+	// HA close 11 above HA open 10.97 but below EMA(4) 11.15 in the reversal
+	expected := []int{0, 0, 0, 1, 1, 1, 1, -1, -1, -1, 0, 1, 1}
+	require.Equal(t, expected, df.HeikinAshiTrend(4))
+
+	t.Run("heikin ashi dataframe", func(t *testing.T) {
+		ha := &OHLC{
+			Open:         []float64{10, 10, 10.25, 10.875, 11.6875},
+			Close:        []float64{10, 10.5, 11.5, 12.5, 11},
+			IsHeikinAshi: true,
+		}
+		require.Equal(t, []int{0, 0, 0, 1, -1}, ha.HeikinAshiTrend(4))
+	})
+}
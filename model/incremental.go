@@ -0,0 +1,122 @@
+package model
+
+import "math"
+
+// EMAState is the EMA updated one value at a time in O(1), seeded with the SMA of the first period values.
+// It is the implementation of the EMA of the indicators of this package and of indicator.NewEMAState.
+type EMAState struct {
+	period int
+	count  int
+	sum    float64
+	value  float64
+}
+
+func NewEMAState(period int) *EMAState {
+	return &EMAState{period: period}
+}
+
+// Update adds a value to the state and returns the EMA, NaN during the warmup
+func (e *EMAState) Update(value float64) float64 {
+	*e = e.next(value)
+	return e.result()
+}
+
+// Peek returns the EMA with the given value, without changing the state
+func (e EMAState) Peek(value float64) float64 {
+	next := e.next(value)
+	return next.result()
+}
+
+func (e EMAState) next(value float64) EMAState {
+	if e.period <= 0 {
+		return e
+	}
+
+	e.count++
+	if e.count < e.period {
+		e.sum += value
+		return e
+	}
+
+	if e.count == e.period {
+		e.value = (e.sum + value) / float64(e.period)
+		return e
+	}
+
+	k := 2 / float64(e.period+1)
+	e.value = (value-e.value)*k + e.value
+	return e
+}
+
+func (e EMAState) result() float64 {
+	if e.period <= 0 || e.count < e.period {
+		return math.NaN()
+	}
+	return e.value
+}
+
+// RSIState is the RSI updated one value at a time in O(1), with the Wilder smoothing seeded by the average of
+// the first period changes. The first value is available after period + 1 values. A window without losses is
+// 100 and a window without changes is 50. It is the implementation of the RSI of the indicators of this
+// package and of indicator.NewRSIState.
+type RSIState struct {
+	period   int
+	count    int
+	previous float64
+	gain     float64
+	loss     float64
+}
+
+func NewRSIState(period int) *RSIState {
+	return &RSIState{period: period}
+}
+
+// Update adds a value to the state and returns the RSI, NaN during the warmup
+func (r *RSIState) Update(value float64) float64 {
+	*r = r.next(value)
+	return r.result()
+}
+
+// Peek returns the RSI with the given value, without changing the state
+func (r RSIState) Peek(value float64) float64 {
+	next := r.next(value)
+	return next.result()
+}
+
+func (r RSIState) next(value float64) RSIState {
+	if r.period <= 0 {
+		return r
+	}
+
+	r.count++
+	if r.count == 1 {
+		r.previous = value
+		return r
+	}
+
+	change := value - r.previous
+	r.previous = value
+	up, down := math.Max(change, 0), math.Max(-change, 0)
+	if r.count <= r.period+1 {
+		r.gain += up / float64(r.period)
+		r.loss += down / float64(r.period)
+		return r
+	}
+
+	r.gain = (r.gain*float64(r.period-1) + up) / float64(r.period)
+	r.loss = (r.loss*float64(r.period-1) + down) / float64(r.period)
+	return r
+}
+
+func (r RSIState) result() float64 {
+	switch {
+	case r.period <= 0 || r.count <= r.period:
+		return math.NaN()
+	case r.gain == 0 && r.loss == 0:
+		return 50
+	case r.loss == 0:
+		return 100
+	default:
+		return 100 - 100/(1+r.gain/r.loss)
+	}
+}
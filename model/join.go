@@ -0,0 +1,45 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// TimeValue is a value of an external time series, e.g. a funding rate or an on-chain metric
+type TimeValue struct {
+	Time  time.Time
+	Value float64
+}
+
+// JoinSeries aligns an external time series to the candles and stores it in the metadata with the given key.
+// Each candle receives the latest value timestamped at or before the candle time, so series with a lower
+// frequency (e.g. daily values on hourly candles) are forward-filled and values timestamped after the candle
+// are never used. Since the candle time is the open time, a value published during a candle is only available
+// from the next candle. Candles before the first value are NaN. The values do not need to be sorted.
+func (df *Dataframe) JoinSeries(key string, values []TimeValue) {
+	sorted := make([]TimeValue, len(values))
+	copy(sorted, values)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	series := make(Series[float64], len(df.Time))
+	for i, candleTime := range df.Time {
+		// first value after the candle time
+		next := sort.Search(len(sorted), func(j int) bool {
+			return sorted[j].Time.After(candleTime)
+		})
+
+		if next == 0 {
+			series[i] = math.NaN()
+			continue
+		}
+		series[i] = sorted[next-1].Value
+	}
+
+	if df.Metadata == nil {
+		df.Metadata = make(map[string]Series[float64])
+	}
+	df.Metadata[key] = series
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataframe_JoinSeries(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	df := &Dataframe{Pair: "BTCUSDT"}
+	// hourly candles from 20:00 of the first day to 03:00 of the third day
+	for i := 20; i < 20+32; i++ {
+		df.Time = append(df.Time, start.Add(time.Duration(i)*time.Hour))
+		df.Close = append(df.Close, float64(i))
+	}
+
+	// sparse daily series, out of order and with a day missing
+	daily := []TimeValue{
+		{Time: start.Add(48 * time.Hour), Value: 3},
+		{Time: start, Value: 1},
+		{Time: start.Add(96 * time.Hour), Value: 5},
+		{Time: start.Add(-24 * time.Hour), Value: 0},
+	}
+	df.JoinSeries("metric", daily)
+
+	series := df.Metadata["metric"]
+	require.Len(t, series, len(df.Close))
+	for i, candleTime := range df.Time {
+		switch {
+		case candleTime.Before(start.Add(48 * time.Hour)):
+			// forward-filled through the second day, without a value
+			require.Equal(t, 1.0, series[i], candleTime)
+		default:
+			// value of the third day, the value of the fifth day is never used
+			require.Equal(t, 3.0, series[i], candleTime)
+		}
+	}
+
+	t.Run("before the first value", func(t *testing.T) {
+		df := &Dataframe{OHLC: OHLC{Time: []time.Time{start, start.Add(time.Hour), start.Add(2 * time.Hour)}}}
+		df.JoinSeries("metric", []TimeValue{{Time: start.Add(30 * time.Minute), Value: 7}})
+		require.True(t, math.IsNaN(df.Metadata["metric"][0]))
+		require.Equal(t, Series[float64]{7, 7}, df.Metadata["metric"][1:])
+	})
+}
@@ -13,9 +13,39 @@ type TelegramSettings struct {
 	Users   []int
 }
 
+// Mode defines the exchange where the bot orders are executed, the data feed is always the live exchange
+type Mode string
+
+const (
+	// ModeLive executes the orders in the exchange, it is the default mode
+	ModeLive Mode = "live"
+	// ModePaper simulates the orders in a paper wallet, initialized with the balances of the exchange account
+	ModePaper Mode = "paper"
+	// ModeDryRun only logs the orders, they are rejected without execution
+	ModeDryRun Mode = "dry-run"
+	// ModeSignal only emits the orders of the strategy as signals, e.g. to be executed manually, see
+	// NinjaBot.Signals. Orders are rejected without execution.
+	ModeSignal Mode = "signal"
+)
+
 type Settings struct {
-	Pairs    []string
-	Telegram TelegramSettings
+	Pairs []string
+	// DenyPairs are excluded from Pairs, the bot does not subscribe to or trade them
+	DenyPairs []string
+	Telegram  TelegramSettings
+	// MaxOpenOrders limits the simultaneously open orders per pair, zero means no limit
+	MaxOpenOrders int
+	// MinLiveCandles is the number of complete candles a pair must receive after the start, preloaded candles
+	// excluded, before any order. Zero uses the default of 2 candles and a negative value disables it.
+	// Backtests are not affected.
+	MinLiveCandles int
+	// Mode swaps the exchange implementation used for orders, see Mode. Empty means ModeLive.
+	Mode Mode
+	// BaseCurrency is the currency of the equity of pairs with different quote assets, e.g. USDT for USDT and
+	// BTC quoted pairs. ConversionPairs are the pairs, not traded, with the conversion rates, e.g. BTCUSDT.
+	// Empty means the quote balances are summed without conversion.
+	BaseCurrency    string
+	ConversionPairs []string
 }
 
 type Balance struct {
@@ -35,11 +65,77 @@ type AssetInfo struct {
 	MaxQuantity float64
 	StepSize    float64
 	TickSize    float64
+	MinNotional float64
 
 	QuotePrecision     int
 	BaseAssetPrecision int
 }
 
+// stepTolerance is the fraction of the step added before rounding down, to absorb float errors of the
+// division by the step, e.g. 0.3 / 0.1 = 2.9999999999999996
+const stepTolerance = 1e-9
+
+// defaultStepPrecision is used when the asset info has a step size without precision
+const defaultStepPrecision = 8
+
+// RoundQuantity rounds the quantity down to the step size and base precision of the asset, like the
+// exchanges. Without step size, the quantity is rounded to the precision only.
+func (a AssetInfo) RoundQuantity(quantity float64) float64 {
+	return roundToStep(quantity, a.StepSize, a.BaseAssetPrecision)
+}
+
+// RoundPrice rounds the price down to the tick size and quote precision of the asset, like the exchanges.
+// Without tick size, the price is rounded to the precision only.
+func (a AssetInfo) RoundPrice(price float64) float64 {
+	return roundToStep(price, a.TickSize, a.QuotePrecision)
+}
+
+func roundToStep(value, step float64, precision int) float64 {
+	if precision <= 0 {
+		if step <= 0 {
+			return value
+		}
+		precision = defaultStepPrecision
+	}
+
+	if step <= 0 {
+		step = math.Pow10(-precision)
+	}
+
+	scale := math.Pow10(precision)
+	return math.Trunc(math.Floor(value/step+stepTolerance)*step*scale) / scale
+}
+
+type BookLevel struct {
+	Price    float64
+	Quantity float64
+}
+
+// OrderBook is a snapshot of the market depth, bids sorted by descending price and asks by ascending price
+type OrderBook struct {
+	Pair string
+	Bids []BookLevel
+	Asks []BookLevel
+}
+
+// Mid returns the price between the best bid and the best ask, zero for a book without one of the sides
+func (o OrderBook) Mid() float64 {
+	if len(o.Bids) == 0 || len(o.Asks) == 0 {
+		return 0
+	}
+	return (o.Bids[0].Price + o.Asks[0].Price) / 2
+}
+
+// Spread returns the difference between the best ask and the best bid as a fraction of the mid price,
+// e.g. 0.001 for 0.1%. A book without one of the sides has an infinite spread.
+func (o OrderBook) Spread() float64 {
+	mid := o.Mid()
+	if mid <= 0 {
+		return math.Inf(1)
+	}
+	return (o.Asks[0].Price - o.Bids[0].Price) / mid
+}
+
 type Dataframe struct {
 	Pair string
 
@@ -78,6 +174,42 @@ func (df Dataframe) Sample(positions int) Dataframe {
 	return sample
 }
 
+// Clone returns a deep copy of the dataframe, the series and metadata of the copy can be changed without
+// changing the original dataframe
+func (df Dataframe) Clone() Dataframe {
+	clone := Dataframe{
+		Pair: df.Pair,
+		OHLC: OHLC{
+			Close:         cloneSlice(df.Close),
+			Open:          cloneSlice(df.Open),
+			High:          cloneSlice(df.High),
+			Low:           cloneSlice(df.Low),
+			Volume:        cloneSlice(df.Volume),
+			ChangePercent: cloneSlice(df.ChangePercent),
+			IsBullMarket:  cloneSlice(df.IsBullMarket),
+			Time:          cloneSlice(df.Time),
+			IsHeikinAshi:  df.IsHeikinAshi,
+		},
+		LastUpdate: df.LastUpdate,
+	}
+
+	if df.Metadata != nil {
+		clone.Metadata = make(map[string]Series[float64], len(df.Metadata))
+		for key, values := range df.Metadata {
+			clone.Metadata[key] = cloneSlice(values)
+		}
+	}
+	return clone
+}
+
+// cloneSlice copies the values of the slice, a nil slice is kept nil
+func cloneSlice[S ~[]T, T any](values S) S {
+	if values == nil {
+		return nil
+	}
+	return append(make(S, 0, len(values)), values...)
+}
+
 // OHLC is a connector for technical analysis usage
 type OHLC struct {
 	Close         Series[float64]
@@ -289,6 +421,13 @@ func (a Account) Equity() float64 {
 	return total
 }
 
+// EquitySnapshot is the net liquidation value of the account at a point of time, in the quote asset
+type EquitySnapshot struct {
+	ID    int64     `db:"id" json:"id" gorm:"primaryKey,autoIncrement"`
+	Time  time.Time `db:"time" json:"time" gorm:"index"`
+	Value float64   `db:"value" json:"value"`
+}
+
 func (ha *HeikinAshi) CalculateHeikinAshi(c Candle) Candle {
 	var hkCandle Candle
 
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -139,3 +140,63 @@ func TestDataframe_Sample(t *testing.T) {
 	sample.Metadata["test"] = []float64{10, 11, 12, 13, 14}
 	require.Equal(t, df.Metadata["test"], Series[float64]([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9}))
 }
+
+func TestDataframe_Clone(t *testing.T) {
+	now := time.Now()
+	df := Dataframe{
+		Pair: "BTCUSDT",
+		OHLC: OHLC{
+			Close:        []float64{1, 2, 3},
+			Open:         []float64{1, 2, 3},
+			High:         []float64{1, 2, 3},
+			Low:          []float64{1, 2, 3},
+			Volume:       []float64{1, 2, 3},
+			IsBullMarket: []bool{true, false, true},
+			Time:         []time.Time{now, now.Add(time.Minute), now.Add(2 * time.Minute)},
+		},
+		LastUpdate: now,
+		Metadata: map[string]Series[float64]{
+			"test": []float64{1, 2, 3},
+		},
+	}
+
+	clone := df.Clone()
+	require.Equal(t, df, clone)
+
+	// mutations of the clone must not mutate the original dataframe
+	clone.Close[0] = 10
+	clone.Open = append(clone.Open[:1], 20)
+	clone.High[1] = 30
+	clone.Low[2] = 40
+	clone.Volume[0] = 50
+	clone.IsBullMarket[0] = false
+	clone.Time[0] = now.Add(time.Hour)
+	clone.Metadata["test"][0] = 60
+	clone.Metadata["other"] = []float64{1}
+
+	require.Equal(t, Series[float64]{1, 2, 3}, df.Close)
+	require.Equal(t, Series[float64]{1, 2, 3}, df.Open)
+	require.Equal(t, Series[float64]{1, 2, 3}, df.High)
+	require.Equal(t, Series[float64]{1, 2, 3}, df.Low)
+	require.Equal(t, Series[float64]{1, 2, 3}, df.Volume)
+	require.Equal(t, []bool{true, false, true}, df.IsBullMarket)
+	require.Equal(t, now, df.Time[0])
+	require.Equal(t, map[string]Series[float64]{"test": {1, 2, 3}}, df.Metadata)
+
+	// nil series are kept nil
+	require.Nil(t, clone.ChangePercent)
+}
+
+func TestOrderBook_Spread(t *testing.T) {
+	book := OrderBook{
+		Pair: "BTCUSDT",
+		Bids: []BookLevel{{Price: 99, Quantity: 1}, {Price: 98, Quantity: 2}},
+		Asks: []BookLevel{{Price: 101, Quantity: 1}, {Price: 102, Quantity: 2}},
+	}
+	require.Equal(t, 100.0, book.Mid())
+	require.Equal(t, 0.02, book.Spread())
+
+	book.Asks = nil
+	require.Equal(t, 0.0, book.Mid())
+	require.True(t, math.IsInf(book.Spread(), 1))
+}
@@ -20,6 +20,7 @@ var (
 	OrderTypeStopLossLimit   OrderType = "STOP_LOSS_LIMIT"
 	OrderTypeTakeProfit      OrderType = "TAKE_PROFIT"
 	OrderTypeTakeProfitLimit OrderType = "TAKE_PROFIT_LIMIT"
+	OrderTypeIceberg         OrderType = "ICEBERG"
 
 	OrderStatusTypeNew             OrderStatusType = "NEW"
 	OrderStatusTypePartiallyFilled OrderStatusType = "PARTIALLY_FILLED"
@@ -40,6 +41,19 @@ type Order struct {
 	Price      float64         `db:"price" json:"price"`
 	Quantity   float64         `db:"quantity" json:"quantity"`
 
+	// ReduceOnly orders can only reduce the current position, never increase or flip it
+	ReduceOnly bool `db:"reduce_only" json:"reduce_only"`
+
+	// Strategy is the name of the sub-account that owns the order, empty for orders of the main account
+	Strategy string `db:"strategy" json:"strategy"`
+
+	// Execution costs in the quote asset, fees charged in the base asset are converted with the price
+	Fee      float64 `db:"fee" json:"fee"`
+	Slippage float64 `db:"slippage" json:"slippage"`
+	// AssetFee is the fee charged in another asset, FeeAsset, e.g. BNB in Binance. It is not included in Fee.
+	AssetFee float64 `db:"asset_fee" json:"asset_fee"`
+	FeeAsset string  `db:"fee_asset" json:"fee_asset"`
+
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
 
@@ -47,6 +61,9 @@ type Order struct {
 	Stop    *float64 `db:"stop" json:"stop"`
 	GroupID *int64   `db:"group_id" json:"group_id"`
 
+	// Iceberg orders only, the quantity of each slice visible in the order book
+	VisibleQuantity float64 `db:"visible_quantity" json:"visible_quantity"`
+
 	// Internal use (Plot)
 	RefPrice    float64 `json:"ref_price" gorm:"-"`
 	Profit      float64 `json:"profit" gorm:"-"`
@@ -54,6 +71,42 @@ type Order struct {
 	Candle      Candle  `json:"-" gorm:"-"`
 }
 
+// Signal is an order requested by a strategy in signal-only mode, it is not sent to the exchange
+type Signal struct {
+	Time time.Time `json:"time"`
+	Pair string    `json:"pair"`
+	Side SideType  `json:"side"`
+	Type OrderType `json:"type"`
+	// Size is the suggested quantity of the asset, orders by quote amount are converted with the price
+	Size float64 `json:"size"`
+	// Price is the limit price, or the close of the current candle in market and stop orders
+	Price float64 `json:"price"`
+	// Stop is the stop price of stop and OCO orders
+	Stop   float64 `json:"stop,omitempty"`
+	Reason string  `json:"reason"`
+	// Indicators are the last values of the indicators of the dataframe (Metadata) that triggered the signal
+	Indicators map[string]float64 `json:"indicators,omitempty"`
+}
+
+// OrderPreview is the estimated execution of an order that was not submitted, with the balances of the pair
+// after the fill
+type OrderPreview struct {
+	Pair     string
+	Side     SideType
+	Type     OrderType
+	Quantity float64
+	// Price is the estimated fill price, including the slippage of market orders
+	Price float64
+	// Value is the quantity multiplied by the fill price, in the quote asset
+	Value    float64
+	Fee      float64
+	Slippage float64
+
+	// resulting position size and quote balance, including the assets locked by other orders
+	AssetBalance float64
+	QuoteBalance float64
+}
+
 func (o Order) String() string {
 	return fmt.Sprintf("[%s] %s %s | ID: %d, Type: %s, %f x $%f (~$%.f)",
 		o.Status, o.Side, o.Pair, o.ID, o.Type, o.Quantity, o.Price, o.Quantity*o.Price)
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+// outlierWindow is the number of recent returns used to calculate the mean and standard deviation
+const outlierWindow = 20
+
+type OutlierAction int
+
+const (
+	// OutlierClip limits the prices of the outliers to zThreshold standard deviations of the recent returns
+	OutlierClip OutlierAction = iota
+	// OutlierDrop removes the outliers from all series
+	OutlierDrop
+)
+
+// Outliers returns the indices of the candles whose close return is beyond zThreshold standard deviations
+// of the previous returns, e.g. bad ticks with a spike to zero or 10x the price. Returns are calculated from
+// the last close that is not an outlier, so the candle after a spike, returning to the normal price, is not
+// flagged. Outliers are not included in the statistics of the next candles.
+// The first outlierWindow returns are not checked, neither windows with constant prices.
+func (df *OHLC) Outliers(zThreshold float64) []int {
+	indices, _ := df.outliers(zThreshold)
+	return indices
+}
+
+// outliers returns the outlier indices and the range of prices accepted for each of them
+func (df *OHLC) outliers(zThreshold float64) ([]int, map[int][2]float64) {
+	indices := make([]int, 0)
+	bounds := make(map[int][2]float64)
+	if len(df.Close) == 0 {
+		return indices, bounds
+	}
+
+	returns := make([]float64, 0, outlierWindow)
+	reference := df.Close[0]
+	for i := 1; i < len(df.Close); i++ {
+		if reference == 0 {
+			reference = df.Close[i]
+			continue
+		}
+
+		change := (df.Close[i] - reference) / reference
+		if len(returns) == outlierWindow {
+			var mean, variance float64
+			for _, value := range returns {
+				mean += value
+			}
+			mean /= float64(len(returns))
+			for _, value := range returns {
+				variance += (value - mean) * (value - mean)
+			}
+			std := math.Sqrt(variance / float64(len(returns)))
+
+			if std > 0 && math.Abs(change-mean) > zThreshold*std {
+				indices = append(indices, i)
+				bounds[i] = [2]float64{
+					reference * (1 + mean - zThreshold*std),
+					reference * (1 + mean + zThreshold*std),
+				}
+				continue
+			}
+			returns = returns[1:]
+		}
+
+		returns = append(returns, change)
+		reference = df.Close[i]
+	}
+	return indices, bounds
+}
+
+// CleanOutliers returns a copy of the dataframe with the outliers, see Outliers, clipped or dropped.
+// OutlierClip keeps the length of the series and limits the open, high, low and close of the outliers to
+// the accepted range. OutlierDrop removes the outliers from all series, so the indices after each dropped
+// candle are shifted back by the number of candles dropped before it. Time keeps the original timestamps,
+// it should be used to align the result with other series calculated from the original dataframe.
+func (df *OHLC) CleanOutliers(zThreshold float64, action OutlierAction) *OHLC {
+	indices, bounds := df.outliers(zThreshold)
+	clean := &OHLC{
+		Close:         append(Series[float64](nil), df.Close...),
+		Open:          append(Series[float64](nil), df.Open...),
+		High:          append(Series[float64](nil), df.High...),
+		Low:           append(Series[float64](nil), df.Low...),
+		Volume:        append(Series[float64](nil), df.Volume...),
+		ChangePercent: append(Series[float64](nil), df.ChangePercent...),
+		IsBullMarket:  append([]bool(nil), df.IsBullMarket...),
+		Time:          append([]time.Time(nil), df.Time...),
+		IsHeikinAshi:  df.IsHeikinAshi,
+	}
+
+	if action == OutlierDrop {
+		drop := make(map[int]bool, len(indices))
+		for _, i := range indices {
+			drop[i] = true
+		}
+
+		clean.Close = dropIndices(clean.Close, drop)
+		clean.Open = dropIndices(clean.Open, drop)
+		clean.High = dropIndices(clean.High, drop)
+		clean.Low = dropIndices(clean.Low, drop)
+		clean.Volume = dropIndices(clean.Volume, drop)
+		clean.ChangePercent = dropIndices(clean.ChangePercent, drop)
+		clean.IsBullMarket = dropIndices(clean.IsBullMarket, drop)
+		clean.Time = dropIndices(clean.Time, drop)
+		return clean
+	}
+
+	for _, i := range indices {
+		low, high := bounds[i][0], bounds[i][1]
+		for _, series := range []Series[float64]{clean.Close, clean.Open, clean.High, clean.Low} {
+			if i < len(series) {
+				series[i] = math.Max(low, math.Min(high, series[i]))
+			}
+		}
+
+		if i < len(clean.Open) && clean.Open[i] != 0 {
+			if i < len(clean.ChangePercent) {
+				clean.ChangePercent[i] = (clean.Close[i] - clean.Open[i]) / clean.Open[i]
+			}
+			if i < len(clean.IsBullMarket) {
+				clean.IsBullMarket[i] = clean.Close[i] > clean.Open[i]
+			}
+		}
+	}
+	return clean
+}
+
+// dropIndices returns the values without the given indices
+func dropIndices[T any](values []T, drop map[int]bool) []T {
+	result := make([]T, 0, len(values))
+	for i, value := range values {
+		if !drop[i] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
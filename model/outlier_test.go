@@ -0,0 +1,61 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func outlierFixture() *OHLC {
+	df := &OHLC{}
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		price := 100 + float64(i%3)
+		switch i {
+		case 25:
+			price = 0 // bad tick to zero
+		case 28:
+			price = 1000 // bad tick to 10x
+		}
+
+		df.Open = append(df.Open, price)
+		df.Close = append(df.Close, price)
+		df.High = append(df.High, price)
+		df.Low = append(df.Low, price)
+		df.Volume = append(df.Volume, float64(i))
+		df.Time = append(df.Time, start.Add(time.Duration(i)*time.Hour))
+	}
+	return df
+}
+
+func TestOHLC_Outliers(t *testing.T) {
+	df := outlierFixture()
+	require.Equal(t, []int{25, 28}, df.Outliers(3))
+
+	// constant prices are never flagged
+	require.Empty(t, (&OHLC{Close: make([]float64, 30)}).Outliers(3))
+
+	t.Run("clip", func(t *testing.T) {
+		clean := df.CleanOutliers(3, OutlierClip)
+		require.Len(t, clean.Close, 30)
+		// limited to 3 standard deviations of the returns, around 1.4%
+		require.InDelta(t, 95.73, clean.Close[25], 0.01)
+		require.InDelta(t, 104.40, clean.High[28], 0.01)
+		require.Equal(t, df.Close[:25], clean.Close[:25])
+
+		// the original dataframe is not changed
+		require.Equal(t, 0.0, df.Close[25])
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		clean := df.CleanOutliers(3, OutlierDrop)
+		require.Len(t, clean.Close, 28)
+		require.Len(t, clean.Time, 28)
+		require.Empty(t, clean.Outliers(3))
+
+		// indices after the outliers are shifted back
+		require.Equal(t, df.Time[26], clean.Time[25])
+		require.Equal(t, df.Volume[29], clean.Volume[27])
+	})
+}
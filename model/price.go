@@ -0,0 +1,45 @@
+package model
+
+// PriceSource is the price of the candle used as the input of an indicator
+type PriceSource string
+
+const (
+	PriceClose PriceSource = "close"
+	PriceOpen  PriceSource = "open"
+	PriceHigh  PriceSource = "high"
+	PriceLow   PriceSource = "low"
+	// PriceHL2 is (high + low) / 2
+	PriceHL2 PriceSource = "hl2"
+	// PriceHLC3 is (high + low + close) / 3, the typical price
+	PriceHLC3 PriceSource = "hlc3"
+	// PriceOHLC4 is (open + high + low + close) / 4
+	PriceOHLC4 PriceSource = "ohlc4"
+	// PriceHeikinAshi is the close of the Heikin Ashi candle
+	PriceHeikinAshi PriceSource = "heikin-ashi"
+)
+
+// Price returns the series of the price source, e.g. to compute an indicator with the typical price instead of
+// the close: indicator.RSI(df.Price(model.PriceHLC3), 14). The close is returned for an unknown source.
+// The series is a copy and can be changed without changing the dataframe.
+func (df *OHLC) Price(source PriceSource) []float64 {
+	switch source {
+	case PriceOpen:
+		return append([]float64{}, df.Open...)
+	case PriceHigh:
+		return append([]float64{}, df.High...)
+	case PriceLow:
+		return append([]float64{}, df.Low...)
+	case PriceHL2:
+		return append([]float64{}, df.HL2()...)
+	case PriceHLC3:
+		return append([]float64{}, df.HLC3()...)
+	case PriceOHLC4:
+		return append([]float64{}, df.OHLC4()...)
+	case PriceHeikinAshi:
+		// the Heikin Ashi close is the average of the original candle
+		if !df.IsHeikinAshi {
+			return append([]float64{}, df.OHLC4()...)
+		}
+	}
+	return append([]float64{}, df.Close...)
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOHLC_Price(t *testing.T) {
+	df := &OHLC{
+		Open:   []float64{10, 20},
+		High:   []float64{16, 26},
+		Low:    []float64{8, 17},
+		Close:  []float64{12, 21},
+		Volume: []float64{1, 1},
+		Time:   []time.Time{time.Now().Add(-time.Hour), time.Now()},
+	}
+
+	tt := []struct {
+		source   PriceSource
+		expected []float64
+	}{
+		{PriceClose, []float64{12, 21}},
+		{PriceOpen, []float64{10, 20}},
+		{PriceHigh, []float64{16, 26}},
+		{PriceLow, []float64{8, 17}},
+		{PriceHL2, []float64{12, 21.5}},
+		{PriceHLC3, []float64{12, 64.0 / 3}},
+		{PriceOHLC4, []float64{11.5, 21}},
+		{PriceHeikinAshi, []float64{11.5, 21}},
+		{"unknown", []float64{12, 21}},
+	}
+
+	for _, tc := range tt {
+		t.Run(string(tc.source), func(t *testing.T) {
+			require.InDeltaSlice(t, tc.expected, df.Price(tc.source), 1e-9)
+		})
+	}
+
+	t.Run("heikin ashi dataframe", func(t *testing.T) {
+		ha := df.ToHeikinAshi()
+		require.Equal(t, []float64(ha.Close), ha.Price(PriceHeikinAshi))
+		require.InDeltaSlice(t, ha.Close, df.Price(PriceHeikinAshi), 1e-9)
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		price := df.Price(PriceClose)
+		price[0] = 100
+		require.Equal(t, 12.0, df.Close[0])
+	})
+}
@@ -0,0 +1,29 @@
+package model
+
+import "math"
+
+// RelativeVolume returns the relative volume (RVOL): the volume of each candle divided by the average volume of
+// the previous period candles, e.g. 3 for three times the usual activity. A zero average volume has no
+// reference, the value is NaN. Warmup positions (the period) are NaN.
+func (df *OHLC) RelativeVolume(period int) []float64 {
+	result := make([]float64, len(df.Close))
+	for i := range result {
+		result[i] = math.NaN()
+	}
+
+	if period <= 0 {
+		return result
+	}
+
+	var sum float64
+	for i := 0; i < len(df.Close) && i < len(df.Volume); i++ {
+		if i >= period {
+			if average := sum / float64(period); average > 0 {
+				result[i] = df.Volume[i] / average
+			}
+			sum -= df.Volume[i-period]
+		}
+		sum += df.Volume[i]
+	}
+	return result
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOHLC_RelativeVolume(t *testing.T) {
+	df := &OHLC{
+		Close:  []float64{10, 10, 10, 10, 10, 10, 10, 10},
+		Volume: []float64{100, 120, 80, 100, 500, 100, 0, 50},
+	}
+
+	rvol := df.RelativeVolume(4)
+	require.Len(t, rvol, len(df.Close))
+	for i := 0; i < 4; i++ {
+		require.True(t, math.IsNaN(rvol[i]))
+	}
+
+	// spike of five times the average volume of 100
+	require.InDelta(t, 5.0, rvol[4], 1e-9)
+	require.Greater(t, rvol[4], 1.0)
+	require.InDelta(t, 100.0/200, rvol[5], 1e-9)
+	require.InDelta(t, 0.0, rvol[6], 1e-9)
+	require.InDelta(t, 50.0/175, rvol[7], 1e-9)
+
+	t.Run("zero average volume", func(t *testing.T) {
+		df := &OHLC{Close: []float64{10, 10, 10}, Volume: []float64{0, 0, 100}}
+		rvol := df.RelativeVolume(2)
+		require.True(t, math.IsNaN(rvol[2]))
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		for _, value := range df.RelativeVolume(0) {
+			require.True(t, math.IsNaN(value))
+		}
+	})
+}
@@ -1,6 +1,10 @@
 package model
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -49,6 +53,43 @@ func (s Series[T]) Cross(ref Series[T]) bool {
 	return s.Crossover(ref) || s.Crossunder(ref)
 }
 
+var ErrInvalidQuantile = errors.New("invalid quantile")
+
+// RollingQuantile returns the q-th quantile (0 <= q <= 1) of the values in a trailing window of the given period,
+// with linear interpolation between the ranks, e.g. q = 0.5 is the rolling median.
+// Warmup positions (period - 1) and windows with NaN values are NaN.
+func RollingQuantile(s Series[float64], period int, q float64) (Series[float64], error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("%w: period %d", ErrInvalidQuantile, period)
+	}
+
+	if math.IsNaN(q) || q < 0 || q > 1 {
+		return nil, fmt.Errorf("%w: %f out of [0, 1]", ErrInvalidQuantile, q)
+	}
+
+	result := make(Series[float64], len(s))
+	window := make([]float64, period)
+	for i := range s {
+		result[i] = math.NaN()
+		if i < period-1 {
+			continue
+		}
+
+		copy(window, s[i-period+1:i+1])
+		sort.Float64s(window)
+		// NaN values are sorted first
+		if math.IsNaN(window[0]) {
+			continue
+		}
+
+		rank := q * float64(period-1)
+		lower := int(math.Floor(rank))
+		upper := int(math.Ceil(rank))
+		result[i] = window[lower] + (rank-float64(lower))*(window[upper]-window[lower])
+	}
+	return result, nil
+}
+
 // NumDecPlaces returns the number of decimal places of a float64
 func NumDecPlaces(v float64) int64 {
 	s := strconv.FormatFloat(v, 'f', -1, 64)
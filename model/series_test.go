@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -61,3 +62,46 @@ func TestNumDecPlaces(t *testing.T) {
 		})
 	}
 }
+
+func TestRollingQuantile(t *testing.T) {
+	series := Series[float64]{5, 1, 4, 2, 3, 10, 6}
+
+	t.Run("median", func(t *testing.T) {
+		median, err := RollingQuantile(series, 4, 0.5)
+		require.NoError(t, err)
+		require.Len(t, median, len(series))
+		require.True(t, math.IsNaN(median[0]) && math.IsNaN(median[1]) && math.IsNaN(median[2]))
+		// windows sorted: [1 2 4 5], [1 2 3 4], [2 3 4 10], [2 3 6 10]
+		require.Equal(t, []float64{3, 2.5, 3.5, 4.5}, []float64(median[3:]))
+	})
+
+	t.Run("interpolation", func(t *testing.T) {
+		quantile, err := RollingQuantile(series, 5, 0.9)
+		require.NoError(t, err)
+		// window [2 3 4 6 10], rank 3.6 between 6 and 10
+		require.InDelta(t, 8.4, quantile.Last(0), 1e-9)
+
+		lowest, err := RollingQuantile(series, 5, 0)
+		require.NoError(t, err)
+		highest, err := RollingQuantile(series, 5, 1)
+		require.NoError(t, err)
+		require.Equal(t, 2.0, lowest.Last(0))
+		require.Equal(t, 10.0, highest.Last(0))
+	})
+
+	t.Run("nan values", func(t *testing.T) {
+		quantile, err := RollingQuantile(Series[float64]{1, math.NaN(), 3, 4}, 2, 0.5)
+		require.NoError(t, err)
+		require.True(t, math.IsNaN(quantile[1]) && math.IsNaN(quantile[2]))
+		require.Equal(t, 3.5, quantile[3])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := RollingQuantile(series, 3, 1.5)
+		require.ErrorIs(t, err, ErrInvalidQuantile)
+		_, err = RollingQuantile(series, 3, -0.1)
+		require.ErrorIs(t, err, ErrInvalidQuantile)
+		_, err = RollingQuantile(series, 0, 0.5)
+		require.ErrorIs(t, err, ErrInvalidQuantile)
+	})
+}
@@ -0,0 +1,73 @@
+package model
+
+import "math"
+
+// STC returns the Schaff Trend Cycle of the close price: the MACD line (EMA(fast) - EMA(slow)) passed
+// through two stochastics over the cycle period, each one smoothed with a factor of 0.5. Values are
+// bounded to [0, 100] and the previous value is kept when a stochastic window is flat.
+// Warmup positions (slow + 2 * (cycle - 1) - 1, with slow as the longest period) are NaN.
+func (df *OHLC) STC(fast, slow, cycle int) []float64 {
+	macd := make([]float64, len(df.Close))
+	if fast <= 0 || slow <= 0 || cycle <= 0 {
+		for i := range macd {
+			macd[i] = math.NaN()
+		}
+		return macd
+	}
+
+	fastEMA := exponentialAverage(df.Close, fast)
+	slowEMA := exponentialAverage(df.Close, slow)
+	for i := range macd {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+
+	return smoothedStochastic(smoothedStochastic(macd, cycle), cycle)
+}
+
+// STCSignals flags the crosses of the STC through the oversold and overbought levels, e.g. 25 and 75:
+// buy when it crosses up through the oversold level and sell when it crosses down through the overbought level.
+// Warmup positions are false.
+func (df *OHLC) STCSignals(fast, slow, cycle int, oversold, overbought float64) (buy, sell []bool) {
+	stc := df.STC(fast, slow, cycle)
+	buy, sell = make([]bool, len(stc)), make([]bool, len(stc))
+	for i := 1; i < len(stc); i++ {
+		// comparisons with NaN are false
+		buy[i] = stc[i-1] <= oversold && stc[i] > oversold
+		sell[i] = stc[i-1] >= overbought && stc[i] < overbought
+	}
+	return buy, sell
+}
+
+// smoothedStochastic returns the stochastic of values over the period, smoothed with a factor of 0.5.
+// Leading NaN values are skipped and positions without a full window are NaN.
+func smoothedStochastic(values []float64, period int) []float64 {
+	result := make([]float64, len(values))
+	for i := range result {
+		result[i] = math.NaN()
+	}
+
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+
+	var stochastic float64
+	for i := start + period - 1; i < len(values); i++ {
+		low, high := values[i], values[i]
+		for _, value := range values[i-period+1 : i+1] {
+			low, high = math.Min(low, value), math.Max(high, value)
+		}
+
+		// flat window, keep the previous value to avoid a division by zero
+		if high > low {
+			stochastic = (values[i] - low) / (high - low) * 100
+		}
+
+		if i == start+period-1 {
+			result[i] = stochastic
+			continue
+		}
+		result[i] = result[i-1] + 0.5*(stochastic-result[i-1])
+	}
+	return result
+}
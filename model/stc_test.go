@@ -0,0 +1,55 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func stcFixture() *OHLC {
+	return &OHLC{
+		Close: []float64{10, 10.5, 11.2, 11, 11.8, 12.5, 12.1, 11.6, 11, 10.4, 10.9, 11.7, 12.6, 13.4, 13.1, 12.2,
+			11.5, 11.9, 12.8, 13.6, 14.1, 13.7, 13.0, 12.4},
+	}
+}
+
+func TestOHLC_STC(t *testing.T) {
+	stc := stcFixture().STC(3, 6, 4)
+
+	// warmup: 6 + 2 * (4 - 1) - 1
+	for _, value := range stc[:11] {
+		require.True(t, math.IsNaN(value))
+	}
+
+	// reference values from the TradingView formula, with the EMA seeded by the SMA
+	expected := []float64{100, 100, 100, 98.3670310810, 49.1835155405, 24.5917577703, 12.2958788851,
+		52.9368041936, 76.4684020968, 88.2342010484, 86.0453852334, 43.0226926167, 21.5113463084}
+	require.InDeltaSlice(t, expected, stc[11:], 1e-6)
+
+	t.Run("flat prices", func(t *testing.T) {
+		df := &OHLC{Close: []float64{10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10}}
+		for _, value := range df.STC(3, 6, 4)[11:] {
+			require.Equal(t, 0.0, value)
+		}
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		for _, value := range stcFixture().STC(3, 6, 0) {
+			require.True(t, math.IsNaN(value))
+		}
+	})
+}
+
+func TestOHLC_STCSignals(t *testing.T) {
+	buy, sell := stcFixture().STCSignals(3, 6, 4, 25, 75)
+
+	expectedBuy, expectedSell := make([]bool, 24), make([]bool, 24)
+	// crosses up through 25, from 12.30 to 52.94
+	expectedBuy[18] = true
+	// crosses down through 75, from 98.37 to 49.18 and from 86.05 to 43.02
+	expectedSell[15], expectedSell[22] = true, true
+
+	require.Equal(t, expectedBuy, buy)
+	require.Equal(t, expectedSell, sell)
+}
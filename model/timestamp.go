@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// TimestampConvention is the moment of the period used as the time of the candles of a data source. The
+// candles of the framework are timestamped by the open time, candles of sources with other conventions must be
+// normalized, otherwise the indicators are shifted by one candle, a lookahead bug.
+type TimestampConvention string
+
+const (
+	// TimestampOpenTime timestamps the candles by the start of the period, e.g. 00:00 for the 1h candle from
+	// 00:00 to 01:00. It is the default.
+	TimestampOpenTime TimestampConvention = "open"
+	// TimestampCloseTime timestamps the candles by the end of the period, e.g. 01:00 for the 1h candle from
+	// 00:00 to 01:00
+	TimestampCloseTime TimestampConvention = "close"
+)
+
+// OpenTime returns the open time of a candle of the interval timestamped with the convention
+func (c TimestampConvention) OpenTime(t time.Time, interval time.Duration) time.Time {
+	if c == TimestampCloseTime {
+		return t.Add(-interval)
+	}
+	return t
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampConvention_OpenTime(t *testing.T) {
+	open := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, open, TimestampConvention("").OpenTime(open, time.Hour))
+	require.Equal(t, open, TimestampOpenTime.OpenTime(open, time.Hour))
+	require.Equal(t, open, TimestampCloseTime.OpenTime(open.Add(time.Hour), time.Hour))
+}
@@ -0,0 +1,36 @@
+package model
+
+import "math"
+
+// TRIX returns the one-period rate of change, in percent, of the triple-smoothed EMA of the close price,
+// each EMA seeded with the SMA of its first period. Warmup positions (3 * (period - 1) + 1) are NaN.
+func (df *OHLC) TRIX(period int) []float64 {
+	ema := exponentialAverage(exponentialAverage(exponentialAverage(df.Close, period), period), period)
+
+	result := make([]float64, len(df.Close))
+	for i := range result {
+		result[i] = math.NaN()
+	}
+
+	for i := 1; i < len(ema); i++ {
+		// NaN during the warmup
+		result[i] = (ema[i] - ema[i-1]) / ema[i-1] * 100
+	}
+	return result
+}
+
+// TRIXSignals flags the crosses of the TRIX with its signal line, the EMA of the TRIX over the signal period:
+// buy when the TRIX crosses above the signal line and sell when it crosses below.
+// Warmup positions (3 * (period - 1) + signal) are false.
+func (df *OHLC) TRIXSignals(period, signal int) (buy, sell []bool) {
+	trix := df.TRIX(period)
+	line := exponentialAverage(trix, signal)
+
+	buy, sell = make([]bool, len(trix)), make([]bool, len(trix))
+	for i := 1; i < len(trix); i++ {
+		// comparisons with NaN are false
+		buy[i] = trix[i-1] <= line[i-1] && trix[i] > line[i]
+		sell[i] = trix[i-1] >= line[i-1] && trix[i] < line[i]
+	}
+	return buy, sell
+}
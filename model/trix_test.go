@@ -0,0 +1,62 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOHLC_TRIX(t *testing.T) {
+	trix := stcFixture().TRIX(3)
+
+	// warmup: 3 * (3 - 1) + 1
+	for _, value := range trix[:7] {
+		require.True(t, math.IsNaN(value))
+	}
+
+	// reference values from the TA-Lib formula, with each EMA seeded by the SMA
+	expected := []float64{1.8788978632, 0.1709772674, -1.3709934944, -1.4330298363, -0.2107887244, 1.6029724111,
+		3.2213755842, 3.1907153342, 1.6274614356, -0.2176648310, -0.7432198129, 0.1652666549, 1.5984868851,
+		2.6492926382, 2.3495424925, 1.0689958162, -0.3735986174}
+	require.InDeltaSlice(t, expected, trix[7:], 1e-6)
+
+	t.Run("mean reverting", func(t *testing.T) {
+		df := &OHLC{}
+		for i := 0; i < 200; i++ {
+			df.Close = append(df.Close, 100+5*math.Sin(float64(i)/5))
+		}
+
+		var sum float64
+		var positive, negative int
+		for _, value := range df.TRIX(5)[13:] {
+			sum += value
+			if value > 0 {
+				positive++
+			} else {
+				negative++
+			}
+		}
+		require.InDelta(t, 0, sum/float64(len(df.Close)-13), 0.05)
+		require.Greater(t, positive, 50)
+		require.Greater(t, negative, 50)
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		for _, value := range stcFixture().TRIX(0) {
+			require.True(t, math.IsNaN(value))
+		}
+	})
+}
+
+func TestOHLC_TRIXSignals(t *testing.T) {
+	buy, sell := stcFixture().TRIXSignals(3, 3)
+
+	expectedBuy, expectedSell := make([]bool, 24), make([]bool, 24)
+	// crosses of the TRIX with the EMA(3) of the TRIX, available after position 9
+	expectedBuy[11], expectedBuy[18] = true, true
+	expectedSell[15], expectedSell[22] = true, true
+
+	require.Equal(t, expectedBuy, buy)
+	require.Equal(t, expectedSell, sell)
+}
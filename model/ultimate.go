@@ -0,0 +1,47 @@
+package model
+
+import "math"
+
+// UltimateOscillator returns the Ultimate Oscillator: the weighted average, 4/2/1, of the buying pressure
+// over the short, medium and long periods. The buying pressure of a candle is the close minus the true low,
+// the lower of the low and the previous close, and each period average is the sum of the buying pressures
+// divided by the sum of the true ranges. Values are bounded to [0, 100]. A period without true range, e.g.
+// flat candles, has an average of 0.5, the middle of the range. Warmup positions (the longest period) are NaN.
+func (df *OHLC) UltimateOscillator(short, medium, long int) []float64 {
+	result := make([]float64, len(df.Close))
+	for i := range result {
+		result[i] = math.NaN()
+	}
+
+	if short <= 0 || medium <= 0 || long <= 0 {
+		return result
+	}
+
+	// buying pressure and true range of each candle, from the second candle
+	pressure, trueRange := make([]float64, len(df.Close)), make([]float64, len(df.Close))
+	for i := 1; i < len(df.Close); i++ {
+		low := math.Min(df.Low[i], df.Close[i-1])
+		pressure[i] = df.Close[i] - low
+		trueRange[i] = math.Max(df.High[i], df.Close[i-1]) - low
+	}
+
+	average := func(i, period int) float64 {
+		var pressureSum, rangeSum float64
+		for j := i - period + 1; j <= i; j++ {
+			pressureSum += pressure[j]
+			rangeSum += trueRange[j]
+		}
+
+		if rangeSum == 0 {
+			return 0.5
+		}
+		return pressureSum / rangeSum
+	}
+
+	warmup := int(math.Max(float64(short), math.Max(float64(medium), float64(long))))
+	for i := warmup; i < len(df.Close); i++ {
+		value := 100 * (4*average(i, short) + 2*average(i, medium) + average(i, long)) / 7
+		result[i] = math.Max(0, math.Min(100, value))
+	}
+	return result
+}
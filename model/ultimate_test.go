@@ -0,0 +1,52 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOHLC_UltimateOscillator(t *testing.T) {
+	// 28 rising candles followed by 7 falling candles, with a range of 1 around the close
+	df := &OHLC{}
+	for i := 0; i < 36; i++ {
+		price := 100 + float64(i)
+		if i > 28 {
+			price = 128 - float64(i-28)
+		}
+		df.Close = append(df.Close, price)
+		df.High = append(df.High, price+0.5)
+		df.Low = append(df.Low, price-0.5)
+	}
+
+	uo := df.UltimateOscillator(7, 14, 28)
+	require.Len(t, uo, len(df.Close))
+	for i := 0; i < 28; i++ {
+		require.True(t, math.IsNaN(uo[i]))
+	}
+
+	// rising candles have a buying pressure of 1 in a true range of 1.5
+	require.InDelta(t, 100*2.0/3, uo[28], 1e-9)
+
+	// falling candles have a buying pressure of 0.5 in a true range of 1.5:
+	// 7 falling candles in the short period, 7 in the medium and 7 of 28 in the long
+	short, medium, long := 1.0/3, 10.5/21, 24.5/42
+	require.InDelta(t, 100*(4*short+2*medium+long)/7, uo[35], 1e-9)
+
+	for _, value := range uo[28:] {
+		require.True(t, value >= 0 && value <= 100)
+	}
+
+	t.Run("flat candles", func(t *testing.T) {
+		df := &OHLC{High: []float64{10, 10, 10}, Low: []float64{10, 10, 10}, Close: []float64{10, 10, 10}}
+		uo := df.UltimateOscillator(1, 1, 2)
+		require.Equal(t, 50.0, uo[2])
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		for _, value := range df.UltimateOscillator(0, 14, 28) {
+			require.True(t, math.IsNaN(value))
+		}
+	})
+}
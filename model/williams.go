@@ -0,0 +1,45 @@
+package model
+
+import "math"
+
+// WilliamsR returns the Williams %R: (highest high - close) / (highest high - lowest low) * -100 over the
+// period. Values are bounded to [-100, 0], 0 is a close at the highest high and -100 at the lowest low.
+// A flat range (highest high equal to the lowest low) is -50, the middle of the range.
+// Warmup positions (period - 1) are NaN.
+func (df *OHLC) WilliamsR(period int) []float64 {
+	result := make([]float64, len(df.Close))
+	for i := range result {
+		result[i] = math.NaN()
+	}
+
+	if period <= 0 {
+		return result
+	}
+
+	for i := period - 1; i < len(df.Close); i++ {
+		low, high := df.Low[i], df.High[i]
+		for j := i - period + 1; j <= i; j++ {
+			low, high = math.Min(low, df.Low[j]), math.Max(high, df.High[j])
+		}
+
+		if high == low {
+			result[i] = -50
+			continue
+		}
+		result[i] = math.Max(-100, math.Min(0, (high-df.Close[i])/(high-low)*-100))
+	}
+	return result
+}
+
+// WilliamsRZones flags the candles with the Williams %R in the overbought zone, above the overbought level
+// (e.g. -20), and in the oversold zone, below the oversold level (e.g. -80). Warmup positions are false.
+func (df *OHLC) WilliamsRZones(period int, overbought, oversold float64) (isOverbought, isOversold []bool) {
+	williamsR := df.WilliamsR(period)
+	isOverbought, isOversold = make([]bool, len(williamsR)), make([]bool, len(williamsR))
+	for i, value := range williamsR {
+		// comparisons with NaN are false
+		isOverbought[i] = value > overbought
+		isOversold[i] = value < oversold
+	}
+	return isOverbought, isOversold
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func williamsFixture() *OHLC {
+	return &OHLC{
+		High: []float64{127.01, 127.62, 126.59, 127.35, 128.17, 128.43, 127.37, 126.42, 126.90, 126.85, 125.65,
+			125.72, 127.16, 127.72, 127.69, 128.22, 128.27, 128.09, 128.27, 127.74, 128.77, 129.29, 130.06, 129.12,
+			129.29},
+		Low: []float64{125.36, 126.16, 124.93, 126.09, 126.82, 126.48, 126.03, 124.83, 126.39, 125.72, 124.56,
+			124.57, 125.07, 126.86, 126.63, 126.80, 126.71, 126.80, 126.13, 125.92, 126.99, 127.81, 128.47, 128.06,
+			127.61},
+		Close: []float64{126.19, 126.89, 125.76, 126.72, 127.50, 127.46, 126.70, 125.63, 126.65, 126.29, 125.11,
+			125.15, 126.12, 127.29, 127.18, 128.01, 127.11, 127.73, 127.06, 127.33, 128.71, 127.87, 128.58, 128.60,
+			127.93},
+	}
+}
+
+func TestOHLC_WilliamsR(t *testing.T) {
+	williamsR := williamsFixture().WilliamsR(14)
+
+	for _, value := range williamsR[:13] {
+		require.True(t, math.IsNaN(value))
+	}
+
+	// prices of the StockCharts Williams %R example, reference values calculated with the formula
+	expected := []float64{-29.4573643411, -32.2997416021, -10.8527131783, -34.1085271318, -18.0878552972,
+		-35.4005167959, -25.3369272237, -1.4251781473, -30.0211416490, -26.9090909091, -26.5454545455,
+		-38.7978142077}
+	require.InDeltaSlice(t, expected, williamsR[13:], 1e-9)
+
+	t.Run("bounds", func(t *testing.T) {
+		df := &OHLC{
+			High:  []float64{10, 11, 12, 12},
+			Low:   []float64{9, 10, 11, 9},
+			Close: []float64{9.5, 11, 12, 9},
+		}
+		require.Equal(t, []float64{0, 0, -100}, df.WilliamsR(2)[1:])
+	})
+
+	t.Run("flat range", func(t *testing.T) {
+		df := &OHLC{High: []float64{10, 10, 10}, Low: []float64{10, 10, 10}, Close: []float64{10, 10, 10}}
+		require.Equal(t, []float64{-50, -50}, df.WilliamsR(2)[1:])
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		for _, value := range williamsFixture().WilliamsR(0) {
+			require.True(t, math.IsNaN(value))
+		}
+	})
+}
+
+func TestOHLC_WilliamsRZones(t *testing.T) {
+	overbought, oversold := williamsFixture().WilliamsRZones(14, -20, -80)
+
+	expectedOverbought := make([]bool, 25)
+	// -10.85, -18.09 and -1.43
+	expectedOverbought[15], expectedOverbought[17], expectedOverbought[20] = true, true, true
+	require.Equal(t, expectedOverbought, overbought)
+	require.Equal(t, make([]bool, 25), oversold)
+
+	df := &OHLC{High: []float64{10, 11, 12}, Low: []float64{9, 10, 9}, Close: []float64{10, 10.5, 9.2}}
+	_, oversold = df.WilliamsRZones(2, -20, -80)
+	require.Equal(t, []bool{false, false, true}, oversold)
+}
@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aybabtme/uniplot/histogram"
 
@@ -23,7 +27,22 @@ import (
 	"github.com/schollz/progressbar/v3"
 )
 
-const defaultDatabase = "ninjabot.db"
+const (
+	defaultDatabase = "ninjabot.db"
+
+	// defaultMinLiveCandles is the number of live candles required before orders, see model.Settings
+	defaultMinLiveCandles = 2
+
+	// highProfitConcentration is the share of the profit from the top 5% of the trades reported as a warning
+	// in the summary, see order.ProfitConcentration
+	highProfitConcentration = 0.5
+
+	// defaultSignalsBuffer is the capacity of the signals channel, see NinjaBot.Signals
+	defaultSignalsBuffer = 100
+
+	// shutdownTimeout bounds the shutdown actions of each pair, see WithShutdownPolicy
+	shutdownTimeout = 30 * time.Second
+)
 
 func init() {
 	log.SetFormatter(&log.TextFormatter{
@@ -40,6 +59,11 @@ type CandleSubscriber interface {
 	OnCandle(model.Candle)
 }
 
+// SignalSubscriber receives the signals of the signal-only mode, e.g. plot.Chart
+type SignalSubscriber interface {
+	OnSignal(model.Signal)
+}
+
 type NinjaBot struct {
 	storage  storage.Storage
 	settings model.Settings
@@ -54,6 +78,46 @@ type NinjaBot struct {
 	orderFeed             *order.Feed
 	dataFeed              *exchange.DataFeedSubscription
 	paperWallet           *exchange.PaperWallet
+	logger                log.Logger
+	tradeLog              *strategy.TradeLog
+	minCandlesEntries     int
+	signalTiming          strategy.SignalTiming
+	lookaheadGuard        bool
+	cloneDataframe        bool
+	closedCandlesOnly     bool
+	strategyTimeout       time.Duration
+	dedupWindow           *time.Duration
+	candleGrace           *time.Duration
+	candleSources         *candleSources
+	crossFeed             *exchange.CrossExchangeFeed
+	minCandleVolume       *minCandleVolume
+	failoverFeed          *exchange.FailoverFeed
+	maxStrategyTimeouts   int
+	tradingCalendar       *strategy.TradingCalendar
+	warmup                *warmupMonitor
+	notificationRetry     *notificationRetry
+	chartOverlays         []string
+	notificationDebounce  time.Duration
+	pairsStateFile        string
+	maxResizes            int
+	confirmOrder          func(model.Order) bool
+	rollingWindow         int
+	maxSpread             map[string]float64
+	warmupTimeout         time.Duration
+	warmupSource          WarmupSource
+	equityHistory         *equityHistory
+	baseTimeframe         string
+	signals               chan model.Signal
+	signalWebhook         *notification.Webhook
+	signalSubscribers     []SignalSubscriber
+	shutdownPolicy        order.ShutdownPolicy
+	reconcile             bool
+	adoptOrders           bool
+	shutdownPolicies      map[string]order.ShutdownPolicy
+	maxOrderNotional      *maxOrderNotional
+	rebalance             *rebalance
+	rebalancer            *strategy.Rebalancer
+	summaryHeatmap        bool
 
 	backtest bool
 }
@@ -63,6 +127,9 @@ type Option func(*NinjaBot)
 func NewBot(ctx context.Context, settings model.Settings, exch service.Exchange, str strategy.Strategy,
 	options ...Option) (*NinjaBot, error) {
 
+	var excluded []string
+	settings.Pairs, excluded = filterDeniedPairs(settings.Pairs, settings.DenyPairs)
+
 	bot := &NinjaBot{
 		settings:              settings,
 		exchange:              exch,
@@ -71,6 +138,9 @@ func NewBot(ctx context.Context, settings model.Settings, exch service.Exchange,
 		dataFeed:              exchange.NewDataFeed(exch),
 		strategiesControllers: make(map[string]*strategy.Controller),
 		priorityQueueCandle:   model.NewPriorityQueue(nil),
+		logger:                log.Default(),
+		maxSpread:             make(map[string]float64),
+		shutdownPolicies:      make(map[string]order.ShutdownPolicy),
 	}
 
 	for _, pair := range settings.Pairs {
@@ -84,30 +154,180 @@ func NewBot(ctx context.Context, settings model.Settings, exch service.Exchange,
 		option(bot)
 	}
 
+	for _, pair := range excluded {
+		bot.logger.Info("Pair excluded by deny list", "pair", pair)
+	}
+
+	if settings.Mode == model.ModeSignal {
+		bot.signals = make(chan model.Signal, defaultSignalsBuffer)
+	}
+
+	// the feed is replaced keeping the subscriptions of the options
+	var feed service.Exchange = exch
+	if bot.candleSources != nil {
+		bot.failoverFeed = exchange.NewFailoverFeed(exch, bot.candleSources.timeout, bot.candleSources.sources...)
+		bot.failoverFeed.SetLogger(bot.logger)
+		feed = bot.failoverFeed
+		bot.dataFeed.SetExchange(feed)
+	}
+
+	if bot.baseTimeframe != "" {
+		bot.dataFeed.SetExchange(exchange.NewAggregatedFeed(feed, bot.baseTimeframe))
+	}
+
+	// orders are executed in the exchange of the mode, the data feed is always the given exchange
+	orderExchange := exch
+	if !bot.backtest {
+		var err error
+		orderExchange, bot.paperWallet, err = modeExchange(ctx, settings, exch, bot.paperWallet)
+		if err != nil {
+			return nil, err
+		}
+
+		if settings.Mode != "" && settings.Mode != model.ModeLive {
+			bot.logger.Info("[SETUP] Orders are not executed in the exchange", "mode", settings.Mode)
+		}
+	}
+
 	var err error
 	if bot.storage == nil {
-		bot.storage, err = storage.FromFile(defaultDatabase)
+		bot.storage, err = storage.FromFile(modeFile(defaultDatabase, settings.Mode))
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	bot.orderController = order.NewController(ctx, exch, bot.storage, bot.orderFeed)
+	bot.orderController = order.NewController(ctx, orderExchange, bot.storage, bot.orderFeed)
+	bot.orderController.SetLogger(bot.logger)
+	bot.orderController.SetDenyPairs(settings.DenyPairs)
+	bot.orderController.SetMaxOpenOrders(settings.MaxOpenOrders)
+	bot.orderController.SetAutoResize(bot.maxResizes)
+	bot.orderController.SetConfirmOrder(bot.confirmOrder)
+	bot.orderController.SetRollingWindow(bot.rollingWindow)
+	bot.orderController.SetOrderAdoption(bot.adoptOrders)
+	if settings.BaseCurrency != "" {
+		bot.orderController.SetBaseCurrency(settings.BaseCurrency, settings.ConversionPairs...)
+	}
+	if bot.equityHistory != nil {
+		err := bot.orderController.SetEquityHistory(bot.equityHistory.interval, bot.equityHistory.retention)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if bot.maxOrderNotional != nil {
+		bot.orderController.SetMaxOrderNotional(bot.maxOrderNotional.value, bot.maxOrderNotional.mode)
+	}
+	for pair, maxSpread := range bot.maxSpread {
+		bot.orderController.SetMaxSpread(pair, maxSpread)
+	}
+	if bot.rebalance != nil {
+		if !bot.backtest {
+			return nil, fmt.Errorf("rebalance is supported only in backtests")
+		}
+		bot.rebalancer = strategy.NewRebalancer(bot.orderController, settings.Pairs, bot.rebalance.score,
+			bot.rebalance.topN, bot.rebalance.schedule)
+	}
+	if bot.pairsStateFile != "" {
+		if err := bot.orderController.SetPairsStateFile(bot.pairsStateFile); err != nil {
+			return nil, err
+		}
+	}
+	bot.dataFeed.SetLogger(bot.logger)
+	if bot.dedupWindow != nil {
+		bot.dataFeed.SetDedupWindow(*bot.dedupWindow)
+	}
+	if bot.candleGrace != nil {
+		bot.dataFeed.SetCandleGrace(*bot.candleGrace)
+	}
 
 	if settings.Telegram.Enabled {
-		bot.telegram, err = notification.NewTelegram(bot.orderController, settings)
+		bot.telegram, err = notification.NewTelegram(bot.orderController, settings,
+			notification.WithChartSource(bot.chartDataframe, bot.chartOverlays...))
 		if err != nil {
 			return nil, err
 		}
 		// register telegram as notifier
-		WithNotifier(bot.telegram)(bot)
+		bot.notifier = bot.telegram
+	}
+
+	if bot.notifier != nil {
+		bot.notifier = bot.wrapNotifier(bot.notifier)
+		bot.orderController.SetNotifier(bot.notifier)
+		bot.SubscribeOrder(bot.notifier)
+	}
+
+	if bot.failoverFeed != nil && bot.notifier != nil {
+		bot.failoverFeed.SetNotifier(bot.notifier)
 	}
 
+	bot.setupStrategyControllers()
 	return bot, nil
 }
 
+// setupStrategyControllers creates the strategy controllers of the pairs with the options of the bot. The
+// controllers are created before the bot runs, so the readers of the map, e.g. Telegram, never see it change.
+func (n *NinjaBot) setupStrategyControllers() {
+	for _, pair := range n.settings.Pairs {
+		controller := strategy.NewStrategyController(pair, n.strategy, n.orderController)
+		if n.signals != nil {
+			controller.SetSignalOnly(n.emitSignal)
+		}
+		if minLiveCandles := n.minLiveCandles(); minLiveCandles > 0 {
+			controller.SetMinLiveCandles(minLiveCandles)
+		}
+		if n.minCandlesEntries > 0 {
+			controller.SetMinCandlesBetweenEntries(n.minCandlesEntries)
+		}
+		if n.minCandleVolume != nil {
+			controller.SetMinCandleVolume(n.minCandleVolume.volume, n.minCandleVolume.ratio,
+				n.minCandleVolume.period)
+		}
+		if n.tradingCalendar != nil {
+			controller.SetTradingCalendar(n.tradingCalendar)
+		}
+		if n.tradeLog != nil {
+			controller.SetTradeLog(n.tradeLog)
+		}
+		controller.SetSignalTiming(n.signalTiming)
+		controller.SetLookaheadGuard(n.lookaheadGuard)
+		controller.SetCloneDataframe(n.cloneDataframe)
+		controller.SetClosedCandlesOnly(n.closedCandlesOnly)
+		if n.strategyTimeout > 0 {
+			controller.SetTimeout(n.strategyTimeout, n.maxStrategyTimeouts)
+		}
+		if n.telegram != nil {
+			controller.SetDataframeSnapshot(true)
+		}
+		n.strategiesControllers[pair] = controller
+	}
+}
+
+// filterDeniedPairs removes the denied pairs from the list, returning the allowed and excluded pairs
+func filterDeniedPairs(pairs, denyPairs []string) (allowed, excluded []string) {
+	if len(denyPairs) == 0 {
+		return pairs, nil
+	}
+
+	denied := make(map[string]bool, len(denyPairs))
+	for _, pair := range denyPairs {
+		denied[pair] = true
+	}
+
+	allowed = make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		if denied[pair] {
+			excluded = append(excluded, pair)
+			continue
+		}
+		allowed = append(allowed, pair)
+	}
+	return allowed, excluded
+}
+
 // WithBacktest sets the bot to run in backtest mode, it is required for backtesting environments
 // Backtest mode optimize the input read for CSV and deal with race conditions
+// The pairs of the settings are a portfolio: their candles are merged in time order (see model.Candle.Less)
+// and the orders of all pairs share the balances of the wallet, with a single equity curve, see Result.Total
 func WithBacktest(wallet *exchange.PaperWallet) Option {
 	return func(bot *NinjaBot) {
 		bot.backtest = true
@@ -123,6 +343,183 @@ func WithStorage(storage storage.Storage) Option {
 	}
 }
 
+// WithAutoResize resubmits orders rejected by insufficient funds with the maximum affordable quantity,
+// up to the given number of resizes, see order.Controller.SetAutoResize
+func WithAutoResize(maxResizes int) Option {
+	return func(bot *NinjaBot) {
+		bot.maxResizes = maxResizes
+	}
+}
+
+// WithConfirmOrder executes the callback before each order is submitted, returning false aborts the order,
+// e.g. for custom risk checks, see order.Controller.SetConfirmOrder
+func WithConfirmOrder(confirm func(model.Order) bool) Option {
+	return func(bot *NinjaBot) {
+		bot.confirmOrder = confirm
+	}
+}
+
+// WithRollingStats sets the number of the last closed trades of the rolling win rate and expectancy, reported
+// in the status, see order.Controller.RollingStats. By default, all the trades are used.
+func WithRollingStats(trades int) Option {
+	return func(bot *NinjaBot) {
+		bot.rollingWindow = trades
+	}
+}
+
+// WithShutdownPolicy defines the actions on the orders and positions when the bot shuts down, i.e. when the
+// context of Run is canceled, see order.ShutdownPolicy. Without pairs, it is the policy of all pairs without
+// a specific one. By default, positions and orders are held for the next run. The actions of each pair are
+// limited to 30 seconds.
+func WithShutdownPolicy(policy order.ShutdownPolicy, pairs ...string) Option {
+	return func(bot *NinjaBot) {
+		if len(pairs) == 0 {
+			bot.shutdownPolicy = policy
+		}
+		for _, pair := range pairs {
+			bot.shutdownPolicies[pair] = policy
+		}
+	}
+}
+
+// WithStartupReconciliation synchronizes the orders and positions with the exchange before the bot starts,
+// e.g. orders filled or canceled while the bot was down, and notifies the differences,
+// see order.Controller.Reconcile
+func WithStartupReconciliation() Option {
+	return func(bot *NinjaBot) {
+		bot.reconcile = true
+	}
+}
+
+// WithOrderAdoption adopts the orders of the exchange not created by the bot in the startup reconciliation,
+// e.g. after the loss of the database, see WithStartupReconciliation and order.Controller.SetOrderAdoption
+func WithOrderAdoption() Option {
+	return func(bot *NinjaBot) {
+		bot.adoptOrders = true
+	}
+}
+
+// WithSummaryHeatmap prints the heatmap of the profit by weekday and hour of the entries in the Summary,
+// see order.TimeAttribution.HeatmapString
+func WithSummaryHeatmap() Option {
+	return func(bot *NinjaBot) {
+		bot.summaryHeatmap = true
+	}
+}
+
+// WithSignalWebhook posts the signals of the signal-only mode as JSON to the given URL, see model.Signal
+func WithSignalWebhook(url string) Option {
+	return func(bot *NinjaBot) {
+		bot.signalWebhook = notification.NewWebhook(url)
+	}
+}
+
+// WithBaseTimeframe subscribes to the candles of the given timeframe, e.g. 1m, and aggregates them locally into
+// the strategy timeframe, so a missed event of the exchange does not lose a whole candle, see
+// exchange.AggregatedFeed
+func WithBaseTimeframe(timeframe string) Option {
+	return func(bot *NinjaBot) {
+		bot.baseTimeframe = timeframe
+	}
+}
+
+type candleSources struct {
+	timeout time.Duration
+	sources []service.Feeder
+}
+
+// WithCandleSources adds fallback sources of candles to the exchange, in the order of priority. The feed fails
+// over to the next source when the active one does not deliver candles within the timeout, and fails back when
+// the source recovers, with a notification, see exchange.FailoverFeed
+func WithCandleSources(timeout time.Duration, sources ...service.Feeder) Option {
+	return func(bot *NinjaBot) {
+		bot.candleSources = &candleSources{timeout: timeout, sources: sources}
+	}
+}
+
+// WithDedupWindow sets the period in which the finalized candles of the live feed are remembered to suppress
+// the candles resent by the exchange, zero disables the deduplication, see exchange.DefaultDedupWindow
+func WithDedupWindow(window time.Duration) Option {
+	return func(bot *NinjaBot) {
+		bot.dedupWindow = &window
+	}
+}
+
+// WithCandleGrace sets the period a complete candle of the live feed is held before it is processed, to absorb
+// the last-tick revisions of the exchange, zero disables the grace period, see exchange.DefaultCandleGrace
+func WithCandleGrace(grace time.Duration) Option {
+	return func(bot *NinjaBot) {
+		bot.candleGrace = &grace
+	}
+}
+
+// WithCrossExchangeFeed delivers the prices of the pairs in several exchanges to strategies that implement
+// strategy.CrossExchangeStrategy, e.g. for arbitrage signals. The orders are still executed in the exchange
+// of the bot. It is not supported in backtests.
+func WithCrossExchangeFeed(feed *exchange.CrossExchangeFeed) Option {
+	return func(bot *NinjaBot) {
+		bot.crossFeed = feed
+	}
+}
+
+type equityHistory struct {
+	interval  time.Duration
+	retention time.Duration
+}
+
+// WithEquityHistory records the net liquidation value in the storage at most once per interval, keeping the
+// snapshots of the given retention (zero keeps all), see order.Controller.SetEquityHistory
+func WithEquityHistory(interval, retention time.Duration) Option {
+	return func(bot *NinjaBot) {
+		bot.equityHistory = &equityHistory{interval: interval, retention: retention}
+	}
+}
+
+// WithMaxSpread rejects market orders of the pair when the order book spread, as a fraction of the mid price,
+// exceeds the given value, see order.Controller.SetMaxSpread
+func WithMaxSpread(pair string, maxSpread float64) Option {
+	return func(bot *NinjaBot) {
+		bot.maxSpread[pair] = maxSpread
+	}
+}
+
+type maxOrderNotional struct {
+	value float64
+	mode  order.NotionalMode
+}
+
+// WithMaxOrderNotional limits the value of a single order in the quote asset, e.g. 5000 USDT, rejecting or
+// clamping the orders above it, see order.Controller.SetMaxOrderNotional
+func WithMaxOrderNotional(value float64, mode order.NotionalMode) Option {
+	return func(bot *NinjaBot) {
+		bot.maxOrderNotional = &maxOrderNotional{value: value, mode: mode}
+	}
+}
+
+type rebalance struct {
+	score    strategy.RebalanceScore
+	topN     int
+	schedule strategy.RebalanceSchedule
+}
+
+// WithRebalance allocates the capital of a backtest to the top N pairs by the score, rebalanced in the
+// schedule, e.g. strategy.RebalanceMonthly(), see strategy.Rebalancer. The orders of the strategy are still
+// executed, use a strategy without orders for a pure allocation backtest. The allocations and the turnover are
+// reported in the summary.
+func WithRebalance(score strategy.RebalanceScore, topN int, schedule strategy.RebalanceSchedule) Option {
+	return func(bot *NinjaBot) {
+		bot.rebalance = &rebalance{score: score, topN: topN, schedule: schedule}
+	}
+}
+
+// WithPairsStateFile sets the file that persists the pairs disabled at runtime, see NinjaBot.DisablePair.
+// By default, the disabled pairs are not persisted and are enabled again after a restart.
+func WithPairsStateFile(path string) Option {
+	return func(bot *NinjaBot) {
+		bot.pairsStateFile = path
+	}
+}
+
 // WithLogLevel sets the log level. eg: log.DebugLevel, log.InfoLevel, log.WarnLevel, log.ErrorLevel, log.FatalLevel
 func WithLogLevel(level log.Level) Option {
 	return func(_ *NinjaBot) {
@@ -130,15 +527,171 @@ func WithLogLevel(level log.Level) Option {
 	}
 }
 
-// WithNotifier registers a notifier to the bot, currently only email and telegram are supported
+// WithLogger sets a structured logger for the bot, data feed and order controller.
+// By default, messages are written with the package logger configured by WithLogLevel.
+func WithLogger(logger log.Logger) Option {
+	return func(bot *NinjaBot) {
+		bot.logger = logger
+	}
+}
+
+// WithTradeLog registers every order submitted by the strategy with its candle, indicator values, fill and
+// resulting balance in the given trade log, which can be exported to JSON or CSV after the execution.
+// It is heavy and intended for debugging backtests.
+func WithTradeLog(tradeLog *strategy.TradeLog) Option {
+	return func(bot *NinjaBot) {
+		bot.tradeLog = tradeLog
+		bot.SubscribeOrder(tradeLog)
+	}
+}
+
+// WithMinCandlesBetweenEntries blocks strategy entries in the same direction of the last entry on a pair
+// until the given number of candles is closed. Exits and opposite entries are not affected.
+func WithMinCandlesBetweenEntries(candles int) Option {
+	return func(bot *NinjaBot) {
+		bot.minCandlesEntries = candles
+	}
+}
+
+type minCandleVolume struct {
+	volume float64
+	ratio  float64
+	period int
+}
+
+// WithMinCandleVolume blocks strategy entries when the volume of the current candle is below minVolume, or
+// below minRatio of the average volume of the previous period candles, e.g. 0.5 for half of the average.
+// Exits are not affected, a zero minimum disables its check, see strategy.Controller.SetMinCandleVolume
+func WithMinCandleVolume(minVolume, minRatio float64, period int) Option {
+	return func(bot *NinjaBot) {
+		bot.minCandleVolume = &minCandleVolume{volume: minVolume, ratio: minRatio, period: period}
+	}
+}
+
+// WithLookaheadGuard enables a strict mode to catch lookahead bugs in the strategy, mainly for backtests.
+// The backtest panics with strategy.ErrLookahead when the strategy reads the dataframe beyond the current
+// candle or computes indicators with more values than the candles available.
+func WithLookaheadGuard() Option {
+	return func(bot *NinjaBot) {
+		bot.lookaheadGuard = true
+	}
+}
+
+// WithDataframeClone delivers a deep copy of the dataframe to each execution of the strategy, so a strategy
+// that changes the series does not corrupt the candles of the next executions,
+// see strategy.Controller.SetCloneDataframe
+func WithDataframeClone() Option {
+	return func(bot *NinjaBot) {
+		bot.cloneDataframe = true
+	}
+}
+
+// WithStrategyTimeout limits the execution time of the strategy OnCandle, a blocked strategy skips the candle
+// instead of stalling the bot. The strategy of the pair is disabled after maxTimeouts consecutive timeouts,
+// zero never disables it, see strategy.Controller.SetTimeout
+func WithStrategyTimeout(timeout time.Duration, maxTimeouts int) Option {
+	return func(bot *NinjaBot) {
+		bot.strategyTimeout = timeout
+		bot.maxStrategyTimeouts = maxTimeouts
+	}
+}
+
+// WithClosedCandlesOnly executes the strategy only with complete candles, partial candles just update the
+// dataframe and OnPartialCandle is never executed, see strategy.Controller.SetClosedCandlesOnly
+func WithClosedCandlesOnly() Option {
+	return func(bot *NinjaBot) {
+		bot.closedCandlesOnly = true
+	}
+}
+
+// WithTradingCalendar blocks the strategy orders outside the windows of the calendar, e.g. backtests of
+// equities or FX with CSV data, see strategy.Controller.SetTradingCalendar
+func WithTradingCalendar(calendar *strategy.TradingCalendar) Option {
+	return func(bot *NinjaBot) {
+		bot.tradingCalendar = calendar
+	}
+}
+
+// WithSignalTiming defines when the strategy OnCandle is executed. With strategy.SignalOnOpen, the signals
+// are evaluated on the open of the next candle with the indicators of the closed candle, see strategy.SignalTiming.
+func WithSignalTiming(timing strategy.SignalTiming) Option {
+	return func(bot *NinjaBot) {
+		bot.signalTiming = timing
+	}
+}
+
+// WithWarmupTimeout sends a warning when the dataframes of the pairs do not reach the strategy warmup period
+// within the timeout after the start of a live bot, e.g. a problem in the data feed. By default, there is no timeout.
+// The warmup completion is always notified once.
+func WithWarmupTimeout(timeout time.Duration) Option {
+	return func(bot *NinjaBot) {
+		bot.warmupTimeout = timeout
+	}
+}
+
+// WithWarmupSource selects where the warmup candles are read at startup, see WarmupSource. By default, they are
+// downloaded from the exchange. The storage sources require a storage with candles, see storage.CandleStorage.
+func WithWarmupSource(source WarmupSource) Option {
+	return func(bot *NinjaBot) {
+		bot.warmupSource = source
+	}
+}
+
+type notificationRetry struct {
+	policy    notification.RetryPolicy
+	fallbacks []notification.Sender
+}
+
+// WithNotificationRetry retries the notifications of the bot with the given policy and falls back to the
+// given channels, e.g. notification.Mail, when the retries are exhausted. Undelivered order and error
+// notifications are queued until a channel is available, see notification.Retry. The notifier must report the
+// delivery errors with notification.Sender, like Telegram and notification.Mail, otherwise it is not retried.
+func WithNotificationRetry(policy notification.RetryPolicy, fallbacks ...notification.Sender) Option {
+	return func(bot *NinjaBot) {
+		bot.notificationRetry = &notificationRetry{policy: policy, fallbacks: fallbacks}
+	}
+}
+
+// WithNotificationDebounce consolidates the entry fills of a pair and side within the window in a single
+// notification, with the total quantity and the weighted-average price, see notification.Debounce.
+func WithNotificationDebounce(window time.Duration) Option {
+	return func(bot *NinjaBot) {
+		bot.notificationDebounce = window
+	}
+}
+
+// WithChartOverlays draws the indicators of the dataframe metadata over the candles of the Telegram `/chart`
+// command, e.g. the names of moving averages set in the Indicators of the strategy
+func WithChartOverlays(names ...string) Option {
+	return func(bot *NinjaBot) {
+		bot.chartOverlays = names
+	}
+}
+
+// WithNotifier registers a notifier to the bot, e.g. notification.Mail. It is replaced by Telegram when it
+// is enabled in the settings.
 func WithNotifier(notifier service.Notifier) Option {
 	return func(bot *NinjaBot) {
 		bot.notifier = notifier
-		bot.orderController.SetNotifier(notifier)
-		bot.SubscribeOrder(notifier)
 	}
 }
 
+// wrapNotifier applies the retry and debounce options to the notifier of the bot
+func (n *NinjaBot) wrapNotifier(notifier service.Notifier) service.Notifier {
+	if n.notificationRetry != nil {
+		if sender, ok := notifier.(notification.Sender); ok {
+			senders := append([]notification.Sender{sender}, n.notificationRetry.fallbacks...)
+			notifier = notification.NewRetry(senders, notification.WithRetryPolicy(n.notificationRetry.policy))
+		} else {
+			n.logger.Warn("[SETUP] Notification retry ignored, the notifier does not report delivery errors")
+		}
+	}
+	if n.notificationDebounce > 0 {
+		notifier = notification.NewDebounce(notifier, n.notificationDebounce)
+	}
+	return notifier
+}
+
 // WithCandleSubscription subscribes a given struct to the candle feed
 func WithCandleSubscription(subscriber CandleSubscriber) Option {
 	return func(bot *NinjaBot) {
@@ -146,6 +699,15 @@ func WithCandleSubscription(subscriber CandleSubscriber) Option {
 	}
 }
 
+// WithBufferedCandleSubscription subscribes a given struct to the candle feed through a bounded buffer,
+// so a slow subscriber does not hold the feed. See exchange.CandleBuffer for the overflow policies.
+func WithBufferedCandleSubscription(subscriber CandleSubscriber, capacity int,
+	options ...exchange.CandleBufferOption) Option {
+	return func(bot *NinjaBot) {
+		bot.SubscribeCandleBuffered(subscriber, capacity, options...)
+	}
+}
+
 // WithPaperWallet sets the paper wallet for the bot (used for backtesting and live simulation)
 func WithPaperWallet(wallet *exchange.PaperWallet) Option {
 	return func(bot *NinjaBot) {
@@ -161,6 +723,27 @@ func (n *NinjaBot) SubscribeCandle(subscriptions ...CandleSubscriber) {
 	}
 }
 
+// SubscribeCandleBuffered subscribes a given struct to the candle feed of each pair through a bounded buffer.
+// The returned buffers expose the queue depth and the number of dropped candles.
+func (n *NinjaBot) SubscribeCandleBuffered(subscriber CandleSubscriber, capacity int,
+	options ...exchange.CandleBufferOption) []*exchange.CandleBuffer {
+
+	buffers := make([]*exchange.CandleBuffer, 0, len(n.settings.Pairs))
+	for _, pair := range n.settings.Pairs {
+		buffer := n.dataFeed.SubscribeBuffered(pair, n.strategy.Timeframe(), subscriber.OnCandle, false,
+			capacity, options...)
+		buffers = append(buffers, buffer)
+	}
+	return buffers
+}
+
+// WithSignalSubscription subscribes a given struct to the signals of the signal-only mode (model.ModeSignal)
+func WithSignalSubscription(subscriber SignalSubscriber) Option {
+	return func(bot *NinjaBot) {
+		bot.signalSubscribers = append(bot.signalSubscribers, subscriber)
+	}
+}
+
 func WithOrderSubscription(subscriber OrderSubscriber) Option {
 	return func(bot *NinjaBot) {
 		bot.SubscribeOrder(subscriber)
@@ -175,10 +758,77 @@ func (n *NinjaBot) SubscribeOrder(subscriptions ...OrderSubscriber) {
 	}
 }
 
+// CandlesUntilEntry returns how many candles remain until a new entry in the given side
+// is allowed for the pair, see WithMinCandlesBetweenEntries
+func (n *NinjaBot) CandlesUntilEntry(pair string, side model.SideType) int {
+	controller, ok := n.strategiesControllers[pair]
+	if !ok {
+		return 0
+	}
+	return controller.CandlesUntilEntry(side)
+}
+
+// VolumeRatio returns the ratio of the volume of the current candle of the pair to the average volume of the
+// previous candles, NaN when it is not available, see WithMinCandleVolume
+func (n *NinjaBot) VolumeRatio(pair string) float64 {
+	controller, ok := n.strategiesControllers[pair]
+	if !ok {
+		return math.NaN()
+	}
+	return controller.VolumeRatio()
+}
+
+// DisablePair blocks new entries on the pair, exits and the management of open positions continue.
+// The candle feed is kept, so the indicators are ready when the pair is enabled again.
+// The state is persisted across restarts with WithPairsStateFile.
+func (n *NinjaBot) DisablePair(pair string) error {
+	return n.orderController.DisablePair(pair)
+}
+
+// EnablePair allows new entries on a pair disabled with DisablePair
+func (n *NinjaBot) EnablePair(pair string) error {
+	return n.orderController.EnablePair(pair)
+}
+
+// CleanDust sells the leftover balances of the base assets of the pairs worth less than the threshold in the
+// quote asset, e.g. 1 for balances under 1 USDT. Balances below the minimum notional of the exchange can not
+// be sold, they are logged, notified and returned in the report, see order.Controller.CleanDust
+func (n *NinjaBot) CleanDust(threshold float64) (order.DustReport, error) {
+	report, err := n.orderController.CleanDust(threshold, n.settings.Pairs...)
+	if err != nil {
+		return report, err
+	}
+
+	if len(report.Errors) > 0 {
+		return report, fmt.Errorf("clean dust: %d of %d orders failed: %w", len(report.Errors),
+			len(report.Sold)+len(report.Errors), report.Errors[0])
+	}
+	return report, nil
+}
+
+// RollingStats returns the win rate and expectancy of the last closed trades, see WithRollingStats
+func (n *NinjaBot) RollingStats() order.RollingStats {
+	return n.orderController.RollingStats()
+}
+
+// Rebalancer returns the rebalancer of WithRebalance, nil without it
+func (n *NinjaBot) Rebalancer() *strategy.Rebalancer {
+	return n.rebalancer
+}
+
 func (n *NinjaBot) Controller() *order.Controller {
 	return n.orderController
 }
 
+// chartDataframe returns a copy of the last candles of the dataframe of the pair, see WithChartOverlays
+func (n *NinjaBot) chartDataframe(pair string, candles int) (model.Dataframe, bool) {
+	controller, ok := n.strategiesControllers[pair]
+	if !ok {
+		return model.Dataframe{}, false
+	}
+	return controller.Dataframe(candles), true
+}
+
 // Summary function displays all trades, accuracy and some bot metrics in stdout
 // To access the raw data, you may access `bot.Controller().Results`
 func (n *NinjaBot) Summary() {
@@ -267,12 +917,76 @@ func (n *NinjaBot) Summary() {
 
 	fmt.Println()
 
+	trades := make([]order.Result, 0)
+	for _, summary := range n.orderController.Results {
+		trades = append(trades, summary.Trades...)
+	}
+
+	if len(trades) > 0 {
+		attribution := order.NewTimeAttribution(trades)
+		fmt.Println("------ PROFIT BY ENTRY TIME (UTC) -------")
+		printTimeBuckets("Hour", attribution.Hour[:])
+		printTimeBuckets("Weekday", attribution.Weekday[:])
+		printTimeBuckets("Month", attribution.Month[:])
+		if n.summaryHeatmap {
+			fmt.Println(attribution.HeatmapString())
+		}
+
+		concentration := order.NewProfitConcentration(trades, order.TopTradesFraction)
+		fmt.Println("------ PROFIT CONCENTRATION -------")
+		fmt.Printf("TOP %.0f%% TRADES: %d of %d (%.1f%% of the profit)\n", order.TopTradesFraction*100,
+			concentration.TopTrades, concentration.Trades, concentration.TopShare*100)
+		fmt.Printf("GINI (WINS):   %.2f\n", concentration.Gini)
+		if concentration.TopShare > highProfitConcentration {
+			fmt.Println("WARNING: the profit depends on a few trades, check for overfitting or rare events")
+		}
+		fmt.Println()
+	}
+
+	if n.rebalancer != nil && len(n.rebalancer.Allocations()) > 0 {
+		fmt.Println("------ REBALANCE -------")
+		for _, allocation := range n.rebalancer.Allocations() {
+			weights := make([]string, 0, len(allocation.Ranking))
+			for _, pair := range allocation.Ranking {
+				weights = append(weights, fmt.Sprintf("%s %.1f%%", pair, allocation.Weights[pair]*100))
+			}
+			fmt.Printf("%s | equity %.2f | turnover %.1f%% | %s\n", allocation.Time.Format("2006-01-02 15:04"),
+				allocation.Equity, allocation.Turnover*100, strings.Join(weights, ", "))
+		}
+		fmt.Printf("REBALANCES: %d, TOTAL TURNOVER: %.1f%%\n", len(n.rebalancer.Allocations()),
+			n.rebalancer.Turnover()*100)
+		fmt.Println()
+	}
+
 	if n.paperWallet != nil {
 		n.paperWallet.Summary()
 	}
 
 }
 
+// printTimeBuckets prints the buckets with closed trades as a table
+func printTimeBuckets(name string, buckets []order.TimeBucket) {
+	buffer := bytes.NewBuffer(nil)
+	table := tablewriter.NewWriter(buffer)
+	table.SetHeader([]string{name, "Trades", "% Win", "Avg. Return", "Profit"})
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	for _, bucket := range buckets {
+		if bucket.Trades == 0 {
+			continue
+		}
+
+		table.Append([]string{
+			bucket.Label,
+			strconv.Itoa(bucket.Trades),
+			fmt.Sprintf("%.1f %%", bucket.WinPercentage()),
+			fmt.Sprintf("%.2f %%", bucket.AvgProfitPercent()*100),
+			fmt.Sprintf("%.2f", bucket.Profit),
+		})
+	}
+	table.Render()
+	fmt.Println(buffer.String())
+}
+
 func (n NinjaBot) SaveReturns(outputDir string) error {
 	for _, summary := range n.orderController.Results {
 		outputFile := fmt.Sprintf("%s/%s.csv", outputDir, summary.Pair)
@@ -283,6 +997,87 @@ func (n NinjaBot) SaveReturns(outputDir string) error {
 	return nil
 }
 
+// Result returns the metrics by pair, the equity curve (with paper wallet) and the filled orders,
+// it can be saved with Result.Save to inspect the execution later
+func (n *NinjaBot) Result() (Result, error) {
+	result := Result{
+		Metrics:   make([]PairResult, 0, len(n.orderController.Results)),
+		Equity:    make([]exchange.AssetValue, 0),
+		Positions: make([]PositionResult, 0),
+		Closed:    make([]TradeResult, 0),
+		Trades:    make([]model.Order, 0),
+	}
+
+	// NaN values are not supported by JSON
+	finite := func(value float64) float64 {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return 0
+		}
+		return value
+	}
+
+	for _, summary := range n.orderController.Results {
+		result.Metrics = append(result.Metrics, PairResult{
+			Pair:          summary.Pair,
+			Trades:        len(summary.Win()) + len(summary.Lose()),
+			Win:           len(summary.Win()),
+			Loss:          len(summary.Lose()),
+			WinPercentage: finite(summary.WinPercentage()),
+			Payoff:        finite(summary.Payoff()),
+			ProfitFactor:  finite(summary.ProfitFactor()),
+			SQN:           finite(summary.SQN()),
+			Profit:        finite(summary.Profit()),
+			Volume:        finite(summary.Volume),
+		})
+
+		for _, trade := range summary.Trades {
+			result.Closed = append(result.Closed, TradeResult{
+				Pair:          trade.Pair,
+				Side:          trade.Side,
+				ProfitPercent: finite(trade.ProfitPercent),
+				ProfitValue:   finite(trade.ProfitValue),
+				EntryTime:     trade.EntryTime(),
+				ExitTime:      trade.CreatedAt,
+			})
+		}
+	}
+	sort.Slice(result.Metrics, func(i, j int) bool {
+		return result.Metrics[i].Pair < result.Metrics[j].Pair
+	})
+	sort.SliceStable(result.Closed, func(i, j int) bool {
+		return result.Closed[i].ExitTime.Before(result.Closed[j].ExitTime)
+	})
+
+	for pair, position := range n.orderController.OpenPositions() {
+		result.Positions = append(result.Positions, PositionResult{
+			Pair:     pair,
+			Side:     position.Side,
+			Quantity: position.Quantity,
+			AvgPrice: position.AvgPrice,
+		})
+	}
+	sort.Slice(result.Positions, func(i, j int) bool {
+		return result.Positions[i].Pair < result.Positions[j].Pair
+	})
+
+	if n.paperWallet != nil {
+		result.Equity = append(result.Equity, n.paperWallet.EquityValues()...)
+		maxDrawdown, _, _ := n.paperWallet.MaxDrawdown()
+		result.MaxDrawdown = finite(maxDrawdown)
+	}
+
+	orders, err := n.storage.Orders(storage.WithStatus(model.OrderStatusTypeFilled))
+	if err != nil {
+		return Result{}, err
+	}
+
+	for _, order := range orders {
+		result.Trades = append(result.Trades, *order)
+	}
+
+	return result, nil
+}
+
 func (n *NinjaBot) onCandle(candle model.Candle) {
 	n.priorityQueueCandle.Push(candle)
 }
@@ -296,26 +1091,118 @@ func (n *NinjaBot) processCandle(candle model.Candle) {
 	if candle.Complete {
 		n.strategiesControllers[candle.Pair].OnCandle(candle)
 		n.orderController.OnCandle(candle)
+		if n.warmup != nil {
+			n.warmup.check(candle.Pair)
+		}
 	}
 }
 
 // Process pending candles in buffer
-func (n *NinjaBot) processCandles() {
-	for item := range n.priorityQueueCandle.PopLock() {
-		n.processCandle(item.(model.Candle))
+func (n *NinjaBot) processCandles(ctx context.Context) {
+	candles := n.priorityQueueCandle.PopLock()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-candles:
+			n.processCandle(item.(model.Candle))
+		}
+	}
+}
+
+// shutdown applies the shutdown policy of each pair and notifies the summary of the actions
+func (n *NinjaBot) shutdown() []order.ShutdownAction {
+	actions := make([]order.ShutdownAction, 0, len(n.settings.Pairs))
+	summary := []string{"[SHUTDOWN] Summary"}
+	for _, pair := range n.settings.Pairs {
+		policy, ok := n.shutdownPolicies[pair]
+		if !ok {
+			policy = n.shutdownPolicy
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		action := n.orderController.Shutdown(ctx, pair, policy)
+		cancel()
+		for _, err := range action.Errors {
+			n.logger.Error("[SHUTDOWN] "+err.Error(), "pair", pair)
+		}
+		actions = append(actions, action)
+		summary = append(summary, action.String())
+	}
+
+	if n.notifier != nil {
+		n.notifier.Notify(strings.Join(summary, "\n"))
+		if closer, ok := n.notifier.(service.NotifierCloser); ok {
+			closer.Close()
+		} else if flusher, ok := n.notifier.(service.NotifierFlusher); ok {
+			flusher.Flush()
+		}
+	}
+	return actions
+}
+
+// loadStrategyState restores the state of a stateful strategy saved in the previous run, see strategy.Stateful
+func (n *NinjaBot) loadStrategyState() error {
+	if _, ok := n.strategy.(strategy.Stateful); !ok {
+		return nil
+	}
+
+	store, ok := n.storage.(storage.StateStorage)
+	if !ok {
+		n.logger.Warn("[SETUP] Storage does not support strategy state, the state is not persisted")
+		return nil
+	}
+
+	loaded, err := strategy.LoadState(n.strategy, store)
+	if err != nil {
+		return err
+	}
+	if loaded {
+		n.logger.Info("[SETUP] Strategy state restored")
+	}
+	return nil
+}
+
+// saveStrategyState persists the state of a stateful strategy for the next run, see strategy.Stateful
+func (n *NinjaBot) saveStrategyState() {
+	store, ok := n.storage.(storage.StateStorage)
+	if !ok {
+		return
+	}
+
+	if err := strategy.SaveState(n.strategy, store); err != nil {
+		n.logger.Error("[SHUTDOWN] " + err.Error())
 	}
 }
 
 // Start the backtest process and create a progress bar
 // backtestCandles will process candles from a prirority queue in chronological order
 func (n *NinjaBot) backtestCandles() {
-	log.Info("[SETUP] Starting backtesting")
+	n.logger.Info("[SETUP] Starting backtesting")
 
 	progressBar := progressbar.Default(int64(n.priorityQueueCandle.Len()))
 	for n.priorityQueueCandle.Len() > 0 {
 		item := n.priorityQueueCandle.Pop()
 
 		candle := item.(model.Candle)
+
+		// backtest data has only complete candles, simulates the open of the candle for signals on open. The
+		// synthetic candle is not sent to the paper wallet, so it does not fill orders or change the prices,
+		// the orders of the signals are executed at the close of the previous candle.
+		if n.signalTiming == strategy.SignalOnOpen && candle.Complete {
+			open := model.Candle{
+				Pair:      candle.Pair,
+				Time:      candle.Time,
+				UpdatedAt: candle.Time,
+				Open:      candle.Open,
+				Close:     candle.Open,
+				Low:       candle.Open,
+				High:      candle.Open,
+				Metadata:  candle.Metadata,
+			}
+			n.strategiesControllers[candle.Pair].OnPartialCandle(open)
+		}
+
 		if n.paperWallet != nil {
 			n.paperWallet.OnCandle(candle)
 		}
@@ -323,10 +1210,13 @@ func (n *NinjaBot) backtestCandles() {
 		n.strategiesControllers[candle.Pair].OnPartialCandle(candle)
 		if candle.Complete {
 			n.strategiesControllers[candle.Pair].OnCandle(candle)
+			if n.rebalancer != nil {
+				n.rebalancer.OnCandle(candle)
+			}
 		}
 
 		if err := progressBar.Add(1); err != nil {
-			log.Warnf("update progressbar fail: %v", err)
+			n.logger.Warn("update progressbar fail: " + err.Error())
 		}
 	}
 }
@@ -338,7 +1228,7 @@ func (n *NinjaBot) preload(ctx context.Context, pair string) error {
 		return nil
 	}
 
-	candles, err := n.exchange.CandlesByLimit(ctx, pair, n.strategy.Timeframe(), n.strategy.WarmupPeriod())
+	candles, err := n.warmupCandles(ctx, pair)
 	if err != nil {
 		return err
 	}
@@ -352,12 +1242,76 @@ func (n *NinjaBot) preload(ctx context.Context, pair string) error {
 	return nil
 }
 
+// minLiveCandles returns the number of live candles required before orders, backtests are not affected
+func (n *NinjaBot) minLiveCandles() int {
+	if n.backtest {
+		return 0
+	}
+	if n.settings.MinLiveCandles == 0 {
+		return defaultMinLiveCandles
+	}
+	return n.settings.MinLiveCandles
+}
+
+// Signals returns the orders of the strategy in signal-only mode (model.ModeSignal), which are not executed.
+// The channel is buffered, signals are dropped when it is full. It is nil in the other modes.
+func (n *NinjaBot) Signals() <-chan model.Signal {
+	return n.signals
+}
+
+func (n *NinjaBot) emitSignal(signal model.Signal) {
+	n.logger.Info("[SIGNAL]", "pair", signal.Pair, "side", signal.Side, "type", signal.Type, "size", signal.Size,
+		"price", signal.Price, "reason", signal.Reason)
+
+	select {
+	case n.signals <- signal:
+	default:
+		n.logger.Warn("[SIGNAL] Signal dropped, channel full", "pair", signal.Pair, "side", signal.Side)
+	}
+
+	if n.notifier != nil {
+		n.notifier.Notify(notification.NewFormatter(n.orderController).FormatSignal(signal))
+	}
+	for _, subscriber := range n.signalSubscribers {
+		subscriber.OnSignal(signal)
+	}
+
+	if n.signalWebhook != nil {
+		go func() {
+			if err := n.signalWebhook.Post(signal); err != nil {
+				n.logger.Error("[SIGNAL] Webhook failed: "+err.Error(), "pair", signal.Pair)
+			}
+		}()
+	}
+}
+
+// subscribeCrossPrices delivers the prices of the cross exchange feed to the strategy controllers
+func (n *NinjaBot) subscribeCrossPrices(ctx context.Context) {
+	for _, pair := range n.settings.Pairs {
+		prices, errs := n.crossFeed.Subscribe(ctx, pair, n.strategy.Timeframe())
+		controller := n.strategiesControllers[pair]
+		go func() {
+			for price := range prices {
+				controller.OnCrossPrice(price)
+			}
+		}()
+		go func(pair string) {
+			for err := range errs {
+				n.logger.Error("[CROSS EXCHANGE] "+err.Error(), "pair", pair)
+			}
+		}(pair)
+	}
+}
+
 // Run will initialize the strategy controller, order controller, preload data and start the bot
 func (n *NinjaBot) Run(ctx context.Context) error {
-	for _, pair := range n.settings.Pairs {
-		// setup and subscribe strategy to data feed (candles)
-		n.strategiesControllers[pair] = strategy.NewStrategyController(pair, n.strategy, n.orderController)
+	if !n.backtest {
+		if err := n.loadStrategyState(); err != nil {
+			return err
+		}
+	}
 
+	for _, pair := range n.settings.Pairs {
 		// preload candles for warmup period
 		err := n.preload(ctx, pair)
 		if err != nil {
@@ -371,6 +1325,15 @@ func (n *NinjaBot) Run(ctx context.Context) error {
 		n.strategiesControllers[pair].Start()
 	}
 
+	if !n.backtest {
+		n.warmup = newWarmupMonitor(n.strategiesControllers, n.notifier, n.logger)
+		n.warmup.start(n.warmupTimeout)
+	}
+
+	if n.reconcile && !n.backtest {
+		n.orderController.Reconcile(n.settings.Pairs...)
+	}
+
 	// start order feed and controller
 	n.orderFeed.Start()
 	n.orderController.Start()
@@ -379,6 +1342,10 @@ func (n *NinjaBot) Run(ctx context.Context) error {
 		n.telegram.Start()
 	}
 
+	if n.crossFeed != nil && !n.backtest {
+		n.subscribeCrossPrices(ctx)
+	}
+
 	// start data feed and receives new candles
 	n.dataFeed.Start(n.backtest)
 
@@ -386,7 +1353,9 @@ func (n *NinjaBot) Run(ctx context.Context) error {
 	if n.backtest {
 		n.backtestCandles()
 	} else {
-		n.processCandles()
+		n.processCandles(ctx)
+		n.shutdown()
+		n.saveStrategyState()
 	}
 
 	return nil
@@ -3,6 +3,7 @@ package ninjabot
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/rodrigo-brito/ninjabot/strategy"
 
@@ -11,6 +12,8 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/notification"
 	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/storage"
 )
@@ -114,3 +117,108 @@ func TestMarketOrder(t *testing.T) {
 
 	bot.Summary()
 }
+
+func TestNewBot_DenyPairs(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	strategy := new(fakeStrategy)
+	csvFeed, err := exchange.NewCSVFeed(
+		strategy.Timeframe(),
+		exchange.PairFeed{
+			Pair:      "BTCUSDT",
+			File:      "testdata/btc-1h.csv",
+			Timeframe: "1h",
+		},
+		exchange.PairFeed{
+			Pair:      "ETHUSDT",
+			File:      "testdata/eth-1h.csv",
+			Timeframe: "1h",
+		},
+	)
+	require.NoError(t, err)
+
+	paperWallet := exchange.NewPaperWallet(
+		ctx,
+		"USDT",
+		exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithDataFeed(csvFeed),
+	)
+
+	bot, err := NewBot(ctx, Settings{
+		Pairs:     []string{"BTCUSDT", "ETHUSDT"},
+		DenyPairs: []string{"ETHUSDT"},
+	},
+		paperWallet,
+		strategy,
+		WithStorage(storage),
+		WithBacktest(paperWallet),
+		WithLogLevel(log.ErrorLevel),
+	)
+	require.NoError(t, err)
+	require.NoError(t, bot.Run(ctx))
+
+	require.Contains(t, bot.dataFeed.SubscriptionsByDataFeed, "BTCUSDT--1d")
+	require.NotContains(t, bot.dataFeed.SubscriptionsByDataFeed, "ETHUSDT--1d")
+	require.NotContains(t, bot.strategiesControllers, "ETHUSDT")
+	require.NotContains(t, bot.orderController.Results, "ETHUSDT")
+	require.Contains(t, bot.orderController.Results, "BTCUSDT")
+}
+
+// senderNotifier is a notifier that reports the delivery errors
+type senderNotifier struct {
+	service.Notifier
+}
+
+func (senderNotifier) Send(string) error {
+	return nil
+}
+
+func TestNewBot_NotificationRetry(t *testing.T) {
+	ctx := context.Background()
+
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	paperWallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	bot, err := NewBot(ctx, Settings{Pairs: []string{"BTCUSDT"}}, paperWallet, new(fakeStrategy),
+		WithStorage(storage),
+		WithBacktest(paperWallet),
+		WithLogLevel(log.ErrorLevel),
+		WithNotifier(senderNotifier{}),
+		WithNotificationRetry(notification.DefaultRetryPolicy),
+	)
+	require.NoError(t, err)
+	require.IsType(t, &notification.Retry{}, bot.notifier)
+	bot.notifier.(*notification.Retry).Close()
+}
+
+type candleCounter struct {
+	candles int
+}
+
+func (c *candleCounter) OnCandle(model.Candle) {
+	c.candles++
+}
+
+func TestNewBot_FeedSubscriptions(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+
+	counter := new(candleCounter)
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 10000))
+	bot, err := NewBot(context.Background(), Settings{Pairs: []string{"BTCUSDT"}}, wallet, new(fakeStrategy),
+		WithStorage(storage),
+		WithCandleSubscription(counter),
+		WithBaseTimeframe("1h"),
+		WithCandleSources(time.Minute, exchange.NewPaperWallet(context.Background(), "USDT",
+			exchange.WithPaperAsset("USDT", 10000))),
+		WithLogLevel(log.ErrorLevel),
+	)
+	require.NoError(t, err)
+
+	// the subscription of the option is kept after the feed is replaced by the failover and aggregated feeds
+	require.Len(t, bot.dataFeed.SubscriptionsByDataFeed["BTCUSDT--1d"], 1)
+}
@@ -0,0 +1,128 @@
+package notification
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// Debounce is a notifier that consolidates the entry fills of a position built across many small orders.
+// The filled orders of a pair and side without profit, i.e. that do not close a position, are held for the
+// window after the first fill and delivered as a single filled order with the total quantity, the
+// weighted-average price and the sum of the fees. A single fill is delivered unchanged. Other notifications
+// are delivered immediately, the pending fills of the pair are delivered before them to keep the order.
+type Debounce struct {
+	mtx      sync.Mutex
+	notifier service.Notifier
+	window   time.Duration
+	pending  map[string][]model.Order
+	timers   map[string]*time.Timer
+}
+
+// NewDebounce creates a notifier over the given one, zero or a negative window disables the debounce
+func NewDebounce(notifier service.Notifier, window time.Duration) *Debounce {
+	return &Debounce{
+		notifier: notifier,
+		window:   window,
+		pending:  make(map[string][]model.Order),
+		timers:   make(map[string]*time.Timer),
+	}
+}
+
+func (d *Debounce) Notify(text string) {
+	d.notifier.Notify(text)
+}
+
+func (d *Debounce) OnError(err error) {
+	d.notifier.OnError(err)
+}
+
+func (d *Debounce) OnOrder(order model.Order) {
+	entry := order.Status == model.OrderStatusTypeFilled && order.Profit == 0 && order.ProfitValue == 0
+	if !entry || d.window <= 0 {
+		d.flushPair(order.Pair)
+		d.notifier.OnOrder(order)
+		return
+	}
+
+	key := order.Pair + ":" + string(order.Side)
+	d.mtx.Lock()
+	d.pending[key] = append(d.pending[key], order)
+	if _, ok := d.timers[key]; !ok {
+		d.timers[key] = time.AfterFunc(d.window, func() {
+			d.flush(key)
+		})
+	}
+	d.mtx.Unlock()
+}
+
+// Flush delivers all the pending fills and flushes the wrapped notifier, e.g. before the bot shuts down
+func (d *Debounce) Flush() {
+	d.mtx.Lock()
+	keys := make([]string, 0, len(d.pending))
+	for key := range d.pending {
+		keys = append(keys, key)
+	}
+	d.mtx.Unlock()
+
+	for _, key := range keys {
+		d.flush(key)
+	}
+
+	if flusher, ok := d.notifier.(service.NotifierFlusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close delivers all the pending fills and closes the wrapped notifier, see service.NotifierCloser
+func (d *Debounce) Close() {
+	d.Flush()
+	if closer, ok := d.notifier.(service.NotifierCloser); ok {
+		closer.Close()
+	}
+}
+
+func (d *Debounce) flushPair(pair string) {
+	d.flush(pair + ":" + string(model.SideTypeBuy))
+	d.flush(pair + ":" + string(model.SideTypeSell))
+}
+
+func (d *Debounce) flush(key string) {
+	d.mtx.Lock()
+	orders := d.pending[key]
+	delete(d.pending, key)
+	if timer, ok := d.timers[key]; ok {
+		timer.Stop()
+		delete(d.timers, key)
+	}
+	d.mtx.Unlock()
+
+	if len(orders) > 0 {
+		d.notifier.OnOrder(consolidate(orders))
+	}
+}
+
+// consolidate merges the fills in the last order, with the total quantity and the weighted-average price
+func consolidate(orders []model.Order) model.Order {
+	order := orders[len(orders)-1]
+	if len(orders) == 1 {
+		return order
+	}
+
+	var quantity, value, fee, assetFee float64
+	for _, fill := range orders {
+		quantity += fill.Quantity
+		value += fill.Quantity * fill.Price
+		fee += fill.Fee
+		assetFee += fill.AssetFee
+	}
+
+	order.Quantity = quantity
+	order.Fee, order.AssetFee = fee, assetFee
+	if quantity > 0 {
+		order.Price = value / quantity
+	}
+	return order
+}
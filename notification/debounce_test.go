@@ -0,0 +1,95 @@
+package notification
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// capturingNotifier records the order notifications
+type capturingNotifier struct {
+	mtx    sync.Mutex
+	orders []model.Order
+}
+
+func (c *capturingNotifier) Notify(string) {}
+
+func (c *capturingNotifier) OnError(error) {}
+
+func (c *capturingNotifier) OnOrder(order model.Order) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.orders = append(c.orders, order)
+}
+
+func (c *capturingNotifier) Orders() []model.Order {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]model.Order(nil), c.orders...)
+}
+
+func TestDebounce(t *testing.T) {
+	fill := func(id int64, side model.SideType, price, quantity float64) model.Order {
+		return model.Order{ID: id, Pair: "BTCUSDT", Side: side, Status: model.OrderStatusTypeFilled,
+			Price: price, Quantity: quantity, Fee: 0.1}
+	}
+
+	t.Run("burst of fills", func(t *testing.T) {
+		notifier := &capturingNotifier{}
+		debounce := NewDebounce(notifier, 50*time.Millisecond)
+
+		debounce.OnOrder(fill(1, model.SideTypeBuy, 100, 1))
+		debounce.OnOrder(fill(2, model.SideTypeBuy, 110, 2))
+		debounce.OnOrder(fill(3, model.SideTypeBuy, 120, 1))
+		require.Empty(t, notifier.Orders())
+
+		require.Eventually(t, func() bool {
+			return len(notifier.Orders()) > 0
+		}, time.Second, 10*time.Millisecond)
+
+		time.Sleep(100 * time.Millisecond)
+		orders := notifier.Orders()
+		require.Len(t, orders, 1)
+		require.Equal(t, int64(3), orders[0].ID)
+		require.Equal(t, model.OrderStatusTypeFilled, orders[0].Status)
+		require.Equal(t, 4.0, orders[0].Quantity)
+		require.InDelta(t, 110, orders[0].Price, 1e-9)
+		require.InDelta(t, 0.3, orders[0].Fee, 1e-9)
+	})
+
+	t.Run("exits and other orders", func(t *testing.T) {
+		notifier := &capturingNotifier{}
+		debounce := NewDebounce(notifier, time.Hour)
+
+		debounce.OnOrder(fill(1, model.SideTypeBuy, 100, 1))
+		debounce.OnOrder(fill(2, model.SideTypeBuy, 100, 1))
+		debounce.OnOrder(model.Order{ID: 3, Pair: "ETHUSDT", Status: model.OrderStatusTypeNew})
+		require.Len(t, notifier.Orders(), 1)
+
+		// the exit of the pair delivers the pending entries first
+		exit := fill(4, model.SideTypeSell, 110, 2)
+		exit.ProfitValue = 20
+		debounce.OnOrder(exit)
+		orders := notifier.Orders()
+		require.Len(t, orders, 3)
+		require.Equal(t, int64(2), orders[1].ID)
+		require.Equal(t, 2.0, orders[1].Quantity)
+		require.Equal(t, int64(4), orders[2].ID)
+
+		// a single fill is delivered unchanged
+		debounce.OnOrder(fill(5, model.SideTypeSell, 105, 1))
+		debounce.Flush()
+		require.Equal(t, fill(5, model.SideTypeSell, 105, 1), notifier.Orders()[3])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		notifier := &capturingNotifier{}
+		debounce := NewDebounce(notifier, 0)
+		debounce.OnOrder(fill(1, model.SideTypeBuy, 100, 1))
+		require.Len(t, notifier.Orders(), 1)
+	})
+}
@@ -0,0 +1,82 @@
+package notification
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// AssetsInfoProvider returns the precision and filters of a pair, e.g. an exchange or the order controller
+type AssetsInfoProvider interface {
+	AssetsInfo(pair string) model.AssetInfo
+}
+
+// Formatter formats the prices and quantities of the notifications with the precision of each pair,
+// e.g. BTCUSDT prices with 2 decimals and SHIBUSDT with 8.
+type Formatter struct {
+	provider AssetsInfoProvider
+}
+
+func NewFormatter(provider AssetsInfoProvider) Formatter {
+	return Formatter{provider: provider}
+}
+
+// decimals returns the number of decimals of the given step (tick or step size), or the precision when the
+// step is unknown. Values without step and precision are formatted with the smallest necessary decimals.
+func decimals(step float64, precision int) int {
+	if step > 0 {
+		return int(math.Max(0, math.Round(-math.Log10(step))))
+	}
+	if precision > 0 {
+		return precision
+	}
+	return -1
+}
+
+// FormatPrice formats a price with the tick size of the pair, or the quote precision as fallback
+func (f Formatter) FormatPrice(pair string, value float64) string {
+	info := f.provider.AssetsInfo(pair)
+	return strconv.FormatFloat(value, 'f', decimals(info.TickSize, info.QuotePrecision), 64)
+}
+
+// FormatQuantity formats a quantity with the step size of the pair, or the base asset precision as fallback
+func (f Formatter) FormatQuantity(pair string, value float64) string {
+	info := f.provider.AssetsInfo(pair)
+	return strconv.FormatFloat(value, 'f', decimals(info.StepSize, info.BaseAssetPrecision), 64)
+}
+
+// FormatOrder is the equivalent of model.Order String with the precision of the pair
+func (f Formatter) FormatOrder(o model.Order) string {
+	return fmt.Sprintf("[%s] %s %s | ID: %d, Type: %s, %s x $%s (~$%.f)",
+		o.Status, o.Side, o.Pair, o.ID, o.Type, f.FormatQuantity(o.Pair, o.Quantity),
+		f.FormatPrice(o.Pair, o.Price), o.Quantity*o.Price)
+}
+
+// FormatSignal formats a signal of the signal-only mode, with the indicator values sorted by name
+func (f Formatter) FormatSignal(s model.Signal) string {
+	lines := []string{
+		fmt.Sprintf("🔔 SIGNAL - %s %s", s.Side, s.Pair),
+		"-----",
+		fmt.Sprintf("Type: %s", s.Type),
+		fmt.Sprintf("Price: %s", f.FormatPrice(s.Pair, s.Price)),
+		fmt.Sprintf("Size: %s", f.FormatQuantity(s.Pair, s.Size)),
+	}
+	if s.Stop > 0 {
+		lines = append(lines, fmt.Sprintf("Stop: %s", f.FormatPrice(s.Pair, s.Stop)))
+	}
+	lines = append(lines, fmt.Sprintf("Reason: %s", s.Reason))
+
+	names := make([]string, 0, len(s.Indicators))
+	for name := range s.Indicators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s: %s", name, strconv.FormatFloat(s.Indicators[name], 'f', 4, 64)))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,42 @@
+package notification
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+type assetsInfo map[string]model.AssetInfo
+
+func (a assetsInfo) AssetsInfo(pair string) model.AssetInfo {
+	return a[pair]
+}
+
+func TestFormatter(t *testing.T) {
+	formatter := NewFormatter(assetsInfo{
+		"BTCUSDT":  {TickSize: 0.01, StepSize: 0.00001, QuotePrecision: 8, BaseAssetPrecision: 8},
+		"SHIBUSDT": {TickSize: 0.00000001, StepSize: 1, QuotePrecision: 8, BaseAssetPrecision: 2},
+		"ETHUSDT":  {QuotePrecision: 4, BaseAssetPrecision: 3},
+	})
+
+	require.Equal(t, "0.12", formatter.FormatPrice("BTCUSDT", 0.123456789))
+	require.Equal(t, "0.12345679", formatter.FormatPrice("SHIBUSDT", 0.123456789))
+	require.Equal(t, "0.1235", formatter.FormatPrice("ETHUSDT", 0.123456789))
+	require.Equal(t, "0.123456789", formatter.FormatPrice("UNKNOWN", 0.123456789))
+
+	require.Equal(t, "1.50000", formatter.FormatQuantity("BTCUSDT", 1.5))
+	require.Equal(t, "2000", formatter.FormatQuantity("SHIBUSDT", 2000))
+	require.Equal(t, "1.500", formatter.FormatQuantity("ETHUSDT", 1.5))
+
+	order := model.Order{ID: 1, Pair: "BTCUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeLimit,
+		Status: model.OrderStatusTypeNew, Price: 30000.123, Quantity: 0.5}
+	require.Equal(t, "[NEW] BUY BTCUSDT | ID: 1, Type: LIMIT, 0.50000 x $30000.12 (~$15000)",
+		formatter.FormatOrder(order))
+
+	signal := model.Signal{Pair: "BTCUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeMarket, Size: 0.5,
+		Price: 30000.123, Reason: "ema crossover", Indicators: map[string]float64{"rsi": 55.5, "ema": 29000}}
+	require.Equal(t, "🔔 SIGNAL - BUY BTCUSDT\n-----\nType: MARKET\nPrice: 30000.12\nSize: 0.50000\n"+
+		"Reason: ema crossover\nema: 29000.0000\nrsi: 55.5000", formatter.FormatSignal(signal))
+}
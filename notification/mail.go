@@ -20,6 +20,15 @@ type Mail struct {
 }
 
 func (t Mail) Notify(text string) {
+	if err := t.Send(text); err != nil {
+		log.
+			WithError(err).
+			Errorf("notification/mail: couldnt send mail")
+	}
+}
+
+// Send delivers the message by mail and returns the delivery error
+func (t Mail) Send(text string) error {
 	serverAddress := fmt.Sprintf(
 		"%s:%d",
 		t.smtpServerAddress,
@@ -32,20 +41,24 @@ func (t Mail) Notify(text string) {
 		text,
 	)
 
-	err := smtp.SendMail(
+	return smtp.SendMail(
 		serverAddress,
 		t.auth,
 		t.from,
 		[]string{t.to},
 		[]byte(message))
-	if err != nil {
-		log.
-			WithError(err).
-			Errorf("notification/mail: couldnt send mail")
-	}
 }
 
 func (t Mail) OnOrder(order model.Order) {
+	t.Notify(t.OrderMessage(order))
+}
+
+func (t Mail) OnError(err error) {
+	t.Notify(t.ErrorMessage(err))
+}
+
+// OrderMessage formats the order notification, with the title as the mail subject, see MessageFormatter
+func (t Mail) OrderMessage(order model.Order) string {
 	title := ""
 	switch order.Status {
 	case model.OrderStatusTypeFilled:
@@ -56,14 +69,12 @@ func (t Mail) OnOrder(order model.Order) {
 		title = fmt.Sprintf("❌ ORDER CANCELED / REJECTED - %s", order.Pair)
 	}
 
-	message := fmt.Sprintf("Subject: %s\nOrder %s", title, order)
-
-	t.Notify(message)
+	return fmt.Sprintf("Subject: %s\nOrder %s", title, order)
 }
 
-func (t Mail) OnError(err error) {
-	message := fmt.Sprintf("Subject: 🛑 ERROR\nError %s", err)
-	t.Notify(message)
+// ErrorMessage formats the error notification, with the title as the mail subject, see MessageFormatter
+func (t Mail) ErrorMessage(err error) string {
+	return fmt.Sprintf("Subject: 🛑 ERROR\nError %s", err)
 }
 
 type MailParams struct {
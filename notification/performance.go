@@ -0,0 +1,118 @@
+package notification
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+)
+
+// maxMessageLength is the limit of the text of a Telegram message, in characters
+const maxMessageLength = 4096
+
+// limitMessage truncates the message to the limit of Telegram, at the last complete line
+func limitMessage(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxMessageLength {
+		return text
+	}
+
+	const suffix = "\n..."
+	text = string(runes[:maxMessageLength-len(suffix)])
+	if index := strings.LastIndex(text, "\n"); index > 0 {
+		text = text[:index]
+	}
+	return text + suffix
+}
+
+// performanceSince returns the start of the period of the performance command: "today" (UTC), "all" or a
+// duration until now, e.g. "7d" or "12h"
+func performanceSince(period string, now time.Time) (time.Time, error) {
+	switch period {
+	case "all":
+		return time.Time{}, nil
+	case "today":
+		return now.UTC().Truncate(24 * time.Hour), nil
+	}
+
+	duration, err := str2duration.ParseDuration(period)
+	if err != nil || duration <= 0 {
+		return time.Time{}, fmt.Errorf("invalid period: %s", period)
+	}
+	return now.Add(-duration), nil
+}
+
+// FormatPerformance formats the performance of the period as a text block, with the precision of each pair
+func (f Formatter) FormatPerformance(period string, performance order.Performance) string {
+	lines := []string{
+		fmt.Sprintf("*PERFORMANCE* (%s)", period),
+		fmt.Sprintf("Equity: `%.2f`", performance.Equity),
+		fmt.Sprintf("Return: `%.2f%%` (`%.2f`)", performance.Return*100, performance.Profit),
+		fmt.Sprintf("Trades: `%d`, Win rate: `%.1f%%`", performance.Trades, performance.WinPercentage),
+		fmt.Sprintf("Payoff: `%.2f`, Profit factor: `%.2f`", performance.Payoff, performance.ProfitFactor),
+		fmt.Sprintf("Max drawdown: `%.2f%%`", performance.MaxDrawdown*100),
+	}
+
+	if len(performance.Positions) == 0 {
+		lines = append(lines, "Open positions: none")
+		return strings.Join(lines, "\n")
+	}
+
+	pairs := make([]string, 0, len(performance.Positions))
+	for pair := range performance.Positions {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	lines = append(lines, fmt.Sprintf("Open positions (PnL `%.2f`):", performance.UnrealizedPnL))
+	for _, pair := range pairs {
+		position := performance.Positions[pair]
+		lines = append(lines, fmt.Sprintf("`%s %s %s @ %s`", pair, position.Side,
+			f.FormatQuantity(pair, position.Quantity), f.FormatPrice(pair, position.AvgPrice)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatInception formats the performance since the bot started, the first snapshot of the equity history.
+// The return and the max drawdown are calculated from the snapshots and the current equity, the trades are
+// the ones closed since the first snapshot.
+func (f Formatter) FormatInception(history []model.EquitySnapshot, performance order.Performance) string {
+	if len(history) == 0 {
+		return "No equity history registered."
+	}
+
+	start := history[0]
+	var cumulative, drawdown float64
+	if start.Value > 0 {
+		cumulative = performance.Equity/start.Value - 1
+	}
+
+	// the max drawdown is a negative fraction, or positive when the equity never declines
+	values := append(equityValues(history), exchange.AssetValue{Value: performance.Equity})
+	if maxDrawdown, _, _ := exchange.MaxDrawdown(values); maxDrawdown < 0 && !math.IsInf(maxDrawdown, 0) {
+		drawdown = -maxDrawdown
+	}
+
+	return strings.Join([]string{
+		fmt.Sprintf("*PERFORMANCE* (since %s)", start.Time.UTC().Format("2006-01-02 15:04")),
+		fmt.Sprintf("Equity: `%.2f` (start `%.2f`)", performance.Equity, start.Value),
+		fmt.Sprintf("Return: `%.2f%%` (`%.2f`)", cumulative*100, performance.Equity-start.Value),
+		fmt.Sprintf("Trades: `%d`, Win rate: `%.1f%%`", performance.Trades, performance.WinPercentage),
+		fmt.Sprintf("Max drawdown: `%.2f%%`", drawdown*100),
+	}, "\n")
+}
+
+func equityValues(history []model.EquitySnapshot) []exchange.AssetValue {
+	values := make([]exchange.AssetValue, 0, len(history)+1)
+	for _, snapshot := range history {
+		values = append(values, exchange.AssetValue{Time: snapshot.Time, Value: snapshot.Value})
+	}
+	return values
+}
@@ -0,0 +1,112 @@
+package notification
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func TestPerformanceSince(t *testing.T) {
+	now := time.Date(2022, 1, 10, 15, 30, 0, 0, time.UTC)
+
+	for period, expected := range map[string]time.Time{
+		"all":   {},
+		"today": time.Date(2022, 1, 10, 0, 0, 0, 0, time.UTC),
+		"7d":    time.Date(2022, 1, 3, 15, 30, 0, 0, time.UTC),
+		"30d":   time.Date(2021, 12, 11, 15, 30, 0, 0, time.UTC),
+		"12h":   time.Date(2022, 1, 10, 3, 30, 0, 0, time.UTC),
+	} {
+		since, err := performanceSince(period, now)
+		require.NoError(t, err, period)
+		require.Equal(t, expected, since, period)
+	}
+
+	_, err := performanceSince("week", now)
+	require.Error(t, err)
+}
+
+func TestFormatter_FormatPerformance(t *testing.T) {
+	repo, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := order.NewController(ctx, wallet, repo, order.NewOrderFeed())
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, step := range []struct {
+		price float64
+		side  model.SideType
+	}{
+		{100, model.SideTypeBuy},
+		{110, model.SideTypeSell},
+		{100, model.SideTypeBuy},
+		{95, model.SideTypeSell},
+		{100, model.SideTypeBuy},
+	} {
+		candle := model.Candle{Time: start.AddDate(0, 0, i), Pair: "BTCUSDT", Close: step.price}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		_, err := controller.CreateOrderMarket(step.side, "BTCUSDT", 2)
+		require.NoError(t, err)
+	}
+
+	performance, err := controller.Performance(time.Time{})
+	require.NoError(t, err)
+
+	formatter := NewFormatter(assetsInfo{"BTCUSDT": {TickSize: 0.01, StepSize: 0.001}})
+	require.Equal(t, "*PERFORMANCE* (all)\n"+
+		"Equity: `1010.00`\n"+
+		"Return: `1.00%` (`10.00`)\n"+
+		"Trades: `2`, Win rate: `50.0%`\n"+
+		"Payoff: `2.00`, Profit factor: `2.00`\n"+
+		"Max drawdown: `0.98%`\n"+
+		"Open positions (PnL `0.00`):\n"+
+		"`BTCUSDT BUY 2.000 @ 100.00`", formatter.FormatPerformance("all", performance))
+
+	t.Run("without positions", func(t *testing.T) {
+		message := formatter.FormatPerformance("today", order.Performance{})
+		require.Contains(t, message, "*PERFORMANCE* (today)")
+		require.Contains(t, message, "Open positions: none")
+	})
+}
+
+func TestFormatter_FormatInception(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []model.EquitySnapshot{
+		{Time: start, Value: 1000},
+		{Time: start.Add(time.Hour), Value: 1100},
+		{Time: start.Add(2 * time.Hour), Value: 990},
+		{Time: start.Add(3 * time.Hour), Value: 1050},
+	}
+	performance := order.Performance{Equity: 1200, Trades: 4, WinPercentage: 75}
+
+	formatter := NewFormatter(assetsInfo{})
+	require.Equal(t, "*PERFORMANCE* (since 2022-01-01 00:00)\n"+
+		"Equity: `1200.00` (start `1000.00`)\n"+
+		"Return: `20.00%` (`200.00`)\n"+
+		"Trades: `4`, Win rate: `75.0%`\n"+
+		"Max drawdown: `10.00%`", formatter.FormatInception(history, performance))
+
+	require.Equal(t, "No equity history registered.", formatter.FormatInception(nil, performance))
+
+	// without declines
+	require.Contains(t, formatter.FormatInception(history[:2], performance), "Max drawdown: `0.00%`")
+}
+
+func TestLimitMessage(t *testing.T) {
+	require.Equal(t, "short", limitMessage("short"))
+
+	line := strings.Repeat("a", 99) + "\n"
+	message := limitMessage(strings.Repeat(line, 50))
+	require.LessOrEqual(t, len([]rune(message)), maxMessageLength)
+	require.True(t, strings.HasSuffix(message, "a\n..."))
+	require.Equal(t, 40, strings.Count(message, line))
+}
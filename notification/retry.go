@@ -0,0 +1,374 @@
+package notification
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// Sender delivers a message and reports the failures, it is implemented by Mail and Telegram
+type Sender interface {
+	Send(text string) error
+}
+
+// RetryPolicy defines how many times a message is sent to each channel and the wait between the attempts,
+// which starts with Backoff and doubles after each failure, limited to MaxBackoff
+type RetryPolicy struct {
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// MultiSender is a channel with many recipients, e.g. the users of Telegram. The retries of a message are
+// delivered only to the recipients that failed, including the retries of the queued messages.
+type MultiSender interface {
+	Sender
+	Recipients() []string
+	SendTo(recipient, text string) error
+}
+
+// MessageFormatter formats the order and error notifications of a channel, it is implemented by Mail and Telegram.
+// Retry delivers the notifications formatted by each channel, the channels without it receive the plain order or
+// error.
+type MessageFormatter interface {
+	OrderMessage(order model.Order) string
+	ErrorMessage(err error) string
+}
+
+// DefaultRetryPolicy is short, the queued notifications wait for the retries of the previous ones
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	Backoff:    500 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+}
+
+const (
+	defaultRetryQueueSize     = 100
+	defaultRetryFlushInterval = time.Minute
+)
+
+// Retry is a notifier that retries failed deliveries with backoff and falls back to the next channel
+// when the retries are exhausted. The notifications are delivered in order by a background worker, so
+// the retries don't hold the caller. Order and error notifications that could not be delivered by any
+// channel are queued and flushed after the next successful delivery, periodically and by Flush. Close stops
+// the worker when the bot shuts down.
+type Retry struct {
+	mtx           sync.Mutex
+	senders       []Sender
+	policy        RetryPolicy
+	inbox         []message
+	wake          chan struct{}
+	queue         []message
+	queueSize     int
+	flushInterval time.Duration
+	sleep         func(time.Duration)
+	stop          chan struct{}
+	closed        bool
+}
+
+// message is a notification waiting for delivery, the order and error messages are formatted by each channel
+type message struct {
+	text     string
+	order    *model.Order
+	err      error
+	critical bool
+
+	// recipients that failed the delivery, by the index of the MultiSender channel
+	recipients map[int][]string
+
+	// done is closed when the worker reaches the message, see Flush
+	done chan struct{}
+}
+
+// format returns the text of the message for the channel
+func (m message) format(sender Sender) string {
+	formatter, ok := sender.(MessageFormatter)
+	switch {
+	case m.order != nil && ok:
+		return formatter.OrderMessage(*m.order)
+	case m.order != nil:
+		return m.order.String()
+	case m.err != nil && ok:
+		return formatter.ErrorMessage(m.err)
+	case m.err != nil:
+		return m.err.Error()
+	}
+	return m.text
+}
+
+type RetryOption func(*Retry)
+
+// WithRetryPolicy sets the retry policy, see DefaultRetryPolicy
+func WithRetryPolicy(policy RetryPolicy) RetryOption {
+	return func(retry *Retry) {
+		retry.policy = policy
+	}
+}
+
+// WithRetryQueueSize limits the undelivered notifications kept in the queue, the oldest are dropped first
+func WithRetryQueueSize(size int) RetryOption {
+	return func(retry *Retry) {
+		retry.queueSize = size
+	}
+}
+
+// WithRetryFlushInterval sets the interval to deliver the queued notifications when the bot is quiet,
+// zero disables the periodic flush
+func WithRetryFlushInterval(interval time.Duration) RetryOption {
+	return func(retry *Retry) {
+		retry.flushInterval = interval
+	}
+}
+
+// NewRetry creates a notifier over the given channels, in order of priority, and starts its worker
+func NewRetry(senders []Sender, options ...RetryOption) *Retry {
+	retry := &Retry{
+		senders:       senders,
+		policy:        DefaultRetryPolicy,
+		wake:          make(chan struct{}, 1),
+		queueSize:     defaultRetryQueueSize,
+		flushInterval: defaultRetryFlushInterval,
+		sleep:         time.Sleep,
+		stop:          make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(retry)
+	}
+
+	go retry.run()
+	return retry
+}
+
+// Pending returns the queued notifications, not delivered by any channel, formatted by the first channel
+func (r *Retry) Pending() []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var first Sender
+	if len(r.senders) > 0 {
+		first = r.senders[0]
+	}
+
+	pending := make([]string, 0, len(r.queue))
+	for _, m := range r.queue {
+		pending = append(pending, m.format(first))
+	}
+	return pending
+}
+
+func (r *Retry) Notify(text string) {
+	r.push(message{text: text})
+}
+
+func (r *Retry) OnOrder(order model.Order) {
+	r.push(message{order: &order, critical: true})
+}
+
+func (r *Retry) OnError(err error) {
+	r.push(message{err: err, critical: true})
+}
+
+// Flush waits for the delivery of the notifications sent before it and tries to deliver the queued ones,
+// e.g. before the bot shuts down
+func (r *Retry) Flush() {
+	done := make(chan struct{})
+	r.push(message{done: done})
+	<-done
+}
+
+// Close delivers the pending notifications, like Flush, and stops the worker and its flush ticker.
+// The notifications sent after Close are discarded.
+func (r *Retry) Close() {
+	r.Flush()
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if !r.closed {
+		r.closed = true
+		close(r.stop)
+	}
+}
+
+// push adds the message to the inbox of the worker
+func (r *Retry) push(m message) {
+	r.mtx.Lock()
+	if r.closed {
+		r.mtx.Unlock()
+		if m.done != nil {
+			close(m.done)
+			return
+		}
+		log.Warnf("notification/retry: notification discarded, the notifier is closed")
+		return
+	}
+	r.inbox = append(r.inbox, m)
+	r.mtx.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default: // the worker is already awake
+	}
+}
+
+// run delivers the messages of the inbox in order, and the queued messages periodically
+func (r *Retry) run() {
+	var tick <-chan time.Time
+	if r.flushInterval > 0 {
+		ticker := time.NewTicker(r.flushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.wake:
+			r.deliverInbox()
+		case <-tick:
+			r.flushQueue()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *Retry) deliverInbox() {
+	for {
+		r.mtx.Lock()
+		if len(r.inbox) == 0 {
+			r.mtx.Unlock()
+			return
+		}
+		m := r.inbox[0]
+		r.inbox = r.inbox[1:]
+		r.mtx.Unlock()
+
+		if m.done != nil {
+			r.flushQueue()
+			close(m.done)
+			continue
+		}
+		r.deliver(m)
+	}
+}
+
+// deliver sends the message and flushes the queue when the delivery succeeds,
+// critical messages are queued when no channel is available
+func (r *Retry) deliver(m message) {
+	if err := r.send(&m); err != nil {
+		log.WithError(err).Errorf("notification/retry: message not delivered by %d channels", len(r.senders))
+		if m.critical {
+			r.enqueue(m)
+		}
+		return
+	}
+
+	r.flushQueue()
+}
+
+// flushQueue delivers the queued messages in order, until a delivery fails
+func (r *Retry) flushQueue() {
+	for {
+		r.mtx.Lock()
+		if len(r.queue) == 0 {
+			r.mtx.Unlock()
+			return
+		}
+		queued := r.queue[0]
+		r.mtx.Unlock()
+
+		// the recipients that failed are updated in the queued message
+		if err := r.send(&queued); err != nil {
+			log.WithError(err).Errorf("notification/retry: queued messages not delivered")
+			return
+		}
+
+		r.mtx.Lock()
+		r.queue = r.queue[1:]
+		r.mtx.Unlock()
+	}
+}
+
+// send tries each channel in order until one of them delivers the message
+func (r *Retry) send(m *message) error {
+	err := errors.New("no notification channel")
+	for i, sender := range r.senders {
+		if multi, ok := sender.(MultiSender); ok {
+			err = r.sendTo(m, i, multi)
+		} else {
+			text := m.format(sender)
+			err = r.retry(func() error {
+				return sender.Send(text)
+			})
+		}
+		if err == nil {
+			return nil
+		}
+
+		if i < len(r.senders)-1 {
+			log.WithError(err).Warnf("notification/retry: channel %d failed, falling back to channel %d", i, i+1)
+		}
+	}
+	return err
+}
+
+// sendTo delivers the message to the recipients of the channel that didn't receive it yet, and keeps the
+// recipients that failed in the message
+func (r *Retry) sendTo(m *message, index int, sender MultiSender) error {
+	recipients, ok := m.recipients[index]
+	if !ok {
+		recipients = sender.Recipients()
+	}
+
+	text := m.format(sender)
+	err := r.retry(func() error {
+		var lastErr error
+		failed := make([]string, 0)
+		for _, recipient := range recipients {
+			if err := sender.SendTo(recipient, text); err != nil {
+				failed = append(failed, recipient)
+				lastErr = err
+			}
+		}
+		recipients = failed
+		return lastErr
+	})
+
+	if m.recipients == nil {
+		m.recipients = make(map[int][]string)
+	}
+	m.recipients[index] = recipients
+	return err
+}
+
+func (r *Retry) retry(send func() error) error {
+	backoff := r.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := send()
+		if err == nil || attempt >= r.policy.Attempts {
+			return err
+		}
+
+		r.sleep(backoff)
+		backoff *= 2
+		if r.policy.MaxBackoff > 0 && backoff > r.policy.MaxBackoff {
+			backoff = r.policy.MaxBackoff
+		}
+	}
+}
+
+func (r *Retry) enqueue(m message) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.queue = append(r.queue, m)
+	if r.queueSize > 0 && len(r.queue) > r.queueSize {
+		dropped := len(r.queue) - r.queueSize
+		log.Errorf("notification/retry: queue is full, %d messages dropped", dropped)
+		r.queue = r.queue[dropped:]
+	}
+}
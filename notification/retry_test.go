@@ -0,0 +1,220 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// failingSender fails the first deliveries and records the delivered messages
+type failingSender struct {
+	failures  int
+	attempts  int
+	delivered []string
+}
+
+func (f *failingSender) Send(text string) error {
+	f.attempts++
+	if f.failures != 0 {
+		f.failures--
+		return errors.New("connection refused")
+	}
+	f.delivered = append(f.delivered, text)
+	return nil
+}
+
+func (f *failingSender) OrderMessage(order model.Order) string {
+	return fmt.Sprintf("ORDER %s - %s", order.Status, order.Pair)
+}
+
+func (f *failingSender) ErrorMessage(err error) string {
+	return "ERROR " + err.Error()
+}
+
+func newTestRetry(senders ...Sender) (*Retry, *[]time.Duration) {
+	waits := make([]time.Duration, 0)
+	retry := NewRetry(senders, WithRetryPolicy(RetryPolicy{
+		Attempts:   3,
+		Backoff:    time.Second,
+		MaxBackoff: 3 * time.Second,
+	}))
+	retry.sleep = func(duration time.Duration) {
+		waits = append(waits, duration)
+	}
+	return retry, &waits
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("retry with backoff", func(t *testing.T) {
+		primary := &failingSender{failures: 2}
+		retry, waits := newTestRetry(primary)
+
+		retry.Notify("hello")
+		retry.Flush()
+		require.Equal(t, 3, primary.attempts)
+		require.Equal(t, []string{"hello"}, primary.delivered)
+		require.Equal(t, []time.Duration{time.Second, 2 * time.Second}, *waits)
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		primary := &failingSender{failures: -1}
+		fallback := &failingSender{}
+		retry, waits := newTestRetry(primary, fallback)
+
+		retry.Notify("hello")
+		retry.Flush()
+		require.Equal(t, 3, primary.attempts)
+		require.Empty(t, primary.delivered)
+		require.Equal(t, []string{"hello"}, fallback.delivered)
+		require.Len(t, *waits, 2)
+	})
+
+	t.Run("max backoff", func(t *testing.T) {
+		primary := &failingSender{failures: -1}
+		retry, waits := newTestRetry(primary)
+		retry.policy.Attempts = 4
+
+		retry.Notify("hello")
+		retry.Flush()
+		require.Equal(t, 4, primary.attempts)
+		require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, *waits)
+	})
+
+	t.Run("queue critical notifications", func(t *testing.T) {
+		primary := &failingSender{failures: -1}
+		retry, _ := newTestRetry(primary)
+
+		retry.Notify("not critical")
+		retry.OnOrder(model.Order{Pair: "BTCUSDT", Status: model.OrderStatusTypeFilled})
+		retry.Flush()
+		require.Empty(t, primary.delivered)
+		require.Equal(t, []string{"ORDER FILLED - BTCUSDT"}, retry.Pending())
+
+		// connectivity returns, queued messages are flushed after the new one
+		primary.failures = 0
+		retry.OnError(errors.New("insufficient funds"))
+		retry.Flush()
+		require.Equal(t, []string{"ERROR insufficient funds", "ORDER FILLED - BTCUSDT"}, primary.delivered)
+		require.Empty(t, retry.Pending())
+	})
+
+	t.Run("queue size", func(t *testing.T) {
+		primary := &failingSender{failures: -1}
+		retry, _ := newTestRetry(primary)
+		retry.queueSize = 2
+
+		for _, message := range []string{"a", "b", "c"} {
+			retry.OnError(errors.New(message))
+		}
+		retry.Flush()
+		require.Len(t, retry.Pending(), 2)
+		require.Contains(t, retry.Pending()[0], "b")
+		require.Contains(t, retry.Pending()[1], "c")
+	})
+	t.Run("retry failed recipients", func(t *testing.T) {
+		users := &usersSender{down: map[string]bool{"2": true}}
+		retry, _ := newTestRetry(users)
+
+		retry.OnError(errors.New("insufficient funds"))
+		retry.Flush()
+		require.Equal(t, []string{"1:insufficient funds"}, users.delivered)
+		require.Len(t, retry.Pending(), 1)
+
+		// the queued message is delivered only to the user that failed
+		users.down["2"] = false
+		retry.Flush()
+		require.Equal(t, []string{"1:insufficient funds", "2:insufficient funds"}, users.delivered)
+		require.Empty(t, retry.Pending())
+	})
+
+	t.Run("flush on interval", func(t *testing.T) {
+		primary := &failingSender{failures: 3}
+		retry := NewRetry([]Sender{primary}, WithRetryFlushInterval(10*time.Millisecond),
+			WithRetryPolicy(RetryPolicy{Attempts: 3}))
+
+		retry.OnError(errors.New("insufficient funds"))
+		require.Eventually(t, func() bool {
+			return len(retry.Pending()) == 0
+		}, time.Second, 10*time.Millisecond)
+		retry.Flush()
+		require.Equal(t, []string{"ERROR insufficient funds"}, primary.delivered)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		primary := &failingSender{}
+		retry, _ := newTestRetry(primary)
+
+		retry.Notify("before close")
+		retry.Close()
+		require.Equal(t, []string{"before close"}, primary.delivered)
+
+		// the worker is stopped, the notifications are discarded and Flush returns
+		retry.Notify("after close")
+		retry.Flush()
+		retry.Close()
+		require.Equal(t, []string{"before close"}, primary.delivered)
+	})
+
+	t.Run("format by channel", func(t *testing.T) {
+		primary := &failingSender{failures: -1}
+		fallback := &plainSender{}
+		retry, _ := newTestRetry(primary, fallback)
+
+		order := model.Order{Pair: "BTCUSDT", Status: model.OrderStatusTypeNew}
+		retry.OnOrder(order)
+		retry.Flush()
+		require.Equal(t, []string{order.String()}, fallback.delivered)
+	})
+
+	t.Run("deliver in background", func(t *testing.T) {
+		primary := &failingSender{failures: -1}
+		retry, _ := newTestRetry(primary)
+		release := make(chan struct{})
+		retry.sleep = func(time.Duration) {
+			<-release
+		}
+
+		// the caller is not held by the retries
+		retry.Notify("hello")
+		close(release)
+		retry.Flush()
+		require.Equal(t, 3, primary.attempts)
+	})
+}
+
+// usersSender delivers to many users, failing the deliveries to the users down
+type usersSender struct {
+	down      map[string]bool
+	delivered []string
+}
+
+func (u *usersSender) Send(string) error {
+	return errors.New("not supported")
+}
+
+func (u *usersSender) Recipients() []string {
+	return []string{"1", "2"}
+}
+
+func (u *usersSender) SendTo(recipient, text string) error {
+	if u.down[recipient] {
+		return errors.New("blocked")
+	}
+	u.delivered = append(u.delivered, recipient+":"+text)
+	return nil
+}
+
+// plainSender records the delivered messages, without formatting
+type plainSender struct {
+	delivered []string
+}
+
+func (p *plainSender) Send(text string) error {
+	p.delivered = append(p.delivered, text)
+	return nil
+}
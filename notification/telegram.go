@@ -1,6 +1,7 @@
 package notification
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"regexp"
@@ -14,23 +15,57 @@ import (
 	"github.com/rodrigo-brito/ninjabot/exchange"
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/order"
+	"github.com/rodrigo-brito/ninjabot/plot"
 	"github.com/rodrigo-brito/ninjabot/service"
 )
 
 var (
 	buyRegexp  = regexp.MustCompile(`/buy\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)(?P<percent>%)?`)
 	sellRegexp = regexp.MustCompile(`/sell\s+(?P<pair>\w+)\s+(?P<amount>\d+(?:\.\d+)?)(?P<percent>%)?`)
+	pairRegexp = regexp.MustCompile(`/(?:enable|disable)\s+(?P<pair>\w+)`)
+
+	performanceRegexp = regexp.MustCompile(`/performance(?:\s+(?P<period>\w+))?`)
+	chartRegexp       = regexp.MustCompile(`/chart\s+(?P<pair>\w+)`)
+)
+
+const (
+	// chartCandles limits the candles of the chart command, keeping the rendering fast
+	chartCandles = 100
+	chartWidth   = 800
+	chartHeight  = 400
 )
 
+// ChartSource returns a copy of the last candles of the dataframe of the pair, false for unknown pairs
+type ChartSource func(pair string, candles int) (model.Dataframe, bool)
+
+// telegramClient is the part of the Telegram bot used by the handlers, implemented by tb.Bot
+type telegramClient interface {
+	Start()
+	Send(to tb.Recipient, what interface{}, options ...interface{}) (*tb.Message, error)
+	GetCommands() ([]tb.Command, error)
+}
+
 type telegram struct {
 	settings        model.Settings
 	orderController *order.Controller
 	defaultMenu     *tb.ReplyMarkup
-	client          *tb.Bot
+	client          telegramClient
+	formatter       Formatter
+	chartSource     ChartSource
+	chartOverlays   []string
 }
 
 type Option func(telegram *telegram)
 
+// WithChartSource enables the `/chart <pair>` command, which sends an image of the last candles of the pair
+// with the series of the metadata in the overlays, e.g. a moving average
+func WithChartSource(source ChartSource, overlays ...string) Option {
+	return func(telegram *telegram) {
+		telegram.chartSource = source
+		telegram.chartOverlays = overlays
+	}
+}
+
 func NewTelegram(controller *order.Controller, settings model.Settings, options ...Option) (service.Telegram, error) {
 	menu := &tb.ReplyMarkup{ResizeReplyKeyboard: true}
 	poller := &tb.LongPoller{Timeout: 10 * time.Second}
@@ -77,8 +112,12 @@ func NewTelegram(controller *order.Controller, settings model.Settings, options
 		{Text: "/status", Description: "Check bot status"},
 		{Text: "/balance", Description: "Wallet balance"},
 		{Text: "/profit", Description: "Summary of last trade results"},
+		{Text: "/performance", Description: "Performance in a period: today, 7d, 30d, all or inception"},
 		{Text: "/buy", Description: "open a buy order"},
 		{Text: "/sell", Description: "open a sell order"},
+		{Text: "/enable", Description: "Enable entries on a pair"},
+		{Text: "/disable", Description: "Disable entries on a pair"},
+		{Text: "/chart", Description: "Chart of the last candles of a pair"},
 	})
 	if err != nil {
 		return nil, err
@@ -94,6 +133,7 @@ func NewTelegram(controller *order.Controller, settings model.Settings, options
 		client:          client,
 		settings:        settings,
 		defaultMenu:     menu,
+		formatter:       NewFormatter(controller),
 	}
 
 	for _, option := range options {
@@ -106,8 +146,12 @@ func NewTelegram(controller *order.Controller, settings model.Settings, options
 	client.Handle("/status", bot.StatusHandle)
 	client.Handle("/balance", bot.BalanceHandle)
 	client.Handle("/profit", bot.ProfitHandle)
+	client.Handle("/performance", bot.PerformanceHandle)
 	client.Handle("/buy", bot.BuyHandle)
 	client.Handle("/sell", bot.SellHandle)
+	client.Handle("/enable", bot.EnablePairHandle)
+	client.Handle("/disable", bot.DisablePairHandle)
+	client.Handle("/chart", bot.ChartHandle)
 
 	return bot, nil
 }
@@ -123,12 +167,47 @@ func (t telegram) Start() {
 }
 
 func (t telegram) Notify(text string) {
-	for _, user := range t.settings.Telegram.Users {
-		_, err := t.client.Send(&tb.User{ID: int64(user)}, text)
-		if err != nil {
-			log.Error(err)
+	if err := t.Send(text); err != nil {
+		log.Error(err)
+	}
+}
+
+// Send delivers the message to all users and returns the last delivery error
+func (t telegram) Send(text string) error {
+	var lastErr error
+	for _, user := range t.Recipients() {
+		if err := t.SendTo(user, text); err != nil {
+			lastErr = err
 		}
 	}
+	return lastErr
+}
+
+// Recipients returns the ids of the users, see MultiSender
+func (t telegram) Recipients() []string {
+	users := make([]string, 0, len(t.settings.Telegram.Users))
+	for _, user := range t.settings.Telegram.Users {
+		users = append(users, strconv.Itoa(user))
+	}
+	return users
+}
+
+// SendTo delivers the message to the user with the given id, see MultiSender
+func (t telegram) SendTo(recipient, text string) error {
+	user, err := strconv.ParseInt(recipient, 10, 64)
+	if err != nil {
+		return fmt.Errorf("telegram user %s: %w", recipient, err)
+	}
+
+	// label the messages when the orders are not executed in the exchange
+	if mode := t.settings.Mode; mode != "" && mode != model.ModeLive {
+		text = fmt.Sprintf("[%s] %s", strings.ToUpper(string(mode)), text)
+	}
+
+	if _, err := t.client.Send(&tb.User{ID: user}, text); err != nil {
+		return fmt.Errorf("telegram user %d: %w", user, err)
+	}
+	return nil
 }
 
 func (t telegram) BalanceHandle(m *tb.Message) {
@@ -160,7 +239,8 @@ func (t telegram) BalanceHandle(m *tb.Message) {
 		assetValue := assetSize * quote
 		quotesValue[quotePair] = quoteSize
 		total += assetValue
-		message += fmt.Sprintf("%s: `%.4f` ≅ `%.2f` %s \n", assetPair, assetSize, assetValue, quotePair)
+		message += fmt.Sprintf("%s: `%s` ≅ `%.2f` %s \n", assetPair, t.formatter.FormatQuantity(pair, assetSize),
+			assetValue, quotePair)
 	}
 
 	for quote, value := range quotesValue {
@@ -212,6 +292,124 @@ func (t telegram) ProfitHandle(m *tb.Message) {
 	}
 }
 
+// PerformanceHandle sends the performance of the period, e.g. `/performance 7d`, all the history by default.
+// With `/performance inception`, the return and drawdown are calculated from the persisted equity history.
+func (t telegram) PerformanceHandle(m *tb.Message) {
+	period := "all"
+	if match := performanceRegexp.FindStringSubmatch(m.Text); len(match) > 1 && match[1] != "" {
+		period = strings.ToLower(match[1])
+	}
+
+	if period == "inception" {
+		t.inceptionPerformance(m)
+		return
+	}
+
+	since, err := performanceSince(period, time.Now())
+	if err != nil {
+		_, err := t.client.Send(m.Sender, "Invalid period.\nExamples of usage:\n`/performance today`\n\n"+
+			"`/performance 7d`\n\n`/performance all`\n\n`/performance inception`")
+		if err != nil {
+			log.Error(err)
+		}
+		return
+	}
+
+	performance, err := t.orderController.Performance(since)
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return
+	}
+
+	_, err = t.client.Send(m.Sender, limitMessage(t.formatter.FormatPerformance(period, performance)))
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// inceptionPerformance sends the performance since the bot started, it requires the equity history
+func (t telegram) inceptionPerformance(m *tb.Message) {
+	history, err := t.orderController.EquityHistory(time.Time{}, time.Now())
+	if errors.Is(err, order.ErrEquityNotSupported) {
+		_, err := t.client.Send(m.Sender, "Equity history not available, see `WithEquityHistory`.")
+		if err != nil {
+			log.Error(err)
+		}
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return
+	}
+
+	var since time.Time
+	if len(history) > 0 {
+		since = history[0].Time
+	}
+
+	performance, err := t.orderController.Performance(since)
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return
+	}
+
+	_, err = t.client.Send(m.Sender, limitMessage(t.formatter.FormatInception(history, performance)))
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// ChartHandle sends an image of the last candles of the pair, e.g. `/chart BTCUSDT`, see WithChartSource
+func (t telegram) ChartHandle(m *tb.Message) {
+	reply := func(text string) {
+		if _, err := t.client.Send(m.Sender, text); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if t.chartSource == nil {
+		reply("Chart not available.")
+		return
+	}
+
+	match := chartRegexp.FindStringSubmatch(m.Text)
+	if len(match) == 0 {
+		reply("Invalid command.\nExample of usage:\n`/chart BTCUSDT`")
+		return
+	}
+
+	pair := strings.ToUpper(match[1])
+	df, ok := t.chartSource(pair, chartCandles)
+	if !ok {
+		reply(fmt.Sprintf("Pair `%s` not found.", pair))
+		return
+	}
+
+	image, err := plot.RenderImage(df, chartWidth, chartHeight, t.chartOverlays...)
+	if errors.Is(err, plot.ErrEmptyDataframe) {
+		reply(fmt.Sprintf("No candles of `%s`.", pair))
+		return
+	}
+	if err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return
+	}
+
+	caption := fmt.Sprintf("%s: last %d candles", pair, len(df.Close))
+	if len(t.chartOverlays) > 0 {
+		caption += fmt.Sprintf(" (%s)", strings.Join(t.chartOverlays, ", "))
+	}
+
+	photo := &tb.Photo{File: tb.FromReader(bytes.NewReader(image)), Caption: caption}
+	if _, err := t.client.Send(m.Sender, photo); err != nil {
+		log.Error(err)
+	}
+}
+
 func (t telegram) BuyHandle(m *tb.Message) {
 	match := buyRegexp.FindStringSubmatch(m.Text)
 	if len(match) == 0 {
@@ -316,7 +514,57 @@ func (t telegram) SellHandle(m *tb.Message) {
 
 func (t telegram) StatusHandle(m *tb.Message) {
 	status := t.orderController.Status()
-	_, err := t.client.Send(m.Sender, fmt.Sprintf("Status: `%s`", status))
+	message := fmt.Sprintf("Status: `%s`", status)
+	if mode := t.settings.Mode; mode != "" {
+		message += fmt.Sprintf("\nMode: `%s`", mode)
+	}
+	if pairs := t.orderController.DisabledPairs(); len(pairs) > 0 {
+		message += fmt.Sprintf("\nDisabled pairs: `%s`", strings.Join(pairs, ", "))
+	}
+	message += fmt.Sprintf("\nRealized PnL: `%.4f`\nUnrealized PnL: `%.4f`",
+		t.orderController.RealizedPnL(), t.orderController.UnrealizedPnL(nil))
+	if stats := t.orderController.RollingStats(); stats.Trades > 0 {
+		message += fmt.Sprintf("\nLast %d trades: `%.1f%%` win rate, `%.4f` expectancy, `%.3f` profit factor",
+			stats.Trades, stats.WinRate*100, stats.Expectancy, stats.ProfitFactor)
+	}
+
+	_, err := t.client.Send(m.Sender, message)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func (t telegram) EnablePairHandle(m *tb.Message) {
+	t.togglePair(m, true)
+}
+
+func (t telegram) DisablePairHandle(m *tb.Message) {
+	t.togglePair(m, false)
+}
+
+func (t telegram) togglePair(m *tb.Message, enabled bool) {
+	match := pairRegexp.FindStringSubmatch(m.Text)
+	if len(match) == 0 {
+		_, err := t.client.Send(m.Sender, "Invalid command.\nExamples of usage:\n`/enable BTCUSDT`\n\n`/disable BTCUSDT`")
+		if err != nil {
+			log.Error(err)
+		}
+		return
+	}
+
+	pair := strings.ToUpper(match[1])
+	action, toggle := "disabled", t.orderController.DisablePair
+	if enabled {
+		action, toggle = "enabled", t.orderController.EnablePair
+	}
+
+	if err := toggle(pair); err != nil {
+		log.Error(err)
+		t.OnError(err)
+		return
+	}
+
+	_, err := t.client.Send(m.Sender, fmt.Sprintf("Entries %s for `%s`", action, pair))
 	if err != nil {
 		log.Error(err)
 	}
@@ -355,6 +603,15 @@ func (t telegram) StopHandle(m *tb.Message) {
 }
 
 func (t telegram) OnOrder(order model.Order) {
+	t.Notify(t.OrderMessage(order))
+}
+
+func (t telegram) OnError(err error) {
+	t.Notify(t.ErrorMessage(err))
+}
+
+// OrderMessage formats the order notification with the precision of the pair, see MessageFormatter
+func (t telegram) OrderMessage(order model.Order) string {
 	title := ""
 	switch order.Status {
 	case model.OrderStatusTypeFilled:
@@ -364,24 +621,22 @@ func (t telegram) OnOrder(order model.Order) {
 	case model.OrderStatusTypeCanceled, model.OrderStatusTypeRejected:
 		title = fmt.Sprintf("❌ ORDER CANCELED / REJECTED - %s", order.Pair)
 	}
-	message := fmt.Sprintf("%s\n-----\n%s", title, order)
-	t.Notify(message)
+	return fmt.Sprintf("%s\n-----\n%s", title, t.formatter.FormatOrder(order))
 }
 
-func (t telegram) OnError(err error) {
+// ErrorMessage formats the error notification, see MessageFormatter
+func (t telegram) ErrorMessage(err error) string {
 	title := "🛑 ERROR"
 
 	var orderError *exchange.OrderError
 	if errors.As(err, &orderError) {
-		message := fmt.Sprintf(`%s
+		return fmt.Sprintf(`%s
 		-----
 		Pair: %s
-		Quantity: %.4f
+		Quantity: %s
 		-----
-		%s`, title, orderError.Pair, orderError.Quantity, orderError.Err)
-		t.Notify(message)
-		return
+		%s`, title, orderError.Pair, t.formatter.FormatQuantity(orderError.Pair, orderError.Quantity), orderError.Err)
 	}
 
-	t.Notify(fmt.Sprintf("%s\n-----\n%s", title, err))
+	return fmt.Sprintf("%s\n-----\n%s", title, err)
 }
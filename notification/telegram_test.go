@@ -0,0 +1,69 @@
+package notification
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tb "gopkg.in/tucnak/telebot.v2"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// mockClient records the messages sent by the handlers
+type mockClient struct {
+	sent []interface{}
+}
+
+func (m *mockClient) Start() {}
+
+func (m *mockClient) Send(_ tb.Recipient, what interface{}, _ ...interface{}) (*tb.Message, error) {
+	m.sent = append(m.sent, what)
+	return &tb.Message{}, nil
+}
+
+func (m *mockClient) GetCommands() ([]tb.Command, error) {
+	return nil, nil
+}
+
+func TestTelegram_ChartHandle(t *testing.T) {
+	var requested int
+	source := func(pair string, candles int) (model.Dataframe, bool) {
+		requested = candles
+		if pair != "BTCUSDT" {
+			return model.Dataframe{}, false
+		}
+		return model.Dataframe{
+			Pair: pair,
+			OHLC: model.OHLC{
+				Open:  []float64{10, 12, 11},
+				Close: []float64{12, 11, 13},
+				High:  []float64{13, 13, 14},
+				Low:   []float64{9, 10, 10},
+			},
+			Metadata: map[string]model.Series[float64]{"sma": {11, 11.5, 12}},
+		}, true
+	}
+
+	client := &mockClient{}
+	bot := telegram{client: client}
+	WithChartSource(source, "sma")(&bot)
+	sender := &tb.User{ID: 1}
+
+	bot.ChartHandle(&tb.Message{Text: "/chart btcusdt", Sender: sender})
+	require.Len(t, client.sent, 1)
+	require.Equal(t, chartCandles, requested)
+
+	photo, ok := client.sent[0].(*tb.Photo)
+	require.True(t, ok)
+	require.Equal(t, "BTCUSDT: last 3 candles (sma)", photo.Caption)
+	content, err := io.ReadAll(photo.FileReader)
+	require.NoError(t, err)
+	require.NotEmpty(t, content)
+
+	bot.ChartHandle(&tb.Message{Text: "/chart ETHUSDT", Sender: sender})
+	require.Equal(t, "Pair `ETHUSDT` not found.", client.sent[1])
+
+	bot.ChartHandle(&tb.Message{Text: "/chart", Sender: sender})
+	require.Contains(t, client.sent[2], "Invalid command.")
+}
@@ -0,0 +1,43 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultWebhookTimeout = 10 * time.Second
+
+// Webhook posts JSON payloads to an HTTP endpoint, e.g. the signals of the signal-only mode
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: defaultWebhookTimeout},
+	}
+}
+
+// Post sends the payload encoded as JSON, responses other than 2xx are returned as errors
+func (w *Webhook) Post(payload interface{}) error {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	response, err := w.client.Post(w.url, "application/json", bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s: unexpected status %d", w.url, response.StatusCode)
+	}
+	return nil
+}
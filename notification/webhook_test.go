@@ -0,0 +1,32 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestWebhook_Post(t *testing.T) {
+	var received model.Signal
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		if received.Pair == "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook(server.URL)
+	signal := model.Signal{Pair: "BTCUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeMarket, Size: 1,
+		Price: 100, Reason: "cross"}
+	require.NoError(t, webhook.Post(signal))
+	require.Equal(t, signal, received)
+
+	require.Error(t, webhook.Post(model.Signal{}))
+}
@@ -0,0 +1,119 @@
+package order
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// TimeBucket aggregates the closed trades with entry in a period of time, e.g. an hour of the day
+type TimeBucket struct {
+	Label         string
+	Trades        int
+	Wins          int
+	Profit        float64
+	ProfitPercent float64
+}
+
+func (b TimeBucket) WinPercentage() float64 {
+	if b.Trades == 0 {
+		return 0
+	}
+	return float64(b.Wins) / float64(b.Trades) * 100
+}
+
+// AvgProfitPercent returns the average return of the trades in the bucket
+func (b TimeBucket) AvgProfitPercent() float64 {
+	if b.Trades == 0 {
+		return 0
+	}
+	return b.ProfitPercent / float64(b.Trades)
+}
+
+func (b *TimeBucket) add(result Result) {
+	b.Trades++
+	if result.ProfitPercent >= 0 {
+		b.Wins++
+	}
+	b.Profit += result.ProfitValue
+	b.ProfitPercent += result.ProfitPercent
+}
+
+// TimeAttribution is the profit of closed trades grouped by the entry time in UTC, it reveals
+// session-dependent edges, e.g. a strategy that is only profitable during US hours.
+type TimeAttribution struct {
+	Hour    [24]TimeBucket
+	Weekday [7]TimeBucket
+	Month   [12]TimeBucket
+
+	// Heatmap is the profit value by weekday (rows) and hour (columns)
+	Heatmap [7][24]float64
+}
+
+// NewTimeAttribution groups the results by the hour of the day, the day of the week and the month
+// of the position entry
+func NewTimeAttribution(results []Result) TimeAttribution {
+	var attribution TimeAttribution
+	for i := range attribution.Hour {
+		attribution.Hour[i].Label = time.Date(0, 1, 1, i, 0, 0, 0, time.UTC).Format("15h")
+	}
+	for i := range attribution.Weekday {
+		attribution.Weekday[i].Label = time.Weekday(i).String()[:3]
+	}
+	for i := range attribution.Month {
+		attribution.Month[i].Label = time.Month(i + 1).String()[:3]
+	}
+
+	for _, result := range results {
+		entry := result.EntryTime().UTC()
+		attribution.Hour[entry.Hour()].add(result)
+		attribution.Weekday[entry.Weekday()].add(result)
+		attribution.Month[entry.Month()-1].add(result)
+		attribution.Heatmap[entry.Weekday()][entry.Hour()] += result.ProfitValue
+	}
+	return attribution
+}
+
+// HeatmapString renders the heatmap as text, one row per weekday and one column per hour.
+// Profits are shown as o (O above half of the largest absolute value), losses as x (X) and
+// hours without profit as a dot.
+func (t TimeAttribution) HeatmapString() string {
+	var maxValue float64
+	for _, hours := range t.Heatmap {
+		for _, value := range hours {
+			maxValue = math.Max(maxValue, math.Abs(value))
+		}
+	}
+
+	builder := &strings.Builder{}
+	builder.WriteString("    ")
+	for hour := 0; hour < 24; hour++ {
+		builder.WriteString(string("0123456789"[hour/10]))
+	}
+	builder.WriteString("\n    ")
+	for hour := 0; hour < 24; hour++ {
+		builder.WriteString(string("0123456789"[hour%10]))
+	}
+	builder.WriteString("\n")
+
+	for weekday, hours := range t.Heatmap {
+		builder.WriteString(t.Weekday[weekday].Label + " ")
+		for _, value := range hours {
+			strong := math.Abs(value) > maxValue/2
+			switch {
+			case value > 0 && strong:
+				builder.WriteString("O")
+			case value > 0:
+				builder.WriteString("o")
+			case value < 0 && strong:
+				builder.WriteString("X")
+			case value < 0:
+				builder.WriteString("x")
+			default:
+				builder.WriteString(".")
+			}
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
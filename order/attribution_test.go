@@ -0,0 +1,55 @@
+package order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTimeAttribution(t *testing.T) {
+	// 2021-01-04 is a Monday
+	monday := time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)
+	results := []Result{
+		// entry on Monday 14h, closed in the next day
+		{CreatedAt: monday.Add(38 * time.Hour), Duration: 24 * time.Hour, ProfitValue: 10, ProfitPercent: 0.5},
+		{CreatedAt: monday.Add(15 * time.Hour), Duration: time.Hour, ProfitValue: -4, ProfitPercent: -0.25},
+		// entry on Tuesday 3h
+		{CreatedAt: monday.Add(29 * time.Hour), Duration: 2 * time.Hour, ProfitValue: 6, ProfitPercent: 0.25},
+		// entry on Saturday 3h, in February
+		{CreatedAt: monday.Add(33*24*time.Hour + 3*time.Hour), ProfitValue: -2, ProfitPercent: -0.125},
+	}
+
+	attribution := NewTimeAttribution(results)
+
+	require.Equal(t, TimeBucket{Label: "14h", Trades: 2, Wins: 1, Profit: 6, ProfitPercent: 0.25},
+		attribution.Hour[14])
+	require.Equal(t, TimeBucket{Label: "03h", Trades: 2, Wins: 1, Profit: 4, ProfitPercent: 0.125},
+		attribution.Hour[3])
+	require.Equal(t, 50.0, attribution.Hour[14].WinPercentage())
+	require.Equal(t, 0.125, attribution.Hour[14].AvgProfitPercent())
+
+	require.Equal(t, "Mon", attribution.Weekday[time.Monday].Label)
+	require.Equal(t, 2, attribution.Weekday[time.Monday].Trades)
+	require.Equal(t, 6.0, attribution.Weekday[time.Monday].Profit)
+	require.Equal(t, 1, attribution.Weekday[time.Tuesday].Trades)
+	require.Equal(t, -2.0, attribution.Weekday[time.Saturday].Profit)
+
+	require.Equal(t, "Jan", attribution.Month[0].Label)
+	require.Equal(t, 3, attribution.Month[0].Trades)
+	require.Equal(t, 12.0, attribution.Month[0].Profit)
+	require.Equal(t, 1, attribution.Month[1].Trades)
+
+	require.Equal(t, 6.0, attribution.Heatmap[time.Monday][14])
+	require.Equal(t, 6.0, attribution.Heatmap[time.Tuesday][3])
+
+	for _, bucket := range attribution.Hour {
+		if bucket.Label != "14h" && bucket.Label != "03h" {
+			require.Zero(t, bucket.Trades)
+		}
+	}
+
+	heatmap := attribution.HeatmapString()
+	require.Contains(t, heatmap, "Mon ..............O.........\n")
+	require.Contains(t, heatmap, "Sat ...x....................\n")
+}
@@ -0,0 +1,79 @@
+package order
+
+import (
+	"math"
+	"sort"
+)
+
+// TopTradesFraction is the fraction of the trades in the top of the profit concentration reported in summaries
+const TopTradesFraction = 0.05
+
+// ProfitConcentration measures how much of the profit of the closed trades comes from a few trades. A high
+// concentration warns of overfitting or of a strategy that relies on rare events.
+type ProfitConcentration struct {
+	Trades int
+	Profit float64
+	// TopTrades is the number of the most profitable trades in the top fraction, at least one trade
+	TopTrades int
+	// TopShare is the fraction of the total profit from the top trades, it is zero when the total profit
+	// is not positive. Losses of the other trades can make it higher than one.
+	TopShare float64
+	// Gini is the Gini coefficient of the profits of the winning trades, from 0 when all wins are equal to
+	// close to 1 when a single trade has all the profit
+	Gini float64
+}
+
+// NewProfitConcentration returns the concentration of the profit of the results in the top fraction of the
+// trades, e.g. 0.05 for the top 5%
+func NewProfitConcentration(results []Result, topFraction float64) ProfitConcentration {
+	concentration := ProfitConcentration{Trades: len(results)}
+	if len(results) == 0 {
+		return concentration
+	}
+
+	profits := make([]float64, 0, len(results))
+	for _, result := range results {
+		profits = append(profits, result.ProfitValue)
+		concentration.Profit += result.ProfitValue
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(profits)))
+
+	concentration.TopTrades = int(math.Max(1, math.Ceil(topFraction*float64(len(profits)))))
+	if concentration.TopTrades > len(profits) {
+		concentration.TopTrades = len(profits)
+	}
+
+	if concentration.Profit > 0 {
+		var top float64
+		for _, profit := range profits[:concentration.TopTrades] {
+			top += profit
+		}
+		concentration.TopShare = top / concentration.Profit
+	}
+
+	// wins in ascending order
+	wins := make([]float64, 0, len(profits))
+	for i := len(profits) - 1; i >= 0; i-- {
+		if profits[i] > 0 {
+			wins = append(wins, profits[i])
+		}
+	}
+	concentration.Gini = gini(wins)
+	return concentration
+}
+
+// gini returns the Gini coefficient of positive values sorted in ascending order
+func gini(values []float64) float64 {
+	var sum, weighted float64
+	for i, value := range values {
+		sum += value
+		weighted += float64(i+1) * value
+	}
+
+	if sum == 0 {
+		return 0
+	}
+
+	n := float64(len(values))
+	return 2*weighted/(n*sum) - (n+1)/n
+}
@@ -0,0 +1,45 @@
+package order
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProfitConcentration(t *testing.T) {
+	// a single trade with most of the profit
+	results := make([]Result, 0)
+	for i := 0; i < 19; i++ {
+		results = append(results, Result{ProfitValue: 1})
+	}
+	results = append(results, Result{ProfitValue: 81})
+
+	concentration := NewProfitConcentration(results, 0.05)
+	require.Equal(t, 20, concentration.Trades)
+	require.Equal(t, 100.0, concentration.Profit)
+	require.Equal(t, 1, concentration.TopTrades)
+	require.InDelta(t, 0.81, concentration.TopShare, 1e-9)
+	require.InDelta(t, 0.76, concentration.Gini, 1e-9)
+
+	t.Run("evenly distributed", func(t *testing.T) {
+		results := make([]Result, 0)
+		for i := 0; i < 40; i++ {
+			results = append(results, Result{ProfitValue: 2}, Result{ProfitValue: -1})
+		}
+
+		concentration := NewProfitConcentration(results, 0.05)
+		require.Equal(t, 4, concentration.TopTrades)
+		require.InDelta(t, 0.2, concentration.TopShare, 1e-9)
+		require.InDelta(t, 0, concentration.Gini, 1e-9)
+	})
+
+	t.Run("losing trades", func(t *testing.T) {
+		concentration := NewProfitConcentration([]Result{{ProfitValue: 5}, {ProfitValue: -10}}, 0.05)
+		require.Equal(t, 0.0, concentration.TopShare)
+		require.Equal(t, 0.0, concentration.Gini)
+	})
+
+	t.Run("no trades", func(t *testing.T) {
+		require.Equal(t, ProfitConcentration{}, NewProfitConcentration(nil, 0.05))
+	})
+}
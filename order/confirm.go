@@ -0,0 +1,41 @@
+package order
+
+import (
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// SetConfirmOrder sets a callback executed before each order is submitted to the exchange, e.g. for custom
+// risk checks or an interactive confirmation. Returning false aborts the order with ErrOrderNotConfirmed.
+// The order has the requested pair, side, type, quantity and prices, after the other checks of the
+// controller. Market orders have the last price of the pair, and the quantity of orders by quote amount is
+// estimated by it. Amendments are confirmed with the new price and quantity, and orders resized for
+// insufficient funds are confirmed again with the new quantity. The controller is unlocked during the callback,
+// so it can read the controller, e.g. OpenPositions, or wait for an interactive answer without blocking the
+// updates of the orders. Nil, the default, submits the orders without confirmation.
+func (c *Controller) SetConfirmOrder(confirm func(model.Order) bool) {
+	c.confirmOrder = confirm
+}
+
+// confirm executes the confirmation callback, returning ErrOrderNotConfirmed when the order is vetoed. It must be
+// called with the controller locked, the lock is released during the callback.
+func (c *Controller) confirm(order model.Order) error {
+	if c.confirmOrder == nil {
+		return nil
+	}
+
+	if order.Type == model.OrderTypeMarket {
+		order.Price = c.lastPrice[order.Pair]
+	}
+
+	c.mtx.Unlock()
+	confirmed := c.confirmOrder(order)
+	c.mtx.Lock()
+	if confirmed {
+		return nil
+	}
+
+	c.logger.Warn("[ORDER] Order not confirmed", orderFields(order)...)
+	return fmt.Errorf("%w: %s %s %s %f", ErrOrderNotConfirmed, order.Type, order.Side, order.Pair, order.Quantity)
+}
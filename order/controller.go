@@ -2,6 +2,7 @@ package order
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -14,9 +15,22 @@ import (
 	"github.com/rodrigo-brito/ninjabot/model"
 	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
 
 	"github.com/olekukonko/tablewriter"
-	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrReduceOnlyNotSupported = errors.New("reduce-only orders not supported by the exchange")
+	ErrMaxOpenOrders          = errors.New("maximum open orders per pair reached")
+	ErrPairDisabled           = errors.New("entries disabled for the pair")
+	ErrPairDenied             = errors.New("pair in the deny list")
+	ErrInvalidAllocation      = errors.New("invalid sub-account allocation")
+	ErrInsufficientAllocation = errors.New("insufficient sub-account allocation")
+	ErrWideSpread             = errors.New("spread above the maximum")
+	ErrEquityNotSupported     = errors.New("equity history not supported by the storage")
+	ErrMaxOrderNotional       = errors.New("order value above the maximum")
+	ErrOrderNotConfirmed      = errors.New("order not confirmed")
 )
 
 type summary struct {
@@ -30,6 +44,35 @@ type summary struct {
 	LoseShort        []float64
 	LoseShortPercent []float64
 	Volume           float64
+	Trades           []Result
+
+	// profit is the exact sum of the profits, float64 sums drift after thousands of trades
+	profit model.Decimal
+}
+
+// add registers the result of a closed trade
+func (s *summary) add(result Result) {
+	s.Trades = append(s.Trades, result)
+	s.profit = s.profit.Add(model.NewDecimal(result.ProfitValue))
+
+	// TODO: replace by a slice of Result
+	if result.ProfitPercent >= 0 {
+		if result.Side == model.SideTypeBuy {
+			s.WinLong = append(s.WinLong, result.ProfitValue)
+			s.WinLongPercent = append(s.WinLongPercent, result.ProfitPercent)
+		} else {
+			s.WinShort = append(s.WinShort, result.ProfitValue)
+			s.WinShortPercent = append(s.WinShortPercent, result.ProfitPercent)
+		}
+	} else {
+		if result.Side == model.SideTypeBuy {
+			s.LoseLong = append(s.LoseLong, result.ProfitValue)
+			s.LoseLongPercent = append(s.LoseLongPercent, result.ProfitPercent)
+		} else {
+			s.LoseShort = append(s.LoseShort, result.ProfitValue)
+			s.LoseShortPercent = append(s.LoseShortPercent, result.ProfitPercent)
+		}
+	}
 }
 
 func (s summary) Win() []float64 {
@@ -48,12 +91,9 @@ func (s summary) LosePercent() []float64 {
 	return append(s.LoseLongPercent, s.LoseShortPercent...)
 }
 
+// Profit returns the sum of the profits of the closed trades, tracked with a fixed-point decimal
 func (s summary) Profit() float64 {
-	profit := 0.0
-	for _, value := range append(s.Win(), s.Lose()...) {
-		profit += value
-	}
-	return profit
+	return s.profit.Float64()
 }
 
 func (s summary) SQN() float64 {
@@ -110,6 +150,11 @@ func (s summary) WinPercentage() float64 {
 	return float64(len(s.Win())) / float64(len(s.Win())+len(s.Lose())) * 100
 }
 
+// TimeAttribution returns the profit of the closed trades of the pair grouped by entry time
+func (s summary) TimeAttribution() TimeAttribution {
+	return NewTimeAttribution(s.Trades)
+}
+
 func (s summary) String() string {
 	tableString := &strings.Builder{}
 	table := tablewriter.NewWriter(tableString)
@@ -171,14 +216,25 @@ type Result struct {
 	CreatedAt     time.Time
 }
 
+// EntryTime returns the time the position of the trade was opened
+func (r Result) EntryTime() time.Time {
+	return r.CreatedAt.Add(-r.Duration)
+}
+
 type Position struct {
 	Side      model.SideType
 	AvgPrice  float64
 	Quantity  float64
 	CreatedAt time.Time
+	// Fee is the fee of the entries of the open quantity, in the quote asset
+	Fee float64
 }
 
-func (p *Position) Update(order *model.Order) (result *Result, finished bool) {
+// Update registers a filled order in the position, with its fee in the quote asset. An order in the opposite
+// side realizes the profit of the closed quantity, net of its fee and of the share of the entry fees. When
+// the order is larger than the position (flip), the position is closed and the remainder opens a new position
+// in the side of the order.
+func (p *Position) Update(order *model.Order, fee float64) (result *Result, finished bool) {
 	price := order.Price
 	if order.Type == model.OrderTypeStopLoss || order.Type == model.OrderTypeStopLossLimit {
 		price = *order.Stop
@@ -187,35 +243,54 @@ func (p *Position) Update(order *model.Order) (result *Result, finished bool) {
 	if p.Side == order.Side {
 		p.AvgPrice = (p.AvgPrice*p.Quantity + price*order.Quantity) / (p.Quantity + order.Quantity)
 		p.Quantity += order.Quantity
-	} else {
-		if p.Quantity == order.Quantity {
-			finished = true
-		} else if p.Quantity > order.Quantity {
-			p.Quantity -= order.Quantity
-		} else {
-			p.Quantity = order.Quantity - p.Quantity
-			p.Side = order.Side
-			p.CreatedAt = order.CreatedAt
-			p.AvgPrice = price
-		}
+		p.Fee += fee
+		return nil, false
+	}
 
-		quantity := math.Min(p.Quantity, order.Quantity)
-		order.Profit = (price - p.AvgPrice) / p.AvgPrice
-		order.ProfitValue = (price - p.AvgPrice) * quantity
-
-		result = &Result{
-			CreatedAt:     order.CreatedAt,
-			Pair:          order.Pair,
-			Duration:      order.CreatedAt.Sub(p.CreatedAt),
-			ProfitPercent: order.Profit,
-			ProfitValue:   order.ProfitValue,
-			Side:          p.Side,
-		}
+	// profit of the closed quantity, short positions profit when the price goes down
+	quantity := math.Min(p.Quantity, order.Quantity)
+	entryFee := p.Fee * quantity / p.Quantity
+	exitFee := fee * quantity / order.Quantity
+	order.ProfitValue = (price - p.AvgPrice) * quantity
+	if p.Side == model.SideTypeSell {
+		order.ProfitValue = -order.ProfitValue
+	}
+	order.ProfitValue -= entryFee + exitFee
+	order.Profit = order.ProfitValue / (p.AvgPrice * quantity)
+
+	result = &Result{
+		CreatedAt:     order.CreatedAt,
+		Pair:          order.Pair,
+		Duration:      order.CreatedAt.Sub(p.CreatedAt),
+		ProfitPercent: order.Profit,
+		ProfitValue:   order.ProfitValue,
+		Side:          p.Side,
+	}
 
-		return result, finished
+	switch {
+	case p.Quantity == order.Quantity:
+		finished = true
+	case p.Quantity > order.Quantity:
+		p.Quantity -= order.Quantity
+		p.Fee -= entryFee
+	default:
+		p.Quantity = order.Quantity - p.Quantity
+		p.Side = order.Side
+		p.CreatedAt = order.CreatedAt
+		p.AvgPrice = price
+		p.Fee = fee - exitFee
 	}
 
-	return nil, false
+	return result, finished
+}
+
+// UnrealizedPnL returns the profit of the position if closed at the given price, in the quote asset
+func (p Position) UnrealizedPnL(price float64) float64 {
+	pnl := (price - p.AvgPrice) * p.Quantity
+	if p.Side == model.SideTypeSell {
+		return -pnl
+	}
+	return pnl
 }
 
 type Controller struct {
@@ -225,11 +300,36 @@ type Controller struct {
 	storage        storage.Storage
 	orderFeed      *Feed
 	notifier       service.Notifier
+	logger         log.Logger
 	Results        map[string]*summary
 	lastPrice      map[string]float64
 	tickerInterval time.Duration
 	finish         chan bool
 	status         Status
+	maxOpenOrders  int
+	maxResizes     int
+	resizes        int
+	disabledPairs  map[string]bool
+	deniedPairs    map[string]bool
+	pairsStateFile string
+	subAccounts    map[string]*SubAccount
+	maxSpread      map[string]float64
+	confirmOrder   func(model.Order) bool
+	rollingWindow  int
+	adoptOrders    bool
+
+	quantizationTolerance float64
+
+	maxOrderNotional float64
+	notionalMode     NotionalMode
+
+	baseCurrency    string
+	conversionPairs []string
+
+	equityStorage   storage.EquityStorage
+	equityInterval  time.Duration
+	equityRetention time.Duration
+	lastEquity      time.Time
 
 	position map[string]*Position
 }
@@ -242,11 +342,18 @@ func NewController(ctx context.Context, exchange service.Exchange, storage stora
 		storage:        storage,
 		exchange:       exchange,
 		orderFeed:      orderFeed,
+		logger:         log.Default(),
 		lastPrice:      make(map[string]float64),
 		Results:        make(map[string]*summary),
 		tickerInterval: time.Second,
 		finish:         make(chan bool),
 		position:       make(map[string]*Position),
+		disabledPairs:  make(map[string]bool),
+		deniedPairs:    make(map[string]bool),
+		subAccounts:    make(map[string]*SubAccount),
+		maxSpread:      make(map[string]float64),
+
+		quantizationTolerance: defaultQuantizationTolerance,
 	}
 }
 
@@ -254,11 +361,34 @@ func (c *Controller) SetNotifier(notifier service.Notifier) {
 	c.notifier = notifier
 }
 
+// SetLogger replaces the default logger, see log.Logger
+func (c *Controller) SetLogger(logger log.Logger) {
+	c.logger = logger
+}
+
+// SetMaxOpenOrders limits the number of simultaneously open orders per pair, orders beyond
+// the limit are rejected with ErrMaxOpenOrders. Reduce-only orders and orders that reduce the position,
+// e.g. stops and OCO exits, are not limited. Zero or a negative value disables the limit.
+func (c *Controller) SetMaxOpenOrders(limit int) {
+	c.maxOpenOrders = limit
+}
+
+// SetAutoResize enables the resize of orders rejected by insufficient funds, e.g. when fees or a concurrent
+// fill consume part of the balance. The order is resubmitted once with the maximum affordable quantity,
+// respecting the step size, minimum quantity and minimum notional of the pair. The resizes are limited
+// to the given number during the bot execution, zero disables it.
+func (c *Controller) SetAutoResize(maxResizes int) {
+	c.maxResizes = maxResizes
+}
+
 func (c *Controller) OnCandle(candle model.Candle) {
 	c.lastPrice[candle.Pair] = candle.Close
+	c.recordEquity(candle.Time)
 }
 
 func (c *Controller) updatePosition(o *model.Order) {
+	fee := c.quoteFee(*o)
+
 	// get filled orders before the current order
 	position, ok := c.position[o.Pair]
 	if !ok {
@@ -267,34 +397,18 @@ func (c *Controller) updatePosition(o *model.Order) {
 			Quantity:  o.Quantity,
 			CreatedAt: o.CreatedAt,
 			Side:      o.Side,
+			Fee:       fee,
 		}
 		return
 	}
 
-	result, closed := position.Update(o)
+	result, closed := position.Update(o, fee)
 	if closed {
 		delete(c.position, o.Pair)
 	}
 
 	if result != nil {
-		// TODO: replace by a slice of Result
-		if result.ProfitPercent >= 0 {
-			if result.Side == model.SideTypeBuy {
-				c.Results[o.Pair].WinLong = append(c.Results[o.Pair].WinLong, result.ProfitValue)
-				c.Results[o.Pair].WinLongPercent = append(c.Results[o.Pair].WinLongPercent, result.ProfitPercent)
-			} else {
-				c.Results[o.Pair].WinShort = append(c.Results[o.Pair].WinShort, result.ProfitValue)
-				c.Results[o.Pair].WinShortPercent = append(c.Results[o.Pair].WinShortPercent, result.ProfitPercent)
-			}
-		} else {
-			if result.Side == model.SideTypeBuy {
-				c.Results[o.Pair].LoseLong = append(c.Results[o.Pair].LoseLong, result.ProfitValue)
-				c.Results[o.Pair].LoseLongPercent = append(c.Results[o.Pair].LoseLongPercent, result.ProfitPercent)
-			} else {
-				c.Results[o.Pair].LoseShort = append(c.Results[o.Pair].LoseShort, result.ProfitValue)
-				c.Results[o.Pair].LoseShortPercent = append(c.Results[o.Pair].LoseShortPercent, result.ProfitPercent)
-			}
-		}
+		c.Results[o.Pair].add(*result)
 
 		_, quote := exchange.SplitAssetQuote(o.Pair)
 		c.notify(fmt.Sprintf(
@@ -304,18 +418,48 @@ func (c *Controller) updatePosition(o *model.Order) {
 			result.ProfitPercent*100,
 			c.Results[o.Pair].String(),
 		))
+
+		// the remainder of the order opened a position in the opposite side
+		if !closed && position.Side != result.Side {
+			c.notify(fmt.Sprintf("[POSITION] %s flipped to %s: %f @ %f", o.Pair, position.Side,
+				position.Quantity, position.AvgPrice))
+		}
+	}
+}
+
+// quoteFee returns the fee of the order in the quote asset. Fees charged in other assets, e.g. BNB, are
+// converted with the last price of the conversion pair, and ignored while the price is unknown.
+func (c *Controller) quoteFee(order model.Order) float64 {
+	fee, err := quoteFee(order, c.lastPrice)
+	if err != nil {
+		c.logger.Warn("[FEE] Fee not converted to the quote asset", "id", order.ExchangeID,
+			"asset", order.FeeAsset, "fee", order.AssetFee, "error", err)
+		return order.Fee
+	}
+	return fee
+}
+
+func orderFields(order model.Order) []interface{} {
+	return []interface{}{
+		"id", order.ExchangeID,
+		"pair", order.Pair,
+		"side", order.Side,
+		"type", order.Type,
+		"status", order.Status,
+		"quantity", order.Quantity,
+		"price", order.Price,
 	}
 }
 
 func (c *Controller) notify(message string) {
-	log.Info(message)
+	c.logger.Info(message)
 	if c.notifier != nil {
 		c.notifier.Notify(message)
 	}
 }
 
 func (c *Controller) notifyError(err error) {
-	log.Error(err)
+	c.logger.Error(err.Error())
 	if c.notifier != nil {
 		c.notifier.OnError(err)
 	}
@@ -354,12 +498,16 @@ func (c *Controller) updateOrders() {
 		return
 	}
 
-	// For each pending order, check for updates
+	c.syncOrders(orders)
+}
+
+// syncOrders checks the exchange for updates of the given orders, the changes are stored and published
+func (c *Controller) syncOrders(orders []*model.Order) {
 	var updatedOrders []model.Order
 	for _, order := range orders {
 		excOrder, err := c.exchange.Order(order.Pair, order.ExchangeID)
 		if err != nil {
-			log.WithField("id", order.ExchangeID).Error("orderControler/get: ", err)
+			c.logger.Error("orderControler/get: "+err.Error(), "id", order.ExchangeID)
 			continue
 		}
 
@@ -369,22 +517,114 @@ func (c *Controller) updateOrders() {
 		}
 
 		excOrder.ID = order.ID
+		excOrder.Strategy = order.Strategy
 		err = c.storage.UpdateOrder(&excOrder)
 		if err != nil {
 			c.notifyError(err)
 			continue
 		}
 
-		log.Infof("[ORDER %s] %s", excOrder.Status, excOrder)
+		c.logger.Info(fmt.Sprintf("[ORDER %s]", excOrder.Status), orderFields(excOrder)...)
 		updatedOrders = append(updatedOrders, excOrder)
 	}
 
 	for _, processOrder := range updatedOrders {
 		c.processTrade(&processOrder)
+		c.updateSubAccount(processOrder)
 		c.orderFeed.Publish(processOrder, false)
 	}
 }
 
+// resize returns the maximum affordable quantity of an order rejected by insufficient funds, see SetAutoResize.
+// Buy orders are limited by the free quote balance at the given price (the last price when zero) and sell
+// orders by the free asset balance.
+func (c *Controller) resize(err error, side model.SideType, pair string, size, price float64) (float64, bool) {
+	if err == nil || c.resizes >= c.maxResizes || !exchange.IsInsufficientFunds(err) {
+		return 0, false
+	}
+
+	if price == 0 {
+		var lastErr error
+		price, lastErr = c.lastQuote(pair)
+		if lastErr != nil || price <= 0 {
+			return 0, false
+		}
+	}
+
+	account, accountErr := c.exchange.Account()
+	if accountErr != nil {
+		return 0, false
+	}
+
+	assetTick, quoteTick := exchange.SplitAssetQuote(pair)
+	asset, quote := account.Balance(assetTick, quoteTick)
+	quantity := asset.Free
+	if side == model.SideTypeBuy {
+		quantity = quote.Free / price
+	}
+
+	info := c.exchange.AssetsInfo(pair)
+	quantity = info.RoundQuantity(quantity)
+
+	if quantity <= 0 || quantity >= size || quantity < info.MinQuantity || quantity*price < info.MinNotional {
+		c.logger.Warn("[ORDER] Insufficient funds, order can not be resized", "pair", pair, "side", side,
+			"quantity", size, "affordable", quantity)
+		return 0, false
+	}
+
+	c.resizes++
+	c.logger.Warn("[ORDER] Insufficient funds, resizing order", "pair", pair, "side", side, "quantity", size,
+		"resized", quantity, "resizes", c.resizes, "limit", c.maxResizes)
+	return quantity, true
+}
+
+// openOrders returns the orders of a pair waiting for execution
+func (c *Controller) openOrders(pair string) ([]*model.Order, error) {
+	return c.storage.Orders(storage.WithPair(pair), storage.WithStatusIn(
+		model.OrderStatusTypeNew,
+		model.OrderStatusTypePartiallyFilled,
+	))
+}
+
+// reducesPosition returns true when the order closes all or part of the tracked position of the pair without
+// opening a position in the opposite side. The size is unknown when zero, e.g. orders in quote amount.
+// The safety limits of the controller, i.e. the maximum open orders, order notional and spread, skip these
+// orders, so positions can always be closed.
+func (c *Controller) reducesPosition(side model.SideType, pair string, size float64) bool {
+	position, ok := c.position[pair]
+	// small tolerance to absorb float errors of the position quantity
+	return ok && size > 0 && position.Side != side && size <= position.Quantity*(1+1e-9)
+}
+
+// checkOpenOrders returns ErrMaxOpenOrders when the pair can not receive the given number of new orders.
+// Open orders are synced with the exchange before rejecting, since they may be filled or canceled
+// since the last update. Orders that reduce the position are not limited, see reducesPosition.
+func (c *Controller) checkOpenOrders(side model.SideType, pair string, size float64, count int) error {
+	if c.maxOpenOrders <= 0 || c.reducesPosition(side, pair, size) {
+		return nil
+	}
+
+	orders, err := c.openOrders(pair)
+	if err != nil {
+		return err
+	}
+
+	if len(orders)+count > c.maxOpenOrders {
+		c.syncOrders(orders)
+		orders, err = c.openOrders(pair)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(orders)+count > c.maxOpenOrders {
+		c.logger.Warn("[ORDER] Maximum open orders reached", "pair", pair, "open", len(orders),
+			"limit", c.maxOpenOrders)
+		return fmt.Errorf("%w: %d open orders for %s", ErrMaxOpenOrders, len(orders), pair)
+	}
+	return nil
+}
+
 func (c *Controller) Status() Status {
 	return c.status
 }
@@ -404,7 +644,7 @@ func (c *Controller) Start() {
 				}
 			}
 		}()
-		log.Info("Bot started.")
+		c.logger.Info("Bot started.")
 	}
 }
 
@@ -413,7 +653,7 @@ func (c *Controller) Stop() {
 		c.status = StatusStopped
 		c.updateOrders()
 		c.finish <- true
-		log.Info("Bot stopped.")
+		c.logger.Info("Bot stopped.")
 	}
 }
 
@@ -425,6 +665,11 @@ func (c *Controller) Position(pair string) (asset, quote float64, err error) {
 	return c.exchange.Position(pair)
 }
 
+// AssetsInfo returns the precision and limits of the pair in the exchange
+func (c *Controller) AssetsInfo(pair string) model.AssetInfo {
+	return c.exchange.AssetsInfo(pair)
+}
+
 func (c *Controller) LastQuote(pair string) (float64, error) {
 	return c.exchange.LastQuote(c.ctx, pair)
 }
@@ -437,28 +682,123 @@ func (c *Controller) PositionValue(pair string) (float64, error) {
 	return asset * c.lastPrice[pair], nil
 }
 
+// SimulateOrder estimates the fill price, fees and resulting balances of an order without submitting it,
+// e.g. for a final decision of the strategy. A zero price estimates a market order. It requires an exchange
+// that implements service.OrderSimulator, e.g. the paper wallet, otherwise exchange.ErrSimulationNotSupported is
+// returned, e.g. by Binance in live trading.
+func (c *Controller) SimulateOrder(pair string, side model.SideType, quantity,
+	price float64) (model.OrderPreview, error) {
+	simulator, ok := c.exchange.(service.OrderSimulator)
+	if !ok {
+		return model.OrderPreview{}, exchange.ErrSimulationNotSupported
+	}
+	return simulator.SimulateOrder(pair, side, quantity, price)
+}
+
+// OpenPositions returns a copy of the open positions by pair
+func (c *Controller) OpenPositions() map[string]Position {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	positions := make(map[string]Position, len(c.position))
+	for pair, position := range c.position {
+		positions[pair] = *position
+	}
+	return positions
+}
+
+// RealizedPnL returns the profit of the closed quantities of all pairs, in the quote asset. Closing part or
+// all of a position moves its profit from UnrealizedPnL to RealizedPnL.
+func (c *Controller) RealizedPnL() float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var pnl model.Decimal
+	for _, summary := range c.Results {
+		pnl = pnl.Add(summary.profit)
+	}
+	return pnl.Float64()
+}
+
+// UnrealizedPnL returns the profit of the open positions of all pairs at the given prices, in the quote asset.
+// Pairs without price in the map are valued at the close of the last candle.
+func (c *Controller) UnrealizedPnL(prices map[string]float64) float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var pnl float64
+	for pair, position := range c.position {
+		price, ok := prices[pair]
+		if !ok {
+			price = c.lastPrice[pair]
+		}
+		pnl += position.UnrealizedPnL(price)
+	}
+	return pnl
+}
+
 func (c *Controller) Order(pair string, id int64) (model.Order, error) {
 	return c.exchange.Order(pair, id)
 }
 
 func (c *Controller) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	return c.createOrderOCO(nil, side, pair, size, price, stop, stopLimit)
+}
+
+func (c *Controller) createOrderOCO(owner *SubAccount, side model.SideType, pair string, size, price, stop,
 	stopLimit float64) ([]model.Order, error) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Creating OCO order for %s", pair)
+	if err := c.checkOpenOrders(side, pair, size, 2); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkPairEntry(side, pair, size); err != nil {
+		return nil, err
+	}
+
+	// the allocation of the sub-account is checked with the size limited by the maximum notional
+	size, err := c.checkNotional(side, pair, size, math.Max(price, stopLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.checkAllocation(owner, side, pair, size, math.Max(price, stopLimit)); err != nil {
+		return nil, err
+	}
+
+	c.checkQuantization(side, pair, size)
+	stopPrice := stop
+	request := model.Order{Pair: pair, Side: side, Type: model.OrderTypeLimitMaker, Quantity: size, Price: price,
+		Stop: &stopPrice}
+	if err := c.confirm(request); err != nil {
+		return nil, err
+	}
+
+	c.logger.Info("[ORDER] Creating OCO order", "pair", pair, "side", side, "quantity", size)
 	orders, err := c.exchange.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
+	if resized, ok := c.resize(err, side, pair, size, price); ok {
+		request.Quantity = resized
+		if err := c.confirm(request); err != nil {
+			return nil, err
+		}
+		orders, err = c.exchange.CreateOrderOCO(side, pair, resized, price, stop, stopLimit)
+	}
 	if err != nil {
 		c.notifyError(err)
 		return nil, err
 	}
 
 	for i := range orders {
+		orders[i].Strategy = owner.Name()
 		err := c.storage.CreateOrder(&orders[i])
 		if err != nil {
 			c.notifyError(err)
 			return nil, err
 		}
+		c.updateSubAccount(orders[i])
 		go c.orderFeed.Publish(orders[i], true)
 	}
 
@@ -466,100 +806,452 @@ func (c *Controller) CreateOrderOCO(side model.SideType, pair string, size, pric
 }
 
 func (c *Controller) CreateOrderLimit(side model.SideType, pair string, size, limit float64) (model.Order, error) {
+	return c.createOrderLimit(nil, side, pair, size, limit)
+}
+
+func (c *Controller) createOrderLimit(owner *SubAccount, side model.SideType, pair string,
+	size, limit float64) (model.Order, error) {
+
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Creating LIMIT %s order for %s", side, pair)
+	if err := c.checkOpenOrders(side, pair, size, 1); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkPairEntry(side, pair, size); err != nil {
+		return model.Order{}, err
+	}
+
+	size, err := c.checkNotional(side, pair, size, limit)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkAllocation(owner, side, pair, size, limit); err != nil {
+		return model.Order{}, err
+	}
+
+	c.checkQuantization(side, pair, size)
+	request := model.Order{Pair: pair, Side: side, Type: model.OrderTypeLimit, Quantity: size, Price: limit}
+	if err := c.confirm(request); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Info("[ORDER] Creating LIMIT order", "pair", pair, "side", side, "quantity", size, "price", limit)
 	order, err := c.exchange.CreateOrderLimit(side, pair, size, limit)
+	if resized, ok := c.resize(err, side, pair, size, limit); ok {
+		request.Quantity = resized
+		if err := c.confirm(request); err != nil {
+			return model.Order{}, err
+		}
+		order, err = c.exchange.CreateOrderLimit(side, pair, resized, limit)
+	}
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
 
+	order.Strategy = owner.Name()
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
+	c.updateSubAccount(order)
 	go c.orderFeed.Publish(order, true)
-	log.Infof("[ORDER CREATED] %s", order)
+	c.logger.Info("[ORDER CREATED]", orderFields(order)...)
 	return order, nil
 }
 
 func (c *Controller) CreateOrderMarketQuote(side model.SideType, pair string, amount float64) (model.Order, error) {
+	return c.createOrderMarketQuote(nil, side, pair, amount)
+}
+
+func (c *Controller) createOrderMarketQuote(owner *SubAccount, side model.SideType, pair string,
+	amount float64) (model.Order, error) {
+
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Creating MARKET %s order for %s", side, pair)
+	// quantity estimated with the last price, zero when unknown
+	var quantity float64
+	if price := c.lastPrice[pair]; price > 0 {
+		quantity = amount / price
+	}
+
+	if err := c.checkOpenOrders(side, pair, quantity, 1); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkPairEntry(side, pair, 0); err != nil {
+		return model.Order{}, err
+	}
+
+	amount, err := c.checkNotionalQuote(side, pair, amount)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkAllocationQuote(owner, side, pair, amount); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkSpread(side, pair, quantity); err != nil {
+		return model.Order{}, err
+	}
+
+	// the amount may be clamped by the maximum order notional
+	if price := c.lastPrice[pair]; price > 0 {
+		quantity = amount / price
+	}
+	if err := c.confirm(model.Order{Pair: pair, Side: side, Type: model.OrderTypeMarket,
+		Quantity: quantity}); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Info("[ORDER] Creating MARKET order", "pair", pair, "side", side, "amount", amount)
 	order, err := c.exchange.CreateOrderMarketQuote(side, pair, amount)
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
 
+	order.Strategy = owner.Name()
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
+	c.updateSubAccount(order)
 
 	// calculate profit
 	c.processTrade(&order)
 	go c.orderFeed.Publish(order, true)
-	log.Infof("[ORDER CREATED] %s", order)
+	c.logger.Info("[ORDER CREATED]", orderFields(order)...)
 	return order, err
 }
 
 func (c *Controller) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return c.createOrderMarket(nil, side, pair, size)
+}
+
+func (c *Controller) createOrderMarket(owner *SubAccount, side model.SideType, pair string,
+	size float64) (model.Order, error) {
+
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Creating MARKET %s order for %s", side, pair)
+	if err := c.checkOpenOrders(side, pair, size, 1); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkPairEntry(side, pair, size); err != nil {
+		return model.Order{}, err
+	}
+
+	size, err := c.checkNotional(side, pair, size, 0)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkAllocation(owner, side, pair, size, 0); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkSpread(side, pair, size); err != nil {
+		return model.Order{}, err
+	}
+
+	c.checkQuantization(side, pair, size)
+	request := model.Order{Pair: pair, Side: side, Type: model.OrderTypeMarket, Quantity: size}
+	if err := c.confirm(request); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Info("[ORDER] Creating MARKET order", "pair", pair, "side", side, "quantity", size)
 	order, err := c.exchange.CreateOrderMarket(side, pair, size)
+	if resized, ok := c.resize(err, side, pair, size, 0); ok {
+		request.Quantity = resized
+		if err := c.confirm(request); err != nil {
+			return model.Order{}, err
+		}
+		order, err = c.exchange.CreateOrderMarket(side, pair, resized)
+	}
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
 
+	order.Strategy = owner.Name()
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
+	c.updateSubAccount(order)
 
 	// calculate profit
 	c.processTrade(&order)
 	go c.orderFeed.Publish(order, true)
-	log.Infof("[ORDER CREATED] %s", order)
+	c.logger.Info("[ORDER CREATED]", orderFields(order)...)
 	return order, err
 }
 
+// CreateOrderMarketReduceOnly creates a market order capped to the current position size,
+// it requires an exchange that implements service.ReduceOnlyBroker
+func (c *Controller) CreateOrderMarketReduceOnly(side model.SideType, pair string,
+	size float64) (model.Order, error) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	broker, ok := c.exchange.(service.ReduceOnlyBroker)
+	if !ok {
+		return model.Order{}, ErrReduceOnlyNotSupported
+	}
+
+	c.checkQuantization(side, pair, size)
+	if err := c.confirm(model.Order{Pair: pair, Side: side, Type: model.OrderTypeMarket, Quantity: size,
+		ReduceOnly: true}); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Info("[ORDER] Creating MARKET reduce-only order", "pair", pair, "side", side, "quantity", size)
+	order, err := broker.CreateOrderMarketReduceOnly(side, pair, size)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+
+	err = c.storage.CreateOrder(&order)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+
+	// calculate profit
+	c.processTrade(&order)
+	go c.orderFeed.Publish(order, true)
+	c.logger.Info("[ORDER CREATED]", orderFields(order)...)
+	return order, nil
+}
+
+// CreateOrderLimitReduceOnly creates a limit order capped to the current position size,
+// it requires an exchange that implements service.ReduceOnlyBroker
+func (c *Controller) CreateOrderLimitReduceOnly(side model.SideType, pair string,
+	size, limit float64) (model.Order, error) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	broker, ok := c.exchange.(service.ReduceOnlyBroker)
+	if !ok {
+		return model.Order{}, ErrReduceOnlyNotSupported
+	}
+
+	c.checkQuantization(side, pair, size)
+	if err := c.confirm(model.Order{Pair: pair, Side: side, Type: model.OrderTypeLimit, Quantity: size,
+		Price: limit, ReduceOnly: true}); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Info("[ORDER] Creating LIMIT reduce-only order", "pair", pair, "side", side, "quantity", size,
+		"price", limit)
+	order, err := broker.CreateOrderLimitReduceOnly(side, pair, size, limit)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+
+	err = c.storage.CreateOrder(&order)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+	go c.orderFeed.Publish(order, true)
+	c.logger.Info("[ORDER CREATED]", orderFields(order)...)
+	return order, nil
+}
+
 func (c *Controller) CreateOrderStop(pair string, size float64, limit float64) (model.Order, error) {
+	return c.createOrderStop(nil, pair, size, limit)
+}
+
+func (c *Controller) createOrderStop(owner *SubAccount, pair string, size float64, limit float64) (model.Order, error) {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Creating STOP order for %s", pair)
+	if err := c.checkOpenOrders(model.SideTypeSell, pair, size, 1); err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkPairEntry(model.SideTypeSell, pair, size); err != nil {
+		return model.Order{}, err
+	}
+
+	size, err := c.checkNotional(model.SideTypeSell, pair, size, limit)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	if err := c.checkAllocation(owner, model.SideTypeSell, pair, size, limit); err != nil {
+		return model.Order{}, err
+	}
+
+	c.checkQuantization(model.SideTypeSell, pair, size)
+	stop := limit
+	request := model.Order{Pair: pair, Side: model.SideTypeSell, Type: model.OrderTypeStopLoss, Quantity: size,
+		Stop: &stop}
+	if err := c.confirm(request); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Info("[ORDER] Creating STOP order", "pair", pair, "quantity", size, "stop", limit)
 	order, err := c.exchange.CreateOrderStop(pair, size, limit)
+	if resized, ok := c.resize(err, model.SideTypeSell, pair, size, limit); ok {
+		request.Quantity = resized
+		if err := c.confirm(request); err != nil {
+			return model.Order{}, err
+		}
+		order, err = c.exchange.CreateOrderStop(pair, resized, limit)
+	}
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
 
+	order.Strategy = owner.Name()
 	err = c.storage.CreateOrder(&order)
 	if err != nil {
 		c.notifyError(err)
 		return model.Order{}, err
 	}
+	c.updateSubAccount(order)
 	go c.orderFeed.Publish(order, true)
-	log.Infof("[ORDER CREATED] %s", order)
+	c.logger.Info("[ORDER CREATED]", orderFields(order)...)
 	return order, nil
 }
 
+// AmendOrder changes the price and quantity of an open limit order, a zero value keeps the current price or
+// quantity. The new values are rounded to the tick and step size of the pair. Exchanges that implement
+// service.OrderAmender amend the order in place, otherwise the order is cancelled and replaced by a new limit
+// order, with a new id. When the replacement is rejected, the original order is restored with a new id and
+// returned with the error.
+func (c *Controller) AmendOrder(order model.Order, price, quantity float64) (model.Order, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if order.Type != model.OrderTypeLimit || order.Status != model.OrderStatusTypeNew {
+		return model.Order{}, exchange.ErrOrderNotAmendable
+	}
+
+	if price == 0 {
+		price = order.Price
+	}
+	if quantity == 0 {
+		quantity = order.Quantity
+	}
+
+	info := c.exchange.AssetsInfo(order.Pair)
+	c.checkQuantization(order.Side, order.Pair, quantity)
+	price, quantity = info.RoundPrice(price), info.RoundQuantity(quantity)
+	quantity, err := c.checkNotional(order.Side, order.Pair, quantity, price)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	change := order
+	change.Price, change.Quantity = price, quantity
+	if err := c.confirm(change); err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Info("[ORDER] Amending order", append(orderFields(order), "new_price", price,
+		"new_quantity", quantity)...)
+	amender, ok := c.exchange.(service.OrderAmender)
+	if !ok {
+		return c.replaceOrder(order, price, quantity)
+	}
+
+	amended, err := amender.AmendOrder(order, price, quantity)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+
+	amended.ID = order.ID
+	amended.Strategy = order.Strategy
+	err = c.storage.UpdateOrder(&amended)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+	c.amendSubAccount(amended)
+	go c.orderFeed.Publish(amended, false)
+	c.logger.Info("[ORDER AMENDED]", orderFields(amended)...)
+	return amended, nil
+}
+
+// replaceOrder cancels the order and creates a limit order with the new price and quantity
+func (c *Controller) replaceOrder(order model.Order, price, quantity float64) (model.Order, error) {
+	err := c.exchange.Cancel(order)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+
+	order.Status = model.OrderStatusTypePendingCancel
+	err = c.storage.UpdateOrder(&order)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+
+	replacement, err := c.exchange.CreateOrderLimit(order.Side, order.Pair, quantity, price)
+	if err != nil {
+		c.notifyError(err)
+		return c.restoreOrder(order, err)
+	}
+
+	replacement.Strategy = order.Strategy
+	err = c.storage.CreateOrder(&replacement)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+	c.updateSubAccount(replacement)
+	go c.orderFeed.Publish(replacement, true)
+	c.logger.Info("[ORDER REPLACED]", append(orderFields(replacement), "replaced", order.ExchangeID)...)
+	return replacement, nil
+}
+
+// restoreOrder creates again a cancelled order whose replacement was rejected, with the original price and
+// quantity. The restored order is returned with the error of the replacement.
+func (c *Controller) restoreOrder(order model.Order, replaceErr error) (model.Order, error) {
+	restored, err := c.exchange.CreateOrderLimit(order.Side, order.Pair, order.Quantity, order.Price)
+	if err != nil {
+		err = fmt.Errorf("order %d cancelled and not restored: %w", order.ExchangeID, err)
+		c.notifyError(err)
+		return model.Order{}, fmt.Errorf("%w, %s", replaceErr, err)
+	}
+
+	restored.Strategy = order.Strategy
+	if err := c.storage.CreateOrder(&restored); err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+	c.updateSubAccount(restored)
+	go c.orderFeed.Publish(restored, true)
+	c.logger.Info("[ORDER RESTORED]", append(orderFields(restored), "replaced", order.ExchangeID)...)
+	return restored, replaceErr
+}
+
 func (c *Controller) Cancel(order model.Order) error {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	log.Infof("[ORDER] Cancelling order for %s", order.Pair)
+	c.logger.Info("[ORDER] Cancelling order", orderFields(order)...)
 	err := c.exchange.Cancel(order)
 	if err != nil {
 		return err
@@ -571,6 +1263,6 @@ func (c *Controller) Cancel(order model.Order) error {
 		c.notifyError(err)
 		return err
 	}
-	log.Infof("[ORDER CANCELED] %s", order)
+	c.logger.Info("[ORDER CANCELED]", orderFields(order)...)
 	return nil
 }
@@ -2,6 +2,10 @@ package order
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +14,7 @@ import (
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
 	"github.com/rodrigo-brito/ninjabot/storage"
 )
 
@@ -57,6 +62,38 @@ func TestController_updatePosition(t *testing.T) {
 		assert.Equal(t, -0.5, order.Profit)
 	})
 
+	t.Run("fees", func(t *testing.T) {
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000),
+			exchange.WithPaperFee(0, 0.001))
+		controller := NewController(ctx, wallet, storage, NewOrderFeed())
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Equal(t, 1.0, controller.position["BTCUSDT"].Fee)
+
+		// the profit is net of the exit fee and of the half of the entry fee
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 2000})
+		order, err := controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.5)
+		require.NoError(t, err)
+		require.InDelta(t, 500-0.5-1, order.ProfitValue, 1e-9)
+		require.InDelta(t, 0.5, controller.position["BTCUSDT"].Fee, 1e-9)
+
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+		order, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 0.5)
+		require.NoError(t, err)
+		require.InDelta(t, -0.5-0.5, order.ProfitValue, 1e-9)
+		require.InDelta(t, -0.002, order.Profit, 1e-9)
+
+		// the wallet balance matches the realized profit
+		_, quote, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.InDelta(t, 3000+498.5-1, quote, 1e-9)
+	})
+
 	t.Run("limit order", func(t *testing.T) {
 		storage, err := storage.FromMemory()
 		require.NoError(t, err)
@@ -178,6 +215,70 @@ func TestController_updatePosition(t *testing.T) {
 		assert.Equal(t, 1500.0, controller.position["BTCUSDT"].AvgPrice)
 		assert.Equal(t, 1.0, controller.position["BTCUSDT"].Quantity)
 	})
+
+	t.Run("flip", func(t *testing.T) {
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 5000))
+		controller := NewController(ctx, wallet, storage, NewOrderFeed())
+		logger := &capturingLogger{}
+		controller.SetLogger(logger)
+
+		events := func() []string {
+			messages := make([]string, 0)
+			for _, entry := range logger.entries {
+				if strings.HasPrefix(entry.msg, "[PROFIT]") || strings.HasPrefix(entry.msg, "[POSITION]") {
+					messages = append(messages, strings.Split(entry.msg, "\n")[0])
+				}
+			}
+			logger.entries = nil
+			return messages
+		}
+
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000})
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		require.Empty(t, events())
+
+		// sell 3 BTC: close the long of 1 BTC with profit and open a short of 2 BTC
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1500})
+		order, err := controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 3)
+		require.NoError(t, err)
+
+		require.Equal(t, 500.0, order.ProfitValue)
+		require.Equal(t, 0.5, order.Profit)
+		require.Equal(t, []float64{500}, controller.Results["BTCUSDT"].WinLong)
+		require.Equal(t, model.SideTypeSell, controller.position["BTCUSDT"].Side)
+		require.Equal(t, 2.0, controller.position["BTCUSDT"].Quantity)
+		require.Equal(t, 1500.0, controller.position["BTCUSDT"].AvgPrice)
+		require.Equal(t, []string{
+			"[PROFIT] 500.000000 USDT (50.000000 %)",
+			"[POSITION] BTCUSDT flipped to SELL: 2.000000 @ 1500.000000",
+		}, events())
+
+		// buy 3 BTC: close the short of 2 BTC with profit and open a long of 1 BTC
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1200})
+		order, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 3)
+		require.NoError(t, err)
+
+		require.Equal(t, 600.0, order.ProfitValue)
+		require.Equal(t, 0.2, order.Profit)
+		require.Equal(t, []float64{600}, controller.Results["BTCUSDT"].WinShort)
+		require.Equal(t, model.SideTypeBuy, controller.position["BTCUSDT"].Side)
+		require.Equal(t, 1.0, controller.position["BTCUSDT"].Quantity)
+		require.Equal(t, 1200.0, controller.position["BTCUSDT"].AvgPrice)
+		require.Equal(t, []string{
+			"[PROFIT] 600.000000 USDT (20.000000 %)",
+			"[POSITION] BTCUSDT flipped to BUY: 1.000000 @ 1200.000000",
+		}, events())
+
+		// the wallet balance matches the realized profit
+		assets, quote, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 1.0, assets)
+		require.InDelta(t, 5000+500+600-1200, quote, 1e-9)
+	})
 }
 
 func TestController_PositionValue(t *testing.T) {
@@ -222,3 +323,1287 @@ func TestController_Position(t *testing.T) {
 	assert.Equal(t, 1.0, asset)
 	assert.Equal(t, 1500.0, quote)
 }
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields []interface{}
+}
+
+type capturingLogger struct {
+	entries []logEntry
+}
+
+func (l *capturingLogger) add(level, msg string, fields []interface{}) {
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, fields: fields})
+}
+
+func (l *capturingLogger) Debug(msg string, fields ...interface{}) { l.add("debug", msg, fields) }
+func (l *capturingLogger) Info(msg string, fields ...interface{})  { l.add("info", msg, fields) }
+func (l *capturingLogger) Warn(msg string, fields ...interface{})  { l.add("warn", msg, fields) }
+func (l *capturingLogger) Error(msg string, fields ...interface{}) { l.add("error", msg, fields) }
+
+func TestController_SetLogger(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 3000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+
+	logger := &capturingLogger{}
+	controller.SetLogger(logger)
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+	order, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	require.Len(t, logger.entries, 2)
+	require.Equal(t, logEntry{
+		level:  "info",
+		msg:    "[ORDER] Creating MARKET order",
+		fields: []interface{}{"pair", "BTCUSDT", "side", model.SideTypeBuy, "quantity", 1.0},
+	}, logger.entries[0])
+	require.Equal(t, logEntry{
+		level: "info",
+		msg:   "[ORDER CREATED]",
+		fields: []interface{}{
+			"id", order.ExchangeID,
+			"pair", "BTCUSDT",
+			"side", model.SideTypeBuy,
+			"type", model.OrderTypeMarket,
+			"status", model.OrderStatusTypeFilled,
+			"quantity", 1.0,
+			"price", 1000.0,
+		},
+	}, logger.entries[1])
+}
+
+func TestController_SetMaxOpenOrders(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	controller.SetMaxOpenOrders(2)
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+	wallet.OnCandle(model.Candle{Pair: "ETHUSDT", Close: 100})
+
+	first, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 900)
+	require.NoError(t, err)
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 800)
+	require.NoError(t, err)
+
+	// limit reached for the pair
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 700)
+	require.ErrorIs(t, err, ErrMaxOpenOrders)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrMaxOpenOrders)
+
+	// other pairs are not affected
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "ETHUSDT", 1, 90)
+	require.NoError(t, err)
+
+	// canceled orders release the limit
+	require.NoError(t, controller.Cancel(first))
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 700)
+	require.NoError(t, err)
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 600)
+	require.ErrorIs(t, err, ErrMaxOpenOrders)
+
+	// filled orders release the limit
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 750})
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 600)
+	require.NoError(t, err)
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 500)
+	require.ErrorIs(t, err, ErrMaxOpenOrders)
+
+	// exits of the position are not limited, orders larger than the position are
+	_, err = controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 2, 2000)
+	require.ErrorIs(t, err, ErrMaxOpenOrders)
+	_, err = controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 2000)
+	require.NoError(t, err)
+}
+
+func TestController_DisablePair(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	stateFile := filepath.Join(t.TempDir(), "pairs.json")
+	require.NoError(t, controller.SetPairsStateFile(stateFile))
+
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+	wallet.OnCandle(model.Candle{Pair: "ETHUSDT", Close: 100})
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+
+	require.NoError(t, controller.DisablePair("BTCUSDT"))
+	require.False(t, controller.PairEnabled("BTCUSDT"))
+	require.Equal(t, []string{"BTCUSDT"}, controller.DisabledPairs())
+
+	// entries are blocked
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrPairDisabled)
+	_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 100)
+	require.ErrorIs(t, err, ErrPairDisabled)
+	_, err = controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 3, 1100)
+	require.ErrorIs(t, err, ErrPairDisabled)
+
+	// other pairs are not affected
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 1)
+	require.NoError(t, err)
+
+	// exits are allowed
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// the state is restored after a restart
+	restarted := NewController(ctx, wallet, storage, NewOrderFeed())
+	require.NoError(t, restarted.SetPairsStateFile(stateFile))
+	require.Equal(t, []string{"BTCUSDT"}, restarted.DisabledPairs())
+
+	require.NoError(t, controller.EnablePair("BTCUSDT"))
+	require.True(t, controller.PairEnabled("BTCUSDT"))
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	restarted = NewController(ctx, wallet, storage, NewOrderFeed())
+	require.NoError(t, restarted.SetPairsStateFile(stateFile))
+	require.Empty(t, restarted.DisabledPairs())
+
+	t.Run("deny list", func(t *testing.T) {
+		denied := NewController(ctx, wallet, storage, NewOrderFeed())
+		denied.SetDenyPairs([]string{"BTCUSDT"})
+
+		_, err := denied.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, ErrPairDenied)
+		_, err = denied.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 100)
+		require.ErrorIs(t, err, ErrPairDenied)
+
+		// the deny list is not changed by EnablePair, exits are allowed
+		require.NoError(t, denied.EnablePair("BTCUSDT"))
+		_, err = denied.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, ErrPairDenied)
+		_, err = denied.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+	})
+}
+
+func TestController_SubAccount(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+	controller.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 1000})
+
+	first, err := controller.SubAccount("first", map[string]float64{"USDT": 3000})
+	require.NoError(t, err)
+	second, err := controller.SubAccount("second", map[string]float64{"USDT": 5000})
+	require.NoError(t, err)
+
+	// allocations can not exceed the account balance
+	_, err = controller.SubAccount("third", map[string]float64{"USDT": 3000})
+	require.ErrorIs(t, err, ErrInvalidAllocation)
+	_, err = controller.SubAccount("first", map[string]float64{"USDT": 100})
+	require.ErrorIs(t, err, ErrInvalidAllocation)
+
+	order, err := first.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+	require.Equal(t, "first", order.Strategy)
+
+	// the strategy can not exceed its allocation, even with funds in the account
+	_, err = first.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.ErrorIs(t, err, ErrInsufficientAllocation)
+	_, err = first.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 1500)
+	require.ErrorIs(t, err, ErrInsufficientAllocation)
+	_, err = first.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 3)
+	require.ErrorIs(t, err, ErrInsufficientAllocation)
+
+	// other sub-accounts have their own allocation
+	_, err = second.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 4)
+	require.NoError(t, err)
+
+	asset, quote, err := first.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 2.0, asset)
+	require.Equal(t, 1000.0, quote)
+
+	// open orders lock the allocation
+	limit, err := first.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 900)
+	require.NoError(t, err)
+	_, err = first.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 200)
+	require.ErrorIs(t, err, ErrInsufficientAllocation)
+
+	account, err := first.Account()
+	require.NoError(t, err)
+	require.Equal(t, []model.Balance{
+		{Asset: "BTC", Free: 2},
+		{Asset: "USDT", Free: 100, Lock: 900},
+	}, account.Balances)
+
+	// filled orders update the balances
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 900, Low: 900})
+	controller.updateOrders()
+	require.Equal(t, 3.0, first.Balance("BTC"))
+	require.Equal(t, 100.0, first.Balance("USDT"))
+
+	stored, err := controller.storage.Orders()
+	require.NoError(t, err)
+	for _, o := range stored {
+		if o.ExchangeID == limit.ExchangeID {
+			require.Equal(t, "first", o.Strategy)
+		}
+	}
+
+	// rebalancing is limited to the account balance, 2000 USDT not allocated
+	require.NoError(t, controller.Rebalance("first", map[string]float64{"USDT": 2100}))
+	require.Equal(t, 2100.0, first.Balance("USDT"))
+	require.ErrorIs(t, controller.Rebalance("first", map[string]float64{"USDT": 2200}), ErrInvalidAllocation)
+	require.ErrorIs(t, controller.Rebalance("unknown", map[string]float64{"USDT": 1}), ErrInvalidAllocation)
+
+	_, err = first.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+
+	// fees in other assets are charged in the asset, not in the quote
+	second.balances["BNB"] = 1
+	second.onOrder(model.Order{ExchangeID: 100, Pair: "BTCUSDT", Side: model.SideTypeSell,
+		Status: model.OrderStatusTypeFilled, Quantity: 1, Price: 1000, AssetFee: 0.01, FeeAsset: "BNB"})
+	require.Equal(t, 2000.0, second.Balance("USDT"))
+	require.Equal(t, 0.99, second.Balance("BNB"))
+}
+
+func TestController_SetAutoResize(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	controller.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+	// disabled by default
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10.5)
+	require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+
+	controller.SetAutoResize(2)
+	order, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10.5)
+	require.NoError(t, err)
+	require.InDelta(t, 10, order.Quantity, 1e-9)
+
+	// sell orders are limited by the asset balance
+	order, err = controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 10.0001, 120)
+	require.NoError(t, err)
+	require.InDelta(t, 10, order.Quantity, 1e-9)
+
+	// limit of resizes reached
+	require.NoError(t, controller.Cancel(order))
+	_, err = controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 10.0001, 120)
+	require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+}
+
+// bookExchange is a paper wallet with a synthetic order book
+type bookExchange struct {
+	*exchange.PaperWallet
+	book model.OrderBook
+}
+
+func (b *bookExchange) OrderBook(_ context.Context, _ string, _ int) (model.OrderBook, error) {
+	return b.book, nil
+}
+
+func TestController_SetMaxSpread(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	book := &bookExchange{PaperWallet: wallet, book: model.OrderBook{
+		Pair: "BTCUSDT",
+		Bids: []model.BookLevel{{Price: 95, Quantity: 1}},
+		Asks: []model.BookLevel{{Price: 105, Quantity: 1}},
+	}}
+	controller := NewController(ctx, book, storage, NewOrderFeed())
+	controller.SetMaxSpread("BTCUSDT", 0.01)
+
+	// 10% spread
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrWideSpread)
+	_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 100)
+	require.ErrorIs(t, err, ErrWideSpread)
+
+	// limit orders and other pairs are not checked
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+	require.NoError(t, err)
+	wallet.OnCandle(model.Candle{Pair: "ETHUSDT", Close: 10})
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 1)
+	require.NoError(t, err)
+
+	// 0.2% spread
+	book.book.Bids[0].Price, book.book.Asks[0].Price = 99.9, 100.1
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// exits of the position are not checked
+	book.book.Bids[0].Price, book.book.Asks[0].Price = 95, 105
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	t.Run("order book not supported", func(t *testing.T) {
+		controller := NewController(ctx, wallet, storage, NewOrderFeed())
+		controller.SetMaxSpread("BTCUSDT", 0.01)
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+	})
+}
+
+// stepExchange is a paper wallet with the step size of a real exchange
+type stepExchange struct {
+	*exchange.PaperWallet
+}
+
+func (s stepExchange) AssetsInfo(pair string) model.AssetInfo {
+	info := s.PaperWallet.AssetsInfo(pair)
+	info.StepSize = 0.001
+	info.BaseAssetPrecision = 3
+	return info
+}
+
+type capturingNotifier struct {
+	messages []string
+}
+
+func (n *capturingNotifier) Notify(message string) { n.messages = append(n.messages, message) }
+func (n *capturingNotifier) OnOrder(model.Order)   {}
+func (n *capturingNotifier) OnError(error)         {}
+
+func TestController_SetQuantizationTolerance(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	controller := NewController(ctx, stepExchange{wallet}, storage, NewOrderFeed())
+	logger := &capturingLogger{}
+	controller.SetLogger(logger)
+	notifier := &capturingNotifier{}
+	controller.SetNotifier(notifier)
+
+	warnings := func() []logEntry {
+		entries := make([]logEntry, 0)
+		for _, entry := range logger.entries {
+			if entry.msg == "[ORDER] Quantity reduced by rounding" {
+				entries = append(entries, entry)
+			}
+		}
+		logger.entries = nil
+		return entries
+	}
+
+	// rounded to zero
+	_, _ = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.0004)
+	entries := warnings()
+	require.Len(t, entries, 1)
+	require.Equal(t, []interface{}{"pair", "BTCUSDT", "side", model.SideTypeBuy, "requested", 0.0004,
+		"quantity", 0.0, "reduction", 1.0, "reason", "rounded to zero by the step size 0.001, precision 3"},
+		entries[0].fields)
+	require.Len(t, notifier.messages, 1)
+	require.Contains(t, notifier.messages[0], "Requested: 0.0004\nFinal: 0")
+
+	// 1.0015 to 1.001, within the default tolerance
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1.0015, 90)
+	require.NoError(t, err)
+	require.Empty(t, warnings())
+
+	// 0.0015 to 0.001, reduced by 33%
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 0.0015, 90)
+	require.NoError(t, err)
+	require.Len(t, warnings(), 1)
+
+	controller.SetQuantizationTolerance(0.5)
+	_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 0.0015, 90)
+	require.NoError(t, err)
+	require.Empty(t, warnings())
+
+	controller.SetQuantizationTolerance(-1)
+	_, _ = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 0.0004)
+	require.Empty(t, warnings())
+	require.Len(t, notifier.messages, 2)
+}
+
+func TestController_SetMaxOrderNotional(t *testing.T) {
+	ctx := context.Background()
+	newController := func(mode NotionalMode) (*Controller, *capturingNotifier) {
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+		candle := model.Candle{Pair: "BTCUSDT", Close: 100}
+		wallet.OnCandle(candle)
+		controller := NewController(ctx, stepExchange{wallet}, storage, NewOrderFeed())
+		controller.OnCandle(candle)
+		notifier := &capturingNotifier{}
+		controller.SetNotifier(notifier)
+		controller.SetMaxOrderNotional(500, mode)
+		return controller, notifier
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		controller, notifier := newController(NotionalReject)
+
+		// at the limit
+		order, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 5)
+		require.NoError(t, err)
+		require.Equal(t, 5.0, order.Quantity)
+		_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 500)
+		require.NoError(t, err)
+		require.Empty(t, notifier.messages)
+
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 5.001)
+		require.ErrorIs(t, err, ErrMaxOrderNotional)
+		_, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 6, 90)
+		require.ErrorIs(t, err, ErrMaxOrderNotional)
+		_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 500.01)
+		require.ErrorIs(t, err, ErrMaxOrderNotional)
+		// sells larger than the position of 10 BTC
+		_, err = controller.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 11, 110, 90, 89)
+		require.ErrorIs(t, err, ErrMaxOrderNotional)
+
+		require.Len(t, notifier.messages, 4)
+		require.Contains(t, notifier.messages[0], "Value: 500.10\nMaximum: 500.00\nAction: order rejected")
+
+		// amended orders are checked with the new quantity
+		order, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 5, 90)
+		require.NoError(t, err)
+		_, err = controller.AmendOrder(order, 0, 6)
+		require.ErrorIs(t, err, ErrMaxOrderNotional)
+
+		// exits of the position are not limited
+		_, err = controller.CreateOrderOCO(model.SideTypeSell, "BTCUSDT", 10, 110, 90, 89)
+		require.NoError(t, err)
+
+		// disabled
+		controller.SetMaxOrderNotional(0, NotionalReject)
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 6)
+		require.NoError(t, err)
+	})
+
+	t.Run("clamp", func(t *testing.T) {
+		controller, notifier := newController(NotionalClamp)
+
+		// at the limit
+		order, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 5, 100)
+		require.NoError(t, err)
+		require.Equal(t, 5.0, order.Quantity)
+		require.Empty(t, notifier.messages)
+
+		order, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 7)
+		require.NoError(t, err)
+		require.Equal(t, 5.0, order.Quantity)
+
+		// 500 / 90 rounded down to the step size
+		order, err = controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 10, 90)
+		require.NoError(t, err)
+		require.InDelta(t, 5.555, order.Quantity, 1e-9)
+
+		order, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 800)
+		require.NoError(t, err)
+		require.InDelta(t, 5.0, order.Quantity, 1e-9)
+
+		require.Len(t, notifier.messages, 3)
+		require.Contains(t, notifier.messages[0], "Action: quantity clamped from 7 to 5")
+
+		// the maximum value is below the step size
+		controller.SetMaxOrderNotional(0.01, NotionalClamp)
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.ErrorIs(t, err, ErrMaxOrderNotional)
+	})
+}
+
+func TestController_SimulateOrder(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 100),
+		exchange.WithPaperFee(0.001, 0.001))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 50})
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+
+	preview, err := controller.SimulateOrder("BTCUSDT", model.SideTypeBuy, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, 50.0, preview.Price)
+	require.InDelta(t, 0.05, preview.Fee, 1e-9)
+	require.Equal(t, 1.0, preview.AssetBalance)
+	require.InDelta(t, 49.95, preview.QuoteBalance, 1e-9)
+
+	// buy above the balance
+	_, err = controller.SimulateOrder("BTCUSDT", model.SideTypeBuy, 3, 0)
+	require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+
+	// no order submitted
+	orders, err := storage.Orders()
+	require.NoError(t, err)
+	require.Empty(t, orders)
+
+	t.Run("not supported", func(t *testing.T) {
+		controller := NewController(ctx, struct{ service.Exchange }{wallet}, storage, NewOrderFeed())
+		_, err := controller.SimulateOrder("BTCUSDT", model.SideTypeBuy, 1, 0)
+		require.ErrorIs(t, err, exchange.ErrSimulationNotSupported)
+	})
+}
+
+func TestController_AmendOrder(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("amend in place", func(t *testing.T) {
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		controller := NewController(ctx, stepExchange{wallet}, storage, NewOrderFeed())
+
+		order, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+		require.NoError(t, err)
+
+		// quantity rounded to the step size, zero price keeps the current price
+		amended, err := controller.AmendOrder(order, 0, 2.0015)
+		require.NoError(t, err)
+		require.Equal(t, order.ID, amended.ID)
+		require.Equal(t, order.ExchangeID, amended.ExchangeID)
+		require.Equal(t, 90.0, amended.Price)
+		require.Equal(t, 2.001, amended.Quantity)
+
+		amended, err = controller.AmendOrder(amended, 95, 0)
+		require.NoError(t, err)
+		require.Equal(t, 95.0, amended.Price)
+
+		orders, err := storage.Orders()
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, 95.0, orders[0].Price)
+		require.Equal(t, 2.001, orders[0].Quantity)
+
+		// filled at the new price
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 95})
+		controller.updateOrders()
+		orders, err = storage.Orders()
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeFilled, orders[0].Status)
+
+		_, err = controller.AmendOrder(*orders[0], 90, 1)
+		require.ErrorIs(t, err, exchange.ErrOrderNotAmendable)
+	})
+
+	t.Run("cancel and replace", func(t *testing.T) {
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		controller := NewController(ctx, struct{ service.Exchange }{wallet}, storage, NewOrderFeed())
+
+		order, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+		require.NoError(t, err)
+
+		replacement, err := controller.AmendOrder(order, 95, 2)
+		require.NoError(t, err)
+		require.NotEqual(t, order.ExchangeID, replacement.ExchangeID)
+		require.Equal(t, 95.0, replacement.Price)
+		require.Equal(t, 2.0, replacement.Quantity)
+
+		original, err := wallet.Order("BTCUSDT", order.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeCanceled, original.Status)
+
+		orders, err := storage.Orders()
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		require.Equal(t, model.OrderStatusTypePendingCancel, orders[0].Status)
+		require.Equal(t, model.OrderStatusTypeNew, orders[1].Status)
+	})
+
+	t.Run("replacement rejected", func(t *testing.T) {
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+		controller := NewController(ctx, rejectLimitExchange{Exchange: wallet, price: 95}, storage,
+			NewOrderFeed())
+
+		order, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+		require.NoError(t, err)
+
+		// the cancelled order is restored with the original price and quantity
+		restored, err := controller.AmendOrder(order, 95, 2)
+		require.ErrorIs(t, err, errLimitRejected)
+		require.NotEqual(t, order.ExchangeID, restored.ExchangeID)
+		require.Equal(t, 90.0, restored.Price)
+		require.Equal(t, 1.0, restored.Quantity)
+
+		open, err := wallet.OpenOrders("BTCUSDT")
+		require.NoError(t, err)
+		require.Len(t, open, 1)
+		require.Equal(t, restored.ExchangeID, open[0].ExchangeID)
+
+		orders, err := storage.Orders()
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		require.Equal(t, model.OrderStatusTypeNew, orders[1].Status)
+	})
+}
+
+var errLimitRejected = errors.New("limit order rejected")
+
+// rejectLimitExchange rejects the limit orders at the price, the orders are not amended in place
+type rejectLimitExchange struct {
+	service.Exchange
+	price float64
+}
+
+func (r rejectLimitExchange) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	if limit == r.price {
+		return model.Order{}, errLimitRejected
+	}
+	return r.Exchange.CreateOrderLimit(side, pair, size, limit)
+}
+
+func TestController_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100, High: 100})
+
+	// previous run: long position with a take profit and a limit entry
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	_, err = controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 120)
+	require.NoError(t, err)
+	entry, err := controller.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 80)
+	require.NoError(t, err)
+
+	// a restart without changes restores the position
+	controller = NewController(ctx, wallet, storage, NewOrderFeed())
+	notifier := &capturingNotifier{}
+	controller.SetNotifier(notifier)
+	report := controller.Reconcile("BTCUSDT")
+	require.False(t, report.Changed())
+	require.Len(t, report.Open, 2)
+	require.Equal(t, map[string]Position{"BTCUSDT": {Side: model.SideTypeBuy, AvgPrice: 100, Quantity: 1}},
+		report.Positions)
+	require.Empty(t, notifier.messages)
+
+	// while the bot is down, the take profit is filled, the entry is canceled and an order is created manually
+	require.NoError(t, wallet.Cancel(entry))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 125, High: 125})
+	manual, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 0.5, 90)
+	require.NoError(t, err)
+
+	controller = NewController(ctx, wallet, storage, NewOrderFeed())
+	controller.SetNotifier(notifier)
+	controller.SetOrderAdoption(true)
+	report = controller.Reconcile("BTCUSDT")
+	require.True(t, report.Changed())
+	require.Empty(t, report.Errors)
+	require.Len(t, report.Filled, 1)
+	require.Equal(t, model.SideTypeSell, report.Filled[0].Side)
+	require.Len(t, report.Canceled, 1)
+	require.Equal(t, entry.ExchangeID, report.Canceled[0].ExchangeID)
+	require.Empty(t, report.Open)
+	require.Len(t, report.Adopted, 1)
+	require.Equal(t, manual.ExchangeID, report.Adopted[0].ExchangeID)
+
+	// the position closed by the take profit
+	require.Empty(t, report.Positions)
+	require.Empty(t, controller.OpenPositions())
+	require.Equal(t, 20.0, controller.RealizedPnL())
+
+	// the adopted order is tracked
+	orders, err := storage.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 4)
+	require.Equal(t, model.OrderStatusTypeFilled, orders[1].Status)
+	require.Equal(t, model.OrderStatusTypeCanceled, orders[2].Status)
+	require.Equal(t, manual.ExchangeID, orders[3].ExchangeID)
+	require.Equal(t, model.OrderStatusTypeNew, orders[3].Status)
+
+	require.NotEmpty(t, notifier.messages)
+	require.True(t, strings.HasPrefix(notifier.messages[len(notifier.messages)-1], "⚠️ ORDERS RECONCILED"))
+	require.Contains(t, notifier.messages[len(notifier.messages)-1],
+		"1 orders filled, 1 canceled, 0 open, 1 adopted, 0 ignored")
+}
+
+func TestController_SetOrderAdoption(t *testing.T) {
+	ctx := context.Background()
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	wallet.OnCandle(model.Candle{Time: start, Pair: "BTCUSDT", Close: 100, High: 100})
+
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+
+	// while the bot is down, an order is filled and another is created in the exchange
+	wallet.OnCandle(model.Candle{Time: start.Add(time.Hour), Pair: "BTCUSDT", Close: 110, High: 110})
+	fill, err := wallet.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+	require.NoError(t, err)
+	open, err := wallet.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+	require.NoError(t, err)
+
+	// by default, the orders are only reported
+	controller = NewController(ctx, wallet, storage, NewOrderFeed())
+	report := controller.Reconcile("BTCUSDT")
+	require.True(t, report.Changed())
+	require.Empty(t, report.Adopted)
+	require.Len(t, report.Ignored, 2)
+	require.Equal(t, 1.0, report.Positions["BTCUSDT"].Quantity)
+
+	// with adoption, the fill updates the position and the open order is tracked
+	controller = NewController(ctx, wallet, storage, NewOrderFeed())
+	controller.SetOrderAdoption(true)
+	report = controller.Reconcile("BTCUSDT")
+	require.Empty(t, report.Errors)
+	require.Len(t, report.Adopted, 2)
+	require.Equal(t, fill.ExchangeID, report.Adopted[0].ExchangeID)
+	require.Equal(t, open.ExchangeID, report.Adopted[1].ExchangeID)
+	require.Equal(t, Position{Side: model.SideTypeBuy, AvgPrice: 105, Quantity: 2, CreatedAt: start},
+		report.Positions["BTCUSDT"])
+
+	// the adopted orders are known after a restart
+	controller = NewController(ctx, wallet, storage, NewOrderFeed())
+	report = controller.Reconcile("BTCUSDT")
+	require.False(t, report.Changed())
+	require.Equal(t, 2.0, report.Positions["BTCUSDT"].Quantity)
+}
+
+func TestController_PnL(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	onCandle := func(price float64) {
+		candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: price}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	onCandle(100)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+	require.Equal(t, 0.0, controller.RealizedPnL())
+	require.Equal(t, 0.0, controller.UnrealizedPnL(nil))
+
+	onCandle(110)
+	require.Equal(t, 20.0, controller.UnrealizedPnL(nil))
+
+	// partial close moves the profit of the closed quantity to realized
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Equal(t, 10.0, controller.RealizedPnL())
+	require.Equal(t, 10.0, controller.UnrealizedPnL(nil))
+	require.Equal(t, 20.0, controller.UnrealizedPnL(map[string]float64{"BTCUSDT": 120}))
+	position := controller.OpenPositions()["BTCUSDT"]
+	require.Equal(t, 1.0, position.Quantity)
+	require.Equal(t, 100.0, position.AvgPrice)
+
+	// full close
+	onCandle(90)
+	_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+	require.NoError(t, err)
+	require.Equal(t, 0.0, controller.RealizedPnL())
+	require.Equal(t, 0.0, controller.UnrealizedPnL(nil))
+	require.Empty(t, controller.OpenPositions())
+
+	t.Run("many small trades", func(t *testing.T) {
+		var result summary
+		var drift float64
+		for i := 0; i < 10000; i++ {
+			result.add(Result{Side: model.SideTypeBuy, ProfitValue: 0.1, ProfitPercent: 0.001})
+			drift += 0.1
+		}
+		require.NotEqual(t, 1000.0, drift)
+		require.Equal(t, 1000.0, result.Profit())
+	})
+
+	t.Run("short", func(t *testing.T) {
+		position := Position{Side: model.SideTypeSell, AvgPrice: 100, Quantity: 2}
+		require.Equal(t, 20.0, position.UnrealizedPnL(90))
+		require.Equal(t, -20.0, position.UnrealizedPnL(110))
+	})
+}
+
+func TestController_FaultExchange(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+	// all orders rejected by insufficient funds
+	fault := exchange.NewFaultExchange(wallet, 1, exchange.WithFaultRejectRate(1),
+		exchange.WithFaultErrors(&exchange.OrderError{Err: exchange.ErrInsufficientFunds, Pair: "BTCUSDT"}))
+	controller := NewController(ctx, fault, storage, NewOrderFeed())
+	controller.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	logger := &capturingLogger{}
+	controller.SetLogger(logger)
+	controller.SetAutoResize(2)
+
+	resizes := func() int {
+		var count int
+		for _, entry := range logger.entries {
+			if entry.msg == "[ORDER] Insufficient funds, resizing order" {
+				count++
+			}
+		}
+		return count
+	}
+
+	// each order is resized and retried once, until the limit of resizes
+	for _, expected := range []int{1, 2, 2} {
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 20)
+		require.ErrorIs(t, err, exchange.ErrInsufficientFunds)
+		require.Equal(t, expected, resizes())
+	}
+
+	orders, err := storage.Orders()
+	require.NoError(t, err)
+	require.Empty(t, orders)
+
+	asset, quote, err := controller.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, asset)
+	require.Equal(t, 1000.0, quote)
+}
+
+func TestController_EquityHistory(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	require.NoError(t, controller.SetEquityHistory(time.Hour, 3*time.Hour))
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	onCandle := func(minutes int, price float64) {
+		candle := model.Candle{Time: start.Add(time.Duration(minutes) * time.Minute), Pair: "BTCUSDT",
+			Close: price}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	onCandle(0, 100)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 5)
+	require.NoError(t, err)
+
+	// 15m candles, one snapshot per hour
+	for i := 1; i < 20; i++ {
+		onCandle(i*15, 100+float64(i))
+	}
+
+	snapshots, err := controller.EquityHistory(start, start.Add(24*time.Hour))
+	require.NoError(t, err)
+
+	// snapshots of the first hours are out of the retention
+	times := make([]time.Time, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		times = append(times, snapshot.Time)
+	}
+	require.Equal(t, []time.Time{start.Add(time.Hour), start.Add(2 * time.Hour), start.Add(3 * time.Hour),
+		start.Add(4 * time.Hour)}, times)
+
+	// 500 USDT and 5 BTC valued at the candle close
+	require.InDelta(t, 500+5*104.0, snapshots[0].Value, 1e-6)
+	require.InDelta(t, 500+5*116.0, snapshots[3].Value, 1e-6)
+
+	value, err := controller.NetLiquidationValue()
+	require.NoError(t, err)
+	require.InDelta(t, 500+5*119.0, value, 1e-6)
+
+	t.Run("storage not supported", func(t *testing.T) {
+		controller := NewController(ctx, wallet, nil, NewOrderFeed())
+		require.ErrorIs(t, controller.SetEquityHistory(time.Hour, 0), ErrEquityNotSupported)
+	})
+}
+
+// quoteExchange is a paper wallet with fixed last quotes
+type quoteExchange struct {
+	*exchange.PaperWallet
+	quotes map[string]float64
+}
+
+func (q quoteExchange) LastQuote(_ context.Context, pair string) (float64, error) {
+	price, ok := q.quotes[pair]
+	if !ok {
+		return 0, fmt.Errorf("no quote for %s", pair)
+	}
+	return price, nil
+}
+
+func TestController_SetBaseCurrency(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000),
+		exchange.WithPaperAsset("BTC", 0.1))
+	exch := quoteExchange{PaperWallet: wallet, quotes: map[string]float64{"BTCUSDT": 20000}}
+	controller := NewController(ctx, exch, storage, NewOrderFeed())
+
+	// USDT and BTC quoted pairs
+	for _, candle := range []model.Candle{
+		{Pair: "ETHUSDT", Close: 1000},
+		{Pair: "ETHBTC", Close: 0.05},
+	} {
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "ETHBTC", 1)
+	require.NoError(t, err)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "ETHUSDT", 0.5)
+	require.NoError(t, err)
+
+	// 500 USDT, 0.05 BTC and 1.5 ETH, without the conversion of BTC
+	controller.SetBaseCurrency("USDT")
+	_, err = controller.NetLiquidationValue()
+	require.ErrorIs(t, err, model.ErrMissingConversion)
+
+	controller.SetBaseCurrency("USDT", "BTCUSDT")
+	value, err := controller.NetLiquidationValue()
+	require.NoError(t, err)
+	require.InDelta(t, 500+0.05*20000+1.5*1000, value, 1e-6)
+
+	// ETH is converted with the ETHBTC close
+	controller.SetBaseCurrency("BTC", "BTCUSDT")
+	value, err = controller.NetLiquidationValue()
+	require.NoError(t, err)
+	require.InDelta(t, 500.0/20000+0.05+1.5*0.05, value, 1e-9)
+
+	t.Run("conversion pair error", func(t *testing.T) {
+		controller.SetBaseCurrency("USDT", "BTCBUSD")
+		_, err := controller.NetLiquidationValue()
+		require.Error(t, err)
+	})
+}
+
+func TestController_Shutdown(t *testing.T) {
+	setup := func(t *testing.T) (*Controller, *exchange.PaperWallet, model.Order) {
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+		controller := NewController(ctx, wallet, storage, NewOrderFeed())
+		candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+		require.NoError(t, err)
+		takeProfit, err := controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 1, 200)
+		require.NoError(t, err)
+		return controller, wallet, takeProfit
+	}
+
+	t.Run("hold", func(t *testing.T) {
+		controller, wallet, takeProfit := setup(t)
+		action := controller.Shutdown(context.Background(), "BTCUSDT", ShutdownHold)
+		require.Empty(t, action.Canceled)
+		require.Nil(t, action.Closed)
+		require.Empty(t, action.Errors)
+
+		order, err := wallet.Order("BTCUSDT", takeProfit.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeNew, order.Status)
+		asset, _, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 2.0, asset)
+	})
+
+	t.Run("cancel orders", func(t *testing.T) {
+		controller, wallet, takeProfit := setup(t)
+		action := controller.Shutdown(context.Background(), "BTCUSDT", ShutdownCancelOrders)
+		require.Len(t, action.Canceled, 1)
+		require.Equal(t, takeProfit.ExchangeID, action.Canceled[0].ExchangeID)
+		require.Nil(t, action.Closed)
+		require.Empty(t, action.Errors)
+
+		order, err := wallet.Order("BTCUSDT", takeProfit.ExchangeID)
+		require.NoError(t, err)
+		require.Equal(t, model.OrderStatusTypeCanceled, order.Status)
+		asset, _, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 2.0, asset)
+	})
+
+	t.Run("flatten", func(t *testing.T) {
+		controller, wallet, takeProfit := setup(t)
+		action := controller.Shutdown(context.Background(), "BTCUSDT", ShutdownFlatten)
+		require.Len(t, action.Canceled, 1)
+		require.Equal(t, takeProfit.ExchangeID, action.Canceled[0].ExchangeID)
+		require.NotNil(t, action.Closed)
+		require.Equal(t, model.SideTypeSell, action.Closed.Side)
+		require.Equal(t, 2.0, action.Closed.Quantity)
+		require.Empty(t, action.Errors)
+		require.Empty(t, controller.OpenPositions())
+
+		asset, quote, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 0.0, asset)
+		require.Equal(t, 1000.0, quote)
+	})
+
+	t.Run("flatten keeps the balances not traded by the bot", func(t *testing.T) {
+		storage, err := storage.FromMemory()
+		require.NoError(t, err)
+		ctx := context.Background()
+		wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000),
+			exchange.WithPaperAsset("BTC", 1.5))
+		controller := NewController(ctx, wallet, storage, NewOrderFeed())
+		candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+
+		// without position of the bot
+		action := controller.Shutdown(ctx, "BTCUSDT", ShutdownFlatten)
+		require.Empty(t, action.Errors)
+		require.Nil(t, action.Closed)
+
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+		require.NoError(t, err)
+		action = controller.Shutdown(ctx, "BTCUSDT", ShutdownFlatten)
+		require.Empty(t, action.Errors)
+		require.NotNil(t, action.Closed)
+		require.Equal(t, 2.0, action.Closed.Quantity)
+
+		asset, _, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 1.5, asset)
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		controller, wallet, _ := setup(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		action := controller.Shutdown(ctx, "BTCUSDT", ShutdownFlatten)
+		require.Len(t, action.Errors, 1)
+		require.ErrorIs(t, action.Errors[0], context.Canceled)
+		asset, _, err := wallet.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, 2.0, asset)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		controller, _, _ := setup(t)
+		action := controller.Shutdown(context.Background(), "BTCUSDT", "close-all")
+		require.Len(t, action.Errors, 1)
+		require.Empty(t, action.Canceled)
+	})
+}
+
+type dustExchange struct {
+	stepExchange
+}
+
+func (d dustExchange) AssetsInfo(pair string) model.AssetInfo {
+	info := d.stepExchange.AssetsInfo(pair)
+	info.MinNotional = 5
+	return info
+}
+
+func TestController_CleanDust(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT",
+		exchange.WithPaperAsset("USDT", 1000),
+		exchange.WithPaperAsset("BTC", 2),
+		exchange.WithPaperAsset("ETH", 0.0805),
+		exchange.WithPaperAsset("BNB", 0.03),
+	)
+	controller := NewController(ctx, dustExchange{stepExchange{wallet}}, storage, NewOrderFeed())
+	notifier := &capturingNotifier{}
+	controller.SetNotifier(notifier)
+	for _, pair := range []string{"BTCUSDT", "ETHUSDT", "BNBUSDT", "SOLUSDT"} {
+		candle := model.Candle{Time: time.Now(), Pair: pair, Close: 100}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	// SOL is worth 6, but it is an open position of the bot
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "SOLUSDT", 0.06)
+	require.NoError(t, err)
+
+	// the sales are not limited by the guards of the orders
+	controller.SetMaxOrderNotional(1, NotionalReject)
+	controller.SetMaxSpread("ETHUSDT", 0.01)
+
+	report, err := controller.CleanDust(10, "BTCUSDT", "ETHUSDT", "BNBUSDT", "SOLUSDT")
+	require.NoError(t, err)
+	require.Empty(t, report.Errors)
+
+	// ETH is worth 8.05, sold with the quantity rounded to the step size
+	require.Len(t, report.Sold, 1)
+	require.Equal(t, "ETHUSDT", report.Sold[0].Pair)
+	require.Equal(t, model.SideTypeSell, report.Sold[0].Side)
+	require.InDelta(t, 0.08, report.Sold[0].Quantity, 1e-9)
+
+	// the sales are not trades of the bot
+	require.Len(t, controller.OpenPositions(), 1)
+	require.NotContains(t, controller.Results, "ETHUSDT")
+
+	// BNB is worth 3, below the minimum notional
+	require.Len(t, report.Uncleanable, 1)
+	require.Equal(t, "BNB", report.Uncleanable[0].Asset)
+	require.InDelta(t, 3.0, report.Uncleanable[0].Value, 1e-9)
+	require.Contains(t, report.Uncleanable[0].Reason, "minimum notional")
+	require.Len(t, notifier.messages, 1)
+	require.Contains(t, notifier.messages[0], "BNB")
+
+	asset, quote, err := wallet.Position("ETHUSDT")
+	require.NoError(t, err)
+	require.InDelta(t, 0.0005, asset, 1e-9)
+	require.InDelta(t, 1002.0, quote, 1e-9)
+
+	// BTC is above the threshold and BNB is kept
+	asset, _, err = wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 2.0, asset)
+	asset, _, err = wallet.Position("BNBUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.03, asset)
+	asset, _, err = wallet.Position("SOLUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.06, asset)
+}
+
+func TestController_SetConfirmOrder(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	candle := model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 100}
+	wallet.OnCandle(candle)
+	controller.OnCandle(candle)
+
+	// orders above 500 USDT are vetoed, the callback can read the controller
+	var confirmed []model.Order
+	controller.SetConfirmOrder(func(order model.Order) bool {
+		confirmed = append(confirmed, order)
+		controller.OpenPositions()
+		return order.Quantity*order.Price <= 500
+	})
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10)
+	require.ErrorIs(t, err, ErrOrderNotConfirmed)
+	require.Len(t, confirmed, 1)
+	require.Equal(t, model.Order{Pair: "BTCUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeMarket,
+		Quantity: 10, Price: 100}, confirmed[0])
+
+	asset, _, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Zero(t, asset)
+	orders, err := storage.Orders()
+	require.NoError(t, err)
+	require.Empty(t, orders)
+
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 2)
+	require.NoError(t, err)
+
+	limit, err := controller.CreateOrderLimit(model.SideTypeSell, "BTCUSDT", 2, 200)
+	require.NoError(t, err)
+
+	// amendments are confirmed with the new values
+	_, err = controller.AmendOrder(limit, 300, 0)
+	require.ErrorIs(t, err, ErrOrderNotConfirmed)
+	require.Equal(t, 300.0, confirmed[len(confirmed)-1].Price)
+
+	_, err = controller.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", 1000)
+	require.ErrorIs(t, err, ErrOrderNotConfirmed)
+	require.Equal(t, 10.0, confirmed[len(confirmed)-1].Quantity)
+
+	controller.SetConfirmOrder(nil)
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 10)
+	require.NoError(t, err)
+
+	// resized orders are confirmed again
+	controller.SetAutoResize(1)
+	confirmed = nil
+	controller.SetConfirmOrder(func(order model.Order) bool {
+		confirmed = append(confirmed, order)
+		return true
+	})
+	_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 200)
+	require.NoError(t, err)
+	require.Len(t, confirmed, 2)
+	require.Equal(t, 200.0, confirmed[0].Quantity)
+	require.Less(t, confirmed[1].Quantity, 200.0)
+}
+
+func TestController_RollingStats(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+	controller.SetRollingWindow(3)
+
+	require.Equal(t, RollingStats{}, controller.RollingStats())
+
+	trade := func(exit float64) RollingStats {
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: 1000})
+		_, err := controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		wallet.OnCandle(model.Candle{Time: time.Now(), Pair: "BTCUSDT", Close: exit})
+		_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 1)
+		require.NoError(t, err)
+		return controller.RollingStats()
+	}
+
+	// win of 100
+	stats := trade(1100)
+	require.Equal(t, 1, stats.Trades)
+	require.Equal(t, 1.0, stats.WinRate)
+	require.Equal(t, 100.0, stats.AvgWin)
+	require.Equal(t, 0.0, stats.ProfitFactor)
+	require.InDelta(t, 100, stats.Expectancy, 1e-6)
+
+	// loss of 50
+	stats = trade(950)
+	require.Equal(t, 2, stats.Trades)
+	require.Equal(t, 0.5, stats.WinRate)
+	require.Equal(t, 50.0, stats.AvgLoss)
+	require.InDelta(t, 2, stats.ProfitFactor, 1e-6)
+	require.InDelta(t, 25, stats.Expectancy, 1e-6)
+
+	// win of 200
+	stats = trade(1200)
+	require.Equal(t, 3, stats.Trades)
+	require.InDelta(t, 2.0/3, stats.WinRate, 1e-6)
+	require.InDelta(t, 150, stats.AvgWin, 1e-6)
+	require.InDelta(t, 6, stats.ProfitFactor, 1e-6)
+	require.InDelta(t, 150*2.0/3-50.0/3, stats.Expectancy, 1e-6)
+
+	// loss of 100, the first win is out of the window
+	stats = trade(900)
+	require.Equal(t, 3, stats.Trades)
+	require.InDelta(t, 1.0/3, stats.WinRate, 1e-6)
+	require.InDelta(t, 200, stats.AvgWin, 1e-6)
+	require.InDelta(t, 75, stats.AvgLoss, 1e-6)
+	require.InDelta(t, 200.0/150, stats.ProfitFactor, 1e-6)
+	require.InDelta(t, 200.0/3-75*2.0/3, stats.Expectancy, 1e-6)
+	require.Len(t, controller.Results["BTCUSDT"].Trades, 4)
+
+	// all the trades without window
+	controller.SetRollingWindow(0)
+	stats = controller.RollingStats()
+	require.Equal(t, 4, stats.Trades)
+	require.Equal(t, 0.5, stats.WinRate)
+	require.InDelta(t, 150*0.5-75*0.5, stats.Expectancy, 1e-6)
+}
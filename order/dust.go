@@ -0,0 +1,150 @@
+package order
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// Dust is a small balance of an asset, worth less than the threshold of CleanDust
+type Dust struct {
+	Asset    string
+	Pair     string
+	Quantity float64
+	// Value is the value of the balance in the quote asset of the pair
+	Value float64
+	// Reason explains why the balance can not be sold, empty when it is sold
+	Reason string
+}
+
+// DustReport is the summary of a dust cleanup
+type DustReport struct {
+	// Sold are the market orders that sold the dust to the quote asset
+	Sold []model.Order
+	// Uncleanable are the balances below the limits of the exchange, e.g. the minimum notional
+	Uncleanable []Dust
+	Errors      []error
+}
+
+func (r DustReport) String() string {
+	parts := []string{fmt.Sprintf("%d balances sold, %d uncleanable", len(r.Sold), len(r.Uncleanable))}
+	for _, dust := range r.Uncleanable {
+		parts = append(parts, fmt.Sprintf("%s: %f (%f) %s", dust.Asset, dust.Quantity, dust.Value, dust.Reason))
+	}
+	if len(r.Errors) > 0 {
+		parts = append(parts, fmt.Sprintf("%d errors", len(r.Errors)))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// CleanDust sells the small leftover balances of the base assets of the given pairs, worth less than the
+// threshold in the quote asset, e.g. the remainder of a position after the rounding of the quantities. The
+// free balance of each asset is aggregated and sold to the quote asset of the first pair with the asset, when
+// it is within the minimum quantity and notional of the pair after the rounding to the step size. Otherwise
+// the balance is reported as uncleanable, logged and notified. The quote assets of the pairs and the assets
+// with an open position of the bot are not sold, the positions are closed by the strategy.
+// Failed orders do not stop the cleanup, they are reported in the summary.
+//
+// The sales are sent straight to the exchange: they are not trades of the bot, so they do not change the
+// positions and results, and the guards of the orders, e.g. SetMaxOrderNotional, are not applied.
+func (c *Controller) CleanDust(threshold float64, pairs ...string) (DustReport, error) {
+	var report DustReport
+	account, err := c.exchange.Account()
+	if err != nil {
+		return report, err
+	}
+
+	free := make(map[string]float64)
+	for _, balance := range account.Balances {
+		free[balance.Asset] += balance.Free
+	}
+
+	quotes := make(map[string]bool)
+	infos := make(map[string]model.AssetInfo, len(pairs))
+	for _, pair := range pairs {
+		info := c.exchange.AssetsInfo(pair)
+		infos[pair] = info
+		quotes[info.QuoteAsset] = true
+	}
+
+	// the assets of the open positions are not dust
+	seen := make(map[string]bool)
+	c.mtx.Lock()
+	for pair, position := range c.position {
+		if position.Quantity > 0 {
+			seen[c.exchange.AssetsInfo(pair).BaseAsset] = true
+		}
+	}
+	c.mtx.Unlock()
+
+	for _, pair := range pairs {
+		info := infos[pair]
+		if seen[info.BaseAsset] || quotes[info.BaseAsset] || free[info.BaseAsset] <= 0 {
+			continue
+		}
+		seen[info.BaseAsset] = true
+
+		c.mtx.Lock()
+		price, err := c.lastQuote(pair)
+		c.mtx.Unlock()
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("last quote of %s: %w", pair, err))
+			continue
+		}
+
+		dust := Dust{Asset: info.BaseAsset, Pair: pair, Quantity: free[info.BaseAsset]}
+		dust.Value = dust.Quantity * price
+		if dust.Value >= threshold {
+			continue
+		}
+
+		quantity := info.RoundQuantity(dust.Quantity)
+		switch {
+		case quantity <= 0 || quantity < info.MinQuantity:
+			dust.Reason = fmt.Sprintf("quantity below the minimum %g", info.MinQuantity)
+		case quantity*price < info.MinNotional:
+			dust.Reason = fmt.Sprintf("value below the minimum notional %g", info.MinNotional)
+		}
+
+		if dust.Reason != "" {
+			c.logger.Warn("[DUST] Balance can not be sold", "asset", dust.Asset, "pair", pair,
+				"quantity", dust.Quantity, "value", dust.Value, "reason", dust.Reason)
+			report.Uncleanable = append(report.Uncleanable, dust)
+			continue
+		}
+
+		order, err := c.sellDust(pair, quantity)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("sell dust of %s: %w", dust.Asset, err))
+			continue
+		}
+		report.Sold = append(report.Sold, order)
+	}
+
+	c.logger.Info("[DUST] "+strings.ReplaceAll(report.String(), "\n", ", "), "threshold", threshold)
+	if len(report.Uncleanable) > 0 || len(report.Errors) > 0 {
+		c.notify(fmt.Sprintf("⚠️ DUST CLEANUP\n-----\n%s", report))
+	}
+	return report, nil
+}
+
+// sellDust sells the quantity with a market order in the exchange, without registering it as a trade
+func (c *Controller) sellDust(pair string, quantity float64) (model.Order, error) {
+	c.mtx.Lock()
+	err := c.confirm(model.Order{Pair: pair, Side: model.SideTypeSell, Type: model.OrderTypeMarket,
+		Quantity: quantity})
+	c.mtx.Unlock()
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	c.logger.Info("[DUST] Selling balance", "pair", pair, "quantity", quantity)
+	order, err := c.exchange.CreateOrderMarket(model.SideTypeSell, pair, quantity)
+	if err != nil {
+		c.notifyError(err)
+		return model.Order{}, err
+	}
+	c.logger.Info("[ORDER CREATED]", orderFields(order)...)
+	return order, nil
+}
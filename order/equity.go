@@ -0,0 +1,147 @@
+package order
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+// SetEquityHistory records the net liquidation value in the storage on the candles, at most once per interval
+// of candle time, e.g. time.Hour. A zero interval records on every new candle time. Snapshots older than the
+// retention are deleted, zero keeps all of them. It requires a storage that implements
+// storage.EquityStorage, otherwise ErrEquityNotSupported is returned.
+func (c *Controller) SetEquityHistory(interval, retention time.Duration) error {
+	equityStorage, ok := c.storage.(storage.EquityStorage)
+	if !ok {
+		return ErrEquityNotSupported
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.equityStorage = equityStorage
+	c.equityInterval = interval
+	c.equityRetention = retention
+	return nil
+}
+
+// EquityHistory returns the snapshots of the net liquidation value between start and end, sorted by time
+func (c *Controller) EquityHistory(start, end time.Time) ([]model.EquitySnapshot, error) {
+	equityStorage, ok := c.storage.(storage.EquityStorage)
+	if !ok {
+		return nil, ErrEquityNotSupported
+	}
+	return equityStorage.EquitySnapshots(start, end)
+}
+
+// SetBaseCurrency reports the net liquidation value, and the equity history, in the base currency, e.g. USDT
+// when trading USDT and BTC quoted pairs. The balances are converted with the last close of the traded pairs
+// and the last quote of the conversion pairs, e.g. BTCUSDT, see model.ConversionRate. An empty base currency
+// sums the quote balances without conversion.
+func (c *Controller) SetBaseCurrency(base string, conversionPairs ...string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.baseCurrency = base
+	c.conversionPairs = conversionPairs
+}
+
+// NetLiquidationValue returns the value of the account in the quote asset, with the balances of the traded
+// assets valued at the close of the last candle. The quote balance of each quote asset is counted once.
+// With a base currency, the value is converted to it and missing conversion rates return
+// model.ErrMissingConversion, see SetBaseCurrency.
+func (c *Controller) NetLiquidationValue() (float64, error) {
+	account, err := c.exchange.Account()
+	if err != nil {
+		return 0, err
+	}
+
+	c.mtx.Lock()
+	prices := make(map[string]float64, len(c.lastPrice))
+	for pair, price := range c.lastPrice {
+		prices[pair] = price
+	}
+	base, conversionPairs := c.baseCurrency, c.conversionPairs
+	c.mtx.Unlock()
+
+	if base != "" {
+		return c.convertedValue(account, prices, base, conversionPairs)
+	}
+
+	var value float64
+	quotes := make(map[string]bool)
+	for pair, price := range prices {
+		info := c.exchange.AssetsInfo(pair)
+		asset, quote := account.Balance(info.BaseAsset, info.QuoteAsset)
+		value += (asset.Free + asset.Lock) * price
+
+		if !quotes[info.QuoteAsset] {
+			quotes[info.QuoteAsset] = true
+			value += quote.Free + quote.Lock
+		}
+	}
+	return value, nil
+}
+
+// convertedValue returns the value of the balances of the traded assets in the base currency
+func (c *Controller) convertedValue(account model.Account, prices map[string]float64, base string,
+	conversionPairs []string) (float64, error) {
+
+	traded := model.Account{}
+	assets := make(map[string]bool)
+	for pair := range prices {
+		info := c.exchange.AssetsInfo(pair)
+		for _, asset := range []string{info.BaseAsset, info.QuoteAsset} {
+			if !assets[asset] {
+				assets[asset] = true
+				balance, _ := account.Balance(asset, "")
+				traded.Balances = append(traded.Balances, balance)
+			}
+		}
+	}
+
+	for _, pair := range conversionPairs {
+		if _, ok := prices[pair]; ok {
+			continue
+		}
+
+		price, err := c.exchange.LastQuote(c.ctx, pair)
+		if err != nil {
+			return 0, fmt.Errorf("conversion pair %s: %w", pair, err)
+		}
+		prices[pair] = price
+	}
+
+	return traded.EquityIn(base, prices)
+}
+
+// recordEquity stores a snapshot of the net liquidation value when the interval since the last snapshot
+// is elapsed, and deletes the snapshots out of the retention
+func (c *Controller) recordEquity(now time.Time) {
+	c.mtx.Lock()
+	if c.equityStorage == nil || !now.After(c.lastEquity) || now.Sub(c.lastEquity) < c.equityInterval {
+		c.mtx.Unlock()
+		return
+	}
+	c.lastEquity = now
+	c.mtx.Unlock()
+
+	value, err := c.NetLiquidationValue()
+	if err != nil {
+		c.notifyError(fmt.Errorf("equity snapshot: %w", err))
+		return
+	}
+
+	if err := c.equityStorage.CreateEquitySnapshot(&model.EquitySnapshot{Time: now, Value: value}); err != nil {
+		c.notifyError(fmt.Errorf("equity snapshot: %w", err))
+		return
+	}
+
+	if c.equityRetention > 0 {
+		if err := c.equityStorage.DeleteEquitySnapshots(now.Add(-c.equityRetention)); err != nil {
+			c.notifyError(fmt.Errorf("equity retention: %w", err))
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package order
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// RollingStats are the metrics of the last closed trades of all pairs, see Controller.SetRollingWindow
+type RollingStats struct {
+	Trades int
+	// WinRate is the fraction of winning trades, e.g. 0.6 for 60%
+	WinRate float64
+	// AvgWin and AvgLoss are the average profit of the winning trades and the average loss of the losing
+	// trades, both positive and in the quote asset
+	AvgWin  float64
+	AvgLoss float64
+	// ProfitFactor is the profit percent of the winning trades relative to the loss percent of the losing
+	// trades, zero without losing trades, as in the summary of the pairs
+	ProfitFactor float64
+	// Expectancy is the expected profit of a trade, AvgWin * WinRate - AvgLoss * (1 - WinRate)
+	Expectancy float64
+}
+
+func (s RollingStats) String() string {
+	return fmt.Sprintf("Trades: %d | Win rate: %.1f%% | Avg. win: %.4f | Avg. loss: %.4f | "+
+		"Profit factor: %.3f | Expectancy: %.4f", s.Trades, s.WinRate*100, s.AvgWin, s.AvgLoss,
+		s.ProfitFactor, s.Expectancy)
+}
+
+// newRollingStats calculates the metrics of the given trades. As in the summary, trades without loss are wins.
+func newRollingStats(trades []Result) RollingStats {
+	stats := RollingStats{Trades: len(trades)}
+	if len(trades) == 0 {
+		return stats
+	}
+
+	var wins, profit, loss float64
+	period := &summary{}
+	for _, trade := range trades {
+		period.add(trade)
+		if trade.ProfitPercent >= 0 {
+			wins++
+			profit += trade.ProfitValue
+		} else {
+			loss += math.Abs(trade.ProfitValue)
+		}
+	}
+
+	losses := float64(len(trades)) - wins
+	stats.WinRate = wins / float64(len(trades))
+	if wins > 0 {
+		stats.AvgWin = profit / wins
+	}
+	if losses > 0 {
+		stats.AvgLoss = loss / losses
+	}
+	stats.ProfitFactor = period.ProfitFactor()
+	stats.Expectancy = stats.AvgWin*stats.WinRate - stats.AvgLoss*(1-stats.WinRate)
+	return stats
+}
+
+// SetRollingWindow sets the number of the last closed trades of RollingStats. Zero or a negative value, the
+// default, uses all the trades.
+func (c *Controller) SetRollingWindow(trades int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.rollingWindow = trades
+}
+
+// RollingStats returns the win rate, average win and loss, profit factor and expectancy of the last closed
+// trades of all pairs, in the order they were closed. In backtests the result is deterministic by the candles.
+func (c *Controller) RollingStats() RollingStats {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	pairs := make([]string, 0, len(c.Results))
+	for pair := range c.Results {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	trades := make([]Result, 0)
+	for _, pair := range pairs {
+		trades = append(trades, c.Results[pair].Trades...)
+	}
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].CreatedAt.Before(trades[j].CreatedAt)
+	})
+
+	if c.rollingWindow > 0 && len(trades) > c.rollingWindow {
+		trades = trades[len(trades)-c.rollingWindow:]
+	}
+	return newRollingStats(trades)
+}
@@ -0,0 +1,195 @@
+package order
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// LotMatching defines which open lot is closed first by a fill in the opposite side
+type LotMatching string
+
+const (
+	// LotMatchingFIFO closes the oldest open lot first
+	LotMatchingFIFO LotMatching = "fifo"
+	// LotMatchingLIFO closes the most recent open lot first
+	LotMatchingLIFO LotMatching = "lifo"
+)
+
+// lotDust is the quantity below which a lot is considered fully matched, to absorb float rounding
+const lotDust = 1e-9
+
+// Lot is the part of a fill matched in a trade
+type Lot struct {
+	OrderID  int64
+	Time     time.Time
+	Side     model.SideType
+	Price    float64
+	Quantity float64
+	// Fee is the share of the fee of the fill proportional to the quantity of the lot, in the quote asset
+	Fee float64
+	// RawFee is the share of the fee in the asset charged by the exchange, FeeAsset, e.g. BNB, see
+	// model.Order.AssetFee. It is equal to Fee when the fee was charged in the quote asset, with an empty FeeAsset.
+	RawFee   float64
+	FeeAsset string
+}
+
+// Trade is a quantity opened by a fill and closed by a fill in the opposite side, Open and Close have the
+// same quantity. Short trades are opened by a sell.
+type Trade struct {
+	Pair  string
+	Open  Lot
+	Close Lot
+	// PnL is the profit of the trade in the quote asset, net of the fees of both lots
+	PnL float64
+}
+
+// Quantity returns the quantity of the trade
+func (t Trade) Quantity() float64 {
+	return t.Open.Quantity
+}
+
+// MatchOption configures MatchTrades
+type MatchOption func(*matchOptions)
+
+type matchOptions struct {
+	prices map[string]float64
+}
+
+// WithFeePrices converts the fees charged in other assets than the quote, e.g. BNB, with the given prices
+// by pair, e.g. BNBUSDT, see model.ConversionRate
+func WithFeePrices(prices map[string]float64) MatchOption {
+	return func(options *matchOptions) {
+		options.prices = prices
+	}
+}
+
+// MatchTrades matches the filled orders in discrete trades, per pair, with the given lot matching method. A fill
+// closes the open lots in the opposite side, partially when needed, and the remaining quantity opens a lot in
+// its side, so partial and interleaved entries and exits are supported. Orders are processed in the order of
+// the fill time (UpdatedAt), orders not filled are ignored. Lots not closed yet are not part of the result.
+// Fees charged in other assets than the quote are converted with the price of the fill when charged in the
+// base asset, otherwise with the prices of WithFeePrices, and return model.ErrMissingConversion without them.
+func MatchTrades(orders []model.Order, method LotMatching, options ...MatchOption) ([]Trade, error) {
+	if method != LotMatchingFIFO && method != LotMatchingLIFO {
+		return nil, fmt.Errorf("invalid lot matching: %s", method)
+	}
+
+	var config matchOptions
+	for _, option := range options {
+		option(&config)
+	}
+
+	fills := make([]model.Order, 0, len(orders))
+	for _, order := range orders {
+		if order.Status == model.OrderStatusTypeFilled && order.Quantity > 0 {
+			fills = append(fills, order)
+		}
+	}
+	sort.SliceStable(fills, func(i, j int) bool {
+		return fills[i].UpdatedAt.Before(fills[j].UpdatedAt)
+	})
+
+	trades := make([]Trade, 0)
+	openLots := make(map[string][]Lot)
+	for _, fill := range fills {
+		fee, err := quoteFee(fill, config.prices)
+		if err != nil {
+			return nil, err
+		}
+
+		lots := openLots[fill.Pair]
+		remaining := Lot{
+			OrderID:  fill.ExchangeID,
+			Time:     fill.UpdatedAt,
+			Side:     fill.Side,
+			Price:    fill.Price,
+			Quantity: fill.Quantity,
+			Fee:      fee,
+			RawFee:   rawFee(fill),
+			FeeAsset: fill.FeeAsset,
+		}
+
+		for remaining.Quantity > lotDust && len(lots) > 0 && lots[0].Side != fill.Side {
+			index := 0
+			if method == LotMatchingLIFO {
+				index = len(lots) - 1
+			}
+
+			open := lots[index]
+			quantity := math.Min(open.Quantity, remaining.Quantity)
+
+			var opened, closed Lot
+			open, opened = splitLot(open, quantity)
+			remaining, closed = splitLot(remaining, quantity)
+			trades = append(trades, newTrade(fill.Pair, opened, closed))
+
+			if open.Quantity > lotDust {
+				lots[index] = open
+			} else {
+				lots = append(lots[:index], lots[index+1:]...)
+			}
+		}
+
+		if remaining.Quantity > lotDust {
+			lots = append(lots, remaining)
+		}
+		openLots[fill.Pair] = lots
+	}
+
+	return trades, nil
+}
+
+// quoteFee returns the fee of the fill in the quote asset of the pair, including the fee in other assets
+func quoteFee(fill model.Order, prices map[string]float64) (float64, error) {
+	asset, quote := exchange.SplitAssetQuote(fill.Pair)
+	switch fill.FeeAsset {
+	case "":
+		return fill.Fee, nil
+	case quote:
+		return fill.Fee + fill.AssetFee, nil
+	case asset:
+		return fill.Fee + fill.AssetFee*fill.Price, nil
+	}
+
+	rate, err := model.ConversionRate(fill.FeeAsset, quote, prices)
+	if err != nil {
+		return 0, fmt.Errorf("fee of order %d: %w", fill.ExchangeID, err)
+	}
+	return fill.Fee + fill.AssetFee*rate, nil
+}
+
+// rawFee returns the fee of the fill in the asset charged by the exchange
+func rawFee(fill model.Order) float64 {
+	if fill.FeeAsset == "" {
+		return fill.Fee
+	}
+	return fill.AssetFee
+}
+
+// splitLot removes the quantity from the lot, it returns the rest of the lot and the removed part with the
+// proportional fee
+func splitLot(lot Lot, quantity float64) (rest, part Lot) {
+	part = lot
+	part.Quantity = quantity
+	part.Fee = lot.Fee * quantity / lot.Quantity
+	part.RawFee = lot.RawFee * quantity / lot.Quantity
+
+	rest = lot
+	rest.Quantity -= quantity
+	rest.Fee -= part.Fee
+	rest.RawFee -= part.RawFee
+	return rest, part
+}
+
+func newTrade(pair string, open, close Lot) Trade {
+	pnl := (close.Price - open.Price) * open.Quantity
+	if open.Side == model.SideTypeSell {
+		pnl = -pnl
+	}
+	return Trade{Pair: pair, Open: open, Close: close, PnL: pnl - open.Fee - close.Fee}
+}
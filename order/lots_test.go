@@ -0,0 +1,129 @@
+package order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestMatchTrades(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fill := func(id int64, side model.SideType, quantity, price, fee float64) model.Order {
+		return model.Order{ExchangeID: id, Pair: "BTCUSDT", Side: side, Status: model.OrderStatusTypeFilled,
+			Quantity: quantity, Price: price, Fee: fee, UpdatedAt: start.Add(time.Duration(id) * time.Hour)}
+	}
+
+	// partial entries and exits, interleaved
+	orders := []model.Order{
+		fill(1, model.SideTypeBuy, 2, 100, 0.4),
+		fill(2, model.SideTypeBuy, 1, 110, 0),
+		fill(3, model.SideTypeSell, 1.5, 120, 0),
+		fill(4, model.SideTypeBuy, 1, 90, 0),
+		fill(5, model.SideTypeSell, 2.5, 130, 0),
+		// not filled
+		{ExchangeID: 6, Pair: "BTCUSDT", Side: model.SideTypeSell, Status: model.OrderStatusTypeCanceled,
+			Quantity: 1, Price: 200, UpdatedAt: start.Add(6 * time.Hour)},
+	}
+
+	type match struct {
+		open, close   int64
+		quantity, pnl float64
+	}
+	matches := func(trades []Trade) []match {
+		result := make([]match, 0, len(trades))
+		for _, trade := range trades {
+			require.Equal(t, trade.Open.Quantity, trade.Close.Quantity)
+			result = append(result, match{trade.Open.OrderID, trade.Close.OrderID, trade.Quantity(), trade.PnL})
+		}
+		return result
+	}
+
+	t.Run("fifo", func(t *testing.T) {
+		trades, err := MatchTrades(orders, LotMatchingFIFO)
+		require.NoError(t, err)
+
+		// the fee of the first fill is split by quantity, 0.3 and 0.1
+		expected := []match{
+			{open: 1, close: 3, quantity: 1.5, pnl: 1.5*20 - 0.3},
+			{open: 1, close: 5, quantity: 0.5, pnl: 0.5*30 - 0.1},
+			{open: 2, close: 5, quantity: 1, pnl: 20},
+			{open: 4, close: 5, quantity: 1, pnl: 40},
+		}
+		actual := matches(trades)
+		require.Len(t, actual, len(expected))
+		for i := range expected {
+			require.Equal(t, expected[i].open, actual[i].open)
+			require.Equal(t, expected[i].close, actual[i].close)
+			require.InDelta(t, expected[i].quantity, actual[i].quantity, 1e-9)
+			require.InDelta(t, expected[i].pnl, actual[i].pnl, 1e-9)
+		}
+	})
+
+	t.Run("lifo", func(t *testing.T) {
+		trades, err := MatchTrades(orders, LotMatchingLIFO)
+		require.NoError(t, err)
+
+		expected := []match{
+			{open: 2, close: 3, quantity: 1, pnl: 10},
+			{open: 1, close: 3, quantity: 0.5, pnl: 0.5*20 - 0.1},
+			{open: 4, close: 5, quantity: 1, pnl: 40},
+			{open: 1, close: 5, quantity: 1.5, pnl: 1.5*30 - 0.3},
+		}
+		actual := matches(trades)
+		require.Len(t, actual, len(expected))
+		for i := range expected {
+			require.Equal(t, expected[i].open, actual[i].open)
+			require.Equal(t, expected[i].close, actual[i].close)
+			require.InDelta(t, expected[i].quantity, actual[i].quantity, 1e-9)
+			require.InDelta(t, expected[i].pnl, actual[i].pnl, 1e-9)
+		}
+	})
+
+	t.Run("flip to short", func(t *testing.T) {
+		trades, err := MatchTrades([]model.Order{
+			fill(1, model.SideTypeBuy, 1, 100, 0),
+			fill(2, model.SideTypeSell, 3, 110, 0),
+			fill(3, model.SideTypeBuy, 1, 90, 0),
+		}, LotMatchingFIFO)
+		require.NoError(t, err)
+		require.Len(t, trades, 2)
+		require.Equal(t, 10.0, trades[0].PnL)
+
+		// the rest of the sell opened a short lot of 2, partially closed
+		require.Equal(t, model.SideTypeSell, trades[1].Open.Side)
+		require.Equal(t, 1.0, trades[1].Quantity())
+		require.Equal(t, 20.0, trades[1].PnL)
+	})
+
+	t.Run("fee in other asset", func(t *testing.T) {
+		buy := fill(1, model.SideTypeBuy, 2, 100, 0)
+		buy.AssetFee, buy.FeeAsset = 0.01, "BNB"
+		// fee in the base asset, converted with the price of the fill
+		sell := fill(2, model.SideTypeSell, 2, 110, 0)
+		sell.AssetFee, sell.FeeAsset = 0.002, "BTC"
+
+		_, err := MatchTrades([]model.Order{buy, sell}, LotMatchingFIFO)
+		require.ErrorIs(t, err, model.ErrMissingConversion)
+
+		trades, err := MatchTrades([]model.Order{buy, sell}, LotMatchingFIFO,
+			WithFeePrices(map[string]float64{"BNBUSDT": 300}))
+		require.NoError(t, err)
+		require.Len(t, trades, 1)
+		require.InDelta(t, 2*10-0.01*300-0.002*110, trades[0].PnL, 1e-9)
+
+		require.Equal(t, "BNB", trades[0].Open.FeeAsset)
+		require.InDelta(t, 0.01, trades[0].Open.RawFee, 1e-9)
+		require.InDelta(t, 3, trades[0].Open.Fee, 1e-9)
+		require.Equal(t, "BTC", trades[0].Close.FeeAsset)
+		require.InDelta(t, 0.002, trades[0].Close.RawFee, 1e-9)
+		require.InDelta(t, 0.22, trades[0].Close.Fee, 1e-9)
+	})
+
+	t.Run("invalid method", func(t *testing.T) {
+		_, err := MatchTrades(orders, "average")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,93 @@
+package order
+
+import (
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// NotionalMode defines the action on orders with value above the maximum order notional
+type NotionalMode string
+
+const (
+	// NotionalReject rejects the order with ErrMaxOrderNotional
+	NotionalReject NotionalMode = "reject"
+	// NotionalClamp reduces the quantity of the order to the maximum value, rounded down to the step size
+	NotionalClamp NotionalMode = "clamp"
+)
+
+// SetMaxOrderNotional limits the value (price * quantity, in the quote asset) of a single order, a safety rail
+// against bugs in the sizing logic. Orders above the value are rejected with ErrMaxOrderNotional or clamped to
+// it, according to the mode, and the action is notified. Orders with the exact value are accepted. Market
+// orders are valued with the last quote of the pair. Amended orders are checked with the new price and
+// quantity. Reduce-only orders and orders that reduce the position, e.g. stops and OCO exits, are not
+// checked. Zero or a negative value disables the limit.
+func (c *Controller) SetMaxOrderNotional(value float64, mode NotionalMode) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.maxOrderNotional = value
+	c.notionalMode = mode
+}
+
+// checkNotional returns the size of the order limited by the maximum order notional, see SetMaxOrderNotional
+func (c *Controller) checkNotional(side model.SideType, pair string, size, price float64) (float64, error) {
+	if c.maxOrderNotional <= 0 || c.reducesPosition(side, pair, size) {
+		return size, nil
+	}
+
+	if price == 0 {
+		var err error
+		price, err = c.lastQuote(pair)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	value := size * price
+	if value <= c.maxOrderNotional {
+		return size, nil
+	}
+
+	if c.notionalMode == NotionalClamp {
+		clamped := c.exchange.AssetsInfo(pair).RoundQuantity(c.maxOrderNotional / price)
+		if clamped > 0 {
+			c.notifyNotional(side, pair, value, fmt.Sprintf("quantity clamped from %g to %g", size, clamped))
+			return clamped, nil
+		}
+	}
+
+	c.notifyNotional(side, pair, value, "order rejected")
+	return 0, fmt.Errorf("%w: %s %s value %.2f above %.2f", ErrMaxOrderNotional, side, pair, value,
+		c.maxOrderNotional)
+}
+
+// checkNotionalQuote is the equivalent of checkNotional to orders in quote amount
+func (c *Controller) checkNotionalQuote(side model.SideType, pair string, amount float64) (float64, error) {
+	if c.maxOrderNotional <= 0 || amount <= c.maxOrderNotional {
+		return amount, nil
+	}
+
+	if price := c.lastPrice[pair]; price > 0 && c.reducesPosition(side, pair, amount/price) {
+		return amount, nil
+	}
+
+	if c.notionalMode == NotionalClamp {
+		c.notifyNotional(side, pair, amount, fmt.Sprintf("amount clamped from %g to %g", amount,
+			c.maxOrderNotional))
+		return c.maxOrderNotional, nil
+	}
+
+	c.notifyNotional(side, pair, amount, "order rejected")
+	return 0, fmt.Errorf("%w: %s %s value %.2f above %.2f", ErrMaxOrderNotional, side, pair, amount,
+		c.maxOrderNotional)
+}
+
+func (c *Controller) notifyNotional(side model.SideType, pair string, value float64, action string) {
+	c.logger.Warn("[ORDER] Order value above the maximum, "+action, "pair", pair, "side", side, "value", value,
+		"maxOrderNotional", c.maxOrderNotional)
+	if c.notifier != nil {
+		c.notifier.Notify(fmt.Sprintf("⚠️ MAX ORDER VALUE - %s %s\n-----\nValue: %.2f\nMaximum: %.2f\nAction: %s",
+			side, pair, value, c.maxOrderNotional, action))
+	}
+}
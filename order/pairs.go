@@ -0,0 +1,140 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// pairsState is the persisted state of the pairs disabled at runtime
+type pairsState struct {
+	DisabledPairs []string `json:"disabled_pairs"`
+}
+
+// SetPairsStateFile persists the pairs disabled at runtime in the given file, so they stay disabled
+// after a restart. The state of the file is loaded when it exists.
+func (c *Controller) SetPairsStateFile(path string) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.pairsStateFile = path
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state pairsState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return fmt.Errorf("invalid pairs state %s: %w", path, err)
+	}
+
+	for _, pair := range state.DisabledPairs {
+		c.disabledPairs[pair] = true
+	}
+	return nil
+}
+
+// SetDenyPairs blocks new entries on the pairs with ErrPairDenied, e.g. the pairs of model.Settings.DenyPairs.
+// Unlike DisablePair, the deny list is not persisted and can't be changed by EnablePair. Orders that reduce
+// or close a position are still allowed, so positions opened before the pair was denied can be closed.
+func (c *Controller) SetDenyPairs(pairs []string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.deniedPairs = make(map[string]bool, len(pairs))
+	for _, pair := range pairs {
+		c.deniedPairs[pair] = true
+	}
+}
+
+// DisablePair blocks new entries on the pair with ErrPairDisabled, orders that reduce or close
+// the current position are still allowed
+func (c *Controller) DisablePair(pair string) error {
+	return c.setPairEnabled(pair, false)
+}
+
+// EnablePair allows new entries on a pair disabled with DisablePair
+func (c *Controller) EnablePair(pair string) error {
+	return c.setPairEnabled(pair, true)
+}
+
+// PairEnabled returns false when new entries are disabled for the pair
+func (c *Controller) PairEnabled(pair string) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return !c.disabledPairs[pair]
+}
+
+// DisabledPairs returns the pairs with entries disabled, in alphabetical order
+func (c *Controller) DisabledPairs() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.sortedDisabledPairs()
+}
+
+func (c *Controller) sortedDisabledPairs() []string {
+	pairs := make([]string, 0, len(c.disabledPairs))
+	for pair := range c.disabledPairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func (c *Controller) setPairEnabled(pair string, enabled bool) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if enabled {
+		delete(c.disabledPairs, pair)
+		c.logger.Info("[PAIR] Entries enabled", "pair", pair)
+	} else {
+		c.disabledPairs[pair] = true
+		c.logger.Info("[PAIR] Entries disabled", "pair", pair)
+	}
+
+	if c.pairsStateFile == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(pairsState{DisabledPairs: c.sortedDisabledPairs()})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.pairsStateFile, content, 0600)
+}
+
+// checkPairEntry returns ErrPairDenied or ErrPairDisabled when the order opens or increases a position of a
+// denied or disabled pair. The size is ignored when it is unknown (zero), e.g. orders in quote amount.
+func (c *Controller) checkPairEntry(side model.SideType, pair string, size float64) error {
+	denied := c.deniedPairs[pair]
+	if !denied && !c.disabledPairs[pair] {
+		return nil
+	}
+
+	asset, _, err := c.exchange.Position(pair)
+	if err != nil {
+		return err
+	}
+
+	exit := (side == model.SideTypeSell && asset > 0 && size <= asset) ||
+		(side == model.SideTypeBuy && asset < 0 && size <= -asset)
+	if exit {
+		return nil
+	}
+
+	if denied {
+		c.logger.Warn("[ORDER] Entry blocked, pair denied", "pair", pair, "side", side, "quantity", size)
+		return fmt.Errorf("%w: %s %s", ErrPairDenied, side, pair)
+	}
+
+	c.logger.Warn("[ORDER] Entry blocked, pair disabled", "pair", pair, "side", side, "quantity", size)
+	return fmt.Errorf("%w: %s %s", ErrPairDisabled, side, pair)
+}
@@ -0,0 +1,81 @@
+package order
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Performance is a snapshot of the results of the closed trades in a period and of the open positions
+type Performance struct {
+	// Since is the start of the period, zero for all the history
+	Since time.Time
+	// Equity is the current net liquidation value, see Controller.NetLiquidationValue
+	Equity float64
+	// Profit is the realized profit of the trades closed in the period
+	Profit float64
+	// Return is the profit relative to the equity at the start of the period, estimated as the current equity
+	// without the profit, e.g. 0.05 for 5%
+	Return        float64
+	Trades        int
+	WinPercentage float64
+	Payoff        float64
+	ProfitFactor  float64
+	// MaxDrawdown is the largest decline of the realized equity in the period, from a peak after the profit of
+	// each trade, as a fraction of the peak
+	MaxDrawdown   float64
+	Positions     map[string]Position
+	UnrealizedPnL float64
+}
+
+// Performance returns the performance of the trades closed since the given time, zero for all the history,
+// with the metrics of the summary
+func (c *Controller) Performance(since time.Time) (Performance, error) {
+	equity, err := c.NetLiquidationValue()
+	if err != nil {
+		return Performance{}, err
+	}
+
+	c.mtx.Lock()
+	period := &summary{}
+	for _, results := range c.Results {
+		for _, result := range results.Trades {
+			if !result.CreatedAt.Before(since) {
+				period.add(result)
+			}
+		}
+	}
+	c.mtx.Unlock()
+
+	sort.Slice(period.Trades, func(i, j int) bool {
+		return period.Trades[i].CreatedAt.Before(period.Trades[j].CreatedAt)
+	})
+
+	performance := Performance{
+		Since:         since,
+		Equity:        equity,
+		Profit:        period.Profit(),
+		Trades:        len(period.Trades),
+		WinPercentage: period.WinPercentage(),
+		Payoff:        period.Payoff(),
+		ProfitFactor:  period.ProfitFactor(),
+		Positions:     c.OpenPositions(),
+		UnrealizedPnL: c.UnrealizedPnL(nil),
+	}
+
+	start := equity - performance.Profit
+	if start > 0 {
+		performance.Return = performance.Profit / start
+	}
+
+	value, peak := start, start
+	for _, result := range period.Trades {
+		value += result.ProfitValue
+		peak = math.Max(peak, value)
+		if peak > 0 {
+			performance.MaxDrawdown = math.Max(performance.MaxDrawdown, (peak-value)/peak)
+		}
+	}
+
+	return performance, nil
+}
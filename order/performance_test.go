@@ -0,0 +1,62 @@
+package order
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+func TestController_Performance(t *testing.T) {
+	storage, err := storage.FromMemory()
+	require.NoError(t, err)
+	ctx := context.Background()
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewController(ctx, wallet, storage, NewOrderFeed())
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	trade := func(day int, price float64, side model.SideType, quantity float64) {
+		candle := model.Candle{Time: start.AddDate(0, 0, day), Pair: "BTCUSDT", Close: price}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		_, err := controller.CreateOrderMarket(side, "BTCUSDT", quantity)
+		require.NoError(t, err)
+	}
+
+	// +20 closed in the day 1, -10 closed in the day 3 and an open position
+	trade(0, 100, model.SideTypeBuy, 2)
+	trade(1, 110, model.SideTypeSell, 2)
+	trade(2, 100, model.SideTypeBuy, 2)
+	trade(3, 95, model.SideTypeSell, 2)
+	trade(4, 100, model.SideTypeBuy, 1)
+
+	performance, err := controller.Performance(time.Time{})
+	require.NoError(t, err)
+	require.InDelta(t, 1010, performance.Equity, 1e-9)
+	require.InDelta(t, 10, performance.Profit, 1e-9)
+	require.InDelta(t, 0.01, performance.Return, 1e-9)
+	require.Equal(t, 2, performance.Trades)
+	require.Equal(t, 50.0, performance.WinPercentage)
+	require.InDelta(t, 10.0/1020, performance.MaxDrawdown, 1e-9)
+	require.Equal(t, Position{Side: model.SideTypeBuy, AvgPrice: 100, Quantity: 1, CreatedAt: start.AddDate(0, 0, 4)},
+		performance.Positions["BTCUSDT"])
+
+	performance, err = controller.Performance(start.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	require.Equal(t, 1, performance.Trades)
+	require.InDelta(t, -10, performance.Profit, 1e-9)
+	require.InDelta(t, -10.0/1020, performance.Return, 1e-9)
+	require.InDelta(t, 10.0/1020, performance.MaxDrawdown, 1e-9)
+	require.Equal(t, 0.0, performance.WinPercentage)
+
+	performance, err = controller.Performance(start.AddDate(0, 0, 5))
+	require.NoError(t, err)
+	require.Zero(t, performance.Trades)
+	require.Zero(t, performance.Return)
+	require.Zero(t, performance.MaxDrawdown)
+}
@@ -0,0 +1,46 @@
+package order
+
+import (
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// defaultQuantizationTolerance is the reduction of the order quantity by rounding, as a fraction of the
+// requested quantity, accepted without warnings
+const defaultQuantizationTolerance = 0.05
+
+// SetQuantizationTolerance configures the diagnostic of order quantities reduced by the rounding to the step
+// size and precision of the pair, e.g. a strategy size that rounds down to zero. When the rounding reduces
+// the requested quantity by more than the tolerance (a fraction, e.g. 0.05 for 5%), or to zero, a warning
+// is logged and notified with the requested and final quantities. The order is still sent to the exchange.
+// The default tolerance is 5% and a negative value disables the diagnostic.
+func (c *Controller) SetQuantizationTolerance(tolerance float64) {
+	c.quantizationTolerance = tolerance
+}
+
+// checkQuantization warns when the rounding of the order quantity exceeds the quantization tolerance
+func (c *Controller) checkQuantization(side model.SideType, pair string, size float64) {
+	if c.quantizationTolerance < 0 || size <= 0 {
+		return
+	}
+
+	info := c.exchange.AssetsInfo(pair)
+	quantity := info.RoundQuantity(size)
+	reduction := (size - quantity) / size
+	if quantity > 0 && reduction <= c.quantizationTolerance {
+		return
+	}
+
+	reason := fmt.Sprintf("step size %g, precision %d", info.StepSize, info.BaseAssetPrecision)
+	if quantity <= 0 {
+		reason = "rounded to zero by the " + reason
+	}
+
+	c.logger.Warn("[ORDER] Quantity reduced by rounding", "pair", pair, "side", side, "requested", size,
+		"quantity", quantity, "reduction", reduction, "reason", reason)
+	if c.notifier != nil {
+		c.notifier.Notify(fmt.Sprintf("⚠️ QUANTITY ROUNDED - %s %s\n-----\nRequested: %g\nFinal: %g\nReason: %s",
+			side, pair, size, quantity, reason))
+	}
+}
@@ -0,0 +1,251 @@
+package order
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+// ReconcileReport is the summary of the differences between the stored orders and the exchange, found by
+// Reconcile at startup
+type ReconcileReport struct {
+	// Filled are the stored open orders filled while the bot was down
+	Filled []model.Order
+	// Canceled are the stored open orders canceled, rejected or expired while the bot was down
+	Canceled []model.Order
+	// Open are the stored open orders still waiting for execution
+	Open []model.Order
+	// Adopted are the orders of the exchange not found in the storage, open or filled while the bot was down,
+	// now tracked by the controller, see Controller.SetOrderAdoption
+	Adopted []model.Order
+	// Ignored are the orders of the exchange not found in the storage and not adopted, e.g. manual orders
+	Ignored []model.Order
+	// Positions are the open positions after the reconciliation
+	Positions map[string]Position
+	Errors    []error
+}
+
+// Changed returns true when the exchange diverged from the stored orders
+func (r ReconcileReport) Changed() bool {
+	return len(r.Filled) > 0 || len(r.Canceled) > 0 || len(r.Adopted) > 0 || len(r.Ignored) > 0
+}
+
+func (r ReconcileReport) String() string {
+	parts := []string{fmt.Sprintf("%d orders filled, %d canceled, %d open, %d adopted, %d ignored",
+		len(r.Filled), len(r.Canceled), len(r.Open), len(r.Adopted), len(r.Ignored))}
+
+	pairs := make([]string, 0, len(r.Positions))
+	for pair := range r.Positions {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	for _, pair := range pairs {
+		position := r.Positions[pair]
+		parts = append(parts, fmt.Sprintf("%s: %s %f @ %f", pair, position.Side, position.Quantity,
+			position.AvgPrice))
+	}
+
+	if len(r.Errors) > 0 {
+		parts = append(parts, fmt.Sprintf("%d errors", len(r.Errors)))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// reconcileHistoryLimit is the number of recent orders of each pair fetched by Reconcile to find the fills
+// while the bot was down
+const reconcileHistoryLimit = 500
+
+// SetOrderAdoption enables the adoption of the orders of the exchange not found in the storage by Reconcile.
+// The open orders are stored and tracked, and the orders filled after the last stored order update the
+// positions. It is disabled by default, since the account may have orders not created by the bot, e.g.
+// manual orders, which are only reported.
+func (c *Controller) SetOrderAdoption(enabled bool) {
+	c.adoptOrders = enabled
+}
+
+// Reconcile synchronizes the controller with the exchange at startup, when the orders may have changed while
+// the bot was down. The positions are restored from the stored filled orders, the stored open orders are
+// updated with their status in the exchange, and the orders filled meanwhile update the positions. The open
+// orders of the given pairs missing in the storage, and the orders filled after the last stored order, are
+// adopted with SetOrderAdoption or reported as ignored. It requires an exchange that implements
+// service.OpenOrdersBroker and service.OrderHistoryBroker, respectively. Failures do not stop the
+// reconciliation, they are reported in the summary. Differences are logged and notified. It must be called
+// before the controller is started.
+func (c *Controller) Reconcile(pairs ...string) ReconcileReport {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var report ReconcileReport
+	filled, err := c.storage.Orders(storage.WithStatus(model.OrderStatusTypeFilled))
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("stored filled orders: %w", err))
+	}
+	c.restorePositions(filled)
+
+	open, err := c.storage.Orders(storage.WithStatusIn(
+		model.OrderStatusTypeNew,
+		model.OrderStatusTypePartiallyFilled,
+		model.OrderStatusTypePendingCancel,
+	))
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("stored open orders: %w", err))
+	}
+
+	stored, err := c.storage.Orders()
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("stored orders: %w", err))
+	}
+
+	// the fills of the exchange after the last stored order happened while the bot was down
+	known := make(map[int64]bool, len(stored))
+	var lastUpdate time.Time
+	for _, order := range stored {
+		known[order.ExchangeID] = true
+		if order.UpdatedAt.After(lastUpdate) {
+			lastUpdate = order.UpdatedAt
+		}
+	}
+
+	for _, order := range open {
+		c.reconcileOrder(*order, &report)
+	}
+
+	if broker, ok := c.exchange.(service.OrderHistoryBroker); ok && !lastUpdate.IsZero() {
+		for _, pair := range pairs {
+			orders, err := broker.Orders(pair, reconcileHistoryLimit)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Errorf("orders of %s: %w", pair, err))
+				continue
+			}
+
+			sort.SliceStable(orders, func(i, j int) bool {
+				return orders[i].UpdatedAt.Before(orders[j].UpdatedAt)
+			})
+			for _, order := range orders {
+				if known[order.ExchangeID] || order.Status != model.OrderStatusTypeFilled ||
+					!order.UpdatedAt.After(lastUpdate) {
+					continue
+				}
+				known[order.ExchangeID] = true
+				c.adoptOrder(order, &report)
+			}
+		}
+	}
+
+	if broker, ok := c.exchange.(service.OpenOrdersBroker); ok {
+		for _, pair := range pairs {
+			orders, err := broker.OpenOrders(pair)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Errorf("open orders of %s: %w", pair, err))
+				continue
+			}
+
+			for _, order := range orders {
+				if known[order.ExchangeID] {
+					continue
+				}
+				known[order.ExchangeID] = true
+				c.adoptOrder(order, &report)
+			}
+		}
+	}
+
+	report.Positions = make(map[string]Position, len(c.position))
+	for pair, position := range c.position {
+		report.Positions[pair] = *position
+	}
+
+	c.logger.Info("[RECONCILE] "+strings.ReplaceAll(report.String(), "\n", ", "), "changed", report.Changed())
+	if report.Changed() || len(report.Errors) > 0 {
+		c.notify(fmt.Sprintf("⚠️ ORDERS RECONCILED\n-----\n%s", report))
+	}
+	return report
+}
+
+// adoptOrder stores and tracks an order of the exchange not found in the storage, a filled order updates the
+// position. Without adoption, the order is only reported.
+func (c *Controller) adoptOrder(order model.Order, report *ReconcileReport) {
+	if !c.adoptOrders {
+		c.logger.Warn("[ORDER IGNORED] Order not created by the bot", orderFields(order)...)
+		report.Ignored = append(report.Ignored, order)
+		return
+	}
+
+	if err := c.storage.CreateOrder(&order); err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("adopt order %d: %w", order.ExchangeID, err))
+		return
+	}
+	c.logger.Info("[ORDER ADOPTED]", orderFields(order)...)
+	if order.Status == model.OrderStatusTypeFilled {
+		c.processTrade(&order)
+	}
+	report.Adopted = append(report.Adopted, order)
+}
+
+// reconcileOrder updates a stored open order with its status in the exchange
+func (c *Controller) reconcileOrder(order model.Order, report *ReconcileReport) {
+	excOrder, err := c.exchange.Order(order.Pair, order.ExchangeID)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("order %d: %w", order.ExchangeID, err))
+		report.Open = append(report.Open, order)
+		return
+	}
+
+	if excOrder.Status == order.Status {
+		report.Open = append(report.Open, order)
+		return
+	}
+
+	excOrder.ID = order.ID
+	excOrder.Strategy = order.Strategy
+	if err := c.storage.UpdateOrder(&excOrder); err != nil {
+		report.Errors = append(report.Errors, fmt.Errorf("order %d: %w", order.ExchangeID, err))
+		return
+	}
+	c.logger.Info(fmt.Sprintf("[ORDER %s]", excOrder.Status), orderFields(excOrder)...)
+
+	switch excOrder.Status {
+	case model.OrderStatusTypeFilled:
+		c.processTrade(&excOrder)
+		report.Filled = append(report.Filled, excOrder)
+	case model.OrderStatusTypeCanceled, model.OrderStatusTypeRejected, model.OrderStatusTypeExpired:
+		report.Canceled = append(report.Canceled, excOrder)
+	default:
+		report.Open = append(report.Open, excOrder)
+	}
+	c.updateSubAccount(excOrder)
+	go c.orderFeed.Publish(excOrder, false)
+}
+
+// restorePositions rebuilds the positions from the filled orders of the previous runs, in the order of
+// execution. The results of the previous runs are not registered again.
+func (c *Controller) restorePositions(orders []*model.Order) {
+	c.position = make(map[string]*Position)
+	sort.SliceStable(orders, func(i, j int) bool {
+		return orders[i].UpdatedAt.Before(orders[j].UpdatedAt)
+	})
+
+	for _, order := range orders {
+		fee := c.quoteFee(*order)
+		position, ok := c.position[order.Pair]
+		if !ok {
+			c.position[order.Pair] = &Position{
+				AvgPrice:  order.Price,
+				Quantity:  order.Quantity,
+				CreatedAt: order.CreatedAt,
+				Side:      order.Side,
+				Fee:       fee,
+			}
+			continue
+		}
+
+		if _, closed := position.Update(order, fee); closed {
+			delete(c.position, order.Pair)
+		}
+	}
+}
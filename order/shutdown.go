@@ -0,0 +1,159 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// ShutdownPolicy defines the actions on the orders and positions of a pair when the bot shuts down
+type ShutdownPolicy string
+
+const (
+	// ShutdownHold keeps the positions and the open orders, e.g. stops, for the next run. It is the default.
+	ShutdownHold ShutdownPolicy = "hold"
+	// ShutdownCancelOrders cancels the open orders and keeps the positions
+	ShutdownCancelOrders ShutdownPolicy = "cancel-orders"
+	// ShutdownFlatten cancels the open orders and closes the positions of the bot with market orders
+	ShutdownFlatten ShutdownPolicy = "flatten"
+)
+
+// ShutdownAction is the summary of the actions taken by a shutdown policy in a pair
+type ShutdownAction struct {
+	Pair     string
+	Policy   ShutdownPolicy
+	Canceled []model.Order
+	// Closed is the market order that closed the position, nil when there was no position to close
+	Closed *model.Order
+	Errors []error
+}
+
+func (a ShutdownAction) String() string {
+	parts := []string{fmt.Sprintf("%s: %s", a.Pair, a.Policy)}
+	if len(a.Canceled) > 0 {
+		parts = append(parts, fmt.Sprintf("%d orders canceled", len(a.Canceled)))
+	}
+	if a.Closed != nil {
+		parts = append(parts, fmt.Sprintf("position closed with %s %f", a.Closed.Side, a.Closed.Quantity))
+	}
+	if len(a.Errors) > 0 {
+		parts = append(parts, fmt.Sprintf("%d errors", len(a.Errors)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Shutdown applies the shutdown policy to the pair, see ShutdownPolicy. Failures do not stop the other
+// actions, they are reported in the summary. Flatten closes only the position tracked by the controller, with a
+// reduce-only order when the exchange supports it, the balances not traded by the bot are kept.
+//
+// The context bounds the actions, e.g. with a timeout, it must not be the context of the bot, that is already
+// cancelled when it shuts down. When the context is done, Shutdown returns the context error, the actions
+// still running are not reported and no new order is submitted.
+func (c *Controller) Shutdown(ctx context.Context, pair string, policy ShutdownPolicy) ShutdownAction {
+	action := ShutdownAction{Pair: pair, Policy: policy}
+
+	switch policy {
+	case "", ShutdownHold:
+		action.Policy = ShutdownHold
+		return action
+	case ShutdownCancelOrders, ShutdownFlatten:
+	default:
+		action.Errors = append(action.Errors, fmt.Errorf("invalid shutdown policy: %s", policy))
+		return action
+	}
+
+	if err := ctx.Err(); err != nil {
+		action.Errors = append(action.Errors, fmt.Errorf("shutdown: %w", err))
+		return action
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan ShutdownAction, 1)
+	go func() {
+		action := action
+		c.shutdown(ctx, &action)
+		done <- action
+	}()
+
+	select {
+	case action = <-done:
+	case <-ctx.Done():
+		action.Errors = append(action.Errors, fmt.Errorf("shutdown: %w", ctx.Err()))
+	}
+
+	c.logger.Info("[SHUTDOWN] "+action.String(), "pair", pair, "policy", action.Policy)
+	return action
+}
+
+// shutdown cancels the open orders of the pair and closes the position with the flatten policy, the actions
+// stop when the context is done
+func (c *Controller) shutdown(ctx context.Context, action *ShutdownAction) {
+	c.mtx.Lock()
+	orders, err := c.openOrders(action.Pair)
+	c.mtx.Unlock()
+	if err != nil {
+		action.Errors = append(action.Errors, err)
+	}
+
+	for _, order := range orders {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := c.Cancel(*order); err != nil {
+			action.Errors = append(action.Errors, fmt.Errorf("cancel order %d: %w", order.ExchangeID, err))
+			continue
+		}
+		action.Canceled = append(action.Canceled, *order)
+	}
+
+	if action.Policy == ShutdownFlatten {
+		c.flatten(ctx, action)
+	}
+}
+
+// flatten closes the position of the pair tracked by the controller with a market order in the opposite side.
+// Quantities below the step size or the minimum quantity of the pair are kept.
+func (c *Controller) flatten(ctx context.Context, action *ShutdownAction) {
+	c.mtx.Lock()
+	var position Position
+	if tracked, ok := c.position[action.Pair]; ok {
+		position = *tracked
+	}
+	c.mtx.Unlock()
+
+	side := model.SideTypeSell
+	if position.Side == model.SideTypeSell {
+		side = model.SideTypeBuy
+	}
+
+	info := c.exchange.AssetsInfo(action.Pair)
+	quantity := info.RoundQuantity(position.Quantity)
+	if quantity <= 0 || quantity < info.MinQuantity {
+		return
+	}
+
+	// the timeout may be already reported, the order is not submitted after it
+	if ctx.Err() != nil {
+		return
+	}
+
+	var (
+		order model.Order
+		err   error
+	)
+	if _, ok := c.exchange.(service.ReduceOnlyBroker); ok {
+		order, err = c.CreateOrderMarketReduceOnly(side, action.Pair, quantity)
+	} else {
+		order, err = c.CreateOrderMarket(side, action.Pair, quantity)
+	}
+	if err != nil {
+		action.Errors = append(action.Errors, fmt.Errorf("close position: %w", err))
+		return
+	}
+	action.Closed = &order
+}
@@ -0,0 +1,53 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// spreadBookLimit is the number of levels requested to the exchange, only the best bid and ask are used
+const spreadBookLimit = 5
+
+// SetMaxSpread rejects market orders of the pair with ErrWideSpread when the spread of the order book,
+// as a fraction of the mid price (e.g. 0.002 for 0.2%), exceeds the given value, to avoid trading with thin
+// liquidity. Zero or a negative value disables the check. It requires an exchange that provides the order
+// book, see service.DepthFeeder, otherwise the check is skipped with a warning. Reduce-only orders and
+// orders that reduce the position are not checked.
+func (c *Controller) SetMaxSpread(pair string, maxSpread float64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if maxSpread <= 0 {
+		delete(c.maxSpread, pair)
+		return
+	}
+	c.maxSpread[pair] = maxSpread
+}
+
+// checkSpread returns ErrWideSpread when the spread of the pair exceeds the configured maximum. The size is
+// unknown when zero, e.g. orders in quote amount.
+func (c *Controller) checkSpread(side model.SideType, pair string, size float64) error {
+	maxSpread, ok := c.maxSpread[pair]
+	if !ok || c.reducesPosition(side, pair, size) {
+		return nil
+	}
+
+	book, err := exchange.OrderBook(c.ctx, c.exchange, pair, spreadBookLimit)
+	if errors.Is(err, exchange.ErrOrderBookNotSupported) {
+		c.logger.Warn("[ORDER] Spread not checked, order book not supported by the exchange", "pair", pair)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if spread := book.Spread(); spread > maxSpread {
+		c.logger.Warn("[ORDER] Market order blocked, wide spread", "pair", pair, "spread", spread,
+			"maxSpread", maxSpread)
+		return fmt.Errorf("%w: %s spread %.4f%% above %.4f%%", ErrWideSpread, pair, spread*100, maxSpread*100)
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package order
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// SubAccount is a virtual partition of the exchange account, e.g. to run multiple strategies on the same
+// account without sharing balances. It implements service.Broker, so it can be given to a strategy instead
+// of the controller. Orders are tagged with the sub-account name and rejected with ErrInsufficientAllocation
+// when they spend more than the balance allocated to the sub-account.
+//
+// The balances are updated when the orders are filled, open orders lock the amount they may spend.
+// Partial fills are accounted only when the order is completely filled.
+type SubAccount struct {
+	mtx        sync.Mutex
+	name       string
+	controller *Controller
+	balances   map[string]float64
+	locked     map[string]lockedAmount
+}
+
+// lockedAmount is the balance reserved by an open order, or by the orders of an OCO group
+type lockedAmount struct {
+	asset  string
+	amount float64
+}
+
+// SubAccount creates a sub-account with the given balance of each asset. The sum of the allocations
+// of all sub-accounts can not exceed the balance of the exchange account.
+func (c *Controller) SubAccount(name string, allocation map[string]float64) (*SubAccount, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if name == "" {
+		return nil, fmt.Errorf("%w: empty sub-account name", ErrInvalidAllocation)
+	}
+
+	if _, ok := c.subAccounts[name]; ok {
+		return nil, fmt.Errorf("%w: sub-account %s already exists", ErrInvalidAllocation, name)
+	}
+
+	subAccount := &SubAccount{
+		name:       name,
+		controller: c,
+		balances:   make(map[string]float64),
+		locked:     make(map[string]lockedAmount),
+	}
+
+	if err := c.checkAllocations(subAccount, allocation); err != nil {
+		return nil, err
+	}
+
+	for asset, amount := range allocation {
+		subAccount.balances[asset] = amount
+	}
+
+	c.subAccounts[name] = subAccount
+	c.logger.Info("[SUB-ACCOUNT] Created", "name", name, "allocation", allocation)
+	return subAccount, nil
+}
+
+// Rebalance replaces the balance of the given assets in a sub-account, other assets are not changed.
+// The new balance can not be lower than the amount locked by open orders.
+func (c *Controller) Rebalance(name string, allocation map[string]float64) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	subAccount, ok := c.subAccounts[name]
+	if !ok {
+		return fmt.Errorf("%w: sub-account %s not found", ErrInvalidAllocation, name)
+	}
+
+	if err := c.checkAllocations(subAccount, allocation); err != nil {
+		return err
+	}
+
+	subAccount.mtx.Lock()
+	defer subAccount.mtx.Unlock()
+
+	for asset, amount := range allocation {
+		if locked := subAccount.lockedAsset(asset); amount < locked {
+			return fmt.Errorf("%w: %s has %f %s locked in open orders", ErrInvalidAllocation, name, locked, asset)
+		}
+	}
+
+	for asset, amount := range allocation {
+		subAccount.balances[asset] = amount
+	}
+
+	c.logger.Info("[SUB-ACCOUNT] Rebalanced", "name", name, "allocation", allocation)
+	return nil
+}
+
+// checkAllocations returns ErrInvalidAllocation when the new allocation of the sub-account,
+// summed to the other sub-accounts, exceeds the balance of the exchange account
+func (c *Controller) checkAllocations(subAccount *SubAccount, allocation map[string]float64) error {
+	account, err := c.exchange.Account()
+	if err != nil {
+		return err
+	}
+
+	total := make(map[string]float64)
+	for _, balance := range account.Balances {
+		total[balance.Asset] = balance.Free + balance.Lock
+	}
+
+	for asset, amount := range allocation {
+		if amount < 0 {
+			return fmt.Errorf("%w: negative allocation of %s", ErrInvalidAllocation, asset)
+		}
+
+		allocated := amount
+		for name, other := range c.subAccounts {
+			if name != subAccount.name {
+				allocated += other.Balance(asset)
+			}
+		}
+
+		if allocated > total[asset] {
+			return fmt.Errorf("%w: %f %s allocated, account balance %f", ErrInvalidAllocation,
+				allocated, asset, total[asset])
+		}
+	}
+	return nil
+}
+
+// checkAllocation returns ErrInsufficientAllocation when the sub-account can not afford the order.
+// Buy orders spend size * price of the quote asset, the last price is used when the price is zero.
+func (c *Controller) checkAllocation(owner *SubAccount, side model.SideType, pair string, size, price float64) error {
+	if owner == nil {
+		return nil
+	}
+
+	asset, quote := exchange.SplitAssetQuote(pair)
+	if side == model.SideTypeSell {
+		return owner.checkAvailable(asset, size)
+	}
+
+	if price == 0 {
+		var err error
+		price, err = c.lastQuote(pair)
+		if err != nil {
+			return err
+		}
+	}
+	return owner.checkAvailable(quote, size*price)
+}
+
+// checkAllocationQuote is the equivalent of checkAllocation to orders in quote amount
+func (c *Controller) checkAllocationQuote(owner *SubAccount, side model.SideType, pair string, amount float64) error {
+	if owner == nil {
+		return nil
+	}
+
+	asset, quote := exchange.SplitAssetQuote(pair)
+	if side == model.SideTypeBuy {
+		return owner.checkAvailable(quote, amount)
+	}
+
+	price, err := c.lastQuote(pair)
+	if err != nil {
+		return err
+	}
+	return owner.checkAvailable(asset, amount/price)
+}
+
+func (c *Controller) lastQuote(pair string) (float64, error) {
+	if price, ok := c.lastPrice[pair]; ok {
+		return price, nil
+	}
+	return c.exchange.LastQuote(c.ctx, pair)
+}
+
+// updateSubAccount updates the balances of the sub-account that owns the order, if any
+func (c *Controller) updateSubAccount(order model.Order) {
+	if subAccount, ok := c.subAccounts[order.Strategy]; ok {
+		subAccount.onOrder(order)
+	}
+}
+
+// amendSubAccount replaces the amount locked by an order amended in place
+func (c *Controller) amendSubAccount(order model.Order) {
+	if subAccount, ok := c.subAccounts[order.Strategy]; ok {
+		subAccount.mtx.Lock()
+		delete(subAccount.locked, lockKey(order))
+		subAccount.mtx.Unlock()
+		subAccount.onOrder(order)
+	}
+}
+
+// Name returns the name of the sub-account, empty for a nil sub-account (main account)
+func (s *SubAccount) Name() string {
+	if s == nil {
+		return ""
+	}
+	return s.name
+}
+
+// Balance returns the balance of the asset in the sub-account, including the amount locked in open orders
+func (s *SubAccount) Balance(asset string) float64 {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.balances[asset]
+}
+
+func (s *SubAccount) lockedAsset(asset string) float64 {
+	var total float64
+	for _, locked := range s.locked {
+		if locked.asset == asset {
+			total += locked.amount
+		}
+	}
+	return total
+}
+
+func (s *SubAccount) checkAvailable(asset string, amount float64) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	available := s.balances[asset] - s.lockedAsset(asset)
+	if amount > available {
+		return fmt.Errorf("%w: %s requires %f %s, available %f", ErrInsufficientAllocation,
+			s.name, amount, asset, available)
+	}
+	return nil
+}
+
+// lockKey identifies the balance locked by an order, orders of an OCO group share the same lock
+func lockKey(order model.Order) string {
+	if order.GroupID != nil {
+		return fmt.Sprintf("group:%d", *order.GroupID)
+	}
+	return fmt.Sprintf("order:%d", order.ID)
+}
+
+func (s *SubAccount) onOrder(order model.Order) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	asset, quote := exchange.SplitAssetQuote(order.Pair)
+	key := lockKey(order)
+
+	switch order.Status {
+	case model.OrderStatusTypeNew, model.OrderStatusTypePartiallyFilled:
+		locked := lockedAmount{asset: asset, amount: order.Quantity}
+		if order.Side == model.SideTypeBuy {
+			locked = lockedAmount{asset: quote, amount: order.Quantity * order.Price}
+		}
+
+		// legs of OCO orders lock the highest amount between them
+		if current, ok := s.locked[key]; ok && current.amount > locked.amount {
+			return
+		}
+		s.locked[key] = locked
+	case model.OrderStatusTypeFilled:
+		delete(s.locked, key)
+		value := order.Quantity * order.Price
+		if order.Side == model.SideTypeBuy {
+			s.balances[asset] += order.Quantity
+			s.balances[quote] -= value + order.Fee
+		} else {
+			s.balances[asset] -= order.Quantity
+			s.balances[quote] += value - order.Fee
+		}
+
+		// fees in other assets are charged only when the asset is allocated to the sub-account
+		if _, ok := s.balances[order.FeeAsset]; ok && order.FeeAsset != "" {
+			s.balances[order.FeeAsset] -= order.AssetFee
+		}
+	case model.OrderStatusTypeCanceled, model.OrderStatusTypeRejected, model.OrderStatusTypeExpired:
+		delete(s.locked, key)
+	}
+}
+
+// Account returns the balances of the sub-account, the amount locked in open orders is reported as Lock
+func (s *SubAccount) Account() (model.Account, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	assets := make([]string, 0, len(s.balances))
+	for asset := range s.balances {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+
+	balances := make([]model.Balance, 0, len(assets))
+	for _, asset := range assets {
+		locked := s.lockedAsset(asset)
+		balances = append(balances, model.Balance{
+			Asset: asset,
+			Free:  s.balances[asset] - locked,
+			Lock:  locked,
+		})
+	}
+	return model.Account{Balances: balances}, nil
+}
+
+func (s *SubAccount) Position(pair string) (asset, quote float64, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	assetTick, quoteTick := exchange.SplitAssetQuote(pair)
+	return s.balances[assetTick], s.balances[quoteTick], nil
+}
+
+func (s *SubAccount) Order(pair string, id int64) (model.Order, error) {
+	return s.controller.Order(pair, id)
+}
+
+func (s *SubAccount) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	return s.controller.createOrderOCO(s, side, pair, size, price, stop, stopLimit)
+}
+
+func (s *SubAccount) CreateOrderLimit(side model.SideType, pair string, size, limit float64) (model.Order, error) {
+	return s.controller.createOrderLimit(s, side, pair, size, limit)
+}
+
+func (s *SubAccount) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return s.controller.createOrderMarket(s, side, pair, size)
+}
+
+func (s *SubAccount) CreateOrderMarketQuote(side model.SideType, pair string, quote float64) (model.Order, error) {
+	return s.controller.createOrderMarketQuote(s, side, pair, quote)
+}
+
+func (s *SubAccount) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	return s.controller.createOrderStop(s, pair, quantity, limit)
+}
+
+func (s *SubAccount) Cancel(order model.Order) error {
+	return s.controller.Cancel(order)
+}
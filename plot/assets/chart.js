@@ -113,6 +113,34 @@ document.addEventListener("DOMContentLoaded", function () {
           });
       });
 
+      // signals of the signal-only mode, the orders are not executed
+      (data.signals || []).forEach((signal) => {
+        const indicators = Object.entries(signal.indicators || {})
+          .map(([name, value]) => `<br>${name}: ${value.toLocaleString()}`)
+          .join("");
+        const buy = signal.side !== SELL_SIDE;
+        annotations.push({
+          x: signal.time,
+          y: signal.price,
+          xref: "x1",
+          yref: "y2",
+          text: buy ? "B?" : "S?",
+          hovertext: `Signal ${signal.side} ${signal.type}
+                    <br>Price: ${signal.price.toLocaleString()}
+                    <br>Reason: ${signal.reason}${indicators}`,
+          showarrow: true,
+          arrowcolor: buy ? "green" : "red",
+          arrowhead: 2,
+          opacity: 0.6,
+          ax: 0,
+          ay: buy ? 30 : -30,
+          font: {
+            size: 12,
+            color: buy ? "green" : "red",
+          },
+        });
+      });
+
       const shapes = data.shapes.map((s) => {
         return {
           type: "rect",
@@ -173,6 +201,28 @@ document.addEventListener("DOMContentLoaded", function () {
         });
       }
 
+      // rolling expectancy of the last closed trades
+      if (data.rolling_stats && data.rolling_stats.trades > 0) {
+        const stats = data.rolling_stats;
+        annotations.push({
+          x: 0,
+          y: 1,
+          xref: "paper",
+          yref: "paper",
+          xanchor: "left",
+          yanchor: "top",
+          align: "left",
+          showarrow: false,
+          text: `Last ${stats.trades} trades
+                <br>Win rate: ${(stats.win_rate * 100).toFixed(1)}%
+                <br>Expectancy: ${stats.expectancy.toFixed(4)}
+                <br>Profit factor: ${stats.profit_factor.toFixed(3)}`,
+          font: {
+            size: 12,
+          },
+        });
+      }
+
       const sellPoints = points.filter((p) => p.side === SELL_SIDE);
       const buyPoints = points.filter((p) => p.side === BUY_SIDE);
       const buyData = {
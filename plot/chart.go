@@ -15,6 +15,7 @@ import (
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
 	"github.com/rodrigo-brito/ninjabot/strategy"
 
 	"github.com/StudioSol/set"
@@ -35,8 +36,10 @@ type Chart struct {
 	dataframe       map[string]*model.Dataframe
 	ordersIDsByPair map[string]*set.LinkedHashSetINT64
 	orderByID       map[int64]model.Order
+	signals         map[string][]model.Signal
 	indicators      []Indicator
 	paperWallet     *exchange.PaperWallet
+	rollingStats    func() order.RollingStats
 	scriptContent   string
 	indexHTML       *template.Template
 	strategy        strategy.Strategy
@@ -87,6 +90,15 @@ type drawdown struct {
 	End   time.Time `json:"end"`
 }
 
+type rollingStats struct {
+	Trades       int     `json:"trades"`
+	WinRate      float64 `json:"win_rate"`
+	AvgWin       float64 `json:"avg_win"`
+	AvgLoss      float64 `json:"avg_loss"`
+	ProfitFactor float64 `json:"profit_factor"`
+	Expectancy   float64 `json:"expectancy"`
+}
+
 type Indicator interface {
 	Name() string
 	Overlay() bool
@@ -111,6 +123,14 @@ func (c *Chart) OnOrder(order model.Order) {
 	c.orderByID[order.ID] = order
 }
 
+// OnSignal registers a signal of the signal-only mode, displayed as a marker in the chart
+func (c *Chart) OnSignal(signal model.Signal) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.signals[signal.Pair] = append(c.signals[signal.Pair], signal)
+}
+
 func (c *Chart) OnCandle(candle model.Candle) {
 	c.Lock()
 	defer c.Unlock()
@@ -227,6 +247,34 @@ func (c *Chart) indicatorsByPair(pair string) []plotIndicator {
 		}
 	}
 
+	// indicators declared with strategy.RegisterIndicator
+	dataframe, ok := c.dataframe[pair]
+	if !ok {
+		return indicators
+	}
+
+	for _, spec := range strategy.RegisteredIndicators() {
+		values, ok := dataframe.Metadata[spec.Source]
+		if !ok || len(values) <= spec.Warmup || len(values) > len(dataframe.Time) {
+			continue
+		}
+
+		// values are aligned with the last candles when the series is shorter than the dataframe
+		times := dataframe.Time[len(dataframe.Time)-len(values):]
+		indicators = append(indicators, plotIndicator{
+			Name:    spec.Name,
+			Overlay: spec.Overlay,
+			Warmup:  spec.Warmup,
+			Metrics: []indicatorMetric{{
+				Name:   spec.Name,
+				Time:   times[spec.Warmup:],
+				Values: values[spec.Warmup:],
+				Color:  spec.Color,
+				Style:  string(spec.Style),
+			}},
+		})
+	}
+
 	return indicators
 }
 
@@ -291,6 +339,13 @@ func (c *Chart) shapesByPair(pair string) []Shape {
 	return shapes
 }
 
+func (c *Chart) signalsByPair(pair string) []model.Signal {
+	c.Lock()
+	defer c.Unlock()
+
+	return append(make([]model.Signal, 0, len(c.signals[pair])), c.signals[pair]...)
+}
+
 func (c *Chart) orderStringByPair(pair string) [][]string {
 	orders := make([][]string, 0)
 	for id := range c.ordersIDsByPair[pair].Iter() {
@@ -361,17 +416,32 @@ func (c *Chart) handleData(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var stats *rollingStats
+	if c.rollingStats != nil {
+		value := c.rollingStats()
+		stats = &rollingStats{
+			Trades:       value.Trades,
+			WinRate:      value.WinRate,
+			AvgWin:       value.AvgWin,
+			AvgLoss:      value.AvgLoss,
+			ProfitFactor: value.ProfitFactor,
+			Expectancy:   value.Expectancy,
+		}
+	}
+
 	asset, quote := exchange.SplitAssetQuote(pair)
 	assetValues, equityValues := c.equityValuesByPair(pair)
 	err := json.NewEncoder(w).Encode(map[string]interface{}{
 		"candles":       c.candlesByPair(pair),
 		"indicators":    c.indicatorsByPair(pair),
 		"shapes":        c.shapesByPair(pair),
+		"signals":       c.signalsByPair(pair),
 		"asset_values":  assetValues,
 		"equity_values": equityValues,
 		"quote":         quote,
 		"asset":         asset,
 		"max_drawdown":  maxDrawdown,
+		"rolling_stats": stats,
 	})
 	if err != nil {
 		log.Error(err)
@@ -457,6 +527,13 @@ func WithPaperWallet(paperWallet *exchange.PaperWallet) Option {
 	}
 }
 
+// WithRollingStats shows the win rate and expectancy of the last closed trades, e.g. NinjaBot.RollingStats
+func WithRollingStats(source func() order.RollingStats) Option {
+	return func(chart *Chart) {
+		chart.rollingStats = source
+	}
+}
+
 // WithDebug starts chart without compress
 func WithDebug() Option {
 	return func(chart *Chart) {
@@ -477,6 +554,7 @@ func NewChart(options ...Option) (*Chart, error) {
 		dataframe:       make(map[string]*model.Dataframe),
 		ordersIDsByPair: make(map[string]*set.LinkedHashSetINT64),
 		orderByID:       make(map[int64]model.Order),
+		signals:         make(map[string][]model.Signal),
 	}
 
 	for _, option := range options {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/rodrigo-brito/ninjabot/exchange"
 	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/strategy"
 
 	"github.com/StudioSol/set"
 	"github.com/stretchr/testify/require"
@@ -147,6 +148,18 @@ func TestChart_CandleAndOrder(t *testing.T) {
 	require.Equal(t, expectShapesByPair, shaped)
 }
 
+func TestChart_OnSignal(t *testing.T) {
+	c, err := NewChart()
+	require.NoError(t, err)
+
+	signal := model.Signal{Time: time.Date(2021, 9, 26, 20, 0, 0, 0, time.UTC), Pair: "BTCUSDT",
+		Side: model.SideTypeBuy, Type: model.OrderTypeMarket, Price: 43000, Indicators: map[string]float64{"rsi": 25}}
+	c.OnSignal(signal)
+
+	require.Equal(t, []model.Signal{signal}, c.signalsByPair("BTCUSDT"))
+	require.Empty(t, c.signalsByPair("ETHUSDT"))
+}
+
 func TestChart_WithPort(t *testing.T) {
 	port := 8081
 	c, err := NewChart(WithPort(port))
@@ -174,6 +187,44 @@ func TestChart_WithIndicator(t *testing.T) {
 	require.Equal(t, indicator, c.indicators)
 }
 
+func TestChart_RegisteredIndicator(t *testing.T) {
+	strategy.RegisterIndicator(strategy.IndicatorSpec{
+		Name:    "VWAP",
+		Source:  "vwap",
+		Overlay: true,
+		Color:   "blue",
+		Warmup:  1,
+	})
+
+	c, err := NewChart()
+	require.NoError(t, err)
+
+	start := time.Date(2021, 9, 26, 20, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		c.OnCandle(model.Candle{
+			Pair:     "ETHUSDT",
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Close:    float64(10 + i),
+			Complete: true,
+			Metadata: map[string]float64{"vwap": float64(20 + i)},
+		})
+	}
+
+	indicators := c.indicatorsByPair("ETHUSDT")
+	require.Contains(t, indicators, plotIndicator{
+		Name:    "VWAP",
+		Overlay: true,
+		Warmup:  1,
+		Metrics: []indicatorMetric{{
+			Name:   "VWAP",
+			Time:   []time.Time{start.Add(time.Hour), start.Add(2 * time.Hour)},
+			Values: []float64{21, 22},
+			Color:  "blue",
+			Style:  strategy.StyleLine,
+		}},
+	})
+}
+
 func TestChart_OrderStringByPair(t *testing.T) {
 	c, err := NewChart()
 	require.NoErrorf(t, err, "error when initial chart")
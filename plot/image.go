@@ -0,0 +1,143 @@
+package plot
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var ErrEmptyDataframe = errors.New("dataframe without candles")
+
+const imagePadding = 10
+
+var (
+	imageBackground = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	imageBull       = color.RGBA{R: 38, G: 166, B: 154, A: 255}
+	imageBear       = color.RGBA{R: 239, G: 83, B: 80, A: 255}
+	imageOverlays   = []color.RGBA{
+		{R: 33, G: 150, B: 243, A: 255},
+		{R: 255, G: 152, B: 0, A: 255},
+		{R: 156, G: 39, B: 176, A: 255},
+		{R: 96, G: 125, B: 139, A: 255},
+	}
+)
+
+// RenderImage renders the candles of the dataframe as a PNG image of the given size, with the series of the
+// metadata in the overlays drawn as lines over the candles, e.g. a moving average. Missing overlays and NaN
+// values are skipped. The image has no axes or labels, it is a snapshot for notifications.
+//
+// Chart is rendered by the browser with plotly (see assets/chart.js), so there is no image of it in the server
+// to reuse: RenderImage is a minimal renderer for the clients without a browser, e.g. Telegram, and it does not
+// replace Chart.
+func RenderImage(df model.Dataframe, width, height int, overlays ...string) ([]byte, error) {
+	size := len(df.Close)
+	if size == 0 {
+		return nil, ErrEmptyDataframe
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for i := 0; i < size; i++ {
+		low, high = math.Min(low, df.Low[i]), math.Max(high, df.High[i])
+	}
+	for _, name := range overlays {
+		for _, value := range df.Metadata[name] {
+			if isValid(value) {
+				low, high = math.Min(low, value), math.Max(high, value)
+			}
+		}
+	}
+	if high <= low {
+		high = low + 1
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: imageBackground}, image.Point{}, draw.Src)
+
+	plotHeight := float64(height - 2*imagePadding)
+	slot := float64(width-2*imagePadding) / float64(size)
+	x := func(i int) int {
+		return imagePadding + int(slot*(float64(i)+0.5))
+	}
+	y := func(price float64) int {
+		return imagePadding + int((high-price)/(high-low)*plotHeight)
+	}
+
+	body := int(slot * 0.35)
+	for i := 0; i < size; i++ {
+		candleColor := imageBull
+		if df.Close[i] < df.Open[i] {
+			candleColor = imageBear
+		}
+
+		drawLine(img, x(i), y(df.High[i]), x(i), y(df.Low[i]), candleColor)
+		top, bottom := y(math.Max(df.Open[i], df.Close[i])), y(math.Min(df.Open[i], df.Close[i]))
+		draw.Draw(img, image.Rect(x(i)-body, top, x(i)+body+1, bottom+1), &image.Uniform{C: candleColor},
+			image.Point{}, draw.Src)
+	}
+
+	for index, name := range overlays {
+		values := df.Metadata[name]
+		overlayColor := imageOverlays[index%len(imageOverlays)]
+		// the overlay is aligned by the last values, the series can be shorter than the candles
+		offset := size - len(values)
+		for i := 1; i < len(values); i++ {
+			if i+offset < 1 || !isValid(values[i-1]) || !isValid(values[i]) {
+				continue
+			}
+			drawLine(img, x(i+offset-1), y(values[i-1]), x(i+offset), y(values[i]), overlayColor)
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func isValid(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// drawLine draws a line between two points with the Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, lineColor color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for {
+		img.Set(x0, y0, lineColor)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,52 @@
+package plot
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderImage(t *testing.T) {
+	df := model.Dataframe{
+		Pair: "BTCUSDT",
+		OHLC: model.OHLC{
+			Open:  []float64{10, 12, 11, 13},
+			Close: []float64{12, 11, 13, 14},
+			High:  []float64{13, 13, 14, 15},
+			Low:   []float64{9, 10, 10, 12},
+		},
+		Metadata: map[string]model.Series[float64]{
+			"sma": {math.NaN(), 11.5, 12, 13.5},
+		},
+	}
+
+	content, err := RenderImage(df, 200, 100, "sma", "unknown")
+	require.NoError(t, err)
+	require.NotEmpty(t, content)
+
+	img, err := png.Decode(bytes.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, 200, img.Bounds().Dx())
+	require.Equal(t, 100, img.Bounds().Dy())
+
+	colors := make(map[color.RGBA]bool)
+	for x := 0; x < 200; x++ {
+		for y := 0; y < 100; y++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			colors[color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)}] = true
+		}
+	}
+	require.True(t, colors[imageBackground])
+	require.True(t, colors[imageBull])
+	require.True(t, colors[imageBear])
+	require.True(t, colors[imageOverlays[0]])
+
+	_, err = RenderImage(model.Dataframe{}, 200, 100)
+	require.ErrorIs(t, err, ErrEmptyDataframe)
+}
@@ -0,0 +1,128 @@
+package ninjabot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+var ErrWarmupGap = errors.New("gap in warmup candles")
+
+// WarmupSource defines where the warmup candles of a live bot are read at startup
+type WarmupSource string
+
+const (
+	// WarmupFromExchange downloads the warmup candles from the exchange, slow but current (default)
+	WarmupFromExchange WarmupSource = "exchange"
+	// WarmupFromStorage reads the warmup candles from the storage, fast but possibly stale
+	WarmupFromStorage WarmupSource = "storage"
+	// WarmupFromStorageTopUp reads the warmup candles from the storage and downloads only the candles after
+	// the last stored one, which are also stored for the next startup
+	WarmupFromStorageTopUp WarmupSource = "storage-topup"
+)
+
+// warmupCandles returns the candles of the warmup period of the pair from the warmup source. The candles read
+// from the storage must be consecutive, otherwise ErrWarmupGap is returned.
+func (n *NinjaBot) warmupCandles(ctx context.Context, pair string) ([]model.Candle, error) {
+	timeframe, warmup := n.strategy.Timeframe(), n.strategy.WarmupPeriod()
+	if n.warmupSource == "" || n.warmupSource == WarmupFromExchange {
+		return n.exchange.CandlesByLimit(ctx, pair, timeframe, warmup)
+	}
+
+	candleStorage, ok := n.storage.(storage.CandleStorage)
+	if !ok {
+		return nil, fmt.Errorf("warmup from %s: candles not supported by the storage", n.warmupSource)
+	}
+
+	duration, err := str2duration.ParseDuration(timeframe)
+	if err != nil {
+		return nil, err
+	}
+
+	// stored candles older than twice the warmup period are not useful even for the top-up
+	now := time.Now()
+	candles, err := candleStorage.Candles(pair, timeframe, now.Add(-2*time.Duration(warmup)*duration), now)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.warmupSource == WarmupFromStorageTopUp {
+		limit := warmup
+		if len(candles) > 0 {
+			// the last stored candle is downloaded again, it may be outdated
+			limit = int(now.Sub(candles[len(candles)-1].Time)/duration) + 1
+			if limit > warmup {
+				limit = warmup
+			}
+		}
+
+		downloaded, err := n.exchange.CandlesByLimit(ctx, pair, timeframe, limit)
+		if err != nil {
+			return nil, err
+		}
+		if complete := completeCandles(downloaded); len(complete) > 0 {
+			if err := candleStorage.CreateCandles(timeframe, complete...); err != nil {
+				return nil, err
+			}
+		}
+		candles = mergeWarmupCandles(candles, downloaded)
+	} else if len(candles) > 0 && now.Sub(candles[len(candles)-1].Time) > 2*duration {
+		n.logger.Warn("Warmup candles from storage are stale", "pair", pair, "last", candles[len(candles)-1].Time)
+	}
+
+	if len(candles) > warmup {
+		candles = candles[len(candles)-warmup:]
+	}
+
+	if err := checkWarmupGaps(candles, duration); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", pair, timeframe, err)
+	}
+	return candles, nil
+}
+
+// mergeWarmupCandles merges the stored and downloaded candles, sorted by time. The downloaded candle replaces the
+// stored candle with the same time.
+func mergeWarmupCandles(stored, downloaded []model.Candle) []model.Candle {
+	byTime := make(map[time.Time]model.Candle, len(stored)+len(downloaded))
+	for _, candles := range [][]model.Candle{stored, downloaded} {
+		for _, candle := range candles {
+			byTime[candle.Time.UTC()] = candle
+		}
+	}
+
+	merged := make([]model.Candle, 0, len(byTime))
+	for _, candle := range byTime {
+		merged = append(merged, candle)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Time.Before(merged[j].Time)
+	})
+	return merged
+}
+
+// checkWarmupGaps returns ErrWarmupGap when two candles are not consecutive in the timeframe duration
+func checkWarmupGaps(candles []model.Candle, duration time.Duration) error {
+	for i := 1; i < len(candles); i++ {
+		if candles[i].Time.Sub(candles[i-1].Time) != duration {
+			return fmt.Errorf("%w: between %s and %s", ErrWarmupGap, candles[i-1].Time, candles[i].Time)
+		}
+	}
+	return nil
+}
+
+func completeCandles(candles []model.Candle) []model.Candle {
+	complete := make([]model.Candle, 0, len(candles))
+	for _, candle := range candles {
+		if candle.Complete {
+			complete = append(complete, candle)
+		}
+	}
+	return complete
+}
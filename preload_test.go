@@ -0,0 +1,126 @@
+package ninjabot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// warmupExchange returns the last candles of the history, recording the limits requested
+type warmupExchange struct {
+	service.Exchange
+	history []model.Candle
+	limits  []int
+}
+
+func (e *warmupExchange) CandlesByLimit(_ context.Context, _, _ string, limit int) ([]model.Candle, error) {
+	e.limits = append(e.limits, limit)
+	if limit > len(e.history) {
+		limit = len(e.history)
+	}
+	return e.history[len(e.history)-limit:], nil
+}
+
+func TestNinjaBot_WarmupCandles(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Hour)
+	candleAt := func(hoursAgo int, price float64) model.Candle {
+		return model.Candle{Pair: "BTCUSDT", Time: now.Add(-time.Duration(hoursAgo) * time.Hour), Close: price,
+			Complete: hoursAgo > 0}
+	}
+	closes := func(candles []model.Candle) []float64 {
+		values := make([]float64, 0, len(candles))
+		for _, candle := range candles {
+			values = append(values, candle.Close)
+		}
+		return values
+	}
+
+	// the warmup strategy has a 1h timeframe and 3 candles of warmup
+	setup := func(source WarmupSource, stored ...model.Candle) (*NinjaBot, *warmupExchange) {
+		repository, err := storage.FromMemory()
+		require.NoError(t, err)
+		if len(stored) > 0 {
+			require.NoError(t, repository.(storage.CandleStorage).CreateCandles("1h", stored...))
+		}
+
+		exch := &warmupExchange{history: []model.Candle{candleAt(3, 30), candleAt(2, 20), candleAt(1, 10),
+			candleAt(0, 1)}}
+		return &NinjaBot{
+			strategy:     warmupStrategy{},
+			exchange:     exch,
+			storage:      repository,
+			logger:       log.Default(),
+			warmupSource: source,
+		}, exch
+	}
+
+	t.Run("exchange", func(t *testing.T) {
+		bot, exch := setup(WarmupFromExchange, candleAt(2, 200), candleAt(1, 100))
+		candles, err := bot.warmupCandles(context.Background(), "BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, []float64{20, 10, 1}, closes(candles))
+		require.Equal(t, []int{3}, exch.limits)
+	})
+
+	t.Run("storage", func(t *testing.T) {
+		bot, exch := setup(WarmupFromStorage, candleAt(4, 400), candleAt(3, 300), candleAt(2, 200),
+			candleAt(1, 100))
+		candles, err := bot.warmupCandles(context.Background(), "BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, []float64{300, 200, 100}, closes(candles))
+		require.Empty(t, exch.limits)
+	})
+
+	t.Run("storage with gap", func(t *testing.T) {
+		bot, _ := setup(WarmupFromStorage, candleAt(4, 400), candleAt(2, 200), candleAt(1, 100))
+		_, err := bot.warmupCandles(context.Background(), "BTCUSDT")
+		require.ErrorIs(t, err, ErrWarmupGap)
+	})
+
+	t.Run("storage top-up", func(t *testing.T) {
+		bot, exch := setup(WarmupFromStorageTopUp, candleAt(4, 400), candleAt(3, 300), candleAt(2, 200))
+		candles, err := bot.warmupCandles(context.Background(), "BTCUSDT")
+		require.NoError(t, err)
+
+		// the last stored candle and the candles after it are downloaded, replacing the stored one
+		require.Equal(t, []int{3}, exch.limits)
+		require.Equal(t, []float64{20, 10, 1}, closes(candles))
+
+		// the complete candles downloaded are stored
+		stored, err := bot.storage.(storage.CandleStorage).Candles("BTCUSDT", "1h", now.Add(-5*time.Hour), now)
+		require.NoError(t, err)
+		require.Equal(t, []float64{400, 300, 20, 10}, closes(stored))
+	})
+
+	t.Run("top-up without stored candles", func(t *testing.T) {
+		bot, exch := setup(WarmupFromStorageTopUp)
+		candles, err := bot.warmupCandles(context.Background(), "BTCUSDT")
+		require.NoError(t, err)
+		require.Equal(t, []int{3}, exch.limits)
+		require.Equal(t, []float64{20, 10, 1}, closes(candles))
+	})
+}
+
+func TestMergeWarmupCandles(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candleAt := func(i int, price float64) model.Candle {
+		return model.Candle{Time: start.Add(time.Duration(i) * time.Hour), Close: price}
+	}
+
+	merged := mergeWarmupCandles(
+		[]model.Candle{candleAt(0, 1), candleAt(1, 2), candleAt(2, 3)},
+		[]model.Candle{candleAt(2, 30), candleAt(3, 40)},
+	)
+	require.Equal(t, []model.Candle{candleAt(0, 1), candleAt(1, 2), candleAt(2, 30), candleAt(3, 40)}, merged)
+	require.NoError(t, checkWarmupGaps(merged, time.Hour))
+
+	err := checkWarmupGaps([]model.Candle{candleAt(0, 1), candleAt(2, 3)}, time.Hour)
+	require.ErrorIs(t, err, ErrWarmupGap)
+}
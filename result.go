@@ -0,0 +1,381 @@
+package ninjabot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+)
+
+// PairResult summarizes the trades of a pair
+type PairResult struct {
+	Pair          string  `json:"pair"`
+	Trades        int     `json:"trades"`
+	Win           int     `json:"win"`
+	Loss          int     `json:"loss"`
+	WinPercentage float64 `json:"win_percentage"`
+	Payoff        float64 `json:"payoff"`
+	ProfitFactor  float64 `json:"profit_factor"`
+	SQN           float64 `json:"sqn"`
+	Profit        float64 `json:"profit"`
+	Volume        float64 `json:"volume"`
+}
+
+// PositionResult is a position open at the end of the execution, see order.Position
+type PositionResult struct {
+	Pair     string         `json:"pair"`
+	Side     model.SideType `json:"side"`
+	Quantity float64        `json:"quantity"`
+	AvgPrice float64        `json:"avg_price"`
+}
+
+// TradeResult is a trade closed in the execution, see order.Result
+type TradeResult struct {
+	Pair          string         `json:"pair"`
+	Side          model.SideType `json:"side"`
+	ProfitPercent float64        `json:"profit_percent"`
+	ProfitValue   float64        `json:"profit_value"`
+	EntryTime     time.Time      `json:"entry_time"`
+	ExitTime      time.Time      `json:"exit_time"`
+}
+
+// EquityPoint is the value of the wallet at a point of time
+type EquityPoint = exchange.AssetValue
+
+// Result is the outcome of a bot execution, e.g. a backtest, with metrics by pair,
+// equity curve (available with paper wallet), the closed trades and the filled orders
+type Result struct {
+	Metrics     []PairResult          `json:"metrics"`
+	MaxDrawdown float64               `json:"max_drawdown"`
+	Equity      []exchange.AssetValue `json:"equity"`
+	Positions   []PositionResult      `json:"positions"`
+	Closed      []TradeResult         `json:"closed"`
+	Trades      []model.Order         `json:"trades"`
+}
+
+// RealizedPnL returns the profit of the closed trades of all pairs, in the quote asset
+func (r Result) RealizedPnL() float64 {
+	var pnl float64
+	for _, metrics := range r.Metrics {
+		pnl += metrics.Profit
+	}
+	return pnl
+}
+
+// Total returns the combined metrics of all pairs of the portfolio, with the pair TOTAL. The counts, profit and
+// volume are summed, the payoff, profit factor and SQN are computed from the closed trades of all pairs. Results
+// without closed trades, e.g. saved by previous versions, have averages of the pairs weighted by their trades.
+func (r Result) Total() PairResult {
+	total := PairResult{Pair: "TOTAL"}
+	var payoff, profitFactor, sqn float64
+	for _, metrics := range r.Metrics {
+		total.Trades += metrics.Trades
+		total.Win += metrics.Win
+		total.Loss += metrics.Loss
+		total.Profit += metrics.Profit
+		total.Volume += metrics.Volume
+		payoff += metrics.Payoff * float64(metrics.Trades)
+		profitFactor += metrics.ProfitFactor * float64(metrics.Trades)
+		sqn += metrics.SQN * float64(metrics.Trades)
+	}
+
+	if total.Trades > 0 {
+		total.WinPercentage = float64(total.Win) / float64(total.Trades) * 100
+		total.Payoff = payoff / float64(total.Trades)
+		total.ProfitFactor = profitFactor / float64(total.Trades)
+		total.SQN = sqn / float64(total.Trades)
+	}
+
+	if len(r.Closed) > 0 {
+		total.Payoff, total.ProfitFactor, total.SQN = tradeMetrics(r.Closed)
+	}
+	return total
+}
+
+// tradeMetrics returns the payoff, profit factor and SQN of the trades, with the formulas of the pair
+// metrics, see order.Controller Results. Undefined metrics, e.g. without losses, are zero.
+func tradeMetrics(trades []TradeResult) (payoff, profitFactor, sqn float64) {
+	var win, loss, profit float64
+	var wins, losses int
+	for _, trade := range trades {
+		if trade.ProfitPercent >= 0 {
+			win += trade.ProfitPercent
+			wins++
+		} else {
+			loss += trade.ProfitPercent
+			losses++
+		}
+		profit += trade.ProfitValue
+	}
+
+	if wins > 0 && losses > 0 && loss != 0 {
+		payoff = (win / float64(wins)) / math.Abs(loss/float64(losses))
+	}
+	if losses > 0 && loss != 0 {
+		profitFactor = win / math.Abs(loss)
+	}
+
+	count := float64(len(trades))
+	mean := profit / count
+	var variance float64
+	for _, trade := range trades {
+		variance += (trade.ProfitValue - mean) * (trade.ProfitValue - mean)
+	}
+	if std := math.Sqrt(variance / count); std > 0 {
+		sqn = math.Sqrt(count) * mean / std
+	}
+	return payoff, profitFactor, sqn
+}
+
+// UnrealizedPnL returns the profit of the open positions if closed at the given prices by pair, in the quote
+// asset. Positions without price are not included.
+func (r Result) UnrealizedPnL(prices map[string]float64) float64 {
+	var pnl float64
+	for _, position := range r.Positions {
+		price, ok := prices[position.Pair]
+		if !ok {
+			continue
+		}
+
+		pnl += order.Position{
+			Side:     position.Side,
+			AvgPrice: position.AvgPrice,
+			Quantity: position.Quantity,
+		}.UnrealizedPnL(price)
+	}
+	return pnl
+}
+
+// EquityResampled downsamples the equity curve to one point per interval, e.g. to plot long backtests of
+// small timeframes. Intervals are aligned to the zero time (see time.Time.Truncate) and each point has the
+// start time of the interval and the last value in it. Intervals without values carry forward the previous
+// value. A zero or negative interval returns the original curve.
+func (r Result) EquityResampled(interval time.Duration) []EquityPoint {
+	if interval <= 0 || len(r.Equity) == 0 {
+		return r.Equity
+	}
+
+	points := make([]EquityPoint, 0)
+	for _, value := range r.Equity {
+		bucket := value.Time.Truncate(interval)
+		if len(points) > 0 && points[len(points)-1].Time.Equal(bucket) {
+			points[len(points)-1].Value = value.Value
+			continue
+		}
+
+		if len(points) > 0 {
+			last := points[len(points)-1]
+			for t := last.Time.Add(interval); t.Before(bucket); t = t.Add(interval) {
+				points = append(points, EquityPoint{Time: t, Value: last.Value})
+			}
+		}
+		points = append(points, EquityPoint{Time: bucket, Value: value.Value})
+	}
+	return points
+}
+
+// NetProfit returns the change of the equity from the first to the last point of the curve, fees and
+// slippage included. Without equity curve, it is the sum of the profit of all pairs.
+func (r Result) NetProfit() float64 {
+	if len(r.Equity) > 0 {
+		return r.Equity[len(r.Equity)-1].Value - r.Equity[0].Value
+	}
+
+	var profit float64
+	for _, metrics := range r.Metrics {
+		profit += metrics.Profit
+	}
+	return profit
+}
+
+// Sharpe returns the annualized Sharpe ratio of the equity curve returns, without risk-free rate.
+// The returns are annualized by the average interval between the points of the curve. It is zero
+// with less than three points or constant equity.
+func (r Result) Sharpe() float64 {
+	if len(r.Equity) < 3 {
+		return 0
+	}
+
+	returns := make([]float64, 0, len(r.Equity)-1)
+	for i := 1; i < len(r.Equity); i++ {
+		if r.Equity[i-1].Value == 0 {
+			continue
+		}
+		returns = append(returns, r.Equity[i].Value/r.Equity[i-1].Value-1)
+	}
+
+	interval := r.Equity[len(r.Equity)-1].Time.Sub(r.Equity[0].Time) / time.Duration(len(r.Equity)-1)
+	if len(returns) < 2 || interval <= 0 {
+		return 0
+	}
+
+	var mean, variance float64
+	for _, value := range returns {
+		mean += value
+	}
+	mean /= float64(len(returns))
+	for _, value := range returns {
+		variance += (value - mean) * (value - mean)
+	}
+	std := math.Sqrt(variance / float64(len(returns)-1))
+	if std == 0 {
+		return 0
+	}
+
+	periodsPerYear := float64(365*24*time.Hour) / float64(interval)
+	return mean / std * math.Sqrt(periodsPerYear)
+}
+
+// Save writes the result as JSON to the given path, trades are written one by one
+func (r Result) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	write := func(key string, value interface{}) error {
+		content, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("result: failed to encode %s: %w", key, err)
+		}
+		_, err = fmt.Fprintf(writer, "%q:%s,", key, content)
+		return err
+	}
+
+	if _, err := writer.WriteString("{"); err != nil {
+		return err
+	}
+
+	if err := write("metrics", r.Metrics); err != nil {
+		return err
+	}
+
+	if err := write("max_drawdown", r.MaxDrawdown); err != nil {
+		return err
+	}
+
+	if err := write("equity", r.Equity); err != nil {
+		return err
+	}
+
+	if err := write("positions", r.Positions); err != nil {
+		return err
+	}
+
+	if err := write("closed", r.Closed); err != nil {
+		return err
+	}
+
+	if _, err := writer.WriteString(`"trades":[`); err != nil {
+		return err
+	}
+
+	for i, trade := range r.Trades {
+		if i > 0 {
+			if err := writer.WriteByte(','); err != nil {
+				return err
+			}
+		}
+
+		content, err := json.Marshal(trade)
+		if err != nil {
+			return fmt.Errorf("result: failed to encode trade %d: %w", trade.ID, err)
+		}
+
+		if _, err := writer.Write(content); err != nil {
+			return err
+		}
+	}
+
+	if _, err := writer.WriteString("]}"); err != nil {
+		return err
+	}
+
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// LoadResult reads a result saved with Result.Save, trades are decoded one by one
+func LoadResult(path string) (Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+
+	var result Result
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	if err := expectDelim(decoder, '{'); err != nil {
+		return Result{}, err
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return Result{}, err
+		}
+
+		switch token {
+		case "metrics":
+			err = decoder.Decode(&result.Metrics)
+		case "max_drawdown":
+			err = decoder.Decode(&result.MaxDrawdown)
+		case "equity":
+			err = decoder.Decode(&result.Equity)
+		case "positions":
+			err = decoder.Decode(&result.Positions)
+		case "closed":
+			err = decoder.Decode(&result.Closed)
+		case "trades":
+			err = decodeTrades(decoder, &result)
+		default:
+			// ignore unknown fields
+			var value json.RawMessage
+			err = decoder.Decode(&value)
+		}
+
+		if err != nil {
+			return Result{}, fmt.Errorf("result: invalid field %v: %w", token, err)
+		}
+	}
+
+	return result, expectDelim(decoder, '}')
+}
+
+func decodeTrades(decoder *json.Decoder, result *Result) error {
+	if err := expectDelim(decoder, '['); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		var trade model.Order
+		if err := decoder.Decode(&trade); err != nil {
+			return err
+		}
+		result.Trades = append(result.Trades, trade)
+	}
+
+	return expectDelim(decoder, ']')
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("result: expected %s, got %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package ninjabot
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestResult_SaveLoad(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	stop := 38000.0
+	groupID := int64(1)
+
+	result := Result{
+		Metrics: []PairResult{
+			{
+				Pair:          "BTCUSDT",
+				Trades:        3,
+				Win:           2,
+				Loss:          1,
+				WinPercentage: 66.67,
+				Payoff:        1.5,
+				ProfitFactor:  3,
+				SQN:           1.2,
+				Profit:        250.5,
+				Volume:        10000,
+			},
+		},
+		MaxDrawdown: -0.12,
+		Equity: []exchange.AssetValue{
+			{Time: start, Value: 10000},
+			{Time: start.Add(time.Hour), Value: 10250.5},
+		},
+		Positions: []PositionResult{
+			{Pair: "ETHUSDT", Side: model.SideTypeSell, Quantity: 2, AvgPrice: 2500},
+		},
+		Closed: []TradeResult{
+			{Pair: "BTCUSDT", Side: model.SideTypeBuy, ProfitPercent: 0.025, ProfitValue: 500, EntryTime: start,
+				ExitTime: start.Add(time.Hour)},
+		},
+		Trades: []model.Order{
+			{
+				ID:         1,
+				ExchangeID: 10,
+				Pair:       "BTCUSDT",
+				Side:       model.SideTypeBuy,
+				Type:       model.OrderTypeMarket,
+				Status:     model.OrderStatusTypeFilled,
+				Price:      40000,
+				Quantity:   0.5,
+				CreatedAt:  start,
+				UpdatedAt:  start,
+			},
+			{
+				ID:          2,
+				ExchangeID:  11,
+				Pair:        "BTCUSDT",
+				Side:        model.SideTypeSell,
+				Type:        model.OrderTypeLimitMaker,
+				Status:      model.OrderStatusTypeFilled,
+				Price:       41000,
+				Quantity:    0.5,
+				ReduceOnly:  true,
+				CreatedAt:   start.Add(time.Hour),
+				UpdatedAt:   start.Add(time.Hour),
+				Stop:        &stop,
+				GroupID:     &groupID,
+				RefPrice:    40000,
+				Profit:      0.025,
+				ProfitValue: 500,
+			},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "result.json")
+	require.NoError(t, result.Save(path))
+
+	loaded, err := LoadResult(path)
+	require.NoError(t, err)
+	require.Equal(t, result, loaded)
+
+	t.Run("unknown fields", func(t *testing.T) {
+		content := `{"version":2,"max_drawdown":-0.5,"extra":{"a":[1,2]},"trades":[{"id":1,"pair":"ETHUSDT"}]}`
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+
+		loaded, err := LoadResult(path)
+		require.NoError(t, err)
+		require.Equal(t, -0.5, loaded.MaxDrawdown)
+		require.Len(t, loaded.Trades, 1)
+		require.Equal(t, "ETHUSDT", loaded.Trades[0].Pair)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(`[]`), 0600))
+		_, err := LoadResult(path)
+		require.Error(t, err)
+
+		_, err = LoadResult(filepath.Join(t.TempDir(), "missing.json"))
+		require.Error(t, err)
+	})
+}
+
+func TestResult_PnL(t *testing.T) {
+	result := Result{
+		Metrics: []PairResult{
+			{Pair: "BTCUSDT", Profit: 250},
+			{Pair: "ETHUSDT", Profit: -50},
+		},
+		Positions: []PositionResult{
+			{Pair: "BTCUSDT", Side: model.SideTypeBuy, Quantity: 0.5, AvgPrice: 40000},
+			{Pair: "ETHUSDT", Side: model.SideTypeSell, Quantity: 2, AvgPrice: 2500},
+		},
+	}
+
+	require.Equal(t, 200.0, result.RealizedPnL())
+	require.Equal(t, 500.0-200.0, result.UnrealizedPnL(map[string]float64{"BTCUSDT": 41000, "ETHUSDT": 2600}))
+
+	// positions without price are not included
+	require.Equal(t, 500.0, result.UnrealizedPnL(map[string]float64{"BTCUSDT": 41000}))
+}
+
+func TestResult_Total(t *testing.T) {
+	result := Result{
+		Metrics: []PairResult{
+			{Pair: "BTCUSDT", Trades: 3, Win: 2, Loss: 1, Payoff: 2, ProfitFactor: 4, SQN: 1, Profit: 250,
+				Volume: 1000},
+			{Pair: "ETHUSDT", Trades: 1, Loss: 1, Profit: -50, Volume: 500},
+		},
+	}
+
+	require.Equal(t, PairResult{
+		Pair:          "TOTAL",
+		Trades:        4,
+		Win:           2,
+		Loss:          2,
+		WinPercentage: 50,
+		Payoff:        1.5,
+		ProfitFactor:  3,
+		SQN:           0.75,
+		Profit:        200,
+		Volume:        1500,
+	}, result.Total())
+	require.Equal(t, PairResult{Pair: "TOTAL"}, Result{}.Total())
+
+	t.Run("closed trades", func(t *testing.T) {
+		result.Closed = []TradeResult{
+			{Pair: "BTCUSDT", ProfitPercent: 0.2, ProfitValue: 200},
+			{Pair: "BTCUSDT", ProfitPercent: 0.1, ProfitValue: 100},
+			{Pair: "BTCUSDT", ProfitPercent: -0.05, ProfitValue: -50},
+			{Pair: "ETHUSDT", ProfitPercent: -0.05, ProfitValue: -50},
+		}
+
+		total := result.Total()
+		require.InDelta(t, 0.15/0.05, total.Payoff, 1e-9)
+		require.InDelta(t, 0.3/0.1, total.ProfitFactor, 1e-9)
+		// mean 50, standard deviation 106.07
+		require.InDelta(t, 2*50/math.Sqrt(11250), total.SQN, 1e-9)
+		require.Equal(t, 200.0, total.Profit)
+	})
+
+	t.Run("without losses", func(t *testing.T) {
+		total := Result{
+			Metrics: []PairResult{{Pair: "BTCUSDT", Trades: 2, Win: 2, Profit: 100}},
+			Closed: []TradeResult{
+				{Pair: "BTCUSDT", ProfitPercent: 0.1, ProfitValue: 50},
+				{Pair: "BTCUSDT", ProfitPercent: 0.1, ProfitValue: 50},
+			},
+		}.Total()
+		require.Equal(t, 0.0, total.Payoff)
+		require.Equal(t, 0.0, total.ProfitFactor)
+		require.Equal(t, 0.0, total.SQN)
+	})
+}
+
+func TestResult_EquityResampled(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := Result{}
+	// one point per minute during two hours, skipping the third hour
+	for i := 0; i < 120; i++ {
+		result.Equity = append(result.Equity, EquityPoint{Time: start.Add(time.Duration(i) * time.Minute),
+			Value: float64(i)})
+	}
+	result.Equity = append(result.Equity, EquityPoint{Time: start.Add(3*time.Hour + 30*time.Minute), Value: 500})
+
+	require.Equal(t, []EquityPoint{
+		{Time: start, Value: 59},
+		{Time: start.Add(time.Hour), Value: 119},
+		{Time: start.Add(2 * time.Hour), Value: 119},
+		{Time: start.Add(3 * time.Hour), Value: 500},
+	}, result.EquityResampled(time.Hour))
+
+	points := result.EquityResampled(15 * time.Minute)
+	require.Len(t, points, 15)
+	require.Equal(t, EquityPoint{Time: start.Add(15 * time.Minute), Value: 29}, points[1])
+
+	require.Equal(t, result.Equity, result.EquityResampled(0))
+	require.Empty(t, Result{}.EquityResampled(time.Hour))
+}
+
+func TestCombineResults(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time {
+		return start.Add(time.Duration(hours) * time.Hour)
+	}
+
+	// the second run starts one hour later
+	btc := Result{
+		Metrics: []PairResult{{Pair: "BTCUSDT", Trades: 1, Win: 1, Profit: 10, Volume: 200}},
+		Equity:  []EquityPoint{{Time: at(0), Value: 100}, {Time: at(1), Value: 110}, {Time: at(2), Value: 99}},
+		Trades:  []model.Order{{Pair: "BTCUSDT", Quantity: 1, Fee: 1, UpdatedAt: at(2)}},
+	}
+	eth := Result{
+		Metrics:   []PairResult{{Pair: "ETHUSDT", Trades: 1, Loss: 1, Profit: -20, Volume: 400}},
+		Equity:    []EquityPoint{{Time: at(1), Value: 200}, {Time: at(2), Value: 180}, {Time: at(3), Value: 230}},
+		Positions: []PositionResult{{Pair: "ETHUSDT", Side: model.SideTypeBuy, Quantity: 2, AvgPrice: 100}},
+		Trades:    []model.Order{{Pair: "ETHUSDT", Quantity: 2, Fee: 2, UpdatedAt: at(1)}},
+	}
+
+	combined, err := CombineResults([]Result{btc, eth}, nil)
+	require.NoError(t, err)
+
+	// equity aligned with forward-fill, the first value of the second run before it starts
+	require.Equal(t, []EquityPoint{
+		{Time: at(0), Value: 300},
+		{Time: at(1), Value: 310},
+		{Time: at(2), Value: 279},
+		{Time: at(3), Value: 329},
+	}, combined.Equity)
+	require.InDelta(t, -31.0/310, combined.MaxDrawdown, 1e-9)
+	// hourly returns 10/300, -31/310 and 50/279: mean 0.037515 / std 0.139653 * sqrt(24 * 365)
+	require.InDelta(t, 25.1424, combined.Sharpe(), 1e-4)
+	require.Equal(t, 29.0, combined.NetProfit())
+
+	require.Len(t, combined.Metrics, 2)
+	require.Equal(t, -10.0, combined.RealizedPnL())
+	require.Len(t, combined.Trades, 2)
+	require.Equal(t, "ETHUSDT", combined.Trades[0].Pair)
+	require.Equal(t, 3.0, combined.Fees())
+
+	t.Run("weights", func(t *testing.T) {
+		combined, err := CombineResults([]Result{btc, eth}, []float64{2, 0.5})
+		require.NoError(t, err)
+		require.Equal(t, []float64{300, 320, 288, 313}, []float64{combined.Equity[0].Value,
+			combined.Equity[1].Value, combined.Equity[2].Value, combined.Equity[3].Value})
+		require.InDelta(t, -0.1, combined.MaxDrawdown, 1e-9)
+		require.Equal(t, 10.0, combined.RealizedPnL())
+		require.Equal(t, 3.0, combined.Fees())
+		require.Equal(t, 1.0, combined.Positions[0].Quantity)
+	})
+
+	t.Run("invalid weights", func(t *testing.T) {
+		_, err := CombineResults([]Result{btc, eth}, []float64{1})
+		require.Error(t, err)
+	})
+}
@@ -34,13 +34,63 @@ type Broker interface {
 	Cancel(model.Order) error
 }
 
+// ReduceOnlyBroker is implemented by brokers that support reduce-only orders. The order quantity is
+// capped to the current position size and orders that would open or increase a position are rejected.
+type ReduceOnlyBroker interface {
+	CreateOrderLimitReduceOnly(side model.SideType, pair string, size float64, limit float64) (model.Order, error)
+	CreateOrderMarketReduceOnly(side model.SideType, pair string, size float64) (model.Order, error)
+}
+
+// DepthFeeder is implemented by exchanges that provide the order book of a pair, limited to the given
+// number of levels in each side
+type DepthFeeder interface {
+	OrderBook(ctx context.Context, pair string, limit int) (model.OrderBook, error)
+}
+
+// OrderSimulator is implemented by brokers that estimate the execution of an order without submitting it.
+// A zero price estimates a market order, otherwise a limit order at the given price.
+type OrderSimulator interface {
+	SimulateOrder(pair string, side model.SideType, quantity, price float64) (model.OrderPreview, error)
+}
+
+// OpenOrdersBroker is implemented by brokers that list the orders of a pair waiting for execution
+type OpenOrdersBroker interface {
+	OpenOrders(pair string) ([]model.Order, error)
+}
+
+// OrderHistoryBroker is implemented by brokers that list the recent orders of a pair, including the filled
+// and canceled ones, limited to the given number of orders
+type OrderHistoryBroker interface {
+	Orders(pair string, limit int) ([]model.Order, error)
+}
+
+// OrderAmender is implemented by brokers that change the price and quantity of an open limit order without
+// cancelling it. The amended order keeps the exchange id of the original order.
+type OrderAmender interface {
+	AmendOrder(order model.Order, price, quantity float64) (model.Order, error)
+}
+
 type Notifier interface {
 	Notify(string)
 	OnOrder(order model.Order)
 	OnError(err error)
 }
 
+// NotifierFlusher is implemented by notifiers that hold notifications, Flush delivers them before the bot
+// shuts down
+type NotifierFlusher interface {
+	Flush()
+}
+
+// NotifierCloser is implemented by notifiers with background workers, Close delivers the held notifications
+// and stops the workers when the bot shuts down
+type NotifierCloser interface {
+	Close()
+}
+
 type Telegram interface {
 	Notifier
 	Start()
+	// Send delivers a message and returns the delivery error
+	Send(text string) error
 }
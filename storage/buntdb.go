@@ -2,18 +2,63 @@ package storage
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/tidwall/buntdb"
 
 	"github.com/rodrigo-brito/ninjabot/model"
 )
 
+// equityPrefix is the key prefix of the equity snapshots, followed by the snapshot time, so the keys are
+// sorted by time
+const equityPrefix = "equity:"
+
+// statePrefix is the key prefix of the strategy states, followed by the state key
+const statePrefix = "state:"
+
+// candlePrefix is the key prefix of the candles, followed by the pair, timeframe and candle time
+const candlePrefix = "candle:"
+
+// candleFileSuffix is appended to the database file to name the file of the candles
+const candleFileSuffix = ".candles"
+
 type Bunt struct {
-	lastID int64
-	db     *buntdb.DB
+	lastID  int64
+	db      *buntdb.DB
+	candles *candleDB
+}
+
+// candleDB is the database of the candles, apart from the orders so the candles are not in the index of the
+// orders. It is opened on the first use, so the file is only created by the bots that store candles.
+type candleDB struct {
+	once sync.Once
+	path string
+	db   *buntdb.DB
+	err  error
+}
+
+func (c *candleDB) open() (*buntdb.DB, error) {
+	c.once.Do(func() {
+		c.db, c.err = buntdb.Open(c.path)
+	})
+	return c.db, c.err
+}
+
+// close closes the database when it is opened, the next uses fail with buntdb.ErrDatabaseClosed
+func (c *candleDB) close() error {
+	c.once.Do(func() {
+		c.err = buntdb.ErrDatabaseClosed
+	})
+	if c.db == nil {
+		return nil
+	}
+	return c.db.Close()
 }
 
 func FromMemory() (Storage, error) {
@@ -35,11 +80,25 @@ func newBunt(sourceFile string) (Storage, error) {
 		return nil, err
 	}
 
+	candlePath := sourceFile
+	if sourceFile != ":memory:" {
+		candlePath += candleFileSuffix
+	}
+
 	return &Bunt{
-		db: db,
+		db:      db,
+		candles: &candleDB{path: candlePath},
 	}, nil
 }
 
+// Close closes the database of the orders and the database of the candles
+func (b *Bunt) Close() error {
+	if err := b.candles.close(); err != nil {
+		return err
+	}
+	return b.db.Close()
+}
+
 func (b *Bunt) getID() int64 {
 	return atomic.AddInt64(&b.lastID, 1)
 }
@@ -74,7 +133,11 @@ func (b Bunt) UpdateOrder(order *model.Order) error {
 func (b Bunt) Orders(filters ...OrderFilter) ([]*model.Order, error) {
 	orders := make([]*model.Order, 0)
 	err := b.db.View(func(tx *buntdb.Tx) error {
-		err := tx.Ascend("update_index", func(_, value string) bool {
+		err := tx.Ascend("update_index", func(key, value string) bool {
+			if strings.HasPrefix(key, equityPrefix) || strings.HasPrefix(key, statePrefix) {
+				return true
+			}
+
 			var order model.Order
 			err := json.Unmarshal([]byte(value), &order)
 			if err != nil {
@@ -99,3 +162,200 @@ func (b Bunt) Orders(filters ...OrderFilter) ([]*model.Order, error) {
 	}
 	return orders, nil
 }
+
+func equityKey(t time.Time) string {
+	return fmt.Sprintf("%s%020d", equityPrefix, t.UnixNano())
+}
+
+// CreateEquitySnapshot stores a snapshot, replacing any snapshot with the same time
+func (b *Bunt) CreateEquitySnapshot(snapshot *model.EquitySnapshot) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		snapshot.ID = b.getID()
+		content, err := json.Marshal(snapshot)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = tx.Set(equityKey(snapshot.Time), string(content), nil)
+		return err
+	})
+}
+
+// EquitySnapshots returns the snapshots between start and end (inclusive), sorted by time
+func (b *Bunt) EquitySnapshots(start, end time.Time) ([]model.EquitySnapshot, error) {
+	snapshots := make([]model.EquitySnapshot, 0)
+	err := b.db.View(func(tx *buntdb.Tx) error {
+		return tx.AscendRange("", equityKey(start), equityKey(end.Add(time.Nanosecond)),
+			func(key, value string) bool {
+				if !strings.HasPrefix(key, equityPrefix) {
+					return false
+				}
+
+				var snapshot model.EquitySnapshot
+				if err := json.Unmarshal([]byte(value), &snapshot); err != nil {
+					log.Println(err)
+					return true
+				}
+
+				snapshots = append(snapshots, snapshot)
+				return true
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// DeleteEquitySnapshots removes the snapshots before the given time
+func (b *Bunt) DeleteEquitySnapshots(before time.Time) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		keys := make([]string, 0)
+		err := tx.AscendRange("", equityPrefix, equityKey(before), func(key, _ string) bool {
+			keys = append(keys, key)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if _, err := tx.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveStrategyState stores the state of a strategy, replacing the previous state of the key
+func (b *Bunt) SaveStrategyState(key string, state []byte) error {
+	return b.db.Update(func(tx *buntdb.Tx) error {
+		_, _, err := tx.Set(statePrefix+key, string(state), nil)
+		return err
+	})
+}
+
+// StrategyState returns the last state saved with the key, nil when there is no state
+func (b *Bunt) StrategyState(key string) ([]byte, error) {
+	var state []byte
+	err := b.db.View(func(tx *buntdb.Tx) error {
+		value, err := tx.Get(statePrefix + key)
+		if err == buntdb.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		state = []byte(value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func candleKey(pair, timeframe string, t time.Time) string {
+	return fmt.Sprintf("%s%s:%s:%020d", candlePrefix, pair, timeframe, t.UnixNano())
+}
+
+func setCandles(tx *buntdb.Tx, timeframe string, candles []model.Candle) error {
+	for _, candle := range candles {
+		content, err := json.Marshal(candle)
+		if err != nil {
+			return err
+		}
+
+		if _, _, err := tx.Set(candleKey(candle.Pair, timeframe, candle.Time), string(content), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func candles(tx *buntdb.Tx, pair, timeframe string, start, end time.Time) ([]model.Candle, error) {
+	candles := make([]model.Candle, 0)
+	var err error
+	iterErr := tx.AscendRange("", candleKey(pair, timeframe, start), candleKey(pair, timeframe,
+		end.Add(time.Nanosecond)), func(_, value string) bool {
+		var candle model.Candle
+		if err = json.Unmarshal([]byte(value), &candle); err != nil {
+			return false
+		}
+		candles = append(candles, candle)
+		return true
+	})
+	if iterErr != nil {
+		return nil, iterErr
+	}
+	return candles, err
+}
+
+// CreateCandles stores the candles of the timeframe, replacing the candles with the same pair and time. The
+// candles of a file storage are stored in a second file, with the candleFileSuffix.
+func (b *Bunt) CreateCandles(timeframe string, candles ...model.Candle) error {
+	db, err := b.candles.open()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *buntdb.Tx) error {
+		return setCandles(tx, timeframe, candles)
+	})
+}
+
+// Candles returns the candles of the pair between start and end (inclusive), sorted by time
+func (b *Bunt) Candles(pair, timeframe string, start, end time.Time) ([]model.Candle, error) {
+	db, err := b.candles.open()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []model.Candle
+	err = db.View(func(tx *buntdb.Tx) error {
+		var err error
+		result, err = candles(tx, pair, timeframe, start, end)
+		return err
+	})
+	return result, err
+}
+
+// Compact rolls up the candles of the pair in the from timeframe, between start and end, into candles of the
+// to timeframe, see CandleStorage
+func (b *Bunt) Compact(pair, from, to string, start, end time.Time, options ...CompactOption) error {
+	var config compactOptions
+	for _, option := range options {
+		option(&config)
+	}
+
+	db, err := b.candles.open()
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *buntdb.Tx) error {
+		fine, err := candles(tx, pair, from, start, end)
+		if err != nil {
+			return err
+		}
+
+		compacted, source, err := compactCandles(fine, from, to)
+		if err != nil {
+			return err
+		}
+
+		if err := setCandles(tx, to, compacted); err != nil {
+			return err
+		}
+
+		if config.deleteSource {
+			for _, candle := range source {
+				if _, err := tx.Delete(candleKey(pair, from, candle.Time)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -3,8 +3,10 @@ package storage
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/tidwall/buntdb"
 )
 
 func TestFromFile(t *testing.T) {
@@ -16,6 +18,16 @@ func TestFromFile(t *testing.T) {
 	db, err := FromFile(file.Name())
 	require.NoError(t, err)
 	require.NotNil(t, db)
+
+	// the candles are stored in a second file
+	defer os.RemoveAll(file.Name() + candleFileSuffix)
+	require.NoFileExists(t, file.Name()+candleFileSuffix)
+	require.NoError(t, db.(CandleStorage).CreateCandles("1m", minuteCandles(time.Now(), 1)...))
+	require.FileExists(t, file.Name()+candleFileSuffix)
+
+	require.NoError(t, db.(*Bunt).Close())
+	_, err = db.(CandleStorage).Candles("BTCUSDT", "1m", time.Now(), time.Now())
+	require.ErrorIs(t, err, buntdb.ErrDatabaseClosed)
 }
 
 func TestNewBunt(t *testing.T) {
@@ -23,4 +35,11 @@ func TestNewBunt(t *testing.T) {
 	require.NoError(t, err)
 
 	storageUseCase(repo, t)
+	equityStorageUseCase(repo.(EquityStorage), t)
+	stateStorageUseCase(repo.(StateStorage), t)
+	candleStorageUseCase(repo.(CandleStorage), t)
+
+	orders, err := repo.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
 }
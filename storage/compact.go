@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/xhit/go-str2duration/v2"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// CandleStorage is implemented by storages that persist candles, by pair and timeframe
+type CandleStorage interface {
+	// CreateCandles stores the candles of the timeframe, replacing the candles with the same pair and time
+	CreateCandles(timeframe string, candles ...model.Candle) error
+	// Candles returns the candles of the pair between start and end (inclusive), sorted by time
+	Candles(pair, timeframe string, start, end time.Time) ([]model.Candle, error)
+	// Compact rolls up the candles of the pair in the from timeframe (e.g. 1m), between start and end
+	// (inclusive), into candles of the to timeframe (e.g. 5m), in a single transaction. See compactCandles.
+	Compact(pair, from, to string, start, end time.Time, options ...CompactOption) error
+}
+
+type compactOptions struct {
+	deleteSource bool
+}
+
+// CompactOption configures the compaction of candles, see CandleStorage.Compact
+type CompactOption func(*compactOptions)
+
+// WithDeleteSource deletes the candles of the from timeframe rolled up by the compaction, to save space
+func WithDeleteSource() CompactOption {
+	return func(options *compactOptions) {
+		options.deleteSource = true
+	}
+}
+
+// compactCandles resamples the candles of a pair, in the from timeframe, to candles of the to timeframe
+// aligned to the UTC clock, e.g. 5m candles start at minutes multiple of 5. Only periods with all candles of
+// the from timeframe are compacted, so the in-progress period and gaps are kept in the fine timeframe and
+// compacted again later. Compacting the same candles again produces the same result. It returns the compacted
+// candles and the candles rolled up into them.
+func compactCandles(candles []model.Candle, from, to string) (compacted, source []model.Candle, err error) {
+	fromDuration, err := str2duration.ParseDuration(from)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, err := str2duration.ParseDuration(to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if target <= fromDuration || target%fromDuration != 0 {
+		return nil, nil, fmt.Errorf("compact: %s timeframe is not a multiple of the %s timeframe", to, from)
+	}
+
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Time.Before(candles[j].Time)
+	})
+
+	size := int(target / fromDuration)
+	for start := 0; start < len(candles); {
+		period := candles[start].Time.Truncate(target)
+		end := start
+		for end < len(candles) && candles[end].Time.Truncate(target).Equal(period) {
+			end++
+		}
+
+		if end-start == size {
+			compacted = append(compacted, mergeCandles(period, candles[start:end]))
+			source = append(source, candles[start:end]...)
+		}
+		start = end
+	}
+	return compacted, source, nil
+}
+
+// mergeCandles merges the candles of a period, sorted by time, in a single complete candle
+func mergeCandles(period time.Time, candles []model.Candle) model.Candle {
+	merged := model.Candle{
+		Pair:      candles[0].Pair,
+		Time:      period,
+		UpdatedAt: candles[len(candles)-1].UpdatedAt,
+		Open:      candles[0].Open,
+		Close:     candles[len(candles)-1].Close,
+		Low:       candles[0].Low,
+		High:      candles[0].High,
+		Complete:  true,
+	}
+
+	for _, candle := range candles {
+		merged.Low = math.Min(merged.Low, candle.Low)
+		merged.High = math.Max(merged.High, candle.High)
+		merged.Volume += candle.Volume
+	}
+	return merged
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// minuteCandles returns 1m candles from 00:00, with open i, close i + 0.5 and volume 1
+func minuteCandles(start time.Time, count int) []model.Candle {
+	candles := make([]model.Candle, 0, count)
+	for i := 0; i < count; i++ {
+		candles = append(candles, model.Candle{
+			Pair:      "BTCUSDT",
+			Time:      start.Add(time.Duration(i) * time.Minute),
+			UpdatedAt: start.Add(time.Duration(i+1) * time.Minute),
+			Open:      float64(i),
+			Close:     float64(i) + 0.5,
+			Low:       float64(i) - 1,
+			High:      float64(i) + 1,
+			Volume:    1,
+			Complete:  true,
+		})
+	}
+	return candles
+}
+
+func TestCompactCandles(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 00:00 to 00:11, the last period is in progress
+	compacted, source, err := compactCandles(minuteCandles(start, 12), "1m", "5m")
+	require.NoError(t, err)
+	require.Len(t, source, 10)
+	require.Equal(t, []model.Candle{
+		{Pair: "BTCUSDT", Time: start, UpdatedAt: start.Add(5 * time.Minute), Open: 0, Close: 4.5, Low: -1,
+			High: 5, Volume: 5, Complete: true},
+		{Pair: "BTCUSDT", Time: start.Add(5 * time.Minute), UpdatedAt: start.Add(10 * time.Minute), Open: 5,
+			Close: 9.5, Low: 4, High: 10, Volume: 5, Complete: true},
+	}, compacted)
+
+	t.Run("gap", func(t *testing.T) {
+		candles := minuteCandles(start, 10)
+		candles = append(candles[:2], candles[3:]...)
+		compacted, source, err := compactCandles(candles, "1m", "5m")
+		require.NoError(t, err)
+		require.Len(t, compacted, 1)
+		require.Equal(t, start.Add(5*time.Minute), compacted[0].Time)
+		require.Len(t, source, 5)
+	})
+
+	t.Run("invalid target", func(t *testing.T) {
+		_, _, err := compactCandles(minuteCandles(start, 10), "5m", "7m")
+		require.Error(t, err)
+		_, _, err = compactCandles(minuteCandles(start, 10), "5m", "1m")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	busyRetries = 5
+	busyBackoff = 10 * time.Millisecond
+)
+
+// isBusy returns true for SQLite errors caused by concurrent access to the database
+func isBusy(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "SQLITE_BUSY") || strings.Contains(message, "database is locked")
+}
+
+// retryBusy executes fn until it succeeds or returns an error other than busy, up to the given number
+// of retries. The wait between attempts starts with backoff and doubles after each retry.
+func retryBusy(fn func() error, retries int, backoff time.Duration) error {
+	err := fn()
+	for attempt := 0; attempt < retries && err != nil && isBusy(err); attempt++ {
+		time.Sleep(backoff << attempt)
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryBusy(t *testing.T) {
+	busy := errors.New("database is locked (5) (SQLITE_BUSY)")
+
+	t.Run("contention", func(t *testing.T) {
+		// the database is locked by another writer during the first attempts
+		var (
+			mtx      sync.Mutex
+			attempts int
+		)
+		write := func() error {
+			mtx.Lock()
+			defer mtx.Unlock()
+			attempts++
+			if attempts < 3 {
+				return busy
+			}
+			return nil
+		}
+
+		require.NoError(t, retryBusy(write, 5, time.Millisecond))
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("retries limit", func(t *testing.T) {
+		attempts := 0
+		err := retryBusy(func() error {
+			attempts++
+			return busy
+		}, 2, time.Millisecond)
+		require.ErrorIs(t, err, busy)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("non busy error", func(t *testing.T) {
+		failure := errors.New("constraint failed")
+		attempts := 0
+		err := retryBusy(func() error {
+			attempts++
+			return failure
+		}, 5, time.Millisecond)
+		require.ErrorIs(t, err, failure)
+		require.Equal(t, 1, attempts)
+	})
+}
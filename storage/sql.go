@@ -9,6 +9,54 @@ import (
 	"github.com/rodrigo-brito/ninjabot/model"
 )
 
+// strategyState is the table of the strategy states
+type strategyState struct {
+	Key       string `gorm:"primaryKey"`
+	State     []byte
+	UpdatedAt time.Time
+}
+
+// candleRecord is the table of the candles, by pair, timeframe and time
+type candleRecord struct {
+	Pair      string    `gorm:"primaryKey"`
+	Timeframe string    `gorm:"primaryKey"`
+	Time      time.Time `gorm:"primaryKey"`
+	UpdatedAt time.Time
+	Open      float64
+	Close     float64
+	Low       float64
+	High      float64
+	Volume    float64
+}
+
+func newCandleRecord(timeframe string, candle model.Candle) candleRecord {
+	return candleRecord{
+		Pair:      candle.Pair,
+		Timeframe: timeframe,
+		Time:      candle.Time,
+		UpdatedAt: candle.UpdatedAt,
+		Open:      candle.Open,
+		Close:     candle.Close,
+		Low:       candle.Low,
+		High:      candle.High,
+		Volume:    candle.Volume,
+	}
+}
+
+func (r candleRecord) candle() model.Candle {
+	return model.Candle{
+		Pair:      r.Pair,
+		Time:      r.Time,
+		UpdatedAt: r.UpdatedAt,
+		Open:      r.Open,
+		Close:     r.Close,
+		Low:       r.Low,
+		High:      r.High,
+		Volume:    r.Volume,
+		Complete:  true,
+	}
+}
+
 type SQL struct {
 	db *gorm.DB
 }
@@ -35,7 +83,7 @@ func FromSQL(dialect gorm.Dialector, opts ...gorm.Option) (Storage, error) {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	err = db.AutoMigrate(&model.Order{})
+	err = db.AutoMigrate(&model.Order{}, &model.EquitySnapshot{}, &strategyState{}, &candleRecord{})
 	if err != nil {
 		return nil, err
 	}
@@ -45,19 +93,28 @@ func FromSQL(dialect gorm.Dialector, opts ...gorm.Option) (Storage, error) {
 	}, nil
 }
 
+// transaction executes fn in a transaction, retried when the database is busy (SQLITE_BUSY)
+func (s *SQL) transaction(fn func(tx *gorm.DB) error) error {
+	return retryBusy(func() error {
+		return s.db.Transaction(fn)
+	}, busyRetries, busyBackoff)
+}
+
 // CreateOrder creates a new order in a SQL database
 func (s *SQL) CreateOrder(order *model.Order) error {
-	result := s.db.Create(order) // pass pointer of data to Create
-	return result.Error
+	return s.transaction(func(tx *gorm.DB) error {
+		return tx.Create(order).Error // pass pointer of data to Create
+	})
 }
 
 // UpdateOrder updates a given order
 func (s *SQL) UpdateOrder(order *model.Order) error {
-	o := model.Order{ID: order.ID}
-	s.db.First(&o)
-	o = *order
-	result := s.db.Save(&o)
-	return result.Error
+	return s.transaction(func(tx *gorm.DB) error {
+		o := model.Order{ID: order.ID}
+		tx.First(&o)
+		o = *order
+		return tx.Save(&o).Error
+	})
 }
 
 // Orders filter a list of orders given a filter
@@ -78,3 +135,121 @@ func (s *SQL) Orders(filters ...OrderFilter) ([]*model.Order, error) {
 		return true
 	}), nil
 }
+
+// CreateEquitySnapshot stores a new snapshot of the net liquidation value
+func (s *SQL) CreateEquitySnapshot(snapshot *model.EquitySnapshot) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return tx.Create(snapshot).Error
+	})
+}
+
+// EquitySnapshots returns the snapshots between start and end (inclusive), sorted by time
+func (s *SQL) EquitySnapshots(start, end time.Time) ([]model.EquitySnapshot, error) {
+	snapshots := make([]model.EquitySnapshot, 0)
+	result := s.db.Where("time >= ? AND time <= ?", start, end).Order("time").Find(&snapshots)
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		return nil, result.Error
+	}
+	return snapshots, nil
+}
+
+// DeleteEquitySnapshots removes the snapshots before the given time
+func (s *SQL) DeleteEquitySnapshots(before time.Time) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return tx.Where("time < ?", before).Delete(&model.EquitySnapshot{}).Error
+	})
+}
+
+// SaveStrategyState stores the state of a strategy, replacing the previous state of the key
+func (s *SQL) SaveStrategyState(key string, state []byte) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return tx.Save(&strategyState{Key: key, State: state}).Error
+	})
+}
+
+// StrategyState returns the last state saved with the key, nil when there is no state
+func (s *SQL) StrategyState(key string) ([]byte, error) {
+	var state strategyState
+	result := s.db.Where(&strategyState{Key: key}).Limit(1).Find(&state)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return state.State, nil
+}
+
+func saveCandles(tx *gorm.DB, timeframe string, candles []model.Candle) error {
+	for _, candle := range candles {
+		record := newCandleRecord(timeframe, candle)
+		if err := tx.Save(&record).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findCandles returns the candles of the query, sorted by time
+func findCandles(query *gorm.DB) ([]model.Candle, error) {
+	records := make([]candleRecord, 0)
+	result := query.Order("time").Find(&records)
+	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+		return nil, result.Error
+	}
+
+	candles := make([]model.Candle, 0, len(records))
+	for _, record := range records {
+		candles = append(candles, record.candle())
+	}
+	return candles, nil
+}
+
+// CreateCandles stores the candles of the timeframe, replacing the candles with the same pair and time
+func (s *SQL) CreateCandles(timeframe string, candles ...model.Candle) error {
+	return s.transaction(func(tx *gorm.DB) error {
+		return saveCandles(tx, timeframe, candles)
+	})
+}
+
+// Candles returns the candles of the pair between start and end (inclusive), sorted by time
+func (s *SQL) Candles(pair, timeframe string, start, end time.Time) ([]model.Candle, error) {
+	return findCandles(s.db.Where(&candleRecord{Pair: pair, Timeframe: timeframe}).
+		Where("time >= ? AND time <= ?", start, end))
+}
+
+// Compact rolls up the candles of the pair in the from timeframe, between start and end, into candles of the
+// to timeframe, see CandleStorage
+func (s *SQL) Compact(pair, from, to string, start, end time.Time, options ...CompactOption) error {
+	var config compactOptions
+	for _, option := range options {
+		option(&config)
+	}
+
+	return s.transaction(func(tx *gorm.DB) error {
+		fine, err := findCandles(tx.Where(&candleRecord{Pair: pair, Timeframe: from}).
+			Where("time >= ? AND time <= ?", start, end))
+		if err != nil {
+			return err
+		}
+
+		compacted, source, err := compactCandles(fine, from, to)
+		if err != nil {
+			return err
+		}
+
+		if err := saveCandles(tx, to, compacted); err != nil {
+			return err
+		}
+
+		if config.deleteSource {
+			for _, candle := range source {
+				record := candleRecord{Pair: pair, Timeframe: from, Time: candle.Time}
+				if err := tx.Delete(&record).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
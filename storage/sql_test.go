@@ -21,4 +21,11 @@ func TestFromSQL(t *testing.T) {
 	require.NoError(t, err)
 
 	storageUseCase(repo, t)
+	equityStorageUseCase(repo.(EquityStorage), t)
+	stateStorageUseCase(repo.(StateStorage), t)
+	candleStorageUseCase(repo.(CandleStorage), t)
+
+	orders, err := repo.Orders()
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
 }
@@ -14,6 +14,22 @@ type Storage interface {
 	Orders(filters ...OrderFilter) ([]*model.Order, error)
 }
 
+// EquityStorage is implemented by storages that persist the history of the net liquidation value
+type EquityStorage interface {
+	CreateEquitySnapshot(snapshot *model.EquitySnapshot) error
+	// EquitySnapshots returns the snapshots between start and end (inclusive), sorted by time
+	EquitySnapshots(start, end time.Time) ([]model.EquitySnapshot, error)
+	// DeleteEquitySnapshots removes the snapshots before the given time
+	DeleteEquitySnapshots(before time.Time) error
+}
+
+// StateStorage is implemented by storages that persist the state of the strategies between restarts
+type StateStorage interface {
+	SaveStrategyState(key string, state []byte) error
+	// StrategyState returns the last state saved with the key, nil when there is no state
+	StrategyState(key string) ([]byte, error)
+}
+
 func WithStatusIn(status ...model.OrderStatusType) OrderFilter {
 	return func(order model.Order) bool {
 		for _, s := range status {
@@ -82,3 +82,101 @@ func storageUseCase(repo Storage, t *testing.T) {
 		require.Equal(t, firstOrder.Quantity, orders[0].Quantity)
 	})
 }
+
+func equityStorageUseCase(repo EquityStorage, t *testing.T) {
+	t.Helper()
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		err := repo.CreateEquitySnapshot(&model.EquitySnapshot{
+			Time:  start.Add(time.Duration(i) * time.Hour),
+			Value: float64(1000 + i),
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("time range", func(t *testing.T) {
+		snapshots, err := repo.EquitySnapshots(start.Add(time.Hour), start.Add(3*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, snapshots, 3)
+		require.Equal(t, 1001.0, snapshots[0].Value)
+		require.Equal(t, 1003.0, snapshots[2].Value)
+		require.True(t, snapshots[0].Time.Equal(start.Add(time.Hour)))
+	})
+
+	t.Run("delete before", func(t *testing.T) {
+		err := repo.DeleteEquitySnapshots(start.Add(2 * time.Hour))
+		require.NoError(t, err)
+
+		snapshots, err := repo.EquitySnapshots(start, start.Add(24*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, snapshots, 3)
+		require.Equal(t, 1002.0, snapshots[0].Value)
+	})
+}
+
+func stateStorageUseCase(repo StateStorage, t *testing.T) {
+	t.Helper()
+
+	state, err := repo.StrategyState("grid")
+	require.NoError(t, err)
+	require.Nil(t, state)
+
+	require.NoError(t, repo.SaveStrategyState("grid", []byte(`{"levels":[1,2]}`)))
+	require.NoError(t, repo.SaveStrategyState("grid", []byte(`{"levels":[3]}`)))
+	require.NoError(t, repo.SaveStrategyState("trailing", []byte(`{"reference":10}`)))
+
+	state, err = repo.StrategyState("grid")
+	require.NoError(t, err)
+	require.Equal(t, `{"levels":[3]}`, string(state))
+
+	state, err = repo.StrategyState("trailing")
+	require.NoError(t, err)
+	require.Equal(t, `{"reference":10}`, string(state))
+}
+
+func candleStorageUseCase(repo CandleStorage, t *testing.T) {
+	t.Helper()
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	require.NoError(t, repo.CreateCandles("1m", minuteCandles(start, 12)...))
+
+	candles, err := repo.Candles("BTCUSDT", "1m", start.Add(time.Minute), start.Add(3*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, candles, 3)
+	require.Equal(t, 1.0, candles[0].Open)
+
+	// the candles out of the range are not compacted
+	require.NoError(t, repo.Compact("BTCUSDT", "1m", "5m", start.Add(5*time.Minute), end))
+	compacted, err := repo.Candles("BTCUSDT", "5m", start, end)
+	require.NoError(t, err)
+	require.Len(t, compacted, 1)
+	require.True(t, compacted[0].Time.Equal(start.Add(5*time.Minute)))
+
+	require.NoError(t, repo.Compact("BTCUSDT", "1m", "5m", start, end, WithDeleteSource()))
+
+	compacted, err = repo.Candles("BTCUSDT", "5m", start, end)
+	require.NoError(t, err)
+	require.Len(t, compacted, 2)
+	require.True(t, compacted[0].Time.Equal(start))
+	require.Equal(t, 0.0, compacted[0].Open)
+	require.Equal(t, 4.5, compacted[0].Close)
+	require.Equal(t, 5.0, compacted[0].High)
+	require.Equal(t, 5.0, compacted[0].Volume)
+	require.Equal(t, 9.5, compacted[1].Close)
+
+	// the in-progress period is kept in the fine timeframe
+	candles, err = repo.Candles("BTCUSDT", "1m", start, end)
+	require.NoError(t, err)
+	require.Len(t, candles, 2)
+	require.True(t, candles[0].Time.Equal(start.Add(10*time.Minute)))
+
+	// idempotent
+	require.NoError(t, repo.Compact("BTCUSDT", "1m", "5m", start, end, WithDeleteSource()))
+	compacted, err = repo.Candles("BTCUSDT", "5m", start, end)
+	require.NoError(t, err)
+	require.Len(t, compacted, 2)
+
+	require.Error(t, repo.Compact("BTCUSDT", "1m", "90s", start, end))
+}
@@ -0,0 +1,55 @@
+package strategy
+
+import (
+	"errors"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+var errAmendNotSupported = errors.New("order amendment not supported by the broker")
+
+// brokerWrapper is the base of the brokers that wrap the broker of the strategy, e.g. the guards. It forwards
+// the optional interfaces of the wrapped broker (service.ReduceOnlyBroker, service.OrderAmender and
+// service.OrderSimulator), so they are available through any chain of wrappers. The wrappers override the
+// methods they check or register.
+type brokerWrapper struct {
+	service.Broker
+}
+
+func (b brokerWrapper) CreateOrderLimitReduceOnly(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	broker, ok := b.Broker.(service.ReduceOnlyBroker)
+	if !ok {
+		return model.Order{}, order.ErrReduceOnlyNotSupported
+	}
+	return broker.CreateOrderLimitReduceOnly(side, pair, size, limit)
+}
+
+func (b brokerWrapper) CreateOrderMarketReduceOnly(side model.SideType, pair string,
+	size float64) (model.Order, error) {
+	broker, ok := b.Broker.(service.ReduceOnlyBroker)
+	if !ok {
+		return model.Order{}, order.ErrReduceOnlyNotSupported
+	}
+	return broker.CreateOrderMarketReduceOnly(side, pair, size)
+}
+
+func (b brokerWrapper) AmendOrder(order model.Order, price, quantity float64) (model.Order, error) {
+	broker, ok := b.Broker.(service.OrderAmender)
+	if !ok {
+		return model.Order{}, errAmendNotSupported
+	}
+	return broker.AmendOrder(order, price, quantity)
+}
+
+func (b brokerWrapper) SimulateOrder(pair string, side model.SideType, quantity,
+	price float64) (model.OrderPreview, error) {
+	simulator, ok := b.Broker.(service.OrderSimulator)
+	if !ok {
+		return model.OrderPreview{}, exchange.ErrSimulationNotSupported
+	}
+	return simulator.SimulateOrder(pair, side, quantity, price)
+}
@@ -0,0 +1,131 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var ErrMarketClosed = errors.New("order blocked outside the trading calendar")
+
+// TradingWindow is a time range of a weekday when orders are allowed, Start and End are offsets from
+// midnight, e.g. 9h30m and 16h. End is exclusive.
+type TradingWindow struct {
+	Weekday time.Weekday
+	Start   time.Duration
+	End     time.Duration
+}
+
+// TradingCalendar defines the windows when orders are allowed, in the given location,
+// e.g. the sessions of a stock exchange for backtests of equities with CSV data
+type TradingCalendar struct {
+	Location *time.Location
+	Windows  []TradingWindow
+}
+
+// NewTradingCalendar creates a calendar with the windows in the location, UTC when nil
+func NewTradingCalendar(location *time.Location, windows ...TradingWindow) *TradingCalendar {
+	if location == nil {
+		location = time.UTC
+	}
+	return &TradingCalendar{Location: location, Windows: windows}
+}
+
+// Weekdays returns the same window from Monday to Friday
+func Weekdays(start, end time.Duration) []TradingWindow {
+	windows := make([]TradingWindow, 0, 5)
+	for weekday := time.Monday; weekday <= time.Friday; weekday++ {
+		windows = append(windows, TradingWindow{Weekday: weekday, Start: start, End: end})
+	}
+	return windows
+}
+
+// Open returns true when the time is inside one of the windows of the calendar
+func (c TradingCalendar) Open(t time.Time) bool {
+	local := t.In(c.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.Location)
+	offset := local.Sub(midnight)
+	for _, window := range c.Windows {
+		if window.Weekday == local.Weekday() && offset >= window.Start && offset < window.End {
+			return true
+		}
+	}
+	return false
+}
+
+// calendarGuard blocks all orders when the time of the current candle is outside the trading calendar
+type calendarGuard struct {
+	brokerWrapper
+	calendar *TradingCalendar
+	now      time.Time
+}
+
+func (g *calendarGuard) check(pair string) error {
+	if !g.calendar.Open(g.now) {
+		return fmt.Errorf("%w: %s at %s", ErrMarketClosed, pair, g.now.In(g.calendar.Location))
+	}
+	return nil
+}
+
+func (g *calendarGuard) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return nil, err
+	}
+	return g.Broker.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
+}
+
+func (g *calendarGuard) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.Broker.CreateOrderLimit(side, pair, size, limit)
+}
+
+func (g *calendarGuard) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.Broker.CreateOrderMarket(side, pair, size)
+}
+
+func (g *calendarGuard) CreateOrderMarketQuote(side model.SideType, pair string,
+	quote float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.Broker.CreateOrderMarketQuote(side, pair, quote)
+}
+
+func (g *calendarGuard) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.Broker.CreateOrderStop(pair, quantity, limit)
+}
+
+func (g *calendarGuard) CreateOrderLimitReduceOnly(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.brokerWrapper.CreateOrderLimitReduceOnly(side, pair, size, limit)
+}
+
+func (g *calendarGuard) CreateOrderMarketReduceOnly(side model.SideType, pair string,
+	size float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.brokerWrapper.CreateOrderMarketReduceOnly(side, pair, size)
+}
+
+func (g *calendarGuard) AmendOrder(order model.Order, price, quantity float64) (model.Order, error) {
+	if err := g.check(order.Pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.brokerWrapper.AmendOrder(order, price, quantity)
+}
@@ -0,0 +1,52 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestTradingCalendar_Open(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	calendar := NewTradingCalendar(newYork, Weekdays(9*time.Hour+30*time.Minute, 16*time.Hour)...)
+
+	// Friday, 2021-01-08 in New York (UTC-5)
+	require.False(t, calendar.Open(time.Date(2021, 1, 8, 14, 29, 0, 0, time.UTC)))
+	require.True(t, calendar.Open(time.Date(2021, 1, 8, 14, 30, 0, 0, time.UTC)))
+	require.True(t, calendar.Open(time.Date(2021, 1, 8, 20, 59, 0, 0, time.UTC)))
+	require.False(t, calendar.Open(time.Date(2021, 1, 8, 21, 0, 0, 0, time.UTC)))
+	// Saturday
+	require.False(t, calendar.Open(time.Date(2021, 1, 9, 15, 0, 0, 0, time.UTC)))
+}
+
+func TestController_SetTradingCalendar(t *testing.T) {
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	strategy := &scriptedStrategy{
+		sides: []model.SideType{model.SideTypeBuy, model.SideTypeBuy, model.SideTypeBuy},
+	}
+	controller := NewStrategyController("BTCUSDT", strategy, wallet)
+	controller.SetTradingCalendar(NewTradingCalendar(time.UTC, Weekdays(0, 24*time.Hour)...))
+	controller.Start()
+
+	// Friday, Saturday and Monday
+	for _, day := range []int{8, 9, 11} {
+		candle := model.Candle{Pair: "BTCUSDT", Time: time.Date(2021, 1, day, 12, 0, 0, 0, time.UTC), Close: 10,
+			Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	require.Len(t, strategy.errors, 3)
+	require.NoError(t, strategy.errors[0])
+	require.ErrorIs(t, strategy.errors[1], ErrMarketClosed)
+	require.NoError(t, strategy.errors[2])
+
+	// the dataframe is updated with the weekend candle
+	require.Len(t, controller.dataframe.Close, 3)
+}
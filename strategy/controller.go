@@ -1,6 +1,10 @@
 package strategy
 
 import (
+	"math"
+	"sync"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/rodrigo-brito/ninjabot/model"
@@ -12,6 +16,27 @@ type Controller struct {
 	dataframe *model.Dataframe
 	broker    service.Broker
 	started   bool
+	tradeLog  *TradeLog
+	guard     *entryGuard
+	live      *liveGuard
+	calendar  *calendarGuard
+	volume    *volumeCheck
+	timing    SignalTiming
+	pending   *model.Dataframe
+	lookahead bool
+	clone     bool
+	closed    bool
+	signal    *signalBroker
+	watchdog  *watchdog
+	// time of the last complete candle, see CandleCloseStrategy
+	lastClose time.Time
+	// serialises the callbacks of the strategy, the candles are received from the feed and the cross prices
+	// from the cross-exchange feed
+	callbacks sync.Mutex
+	// copy of the dataframe of the last complete candle with the indicators, when enabled, see Dataframe
+	mtx             sync.Mutex
+	snapshotEnabled bool
+	snapshot        model.Dataframe
 }
 
 func NewStrategyController(pair string, strategy Strategy, broker service.Broker) *Controller {
@@ -27,20 +52,240 @@ func NewStrategyController(pair string, strategy Strategy, broker service.Broker
 	}
 }
 
+// SetTradeLog registers the orders created by the strategy in the given TradeLog,
+// with the candle and indicator values of each order
+func (s *Controller) SetTradeLog(tradeLog *TradeLog) {
+	if tradeLog.broker == nil {
+		tradeLog.broker = s.broker
+	}
+	s.tradeLog = tradeLog
+	s.broker = tradeLogBroker{brokerWrapper: brokerWrapper{s.broker}, tradeLog: tradeLog}
+}
+
+// SetMinCandlesBetweenEntries blocks entries in the same direction of the last entry until the given
+// number of candles is closed, avoiding unintentional pyramiding. Exits and entries in the opposite
+// direction are not affected. Blocked orders return ErrEntrySpacing.
+func (s *Controller) SetMinCandlesBetweenEntries(candles int) {
+	s.entryGuard().minCandles = candles
+}
+
+// entryGuard returns the guard of the entries of the strategy, the broker is wrapped in the first call
+func (s *Controller) entryGuard() *entryGuard {
+	if s.guard == nil {
+		s.guard = newEntryGuard(s.broker, 0)
+		s.broker = s.guard
+	}
+	return s.guard
+}
+
+// CandlesUntilEntry returns how many candles remain until a new entry in the given side is allowed
+func (s *Controller) CandlesUntilEntry(side model.SideType) int {
+	if s.guard == nil {
+		return 0
+	}
+	return s.guard.candlesUntilEntry(side)
+}
+
+// SetMinLiveCandles blocks all orders with ErrMinLiveCandles until the given number of complete candles
+// is received after Start, e.g. preloaded candles do not count. It avoids trading on thin startup data,
+// even when the dataframe has enough candles for the warmup period.
+func (s *Controller) SetMinLiveCandles(candles int) {
+	s.live = &liveGuard{brokerWrapper: brokerWrapper{s.broker}, minCandles: candles}
+	s.broker = s.live
+}
+
+// SetDataframeSnapshot keeps a copy of the dataframe of each complete candle for Dataframe. It is disabled by
+// default, since it copies the dataframe and the indicators on every candle.
+func (s *Controller) SetDataframeSnapshot(enabled bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.snapshotEnabled = enabled
+}
+
+// Dataframe returns a copy of the last candles of the dataframe of the last complete candle, with the
+// indicators of the metadata. It is safe to call while the controller receives candles, and it is empty
+// until the warmup period is completed or without SetDataframeSnapshot.
+func (s *Controller) Dataframe(candles int) model.Dataframe {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.snapshot.Sample(candles).Clone()
+}
+
+// SetTradingCalendar blocks all orders with ErrMarketClosed when the time of the current candle is outside
+// the windows of the calendar. Dataframes and indicators are still updated with all candles.
+func (s *Controller) SetTradingCalendar(calendar *TradingCalendar) {
+	s.calendar = &calendarGuard{brokerWrapper: brokerWrapper{s.broker}, calendar: calendar}
+	s.broker = s.calendar
+}
+
+// SetMinCandleVolume blocks entries with ErrLowVolume when the volume of the current candle is below the
+// minimum volume, or below the minimum ratio of the average volume of the previous period candles, e.g. 0.5
+// for half of the average. Low volume candles have poor fills and unreliable signals. Exits are not affected
+// and a zero minimum disables the check.
+func (s *Controller) SetMinCandleVolume(minVolume, minRatio float64, period int) {
+	s.volume = &volumeCheck{minVolume: minVolume, minRatio: minRatio, period: period, ratio: math.NaN()}
+	guard := s.entryGuard()
+	guard.checks = append(guard.checks, s.volume.check)
+}
+
+// VolumeRatio returns the ratio of the volume of the current candle to the average volume of the previous
+// candles, see SetMinCandleVolume. It is NaN without the check, before the candles of the period or when they
+// have no volume.
+func (s *Controller) VolumeRatio() float64 {
+	if s.volume == nil {
+		return math.NaN()
+	}
+	return s.volume.ratio
+}
+
+// SetSignalOnly emits the orders of the strategy as signals instead of sending them to the broker, they are
+// rejected with ErrSignalOnly. It must be set before the other guards, so the blocked orders are not emitted.
+// Account and positions are still read from the broker.
+func (s *Controller) SetSignalOnly(emit func(model.Signal)) {
+	s.signal = &signalBroker{brokerWrapper: brokerWrapper{s.broker}, strategy: s.strategy, emit: emit}
+	s.broker = s.signal
+}
+
+// SetLookaheadGuard enables a strict mode to catch lookahead bugs, mainly useful in backtests. The series
+// delivered to the strategy are bounded to the candles available, so reading the dataframe beyond the current
+// candle, or indicators with more values than candles, panic with ErrLookahead.
+func (s *Controller) SetLookaheadGuard(enabled bool) {
+	s.lookahead = enabled
+}
+
+// SetCloneDataframe delivers a deep copy of the dataframe to each execution of the strategy, so changes of the
+// series by the strategy do not affect the dataframe of the next candles. Disabled by default, the copy has
+// a cost proportional to the warmup period and the indicators.
+func (s *Controller) SetCloneDataframe(enabled bool) {
+	s.clone = enabled
+}
+
+// SetClosedCandlesOnly prevents the strategy from acting on forming candles. When enabled, partial candles
+// only update the dataframe: OnCandle is executed only for complete candles and OnPartialCandle of
+// high frequency strategies is never executed. Disabled by default, e.g. for scalping strategies.
+func (s *Controller) SetClosedCandlesOnly(enabled bool) {
+	s.closed = enabled
+}
+
+// SetSignalTiming defines when the strategy OnCandle is executed, see SignalTiming
+func (s *Controller) SetSignalTiming(timing SignalTiming) {
+	s.timing = timing
+}
+
+// SetTimeout limits the execution time of the strategy OnCandle. When exceeded, an error is logged and the
+// candle is skipped by the strategy, the dataframe is still updated. The strategy is disabled after
+// maxTimeouts consecutive timeouts, zero never disables it. The execution that exceeded the timeout is not
+// interrupted, the next candles are skipped while it is running, and its calls to the broker return
+// ErrStrategyTimeout. The strategy receives a copy of the dataframe, as in SetCloneDataframe.
+func (s *Controller) SetTimeout(timeout time.Duration, maxTimeouts int) {
+	s.watchdog = &watchdog{timeout: timeout, maxTimeouts: maxTimeouts}
+}
+
+// Disabled returns true when the strategy was disabled after repeated timeouts, see SetTimeout
+func (s *Controller) Disabled() bool {
+	return s.watchdog != nil && s.watchdog.disabled
+}
+
+// WarmedUp returns true when the dataframe has enough candles for the strategy warmup period
+func (s *Controller) WarmedUp() bool {
+	return len(s.dataframe.Close) >= s.strategy.WarmupPeriod()
+}
+
 func (s *Controller) Start() {
+	s.callbacks.Lock()
+	defer s.callbacks.Unlock()
 	s.started = true
 }
 
+// onOpen executes the strategy with the dataframe of the last closed candle,
+// when the candle is the first update of a new period (SignalOnOpen)
+func (s *Controller) onOpen(candle model.Candle) {
+	if s.pending == nil || !candle.Time.After(s.pending.Time[len(s.pending.Time)-1]) {
+		return
+	}
+
+	sample := s.pending
+	s.pending = nil
+	s.onCandle(sample)
+}
+
+// indicators executes the strategy Indicators, checking lookahead when enabled
+func (s *Controller) indicators(df *model.Dataframe) {
+	if s.lookahead {
+		defer recoverLookahead(df)
+	}
+
+	s.strategy.Indicators(df)
+	if s.lookahead {
+		checkIndicators(df)
+	}
+}
+
+// onCandle executes the strategy OnCandle, checking lookahead and the timeout when enabled
+func (s *Controller) onCandle(df *model.Dataframe) {
+	if s.watchdog != nil {
+		s.watchdog.run(df, s.broker, func(df *model.Dataframe, broker service.Broker) {
+			if s.lookahead {
+				defer recoverLookahead(df)
+			}
+			s.strategy.OnCandle(df, broker)
+		})
+		return
+	}
+
+	if s.lookahead {
+		defer recoverLookahead(df)
+	}
+	s.strategy.OnCandle(df, s.broker)
+}
+
 func (s *Controller) OnPartialCandle(candle model.Candle) {
+	s.callbacks.Lock()
+	defer s.callbacks.Unlock()
+
+	if s.calendar != nil {
+		s.calendar.now = candle.Time
+	}
+	s.onOpen(candle)
+	if s.closed {
+		if !candle.Complete {
+			s.updateDataFrame(candle)
+		}
+		return
+	}
+
 	if !candle.Complete && len(s.dataframe.Close) >= s.strategy.WarmupPeriod() {
 		if str, ok := s.strategy.(HighFrequencyStrategy); ok {
 			s.updateDataFrame(candle)
-			str.Indicators(s.dataframe)
-			str.OnPartialCandle(s.dataframe, s.broker)
+			df := s.dataframe
+			if s.clone {
+				clone := df.Clone()
+				df = &clone
+			}
+			if s.lookahead {
+				df = boundDataframe(df)
+			}
+
+			s.indicators(df)
+			if s.tradeLog != nil {
+				s.tradeLog.setContext(candle, df)
+			}
+			if s.signal != nil {
+				s.signal.candle, s.signal.dataframe = candle, df
+			}
+			s.onPartialCandle(str, df)
 		}
 	}
 }
 
+// onPartialCandle executes the strategy OnPartialCandle, checking lookahead when enabled
+func (s *Controller) onPartialCandle(str HighFrequencyStrategy, df *model.Dataframe) {
+	if s.lookahead {
+		defer recoverLookahead(df)
+	}
+	str.OnPartialCandle(df, s.broker)
+}
+
 func (s *Controller) updateDataFrame(candle model.Candle) {
 	if len(s.dataframe.Time) > 0 && candle.Time.Equal(s.dataframe.Time[len(s.dataframe.Time)-1]) {
 		last := len(s.dataframe.Time) - 1
@@ -65,21 +310,94 @@ func (s *Controller) updateDataFrame(candle model.Candle) {
 			s.dataframe.Metadata[k] = append(s.dataframe.Metadata[k], v)
 		}
 	}
+
+	if s.volume != nil {
+		s.volume.update(s.dataframe)
+	}
+}
+
+// OnCrossPrice executes the strategy OnCrossPrice, see CrossExchangeStrategy. It is not executed concurrently
+// with the candle callbacks of the strategy.
+func (s *Controller) OnCrossPrice(price model.CrossPrice) {
+	s.callbacks.Lock()
+	defer s.callbacks.Unlock()
+
+	if str, ok := s.strategy.(CrossExchangeStrategy); ok && s.started {
+		str.OnCrossPrice(price)
+	}
+}
+
+// onCandleClose executes the strategy OnCandleClose for the first complete candle of each period
+func (s *Controller) onCandleClose(candle model.Candle) {
+	if !candle.Complete || (!s.lastClose.IsZero() && !candle.Time.After(s.lastClose)) {
+		return
+	}
+
+	s.lastClose = candle.Time
+	if str, ok := s.strategy.(CandleCloseStrategy); ok && s.started {
+		str.OnCandleClose(candle)
+	}
 }
 
 func (s *Controller) OnCandle(candle model.Candle) {
+	s.callbacks.Lock()
+	defer s.callbacks.Unlock()
+
 	if len(s.dataframe.Time) > 0 && candle.Time.Before(s.dataframe.Time[len(s.dataframe.Time)-1]) {
 		log.Errorf("late candle received: %#v", candle)
 		return
 	}
 
+	if s.calendar != nil {
+		s.calendar.now = candle.Time
+	}
+
+	// when the open of the candle was not received, e.g. backtest without partial candles
+	s.onOpen(candle)
+
 	s.updateDataFrame(candle)
+	if s.closed && !candle.Complete {
+		return
+	}
+	s.onCandleClose(candle)
+
+	if s.guard != nil {
+		s.guard.candles++
+	}
+	if s.live != nil && s.started {
+		s.live.candles++
+	}
 
 	if len(s.dataframe.Close) >= s.strategy.WarmupPeriod() {
 		sample := s.dataframe.Sample(s.strategy.WarmupPeriod())
-		s.strategy.Indicators(&sample)
+		if s.clone {
+			sample = sample.Clone()
+		}
+		df := &sample
+		if s.lookahead {
+			df = boundDataframe(df)
+		}
+
+		s.indicators(df)
+		// the copy is published before the strategy is executed, it can change the dataframe
+		s.mtx.Lock()
+		if s.snapshotEnabled {
+			s.snapshot = df.Clone()
+		}
+		s.mtx.Unlock()
+
+		if s.tradeLog != nil {
+			s.tradeLog.setContext(candle, df)
+		}
+		if s.signal != nil {
+			s.signal.candle, s.signal.dataframe = candle, df
+		}
 		if s.started {
-			s.strategy.OnCandle(&sample, s.broker)
+			if s.timing == SignalOnOpen {
+				s.pending = df
+				return
+			}
+			s.onCandle(df)
 		}
 	}
 }
@@ -0,0 +1,380 @@
+package strategy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+type decision struct {
+	candleTime time.Time
+	close      float64
+	indicator  float64
+	price      float64
+}
+
+// decisionStrategy records the data available when OnCandle is executed
+type decisionStrategy struct {
+	decisions []decision
+}
+
+func (s *decisionStrategy) Timeframe() string {
+	return "1h"
+}
+
+func (s *decisionStrategy) WarmupPeriod() int {
+	return 2
+}
+
+func (s *decisionStrategy) Indicators(df *model.Dataframe) []ChartIndicator {
+	// sum of the last two closes
+	df.Metadata["sum"] = model.Series[float64]{df.Close.Last(0) + df.Close.Last(1)}
+	return nil
+}
+
+func (s *decisionStrategy) OnCandle(df *model.Dataframe, broker service.Broker) {
+	order, _ := broker.CreateOrderMarket(model.SideTypeBuy, df.Pair, 1)
+	s.decisions = append(s.decisions, decision{
+		candleTime: df.Time[len(df.Time)-1],
+		close:      df.Close.Last(0),
+		indicator:  df.Metadata["sum"].Last(0),
+		price:      order.Price,
+	})
+}
+
+func TestController_SetSignalTiming(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candles := make([]model.Candle, 0)
+	for i := 0; i < 4; i++ {
+		candles = append(candles, model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Open:     float64(10*i + 5),
+			Close:    float64(10*i + 10),
+			Complete: true,
+		})
+	}
+
+	setup := func(timing SignalTiming) (*Controller, *exchange.PaperWallet, *decisionStrategy) {
+		wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+		strategy := &decisionStrategy{}
+		controller := NewStrategyController("BTCUSDT", strategy, wallet)
+		controller.SetSignalTiming(timing)
+		controller.Start()
+		return controller, wallet, strategy
+	}
+
+	t.Run("on close", func(t *testing.T) {
+		controller, wallet, strategy := setup(SignalOnClose)
+		for _, candle := range candles {
+			wallet.OnCandle(candle)
+			controller.OnCandle(candle)
+		}
+
+		require.Equal(t, []decision{
+			{candleTime: candles[1].Time, close: 20, indicator: 30, price: 20},
+			{candleTime: candles[2].Time, close: 30, indicator: 50, price: 30},
+			{candleTime: candles[3].Time, close: 40, indicator: 70, price: 40},
+		}, strategy.decisions)
+	})
+
+	t.Run("on open", func(t *testing.T) {
+		controller, wallet, strategy := setup(SignalOnOpen)
+		for _, candle := range candles {
+			open := model.Candle{Pair: candle.Pair, Time: candle.Time, Open: candle.Open, Close: candle.Open,
+				Low: candle.Open, High: candle.Open}
+			wallet.OnCandle(open)
+			controller.OnPartialCandle(open)
+			// further partial candles do not execute the strategy again
+			controller.OnPartialCandle(open)
+
+			wallet.OnCandle(candle)
+			controller.OnCandle(candle)
+		}
+
+		// indicators of the closed candle, executed at the open price of the next one
+		require.Equal(t, []decision{
+			{candleTime: candles[1].Time, close: 20, indicator: 30, price: 25},
+			{candleTime: candles[2].Time, close: 30, indicator: 50, price: 35},
+		}, strategy.decisions)
+	})
+
+	t.Run("on open without partial candles", func(t *testing.T) {
+		controller, wallet, strategy := setup(SignalOnOpen)
+		for _, candle := range candles {
+			wallet.OnCandle(candle)
+			controller.OnCandle(candle)
+		}
+
+		require.Equal(t, []decision{
+			{candleTime: candles[1].Time, close: 20, indicator: 30, price: 30},
+			{candleTime: candles[2].Time, close: 30, indicator: 50, price: 40},
+		}, strategy.decisions)
+	})
+}
+
+// partialStrategy counts the executions of a high frequency strategy
+type partialStrategy struct {
+	decisionStrategy
+	partials int
+}
+
+func (s *partialStrategy) OnPartialCandle(_ *model.Dataframe, _ service.Broker) {
+	s.partials++
+}
+
+func TestController_SetClosedCandlesOnly(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	strategy := &partialStrategy{}
+	controller := NewStrategyController("BTCUSDT", strategy, wallet)
+	controller.SetClosedCandlesOnly(true)
+	controller.Start()
+
+	for i := 0; i < 3; i++ {
+		candle := model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour), Close: float64(10 * i)}
+		for _, price := range []float64{1, 2, 3} {
+			candle.Close = float64(10*i) + price
+			wallet.OnCandle(candle)
+			controller.OnPartialCandle(candle)
+			// incomplete candles given to OnCandle do not execute the strategy either
+			controller.OnCandle(candle)
+			require.Equal(t, candle.Close, controller.dataframe.Close.Last(0))
+			require.Equal(t, i+1, len(controller.dataframe.Close))
+		}
+
+		candle.Close = float64(10*i + 10)
+		candle.Complete = true
+		wallet.OnCandle(candle)
+		controller.OnPartialCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	require.Zero(t, strategy.partials)
+	require.Equal(t, []decision{
+		{candleTime: start.Add(time.Hour), close: 20, indicator: 30, price: 20},
+		{candleTime: start.Add(2 * time.Hour), close: 30, indicator: 50, price: 30},
+	}, strategy.decisions)
+
+	t.Run("disabled", func(t *testing.T) {
+		strategy := &partialStrategy{}
+		controller := NewStrategyController("BTCUSDT", strategy, wallet)
+		controller.Start()
+		for i := 0; i < 3; i++ {
+			candle := model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour), Close: 1}
+			controller.OnPartialCandle(candle)
+			controller.OnCandle(candle)
+		}
+		// after the warmup
+		require.Equal(t, 1, strategy.partials)
+		require.Len(t, strategy.decisions, 2)
+	})
+}
+
+// mutatingStrategy overwrites the closes of the dataframe received
+type mutatingStrategy struct {
+	decisionStrategy
+}
+
+func (s *mutatingStrategy) OnCandle(df *model.Dataframe, _ service.Broker) {
+	for i := range df.Close {
+		df.Close[i] = 0
+	}
+}
+
+func TestController_SetCloneDataframe(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(clone bool) *Controller {
+		wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+		controller := NewStrategyController("BTCUSDT", &mutatingStrategy{}, wallet)
+		controller.SetCloneDataframe(clone)
+		controller.Start()
+		for i := 0; i < 3; i++ {
+			controller.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour),
+				Close: float64(10 * (i + 1)), Complete: true})
+		}
+		return controller
+	}
+
+	require.Equal(t, model.Series[float64]{10, 20, 30}, run(true).dataframe.Close)
+
+	// without the clone, the strategy changes the candles of the controller
+	require.Equal(t, model.Series[float64]{0, 0, 0}, run(false).dataframe.Close)
+}
+
+func TestController_Dataframe(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	controller := NewStrategyController("BTCUSDT", &decisionStrategy{}, wallet)
+	candle := func(i int) model.Candle {
+		return model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour),
+			Close: float64(10 * (i + 1)), Complete: true}
+	}
+
+	// disabled by default
+	disabled := NewStrategyController("BTCUSDT", &decisionStrategy{}, wallet)
+	for i := 0; i < 3; i++ {
+		disabled.OnCandle(candle(i))
+	}
+	require.Empty(t, disabled.Dataframe(10).Close)
+
+	// empty until the warmup period
+	controller.SetDataframeSnapshot(true)
+	controller.OnCandle(candle(0))
+	require.Empty(t, controller.Dataframe(10).Close)
+
+	controller.OnCandle(candle(1))
+	controller.OnCandle(candle(2))
+	df := controller.Dataframe(10)
+	require.Equal(t, model.Series[float64]{20, 30}, df.Close)
+	require.Equal(t, model.Series[float64]{50}, df.Metadata["sum"])
+
+	// the dataframe is a copy
+	df.Close[1] = 0
+	require.Equal(t, 30.0, controller.dataframe.Close.Last(0))
+	require.Equal(t, model.Series[float64]{30}, controller.Dataframe(1).Close)
+}
+
+// closeStrategy records the candles received by OnCandleClose
+type closeStrategy struct {
+	partialStrategy
+	closes []model.Candle
+}
+
+func (s *closeStrategy) OnCandleClose(candle model.Candle) {
+	s.closes = append(s.closes, candle)
+}
+
+func TestController_OnCandleClose(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	strategy := &closeStrategy{}
+	controller := NewStrategyController("BTCUSDT", strategy, wallet)
+	process := func(candle model.Candle) {
+		wallet.OnCandle(candle)
+		controller.OnPartialCandle(candle)
+		if candle.Complete {
+			controller.OnCandle(candle)
+		}
+	}
+
+	// preloaded candles are not notified
+	process(model.Candle{Pair: "BTCUSDT", Time: start, Close: 1, Complete: true})
+	controller.Start()
+
+	for i := 1; i <= 3; i++ {
+		candle := model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour)}
+		for _, price := range []float64{1, 2, 3} {
+			candle.Close = float64(10*i) + price
+			process(candle)
+		}
+		require.Len(t, strategy.closes, i-1)
+
+		candle.Close = float64(10 * i)
+		candle.Complete = true
+		process(candle)
+
+		// duplicated and revised candles of the same period
+		process(candle)
+		candle.Close++
+		process(candle)
+	}
+
+	// late candle
+	process(model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Hour), Close: 1, Complete: true})
+
+	require.Len(t, strategy.closes, 3)
+	for i, candle := range strategy.closes {
+		require.Equal(t, start.Add(time.Duration(i+1)*time.Hour), candle.Time)
+		require.Equal(t, float64(10*(i+1)), candle.Close)
+		require.True(t, candle.Complete)
+	}
+	require.Equal(t, 6, strategy.partials)
+}
+
+// crossStrategy records if the cross prices are received while a candle is processed
+type crossStrategy struct {
+	decisionStrategy
+	running    int32
+	overlapped int32
+	prices     int32
+}
+
+func (s *crossStrategy) OnCandle(_ *model.Dataframe, _ service.Broker) {
+	atomic.StoreInt32(&s.running, 1)
+	time.Sleep(time.Millisecond)
+	atomic.StoreInt32(&s.running, 0)
+}
+
+func (s *crossStrategy) OnCrossPrice(_ model.CrossPrice) {
+	atomic.AddInt32(&s.prices, 1)
+	if atomic.LoadInt32(&s.running) == 1 {
+		atomic.StoreInt32(&s.overlapped, 1)
+	}
+}
+
+func TestController_OnCrossPrice(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	strategy := &crossStrategy{}
+	controller := NewStrategyController("BTCUSDT", strategy, wallet)
+
+	// not started
+	controller.OnCrossPrice(model.CrossPrice{Pair: "BTCUSDT"})
+	require.Zero(t, atomic.LoadInt32(&strategy.prices))
+	controller.Start()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			controller.OnCrossPrice(model.CrossPrice{Pair: "BTCUSDT", Prices: map[string]float64{"binance": float64(i)}})
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		controller.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour),
+			Close: float64(i), Complete: true})
+	}
+	<-done
+
+	require.Equal(t, int32(20), atomic.LoadInt32(&strategy.prices))
+	require.Zero(t, atomic.LoadInt32(&strategy.overlapped))
+}
+
+func TestController_SimulateOrder(t *testing.T) {
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+
+	// the wrappers of the broker of the strategy forward the simulation
+	var broker service.Broker = tradeLogBroker{brokerWrapper: brokerWrapper{wallet}}
+	broker = &liveGuard{brokerWrapper: brokerWrapper{broker}}
+	broker = newEntryGuard(broker, 1)
+	broker = &calendarGuard{brokerWrapper: brokerWrapper{broker}}
+	broker = &signalBroker{brokerWrapper: brokerWrapper{broker}}
+	deadline := &timeoutBroker{brokerWrapper: brokerWrapper{broker}}
+
+	simulator, ok := service.Broker(deadline).(service.OrderSimulator)
+	require.True(t, ok)
+	preview, err := simulator.SimulateOrder("BTCUSDT", model.SideTypeBuy, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, 100.0, preview.Price)
+
+	_, err = simulator.SimulateOrder("ETHUSDT", model.SideTypeBuy, 1, 0)
+	require.ErrorIs(t, err, exchange.ErrPriceNotAvailable)
+
+	deadline.expired = 1
+	_, err = simulator.SimulateOrder("BTCUSDT", model.SideTypeBuy, 1, 0)
+	require.ErrorIs(t, err, ErrStrategyTimeout)
+
+	// brokers without simulation
+	_, err = brokerWrapper{struct{ service.Broker }{wallet}}.SimulateOrder("BTCUSDT", model.SideTypeBuy, 1, 0)
+	require.ErrorIs(t, err, exchange.ErrSimulationNotSupported)
+}
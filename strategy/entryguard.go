@@ -0,0 +1,146 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+var ErrEntrySpacing = errors.New("entry blocked by minimum candles between same-direction entries")
+
+// entryCheck returns an error when a new entry in the side is not allowed
+type entryCheck func(side model.SideType, pair string) error
+
+// entryGuard blocks the entries that do not pass the checks, e.g. the minimum volume of the candle. Entries in
+// the same direction of the last entry are also blocked before a minimum number of candles. An order is an
+// entry when it opens or increases a position, exits are never blocked. Reduce-only orders, amendments and
+// simulations never create an entry, they are forwarded without checks.
+type entryGuard struct {
+	brokerWrapper
+	checks     []entryCheck
+	minCandles int
+	candles    int
+	lastEntry  map[model.SideType]int
+}
+
+func newEntryGuard(broker service.Broker, minCandles int) *entryGuard {
+	guard := &entryGuard{
+		brokerWrapper: brokerWrapper{broker},
+		minCandles:    minCandles,
+		lastEntry:     make(map[model.SideType]int),
+	}
+	guard.checks = []entryCheck{guard.checkSpacing}
+	return guard
+}
+
+// candlesUntilEntry returns how many candles remain until a new entry in the given side is allowed
+func (g *entryGuard) candlesUntilEntry(side model.SideType) int {
+	last, ok := g.lastEntry[side]
+	if !ok {
+		return 0
+	}
+
+	remaining := last + g.minCandles - g.candles
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// isEntry returns if the order opens or increases the position of the pair in the broker
+func isEntry(broker service.Broker, side model.SideType, pair string) (bool, error) {
+	asset, _, err := broker.Position(pair)
+	if err != nil {
+		return false, err
+	}
+	return (side == model.SideTypeBuy && asset >= 0) || (side == model.SideTypeSell && asset <= 0), nil
+}
+
+// checkSpacing returns an error before the minimum candles since the last entry in the side
+func (g *entryGuard) checkSpacing(side model.SideType, pair string) error {
+	if remaining := g.candlesUntilEntry(side); remaining > 0 {
+		return fmt.Errorf("%w: %d candles remaining for %s %s", ErrEntrySpacing, remaining, side, pair)
+	}
+	return nil
+}
+
+// check returns if the order is an entry, or an error when the entry is not allowed
+func (g *entryGuard) check(side model.SideType, pair string) (bool, error) {
+	entry, err := isEntry(g.Broker, side, pair)
+	if err != nil || !entry {
+		return false, err
+	}
+
+	for _, check := range g.checks {
+		if err := check(side, pair); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func (g *entryGuard) register(side model.SideType, entry bool, err error) {
+	if entry && err == nil {
+		g.lastEntry[side] = g.candles
+	}
+}
+
+func (g *entryGuard) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	entry, err := g.check(side, pair)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := g.Broker.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
+	g.register(side, entry, err)
+	return orders, err
+}
+
+func (g *entryGuard) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	entry, err := g.check(side, pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order, err := g.Broker.CreateOrderLimit(side, pair, size, limit)
+	g.register(side, entry, err)
+	return order, err
+}
+
+func (g *entryGuard) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	entry, err := g.check(side, pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order, err := g.Broker.CreateOrderMarket(side, pair, size)
+	g.register(side, entry, err)
+	return order, err
+}
+
+func (g *entryGuard) CreateOrderMarketQuote(side model.SideType, pair string,
+	quote float64) (model.Order, error) {
+	entry, err := g.check(side, pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order, err := g.Broker.CreateOrderMarketQuote(side, pair, quote)
+	g.register(side, entry, err)
+	return order, err
+}
+
+func (g *entryGuard) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	entry, err := g.check(model.SideTypeSell, pair)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order, err := g.Broker.CreateOrderStop(pair, quantity, limit)
+	g.register(model.SideTypeSell, entry, err)
+	return order, err
+}
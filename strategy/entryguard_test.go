@@ -0,0 +1,89 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// scriptedStrategy creates a market order with the given side for each candle
+type scriptedStrategy struct {
+	sides  []model.SideType
+	errors []error
+}
+
+func (s *scriptedStrategy) Timeframe() string {
+	return "1h"
+}
+
+func (s *scriptedStrategy) WarmupPeriod() int {
+	return 1
+}
+
+func (s *scriptedStrategy) Indicators(_ *model.Dataframe) []ChartIndicator {
+	return nil
+}
+
+func (s *scriptedStrategy) OnCandle(df *model.Dataframe, broker service.Broker) {
+	side := s.sides[len(s.errors)]
+	if side == "" {
+		s.errors = append(s.errors, nil)
+		return
+	}
+
+	_, err := broker.CreateOrderMarket(side, df.Pair, 1)
+	s.errors = append(s.errors, err)
+}
+
+func TestController_SetMinCandlesBetweenEntries(t *testing.T) {
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	strategy := &scriptedStrategy{
+		sides: []model.SideType{
+			model.SideTypeBuy,  // long entry
+			model.SideTypeBuy,  // blocked
+			model.SideTypeBuy,  // blocked
+			model.SideTypeBuy,  // allowed after 3 candles
+			"",                 // no signal
+			model.SideTypeSell, // exit
+			model.SideTypeSell, // exit
+			model.SideTypeSell, // short entry, not affected by the long entries
+			model.SideTypeSell, // blocked
+			model.SideTypeBuy,  // exit
+		},
+	}
+
+	controller := NewStrategyController("BTCUSDT", strategy, wallet)
+	controller.SetMinCandlesBetweenEntries(3)
+	controller.Start()
+
+	remaining := make([]int, 0)
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range strategy.sides {
+		candle := model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour), Close: 10,
+			Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		remaining = append(remaining, controller.CandlesUntilEntry(model.SideTypeBuy))
+	}
+
+	for i, err := range strategy.errors {
+		if i == 1 || i == 2 || i == 8 {
+			require.ErrorIs(t, err, ErrEntrySpacing, i)
+			continue
+		}
+		require.NoError(t, err, i)
+	}
+
+	require.Equal(t, []int{3, 2, 1, 3, 2, 1, 0, 0, 0, 0}, remaining)
+	require.Equal(t, 1, controller.CandlesUntilEntry(model.SideTypeSell))
+
+	asset, _, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, asset)
+}
@@ -1,6 +1,7 @@
 package strategy
 
 import (
+	"sync"
 	"time"
 
 	"github.com/rodrigo-brito/ninjabot/model"
@@ -30,3 +31,44 @@ type ChartIndicator struct {
 	GroupName string
 	Warmup    int
 }
+
+// IndicatorSpec declares a Dataframe.Metadata series to be plotted, without custom plotting code
+type IndicatorSpec struct {
+	Name    string      // name displayed in the chart
+	Source  string      // key in Dataframe.Metadata
+	Overlay bool        // true to draw over the candles, false for a separate panel
+	Color   string      // e.g. "red" or "#ff0000"
+	Style   MetricStyle // default: line
+	Warmup  int         // initial values ignored
+}
+
+var (
+	indicatorRegistryMtx sync.Mutex
+	indicatorRegistry    []IndicatorSpec
+)
+
+// RegisterIndicator adds an indicator to the registry used by the plot module.
+// A spec with the same name of a registered one replaces it.
+func RegisterIndicator(spec IndicatorSpec) {
+	indicatorRegistryMtx.Lock()
+	defer indicatorRegistryMtx.Unlock()
+
+	if spec.Style == "" {
+		spec.Style = StyleLine
+	}
+
+	for i, registered := range indicatorRegistry {
+		if registered.Name == spec.Name {
+			indicatorRegistry[i] = spec
+			return
+		}
+	}
+	indicatorRegistry = append(indicatorRegistry, spec)
+}
+
+// RegisteredIndicators returns the registered indicators in registration order
+func RegisteredIndicators() []IndicatorSpec {
+	indicatorRegistryMtx.Lock()
+	defer indicatorRegistryMtx.Unlock()
+	return append([]IndicatorSpec(nil), indicatorRegistry...)
+}
@@ -0,0 +1,87 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var ErrMinLiveCandles = errors.New("order blocked by minimum live candles")
+
+// liveGuard blocks all orders until a minimum number of candles is observed after the controller start,
+// so the strategy does not trade on preloaded data only, even when the indicators are ready
+type liveGuard struct {
+	brokerWrapper
+	minCandles int
+	candles    int
+}
+
+func (g *liveGuard) check(pair string) error {
+	if g.candles < g.minCandles {
+		return fmt.Errorf("%w: %d of %d candles observed for %s", ErrMinLiveCandles, g.candles,
+			g.minCandles, pair)
+	}
+	return nil
+}
+
+func (g *liveGuard) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return nil, err
+	}
+	return g.Broker.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
+}
+
+func (g *liveGuard) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.Broker.CreateOrderLimit(side, pair, size, limit)
+}
+
+func (g *liveGuard) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.Broker.CreateOrderMarket(side, pair, size)
+}
+
+func (g *liveGuard) CreateOrderMarketQuote(side model.SideType, pair string,
+	quote float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.Broker.CreateOrderMarketQuote(side, pair, quote)
+}
+
+func (g *liveGuard) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.Broker.CreateOrderStop(pair, quantity, limit)
+}
+
+func (g *liveGuard) CreateOrderLimitReduceOnly(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.brokerWrapper.CreateOrderLimitReduceOnly(side, pair, size, limit)
+}
+
+func (g *liveGuard) CreateOrderMarketReduceOnly(side model.SideType, pair string,
+	size float64) (model.Order, error) {
+	if err := g.check(pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.brokerWrapper.CreateOrderMarketReduceOnly(side, pair, size)
+}
+
+func (g *liveGuard) AmendOrder(order model.Order, price, quantity float64) (model.Order, error) {
+	if err := g.check(order.Pair); err != nil {
+		return model.Order{}, err
+	}
+	return g.brokerWrapper.AmendOrder(order, price, quantity)
+}
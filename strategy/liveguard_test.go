@@ -0,0 +1,54 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestController_SetMinLiveCandles(t *testing.T) {
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	strategy := &scriptedStrategy{
+		sides: []model.SideType{model.SideTypeBuy, model.SideTypeBuy, model.SideTypeSell},
+	}
+	controller := NewStrategyController("BTCUSDT", strategy, wallet)
+	controller.SetMinLiveCandles(2)
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candle := func(i int) model.Candle {
+		return model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour), Close: 10,
+			Complete: true}
+	}
+
+	// preloaded candles, the warmup period is ready but they are not live candles
+	for i := 0; i < 3; i++ {
+		wallet.OnCandle(candle(i))
+		controller.OnCandle(candle(i))
+	}
+	require.True(t, controller.WarmedUp())
+
+	controller.Start()
+	for i := 3; i < 6; i++ {
+		wallet.OnCandle(candle(i))
+		controller.OnCandle(candle(i))
+	}
+
+	require.Len(t, strategy.errors, 3)
+	require.ErrorIs(t, strategy.errors[0], ErrMinLiveCandles)
+	require.NoError(t, strategy.errors[1])
+	require.NoError(t, strategy.errors[2])
+
+	// only the orders after the minimum were placed
+	orders := 0
+	for id := int64(1); id <= 3; id++ {
+		if _, err := wallet.Order("BTCUSDT", id); err == nil {
+			orders++
+		}
+	}
+	require.Equal(t, 2, orders)
+}
@@ -0,0 +1,56 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var ErrLookahead = errors.New("lookahead detected")
+
+// boundDataframe returns a copy of the dataframe with the capacity of the series limited to the candles
+// available, so reading or re-slicing beyond the current candle panics instead of returning stale values.
+// The metadata map is shared, indicators registered by the strategy are kept in the original dataframe.
+func boundDataframe(df *model.Dataframe) *model.Dataframe {
+	bounded := *df
+	bounded.Close = df.Close[:len(df.Close):len(df.Close)]
+	bounded.Open = df.Open[:len(df.Open):len(df.Open)]
+	bounded.High = df.High[:len(df.High):len(df.High)]
+	bounded.Low = df.Low[:len(df.Low):len(df.Low)]
+	bounded.Volume = df.Volume[:len(df.Volume):len(df.Volume)]
+	bounded.Time = df.Time[:len(df.Time):len(df.Time)]
+	for key, values := range df.Metadata {
+		df.Metadata[key] = values[:len(values):len(values)]
+	}
+	return &bounded
+}
+
+// checkIndicators panics with ErrLookahead when an indicator has more values than the candles available,
+// the extra values can only come from future data
+func checkIndicators(df *model.Dataframe) {
+	for key, values := range df.Metadata {
+		if len(values) > len(df.Close) {
+			panic(fmt.Errorf("%w: %s indicator %s has %d values for %d candles at %s", ErrLookahead,
+				df.Pair, key, len(values), len(df.Close), df.Time[len(df.Time)-1]))
+		}
+	}
+}
+
+// recoverLookahead converts the out of range panics of the strategy, reading the dataframe beyond the
+// current candle, to an ErrLookahead panic. Other panics are propagated without changes.
+func recoverLookahead(df *model.Dataframe) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	var runtimeErr runtime.Error
+	if err, ok := r.(error); ok && errors.As(err, &runtimeErr) && strings.Contains(err.Error(), "out of range") {
+		panic(fmt.Errorf("%w: %s dataframe read beyond the candle of %s: %v", ErrLookahead, df.Pair,
+			df.Time[len(df.Time)-1], err))
+	}
+	panic(r)
+}
@@ -0,0 +1,81 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// cheatingStrategy peeks the next close, or registers an indicator with future values
+type cheatingStrategy struct {
+	future []float64
+}
+
+func (s *cheatingStrategy) Timeframe() string {
+	return "1h"
+}
+
+func (s *cheatingStrategy) WarmupPeriod() int {
+	return 3
+}
+
+func (s *cheatingStrategy) Indicators(df *model.Dataframe) []ChartIndicator {
+	if s.future != nil {
+		df.Metadata["future"] = s.future
+	}
+	return nil
+}
+
+func (s *cheatingStrategy) OnCandle(df *model.Dataframe, _ service.Broker) {
+	if s.future == nil {
+		next := df.Close[:len(df.Close)+1]
+		_ = next[len(next)-1]
+	}
+}
+
+func TestController_SetLookaheadGuard(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := func(str Strategy, guard bool) {
+		wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+		controller := NewStrategyController("BTCUSDT", str, wallet)
+		controller.SetLookaheadGuard(guard)
+		controller.Start()
+
+		// series with spare capacity, as grown by the dataframe updates
+		for i := 0; i < 10; i++ {
+			candle := model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour),
+				Close: float64(i), Complete: true}
+			wallet.OnCandle(candle)
+			controller.OnCandle(candle)
+		}
+	}
+
+	// panic returns the error of the panic in f
+	panicErr := func(f func()) (err error) {
+		defer func() {
+			err, _ = recover().(error)
+		}()
+		f()
+		return nil
+	}
+
+	t.Run("read beyond the current candle", func(t *testing.T) {
+		err := panicErr(func() { run(&cheatingStrategy{}, true) })
+		require.ErrorIs(t, err, ErrLookahead)
+		require.Contains(t, err.Error(), "dataframe read beyond the candle of 2021-01-01 02:00:00")
+	})
+
+	t.Run("indicator with future data", func(t *testing.T) {
+		err := panicErr(func() { run(&cheatingStrategy{future: make([]float64, 10)}, true) })
+		require.ErrorIs(t, err, ErrLookahead)
+		require.Contains(t, err.Error(), "indicator future has 10 values for 3 candles")
+
+		require.NoError(t, panicErr(func() { run(&cheatingStrategy{future: make([]float64, 10)}, false) }))
+	})
+}
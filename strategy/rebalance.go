@@ -0,0 +1,232 @@
+package strategy
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// rebalanceDust is the change of the value of a pair, as a fraction of the equity, ignored in a rebalance
+const rebalanceDust = 1e-6
+
+// RebalanceScore ranks a pair in a rebalance, higher scores first, e.g. the rate of change of the closes.
+// NaN excludes the pair from the allocation, e.g. before enough candles to calculate the score.
+type RebalanceScore func(df *model.Dataframe) float64
+
+// RebalanceSchedule returns true when a rebalance is due at the time of the candle, given the time of the
+// last rebalance, which is zero before the first one
+type RebalanceSchedule func(last, now time.Time) bool
+
+// RebalanceMonthly rebalances on the first candle of each month
+func RebalanceMonthly() RebalanceSchedule {
+	return func(last, now time.Time) bool {
+		return last.IsZero() || now.Year() != last.Year() || now.Month() != last.Month()
+	}
+}
+
+// RebalanceEvery rebalances when the interval has passed since the last rebalance
+func RebalanceEvery(interval time.Duration) RebalanceSchedule {
+	return func(last, now time.Time) bool {
+		return last.IsZero() || now.Sub(last) >= interval
+	}
+}
+
+// RebalanceAllocation is the result of a rebalance
+type RebalanceAllocation struct {
+	Time time.Time
+	// Equity is the value of the quote balance and the positions of the pairs before the rebalance
+	Equity float64
+	Scores map[string]float64
+	// Ranking is the pairs selected for the allocation, sorted by score
+	Ranking []string
+	// Weights is the target fraction of the equity of each pair, zero for the pairs not selected
+	Weights map[string]float64
+	// Turnover is the fraction of the equity traded in the rebalance, the larger of the values bought and sold,
+	// from 0 without trades to 1 when all positions are replaced
+	Turnover float64
+}
+
+// Rebalancer is a long-term allocation of the capital in the pairs, an alternative to the per-candle flow of
+// the strategies. In each rebalance of the schedule, e.g. monthly, it ranks the pairs by the score, allocates
+// equal weights to the top N pairs and creates the market orders to reach the target weights, sells first.
+// The rebalance waits for the candle of the same time of all pairs.
+type Rebalancer struct {
+	broker   service.Broker
+	pairs    []string
+	score    RebalanceScore
+	topN     int
+	schedule RebalanceSchedule
+
+	dataframes  map[string]*model.Dataframe
+	prices      map[string]float64
+	current     time.Time
+	received    map[string]bool
+	last        time.Time
+	allocations []RebalanceAllocation
+}
+
+// NewRebalancer creates a rebalancer of the pairs, see Rebalancer. The score receives the dataframe of the
+// complete candles of the pair.
+func NewRebalancer(broker service.Broker, pairs []string, score RebalanceScore, topN int,
+	schedule RebalanceSchedule) *Rebalancer {
+
+	dataframes := make(map[string]*model.Dataframe, len(pairs))
+	for _, pair := range pairs {
+		dataframes[pair] = &model.Dataframe{Pair: pair, Metadata: make(map[string]model.Series[float64])}
+	}
+
+	return &Rebalancer{
+		broker:     broker,
+		pairs:      pairs,
+		score:      score,
+		topN:       topN,
+		schedule:   schedule,
+		dataframes: dataframes,
+		prices:     make(map[string]float64, len(pairs)),
+		received:   make(map[string]bool, len(pairs)),
+	}
+}
+
+// Allocations returns the rebalances executed, in chronological order
+func (r *Rebalancer) Allocations() []RebalanceAllocation {
+	return r.allocations
+}
+
+// Turnover returns the sum of the turnover of the rebalances
+func (r *Rebalancer) Turnover() float64 {
+	var turnover float64
+	for _, allocation := range r.allocations {
+		turnover += allocation.Turnover
+	}
+	return turnover
+}
+
+// OnCandle registers a complete candle and rebalances when the candles of all pairs at its time are received
+func (r *Rebalancer) OnCandle(candle model.Candle) {
+	df, ok := r.dataframes[candle.Pair]
+	if !ok || !candle.Complete {
+		return
+	}
+
+	if !candle.Time.Equal(r.current) {
+		r.current = candle.Time
+		r.received = make(map[string]bool, len(r.pairs))
+	}
+
+	df.Close = append(df.Close, candle.Close)
+	df.Open = append(df.Open, candle.Open)
+	df.High = append(df.High, candle.High)
+	df.Low = append(df.Low, candle.Low)
+	df.Volume = append(df.Volume, candle.Volume)
+	df.Time = append(df.Time, candle.Time)
+	df.LastUpdate = candle.Time
+	r.prices[candle.Pair] = candle.Close
+	r.received[candle.Pair] = true
+
+	if len(r.received) == len(r.pairs) && r.schedule(r.last, candle.Time) {
+		r.rebalance(candle.Time)
+	}
+}
+
+// allocate returns the pairs with the top scores and their target weights
+func (r *Rebalancer) allocate(scores map[string]float64) ([]string, map[string]float64) {
+	ranking := make([]string, 0, len(scores))
+	for pair, score := range scores {
+		if !math.IsNaN(score) {
+			ranking = append(ranking, pair)
+		}
+	}
+	sort.Slice(ranking, func(i, j int) bool {
+		if scores[ranking[i]] == scores[ranking[j]] {
+			return ranking[i] < ranking[j]
+		}
+		return scores[ranking[i]] > scores[ranking[j]]
+	})
+	if len(ranking) > r.topN {
+		ranking = ranking[:r.topN]
+	}
+
+	weights := make(map[string]float64, len(r.pairs))
+	for _, pair := range r.pairs {
+		weights[pair] = 0
+	}
+	for _, pair := range ranking {
+		weights[pair] = 1 / float64(len(ranking))
+	}
+	return ranking, weights
+}
+
+func (r *Rebalancer) rebalance(now time.Time) {
+	scores := make(map[string]float64, len(r.pairs))
+	for _, pair := range r.pairs {
+		scores[pair] = r.score(r.dataframes[pair])
+	}
+
+	ranking, weights := r.allocate(scores)
+	if len(ranking) == 0 {
+		// e.g. warmup of the scores, retries in the next candle
+		return
+	}
+
+	var quote float64
+	positions := make(map[string]float64, len(r.pairs))
+	equity := 0.0
+	for _, pair := range r.pairs {
+		asset, quoteBalance, err := r.broker.Position(pair)
+		if err != nil {
+			log.Errorf("rebalance: position of %s: %v", pair, err)
+			return
+		}
+		positions[pair] = asset
+		quote = quoteBalance
+		equity += asset * r.prices[pair]
+	}
+	equity += quote
+
+	if equity <= 0 {
+		return
+	}
+
+	allocation := RebalanceAllocation{
+		Time:    now,
+		Equity:  equity,
+		Scores:  scores,
+		Ranking: ranking,
+		Weights: weights,
+	}
+
+	// sells first, to release the quote balance of the buys
+	traded := make(map[model.SideType]float64, 2)
+	for _, side := range []model.SideType{model.SideTypeSell, model.SideTypeBuy} {
+		for _, pair := range r.pairs {
+			price := r.prices[pair]
+			delta := weights[pair]*equity - positions[pair]*price
+			if math.Abs(delta) <= rebalanceDust*equity || (delta < 0) != (side == model.SideTypeSell) {
+				continue
+			}
+
+			quantity := math.Abs(delta) / price
+			if side == model.SideTypeSell {
+				quantity = math.Min(quantity, positions[pair])
+			} else if _, quote, err := r.broker.Position(pair); err == nil {
+				// fees of the sells can reduce the quote balance
+				quantity = math.Min(quantity, quote/price)
+			}
+
+			if _, err := r.broker.CreateOrderMarket(side, pair, quantity); err != nil {
+				log.Errorf("rebalance: %s %s: %v", side, pair, err)
+				continue
+			}
+			traded[side] += quantity * price
+		}
+	}
+	allocation.Turnover = math.Max(traded[model.SideTypeBuy], traded[model.SideTypeSell]) / equity
+
+	r.last = now
+	r.allocations = append(r.allocations, allocation)
+}
@@ -0,0 +1,74 @@
+package strategy
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestRebalancer(t *testing.T) {
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	pairs := []string{"BTCUSDT", "ETHUSDT", "BNBUSDT"}
+
+	// rate of change since the first candle
+	roc := func(df *model.Dataframe) float64 {
+		if len(df.Close) < 2 {
+			return math.NaN()
+		}
+		return df.Close.Last(0)/df.Close[0] - 1
+	}
+	rebalancer := NewRebalancer(wallet, pairs, roc, 2, RebalanceMonthly())
+
+	times := []time.Time{
+		time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	closes := map[string][]float64{
+		"BTCUSDT": {10, 12, 12, 11},
+		"ETHUSDT": {10, 11, 11, 15},
+		"BNBUSDT": {10, 9, 9, 14},
+	}
+	for i, candleTime := range times {
+		for _, pair := range pairs {
+			candle := model.Candle{Pair: pair, Time: candleTime, Close: closes[pair][i], Complete: true}
+			wallet.OnCandle(candle)
+			rebalancer.OnCandle(candle)
+		}
+	}
+
+	// the first candle has no score, the first rebalance is in the next candle
+	allocations := rebalancer.Allocations()
+	require.Len(t, allocations, 2)
+
+	// ranking of Jan 15: BTC 20%, ETH 10%, BNB -10%
+	require.Equal(t, times[1], allocations[0].Time)
+	require.Equal(t, []string{"BTCUSDT", "ETHUSDT"}, allocations[0].Ranking)
+	require.Equal(t, map[string]float64{"BTCUSDT": 0.5, "ETHUSDT": 0.5, "BNBUSDT": 0}, allocations[0].Weights)
+	require.InDelta(t, 1000, allocations[0].Equity, 1e-6)
+	require.InDelta(t, 1, allocations[0].Turnover, 1e-6)
+
+	// ranking of Feb 1: ETH 50%, BNB 40%, BTC 10%
+	require.Equal(t, times[3], allocations[1].Time)
+	require.Equal(t, []string{"ETHUSDT", "BNBUSDT"}, allocations[1].Ranking)
+	require.Equal(t, map[string]float64{"BTCUSDT": 0, "ETHUSDT": 0.5, "BNBUSDT": 0.5}, allocations[1].Weights)
+
+	// BTC 500 / 12 * 11 + ETH 500 / 11 * 15
+	equity := 500.0/12*11 + 500.0/11*15
+	require.InDelta(t, equity, allocations[1].Equity, 1e-6)
+	require.InDelta(t, 0.5, allocations[1].Turnover, 1e-6)
+	require.InDelta(t, 1.5, rebalancer.Turnover(), 1e-6)
+
+	for pair, weight := range map[string]float64{"BTCUSDT": 0, "ETHUSDT": 0.5, "BNBUSDT": 0.5} {
+		asset, _, err := wallet.Position(pair)
+		require.NoError(t, err)
+		require.InDelta(t, weight*equity, asset*closes[pair][3], 1e-6, pair)
+	}
+}
@@ -0,0 +1,111 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var ErrSignalOnly = errors.New("order not executed in signal-only mode")
+
+// SignalReasoner is implemented by strategies that describe the reason of their orders in signal-only mode,
+// e.g. the indicator crossing that triggered the order. Without it, the reason describes the order.
+type SignalReasoner interface {
+	SignalReason(df *model.Dataframe, side model.SideType) string
+}
+
+// signalBroker emits the orders of the strategy as signals and rejects them with ErrSignalOnly, the account
+// and positions are read from the given broker. Simulations are not submitted, they are estimated by the broker.
+type signalBroker struct {
+	brokerWrapper
+	strategy Strategy
+	emit     func(model.Signal)
+
+	// candle and dataframe of the current strategy execution
+	candle    model.Candle
+	dataframe *model.Dataframe
+}
+
+func (b *signalBroker) signal(orderType model.OrderType, side model.SideType, pair string, size, price,
+	stop float64) error {
+	if price == 0 {
+		price = b.candle.Close
+	}
+
+	reason := fmt.Sprintf("%s %s order", side, orderType)
+	if reasoner, ok := b.strategy.(SignalReasoner); ok && b.dataframe != nil {
+		reason = reasoner.SignalReason(b.dataframe, side)
+	}
+
+	var indicators map[string]float64
+	if b.dataframe != nil && len(b.dataframe.Metadata) > 0 {
+		indicators = indicatorValues(b.dataframe)
+	}
+
+	b.emit(model.Signal{
+		Time:       b.candle.Time,
+		Pair:       pair,
+		Side:       side,
+		Type:       orderType,
+		Size:       size,
+		Price:      price,
+		Stop:       stop,
+		Reason:     reason,
+		Indicators: indicators,
+	})
+	return fmt.Errorf("%w: %s %s %s", ErrSignalOnly, orderType, side, pair)
+}
+
+func (b *signalBroker) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	_ float64) ([]model.Order, error) {
+	return nil, b.signal("OCO", side, pair, size, price, stop)
+}
+
+func (b *signalBroker) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	return model.Order{}, b.signal(model.OrderTypeLimit, side, pair, size, limit, 0)
+}
+
+func (b *signalBroker) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	return model.Order{}, b.signal(model.OrderTypeMarket, side, pair, size, 0, 0)
+}
+
+func (b *signalBroker) CreateOrderMarketQuote(side model.SideType, pair string,
+	quote float64) (model.Order, error) {
+	var size float64
+	if b.candle.Close > 0 {
+		size = quote / b.candle.Close
+	}
+	return model.Order{}, b.signal(model.OrderTypeMarket, side, pair, size, 0, 0)
+}
+
+func (b *signalBroker) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	return model.Order{}, b.signal(model.OrderTypeStopLoss, model.SideTypeSell, pair, quantity, 0, limit)
+}
+
+func (b *signalBroker) CreateOrderLimitReduceOnly(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	return model.Order{}, b.signal(model.OrderTypeLimit, side, pair, size, limit, 0)
+}
+
+func (b *signalBroker) CreateOrderMarketReduceOnly(side model.SideType, pair string,
+	size float64) (model.Order, error) {
+	return model.Order{}, b.signal(model.OrderTypeMarket, side, pair, size, 0, 0)
+}
+
+// AmendOrder emits the new price and quantity of the order as a limit order signal, a zero value keeps the
+// current price or quantity
+func (b *signalBroker) AmendOrder(order model.Order, price, quantity float64) (model.Order, error) {
+	if price == 0 {
+		price = order.Price
+	}
+	if quantity == 0 {
+		quantity = order.Quantity
+	}
+	return model.Order{}, b.signal(model.OrderTypeLimit, order.Side, order.Pair, quantity, price, 0)
+}
+
+func (b *signalBroker) Cancel(order model.Order) error {
+	return fmt.Errorf("%w: cancel %s", ErrSignalOnly, order.Pair)
+}
@@ -0,0 +1,119 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+type reasonedStrategy struct {
+	*scriptedStrategy
+}
+
+func (s reasonedStrategy) SignalReason(df *model.Dataframe, side model.SideType) string {
+	return fmt.Sprintf("%s at %.2f", side, df.Close.Last(0))
+}
+
+// indicatorStrategy is a scripted strategy with an indicator of the close
+type indicatorStrategy struct {
+	*scriptedStrategy
+}
+
+func (s indicatorStrategy) Indicators(df *model.Dataframe) []ChartIndicator {
+	df.Metadata["double"] = model.Series[float64]{df.Close.Last(0) * 2}
+	return nil
+}
+
+func TestController_SetSignalOnly(t *testing.T) {
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	strategy := &scriptedStrategy{
+		sides: []model.SideType{model.SideTypeBuy, "", model.SideTypeSell},
+	}
+
+	var signals []model.Signal
+	controller := NewStrategyController("BTCUSDT", strategy, wallet)
+	controller.SetSignalOnly(func(signal model.Signal) {
+		signals = append(signals, signal)
+	})
+	controller.Start()
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		candle := model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour),
+			Close: float64(10 + i), Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	require.Equal(t, []model.Signal{
+		{Time: start, Pair: "BTCUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeMarket, Size: 1, Price: 10,
+			Reason: "BUY MARKET order"},
+		{Time: start.Add(2 * time.Hour), Pair: "BTCUSDT", Side: model.SideTypeSell, Type: model.OrderTypeMarket,
+			Size: 1, Price: 12, Reason: "SELL MARKET order"},
+	}, signals)
+	require.ErrorIs(t, strategy.errors[0], ErrSignalOnly)
+	require.ErrorIs(t, strategy.errors[2], ErrSignalOnly)
+
+	// no orders placed in the broker
+	_, err := wallet.Order("BTCUSDT", 1)
+	require.Error(t, err)
+	asset, quote, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, asset)
+	require.Equal(t, 1000.0, quote)
+
+	t.Run("amend", func(t *testing.T) {
+		var signals []model.Signal
+		broker := &signalBroker{brokerWrapper: brokerWrapper{wallet}, strategy: strategy,
+			emit: func(signal model.Signal) {
+				signals = append(signals, signal)
+			}}
+		broker.candle = model.Candle{Pair: "BTCUSDT", Time: start, Close: 10}
+
+		amender, ok := service.Broker(broker).(service.OrderAmender)
+		require.True(t, ok)
+		order := model.Order{Pair: "BTCUSDT", Side: model.SideTypeBuy, Type: model.OrderTypeLimit, Price: 9,
+			Quantity: 2}
+		_, err := amender.AmendOrder(order, 8, 0)
+		require.ErrorIs(t, err, ErrSignalOnly)
+		require.Equal(t, []model.Signal{{Time: start, Pair: "BTCUSDT", Side: model.SideTypeBuy,
+			Type: model.OrderTypeLimit, Size: 2, Price: 8, Reason: "BUY LIMIT order"}}, signals)
+	})
+
+	t.Run("reason", func(t *testing.T) {
+		var signals []model.Signal
+		strategy := reasonedStrategy{&scriptedStrategy{sides: []model.SideType{model.SideTypeBuy}}}
+		controller := NewStrategyController("BTCUSDT", strategy, wallet)
+		controller.SetSignalOnly(func(signal model.Signal) {
+			signals = append(signals, signal)
+		})
+		controller.Start()
+		controller.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start, Close: 10, Complete: true})
+
+		require.Len(t, signals, 1)
+		require.Equal(t, "BUY at 10.00", signals[0].Reason)
+	})
+
+	t.Run("indicators", func(t *testing.T) {
+		var signals []model.Signal
+		strategy := indicatorStrategy{&scriptedStrategy{sides: []model.SideType{model.SideTypeBuy}}}
+		controller := NewStrategyController("BTCUSDT", strategy, wallet)
+		controller.SetSignalOnly(func(signal model.Signal) {
+			signals = append(signals, signal)
+		})
+		controller.Start()
+		controller.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start, Close: 10, Complete: true})
+
+		require.Len(t, signals, 1)
+		require.Equal(t, map[string]float64{"double": 20}, signals[0].Indicators)
+		_, err := wallet.Order("BTCUSDT", 1)
+		require.Error(t, err)
+	})
+}
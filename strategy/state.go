@@ -0,0 +1,75 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+// Stateful is implemented by strategies with state that must survive a restart, e.g. grid levels or trailing
+// references. The bot saves the state in the storage on shutdown and loads it on startup, before the warmup.
+type Stateful interface {
+	SaveState() ([]byte, error)
+	LoadState(state []byte) error
+}
+
+// JSONState implements Stateful with the JSON encoding of Value, which must be a pointer to the state. Embed it
+// in the strategy, e.g.:
+//
+//	type Grid struct {
+//		strategy.JSONState
+//		Levels []float64
+//	}
+//
+//	grid := &Grid{}
+//	grid.Value = &grid.Levels
+type JSONState struct {
+	Value interface{}
+}
+
+func (s JSONState) SaveState() ([]byte, error) {
+	return json.Marshal(s.Value)
+}
+
+func (s JSONState) LoadState(state []byte) error {
+	return json.Unmarshal(state, s.Value)
+}
+
+// stateKey identifies the state of the strategy in the storage
+func stateKey(str Strategy) string {
+	return fmt.Sprintf("%T", str)
+}
+
+// SaveState persists the state of a Stateful strategy, other strategies are ignored
+func SaveState(str Strategy, store storage.StateStorage) error {
+	stateful, ok := str.(Stateful)
+	if !ok {
+		return nil
+	}
+
+	state, err := stateful.SaveState()
+	if err != nil {
+		return fmt.Errorf("save strategy state: %w", err)
+	}
+	return store.SaveStrategyState(stateKey(str), state)
+}
+
+// LoadState restores the last state saved of a Stateful strategy, it returns false when the strategy is not
+// Stateful or there is no state saved, e.g. in the first run
+func LoadState(str Strategy, store storage.StateStorage) (bool, error) {
+	stateful, ok := str.(Stateful)
+	if !ok {
+		return false, nil
+	}
+
+	state, err := store.StrategyState(stateKey(str))
+	if err != nil || state == nil {
+		return false, err
+	}
+
+	if err := stateful.LoadState(state); err != nil {
+		return false, fmt.Errorf("load strategy state: %w", err)
+	}
+	return true, nil
+}
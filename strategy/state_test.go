@@ -0,0 +1,62 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/storage"
+)
+
+type gridState struct {
+	Levels    []float64 `json:"levels"`
+	Reference float64   `json:"reference"`
+}
+
+type gridStrategy struct {
+	*scriptedStrategy
+	JSONState
+	grid gridState
+}
+
+func newGridStrategy() *gridStrategy {
+	strategy := &gridStrategy{scriptedStrategy: &scriptedStrategy{}}
+	strategy.Value = &strategy.grid
+	return strategy
+}
+
+func TestLoadState(t *testing.T) {
+	repo, err := storage.FromMemory()
+	require.NoError(t, err)
+	store := repo.(storage.StateStorage)
+
+	// first run, there is no state saved
+	strategy := newGridStrategy()
+	loaded, err := LoadState(strategy, store)
+	require.NoError(t, err)
+	require.False(t, loaded)
+
+	strategy.grid.Levels = []float64{100, 110, 120}
+	strategy.grid.Reference = 115
+	require.NoError(t, SaveState(strategy, store))
+
+	// restart, the new instance restores the state of the previous run
+	restarted := newGridStrategy()
+	loaded, err = LoadState(restarted, store)
+	require.NoError(t, err)
+	require.True(t, loaded)
+	require.Equal(t, gridState{Levels: []float64{100, 110, 120}, Reference: 115}, restarted.grid)
+
+	t.Run("stateless strategy", func(t *testing.T) {
+		require.NoError(t, SaveState(&scriptedStrategy{}, store))
+		loaded, err := LoadState(&scriptedStrategy{}, store)
+		require.NoError(t, err)
+		require.False(t, loaded)
+	})
+
+	t.Run("invalid state", func(t *testing.T) {
+		require.NoError(t, store.SaveStrategyState(stateKey(strategy), []byte("{")))
+		_, err := LoadState(newGridStrategy(), store)
+		require.Error(t, err)
+	})
+}
@@ -14,7 +14,7 @@ type Strategy interface {
 	// Indicators will be executed for each new candle, in order to fill indicators before `OnCandle` function is called.
 	Indicators(df *model.Dataframe) []ChartIndicator
 	// OnCandle will be executed for each new candle, after indicators are filled, here you can do your trading logic.
-	// OnCandle is executed after the candle close.
+	// OnCandle is executed after the candle close, or on the open of the next candle with SignalOnOpen.
 	OnCandle(df *model.Dataframe, broker service.Broker)
 }
 
@@ -24,3 +24,38 @@ type HighFrequencyStrategy interface {
 	// OnPartialCandle will be executed for each new partial candle, after indicators are filled.
 	OnPartialCandle(df *model.Dataframe, broker service.Broker)
 }
+
+// CandleCloseStrategy is notified when a candle finalizes, to separate the bar close logic from the intrabar
+// logic of OnPartialCandle.
+type CandleCloseStrategy interface {
+	Strategy
+
+	// OnCandleClose will be executed once per candle, when the complete candle is received, after the
+	// dataframe is updated and before OnCandle. Duplicated or revised complete candles are ignored.
+	OnCandleClose(candle model.Candle)
+}
+
+// CrossExchangeStrategy receives the prices of the pair in several exchanges, e.g. for arbitrage signals,
+// see exchange.CrossExchangeFeed
+type CrossExchangeStrategy interface {
+	Strategy
+
+	// OnCrossPrice will be executed for each update of the price of the pair in any of the exchanges. It runs
+	// in the goroutine of the feed, concurrently with OnCandle.
+	OnCrossPrice(price model.CrossPrice)
+}
+
+// SignalTiming defines when the strategy OnCandle function is executed
+type SignalTiming int
+
+const (
+	// SignalOnClose executes OnCandle as soon as the candle closes (default).
+	SignalOnClose SignalTiming = iota
+	// SignalOnOpen executes OnCandle when the next candle opens, i.e. on its first partial candle,
+	// with the same dataframe and indicators calculated at the close of the previous candle.
+	// The last value of the dataframe is still the closed candle, the new candle is not visible yet.
+	// Live orders are created at the open price of the new candle. In backtests the bot simulates this
+	// moment with a partial candle at the open price before processing the full candle, the paper wallet
+	// is not updated by it, so market orders are executed at the close of the previous candle.
+	SignalOnOpen
+)
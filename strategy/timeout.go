@@ -0,0 +1,197 @@
+package strategy
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+var ErrStrategyTimeout = errors.New("broker call after the strategy timeout")
+
+// watchdog limits the execution time of the strategy OnCandle, so a blocked strategy does not stall the bot.
+// The execution is abandoned after the timeout, the candle is skipped, and the strategy is disabled after
+// the given number of consecutive timeouts, when greater than zero.
+type watchdog struct {
+	timeout     time.Duration
+	maxTimeouts int
+	timeouts    int
+	disabled    bool
+	// running is closed when the last execution returns, it is still open after a timeout
+	running chan struct{}
+}
+
+// run executes fn within the timeout with a copy of the dataframe and a broker that rejects all calls with
+// ErrStrategyTimeout after the timeout, so an abandoned execution does not read the candles of the next
+// executions nor creates orders. Panics of fn are propagated to the caller, e.g. ErrLookahead.
+func (w *watchdog) run(df *model.Dataframe, broker service.Broker, fn func(*model.Dataframe, service.Broker)) {
+	if w.disabled {
+		return
+	}
+
+	if w.running != nil {
+		select {
+		case <-w.running:
+		default:
+			log.Errorf("strategy timeout: %s candle of %s skipped, last execution still running", df.Pair,
+				df.Time[len(df.Time)-1])
+			w.timedOut(df)
+			return
+		}
+	}
+
+	clone := df.Clone()
+	deadline := &timeoutBroker{brokerWrapper: brokerWrapper{broker}}
+	done := make(chan struct{})
+	panics := make(chan interface{}, 1)
+	w.running = done
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				panics <- r
+			}
+		}()
+		fn(&clone, deadline)
+	}()
+
+	timer := time.NewTimer(w.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		w.timeouts = 0
+		select {
+		case r := <-panics:
+			panic(r)
+		default:
+		}
+	case <-timer.C:
+		atomic.StoreInt32(&deadline.expired, 1)
+		log.Errorf("strategy timeout: %s candle of %s skipped, OnCandle exceeded %s", df.Pair,
+			df.Time[len(df.Time)-1], w.timeout)
+		w.timedOut(df)
+	}
+}
+
+func (w *watchdog) timedOut(df *model.Dataframe) {
+	w.timeouts++
+	if w.maxTimeouts > 0 && w.timeouts >= w.maxTimeouts {
+		w.disabled = true
+		log.Errorf("strategy timeout: %s strategy disabled after %d consecutive timeouts", df.Pair, w.timeouts)
+	}
+}
+
+// timeoutBroker rejects all calls of the strategy after the timeout of the execution, see watchdog
+type timeoutBroker struct {
+	brokerWrapper
+	expired int32
+}
+
+func (t *timeoutBroker) check() error {
+	if atomic.LoadInt32(&t.expired) == 1 {
+		return ErrStrategyTimeout
+	}
+	return nil
+}
+
+func (t *timeoutBroker) Account() (model.Account, error) {
+	if err := t.check(); err != nil {
+		return model.Account{}, err
+	}
+	return t.Broker.Account()
+}
+
+func (t *timeoutBroker) Position(pair string) (asset, quote float64, err error) {
+	if err := t.check(); err != nil {
+		return 0, 0, err
+	}
+	return t.Broker.Position(pair)
+}
+
+func (t *timeoutBroker) Order(pair string, id int64) (model.Order, error) {
+	if err := t.check(); err != nil {
+		return model.Order{}, err
+	}
+	return t.Broker.Order(pair, id)
+}
+
+func (t *timeoutBroker) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	return t.Broker.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
+}
+
+func (t *timeoutBroker) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	if err := t.check(); err != nil {
+		return model.Order{}, err
+	}
+	return t.Broker.CreateOrderLimit(side, pair, size, limit)
+}
+
+func (t *timeoutBroker) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	if err := t.check(); err != nil {
+		return model.Order{}, err
+	}
+	return t.Broker.CreateOrderMarket(side, pair, size)
+}
+
+func (t *timeoutBroker) CreateOrderMarketQuote(side model.SideType, pair string, quote float64) (model.Order,
+	error) {
+	if err := t.check(); err != nil {
+		return model.Order{}, err
+	}
+	return t.Broker.CreateOrderMarketQuote(side, pair, quote)
+}
+
+func (t *timeoutBroker) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	if err := t.check(); err != nil {
+		return model.Order{}, err
+	}
+	return t.Broker.CreateOrderStop(pair, quantity, limit)
+}
+
+func (t *timeoutBroker) Cancel(order model.Order) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+	return t.Broker.Cancel(order)
+}
+
+func (t *timeoutBroker) CreateOrderLimitReduceOnly(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	if err := t.check(); err != nil {
+		return model.Order{}, err
+	}
+	return t.brokerWrapper.CreateOrderLimitReduceOnly(side, pair, size, limit)
+}
+
+func (t *timeoutBroker) CreateOrderMarketReduceOnly(side model.SideType, pair string,
+	size float64) (model.Order, error) {
+	if err := t.check(); err != nil {
+		return model.Order{}, err
+	}
+	return t.brokerWrapper.CreateOrderMarketReduceOnly(side, pair, size)
+}
+
+func (t *timeoutBroker) AmendOrder(order model.Order, price, quantity float64) (model.Order, error) {
+	if err := t.check(); err != nil {
+		return model.Order{}, err
+	}
+	return t.brokerWrapper.AmendOrder(order, price, quantity)
+}
+
+func (t *timeoutBroker) SimulateOrder(pair string, side model.SideType, quantity,
+	price float64) (model.OrderPreview, error) {
+	if err := t.check(); err != nil {
+		return model.OrderPreview{}, err
+	}
+	return t.brokerWrapper.SimulateOrder(pair, side, quantity, price)
+}
@@ -0,0 +1,126 @@
+package strategy
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+// slowStrategy blocks in OnCandle until released, for the candles of the slow closes
+type slowStrategy struct {
+	slow    map[float64]bool
+	release chan struct{}
+	errors  chan error
+	calls   int32
+}
+
+func (s *slowStrategy) Timeframe() string {
+	return "1h"
+}
+
+func (s *slowStrategy) WarmupPeriod() int {
+	return 1
+}
+
+func (s *slowStrategy) Indicators(_ *model.Dataframe) []ChartIndicator {
+	return nil
+}
+
+func (s *slowStrategy) OnCandle(df *model.Dataframe, broker service.Broker) {
+	atomic.AddInt32(&s.calls, 1)
+	if s.slow[df.Close.Last(0)] {
+		<-s.release
+		// late changes of the abandoned execution
+		df.Close[len(df.Close)-1] = 0
+		_, err := broker.CreateOrderMarket(model.SideTypeBuy, df.Pair, 1)
+		s.errors <- err
+	}
+}
+
+func TestController_SetTimeout(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candle := func(i int, price float64) model.Candle {
+		return model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour), Close: price,
+			Complete: true}
+	}
+
+	t.Run("skip slow candle", func(t *testing.T) {
+		wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+		strategy := &slowStrategy{slow: map[float64]bool{2: true}, release: make(chan struct{}),
+			errors: make(chan error, 1)}
+		controller := NewStrategyController("BTCUSDT", strategy, wallet)
+		controller.SetTimeout(50*time.Millisecond, 0)
+		controller.Start()
+
+		controller.OnCandle(candle(0, 1))
+		controller.OnCandle(candle(1, 2))
+		require.Equal(t, int32(2), atomic.LoadInt32(&strategy.calls))
+
+		// the last execution is still running, the candle is skipped by the strategy
+		controller.OnCandle(candle(2, 3))
+		require.Equal(t, int32(2), atomic.LoadInt32(&strategy.calls))
+
+		// the skipped candles still update the dataframe
+		require.Equal(t, []float64{1, 2, 3}, controller.dataframe.Close.Values())
+
+		// the abandoned execution has a copy of the candles and can not create orders
+		close(strategy.release)
+		require.ErrorIs(t, <-strategy.errors, ErrStrategyTimeout)
+		require.Equal(t, []float64{1, 2, 3}, controller.dataframe.Close.Values())
+
+		require.Eventually(t, func() bool {
+			controller.OnCandle(candle(3, 4))
+			return atomic.LoadInt32(&strategy.calls) == 3
+		}, time.Second, 10*time.Millisecond)
+		require.False(t, controller.Disabled())
+	})
+
+	t.Run("disable after repeated timeouts", func(t *testing.T) {
+		wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+		strategy := &slowStrategy{slow: map[float64]bool{1: true}, release: make(chan struct{}),
+			errors: make(chan error, 1)}
+		defer close(strategy.release)
+
+		controller := NewStrategyController("BTCUSDT", strategy, wallet)
+		controller.SetTimeout(10*time.Millisecond, 2)
+		controller.Start()
+
+		controller.OnCandle(candle(0, 1))
+		require.False(t, controller.Disabled())
+		controller.OnCandle(candle(1, 2))
+		require.True(t, controller.Disabled())
+
+		controller.OnCandle(candle(2, 3))
+		require.Equal(t, int32(1), atomic.LoadInt32(&strategy.calls))
+		require.Equal(t, []float64{1, 2, 3}, controller.dataframe.Close.Values())
+	})
+}
+
+func TestTimeoutBroker(t *testing.T) {
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Close: 100})
+	deadline := &timeoutBroker{brokerWrapper: brokerWrapper{wallet}}
+
+	// the optional interfaces of the broker are kept
+	reduceOnly, ok := service.Broker(deadline).(service.ReduceOnlyBroker)
+	amender, ok := service.Broker(deadline).(service.OrderAmender)
+	require.True(t, ok)
+
+	order, err := deadline.CreateOrderLimit(model.SideTypeBuy, "BTCUSDT", 1, 90)
+	require.NoError(t, err)
+	_, err = amender.AmendOrder(order, 95, 1)
+	require.NoError(t, err)
+
+	atomic.StoreInt32(&deadline.expired, 1)
+	_, err = amender.AmendOrder(order, 90, 1)
+	require.ErrorIs(t, err, ErrStrategyTimeout)
+	_, err = reduceOnly.CreateOrderMarketReduceOnly(model.SideTypeSell, "BTCUSDT", 1)
+	require.ErrorIs(t, err, ErrStrategyTimeout)
+}
@@ -0,0 +1,432 @@
+package strategy
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+type TradeEvent string
+
+const (
+	// TradeEventEntry is an order that opens or increases a position
+	TradeEventEntry TradeEvent = "entry"
+	// TradeEventExit is an order that reduces or closes a position
+	TradeEventExit TradeEvent = "exit"
+)
+
+// TradeLogEntry is a snapshot of an order with the candle and indicator values that triggered it
+type TradeLogEntry struct {
+	Time    time.Time             `json:"time"`
+	Event   TradeEvent            `json:"event"`
+	OrderID int64                 `json:"order_id"`
+	Pair    string                `json:"pair"`
+	Side    model.SideType        `json:"side"`
+	Type    model.OrderType       `json:"type"`
+	Status  model.OrderStatusType `json:"status"`
+	// Filled is true when the entry registers the order execution
+	Filled   bool    `json:"filled"`
+	Quantity float64 `json:"quantity"`
+	Price    float64 `json:"price"`
+	Error    string  `json:"error,omitempty"`
+
+	CandleTime time.Time          `json:"candle_time"`
+	Open       float64            `json:"open"`
+	High       float64            `json:"high"`
+	Low        float64            `json:"low"`
+	Close      float64            `json:"close"`
+	Volume     float64            `json:"volume"`
+	Indicators map[string]float64 `json:"indicators"`
+
+	// balance of the pair after the order
+	AssetBalance float64 `json:"asset_balance"`
+	QuoteBalance float64 `json:"quote_balance"`
+
+	// fee and modeled slippage cost of the fill, in the quote asset. Fees charged in other assets, e.g. BNB,
+	// are not included, see model.Order.AssetFee.
+	Fee      float64 `json:"fee"`
+	Slippage float64 `json:"slippage"`
+
+	// profit attribution of the quantity closed by an exit fill, including the costs of the entries.
+	// GrossProfit is the profit without friction, so GrossProfit - TradeFees - TradeSlippage = NetProfit.
+	GrossProfit   float64 `json:"gross_profit"`
+	TradeFees     float64 `json:"trade_fees"`
+	TradeSlippage float64 `json:"trade_slippage"`
+	NetProfit     float64 `json:"net_profit"`
+}
+
+// tradePosition is the net position of a pair built from the registered fills
+type tradePosition struct {
+	// quantity is positive for long positions and negative for short positions
+	quantity float64
+	// average entry price and costs per unit of the open quantity
+	price    float64
+	fee      float64
+	slippage float64
+}
+
+// fill updates the position with an executed order and returns the attribution of the closed quantity
+func (p *tradePosition) fill(order model.Order, price float64) (gross, fees, slippage, net float64) {
+	quantity := order.Quantity
+	if order.Side == model.SideTypeSell {
+		quantity = -quantity
+	}
+
+	if p.quantity*quantity < 0 {
+		closed := math.Min(math.Abs(p.quantity), math.Abs(quantity))
+		share := closed / order.Quantity
+		direction := 1.0
+		if p.quantity < 0 {
+			direction = -1
+		}
+
+		// the fill price already includes the slippage, added back to get the gross profit
+		profit := (price - p.price) * closed * direction
+		fees = p.fee*closed + order.Fee*share
+		slippage = p.slippage*closed + order.Slippage*share
+		net = profit - fees
+		gross = profit + slippage
+
+		p.quantity += math.Copysign(closed, quantity)
+		quantity -= math.Copysign(closed, quantity)
+	}
+
+	if quantity == 0 {
+		return gross, fees, slippage, net
+	}
+
+	// remaining quantity opens or increases the position, with costs averaged by unit
+	remaining := math.Abs(quantity)
+	share := remaining / order.Quantity
+	current := math.Abs(p.quantity)
+	total := current + remaining
+	p.price = (p.price*current + price*remaining) / total
+	p.fee = (p.fee*current + order.Fee*share) / total
+	p.slippage = (p.slippage*current + order.Slippage*share) / total
+	p.quantity += quantity
+
+	return gross, fees, slippage, net
+}
+
+type tradeContext struct {
+	candle     model.Candle
+	indicators map[string]float64
+}
+
+// TradeLog records every order submitted by a strategy, the candle it fired on, the indicator
+// values (from Dataframe.Metadata) at that moment, the fill and the resulting balance.
+// It copies the indicators for each order, so it is intended for debugging backtests.
+type TradeLog struct {
+	mtx       sync.Mutex
+	entries   []TradeLogEntry
+	positions map[string]*tradePosition
+	pending   map[int64]TradeEvent
+	context   map[string]tradeContext
+	broker    service.Broker
+}
+
+func NewTradeLog() *TradeLog {
+	return &TradeLog{
+		positions: make(map[string]*tradePosition),
+		pending:   make(map[int64]TradeEvent),
+		context:   make(map[string]tradeContext),
+	}
+}
+
+// Entries returns a copy of the registered entries in chronological order
+func (t *TradeLog) Entries() []TradeLogEntry {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return append([]TradeLogEntry(nil), t.entries...)
+}
+
+func (t *TradeLog) setContext(candle model.Candle, df *model.Dataframe) {
+	indicators := indicatorValues(df)
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.context[candle.Pair] = tradeContext{candle: candle, indicators: indicators}
+}
+
+// indicatorValues returns the last value of each indicator of the dataframe (Metadata), without the
+// indicators not defined yet (NaN or infinite), e.g. during the warmup period
+func indicatorValues(df *model.Dataframe) map[string]float64 {
+	indicators := make(map[string]float64, len(df.Metadata))
+	for key, series := range df.Metadata {
+		if len(series) > 0 && isFinite(series.Last(0)) {
+			indicators[key] = series.Last(0)
+		}
+	}
+	return indicators
+}
+
+// event classifies an order as entry or exit based on the current net position of the pair
+func (t *TradeLog) event(pair string, side model.SideType) TradeEvent {
+	var position float64
+	if t.positions[pair] != nil {
+		position = t.positions[pair].quantity
+	}
+	if (side == model.SideTypeBuy && position < 0) || (side == model.SideTypeSell && position > 0) {
+		return TradeEventExit
+	}
+	return TradeEventEntry
+}
+
+func (t *TradeLog) register(event TradeEvent, order model.Order, err error) {
+	context := t.context[order.Pair]
+	entry := TradeLogEntry{
+		Time:       context.candle.Time,
+		Event:      event,
+		OrderID:    order.ExchangeID,
+		Pair:       order.Pair,
+		Side:       order.Side,
+		Type:       order.Type,
+		Status:     order.Status,
+		Filled:     order.Status == model.OrderStatusTypeFilled,
+		Quantity:   order.Quantity,
+		Price:      order.Price,
+		CandleTime: context.candle.Time,
+		Open:       context.candle.Open,
+		High:       context.candle.High,
+		Low:        context.candle.Low,
+		Close:      context.candle.Close,
+		Volume:     context.candle.Volume,
+		Indicators: context.indicators,
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if entry.Filled && order.Quantity > 0 {
+		position, ok := t.positions[order.Pair]
+		if !ok {
+			position = &tradePosition{}
+			t.positions[order.Pair] = position
+		}
+
+		price := order.Price
+		if order.Stop != nil && (order.Type == model.OrderTypeStopLoss || order.Type == model.OrderTypeStopLossLimit) {
+			price = *order.Stop
+		}
+
+		entry.Fee = order.Fee
+		entry.Slippage = order.Slippage
+		entry.GrossProfit, entry.TradeFees, entry.TradeSlippage, entry.NetProfit = position.fill(order, price)
+	}
+
+	if t.broker != nil {
+		entry.AssetBalance, entry.QuoteBalance, _ = t.broker.Position(order.Pair)
+	}
+
+	t.entries = append(t.entries, entry)
+}
+
+func (t *TradeLog) submit(side model.SideType, pair string, orders []model.Order, err error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	event := t.event(pair, side)
+	if err != nil {
+		t.register(event, model.Order{Pair: pair, Side: side}, err)
+		return
+	}
+
+	for _, order := range orders {
+		if order.Status != model.OrderStatusTypeFilled {
+			t.pending[order.ExchangeID] = event
+		}
+		t.register(event, order, nil)
+	}
+}
+
+// OnOrder registers the fill of orders not executed at submission, e.g. limit orders.
+// The candle context is the last candle processed by the strategy for the pair.
+func (t *TradeLog) OnOrder(order model.Order) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	event, ok := t.pending[order.ExchangeID]
+	if !ok || order.Status == model.OrderStatusTypeNew || order.Status == model.OrderStatusTypePartiallyFilled {
+		return
+	}
+
+	delete(t.pending, order.ExchangeID)
+	t.register(event, order, nil)
+}
+
+// WriteJSON exports the trade log as a JSON array. JSON has no NaN and infinite numbers, so the non-finite
+// values are written as zero and the non-finite indicators are omitted.
+func (t *TradeLog) WriteJSON(w io.Writer) error {
+	entries := t.Entries()
+	for i := range entries {
+		entries[i] = entries[i].finite()
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// finite returns a copy of the entry with the NaN and infinite values replaced by zero and without the
+// non-finite indicators
+func (e TradeLogEntry) finite() TradeLogEntry {
+	for _, value := range []*float64{&e.Quantity, &e.Price, &e.Open, &e.High, &e.Low, &e.Close, &e.Volume,
+		&e.AssetBalance, &e.QuoteBalance, &e.Fee, &e.Slippage, &e.GrossProfit, &e.TradeFees, &e.TradeSlippage,
+		&e.NetProfit} {
+		if !isFinite(*value) {
+			*value = 0
+		}
+	}
+
+	indicators := make(map[string]float64, len(e.Indicators))
+	for key, value := range e.Indicators {
+		if isFinite(value) {
+			indicators[key] = value
+		}
+	}
+	e.Indicators = indicators
+	return e
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// WriteCSV exports the trade log as CSV, with one column for each indicator
+func (t *TradeLog) WriteCSV(w io.Writer) error {
+	entries := t.Entries()
+
+	indicatorSet := make(map[string]bool)
+	for _, entry := range entries {
+		for key := range entry.Indicators {
+			indicatorSet[key] = true
+		}
+	}
+
+	indicators := make([]string, 0, len(indicatorSet))
+	for key := range indicatorSet {
+		indicators = append(indicators, key)
+	}
+	sort.Strings(indicators)
+
+	formatFloat := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	writer := csv.NewWriter(w)
+	header := []string{"time", "event", "order_id", "pair", "side", "type", "status", "filled", "quantity",
+		"price", "error", "candle_time", "open", "high", "low", "close", "volume", "asset_balance", "quote_balance",
+		"fee", "slippage", "gross_profit", "trade_fees", "trade_slippage", "net_profit"}
+	if err := writer.Write(append(header, indicators...)); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		row := []string{
+			entry.Time.Format(time.RFC3339),
+			string(entry.Event),
+			strconv.FormatInt(entry.OrderID, 10),
+			entry.Pair,
+			string(entry.Side),
+			string(entry.Type),
+			string(entry.Status),
+			strconv.FormatBool(entry.Filled),
+			formatFloat(entry.Quantity),
+			formatFloat(entry.Price),
+			entry.Error,
+			entry.CandleTime.Format(time.RFC3339),
+			formatFloat(entry.Open),
+			formatFloat(entry.High),
+			formatFloat(entry.Low),
+			formatFloat(entry.Close),
+			formatFloat(entry.Volume),
+			formatFloat(entry.AssetBalance),
+			formatFloat(entry.QuoteBalance),
+			formatFloat(entry.Fee),
+			formatFloat(entry.Slippage),
+			formatFloat(entry.GrossProfit),
+			formatFloat(entry.TradeFees),
+			formatFloat(entry.TradeSlippage),
+			formatFloat(entry.NetProfit),
+		}
+
+		for _, key := range indicators {
+			value, ok := entry.Indicators[key]
+			if !ok {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, formatFloat(value))
+		}
+
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// tradeLogBroker registers the orders created by the strategy in a TradeLog, amendments and simulations are
+// not registered
+type tradeLogBroker struct {
+	brokerWrapper
+	tradeLog *TradeLog
+}
+
+func (b tradeLogBroker) CreateOrderOCO(side model.SideType, pair string, size, price, stop,
+	stopLimit float64) ([]model.Order, error) {
+	orders, err := b.Broker.CreateOrderOCO(side, pair, size, price, stop, stopLimit)
+	b.tradeLog.submit(side, pair, orders, err)
+	return orders, err
+}
+
+func (b tradeLogBroker) CreateOrderLimit(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	order, err := b.Broker.CreateOrderLimit(side, pair, size, limit)
+	b.tradeLog.submit(side, pair, []model.Order{order}, err)
+	return order, err
+}
+
+func (b tradeLogBroker) CreateOrderMarket(side model.SideType, pair string, size float64) (model.Order, error) {
+	order, err := b.Broker.CreateOrderMarket(side, pair, size)
+	b.tradeLog.submit(side, pair, []model.Order{order}, err)
+	return order, err
+}
+
+func (b tradeLogBroker) CreateOrderMarketQuote(side model.SideType, pair string,
+	quote float64) (model.Order, error) {
+	order, err := b.Broker.CreateOrderMarketQuote(side, pair, quote)
+	b.tradeLog.submit(side, pair, []model.Order{order}, err)
+	return order, err
+}
+
+func (b tradeLogBroker) CreateOrderStop(pair string, quantity float64, limit float64) (model.Order, error) {
+	order, err := b.Broker.CreateOrderStop(pair, quantity, limit)
+	b.tradeLog.submit(model.SideTypeSell, pair, []model.Order{order}, err)
+	return order, err
+}
+
+func (b tradeLogBroker) CreateOrderLimitReduceOnly(side model.SideType, pair string, size float64,
+	limit float64) (model.Order, error) {
+	order, err := b.brokerWrapper.CreateOrderLimitReduceOnly(side, pair, size, limit)
+	b.tradeLog.submit(side, pair, []model.Order{order}, err)
+	return order, err
+}
+
+func (b tradeLogBroker) CreateOrderMarketReduceOnly(side model.SideType, pair string,
+	size float64) (model.Order, error) {
+	order, err := b.brokerWrapper.CreateOrderMarketReduceOnly(side, pair, size)
+	b.tradeLog.submit(side, pair, []model.Order{order}, err)
+	return order, err
+}
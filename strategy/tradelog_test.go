@@ -0,0 +1,220 @@
+package strategy
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+type momentumStrategy struct{}
+
+func (m momentumStrategy) Timeframe() string {
+	return "1d"
+}
+
+func (m momentumStrategy) WarmupPeriod() int {
+	return 2
+}
+
+func (m momentumStrategy) Indicators(df *model.Dataframe) []ChartIndicator {
+	momentum := make(model.Series[float64], len(df.Close))
+	for i := 1; i < len(df.Close); i++ {
+		momentum[i] = df.Close[i] - df.Close[i-1]
+	}
+	df.Metadata["momentum"] = momentum
+	return nil
+}
+
+func (m momentumStrategy) OnCandle(df *model.Dataframe, broker service.Broker) {
+	asset, _, err := broker.Position(df.Pair)
+	if err != nil {
+		return
+	}
+
+	if asset == 0 && df.Metadata["momentum"].Last(0) > 0 {
+		_, _ = broker.CreateOrderMarket(model.SideTypeBuy, df.Pair, 1)
+	} else if asset > 0 && df.Metadata["momentum"].Last(0) < 0 {
+		_, _ = broker.CreateOrderMarket(model.SideTypeSell, df.Pair, asset)
+	}
+}
+
+func TestTradeLog(t *testing.T) {
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 100))
+	tradeLog := NewTradeLog()
+	controller := NewStrategyController("BTCUSDT", momentumStrategy{}, wallet)
+	controller.SetTradeLog(tradeLog)
+	controller.Start()
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, price := range []float64{10, 11, 12, 11, 10} {
+		candle := model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.AddDate(0, 0, i),
+			Open:     price,
+			Close:    price,
+			Low:      price,
+			High:     price,
+			Volume:   100,
+			Complete: true,
+		}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	entries := tradeLog.Entries()
+	require.Len(t, entries, 2)
+
+	entry := entries[0]
+	require.Equal(t, TradeEventEntry, entry.Event)
+	require.Equal(t, model.SideTypeBuy, entry.Side)
+	require.True(t, entry.Filled)
+	require.Equal(t, start.AddDate(0, 0, 1), entry.CandleTime)
+	require.Equal(t, 11.0, entry.Close)
+	require.Equal(t, 11.0, entry.Price)
+	require.Equal(t, 1.0, entry.Quantity)
+	require.Equal(t, map[string]float64{"momentum": 1}, entry.Indicators)
+	require.Equal(t, 1.0, entry.AssetBalance)
+	require.Equal(t, 89.0, entry.QuoteBalance)
+
+	exit := entries[1]
+	require.Equal(t, TradeEventExit, exit.Event)
+	require.Equal(t, model.SideTypeSell, exit.Side)
+	require.Equal(t, start.AddDate(0, 0, 3), exit.CandleTime)
+	require.Equal(t, 11.0, exit.Close)
+	require.Equal(t, map[string]float64{"momentum": -1}, exit.Indicators)
+	require.Equal(t, 0.0, exit.AssetBalance)
+	require.Equal(t, 100.0, exit.QuoteBalance)
+
+	t.Run("json", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.NoError(t, tradeLog.WriteJSON(&buffer))
+
+		var result []TradeLogEntry
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &result))
+		require.Equal(t, entries, result)
+	})
+
+	t.Run("json with non-finite values", func(t *testing.T) {
+		tradeLog := NewTradeLog()
+		tradeLog.entries = []TradeLogEntry{{
+			Pair:       "BTCUSDT",
+			Price:      10,
+			Slippage:   math.Inf(1),
+			NetProfit:  math.NaN(),
+			Indicators: map[string]float64{"momentum": 1, "rsi": math.NaN()},
+		}}
+
+		var buffer bytes.Buffer
+		require.NoError(t, tradeLog.WriteJSON(&buffer))
+
+		var result []TradeLogEntry
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), &result))
+		require.Len(t, result, 1)
+		require.Equal(t, 10.0, result[0].Price)
+		require.Equal(t, 0.0, result[0].Slippage)
+		require.Equal(t, 0.0, result[0].NetProfit)
+		require.Equal(t, map[string]float64{"momentum": 1}, result[0].Indicators)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.NoError(t, tradeLog.WriteCSV(&buffer))
+
+		lines, err := csv.NewReader(&buffer).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, lines, 3)
+		require.Equal(t, "momentum", lines[0][len(lines[0])-1])
+		require.Equal(t, []string{"entry", "BTCUSDT", "BUY", "1"},
+			[]string{lines[1][1], lines[1][3], lines[1][4], lines[1][len(lines[1])-1]})
+		require.Equal(t, []string{"exit", "SELL", "-1"},
+			[]string{lines[2][1], lines[2][4], lines[2][len(lines[2])-1]})
+	})
+}
+
+func TestTradeLog_Attribution(t *testing.T) {
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 100),
+		exchange.WithPaperFee(0.001, 0.001), exchange.WithPaperSlippage(0.005))
+	tradeLog := NewTradeLog()
+	controller := NewStrategyController("BTCUSDT", momentumStrategy{}, wallet)
+	controller.SetTradeLog(tradeLog)
+	controller.Start()
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, price := range []float64{10, 11, 12, 11, 10} {
+		candle := model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.AddDate(0, 0, i),
+			Open:     price,
+			Close:    price,
+			Low:      price,
+			High:     price,
+			Volume:   100,
+			Complete: true,
+		}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+	}
+
+	entries := tradeLog.Entries()
+	require.Len(t, entries, 2)
+
+	// market buy filled at 11 + 0.5% of slippage
+	entry := entries[0]
+	require.InDelta(t, 11.055, entry.Price, 1e-9)
+	require.InDelta(t, 0.011055, entry.Fee, 1e-9)
+	require.InDelta(t, 0.055, entry.Slippage, 1e-9)
+	require.Zero(t, entry.NetProfit)
+
+	// market sell filled at 11 - 0.5% of slippage, same close price of the entry
+	exit := entries[1]
+	require.InDelta(t, 10.945, exit.Price, 1e-9)
+	require.InDelta(t, 0.010945, exit.Fee, 1e-9)
+	require.InDelta(t, 0.055, exit.Slippage, 1e-9)
+	require.InDelta(t, 0.0, exit.GrossProfit, 1e-9)
+	require.InDelta(t, 0.022, exit.TradeFees, 1e-9)
+	require.InDelta(t, 0.11, exit.TradeSlippage, 1e-9)
+	require.InDelta(t, -0.132, exit.NetProfit, 1e-9)
+	require.InDelta(t, exit.NetProfit, exit.GrossProfit-exit.TradeFees-exit.TradeSlippage, 1e-9)
+	require.InDelta(t, 100+exit.NetProfit, exit.QuoteBalance, 1e-9)
+
+	t.Run("csv", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.NoError(t, tradeLog.WriteCSV(&buffer))
+
+		lines, err := csv.NewReader(&buffer).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, lines, 3)
+
+		columns := make(map[string]int)
+		for i, name := range lines[0] {
+			columns[name] = i
+		}
+		for column, expected := range map[string]float64{"trade_fees": 0.022, "trade_slippage": 0.11,
+			"net_profit": -0.132} {
+			value, err := strconv.ParseFloat(lines[2][columns[column]], 64)
+			require.NoError(t, err)
+			require.InDelta(t, expected, value, 1e-9, column)
+		}
+	})
+}
+
+func TestIndicatorValues(t *testing.T) {
+	df := &model.Dataframe{Metadata: map[string]model.Series[float64]{
+		"ema":    {1, 2},
+		"rsi":    {math.NaN()},
+		"ratio":  {math.Inf(1)},
+		"volume": {},
+	}}
+	require.Equal(t, map[string]float64{"ema": 2}, indicatorValues(df))
+}
@@ -0,0 +1,53 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var ErrLowVolume = errors.New("entry blocked by low candle volume")
+
+// volumeCheck blocks entries when the volume of the current candle is below the minimum volume, or below the
+// minimum ratio of the average volume of the previous candles, see entryGuard
+type volumeCheck struct {
+	minVolume float64
+	minRatio  float64
+	period    int
+
+	// volume of the current candle and its ratio to the average volume
+	volume float64
+	ratio  float64
+}
+
+// update registers the volume of the last candle of the dataframe and its relative volume, see
+// model.OHLC.RelativeVolume. The ratio is NaN until the dataframe has the candles of the period before the
+// current one, or when they have no volume.
+func (v *volumeCheck) update(df *model.Dataframe) {
+	last := len(df.Volume) - 1
+	if last < 0 {
+		return
+	}
+
+	v.volume, v.ratio = df.Volume[last], math.NaN()
+	if v.period <= 0 || last < v.period {
+		return
+	}
+
+	window := model.OHLC{Close: df.Close[last-v.period:], Volume: df.Volume[last-v.period:]}
+	v.ratio = window.RelativeVolume(v.period)[v.period]
+}
+
+func (v *volumeCheck) check(_ model.SideType, pair string) error {
+	if v.volume < v.minVolume {
+		return fmt.Errorf("%w: volume %f below %f for %s", ErrLowVolume, v.volume, v.minVolume, pair)
+	}
+
+	// comparisons with NaN are false, the ratio is not checked during the warmup
+	if v.ratio < v.minRatio {
+		return fmt.Errorf("%w: volume ratio %.2f below %.2f for %s", ErrLowVolume, v.ratio, v.minRatio, pair)
+	}
+	return nil
+}
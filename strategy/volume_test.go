@@ -0,0 +1,59 @@
+package strategy
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+func TestController_SetMinCandleVolume(t *testing.T) {
+	wallet := exchange.NewPaperWallet(context.Background(), "USDT", exchange.WithPaperAsset("USDT", 1000))
+	strategy := &scriptedStrategy{
+		sides: []model.SideType{
+			model.SideTypeBuy,  // long entry, ratio not available yet
+			model.SideTypeSell, // exit
+			model.SideTypeBuy,  // blocked by the minimum volume
+			model.SideTypeBuy,  // long entry, volume recovered
+			"",                 // no signal
+			model.SideTypeBuy,  // blocked by the ratio to the average volume
+			model.SideTypeSell, // exit, not affected by the low volume
+		},
+	}
+	volumes := []float64{10, 10, 4, 10, 20, 6, 2}
+
+	controller := NewStrategyController("BTCUSDT", strategy, wallet)
+	controller.SetMinCandleVolume(5, 0.5, 2)
+	controller.Start()
+
+	ratios := make([]float64, 0)
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range strategy.sides {
+		candle := model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour), Close: 10,
+			Volume: volumes[i], Complete: true}
+		wallet.OnCandle(candle)
+		controller.OnCandle(candle)
+		ratios = append(ratios, controller.VolumeRatio())
+	}
+
+	for i, err := range strategy.errors {
+		if i == 2 || i == 5 {
+			require.ErrorIs(t, err, ErrLowVolume, i)
+			continue
+		}
+		require.NoError(t, err, i)
+	}
+
+	require.True(t, math.IsNaN(ratios[0]))
+	require.True(t, math.IsNaN(ratios[1]))
+	require.InDeltaSlice(t, []float64{0.4, 10.0 / 7, 20.0 / 7, 0.4, 2.0 / 13}, ratios[2:], 1e-9)
+
+	asset, _, err := wallet.Position("BTCUSDT")
+	require.NoError(t, err)
+	require.Equal(t, 0.0, asset)
+}
@@ -0,0 +1,154 @@
+package ninjabot
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// CostLevel is a fee and slippage assumption of a backtest, as fractions of the order value,
+// e.g. 0.001 for 0.1%
+type CostLevel struct {
+	Fee      float64
+	Slippage float64
+}
+
+// CostSensitivity is the result of a backtest with a cost level
+type CostSensitivity struct {
+	CostLevel
+	Profit float64
+	Sharpe float64
+	Trades int
+}
+
+// CostBacktest runs a backtest with the given costs and returns its result, e.g. a bot created with
+// WithBacktest and a paper wallet with exchange.WithPaperFee and exchange.WithPaperSlippage.
+// Each call must create its own wallet, storage and bot, since the backtests are executed in parallel.
+type CostBacktest func(ctx context.Context, costs CostLevel) (Result, error)
+
+// CostLevels returns steps + 1 levels increasing linearly from zero to the maximum fee and slippage
+func CostLevels(maxFee, maxSlippage float64, steps int) []CostLevel {
+	if steps <= 0 {
+		return []CostLevel{{Fee: maxFee, Slippage: maxSlippage}}
+	}
+
+	levels := make([]CostLevel, 0, steps+1)
+	for i := 0; i <= steps; i++ {
+		ratio := float64(i) / float64(steps)
+		levels = append(levels, CostLevel{Fee: maxFee * ratio, Slippage: maxSlippage * ratio})
+	}
+	return levels
+}
+
+// CostSweep runs the backtest for each cost level and returns the net profit and Sharpe ratio of each one,
+// in the order of the levels. It shows how the profitability degrades with higher costs, e.g. fragile high
+// frequency strategies. The backtests are executed in parallel, up to the given number at the same time
+// (the number of CPUs when zero).
+func CostSweep(ctx context.Context, backtest CostBacktest, levels []CostLevel,
+	parallel int) ([]CostSensitivity, error) {
+
+	results := make([]CostSensitivity, len(levels))
+	err := runParallel(ctx, len(levels), parallel, func(i int) error {
+		result, err := backtest(ctx, levels[i])
+		if err != nil {
+			return fmt.Errorf("sweep: fee %f, slippage %f: %w", levels[i].Fee, levels[i].Slippage, err)
+		}
+
+		results[i] = CostSensitivity{
+			CostLevel: levels[i],
+			Profit:    result.NetProfit(),
+			Sharpe:    result.Sharpe(),
+		}
+		for _, metrics := range result.Metrics {
+			results[i].Trades += metrics.Trades
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// BreakEvenCost returns the first cost level without profit, false when all levels are profitable
+func BreakEvenCost(results []CostSensitivity) (CostLevel, bool) {
+	for _, result := range results {
+		if result.Profit <= 0 {
+			return result.CostLevel, true
+		}
+	}
+	return CostLevel{}, false
+}
+
+// CostSensitivityTable renders the results of a cost sweep as a table
+func CostSensitivityTable(results []CostSensitivity) string {
+	builder := &strings.Builder{}
+	table := tablewriter.NewWriter(builder)
+	table.SetHeader([]string{"Fee", "Slippage", "Trades", "Profit", "Sharpe"})
+	for _, result := range results {
+		table.Append([]string{
+			fmt.Sprintf("%.3f%%", result.Fee*100),
+			fmt.Sprintf("%.3f%%", result.Slippage*100),
+			fmt.Sprintf("%d", result.Trades),
+			fmt.Sprintf("%.4f", result.Profit),
+			fmt.Sprintf("%.2f", result.Sharpe),
+		})
+	}
+	table.Render()
+	return builder.String()
+}
+
+// runParallel executes run for the indices from 0 to count - 1, up to parallel executions at the same time.
+// It stops scheduling new executions after the first error or the cancellation of the context.
+func runParallel(ctx context.Context, count, parallel int, run func(i int) error) error {
+	if parallel <= 0 {
+		parallel = runtime.NumCPU()
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mtx      sync.Mutex
+		firstErr error
+	)
+
+	failed := func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return firstErr != nil
+	}
+
+	slots := make(chan struct{}, parallel)
+	for i := 0; i < count; i++ {
+		slots <- struct{}{}
+		if ctx.Err() != nil || failed() {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			if err := run(i); err != nil {
+				mtx.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mtx.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
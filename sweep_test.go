@@ -0,0 +1,86 @@
+package ninjabot
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+// roundTripBacktest buys on even candles and sells on odd candles of a slow uptrend
+func roundTripBacktest(ctx context.Context, costs CostLevel) (Result, error) {
+	wallet := exchange.NewPaperWallet(ctx, "USDT", exchange.WithPaperAsset("USDT", 10000),
+		exchange.WithPaperFee(costs.Fee, costs.Fee), exchange.WithPaperSlippage(costs.Slippage))
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	trades := 0
+	for i := 0; i < 40; i++ {
+		price := 100 * (1 + 0.002*float64(i))
+		wallet.OnCandle(model.Candle{Pair: "BTCUSDT", Time: start.Add(time.Duration(i) * time.Hour), Open: price,
+			Close: price, High: price, Low: price, Complete: true})
+
+		asset, quote, err := wallet.Position("BTCUSDT")
+		if err != nil {
+			return Result{}, err
+		}
+
+		if i%2 == 0 {
+			_, err = wallet.CreateOrderMarketQuote(model.SideTypeBuy, "BTCUSDT", quote*0.9)
+		} else {
+			_, err = wallet.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", asset)
+			trades++
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	return Result{
+		Metrics: []PairResult{{Pair: "BTCUSDT", Trades: trades}},
+		Equity:  wallet.EquityValues(),
+	}, nil
+}
+
+func TestCostSweep(t *testing.T) {
+	levels := CostLevels(0.002, 0.002, 8)
+	require.Len(t, levels, 9)
+	require.Equal(t, CostLevel{}, levels[0])
+	require.InDelta(t, 0.001, levels[4].Fee, 1e-12)
+
+	results, err := CostSweep(context.Background(), roundTripBacktest, levels, 3)
+	require.NoError(t, err)
+	require.Len(t, results, len(levels))
+
+	require.Greater(t, results[0].Profit, 0.0)
+	for i := 1; i < len(results); i++ {
+		require.Equal(t, levels[i], results[i].CostLevel)
+		require.Equal(t, 20, results[i].Trades)
+		require.LessOrEqual(t, results[i].Profit, results[i-1].Profit)
+		require.LessOrEqual(t, results[i].Sharpe, results[i-1].Sharpe)
+	}
+
+	// each round trip earns 0.2% before costs, fees and slippage are paid twice
+	breakEven, ok := BreakEvenCost(results)
+	require.True(t, ok)
+	require.InDelta(t, 0.0005, breakEven.Fee, 1e-12)
+
+	table := CostSensitivityTable(results)
+	require.Contains(t, table, "0.050%")
+
+	t.Run("error", func(t *testing.T) {
+		var calls int32
+		failure := errors.New("failure")
+		_, err := CostSweep(context.Background(), func(ctx context.Context, costs CostLevel) (Result, error) {
+			atomic.AddInt32(&calls, 1)
+			return Result{}, failure
+		}, levels, 1)
+		require.ErrorIs(t, err, failure)
+		require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
@@ -104,6 +104,43 @@ func (_c *Telegram_OnOrder_Call) Return() *Telegram_OnOrder_Call {
 	return _c
 }
 
+// Send provides a mock function with given fields: text
+func (_m *Telegram) Send(text string) error {
+	ret := _m.Called(text)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(text)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Telegram_Send_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Send'
+type Telegram_Send_Call struct {
+	*mock.Call
+}
+
+// Send is a helper method to define mock.On call
+//   - text string
+func (_e *Telegram_Expecter) Send(text interface{}) *Telegram_Send_Call {
+	return &Telegram_Send_Call{Call: _e.mock.On("Send", text)}
+}
+
+func (_c *Telegram_Send_Call) Run(run func(text string)) *Telegram_Send_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *Telegram_Send_Call) Return(_a0 error) *Telegram_Send_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
 // Start provides a mock function with given fields:
 func (_m *Telegram) Start() {
 	_m.Called()
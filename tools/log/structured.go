@@ -0,0 +1,99 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	stdlog "log"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger is a structured logger used by the bot components. Fields are key-value pairs, e.g.:
+//
+//	logger.Info("order created", "pair", "BTCUSDT", "quantity", 0.1)
+//
+// Other logging libraries can be plugged with a small adapter, e.g. zap's SugaredLogger
+// methods Debugw, Infow, Warnw and Errorw have the same signature.
+type Logger interface {
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+}
+
+// Default returns the default Logger, a StdLogger that writes to the output of the standard library log
+// package, with the level configured by SetLevel
+func Default() Logger {
+	return &StdLogger{logger: stdlog.Default(), level: logrus.GetLevel}
+}
+
+// StdLogger is a Logger implementation with the standard library log package.
+// Messages are written in logfmt style: level=info msg="order created" pair=BTCUSDT
+type StdLogger struct {
+	logger *stdlog.Logger
+	level  func() Level
+}
+
+// NewStdLogger creates a StdLogger that writes messages with severity up to the given level, e.g.
+// with InfoLevel, debug messages are discarded
+func NewStdLogger(out io.Writer, level Level) *StdLogger {
+	return &StdLogger{
+		logger: stdlog.New(out, "", stdlog.LstdFlags),
+		level: func() Level {
+			return level
+		},
+	}
+}
+
+func (l StdLogger) log(level Level, msg string, fields []interface{}) {
+	if level > l.level() {
+		return
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "level=%s msg=%q", level, msg)
+	for i := 0; i < len(fields); i += 2 {
+		fmt.Fprintf(&builder, " %s=%s", fieldKey(fields[i]), formatValue(fieldValue(fields, i+1)))
+	}
+	l.logger.Println(builder.String())
+}
+
+func (l StdLogger) Debug(msg string, fields ...interface{}) {
+	l.log(DebugLevel, msg, fields)
+}
+
+func (l StdLogger) Info(msg string, fields ...interface{}) {
+	l.log(InfoLevel, msg, fields)
+}
+
+func (l StdLogger) Warn(msg string, fields ...interface{}) {
+	l.log(WarnLevel, msg, fields)
+}
+
+func (l StdLogger) Error(msg string, fields ...interface{}) {
+	l.log(ErrorLevel, msg, fields)
+}
+
+func fieldKey(key interface{}) string {
+	if value, ok := key.(string); ok {
+		return value
+	}
+	return fmt.Sprint(key)
+}
+
+// fieldValue returns the value of a key-value pair, a key without value is reported as missing
+func fieldValue(fields []interface{}, index int) interface{} {
+	if index >= len(fields) {
+		return "MISSING"
+	}
+	return fields[index]
+}
+
+func formatValue(value interface{}) string {
+	text := fmt.Sprint(value)
+	if text == "" || strings.ContainsAny(text, " =\"") {
+		return fmt.Sprintf("%q", text)
+	}
+	return text
+}
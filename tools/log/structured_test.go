@@ -0,0 +1,40 @@
+package log
+
+import (
+	"bytes"
+	stdlog "log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := NewStdLogger(&buffer, InfoLevel)
+
+	logger.Debug("ignored", "pair", "BTCUSDT")
+	logger.Info("order created", "pair", "BTCUSDT", "quantity", 0.5, "note", "first order", "missing")
+	logger.Error("failed")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	require.Len(t, lines, 2)
+	require.True(t, strings.HasSuffix(lines[0],
+		`level=info msg="order created" pair=BTCUSDT quantity=0.5 note="first order" missing=MISSING`), lines[0])
+	require.True(t, strings.HasSuffix(lines[1], `level=error msg="failed"`), lines[1])
+}
+
+func TestDefault(t *testing.T) {
+	var buffer bytes.Buffer
+	stdlog.SetOutput(&buffer)
+	defer stdlog.SetOutput(os.Stderr)
+	SetLevel(WarnLevel)
+	defer SetLevel(InfoLevel)
+
+	logger := Default()
+	logger.Info("ignored")
+	logger.Warn("order rejected", "pair", "BTCUSDT")
+	require.True(t, strings.HasSuffix(strings.TrimSpace(buffer.String()),
+		`level=warning msg="order rejected" pair=BTCUSDT`), buffer.String())
+}
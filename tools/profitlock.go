@@ -0,0 +1,100 @@
+package tools
+
+import "github.com/rodrigo-brito/ninjabot/model"
+
+// ProfitLock moves the protective stop of a position to the entry price (break-even) once the unrealized
+// profit reaches the trigger, e.g. a trigger of 0.02 for a long entry at 100 moves the stop when the price
+// touches 102. An optional offset locks a portion of the gains, the stop is moved to entry * (1 + offset)
+// for long positions and entry * (1 - offset) for short positions.
+//
+// The trigger is checked with the high (long) or low (short) of the candle, so it also detects touches
+// inside partial candles. The stop is moved only once per position and never backward: when the stop is
+// already beyond the lock price, e.g. moved by a trailing stop, it is kept.
+type ProfitLock struct {
+	trigger  float64
+	offset   float64
+	side     model.SideType
+	entry    float64
+	stop     float64
+	active   bool
+	locked   bool
+	trailing *TrailingStop
+}
+
+// NewProfitLock creates a profit lock with the trigger and the lock offset as fractions of the entry price
+func NewProfitLock(trigger, offset float64) *ProfitLock {
+	return &ProfitLock{trigger: trigger, offset: offset}
+}
+
+// Start manages the stop of a new position, the side is the side of the entry order
+func (p *ProfitLock) Start(side model.SideType, entry, stop float64) {
+	p.side = side
+	p.entry = entry
+	p.stop = stop
+	p.active = true
+	p.locked = false
+	p.trailing = nil
+}
+
+// StartTrailing manages the stop of a long position protected by a trailing stop, the lock raises
+// the stop of the trailing stop instead of keeping a separate stop, so both never disagree
+func (p *ProfitLock) StartTrailing(entry float64, trailing *TrailingStop) {
+	p.Start(model.SideTypeBuy, entry, trailing.StopPrice())
+	p.trailing = trailing
+}
+
+func (p *ProfitLock) Stop() {
+	p.active = false
+	p.trailing = nil
+}
+
+func (p ProfitLock) Active() bool {
+	return p.active
+}
+
+// Locked returns if the trigger was reached for the current position
+func (p ProfitLock) Locked() bool {
+	return p.locked
+}
+
+// StopPrice returns the current stop of the position
+func (p ProfitLock) StopPrice() float64 {
+	if p.trailing != nil {
+		return p.trailing.StopPrice()
+	}
+	return p.stop
+}
+
+// Update checks the trigger with the candle and returns true when the stop is moved, the new stop is
+// available in StopPrice. Stop orders in the exchange, e.g. the stop of an OCO bracket, must be replaced
+// by the caller when it returns true.
+func (p *ProfitLock) Update(candle model.Candle) bool {
+	if !p.active || p.locked {
+		return false
+	}
+
+	var lock float64
+	if p.side == model.SideTypeSell {
+		if candle.Low > p.entry*(1-p.trigger) {
+			return false
+		}
+		lock = p.entry * (1 - p.offset)
+	} else {
+		if candle.High < p.entry*(1+p.trigger) {
+			return false
+		}
+		lock = p.entry * (1 + p.offset)
+	}
+
+	p.locked = true
+	if p.trailing != nil {
+		return p.trailing.Raise(lock)
+	}
+
+	// never move the stop backward
+	if (p.side == model.SideTypeSell && lock >= p.stop) || (p.side != model.SideTypeSell && lock <= p.stop) {
+		return false
+	}
+	p.stop = lock
+	return true
+}
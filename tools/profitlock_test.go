@@ -0,0 +1,96 @@
+package tools_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools"
+)
+
+func TestProfitLock_Update(t *testing.T) {
+	t.Run("long", func(t *testing.T) {
+		lock := tools.NewProfitLock(0.02, 0.005)
+
+		// not started
+		require.False(t, lock.Update(model.Candle{High: 110, Low: 100}))
+
+		lock.Start(model.SideTypeBuy, 100, 95)
+		require.True(t, lock.Active())
+
+		// close below the trigger
+		require.False(t, lock.Update(model.Candle{Close: 101, High: 101.9, Low: 99}))
+		require.Equal(t, 95.0, lock.StopPrice())
+
+		// high touches the trigger inside the candle
+		require.True(t, lock.Update(model.Candle{Close: 101, High: 102, Low: 100}))
+		require.True(t, lock.Locked())
+		require.InDelta(t, 100.5, lock.StopPrice(), 1e-9)
+
+		// moved only once, even with a new trigger
+		require.False(t, lock.Update(model.Candle{Close: 110, High: 110, Low: 108}))
+		require.InDelta(t, 100.5, lock.StopPrice(), 1e-9)
+	})
+
+	t.Run("short", func(t *testing.T) {
+		lock := tools.NewProfitLock(0.02, 0)
+		lock.Start(model.SideTypeSell, 100, 105)
+
+		require.False(t, lock.Update(model.Candle{High: 101, Low: 98.1}))
+		require.True(t, lock.Update(model.Candle{High: 99, Low: 98}))
+		require.Equal(t, 100.0, lock.StopPrice())
+	})
+
+	t.Run("never backward", func(t *testing.T) {
+		lock := tools.NewProfitLock(0.02, 0.005)
+		lock.Start(model.SideTypeBuy, 100, 101)
+
+		require.False(t, lock.Update(model.Candle{High: 103}))
+		require.True(t, lock.Locked())
+		require.Equal(t, 101.0, lock.StopPrice())
+	})
+
+	t.Run("trailing stop", func(t *testing.T) {
+		trailing := tools.NewTrailingStop()
+		trailing.Start(100, 95)
+		lock := tools.NewProfitLock(0.05, 0)
+		lock.StartTrailing(100, trailing)
+
+		// trailing raises the stop to 98
+		require.False(t, trailing.Update(103))
+		require.False(t, lock.Update(model.Candle{High: 103}))
+
+		// lock raises the stop of the trailing stop to break-even
+		require.True(t, lock.Update(model.Candle{High: 105}))
+		require.Equal(t, 100.0, trailing.StopPrice())
+		require.Equal(t, 100.0, lock.StopPrice())
+
+		// trailing keeps moving the same stop
+		require.False(t, trailing.Update(108))
+		require.Equal(t, 105.0, lock.StopPrice())
+		require.True(t, trailing.Update(104))
+	})
+
+	t.Run("trailing stop beyond break-even", func(t *testing.T) {
+		trailing := tools.NewTrailingStop()
+		trailing.Start(100, 98)
+		lock := tools.NewProfitLock(0.05, 0)
+		lock.StartTrailing(100, trailing)
+
+		require.False(t, trailing.Update(106))
+		require.False(t, lock.Update(model.Candle{High: 106}))
+		require.True(t, lock.Locked())
+		require.Equal(t, 104.0, trailing.StopPrice())
+	})
+}
+
+func TestTrailingStop_Raise(t *testing.T) {
+	ts := tools.NewTrailingStop()
+	require.False(t, ts.Raise(10))
+
+	ts.Start(21.5, 13.0)
+	require.False(t, ts.Raise(12))
+	require.True(t, ts.Raise(14))
+	require.Equal(t, 14.0, ts.StopPrice())
+}
@@ -0,0 +1,169 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+var ErrReplayWindow = errors.New("invalid replay window")
+
+type replayConfig struct {
+	logger log.Logger
+	wallet []exchange.PaperWalletOption
+}
+
+type ReplayOption func(*replayConfig)
+
+// WithReplayLogger sets the logger of the replay, candles are logged in debug level and orders in info level
+func WithReplayLogger(logger log.Logger) ReplayOption {
+	return func(config *replayConfig) {
+		config.logger = logger
+	}
+}
+
+// WithReplayWallet sets the paper wallet options used to simulate the orders,
+// by default the wallet starts with 10000 of the quote asset
+func WithReplayWallet(options ...exchange.PaperWalletOption) ReplayOption {
+	return func(config *replayConfig) {
+		config.wallet = options
+	}
+}
+
+// Replay re-runs a strategy over a stored candle window of a pair, e.g. the candles before an unexpected
+// live trade, and returns the orders the strategy would create with the candle and the indicator values
+// of each one. Candles are served by an in-memory feed and orders are simulated in a paper wallet,
+// so no exchange is touched. The window must include the warmup period of the strategy.
+// Replaying the same window twice should return the same orders, otherwise the strategy keeps state
+// between executions or depends on non-deterministic data.
+func Replay(ctx context.Context, str strategy.Strategy, candles []model.Candle,
+	options ...ReplayOption) ([]strategy.TradeLogEntry, error) {
+
+	if len(candles) == 0 {
+		return nil, fmt.Errorf("%w: no candles", ErrReplayWindow)
+	}
+
+	pair := candles[0].Pair
+	for _, candle := range candles {
+		if candle.Pair != pair {
+			return nil, fmt.Errorf("%w: candles of multiple pairs, %s and %s", ErrReplayWindow, pair, candle.Pair)
+		}
+	}
+
+	_, quote := exchange.SplitAssetQuote(pair)
+	config := replayConfig{
+		logger: log.Default(),
+		wallet: []exchange.PaperWalletOption{exchange.WithPaperAsset(quote, 10000)},
+	}
+	for _, option := range options {
+		option(&config)
+	}
+
+	feed := replayFeed{candles: candles}
+	wallet := exchange.NewPaperWallet(ctx, quote, append(config.wallet, exchange.WithDataFeed(feed))...)
+
+	tradeLog := strategy.NewTradeLog()
+	controller := strategy.NewStrategyController(pair, str, wallet)
+	controller.SetTradeLog(tradeLog)
+	controller.Start()
+
+	// orders waiting for execution, checked in the paper wallet after each candle
+	pending := make([]int64, 0)
+	logged := 0
+	logOrders := func() {
+		entries := tradeLog.Entries()
+		for _, entry := range entries[logged:] {
+			if entry.Error == "" && (entry.Status == model.OrderStatusTypeNew ||
+				entry.Status == model.OrderStatusTypePartiallyFilled) {
+				pending = append(pending, entry.OrderID)
+			}
+			config.logger.Info("[REPLAY] order", "time", entry.CandleTime, "event", entry.Event,
+				"side", entry.Side, "type", entry.Type, "status", entry.Status, "quantity", entry.Quantity,
+				"price", entry.Price, "error", entry.Error)
+		}
+		logged = len(entries)
+	}
+
+	dataFeed := exchange.NewDataFeed(wallet)
+	dataFeed.SetLogger(config.logger)
+	dataFeed.Subscribe(pair, str.Timeframe(), func(candle model.Candle) {
+		config.logger.Debug("[REPLAY] candle", "time", candle.Time, "open", candle.Open, "high", candle.High,
+			"low", candle.Low, "close", candle.Close, "volume", candle.Volume, "complete", candle.Complete)
+
+		wallet.OnCandle(candle)
+		open := pending[:0]
+		for _, id := range pending {
+			order, err := wallet.Order(pair, id)
+			if err != nil {
+				config.logger.Error("[REPLAY] order not found", "id", id)
+				continue
+			}
+
+			if order.Status == model.OrderStatusTypeNew || order.Status == model.OrderStatusTypePartiallyFilled {
+				open = append(open, id)
+				continue
+			}
+			tradeLog.OnOrder(order)
+		}
+		pending = open
+
+		controller.OnPartialCandle(candle)
+		if candle.Complete {
+			controller.OnCandle(candle)
+		}
+		logOrders()
+	}, false)
+	dataFeed.Start(true)
+
+	return tradeLog.Entries(), nil
+}
+
+// replayFeed is an in-memory service.Feeder over a candle window
+type replayFeed struct {
+	candles []model.Candle
+}
+
+func (r replayFeed) AssetsInfo(pair string) model.AssetInfo {
+	return exchange.CSVFeed{}.AssetsInfo(pair)
+}
+
+func (r replayFeed) LastQuote(_ context.Context, _ string) (float64, error) {
+	return r.candles[len(r.candles)-1].Close, nil
+}
+
+func (r replayFeed) CandlesByPeriod(_ context.Context, _, _ string, start, end time.Time) ([]model.Candle, error) {
+	candles := make([]model.Candle, 0)
+	for _, candle := range r.candles {
+		if candle.Time.Before(start) || candle.Time.After(end) {
+			continue
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+// CandlesByLimit returns the last candles of the window, like the exchanges
+func (r replayFeed) CandlesByLimit(_ context.Context, pair, _ string, limit int) ([]model.Candle, error) {
+	if len(r.candles) < limit {
+		return nil, fmt.Errorf("%w: %s", exchange.ErrInsufficientData, pair)
+	}
+	return r.candles[len(r.candles)-limit:], nil
+}
+
+func (r replayFeed) CandlesSubscription(_ context.Context, _, _ string) (chan model.Candle, chan error) {
+	ccandle := make(chan model.Candle)
+	cerr := make(chan error)
+	go func() {
+		for _, candle := range r.candles {
+			ccandle <- candle
+		}
+		close(ccandle)
+	}()
+	return ccandle, cerr
+}
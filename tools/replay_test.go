@@ -0,0 +1,113 @@
+package tools_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+	"github.com/rodrigo-brito/ninjabot/tools"
+)
+
+// breakoutStrategy buys when the price goes up and takes profit with a limit order
+type breakoutStrategy struct{}
+
+func (b breakoutStrategy) Timeframe() string {
+	return "1h"
+}
+
+func (b breakoutStrategy) WarmupPeriod() int {
+	return 2
+}
+
+func (b breakoutStrategy) Indicators(df *model.Dataframe) []strategy.ChartIndicator {
+	df.Metadata["diff"] = model.Series[float64]{df.Close.Last(0) - df.Close.Last(1)}
+	return nil
+}
+
+func (b breakoutStrategy) OnCandle(df *model.Dataframe, broker service.Broker) {
+	asset, _, err := broker.Position(df.Pair)
+	if err != nil || asset > 0 || df.Metadata["diff"].Last(0) <= 0 {
+		return
+	}
+
+	if _, err := broker.CreateOrderMarket(model.SideTypeBuy, df.Pair, 1); err != nil {
+		return
+	}
+	_, _ = broker.CreateOrderLimit(model.SideTypeSell, df.Pair, 1, df.Close.Last(0)+2)
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	candles := make([]model.Candle, 0)
+	for i, price := range []float64{10, 11, 12, 14, 13, 12} {
+		candles = append(candles, model.Candle{
+			Pair:     "BTCUSDT",
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Open:     price,
+			Close:    price,
+			Low:      price,
+			High:     price,
+			Complete: true,
+		})
+	}
+
+	entries, err := tools.Replay(context.Background(), breakoutStrategy{}, candles)
+	require.NoError(t, err)
+
+	type signal struct {
+		candleTime time.Time
+		event      strategy.TradeEvent
+		side       model.SideType
+		orderType  model.OrderType
+		status     model.OrderStatusType
+		price      float64
+		diff       float64
+	}
+
+	signals := make([]signal, 0, len(entries))
+	for _, entry := range entries {
+		signals = append(signals, signal{
+			candleTime: entry.CandleTime,
+			event:      entry.Event,
+			side:       entry.Side,
+			orderType:  entry.Type,
+			status:     entry.Status,
+			price:      entry.Price,
+			diff:       entry.Indicators["diff"],
+		})
+	}
+
+	require.Equal(t, []signal{
+		{candles[1].Time, strategy.TradeEventEntry, model.SideTypeBuy, model.OrderTypeMarket,
+			model.OrderStatusTypeFilled, 11, 1},
+		{candles[1].Time, strategy.TradeEventExit, model.SideTypeSell, model.OrderTypeLimit,
+			model.OrderStatusTypeNew, 13, 1},
+		// take profit filled on the next candles, with the context of the last candle processed
+		{candles[2].Time, strategy.TradeEventExit, model.SideTypeSell, model.OrderTypeLimit,
+			model.OrderStatusTypeFilled, 13, 1},
+		{candles[3].Time, strategy.TradeEventEntry, model.SideTypeBuy, model.OrderTypeMarket,
+			model.OrderStatusTypeFilled, 14, 2},
+		{candles[3].Time, strategy.TradeEventExit, model.SideTypeSell, model.OrderTypeLimit,
+			model.OrderStatusTypeNew, 16, 2},
+	}, signals)
+
+	// deterministic strategies return the same orders
+	replay, err := tools.Replay(context.Background(), breakoutStrategy{}, candles)
+	require.NoError(t, err)
+	require.Equal(t, entries, replay)
+
+	t.Run("invalid window", func(t *testing.T) {
+		_, err := tools.Replay(context.Background(), breakoutStrategy{}, nil)
+		require.ErrorIs(t, err, tools.ErrReplayWindow)
+
+		_, err = tools.Replay(context.Background(), breakoutStrategy{}, []model.Candle{
+			{Pair: "BTCUSDT"}, {Pair: "ETHUSDT"},
+		})
+		require.ErrorIs(t, err, tools.ErrReplayWindow)
+	})
+}
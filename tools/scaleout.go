@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+)
+
+var ErrInvalidLadder = errors.New("invalid scale-out ladder")
+
+// ladderTolerance absorbs float errors of the sum of the fractions, e.g. 0.1 + 0.2 + 0.7
+const ladderTolerance = 1e-9
+
+// ScaleOutLevel is a take-profit of a ladder, it closes the fraction of the initial quantity of the position
+// when the profit reaches the level, e.g. {Profit: 0.02, Fraction: 0.25} closes 25% at +2%
+type ScaleOutLevel struct {
+	Profit   float64
+	Fraction float64
+}
+
+// ScaleOut closes a position in parts across multiple take-profit levels, e.g. 25% at +2%, 25% at +4% and
+// 50% at +6%, and moves the stop to the entry price (break-even) after the first take-profit.
+//
+// The levels are checked with the high (long) or low (short) of the candle, as in ProfitLock, and each level
+// is closed once with a market order, so every partial close is registered as its own trade. The quantities
+// are cumulative fractions of the initial quantity rounded down to the step size of the asset, the remainder
+// of the rounding is carried to the next level. When the fractions sum to 1, the last level closes all the
+// remaining quantity.
+//
+// The ladder also manages the stop of the position: when the low (long) or high (short) of a candle reaches
+// the stop, the remaining quantity is closed with a market order. The stop of the previous candle is checked
+// before the levels, the worst case when a candle reaches both. When the fractions sum to less than 1, the
+// rest of the position is protected by the stop after the last level.
+type ScaleOut struct {
+	levels    []ScaleOutLevel
+	pair      string
+	side      model.SideType
+	entry     float64
+	quantity  float64
+	closed    float64
+	stop      float64
+	info      model.AssetInfo
+	next      int
+	active    bool
+	breakEven bool
+}
+
+// NewScaleOut creates a ladder with the levels, sorted by profit. The profits and fractions must be positive,
+// and the fractions must sum to 1 or less, otherwise it returns ErrInvalidLadder.
+func NewScaleOut(levels ...ScaleOutLevel) (*ScaleOut, error) {
+	if len(levels) == 0 {
+		return nil, fmt.Errorf("%w: no levels", ErrInvalidLadder)
+	}
+
+	var total float64
+	for _, level := range levels {
+		if level.Profit <= 0 || level.Fraction <= 0 {
+			return nil, fmt.Errorf("%w: profit %f and fraction %f must be positive", ErrInvalidLadder,
+				level.Profit, level.Fraction)
+		}
+		total += level.Fraction
+	}
+
+	if total > 1+ladderTolerance {
+		return nil, fmt.Errorf("%w: fractions sum to %f", ErrInvalidLadder, total)
+	}
+
+	levels = append([]ScaleOutLevel(nil), levels...)
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Profit < levels[j].Profit
+	})
+	return &ScaleOut{levels: levels}, nil
+}
+
+// Start manages a new position, the side is the side of the entry order and the info is used to round the
+// quantities of the partial closes, see service.Feeder.AssetsInfo
+func (s *ScaleOut) Start(pair string, side model.SideType, entry, quantity, stop float64, info model.AssetInfo) {
+	s.pair = pair
+	s.side = side
+	s.entry = entry
+	s.quantity = quantity
+	s.closed = 0
+	s.stop = stop
+	s.info = info
+	s.next = 0
+	s.active = true
+	s.breakEven = false
+}
+
+func (s *ScaleOut) Stop() {
+	s.active = false
+}
+
+func (s ScaleOut) Active() bool {
+	return s.active
+}
+
+// BreakEven returns if the first take-profit was reached for the current position
+func (s ScaleOut) BreakEven() bool {
+	return s.breakEven
+}
+
+// StopPrice returns the current stop of the position
+func (s ScaleOut) StopPrice() float64 {
+	return s.stop
+}
+
+// Remaining returns the quantity of the position not closed by the ladder
+func (s ScaleOut) Remaining() float64 {
+	return s.quantity - s.closed
+}
+
+// Update closes the remaining quantity when the candle reaches the stop, otherwise the parts of the levels
+// reached by the candle, and returns the orders. The position must not be protected by other stop orders in
+// the exchange, e.g. an OCO bracket, they are not resized by the ladder. A failed order stops the update, the
+// stop or the level is retried in the next candle. The ladder is stopped when the position is closed.
+func (s *ScaleOut) Update(candle model.Candle, broker service.Broker) ([]model.Order, error) {
+	if !s.active {
+		return nil, nil
+	}
+
+	exit := model.SideTypeSell
+	if s.side == model.SideTypeSell {
+		exit = model.SideTypeBuy
+	}
+
+	if s.stop > 0 && (s.side == model.SideTypeSell && candle.High >= s.stop ||
+		s.side != model.SideTypeSell && candle.Low <= s.stop) {
+		order, err := broker.CreateOrderMarket(exit, s.pair, s.Remaining())
+		if err != nil {
+			return nil, fmt.Errorf("scale-out %s stop at %f: %w", s.pair, s.stop, err)
+		}
+		s.closed = s.quantity
+		s.active = false
+		return []model.Order{order}, nil
+	}
+
+	var orders []model.Order
+	var fraction float64
+	for _, level := range s.levels[:s.next] {
+		fraction += level.Fraction
+	}
+
+	for ; s.next < len(s.levels); s.next++ {
+		level := s.levels[s.next]
+		if s.side == model.SideTypeSell && candle.Low > s.entry*(1-level.Profit) ||
+			s.side != model.SideTypeSell && candle.High < s.entry*(1+level.Profit) {
+			break
+		}
+		fraction += level.Fraction
+
+		quantity := s.Remaining()
+		last := s.next == len(s.levels)-1 && fraction >= 1-ladderTolerance
+		if !last {
+			quantity = s.info.RoundQuantity(s.quantity*fraction - s.closed)
+		}
+
+		if quantity > 0 && quantity >= s.info.MinQuantity {
+			order, err := broker.CreateOrderMarket(exit, s.pair, quantity)
+			if err != nil {
+				return orders, fmt.Errorf("scale-out %s at %.2f%%: %w", s.pair, level.Profit*100, err)
+			}
+			s.closed += quantity
+			if last {
+				s.closed = s.quantity
+			}
+			orders = append(orders, order)
+		}
+
+		// the stop is moved once, never backward
+		if !s.breakEven {
+			s.breakEven = true
+			if s.side == model.SideTypeSell && s.entry < s.stop || s.side != model.SideTypeSell && s.entry > s.stop {
+				s.stop = s.entry
+			}
+		}
+	}
+
+	// with fractions below 1, the rest of the position is closed by the stop
+	if s.next == len(s.levels) && (s.Remaining() <= 0 || s.stop <= 0) {
+		s.active = false
+	}
+	return orders, nil
+}
@@ -0,0 +1,183 @@
+package tools_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/exchange"
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/order"
+	"github.com/rodrigo-brito/ninjabot/storage"
+	"github.com/rodrigo-brito/ninjabot/tools"
+)
+
+func TestNewScaleOut(t *testing.T) {
+	_, err := tools.NewScaleOut()
+	require.ErrorIs(t, err, tools.ErrInvalidLadder)
+
+	_, err = tools.NewScaleOut(tools.ScaleOutLevel{Profit: 0.02, Fraction: 0})
+	require.ErrorIs(t, err, tools.ErrInvalidLadder)
+
+	_, err = tools.NewScaleOut(
+		tools.ScaleOutLevel{Profit: 0.02, Fraction: 0.5},
+		tools.ScaleOutLevel{Profit: 0.04, Fraction: 0.6},
+	)
+	require.ErrorIs(t, err, tools.ErrInvalidLadder)
+
+	_, err = tools.NewScaleOut(
+		tools.ScaleOutLevel{Profit: 0.02, Fraction: 0.1},
+		tools.ScaleOutLevel{Profit: 0.04, Fraction: 0.2},
+		tools.ScaleOutLevel{Profit: 0.06, Fraction: 0.7},
+	)
+	require.NoError(t, err)
+}
+
+func TestScaleOut_Update(t *testing.T) {
+	info := model.AssetInfo{StepSize: 0.1}
+	ladder := []tools.ScaleOutLevel{
+		{Profit: 0.06, Fraction: 0.5},
+		{Profit: 0.02, Fraction: 0.25},
+		{Profit: 0.04, Fraction: 0.25},
+	}
+
+	newController := func(t *testing.T, assets ...exchange.PaperWalletOption) (*order.Controller,
+		func(price float64) model.Candle) {
+
+		ctx := context.Background()
+		memory, err := storage.FromMemory()
+		require.NoError(t, err)
+		wallet := exchange.NewPaperWallet(ctx, "USDT", assets...)
+		controller := order.NewController(ctx, wallet, memory, order.NewOrderFeed())
+
+		now := time.Now()
+		candle := func(price float64) model.Candle {
+			now = now.Add(time.Minute)
+			candle := model.Candle{Time: now, Pair: "BTCUSDT", Close: price, High: price, Low: price}
+			wallet.OnCandle(candle)
+			controller.OnCandle(candle)
+			return candle
+		}
+		return controller, candle
+	}
+
+	t.Run("long", func(t *testing.T) {
+		controller, candle := newController(t, exchange.WithPaperAsset("USDT", 1000))
+		scaleOut, err := tools.NewScaleOut(ladder...)
+		require.NoError(t, err)
+
+		// not started
+		orders, err := scaleOut.Update(candle(100), controller)
+		require.NoError(t, err)
+		require.Empty(t, orders)
+
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		scaleOut.Start("BTCUSDT", model.SideTypeBuy, 100, 1, 95, info)
+
+		// below the first level
+		orders, err = scaleOut.Update(candle(101), controller)
+		require.NoError(t, err)
+		require.Empty(t, orders)
+		require.False(t, scaleOut.BreakEven())
+		require.Equal(t, 95.0, scaleOut.StopPrice())
+
+		// first level, 25% rounded down to the step size and the stop moved to break-even
+		orders, err = scaleOut.Update(candle(102), controller)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, model.SideTypeSell, orders[0].Side)
+		require.InDelta(t, 0.2, orders[0].Quantity, 1e-9)
+		require.True(t, scaleOut.BreakEven())
+		require.Equal(t, 100.0, scaleOut.StopPrice())
+
+		// second level, the remainder of the rounding is carried
+		orders, err = scaleOut.Update(candle(104), controller)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.InDelta(t, 0.3, orders[0].Quantity, 1e-9)
+		require.InDelta(t, 0.5, scaleOut.Remaining(), 1e-9)
+
+		// last level closes all the remaining quantity
+		orders, err = scaleOut.Update(candle(106), controller)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.InDelta(t, 0.5, orders[0].Quantity, 1e-9)
+		require.InDelta(t, 0, scaleOut.Remaining(), 1e-9)
+		require.False(t, scaleOut.Active())
+
+		// each partial close is a trade
+		trades := controller.Results["BTCUSDT"].Trades
+		require.Len(t, trades, 3)
+		require.InDelta(t, 0.4, trades[0].ProfitValue, 1e-6)
+		require.InDelta(t, 1.2, trades[1].ProfitValue, 1e-6)
+		require.InDelta(t, 3, trades[2].ProfitValue, 1e-6)
+
+		orders, err = scaleOut.Update(candle(110), controller)
+		require.NoError(t, err)
+		require.Empty(t, orders)
+	})
+
+	t.Run("short with multiple levels in a candle", func(t *testing.T) {
+		controller, candle := newController(t, exchange.WithPaperAsset("USDT", 1000),
+			exchange.WithPaperAsset("BTC", 2))
+		scaleOut, err := tools.NewScaleOut(ladder[:2]...)
+		require.NoError(t, err)
+
+		candle(100)
+		_, err = controller.CreateOrderMarket(model.SideTypeSell, "BTCUSDT", 2)
+		require.NoError(t, err)
+		scaleOut.Start("BTCUSDT", model.SideTypeSell, 100, 2, 105, info)
+
+		// the profit of both levels is reached, fractions below 1 keep the rest of the position
+		orders, err := scaleOut.Update(candle(93), controller)
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		require.Equal(t, model.SideTypeBuy, orders[0].Side)
+		require.InDelta(t, 0.5, orders[0].Quantity, 1e-9)
+		require.InDelta(t, 1, orders[1].Quantity, 1e-9)
+		require.InDelta(t, 0.5, scaleOut.Remaining(), 1e-9)
+		require.Equal(t, 100.0, scaleOut.StopPrice())
+		require.True(t, scaleOut.Active())
+		require.Len(t, controller.Results["BTCUSDT"].Trades, 2)
+
+		// the rest of the position is closed by the stop at break-even
+		orders, err = scaleOut.Update(candle(100), controller)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.InDelta(t, 0.5, orders[0].Quantity, 1e-9)
+		require.False(t, scaleOut.Active())
+		require.Len(t, controller.Results["BTCUSDT"].Trades, 3)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		controller, candle := newController(t, exchange.WithPaperAsset("USDT", 1000))
+		scaleOut, err := tools.NewScaleOut(ladder...)
+		require.NoError(t, err)
+
+		candle(100)
+		_, err = controller.CreateOrderMarket(model.SideTypeBuy, "BTCUSDT", 1)
+		require.NoError(t, err)
+		scaleOut.Start("BTCUSDT", model.SideTypeBuy, 100, 1, 95, info)
+
+		// first level, the stop is moved to break-even
+		orders, err := scaleOut.Update(candle(102), controller)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+
+		// the remaining quantity is closed at the stop
+		orders, err = scaleOut.Update(candle(99), controller)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		require.Equal(t, model.SideTypeSell, orders[0].Side)
+		require.InDelta(t, 0.8, orders[0].Quantity, 1e-9)
+		require.InDelta(t, 0, scaleOut.Remaining(), 1e-9)
+		require.False(t, scaleOut.Active())
+
+		asset, _, err := controller.Position("BTCUSDT")
+		require.NoError(t, err)
+		require.InDelta(t, 0, asset, 1e-9)
+	})
+}
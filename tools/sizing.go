@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+)
+
+var (
+	ErrInvalidSizeParameter = errors.New("invalid position size parameter")
+	ErrSizeBelowMinimum     = errors.New("position size below minimum quantity")
+)
+
+// minRealizedVolatility avoids oversized positions when recent returns are almost constant
+const minRealizedVolatility = 1e-6
+
+// VolTargetSize returns the quantity of an asset to hold so the position annualized volatility matches
+// targetVolAnnual of the equity, e.g. a target of 0.2 with a realized volatility of 0.8 invests 25% of equity.
+// The realized volatility is the sample standard deviation of returns annualized by periodsPerYear.
+// When realized volatility is close to zero, the position is capped to the whole equity (no leverage).
+// The quantity is rounded down to the asset step size and precision, and limited by MaxQuantity.
+func VolTargetSize(equity, targetVolAnnual float64, returns []float64, price float64, periodsPerYear int,
+	info model.AssetInfo) (float64, error) {
+
+	if equity <= 0 || targetVolAnnual <= 0 || price <= 0 || periodsPerYear <= 0 {
+		return 0, ErrInvalidSizeParameter
+	}
+
+	if len(returns) < 2 {
+		return 0, fmt.Errorf("%w: at least 2 returns required, got %d", ErrInvalidSizeParameter, len(returns))
+	}
+
+	var mean float64
+	for _, value := range returns {
+		mean += value
+	}
+	mean /= float64(len(returns))
+
+	var variance float64
+	for _, value := range returns {
+		variance += (value - mean) * (value - mean)
+	}
+	variance /= float64(len(returns) - 1)
+
+	notional := equity
+	realizedVol := math.Sqrt(variance) * math.Sqrt(float64(periodsPerYear))
+	if realizedVol > minRealizedVolatility {
+		notional = math.Min(equity*targetVolAnnual/realizedVol, equity)
+	}
+
+	return roundQuantity(notional/price, info)
+}
+
+// KellySize returns the fraction of capital to risk by the Kelly criterion, winRate - (1 - winRate) / winLossRatio,
+// scaled by a safety fraction, e.g. 0.5 for half-Kelly. The winLossRatio is the average win divided by the
+// average loss. Negative edges and invalid inputs return 0, the trade should not be taken.
+func KellySize(winRate, winLossRatio, fraction float64) float64 {
+	if winRate <= 0 || winRate > 1 || winLossRatio <= 0 || fraction <= 0 {
+		return 0
+	}
+
+	kelly := winRate - (1-winRate)/winLossRatio
+	if kelly <= 0 {
+		return 0
+	}
+	return kelly * fraction
+}
+
+// KellyQuantity returns the quantity of an asset to buy with the fraction of equity given by KellySize,
+// capped to the whole equity (no leverage) and rounded like VolTargetSize
+func KellyQuantity(equity, price, winRate, winLossRatio, fraction float64, info model.AssetInfo) (float64, error) {
+	if equity <= 0 || price <= 0 {
+		return 0, ErrInvalidSizeParameter
+	}
+
+	notional := equity * math.Min(KellySize(winRate, winLossRatio, fraction), 1)
+	return roundQuantity(notional/price, info)
+}
+
+// roundQuantity rounds the quantity down to the asset step size and precision, limited by MaxQuantity.
+// It returns ErrSizeBelowMinimum when the result is zero or below MinQuantity.
+func roundQuantity(quantity float64, info model.AssetInfo) (float64, error) {
+	if info.MaxQuantity > 0 {
+		quantity = math.Min(quantity, info.MaxQuantity)
+	}
+
+	quantity = info.RoundQuantity(quantity)
+	if quantity <= 0 || quantity < info.MinQuantity {
+		return 0, fmt.Errorf("%w: %f < %f", ErrSizeBelowMinimum, quantity, info.MinQuantity)
+	}
+
+	return quantity, nil
+}
@@ -0,0 +1,130 @@
+package tools_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/tools"
+)
+
+func TestVolTargetSize(t *testing.T) {
+	info := model.AssetInfo{
+		MinQuantity:        0.001,
+		MaxQuantity:        1000,
+		StepSize:           0.001,
+		BaseAssetPrecision: 3,
+	}
+
+	highVol := make([]float64, 0)
+	lowVol := make([]float64, 0)
+	for i := 0; i < 30; i++ {
+		sign := 1.0
+		if i%2 == 0 {
+			sign = -1.0
+		}
+		highVol = append(highVol, sign*0.05)
+		lowVol = append(lowVol, sign*0.005)
+	}
+
+	t.Run("high volatility", func(t *testing.T) {
+		// realized vol = 0.05085 * sqrt(365) = 0.9716, notional = 10000 * 0.2 / 0.9716 = 2058.5
+		size, err := tools.VolTargetSize(10000, 0.2, highVol, 100, 365, info)
+		require.NoError(t, err)
+		require.Equal(t, 20.585, size)
+	})
+
+	t.Run("low volatility", func(t *testing.T) {
+		// realized vol = 0.09716, notional = 10000 * 0.2 / 0.0971 is capped by equity
+		size, err := tools.VolTargetSize(10000, 0.2, lowVol, 100, 365, info)
+		require.NoError(t, err)
+		require.Equal(t, 100.0, size)
+
+		size, err = tools.VolTargetSize(10000, 0.05, lowVol, 100, 365, info)
+		require.NoError(t, err)
+		require.Equal(t, 51.462, size)
+	})
+
+	t.Run("near zero volatility", func(t *testing.T) {
+		size, err := tools.VolTargetSize(10000, 0.2, []float64{0.01, 0.01, 0.01}, 100, 365, info)
+		require.NoError(t, err)
+		require.Equal(t, 100.0, size)
+	})
+
+	t.Run("step boundary", func(t *testing.T) {
+		// 0.3 / 0.1 = 2.9999999999999996 is not rounded down to 0.2
+		size, err := tools.VolTargetSize(30, 0.2, []float64{0.01, 0.01, 0.01}, 100, 365,
+			model.AssetInfo{StepSize: 0.1})
+		require.NoError(t, err)
+		require.Equal(t, 0.3, size)
+	})
+
+	t.Run("max quantity", func(t *testing.T) {
+		size, err := tools.VolTargetSize(10000, 0.2, lowVol, 1, 365, info)
+		require.NoError(t, err)
+		require.Equal(t, 1000.0, size)
+	})
+
+	t.Run("below minimum", func(t *testing.T) {
+		_, err := tools.VolTargetSize(0.01, 0.2, highVol, 100, 365, info)
+		require.ErrorIs(t, err, tools.ErrSizeBelowMinimum)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		_, err := tools.VolTargetSize(10000, 0.2, []float64{0.01}, 100, 365, info)
+		require.ErrorIs(t, err, tools.ErrInvalidSizeParameter)
+
+		_, err = tools.VolTargetSize(10000, 0.2, highVol, 0, 365, info)
+		require.ErrorIs(t, err, tools.ErrInvalidSizeParameter)
+	})
+}
+
+func TestKellySize(t *testing.T) {
+	t.Run("positive edge", func(t *testing.T) {
+		// 0.6 - 0.4 / 2 = 0.4
+		require.InDelta(t, 0.4, tools.KellySize(0.6, 2, 1), 1e-9)
+		require.InDelta(t, 0.2, tools.KellySize(0.6, 2, 0.5), 1e-9)
+		// 0.5 - 0.5 / 1.5 = 0.1667
+		require.InDelta(t, 0.0416667, tools.KellySize(0.5, 1.5, 0.25), 1e-6)
+		require.Equal(t, 1.0, tools.KellySize(1, 2, 1))
+	})
+
+	t.Run("negative edge", func(t *testing.T) {
+		// 0.4 - 0.6 / 1 = -0.2
+		require.Zero(t, tools.KellySize(0.4, 1, 0.5))
+		// break-even: 0.5 - 0.5 / 1 = 0
+		require.Zero(t, tools.KellySize(0.5, 1, 1))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		require.Zero(t, tools.KellySize(0, 2, 1))
+		require.Zero(t, tools.KellySize(1.2, 2, 1))
+		require.Zero(t, tools.KellySize(0.6, 0, 1))
+		require.Zero(t, tools.KellySize(0.6, 2, 0))
+	})
+}
+
+func TestKellyQuantity(t *testing.T) {
+	info := model.AssetInfo{
+		MinQuantity:        0.001,
+		StepSize:           0.001,
+		BaseAssetPrecision: 3,
+	}
+
+	// half-Kelly of 0.4, 2000 of 10000 at 300
+	size, err := tools.KellyQuantity(10000, 300, 0.6, 2, 0.5, info)
+	require.NoError(t, err)
+	require.Equal(t, 6.666, size)
+
+	// capped to the whole equity with leveraged fractions
+	size, err = tools.KellyQuantity(10000, 100, 0.9, 9, 2, info)
+	require.NoError(t, err)
+	require.Equal(t, 100.0, size)
+
+	_, err = tools.KellyQuantity(10000, 300, 0.4, 1, 0.5, info)
+	require.ErrorIs(t, err, tools.ErrSizeBelowMinimum)
+
+	_, err = tools.KellyQuantity(0, 300, 0.6, 2, 0.5, info)
+	require.ErrorIs(t, err, tools.ErrInvalidSizeParameter)
+}
@@ -38,3 +38,18 @@ func (t *TrailingStop) Update(current float64) bool {
 	t.current = current
 	return current <= t.stop
 }
+
+// StopPrice returns the current stop price
+func (t TrailingStop) StopPrice() float64 {
+	return t.stop
+}
+
+// Raise moves the stop up to the given price, e.g. to break-even with ProfitLock.
+// The stop never moves backward, it returns false when the given price is not above the current stop.
+func (t *TrailingStop) Raise(stop float64) bool {
+	if !t.active || stop <= t.stop {
+		return false
+	}
+	t.stop = stop
+	return true
+}
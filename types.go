@@ -12,6 +12,8 @@ type (
 	SideType         = model.SideType
 	OrderType        = model.OrderType
 	OrderStatusType  = model.OrderStatusType
+	Mode             = model.Mode
+	Signal           = model.Signal
 )
 
 var (
@@ -31,4 +33,8 @@ var (
 	OrderStatusTypePendingCancel   = model.OrderStatusTypePendingCancel
 	OrderStatusTypeRejected        = model.OrderStatusTypeRejected
 	OrderStatusTypeExpired         = model.OrderStatusTypeExpired
+	ModeLive                       = model.ModeLive
+	ModePaper                      = model.ModePaper
+	ModeDryRun                     = model.ModeDryRun
+	ModeSignal                     = model.ModeSignal
 )
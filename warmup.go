@@ -0,0 +1,106 @@
+package ninjabot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+// warmupMonitor notifies once when the dataframes of all pairs reach the strategy warmup period,
+// and warns when the warmup is not completed before the timeout, e.g. a problem in the data feed
+type warmupMonitor struct {
+	mtx         sync.Mutex
+	controllers map[string]*strategy.Controller
+	pending     map[string]bool
+	notifier    service.Notifier
+	logger      log.Logger
+	timer       *time.Timer
+	done        bool
+}
+
+func newWarmupMonitor(controllers map[string]*strategy.Controller, notifier service.Notifier,
+	logger log.Logger) *warmupMonitor {
+
+	pending := make(map[string]bool, len(controllers))
+	for pair := range controllers {
+		pending[pair] = true
+	}
+
+	return &warmupMonitor{
+		controllers: controllers,
+		pending:     pending,
+		notifier:    notifier,
+		logger:      logger,
+	}
+}
+
+// start checks the pairs already warmed up by the preload, a zero timeout disables the warning
+func (w *warmupMonitor) start(timeout time.Duration) {
+	for pair := range w.controllers {
+		w.check(pair)
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if timeout > 0 && !w.done {
+		w.timer = time.AfterFunc(timeout, func() {
+			w.onTimeout(timeout)
+		})
+	}
+}
+
+// check updates the warmup of the pair, it must be called after the candle is processed by the controller
+func (w *warmupMonitor) check(pair string) {
+	controller, ok := w.controllers[pair]
+	if !ok || !controller.WarmedUp() {
+		return
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.done || !w.pending[pair] {
+		return
+	}
+
+	delete(w.pending, pair)
+	if len(w.pending) > 0 {
+		return
+	}
+
+	w.done = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+
+	message := fmt.Sprintf("Warmup completed for %d pairs, bot is actively trading.", len(w.controllers))
+	w.logger.Info(message)
+	if w.notifier != nil {
+		w.notifier.Notify(message)
+	}
+}
+
+func (w *warmupMonitor) onTimeout(timeout time.Duration) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.done {
+		return
+	}
+
+	pairs := make([]string, 0, len(w.pending))
+	for pair := range w.pending {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+
+	message := fmt.Sprintf("Warmup not completed after %s, waiting for: %s", timeout, strings.Join(pairs, ", "))
+	w.logger.Warn(message)
+	if w.notifier != nil {
+		w.notifier.Notify(message)
+	}
+}
@@ -0,0 +1,129 @@
+package ninjabot
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rodrigo-brito/ninjabot/model"
+	"github.com/rodrigo-brito/ninjabot/service"
+	"github.com/rodrigo-brito/ninjabot/strategy"
+	"github.com/rodrigo-brito/ninjabot/tools/log"
+)
+
+type warmupStrategy struct{}
+
+func (w warmupStrategy) Timeframe() string {
+	return "1h"
+}
+
+func (w warmupStrategy) WarmupPeriod() int {
+	return 3
+}
+
+func (w warmupStrategy) Indicators(_ *model.Dataframe) []strategy.ChartIndicator {
+	return nil
+}
+
+func (w warmupStrategy) OnCandle(_ *model.Dataframe, _ service.Broker) {}
+
+type notifierRecorder struct {
+	mtx      sync.Mutex
+	messages []string
+}
+
+func (n *notifierRecorder) Notify(message string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.messages = append(n.messages, message)
+}
+
+func (n *notifierRecorder) Messages() []string {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return append([]string(nil), n.messages...)
+}
+
+func (n *notifierRecorder) OnOrder(_ model.Order) {}
+
+func (n *notifierRecorder) OnError(_ error) {}
+
+func TestWarmupMonitor(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	setup := func() (map[string]*strategy.Controller, func(pair string, i int)) {
+		controllers := map[string]*strategy.Controller{
+			"BTCUSDT": strategy.NewStrategyController("BTCUSDT", warmupStrategy{}, nil),
+			"ETHUSDT": strategy.NewStrategyController("ETHUSDT", warmupStrategy{}, nil),
+		}
+		feed := func(pair string, i int) {
+			controllers[pair].OnCandle(model.Candle{Pair: pair, Time: start.Add(time.Duration(i) * time.Hour),
+				Close: 10, Complete: true})
+		}
+		return controllers, feed
+	}
+	logger := log.NewStdLogger(io.Discard, log.InfoLevel)
+
+	t.Run("notify once", func(t *testing.T) {
+		controllers, feed := setup()
+		notifier := &notifierRecorder{}
+		monitor := newWarmupMonitor(controllers, notifier, logger)
+		monitor.start(0)
+
+		for i := 0; i < 5; i++ {
+			feed("BTCUSDT", i)
+			monitor.check("BTCUSDT")
+		}
+		// only one pair is warmed up
+		require.Empty(t, notifier.Messages())
+
+		for i := 0; i < 5; i++ {
+			feed("ETHUSDT", i)
+			monitor.check("ETHUSDT")
+			if i < 2 {
+				require.Empty(t, notifier.Messages())
+			}
+		}
+		require.Equal(t, []string{"Warmup completed for 2 pairs, bot is actively trading."}, notifier.Messages())
+	})
+
+	t.Run("preloaded", func(t *testing.T) {
+		controllers, feed := setup()
+		for i := 0; i < 3; i++ {
+			feed("BTCUSDT", i)
+			feed("ETHUSDT", i)
+		}
+
+		notifier := &notifierRecorder{}
+		monitor := newWarmupMonitor(controllers, notifier, logger)
+		monitor.start(time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		require.Len(t, notifier.Messages(), 1)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		controllers, feed := setup()
+		notifier := &notifierRecorder{}
+		monitor := newWarmupMonitor(controllers, notifier, logger)
+		monitor.start(10 * time.Millisecond)
+
+		for i := 0; i < 3; i++ {
+			feed("BTCUSDT", i)
+			monitor.check("BTCUSDT")
+		}
+
+		require.Eventually(t, func() bool {
+			return len(notifier.Messages()) == 1
+		}, time.Second, time.Millisecond)
+		require.Equal(t, "Warmup not completed after 10ms, waiting for: ETHUSDT", notifier.Messages()[0])
+
+		// completion is still notified after the warning
+		for i := 0; i < 3; i++ {
+			feed("ETHUSDT", i)
+			monitor.check("ETHUSDT")
+		}
+		require.Len(t, notifier.Messages(), 2)
+	})
+}